@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/app"
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/ipc"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/schedule"
+	"wallpaper-changer/internal/store"
+	"wallpaper-changer/internal/winlog"
+)
+
+// runCommand handles CLI subcommands and returns the process exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "config":
+		return runConfigCommand(args[1:])
+	case "doctor":
+		return runDoctorCommand()
+	case "schedule":
+		return runScheduleCommand()
+	case "lists":
+		return runListsCommand()
+	case "eventlog":
+		return runEventLogCommand(args[1:])
+	case "status", "change", "exit":
+		return runControlCommand(args[0])
+	case "favorites":
+		if len(args) != 2 || args[1] != "refresh" {
+			fmt.Fprintln(os.Stderr, "usage: go-wallpaper-tray favorites refresh")
+			return 2
+		}
+		return runControlCommand("favorites refresh")
+	case "--export-registry":
+		return runExportRegistry()
+	case "--import-registry":
+		return runImportRegistry()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		return 2
+	}
+}
+
+// takeSeedFlag removes "--seed N" or "--seed=N" from the front of args,
+// where it may come before a subcommand, and returns N.
+func takeSeedFlag(args []string) (rest []string, seed int64, ok bool, err error) {
+	if len(args) == 0 {
+		return args, 0, false, nil
+	}
+	var v string
+	switch {
+	case args[0] == "--seed":
+		if len(args) < 2 {
+			return nil, 0, false, errors.New("--seed needs a number")
+		}
+		v, rest = args[1], args[2:]
+	case strings.HasPrefix(args[0], "--seed="):
+		v, rest = strings.TrimPrefix(args[0], "--seed="), args[1:]
+	default:
+		return args, 0, false, nil
+	}
+	seed, err = strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("--seed: %q is not a whole number", v)
+	}
+	return rest, seed, true, nil
+}
+
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-wallpaper-tray config init [--force] [--format json|yaml] [path] | validate [path]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	format := fs.String("format", "json", "file format for init: "+strings.Join(config.Formats, " or "))
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if !slices.Contains(config.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		appDir, err := store.ResolveAppDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.MkdirAll(appDir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		path = config.FilePath(appDir)
+		if args[0] == "init" {
+			path = filepath.Join(appDir, config.FileName)
+			if *format == "yaml" {
+				path = filepath.Join(appDir, config.YAMLFileName)
+			}
+		}
+	} else if args[0] == "init" && *format == "yaml" && !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+		fmt.Fprintln(os.Stderr, "--format yaml needs a .yaml or .yml path")
+		return 2
+	}
+
+	switch args[0] {
+	case "init":
+		if err := config.WriteDefault(path, *force); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("wrote", path)
+		return 0
+	case "validate":
+		_, problems, err := config.Load(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, path+":", err)
+			return 1
+		}
+		for _, p := range problems {
+			fmt.Println(path+":", p)
+		}
+		if len(problems) > 0 {
+			return 1
+		}
+		fmt.Println(path + ": OK")
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command %q\n", args[0])
+		return 2
+	}
+}
+
+// runScheduleCommand prints the next planned changes from the config file
+// and the app's state, whether or not the app is running.
+func runScheduleCommand() int {
+	appDir, err := store.ResolveAppDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	path := config.FilePath(appDir)
+	cfg, problems, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, path+":", err)
+		return 1
+	}
+	for _, p := range problems {
+		fmt.Println(path+":", p)
+	}
+	now := time.Now()
+	st := schedule.State{
+		UpdatedToday: store.New(appDir, store.Hooks{}).WasUpdatedToday(now),
+		Policy:       policy.Current(appDir),
+	}
+	// An instance that answers on the pipe is past its startup catch-up.
+	if _, err := ipc.Send("status"); err == nil {
+		st.Running = true
+	}
+	for _, p := range schedule.Plan(cfg, st, now, schedule.PreviewLength) {
+		fmt.Println(p)
+	}
+	return 0
+}
+
+// runListsCommand prints the cached category list with when it was fetched.
+func runListsCommand() int {
+	appDir, err := store.ResolveAppDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	l, err := app.ReadSourceLists(store.New(appDir, store.Hooks{}))
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("no lists cached yet; they are fetched while the app runs, or with \"Refresh lists\" in the tray")
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if l.Fetched.IsZero() {
+		fmt.Printf("%s categories: never fetched\n", l.Source)
+	} else {
+		fmt.Printf("%s categories, fetched %s:\n", l.Source, l.Fetched.Format("2006-01-02 15:04"))
+	}
+	for _, c := range l.Categories {
+		fmt.Printf("  %-24s %s\n", c.Slug, c.Name)
+	}
+	if l.LastError != "" {
+		fmt.Printf("last refresh failed at %s: %s\n", l.Attempted.Format("2006-01-02 15:04"), l.LastError)
+	}
+	return 0
+}
+
+// runEventLogCommand installs or removes the Application log event source;
+// both need administrator rights.
+func runEventLogCommand(args []string) int {
+	if len(args) != 1 || (args[0] != "install" && args[0] != "remove") {
+		fmt.Fprintln(os.Stderr, "usage: go-wallpaper-tray eventlog install|remove")
+		return 2
+	}
+	op, verb := winlog.Install, "installed"
+	if args[0] == "remove" {
+		op, verb = winlog.Remove, "removed"
+	}
+	if err := op(); err != nil {
+		fmt.Fprintf(os.Stderr, "eventlog %s: %v\n", args[0], err)
+		return 1
+	}
+	fmt.Printf("event source %s %s\n", winlog.SourceName, verb)
+	return 0
+}
+
+// runControlCommand sends cmd to the running instance over the command pipe
+// and prints its reply.
+func runControlCommand(cmd string) int {
+	reply, err := ipc.Send(cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if strings.HasPrefix(reply, "error: ") {
+		fmt.Fprintln(os.Stderr, strings.TrimPrefix(reply, "error: "))
+		return 1
+	}
+	fmt.Println(reply)
+	return 0
+}
+
+// runExportRegistry copies config.json (or config.yaml) to the registry so it
+// can be turned into a Group Policy preference.
+func runExportRegistry() int {
+	appDir, err := store.ResolveAppDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	path := config.FilePath(appDir)
+	cfg, problems, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, path+":", err)
+		return 1
+	}
+	for _, p := range problems {
+		fmt.Println(path+":", p)
+	}
+	if err := config.ExportRegistry(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "export failed:", err)
+		return 1
+	}
+	fmt.Println(`wrote HKCU\` + config.RegistryPath)
+	return 0
+}
+
+// runImportRegistry writes the registry values, e.g. pushed by Group Policy,
+// to the config file (config.json unless a config.yaml is in use).
+func runImportRegistry() int {
+	cfg, problems, err := config.ImportRegistry()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, `HKCU\`+config.RegistryPath+":", err)
+		return 1
+	}
+	for _, p := range problems {
+		fmt.Println("registry:", p)
+	}
+	appDir, err := store.ResolveAppDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.MkdirAll(appDir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	path := config.FilePath(appDir)
+	if err := config.Save(path, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("wrote", path)
+	return 0
+}
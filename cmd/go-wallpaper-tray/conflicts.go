@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/setter"
+	"wallpaper-changer/internal/ui"
+)
+
+const (
+	// conflictCheckInterval is how often, after startup, other wallpaper
+	// apps are looked for again.
+	conflictCheckInterval = 24 * time.Hour
+	// conflictsWarnedFile lists the apps already warned about, one per line,
+	// so each warning shows once.
+	conflictsWarnedFile = "conflicts_warned.txt"
+)
+
+// watchConflicts looks for conflicting_apps once the tray menu is up, or
+// has had its chance, and then daily.
+func (t *tray) watchConflicts(ctx context.Context) {
+	select {
+	case <-t.ready:
+	case <-time.After(trayReadyTimeout):
+	case <-ctx.Done():
+		return
+	}
+	for {
+		t.checkConflicts()
+		select {
+		case <-time.After(conflictCheckInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkConflicts warns once about each conflicting app found running and,
+// while any is, offers respect_external_changes in the tray menu.
+func (t *tray) checkConflicts() {
+	cfg := t.live.Current()
+	if len(cfg.ConflictingApps) == 0 {
+		return
+	}
+	procs, err := setter.RunningProcesses()
+	if err != nil {
+		fmt.Println("conflicting app check failed:", err)
+		return
+	}
+	var running []string
+	for _, name := range cfg.ConflictingApps {
+		if procs[strings.ToLower(name)] {
+			running = append(running, name)
+		}
+	}
+	if menu := t.conflicts.Load(); menu != nil {
+		if cfg.RespectExternalChanges {
+			menu.Offer(nil)
+		} else {
+			menu.Offer(running)
+		}
+	}
+
+	warned := map[string]bool{}
+	if b, err := t.store.Read(conflictsWarnedFile); err == nil {
+		for _, name := range strings.Fields(string(b)) {
+			warned[name] = true
+		}
+	}
+	var fresh []string
+	for _, name := range running {
+		if !warned[strings.ToLower(name)] {
+			fresh = append(fresh, name)
+			warned[strings.ToLower(name)] = true
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	msg := strings.Join(fresh, ", ") + " also changes the wallpaper and the two will undo each other's changes"
+	if !cfg.RespectExternalChanges {
+		msg += `; choose "Let ` + strings.Join(running, ", ") + ` change the wallpaper" in the tray menu to stop overriding it`
+	}
+	ui.ShowMessage("Another wallpaper app is running", msg)
+	names := make([]string, 0, len(warned))
+	for name := range warned {
+		names = append(names, name)
+	}
+	if err := t.store.Write(conflictsWarnedFile, []byte(strings.Join(names, "\n")+"\n")); err != nil {
+		fmt.Println("failed to save conflict warnings:", err)
+	}
+}
+
+// respectExternalChanges saves respect_external_changes locally, from the
+// conflict item.
+func (t *tray) respectExternalChanges(menu *ui.ConflictMenu) {
+	cfg := t.live.Local()
+	cfg.RespectExternalChanges = true
+	if appDir := t.store.Dir(); appDir != "" {
+		if err := config.Save(config.FilePath(appDir), cfg); err != nil {
+			fmt.Println("failed to save config:", err)
+		}
+	}
+	t.live.SetLocal(cfg)
+	menu.Offer(nil)
+	ui.ShowMessage("Respecting other apps", "scheduled changes are skipped while another app's wallpaper is showing")
+}
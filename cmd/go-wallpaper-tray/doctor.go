@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/doctor"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/setter"
+	"wallpaper-changer/internal/source"
+	"wallpaper-changer/internal/store"
+	"wallpaper-changer/internal/ui"
+)
+
+// trayErrDoctor keys the tray warning for failed startup checks.
+const trayErrDoctor = "doctor"
+
+// doctorEnv wires the real implementations the checks run against.
+func doctorEnv(appDir string, cfg config.Config, client *fetch.Client) doctor.Env {
+	return doctor.Env{
+		AppDir:     appDir,
+		ConfigPath: config.FilePath(appDir),
+		Client:     client,
+		NewSource: func() (source.WallpaperSource, error) {
+			return source.New(cfg, source.Deps{Client: client, AppDir: appDir, Now: time.Now})
+		},
+		CurrentWallpaper: setter.CurrentWallpaper,
+		AutostartCommand: func() (string, bool, error) { return doctor.RunKeyCommand(store.FolderName) },
+		Executable:       os.Executable,
+	}
+}
+
+// runDoctorCommand runs every check and prints pass/fail with hints.
+func runDoctorCommand() int {
+	appDir, err := store.ResolveAppDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cfg, _, err := config.Load(config.FilePath(appDir))
+	if err != nil {
+		cfg = config.Default() // "config valid" reports the error
+	}
+	env := doctorEnv(appDir, cfg, fetch.New(http.DefaultClient, store.FolderName))
+	results := doctor.Run(context.Background(), doctor.Checks(env), false)
+	for _, r := range results {
+		if r.Err == nil {
+			fmt.Println("PASS", r.Name)
+			continue
+		}
+		fmt.Printf("FAIL %s: %v\n     hint: %s\n", r.Name, r.Err, r.Hint)
+	}
+	if len(doctor.Failed(results)) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// startupChecks runs the quick offline checks and badges the tray on failure.
+func (t *tray) startupChecks(ctx context.Context) {
+	appDir := t.store.Dir()
+	if appDir == "" {
+		return // the data dir error is already shown
+	}
+	env := doctorEnv(appDir, t.live.Current(), fetch.New(http.DefaultClient, store.FolderName))
+	failed := doctor.Failed(doctor.Run(ctx, doctor.Checks(env), true))
+	if len(failed) == 0 {
+		return
+	}
+	names := make([]string, len(failed))
+	for i, r := range failed {
+		fmt.Printf("startup check failed: %s: %v (%s)\n", r.Name, r.Err, r.Hint)
+		names[i] = r.Name
+	}
+	ui.SetError(trayErrDoctor, "Startup checks failed: "+strings.Join(names, ", ")+` - run "go-wallpaper-tray doctor"`)
+}
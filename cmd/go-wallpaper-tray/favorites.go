@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"wallpaper-changer/internal/favorites"
+	"wallpaper-changer/internal/ui"
+)
+
+// toggleFavorite adds the current wallpaper to the favorites or removes it,
+// from the favorite item.
+func (t *tray) toggleFavorite(menu *ui.FavoriteMenu) {
+	t.infoMu.Lock()
+	e := t.current
+	t.infoMu.Unlock()
+	if e == nil {
+		ui.ShowError("no wallpaper set yet to keep as a favorite")
+		menu.Check(false)
+		return
+	}
+	favs, err := t.changes.Favorites()
+	if err != nil {
+		ui.ShowError(err.Error())
+		return
+	}
+	if fav, ok := favs.Find(*e); ok {
+		if err := favs.Remove(fav.File); err != nil {
+			ui.ShowError("failed to remove the favorite: " + err.Error())
+			return
+		}
+		menu.Check(false)
+	} else {
+		if _, err := t.changes.AddFavorite(*e); err != nil {
+			ui.ShowError("failed to keep the favorite: " + err.Error())
+			menu.Check(false)
+			return
+		}
+		menu.Check(true)
+	}
+	select {
+	case t.favoritesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// checkFavorite updates the favorite item for the current wallpaper.
+func (t *tray) checkFavorite() {
+	menu := t.favorite.Load()
+	if menu == nil {
+		return
+	}
+	t.infoMu.Lock()
+	e := t.current
+	t.infoMu.Unlock()
+	favs, err := t.changes.Favorites()
+	if e == nil || err != nil {
+		menu.Check(false)
+		return
+	}
+	_, ok := favs.Find(*e)
+	menu.Check(ok)
+}
+
+// mirrorFavorites keeps the Pictures mirror of the favorites in sync while
+// mirror_favorites is on: when it is switched on, at startup included, and
+// after every favorite added or removed.
+func (t *tray) mirrorFavorites(ctx context.Context) {
+	on := false
+	for {
+		changed := t.live.Changed()
+		if now := t.live.Current().MirrorFavorites; now != on {
+			on = now
+			if on {
+				t.syncMirror()
+			}
+		}
+		select {
+		case <-t.favoritesChanged:
+			if on {
+				t.syncMirror()
+			}
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *tray) syncMirror() {
+	favs, err := t.changes.Favorites()
+	if err != nil {
+		fmt.Println("favorites mirror:", err)
+		return
+	}
+	root, err := favorites.MirrorDir()
+	if err != nil {
+		fmt.Println("favorites mirror:", err)
+		return
+	}
+	r, err := favs.Mirror(root)
+	if err != nil {
+		fmt.Println("favorites mirror:", err)
+		return
+	}
+	fmt.Printf("favorites mirror %s: %s\n", root, r)
+}
+
+const refetchTooltip = "Download the favorites again at the target monitor's resolution"
+
+// toggleRefetch starts re-fetching the favorites from the re-fetch item,
+// or cancels the run in progress.
+func (t *tray) toggleRefetch() {
+	t.refetchMu.Lock()
+	stop := t.refetchStop
+	t.refetchMu.Unlock()
+	if stop != nil {
+		stop()
+		return
+	}
+	t.startRefetch()
+}
+
+// startRefetch re-fetches the favorites in the background and shows the
+// summary when done. It reports false if a run is already in progress.
+func (t *tray) startRefetch() bool {
+	t.refetchMu.Lock()
+	defer t.refetchMu.Unlock()
+	if t.refetchStop != nil {
+		return false
+	}
+	ctx, stop := context.WithCancel(t.ctx)
+	t.refetchStop = stop
+	if t.refetchItem != nil {
+		t.refetchItem.SetTitle(ui.Label(ui.MsgRefetchFavorites, ui.StateRunning))
+		t.refetchItem.SetTooltip("Click to cancel the re-fetch")
+	}
+	go t.refetchFavorites(ctx)
+	return true
+}
+
+func (t *tray) refetchFavorites(ctx context.Context) {
+	r, err := t.changes.RefetchFavorites(ctx)
+	t.refetchMu.Lock()
+	t.refetchStop()
+	t.refetchStop = nil
+	if t.refetchItem != nil {
+		t.refetchItem.SetTitle(ui.Label(ui.MsgRefetchFavorites, ui.StateNone))
+		t.refetchItem.SetTooltip(refetchTooltip)
+	}
+	t.refetchMu.Unlock()
+
+	if r.Updated > 0 {
+		select {
+		case t.favoritesChanged <- struct{}{}:
+		default:
+		}
+	}
+	fmt.Println("favorites re-fetch:", r)
+	switch {
+	case t.ctx.Err() != nil:
+		// Exiting.
+	case errors.Is(err, context.Canceled):
+		ui.ShowMessage("Favorites re-fetch cancelled", "So far: "+r.String())
+	case err != nil:
+		ui.ShowError("re-fetching favorites: " + err.Error())
+	default:
+		ui.ShowMessage("Favorites re-fetched", r.String())
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wallpaper-changer/internal/app"
+	"wallpaper-changer/internal/ui"
+	"wallpaper-changer/internal/winlog"
+)
+
+// runTray shows the tray icon and blocks. systray can neither report that
+// the icon failed to register nor be started twice, so it is only started
+// once Explorer's notification area exists; if the icon still doesn't
+// appear, or the tray's message loop dies, the app keeps running headless
+// and is controlled through the command pipe.
+func (t *tray) runTray(ctx context.Context) {
+	if !ui.TaskbarPresent() {
+		fmt.Println("notification area unavailable, running headless until it appears;",
+			`use "go-wallpaper-tray status|change|exit" meanwhile`)
+		winlog.Enable("headless")
+		for !ui.TaskbarPresent() {
+			select {
+			case <-time.After(trayWaitInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	go func() {
+		select {
+		case <-t.ready:
+		case <-time.After(trayReadyTimeout):
+			fmt.Printf("tray icon did not appear within %s, running headless; "+
+				"use \"go-wallpaper-tray status|change|exit\"\n", trayReadyTimeout)
+			winlog.Enable("headless")
+		case <-ctx.Done():
+		}
+	}()
+	ui.RunTray(func() { t.onReady(ctx) }, t.onExit)
+
+	// Exit through the tray ends the process in onExit, so getting here means
+	// the message loop failed.
+	fmt.Println("tray message loop ended, running headless")
+	winlog.Enable("headless")
+	<-ctx.Done()
+}
+
+// quit ends the process, through systray when the icon is up so it is
+// removed from the notification area.
+func (t *tray) quit() {
+	t.cancel()
+	if ui.Ready() {
+		ui.QuitTray()
+		return
+	}
+	t.onExit()
+}
+
+// watchTrayIcon puts the icon back when the notification area loses it:
+// after Explorer signals TaskbarCreated, once systray has had the chance to
+// re-add it itself, and periodically in case that broadcast was missed.
+func (t *tray) watchTrayIcon(ctx context.Context, taskbar <-chan struct{}) {
+	for {
+		select {
+		case <-taskbar:
+			select {
+			case <-time.After(trayRestoreDelay):
+			case <-ctx.Done():
+				return
+			}
+		case <-time.After(trayWaitInterval):
+		case <-ctx.Done():
+			return
+		}
+		if ui.RestoreIcon() {
+			fmt.Println("tray icon was missing from the notification area, re-added it")
+		}
+	}
+}
+
+// control answers the CLI commands sent over the command pipe.
+func (t *tray) control(cmd string) (string, func()) {
+	switch cmd {
+	case "status":
+		status := ui.Status()
+		if !ui.Ready() {
+			status += "\n(running without a tray icon)"
+		}
+		return status, nil
+	case "change":
+		if err := t.changes.ChangeNow(app.InitiatorAPI); err != nil {
+			return "error: " + err.Error(), nil
+		}
+		return "wallpaper changed", nil
+	case "favorites refresh":
+		if !t.startRefetch() {
+			return "error: favorites are already being re-fetched", nil
+		}
+		return "re-fetching favorites in the background; the summary is shown when done", nil
+	case "exit":
+		return "exiting", t.quit
+	}
+	return fmt.Sprintf("error: unknown command %q", cmd), nil
+}
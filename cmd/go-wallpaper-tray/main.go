@@ -0,0 +1,674 @@
+// go-wallpaper-tray - Windows 10 daily wallpaper changer from wallscloud.net
+// (or another source selected by config "source", see internal/source)
+// Features:
+// - At 09:00 local time (config "change_time") each day the program requests https://wallscloud.net/ru/wallpapers/random
+//   and uses XPath //*[@id="main"]/div[4]/div[2]/figure[1]/div/a to get the <a href="..."> link.
+// - Appends "/WxH/download" (target monitor size, config "target_monitor") to the href and downloads the image.
+// - Converts downloaded image to BMP and sets as desktop wallpaper on Windows 10.
+// - If started after 09:00, checks whether today's wallpaper was already set (stores last date in a file).
+// - Runs in the system tray. Menu items: "Force change now", "Exit".
+// NOTE: Minimal error handling. Improve for production use.
+// This package only parses the command line and wires the tray together;
+// the work is done in wallpaper-changer/internal/...
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wallpaper-changer/internal/app"
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/ipc"
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/outbox"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/schedule"
+	"wallpaper-changer/internal/setter"
+	"wallpaper-changer/internal/source"
+	"wallpaper-changer/internal/store"
+	"wallpaper-changer/internal/trigger"
+	"wallpaper-changer/internal/ui"
+	"wallpaper-changer/internal/winlog"
+	"wallpaper-changer/internal/winmsg"
+)
+
+const (
+	// monitorPollInterval is how often attached monitors are re-listed.
+	monitorPollInterval = 10 * time.Second
+	// messageWindowClass names the hidden window receiving system broadcasts.
+	messageWindowClass = "GoWallpaperTrayMessages"
+	// trayErrFetch keys the tray warning naming why the last download failed.
+	trayErrFetch = "fetch"
+	// trayErrPolicy keys the tray notice that the kill switch is on.
+	trayErrPolicy = "policy"
+	// policyPollInterval is how often the tray rechecks the kill switch.
+	policyPollInterval = time.Minute
+	// trayWaitInterval is how often a missing notification area, or a tray
+	// icon it dropped, is checked for again.
+	trayWaitInterval = 30 * time.Second
+	// trayReadyTimeout is how long systray gets to show the icon before the
+	// app settles for running headless.
+	trayReadyTimeout = 15 * time.Second
+	// trayRestoreDelay lets systray re-add its own icon on TaskbarCreated
+	// before checking it did.
+	trayRestoreDelay = 5 * time.Second
+)
+
+var (
+	//go:embed icon_light.ico
+	iconLight []byte
+	//go:embed icon_dark.ico
+	iconDark []byte
+	//go:embed icon_color.ico
+	iconColor []byte
+)
+
+var trayIcons = ui.Icons{Light: iconLight, Dark: iconDark, Color: iconColor}
+
+// tray holds the long-lived pieces main wires together.
+type tray struct {
+	live    *config.Live
+	store   *store.Store
+	setter  *setter.Setter
+	changes *app.Manager
+	outbox  *outbox.Outbox
+
+	infoMu  sync.Mutex
+	current *history.Entry // shown in the tooltip
+
+	preview   atomic.Pointer[ui.PreviewMenu] // set once the menu exists
+	conflicts atomic.Pointer[ui.ConflictMenu]
+	favorite  atomic.Pointer[ui.FavoriteMenu]
+
+	favoritesChanged chan struct{} // a favorite was added, removed or replaced
+	forced           chan struct{} // a manual change was asked for
+
+	refetchMu   sync.Mutex
+	refetchItem ui.MenuItem        // set once the menu exists
+	refetchStop context.CancelFunc // cancels the favorites re-fetch in progress
+
+	seeded bool               // by --seed, which wins over config "seed"
+	ctx    context.Context    // the background work's, for jobs started later
+	cancel context.CancelFunc // stops the background work
+	ready  chan struct{}      // closed by onReady
+}
+
+func main() {
+	if runtime.GOOS != "windows" {
+		fmt.Println("This program is intended to run on Windows.")
+		return
+	}
+
+	args, seed, seeded, err := takeSeedFlag(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if seeded {
+		random.Seed(seed)
+	}
+	if len(args) > 0 {
+		os.Exit(runCommand(args))
+	}
+
+	t := newTray(http.DefaultClient)
+	t.seeded = seeded
+
+	// Ensure app dir
+	appDir, err := store.WaitForAppDir()
+	if err != nil {
+		// Start anyway so the user sees the problem; recoverAppDir keeps trying.
+		fmt.Println("failed to create app dir:", err)
+		ui.SetError(ui.ErrDataDir, "Data folder unavailable: "+err.Error())
+	} else {
+		t.store.SetDir(appDir)
+		t.loadStartupConfig(appDir)
+	}
+
+	// The scheduler doesn't depend on the tray: it runs headless until the
+	// icon is up, or for good if it never comes.
+	ctx, cancel := context.WithCancel(context.Background())
+	t.ctx, t.cancel = ctx, cancel
+	t.start(ctx)
+	t.runTray(ctx)
+}
+
+func newTray(hc *http.Client) *tray {
+	t := &tray{live: config.NewLive(config.Default()), ready: make(chan struct{}), favoritesChanged: make(chan struct{}, 1),
+		forced: make(chan struct{}, 1)}
+	t.store = store.New("", store.Hooks{
+		Outage: func(err error) {
+			ui.SetError(ui.ErrDataDir, "Data folder unavailable, changes are kept in memory")
+			winlog.StorageFailed(err)
+		},
+		Recovered: func() { ui.ClearError(ui.ErrDataDir) },
+		Corrupt: func(name string, restored bool) {
+			if restored {
+				ui.ShowMessage("State file recovered", name+" was damaged, restored its previous version")
+				return
+			}
+			ui.ShowError(name + " and its backup were damaged, starting over; kept the damaged files with suffix " + store.CorruptSuffix)
+		},
+	})
+	t.setter = setter.New(t.store)
+	t.outbox = outbox.New(t.store.Dir, outbox.Webhook(hc, func() string { return t.live.Current().WebhookURL }), time.Now)
+	t.changes = app.NewManager(t.live, t.store, t.setter, fetch.New(hc, store.FolderName), time.Now, display.Target,
+		app.Hooks{
+			Changed: t.wallpaperChanged,
+			Confirm: t.confirmCandidate,
+			Alert:   sourceBlocked,
+			Failed:  func(reason string) { ui.SetError(trayErrFetch, "Last failure: "+reason) },
+			Limited: sourceLimited,
+			Notice:  ui.ShowMessage,
+			Pending: pendingNote,
+		})
+	return t
+}
+
+func (t *tray) loadStartupConfig(appDir string) {
+	cfg, problems, err := config.Load(config.FilePath(appDir))
+	if err != nil {
+		fmt.Println("failed to load config, using defaults:", err)
+	}
+	for _, p := range problems {
+		fmt.Println("config warning:", p)
+	}
+	t.live.Init(cfg)
+	logging.SetDebug(cfg.Debug)
+	if !t.seeded && cfg.Seed != 0 {
+		random.Seed(cfg.Seed)
+	}
+	logging.Debugf("random", "seed %d", random.CurrentSeed())
+}
+
+// onReady builds the menu once systray has shown the icon.
+func (t *tray) onReady(ctx context.Context) {
+	ui.MarkReady()
+	ui.ApplyIconTheme(trayIcons, t.live.Current().IconTheme)
+	close(t.ready)
+
+	t.preview.Store(ui.AddPreviewMenu())
+	conflictItem := ui.AddConflictMenu()
+	t.conflicts.Store(conflictItem)
+	mForce := ui.AddMenuItem(ui.Text(ui.MsgForceChange), "Download and set wallpaper now")
+	favoriteItem := ui.AddFavoriteMenu()
+	t.favorite.Store(favoriteItem)
+	go t.checkFavorite()
+	mRefetch := ui.AddMenuItem(ui.Text(ui.MsgRefetchFavorites), refetchTooltip)
+	t.refetchMu.Lock()
+	t.refetchItem = mRefetch
+	running := t.refetchStop != nil
+	t.refetchMu.Unlock()
+	if running {
+		mRefetch.SetTitle(ui.Label(ui.MsgRefetchFavorites, ui.StateRunning))
+	}
+	mFit := ui.AddMenuItem("Fit mode", "How the image is placed on the desktop")
+	fitItems := ui.AddFitModeMenu(mFit, t.live.Current().FitMode)
+	mMonitor := ui.AddMenuItem("Target monitor", "Monitor whose resolution sizes downloads")
+	monitorItems := ui.AddMonitorMenu(mMonitor)
+	mHistory := ui.AddMenuItem("History", "Recent wallpapers")
+	historyItems := ui.AddHistoryMenu(mHistory)
+	mSchedule := ui.AddMenuItem("Show schedule…", "The next planned wallpaper changes")
+	mLists := ui.AddMenuItem("Refresh lists", "Fetch the selected source's categories again")
+	mExit := ui.AddMenuItem("Exit", "Exit the program")
+	go t.watchMonitors(ctx, monitorItems)
+
+	// menu handling
+	go func() {
+		for {
+			select {
+			case <-mForce.Clicked():
+				t.forceChange(mForce)
+			case mode := <-fitItems.Clicked:
+				go t.selectFitMode(fitItems, mode)
+			case id := <-monitorItems.Clicked:
+				go t.selectMonitor(monitorItems, id)
+			case <-historyItems.Load:
+				go t.loadHistoryMenu(historyItems)
+			case file := <-historyItems.Clicked:
+				go func() {
+					if err := t.changes.ApplyHistoryEntry(file); err != nil {
+						ui.ShowError(err.Error())
+					}
+				}()
+			case <-conflictItem.Clicked:
+				go t.respectExternalChanges(conflictItem)
+			case <-favoriteItem.Clicked:
+				go t.toggleFavorite(favoriteItem)
+			case <-mRefetch.Clicked():
+				go t.toggleRefetch()
+			case <-mLists.Clicked():
+				go t.refreshLists(ctx)
+			case <-mSchedule.Clicked():
+				ui.ShowText("Schedule", t.schedulePreview())
+			case <-mExit.Clicked():
+				t.quit()
+				return
+			}
+		}
+	}()
+}
+
+// start runs the scheduler and everything else that works without the
+// tray.
+func (t *tray) start(ctx context.Context) {
+	// Run background worker for scheduling
+	go t.changes.Run(ctx)
+	go t.changes.RefreshLoop(ctx)
+	go t.changes.ListsLoop(ctx)
+	go t.changes.PendingLoop(ctx)
+	go t.startupChecks(ctx)
+	go t.watchConflicts(ctx)
+	if t.live.Current().EventLog {
+		winlog.Enable("event_log")
+	}
+	if t.live.Current().HistoryIntegritySweep && t.store.Dir() != "" {
+		go func() {
+			if err := t.changes.SweepHistory(); err != nil {
+				fmt.Println("history sweep failed:", err)
+			}
+		}()
+	}
+	go t.store.FlushLoop(ctx)
+	go t.outbox.Run(ctx)
+	go config.PollRemote(ctx, http.DefaultClient, t.live, t.reprocessInBackground)
+	if appDir := t.store.Dir(); appDir == "" {
+		go t.recoverAppDir(ctx)
+	} else {
+		go config.Watch(ctx, config.FilePath(appDir), t.live, t.reprocessInBackground)
+	}
+	worker := &schedule.Worker{
+		Clock:        schedule.SystemClock{},
+		Config:       t.live,
+		UpdatedToday: t.store.WasUpdatedToday,
+		Change:       func() error { return t.changes.ChangeNow(app.InitiatorScheduled) },
+		IdleTime:     setter.IdleTime,
+		Deferred:     deferredNote,
+		Failed:       changeFailed,
+		SkipStartup:  t.forced,
+		Starting:     startingNote,
+	}
+	go worker.Run(ctx)
+	go t.watchInfo(ctx)
+	go t.watchPolicy(ctx)
+	go t.mirrorFavorites(ctx)
+	go ui.WatchNotifications(ctx, t.live)
+	go trigger.WatchMail(ctx, t.live, func() error { return t.changes.ChangeNow(app.InitiatorTrigger) })
+	go func() {
+		if err := ipc.Serve(ctx, t.control); err != nil {
+			fmt.Println("command pipe unavailable:", err)
+		}
+	}()
+	if win, err := winmsg.Start(messageWindowClass); err != nil {
+		fmt.Println("system notifications unavailable:", err)
+		go t.watchIconTheme(ctx, nil)
+		go t.watchTrayIcon(ctx, nil)
+	} else {
+		settings := make(chan struct{}, 1)
+		taskbar := make(chan struct{}, 1)
+		win.Handle(func(msg uint32, _, _ uintptr) {
+			var ch chan struct{}
+			switch msg {
+			case winmsg.WMSettingChange:
+				ch = settings
+			case ui.TaskbarCreated():
+				ch = taskbar
+			default:
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		})
+		go t.watchIconTheme(ctx, settings)
+		go t.watchTrayIcon(ctx, taskbar)
+		go trigger.WatchUSB(ctx, win, t.live, t.changes.ApplyOverride)
+		go trigger.WatchDrops(ctx, win, t.live, t.changes.ApplyOverrideManual)
+		go trigger.WatchSession(ctx, win, t.live, t.changes.ApplyPending)
+	}
+}
+
+func (t *tray) onExit() {
+	fmt.Println("Exiting…")
+	if err := t.setter.RestoreBackgroundColor(); err != nil {
+		fmt.Println("failed to restore background color:", err)
+	}
+	t.store.Flush()
+	logging.Flush()
+	os.Exit(0) // ⚡ гарантированное завершение процесса
+}
+
+// wallpaperChanged updates the tooltip and queues the webhook event for a
+// change.
+func (t *tray) wallpaperChanged(e history.Entry, fromHistory bool) {
+	t.infoMu.Lock()
+	t.current = &e
+	t.infoMu.Unlock()
+	t.refreshInfo()
+	t.checkFavorite()
+	ui.NoteChange()
+	if fromHistory {
+		ui.Notify(ui.EventOfflineFallback, "No new wallpaper", "the source was unreachable, set "+e.Label()+" instead")
+	} else {
+		ui.ClearError(trayErrFetch)
+		if e.Initiator == string(app.InitiatorScheduled) {
+			winlog.DailySummary(e.Label())
+		}
+	}
+	if t.live.Current().WebhookURL == "" {
+		return
+	}
+	origin := "source"
+	if fromHistory {
+		origin = "history"
+	}
+	t.outbox.Enqueue(outbox.NewEvent("wallpaper_changed", time.Now(), map[string]string{
+		"source":     e.Source,
+		"source_url": e.SourceURL,
+		"title":      e.Title,
+		"origin":     origin,
+		"initiator":  e.Initiator,
+	}))
+}
+
+// sourceBlocked reports a source blocked by anti-bot checks for days.
+func sourceBlocked(msg string) {
+	ui.NotifyError(ui.EventSourceBlocked, "wallpaper source blocked: "+msg)
+	winlog.SourceBlocked(msg)
+}
+
+// sourceLimited reports a source backed off after an anti-bot challenge.
+func sourceLimited(name string, until time.Time) {
+	ui.Notify(ui.EventRateLimited, "Source rate-limited", name+" is backed off until "+until.Format("Jan 2 15:04"))
+}
+
+// changeFailed reports a scheduled change that failed for good.
+func changeFailed(err error) {
+	ui.NotifyError(ui.EventFailure, "scheduled change failed: "+err.Error())
+	winlog.ChangeFailed(err)
+}
+
+// startingNote counts down startup_delay_seconds in the tooltip.
+func startingNote(remaining time.Duration) {
+	if remaining <= 0 {
+		ui.SetNote("")
+		return
+	}
+	ui.SetNote(fmt.Sprintf("Starting in %d s…", int(remaining.Round(time.Second).Seconds())))
+}
+
+// deferredNote shows in the tooltip that the daily change waits for the
+// user to go idle.
+func deferredNote(deadline time.Time) {
+	if deadline.IsZero() {
+		ui.SetNote("")
+		return
+	}
+	ui.SetNote("Change waits for idle, by " + deadline.Format("15:04"))
+}
+
+// pendingNote shows in the tooltip and status that the scheduled change's
+// wallpaper waits for a session lock or unlock.
+func pendingNote(p *app.Pending) {
+	if p == nil {
+		ui.SetNote("")
+		return
+	}
+	ui.SetNote(fmt.Sprintf("Pending, will apply at next %s (by %s)", p.On, p.Due.Format("Jan 2 15:04")))
+}
+
+// confirmCandidate asks about c in the tray menu; before the menu exists the
+// candidate is applied.
+func (t *tray) confirmCandidate(c *source.Candidate, timeout time.Duration) bool {
+	menu := t.preview.Load()
+	if menu == nil {
+		return true
+	}
+	return menu.Ask(c.Path, c.Title, timeout)
+}
+
+// watchIconTheme re-picks the tray icon when the system theme (signalled on
+// settings) or config "icon_theme" changes. When the apps theme flips and
+// "dark_mode_dim" is on, the wallpaper is reprocessed to add or drop the dim
+// filter.
+func (t *tray) watchIconTheme(ctx context.Context, settings <-chan struct{}) {
+	dark := setter.DarkModeOn()
+	for {
+		changed := t.live.Changed()
+		select {
+		case <-settings:
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+		cfg := t.live.Current()
+		ui.ApplyIconTheme(trayIcons, cfg.IconTheme)
+		if now := setter.DarkModeOn(); now != dark {
+			dark = now
+			if cfg.DarkModeDim {
+				t.reprocessInBackground()
+			}
+		}
+	}
+}
+
+// refreshInfo shows the current wallpaper in the tooltip, if enabled.
+func (t *tray) refreshInfo() {
+	cfg := t.live.Current()
+	t.infoMu.Lock()
+	e := t.current
+	t.infoMu.Unlock()
+	if !cfg.WallpaperInfoTooltip || e == nil {
+		ui.SetWallpaperInfo(nil)
+		return
+	}
+	hour, minute := cfg.ChangeClock()
+	ui.SetWallpaperInfo(&ui.WallpaperInfo{
+		Title:      e.Title,
+		Author:     e.Author,
+		Source:     e.Source,
+		Initiator:  e.Initiator,
+		Applied:    e.Added,
+		NextChange: schedule.NextChangeTime(time.Now(), hour, minute),
+	})
+}
+
+// schedulePreview lists the next planned changes, one per line.
+func (t *tray) schedulePreview() string {
+	now := time.Now()
+	st := schedule.State{UpdatedToday: t.store.WasUpdatedToday(now), Policy: policy.Current(t.store.Dir()), Running: true}
+	var lines []string
+	for _, p := range schedule.Plan(t.live.Current(), st, now, schedule.PreviewLength) {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// refreshLists fetches the source's category list on request.
+func (t *tray) refreshLists(ctx context.Context) {
+	l, err := t.changes.RefreshLists(ctx)
+	if err != nil {
+		ui.ShowError("refreshing lists: " + err.Error())
+		return
+	}
+	ui.ShowMessage("Lists refreshed", fmt.Sprintf("%d %s categories; run \"go-wallpaper-tray lists\" to see them", len(l.Categories), l.Source))
+}
+
+// watchInfo seeds the tooltip from the newest history entry and keeps it
+// in step with the config.
+func (t *tray) watchInfo(ctx context.Context) {
+	if entries, err := t.changes.RecentHistory(1); err == nil && len(entries) > 0 {
+		t.infoMu.Lock()
+		if t.current == nil {
+			t.current = &entries[0]
+		}
+		t.infoMu.Unlock()
+		t.checkFavorite()
+	}
+	for {
+		changed := t.live.Changed()
+		t.refreshInfo()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchPolicy shows the kill switch in the tray. The manager checks the
+// policy itself before every change; this is only the display.
+func (t *tray) watchPolicy(ctx context.Context) {
+	shown := policy.None
+	for {
+		if level := policy.Current(t.store.Dir()); level != shown {
+			shown = level
+			switch level {
+			case policy.None:
+				ui.ClearError(trayErrPolicy)
+			case policy.Automatic:
+				ui.SetError(trayErrPolicy, "Disabled by policy (manual changes allowed)")
+			default:
+				ui.SetError(trayErrPolicy, "Disabled by policy")
+			}
+		}
+		select {
+		case <-time.After(policyPollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recoverAppDir waits for the app dir after startup gave up on it, then
+// loads and starts watching the config and clears the tray error.
+func (t *tray) recoverAppDir(ctx context.Context) {
+	appDir, err := store.RecoverAppDir(ctx)
+	if err != nil {
+		return
+	}
+	t.store.SetDir(appDir)
+	t.loadStartupConfig(appDir)
+	t.live.Publish()
+	ui.ClearError(ui.ErrDataDir)
+	go config.Watch(ctx, config.FilePath(appDir), t.live, t.reprocessInBackground)
+}
+
+func (t *tray) reprocessInBackground() {
+	go func() {
+		if err := t.changes.ReprocessNow(); err != nil {
+			fmt.Println("reprocess after config change failed:", err)
+		}
+	}()
+}
+
+// watchMonitors keeps the "Target monitor" submenu in sync with the attached
+// monitors, so hot-plugged displays show up.
+func (t *tray) watchMonitors(ctx context.Context, menu *ui.MonitorMenu) {
+	var last []display.Monitor
+	lastTarget := ""
+	for {
+		monitors, err := display.List()
+		if err != nil {
+			fmt.Println("failed to list monitors:", err)
+		} else if target, _ := display.Select(monitors, t.live.Current().TargetMonitor); !slices.Equal(monitors, last) || target.ID != lastTarget {
+			menu.Update(monitors, target.ID)
+			last, lastTarget = monitors, target.ID
+		}
+		changed := t.live.Changed()
+		select {
+		case <-time.After(monitorPollInterval):
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forceChange runs or queues a change for a "Force change now" click.
+func (t *tray) forceChange(item ui.MenuItem) {
+	busy := func(b bool) {
+		if b {
+			item.SetTitle(ui.Label(ui.MsgForceChange, ui.StateRunning))
+		} else {
+			item.SetTitle(ui.Label(ui.MsgForceChange, ui.StateNone))
+		}
+	}
+	report := func(err error) {
+		if err != nil {
+			ui.NotifyError(ui.EventFailure, err.Error())
+		} else {
+			ui.Notify(ui.EventSuccess, "Wallpaper updated", "Wallpaper changed successfully")
+		}
+	}
+	select {
+	case t.forced <- struct{}{}:
+	default:
+	}
+	switch t.changes.ForceChange(busy, report) {
+	case app.ForceBusy:
+		ui.ShowMessage("Busy", "change already running — click again to queue one more")
+	case app.ForceQueued:
+		item.SetTitle(ui.Label(ui.MsgForceChange, ui.StateQueued))
+		ui.ShowMessage("Queued", "one more change will run after the current one")
+	case app.ForceQueueFull:
+		ui.ShowMessage("Queued", "a change is already queued")
+	}
+}
+
+// loadHistoryMenu fills the History submenu with the most recent entries.
+func (t *tray) loadHistoryMenu(menu *ui.HistoryMenu) {
+	entries, err := t.changes.RecentHistory(t.live.Current().MaxHistoryMenuItems)
+	if err != nil {
+		ui.ShowError(err.Error())
+		return
+	}
+	menu.Populate(entries)
+}
+
+// selectMonitor saves the target monitor locally; the next change uses it.
+func (t *tray) selectMonitor(menu *ui.MonitorMenu, id string) {
+	cfg := t.live.Local()
+	cfg.TargetMonitor = id
+	if appDir := t.store.Dir(); appDir != "" {
+		if err := config.Save(config.FilePath(appDir), cfg); err != nil {
+			fmt.Println("failed to save config:", err)
+		}
+	}
+	t.live.SetLocal(cfg) // wakes watchMonitors, which moves the check mark
+}
+
+// selectFitMode saves the new mode locally and reprocesses the current
+// wallpaper. A remote config that pins fit_mode still wins.
+func (t *tray) selectFitMode(m *ui.FitModeMenu, mode string) {
+	cfg := t.live.Local()
+	cfg.FitMode = mode
+	if appDir := t.store.Dir(); appDir != "" {
+		if err := config.Save(config.FilePath(appDir), cfg); err != nil {
+			fmt.Println("failed to save config:", err)
+		}
+	}
+	reprocess := t.live.SetLocal(cfg)
+	m.Check(t.live.Current().FitMode)
+	if !reprocess {
+		return
+	}
+	if err := t.changes.ReprocessNow(); err != nil {
+		ui.ShowError(err.Error())
+	}
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/source"
+)
+
+const (
+	// A challenged source is left alone for challengeBackoff plus up to
+	// challengeJitter, so retries don't line up with other clients.
+	challengeBackoff = 6 * time.Hour
+	challengeJitter  = 6 * time.Hour
+	// challengeAlertDays consecutive challenged days raise the Alert hook,
+	// once per streak.
+	challengeAlertDays = 3
+	challengeFileName  = "challenge_streak.json"
+	// challengeSchemaVersion is the version of challengeFileName's layout.
+	challengeSchemaVersion = 1
+	streakDateLayout       = "2006-01-02"
+)
+
+// errAllBlocked means every configured source is backing off a challenge.
+var errAllBlocked = errors.New("every configured source is backing off anti-bot checks")
+
+// challengeStreak counts consecutive days a source served challenges.
+type challengeStreak struct {
+	Last    string `json:"last"` // date of the last challenged day
+	Days    int    `json:"days"`
+	Alerted bool   `json:"alerted"`
+}
+
+// newSource is source.New without the sources backing off a challenge.
+// In single-source mode a backing-off Source fails over to a weighted draw
+// of the other sources with a positive weight in SourceWeights.
+func (m *Manager) newSource(cfg config.Config, deps source.Deps) (source.WallpaperSource, error) {
+	now := m.now()
+	blocked := func(name string) bool { return now.Before(m.blocked[name]) }
+	if !cfg.WeightedRandomSelection {
+		if !blocked(cfg.Source) {
+			return source.New(cfg, deps)
+		}
+		until := m.blocked[cfg.Source].Format("15:04")
+		rest := cfg
+		rest.WeightedRandomSelection = true
+		rest.SourceWeights = map[string]float64{}
+		for name, w := range cfg.SourceWeights {
+			if name != cfg.Source && w > 0 && !blocked(name) {
+				rest.SourceWeights[name] = w
+			}
+		}
+		if len(rest.SourceWeights) > 0 {
+			src, err := source.New(rest, deps)
+			if err == nil {
+				fmt.Printf("%s is backing off until %s, using %s from source_weights\n", cfg.Source, until, src.Name())
+				return src, nil
+			}
+			fmt.Printf("%s is backing off until %s, and no other source could be set up: %v\n", cfg.Source, until, err)
+		}
+		return nil, fmt.Errorf("%s: %w until %s", cfg.Source, errAllBlocked, until)
+	}
+	weights := map[string]float64{}
+	open := 0
+	for name, w := range cfg.SourceWeights {
+		if blocked(name) {
+			continue
+		}
+		weights[name] = w
+		if w > 0 {
+			open++
+		}
+	}
+	if open == 0 {
+		return nil, errAllBlocked
+	}
+	cfg.SourceWeights = weights
+	if open > 1 {
+		if src := m.withoutOverused(cfg, deps); src != nil {
+			return src, nil
+		}
+	}
+	return source.New(cfg, deps)
+}
+
+// noteChallenge backs name off and counts the day towards its streak.
+func (m *Manager) noteChallenge(name string) {
+	now := m.now()
+	until := now.Add(challengeBackoff + time.Duration(random.Int63n(int64(challengeJitter))))
+	m.blocked[name] = until
+	fmt.Printf("%s: anti-bot challenge, backing off until %s\n", name, until.Format("Jan 2 15:04"))
+	if m.hooks.Limited != nil {
+		m.hooks.Limited(name, until)
+	}
+
+	streaks := m.loadStreaks()
+	st := streaks[name]
+	today := now.Format(streakDateLayout)
+	if st.Last == today {
+		return
+	}
+	if st.Last == now.AddDate(0, 0, -1).Format(streakDateLayout) {
+		st.Days++
+	} else {
+		st = challengeStreak{Days: 1}
+	}
+	st.Last = today
+	if st.Days >= challengeAlertDays && !st.Alerted {
+		st.Alerted = true
+		if m.hooks.Alert != nil {
+			m.hooks.Alert(fmt.Sprintf("%s has been blocked by anti-bot checks for %d days; consider another source", name, st.Days))
+		}
+	}
+	streaks[name] = st
+	m.saveStreaks(streaks)
+}
+
+// clearChallenge ends name's streak after a successful fetch.
+func (m *Manager) clearChallenge(name string) {
+	delete(m.blocked, name)
+	streaks := m.loadStreaks()
+	if _, ok := streaks[name]; !ok {
+		return
+	}
+	delete(streaks, name)
+	m.saveStreaks(streaks)
+}
+
+func (m *Manager) loadStreaks() map[string]challengeStreak {
+	streaks := map[string]challengeStreak{}
+	if err := m.store.ReadJSON(challengeFileName, challengeSchemaVersion, &streaks); err != nil {
+		return map[string]challengeStreak{}
+	}
+	return streaks
+}
+
+func (m *Manager) saveStreaks(streaks map[string]challengeStreak) {
+	if err := m.store.WriteJSON(challengeFileName, challengeSchemaVersion, streaks); err != nil {
+		fmt.Println("failed to save challenge streaks:", err)
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/source"
+)
+
+// TestNewSourceSingle checks that a backing-off Source fails over to the
+// other weighted sources when weighted selection is off.
+func TestNewSourceSingle(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]float64
+		blocked []string
+		want    string // "" for errAllBlocked
+	}{
+		{"not backing off", map[string]float64{"voronoi": 1}, nil, "starfield"},
+		{"fails over", map[string]float64{"starfield": 1, "voronoi": 1}, []string{"starfield"}, "voronoi"},
+		{"no other source", nil, []string{"starfield"}, ""},
+		{"only itself", map[string]float64{"starfield": 1}, []string{"starfield"}, ""},
+		{"the other has no weight", map[string]float64{"voronoi": 0}, []string{"starfield"}, ""},
+		{"the other can't set up", map[string]float64{"dalle": 5}, []string{"starfield"}, ""},
+		{"the other is backing off too", map[string]float64{"voronoi": 1}, []string{"starfield", "voronoi"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Source = "starfield"
+			cfg.WeightedRandomSelection = false
+			cfg.SourceWeights = tt.weights
+			m, _, dir := testManager(t, cfg)
+			for _, name := range tt.blocked {
+				m.blocked[name] = m.now().Add(time.Hour)
+			}
+			src, err := m.newSource(cfg, source.Deps{Client: m.client, AppDir: dir, Now: m.now})
+			if tt.want == "" {
+				if !errors.Is(err, errAllBlocked) {
+					t.Fatalf("newSource = %v, %v, want errAllBlocked", src, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newSource: %v", err)
+			}
+			if src.Name() != tt.want {
+				t.Errorf("newSource = %s, want %s", src.Name(), tt.want)
+			}
+		})
+	}
+}
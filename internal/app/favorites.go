@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/favorites"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/source"
+)
+
+// refetchPause spaces the downloads of RefetchFavorites, a background job
+// that shouldn't crowd the sites it asks.
+const refetchPause = 5 * time.Second
+
+// Favorites returns the favorites kept in the app dir.
+func (m *Manager) Favorites() (*favorites.Favorites, error) {
+	appDir := m.store.Dir()
+	if appDir == "" {
+		return nil, errors.New("app dir is not available")
+	}
+	return favorites.Open(filepath.Join(appDir, favorites.DirName)), nil
+}
+
+// AddFavorite keeps the current wallpaper, which e describes, as a
+// favorite: its original, as downloaded.
+func (m *Manager) AddFavorite(e history.Entry) (history.Entry, error) {
+	f, err := m.Favorites()
+	if err != nil {
+		return e, err
+	}
+	return f.Add(filepath.Join(m.store.Dir(), originalFileName), e)
+}
+
+// RefetchReport counts what RefetchFavorites did.
+type RefetchReport struct {
+	Updated int
+	// Skipped are favorites without a source URL, from a source that can't
+	// download them again or is rate limiting, gone from their source, or
+	// already at least as large as what it has now.
+	Skipped int
+	Failed  int
+}
+
+func (r RefetchReport) String() string {
+	return fmt.Sprintf("%d updated, %d skipped, %d failed", r.Updated, r.Skipped, r.Failed)
+}
+
+// RefetchFavorites downloads the favorites again from their sources at the
+// target monitor's size, one every refetchPause, and replaces those that
+// come back larger. A source that rate limits or challenges a download is
+// left alone for the rest of the run. When ctx is done it stops and
+// returns what it did so far with ctx.Err().
+func (m *Manager) RefetchFavorites(ctx context.Context) (RefetchReport, error) {
+	var r RefetchReport
+	favs, err := m.Favorites()
+	if err != nil {
+		return r, err
+	}
+	all, err := favs.List()
+	if err != nil {
+		return r, err
+	}
+	cfg := m.config.Current()
+	cfg.WeightedRandomSelection = false
+	deps := source.Deps{Client: m.client, AppDir: m.store.Dir(), Now: m.now}
+	if mon, err := m.monitor(cfg.TargetMonitor); err != nil {
+		fmt.Println("failed to detect target monitor, using default size:", err)
+	} else {
+		deps.Screen = image.Pt(mon.Width, mon.Height)
+	}
+
+	sources := map[string]source.Refetchable{} // nil for those that can't
+	limited := map[string]bool{}
+	asked := false
+	for _, e := range all {
+		src, ok := sources[e.Source]
+		if !ok {
+			cfg.Source = e.Source
+			if s, err := source.New(cfg, deps); err == nil {
+				src, _ = s.(source.Refetchable)
+			}
+			sources[e.Source] = src
+		}
+		if e.SourceURL == "" || src == nil || limited[e.Source] {
+			r.Skipped++
+			continue
+		}
+		if asked {
+			select {
+			case <-time.After(refetchPause):
+			case <-ctx.Done():
+				return r, ctx.Err()
+			}
+		}
+		asked = true
+
+		updated, err := refetchFavorite(ctx, favs, src, e)
+		switch {
+		case err == nil && updated:
+			r.Updated++
+		case err == nil:
+			r.Skipped++
+		case ctx.Err() != nil:
+			return r, ctx.Err()
+		case errors.Is(err, fetch.ErrNotFound):
+			fmt.Printf("favorites re-fetch: %s is gone from %s\n", e.File, e.SourceURL)
+			r.Skipped++
+		case errors.Is(err, fetch.ErrRateLimited) || errors.Is(err, fetch.ErrChallenge):
+			fmt.Printf("favorites re-fetch: %s: %v, skipping its other favorites\n", e.Source, err)
+			limited[e.Source] = true
+			r.Skipped++
+		default:
+			fmt.Printf("favorites re-fetch: %s: %v\n", e.File, err)
+			r.Failed++
+		}
+	}
+	return r, nil
+}
+
+// refetchFavorite downloads e again from src and replaces its file if the
+// download has more pixels.
+func refetchFavorite(ctx context.Context, favs *favorites.Favorites, src source.Refetchable, e history.Entry) (bool, error) {
+	c, err := src.Refetch(ctx, e.SourceURL)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(c.Path)
+	have, err := imageArea(favs.Path(e))
+	if err != nil {
+		have = 0 // a missing or broken file is worth replacing
+	}
+	got, err := imageArea(c.Path)
+	if err != nil {
+		return false, err
+	}
+	if got <= have {
+		return false, nil
+	}
+	_, err = favs.Replace(e, c.Path, c.Size)
+	return err == nil, err
+}
+
+// imageArea returns the pixel count of the image at path.
+func imageArea(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	ic, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, err
+	}
+	return ic.Width * ic.Height, nil
+}
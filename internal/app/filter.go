@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"unicode"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/source"
+)
+
+// rejectReason returns why c must not be used, or "" to accept it.
+func rejectReason(c *source.Candidate, cfg config.Config) string {
+	if kw, field := matchExcluded(c, cfg.ExcludeKeywords); kw != "" {
+		return fmt.Sprintf("%s matches excluded keyword %q", field, kw)
+	}
+	return ""
+}
+
+// imageRejectReason is rejectReason for checks that need the decoded image.
+func imageRejectReason(img image.Image, cfg config.Config, dark bool) string {
+	if dark && cfg.DarkModeWallpapers {
+		if l := imaging.MeanLuminance(img); l > cfg.DarkModeMaxLuminance {
+			return fmt.Sprintf("mean luminance %.2f is above %.2f for dark mode", l, cfg.DarkModeMaxLuminance)
+		}
+	}
+	return ""
+}
+
+// matchExcluded returns the first keyword found in c's metadata as whole
+// words, and the metadata field it was found in. Candidates without metadata
+// never match.
+func matchExcluded(c *source.Candidate, keywords []string) (keyword, field string) {
+	fields := []struct{ name, text string }{
+		{"title", c.Title},
+		{"category", c.Category},
+		{"tags", strings.Join(c.Tags, " ")},
+	}
+	for _, kw := range keywords {
+		want := words(kw)
+		if len(want) == 0 {
+			continue
+		}
+		for _, f := range fields {
+			if containsWords(words(f.text), want) {
+				return kw, f.name
+			}
+		}
+	}
+	return "", ""
+}
+
+// words splits s at anything that isn't a letter or digit, so "car" matches
+// "Red car, 4K" but not "scarf".
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords reports whether want occurs as a consecutive run in have,
+// comparing with Unicode case folding.
+func containsWords(have, want []string) bool {
+	for i := 0; i+len(want) <= len(have); i++ {
+		match := true
+		for j, w := range want {
+			if !strings.EqualFold(have[i+j], w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/source"
+)
+
+func TestMatchExcluded(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         source.Candidate
+		keywords  []string
+		wantKW    string
+		wantField string
+	}{
+		{"no metadata", source.Candidate{}, []string{"car"}, "", ""},
+		{"no keywords", source.Candidate{Title: "Red car"}, nil, "", ""},
+		{"whole word", source.Candidate{Title: "Red car, 4K"}, []string{"car"}, "car", "title"},
+		{"inside a word", source.Candidate{Title: "Blue scarf"}, []string{"car"}, "", ""},
+		{"case", source.Candidate{Category: "CARS and Car"}, []string{"car"}, "car", "category"},
+		{"tags", source.Candidate{Tags: []string{"sea", "gun_range"}}, []string{"gun"}, "gun", "tags"},
+		{"phrase", source.Candidate{Title: "a Sad Clown face"}, []string{"sad clown"}, "sad clown", "title"},
+		{"phrase split", source.Candidate{Title: "sad old clown"}, []string{"sad clown"}, "", ""},
+		{"first keyword wins", source.Candidate{Title: "gun car"}, []string{"car", "gun"}, "car", "title"},
+		{"blank keyword", source.Candidate{Title: "anything"}, []string{" ", "—"}, "", ""},
+
+		{"russian", source.Candidate{Title: "Красная машина"}, []string{"машина"}, "машина", "title"},
+		{"russian case folding", source.Candidate{Title: "МАШИНА у моря"}, []string{"Машина"}, "Машина", "title"},
+		{"russian inside a word", source.Candidate{Title: "Машинальный жест"}, []string{"машина"}, "", ""},
+		{"russian yo", source.Candidate{Tags: []string{"Ёлка"}}, []string{"ёлка"}, "ёлка", "tags"},
+		{"russian punctuation", source.Candidate{Title: "клоун!»"}, []string{"клоун"}, "клоун", "title"},
+		{"greek final sigma", source.Candidate{Title: "ΟΔΟΣ"}, []string{"οδος"}, "οδος", "title"},
+		{"kelvin sign", source.Candidate{Title: "\u212a-pop"}, []string{"k"}, "k", "title"},
+		{"digits", source.Candidate{Title: "Top 10 cars"}, []string{"10"}, "10", "title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kw, field := matchExcluded(&tt.c, tt.keywords)
+			if kw != tt.wantKW || field != tt.wantField {
+				t.Errorf("matchExcluded = %q in %q, want %q in %q", kw, field, tt.wantKW, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRejectReason(t *testing.T) {
+	cfg := config.Default()
+	cfg.ExcludeKeywords = []string{"клоун"}
+	if r := rejectReason(&source.Candidate{Title: "Клоун"}, cfg); r != `title matches excluded keyword "клоун"` {
+		t.Errorf("rejectReason = %q", r)
+	}
+	if r := rejectReason(&source.Candidate{Title: "Клоуны"}, cfg); r != "" {
+		t.Errorf("rejectReason of a longer word = %q, want accept", r)
+	}
+}
+
+func TestImageRejectReason(t *testing.T) {
+	gray := func(v uint8) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		imaging.FillRect(img, img.Bounds(), color.RGBA{v, v, v, 0xff})
+		return img
+	}
+	tests := []struct {
+		name   string
+		img    image.Image
+		dark   bool
+		enable bool
+		want   string
+	}{
+		{"bright in dark mode", gray(200), true, true, "mean luminance 0.78 is above 0.35 for dark mode"},
+		{"dark in dark mode", gray(40), true, true, ""},
+		{"at the threshold", gray(89), true, true, ""}, // 89/255 rounds to 0.35 yet is below it
+		{"bright in light mode", gray(200), false, true, ""},
+		{"dark_mode_wallpapers off", gray(200), true, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.DarkModeWallpapers = tt.enable
+			cfg.DarkModeMaxLuminance = 0.35
+			if got := imageRejectReason(tt.img, cfg, tt.dark); got != tt.want {
+				t.Errorf("imageRejectReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRejectDeterministic checks that a daily generator's picture turned
+// down in dark mode ends the change at once, as fetching again draws it
+// again, while in random mode each try draws a new one.
+func TestRejectDeterministic(t *testing.T) {
+	orig := darkMode
+	darkMode = func() bool { return true }
+	t.Cleanup(func() { darkMode = orig })
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"daily", "draws again on every try"},
+		{"random", fmt.Sprintf("rejected %d candidates in a row", maxRejections+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Source = "starfield"
+			cfg.GeneratorMode = tt.mode
+			cfg.RespectExternalChanges = false
+			cfg.DarkModeWallpapers = true
+			cfg.DarkModeMaxLuminance = 0.001 // no starfield is this dark
+			m, set, _ := testManager(t, cfg)
+			err := m.ChangeNow(InitiatorScheduled)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ChangeNow = %v, want an error mentioning %q", err, tt.want)
+			}
+			if got := set.set(); len(got) != 0 {
+				t.Errorf("set %v, want nothing", got)
+			}
+		})
+	}
+}
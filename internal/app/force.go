@@ -0,0 +1,104 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// ForceResult says what a "Force change now" click did.
+type ForceResult int
+
+const (
+	// ForceStarted means a change started right away.
+	ForceStarted ForceResult = iota
+	// ForceBusy means a change is running; clicking again queues one more.
+	ForceBusy
+	// ForceQueued means one more change runs after the current one.
+	ForceQueued
+	// ForceQueueFull means a change was already queued; the click is dropped.
+	ForceQueueFull
+)
+
+// forceWarnWindow is how long the "click again to queue" warning stands; a
+// click after it is warned afresh rather than queueing.
+const forceWarnWindow = 10 * time.Second
+
+// forceState is the state of the forced-change queue:
+// idle → running → running, warned → running, queued.
+type forceState int
+
+const (
+	forceIdle forceState = iota
+	forceRunning
+	// forceRunningWarned: a second click was told a change is running.
+	forceRunningWarned
+	forceRunningQueued
+)
+
+// forceQueue holds at most one forced change behind the running one.
+type forceQueue struct {
+	mu     sync.Mutex
+	state  forceState
+	warned time.Time // when the running change last warned
+	now    func() time.Time
+}
+
+// click advances the state for one click.
+func (q *forceQueue) click() ForceResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch q.state {
+	case forceIdle:
+		q.state = forceRunning
+		return ForceStarted
+	case forceRunning:
+		q.state, q.warned = forceRunningWarned, q.now()
+		return ForceBusy
+	case forceRunningWarned:
+		if now := q.now(); now.Sub(q.warned) > forceWarnWindow {
+			q.warned = now
+			return ForceBusy
+		}
+		q.state = forceRunningQueued
+		return ForceQueued
+	default:
+		return ForceQueueFull
+	}
+}
+
+// finish is called when a forced change completes; it reports whether a
+// queued change should run next.
+func (q *forceQueue) finish() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.state == forceRunningQueued {
+		q.state = forceRunning
+		return true
+	}
+	q.state = forceIdle
+	return false
+}
+
+// ForceChange handles a "Force change now" click. Clicks while a change runs
+// queue at most one more change, and only from the second extra click on, so
+// habitual double-clicks don't cost a second download. busy is called with
+// true when each change starts and false once the queue is drained; report
+// gets the result of every change.
+func (m *Manager) ForceChange(busy func(bool), report func(error)) ForceResult {
+	res := m.force.click()
+	if res != ForceStarted {
+		return res
+	}
+	busy(true)
+	go func() {
+		for {
+			report(m.ChangeNow(InitiatorManual))
+			if !m.force.finish() {
+				break
+			}
+			busy(true) // the queued change is the running one now
+		}
+		busy(false)
+	}()
+	return res
+}
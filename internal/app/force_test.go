@@ -0,0 +1,149 @@
+package app
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+)
+
+// clock is a settable time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestForceQueue(t *testing.T) {
+	type step struct {
+		after  time.Duration // clock advance before the step
+		finish bool          // finish the running change instead of clicking
+		want   ForceResult   // for clicks
+		next   bool          // for finishes: whether a queued change runs
+		state  forceState
+	}
+	click := func(after time.Duration, want ForceResult, state forceState) step {
+		return step{after: after, want: want, state: state}
+	}
+	finish := func(next bool, state forceState) step {
+		return step{finish: true, next: next, state: state}
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"single click", []step{
+			click(0, ForceStarted, forceRunning),
+			finish(false, forceIdle),
+		}},
+		{"double click warns", []step{
+			click(0, ForceStarted, forceRunning),
+			click(200*time.Millisecond, ForceBusy, forceRunningWarned),
+			finish(false, forceIdle),
+		}},
+		{"third click queues", []step{
+			click(0, ForceStarted, forceRunning),
+			click(time.Second, ForceBusy, forceRunningWarned),
+			click(time.Second, ForceQueued, forceRunningQueued),
+			click(time.Second, ForceQueueFull, forceRunningQueued),
+			finish(true, forceRunning),
+			click(0, ForceBusy, forceRunningWarned),
+			finish(false, forceIdle),
+		}},
+		{"queue full drops clicks", []step{
+			click(0, ForceStarted, forceRunning),
+			click(0, ForceBusy, forceRunningWarned),
+			click(0, ForceQueued, forceRunningQueued),
+			click(time.Hour, ForceQueueFull, forceRunningQueued),
+			finish(true, forceRunning),
+			finish(false, forceIdle),
+		}},
+		{"warning expires", []step{
+			click(0, ForceStarted, forceRunning),
+			click(0, ForceBusy, forceRunningWarned),
+			click(forceWarnWindow+time.Second, ForceBusy, forceRunningWarned),
+			click(forceWarnWindow, ForceQueued, forceRunningQueued),
+		}},
+		{"change ends while warned", []step{
+			click(0, ForceStarted, forceRunning),
+			click(0, ForceBusy, forceRunningWarned),
+			finish(false, forceIdle),
+			click(time.Second, ForceStarted, forceRunning),
+		}},
+		{"idle after queue drains", []step{
+			click(0, ForceStarted, forceRunning),
+			click(0, ForceBusy, forceRunningWarned),
+			click(0, ForceQueued, forceRunningQueued),
+			finish(true, forceRunning),
+			finish(false, forceIdle),
+			click(0, ForceStarted, forceRunning),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{t: time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)}
+			q := forceQueue{now: c.now}
+			for i, s := range tt.steps {
+				c.advance(s.after)
+				if s.finish {
+					if next := q.finish(); next != s.next {
+						t.Errorf("step %d: finish = %v, want %v", i, next, s.next)
+					}
+				} else if got := q.click(); got != s.want {
+					t.Errorf("step %d: click = %v, want %v", i, got, s.want)
+				}
+				if q.state != s.state {
+					t.Fatalf("step %d: state = %v, want %v", i, q.state, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestForceChange(t *testing.T) {
+	cfg := config.Default()
+	cfg.Source = "starfield"
+	m, set, _ := testManager(t, cfg)
+	set.gate = make(chan struct{})
+
+	var mu sync.Mutex
+	var busy []bool
+	reports := make(chan error, 3)
+	onBusy := func(b bool) {
+		mu.Lock()
+		busy = append(busy, b)
+		mu.Unlock()
+	}
+	report := func(err error) { reports <- err }
+
+	for _, want := range []ForceResult{ForceStarted, ForceBusy, ForceQueued, ForceQueueFull} {
+		if got := m.ForceChange(onBusy, report); got != want {
+			t.Fatalf("ForceChange = %v, want %v", got, want)
+		}
+	}
+	for i := range 2 {
+		set.gate <- struct{}{}
+		if err := <-reports; err != nil {
+			t.Fatalf("change %d: %v", i, err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := append([]bool(nil), busy...)
+		mu.Unlock()
+		if slices.Equal(got, []bool{true, true, false}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("busy calls = %v, want [true true false]", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.ForceChange(onBusy, report); got != ForceStarted {
+		t.Errorf("ForceChange after the queue drained = %v", got)
+	}
+	set.gate <- struct{}{}
+	<-reports
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/source"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// ListsFileName caches the selected source's category list.
+	ListsFileName      = "source_lists.json"
+	listsSchemaVersion = 1
+	// The lists change rarely and cost a page load each, so they are
+	// refreshed at most once per listsMaxAge unless the user asks.
+	listsMaxAge       = 24 * time.Hour
+	listsPollInterval = time.Hour
+)
+
+// ErrNoLists means the selected source has no category list.
+var ErrNoLists = errors.New("the selected source has no category list")
+
+// SourceLists is the cached category list of one source. A failed refresh
+// keeps the old Categories and their Fetched time, and records LastError.
+type SourceLists struct {
+	Source     string            `json:"source"`
+	Categories []source.Category `json:"categories"`
+	Fetched    time.Time         `json:"fetched"`
+	Attempted  time.Time         `json:"attempted"`
+	LastError  string            `json:"last_error,omitempty"`
+}
+
+// ReadSourceLists returns the cached lists in st.
+func ReadSourceLists(st *store.Store) (SourceLists, error) {
+	var l SourceLists
+	err := st.ReadJSON(ListsFileName, listsSchemaVersion, &l)
+	return l, err
+}
+
+// ListsLoop keeps the selected source's category list at most a day old.
+func (m *Manager) ListsLoop(ctx context.Context) {
+	for {
+		changed := m.config.Changed()
+		name := m.config.Current().Source
+		if l, err := ReadSourceLists(m.store); err != nil || l.Source != name || m.now().Sub(l.Attempted) >= listsMaxAge {
+			if _, err := m.RefreshLists(ctx); err != nil && !errors.Is(err, ErrNoLists) {
+				logging.Warnf("lists", "refresh failed: %v", err)
+			}
+		}
+		select {
+		case <-time.After(listsPollInterval):
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RefreshLists fetches the selected source's categories now and caches
+// them. On failure the cache keeps the previous list.
+func (m *Manager) RefreshLists(ctx context.Context) (SourceLists, error) {
+	cfg := m.config.Current()
+	cfg.WeightedRandomSelection = false // list the source the settings name
+	appDir := m.store.Dir()
+	if appDir == "" {
+		return SourceLists{}, errors.New("app dir is not available")
+	}
+	src, err := source.New(cfg, source.Deps{Client: m.client, AppDir: appDir, Now: m.now})
+	if err != nil {
+		return SourceLists{}, err
+	}
+	lister, ok := src.(source.Categorized)
+	if !ok {
+		return SourceLists{}, ErrNoLists
+	}
+	l, err := ReadSourceLists(m.store)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return SourceLists{}, err
+	}
+	if l.Source != src.Name() {
+		l = SourceLists{Source: src.Name()}
+	}
+	l.Attempted = m.now()
+	cats, err := lister.Categories(ctx)
+	if err != nil {
+		l.LastError = err.Error()
+	} else {
+		l.Categories, l.Fetched, l.LastError = cats, l.Attempted, ""
+	}
+	if werr := m.store.WriteJSON(ListsFileName, listsSchemaVersion, l); werr != nil {
+		fmt.Println("failed to save source lists:", werr)
+	}
+	return l, err
+}
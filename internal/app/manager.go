@@ -0,0 +1,770 @@
+// Package app ties the sources, processing and setter into wallpaper changes.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/setter"
+	"wallpaper-changer/internal/source"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	wallpaperFileName = "wallpaper.bmp"
+	originalFileName  = "current_original"
+	// protectCurrentOwner owns the store's protected paths of the current
+	// wallpaper.
+	protectCurrentOwner = "current_wallpaper"
+	// maxCorruptRetries is how many fresh downloads replace a corrupted one.
+	maxCorruptRetries = 2
+	// maxRejections is the per-change budget of candidates turned down by
+	// filters such as exclude_keywords or skipped in preview.
+	maxRejections = 5
+	// DNS failures usually clear within seconds (a VPN reconnecting), so
+	// they get their own, quicker retries.
+	maxDNSRetries = 3
+	dnsRetryDelay = 20 * time.Second
+	// verifyTimeout bounds the verify_before_reapply check of one entry.
+	verifyTimeout = 10 * time.Second
+)
+
+// The retry wait, the host lookup and the theme are variables so tests can
+// replace them.
+var (
+	after    = time.After
+	resolve  = fetch.Resolve
+	darkMode = setter.DarkModeOn
+)
+
+// changeKind distinguishes a fresh download from re-running the processing
+// pipeline on the image already downloaded.
+type changeKind int
+
+const (
+	changeNewWallpaper changeKind = iota
+	// changeReprocess re-applies current_original with the current settings:
+	// no network, no daily-marker update.
+	changeReprocess
+	// changeSweepHistory validates the history; it runs here so it never
+	// races a change that reads or adds history entries.
+	changeSweepHistory
+	// changeHistoryEntry re-applies a wallpaper the user picked from history.
+	changeHistoryEntry
+	// changeOverride sets an image given by path or URL, e.g. from a USB
+	// drive's override file.
+	changeOverride
+	// changeRefresh redraws a source that goes stale within the day.
+	changeRefresh
+	// changeApplyPending sets the wallpaper a scheduled change left pending
+	// for apply_on's session event.
+	changeApplyPending
+	// changeResumePending is changeApplyPending after a restart: the
+	// pending wallpaper is only set if its fallback time has passed.
+	changeResumePending
+)
+
+// Initiator says who asked for a change. Changes the user is waiting for
+// fail fast and loudly; the others retry patiently in the background.
+type Initiator string
+
+const (
+	// InitiatorManual is the user, from the tray.
+	InitiatorManual Initiator = "manual"
+	// InitiatorAPI is the user, through the command pipe.
+	InitiatorAPI Initiator = "api"
+	// InitiatorScheduled is the daily change and the redraws of stale
+	// sources.
+	InitiatorScheduled Initiator = "scheduled"
+	// InitiatorTrigger is an event such as new mail or a USB drive.
+	InitiatorTrigger Initiator = "trigger"
+)
+
+// interactive reports whether someone is waiting for the change's result.
+func (i Initiator) interactive() bool {
+	return i == InitiatorManual || i == InitiatorAPI
+}
+
+type changeRequest struct {
+	kind changeKind
+	// file is the history entry for changeHistoryEntry and the image path
+	// or URL for changeOverride.
+	file string
+	// by is empty for housekeeping such as the history sweep.
+	by   Initiator
+	done chan error
+}
+
+// Setter is what the Manager needs of *setter.Setter.
+type Setter interface {
+	SetWallpaper(path string) error
+	ApplyFitMode(mode string) error
+	MatchBackgroundColor(c color.RGBA) error
+}
+
+// Manager serializes every wallpaper change through one goroutine.
+type Manager struct {
+	config *config.Live
+	store  *store.Store
+	setter Setter
+	client *fetch.Client
+	now    func() time.Time
+	// monitor resolves config "target_monitor" to a display.
+	monitor func(id string) (display.Monitor, error)
+	hooks   Hooks
+
+	// blocked holds when sources backing off a challenge may be tried
+	// again; only the Run goroutine touches it.
+	blocked map[string]time.Time
+	// fellBack is set once a failed change fell back to history, so its
+	// retries don't put up yet another old wallpaper each; only the Run
+	// goroutine touches it.
+	fellBack bool
+	// sizedFor is the target monitor whose size strategy was last logged;
+	// only the Run goroutine touches it.
+	sizedFor display.Monitor
+
+	requests chan changeRequest
+	// interactive receives when an interactive change is submitted, so a
+	// scheduled change waiting to retry gives way to it.
+	interactive chan struct{}
+	force       forceQueue
+}
+
+// Hooks connect the Manager to the UI. Either may be nil.
+type Hooks struct {
+	// Changed is told about every wallpaper set from a source or, as a
+	// fallback, from history.
+	Changed func(e history.Entry, fromHistory bool)
+	// Confirm asks whether to apply a downloaded, validated candidate when
+	// preview_before_apply is on; false skips to the next one.
+	Confirm func(c *source.Candidate, timeout time.Duration) bool
+	// Alert reports a lasting problem the user should act on, once.
+	Alert func(msg string)
+	// Failed is told why the source failed, such as "DNS lookup of
+	// wallscloud.net timed out", when a change falls back to history.
+	Failed func(reason string)
+	// Limited is told when a source hits an anti-bot challenge and is
+	// backed off until the given time.
+	Limited func(name string, until time.Time)
+	// Notice shows something a source noticed, such as a strong aurora
+	// forecast.
+	Notice func(title, msg string)
+	// Pending is told about a wallpaper left pending for apply_on's
+	// session event, and with nil once it is set or dropped.
+	Pending func(p *Pending)
+}
+
+// NewManager wires a Manager; call Run before submitting changes.
+func NewManager(live *config.Live, st *store.Store, set Setter, client *fetch.Client,
+	now func() time.Time, monitor func(id string) (display.Monitor, error), hooks Hooks) *Manager {
+	return &Manager{
+		config:      live,
+		store:       st,
+		setter:      set,
+		client:      client,
+		now:         now,
+		monitor:     monitor,
+		hooks:       hooks,
+		force:       forceQueue{now: now},
+		blocked:     map[string]time.Time{},
+		requests:    make(chan changeRequest),
+		interactive: make(chan struct{}, 1),
+	}
+}
+
+// Run handles submitted changes until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		select {
+		case req := <-m.requests:
+			req.done <- m.handle(ctx, req)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// submit queues a change and waits for its result.
+func (m *Manager) submit(kind changeKind, by Initiator) error {
+	return m.submitRequest(changeRequest{kind: kind, by: by})
+}
+
+func (m *Manager) submitRequest(req changeRequest) error {
+	req.done = make(chan error, 1)
+	if req.by.interactive() {
+		select {
+		case m.interactive <- struct{}{}:
+		default:
+		}
+	}
+	m.requests <- req
+	return <-req.done
+}
+
+func (m *Manager) handle(ctx context.Context, req changeRequest) error {
+	if req.by.interactive() {
+		select {
+		case <-m.interactive: // this is the change that was waited for
+		default:
+		}
+	}
+	appDir := m.store.Dir()
+	if appDir == "" {
+		return errors.New("app dir is not available")
+	}
+	if err := allowed(req, policy.Current(appDir)); err != nil {
+		if req.kind != changeRefresh { // once a minute is too chatty
+			fmt.Println("change skipped:", err)
+		}
+		return err
+	}
+	if (req.kind == changeNewWallpaper || req.kind == changeRefresh) && !req.by.interactive() &&
+		m.config.Current().RespectExternalChanges && externalWallpaper(appDir) {
+		if req.kind != changeRefresh {
+			fmt.Println("change skipped: another app set the wallpaper (respect_external_changes)")
+		}
+		return nil
+	}
+	switch req.kind {
+	case changeHistoryEntry:
+		return m.applyHistoryEntry(appDir, req.file)
+	case changeOverride:
+		return m.applyOverride(appDir, req.file)
+	case changeReprocess:
+		return m.reprocessWallpaper(appDir)
+	case changeSweepHistory:
+		return m.sweepHistory(appDir)
+	case changeRefresh:
+		return m.refreshWallpaper(appDir)
+	case changeApplyPending:
+		return m.applyPending(appDir, false)
+	case changeResumePending:
+		return m.applyPending(appDir, true)
+	default:
+		return m.applyNewWallpaper(ctx, appDir, req.by)
+	}
+}
+
+// allowed checks req against the policy level. Automatic blocks every
+// change the user didn't ask for; Full blocks everything but the history
+// sweep.
+func allowed(req changeRequest, level policy.Level) error {
+	switch {
+	case level == policy.Full && req.kind != changeSweepHistory:
+		return policy.ErrDisabled
+	case level == policy.Automatic && !req.by.interactive() && req.kind != changeSweepHistory && req.kind != changeReprocess:
+		return policy.ErrDisabled
+	}
+	return nil
+}
+
+// externalWallpaper reports whether the desktop shows a wallpaper other than
+// the one this app last set. Before the app set one nothing counts as
+// external, so the first change still happens.
+func externalWallpaper(appDir string) bool {
+	wallPath := filepath.Join(appDir, wallpaperFileName)
+	if _, err := os.Stat(wallPath); err != nil {
+		return false
+	}
+	current, err := setter.CurrentWallpaper()
+	if err != nil {
+		return false
+	}
+	return !strings.EqualFold(filepath.Clean(current), wallPath)
+}
+
+// ChangeNow fetches and sets a new wallpaper. Changes by the user, which
+// policy level Automatic still allows, make one attempt and return its
+// error; the others retry corrupt downloads and DNS failures and fall back
+// to history, returning the error they fell back on.
+func (m *Manager) ChangeNow(by Initiator) error {
+	return m.submit(changeNewWallpaper, by)
+}
+
+// ReprocessNow re-applies the current wallpaper with the current settings.
+func (m *Manager) ReprocessNow() error {
+	return m.submit(changeReprocess, "")
+}
+
+// ApplyHistoryEntry sets the history entry stored as file.
+func (m *Manager) ApplyHistoryEntry(file string) error {
+	return m.submitRequest(changeRequest{kind: changeHistoryEntry, file: file, by: InitiatorManual})
+}
+
+// ApplyOverride sets the image at ref, a local path or an http(s) URL, for
+// a trigger. The daily marker and history are left alone.
+func (m *Manager) ApplyOverride(ref string) error {
+	return m.submitRequest(changeRequest{kind: changeOverride, file: ref, by: InitiatorTrigger})
+}
+
+// ApplyOverrideManual is ApplyOverride for an image the user handed over,
+// such as by dropping a file, which policy level Automatic still allows.
+func (m *Manager) ApplyOverrideManual(ref string) error {
+	return m.submitRequest(changeRequest{kind: changeOverride, file: ref, by: InitiatorManual})
+}
+
+// RecentHistory returns up to n history entries, newest first.
+func (m *Manager) RecentHistory(n int) ([]history.Entry, error) {
+	appDir := m.store.Dir()
+	if appDir == "" {
+		return nil, errors.New("app dir is not available")
+	}
+	h, err := m.openHistory(appDir)
+	if err != nil {
+		return nil, err
+	}
+	return h.Recent(n), nil
+}
+
+// SweepHistory quarantines unreadable history entries.
+func (m *Manager) SweepHistory() error {
+	return m.submit(changeSweepHistory, "")
+}
+
+func (m *Manager) applyNewWallpaper(ctx context.Context, appDir string, by Initiator) error {
+	cfg := m.config.Current()
+	dark := darkMode()
+	deps := source.Deps{Client: m.client, AppDir: appDir, Now: m.now, PreferDark: dark && cfg.DarkModeWallpapers,
+		Notify: m.hooks.Notice}
+	if mon, err := m.monitor(cfg.TargetMonitor); err != nil {
+		fmt.Println("failed to detect target monitor, using default size:", err)
+	} else {
+		deps.Screen = image.Pt(mon.Width, mon.Height)
+		if mon != m.sizedFor {
+			m.sizedFor = mon
+			fmt.Printf("target monitor %s (%dx%d): %s\n", mon.Name, mon.Width, mon.Height, source.SizeStrategy(deps.Screen))
+		}
+	}
+	src, err := m.newSource(cfg, deps)
+	if errors.Is(err, errAllBlocked) {
+		return m.fallBack(appDir, by, err)
+	}
+	if err != nil {
+		return err
+	}
+	var c *source.Candidate
+	var img image.Image
+	rejections, dnsRetries := 0, 0
+	for attempt := 0; ; attempt++ {
+		c, img, err = fetchValidated(m.client, src, cfg.ColorManage)
+		if err == nil {
+			reason := rejectReason(c, cfg)
+			if reason == "" {
+				reason = imageRejectReason(img, cfg, dark)
+			}
+			if reason == "" {
+				if m.confirmed(cfg, c, rejections) {
+					break
+				}
+				reason = "skipped in preview"
+			}
+			os.Remove(c.Path)
+			rejections++
+			fmt.Printf("%s: rejected candidate %s: %s\n", src.Name(), c.SourceURL, reason)
+			if rejections > maxRejections {
+				return fmt.Errorf("%s: rejected %d candidates in a row, last: %s", src.Name(), rejections, reason)
+			}
+			if d, ok := src.(source.Deterministic); ok && d.Deterministic() {
+				return fmt.Errorf("%s: rejected today's picture, which it draws again on every try: %s", src.Name(), reason)
+			}
+			attempt-- // rejections have their own budget
+			continue
+		}
+		if errors.Is(err, fetch.ErrChallenge) {
+			m.noteChallenge(src.Name())
+			if next, nerr := m.newSource(cfg, deps); nerr == nil {
+				fmt.Printf("%s: %v, failing over to %s\n", src.Name(), err, next.Name())
+				src = next
+				continue
+			}
+		}
+		if msg, ok := fetch.DNSFailure(err); ok && !by.interactive() && dnsRetries < maxDNSRetries {
+			dnsRetries++
+			logging.Warnf(src.Name(), "%s (%s), retrying in %s", msg, fetch.Resolver(err), dnsRetryDelay)
+			select {
+			case <-after(dnsRetryDelay):
+			case <-m.interactive:
+				// The user's change runs next and stands in for this one.
+				fmt.Printf("%s: giving up the retry for a change asked for meanwhile\n", src.Name())
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			attempt-- // DNS retries have their own budget
+			continue
+		}
+		corrupt := errors.Is(err, fetch.ErrCorrupt) || errors.Is(err, imaging.ErrCorrupt)
+		if !corrupt || by.interactive() || attempt >= maxCorruptRetries {
+			m.notifyFailed(src.Name() + ": " + fetch.Describe(err))
+			return m.fallBack(appDir, by, fmt.Errorf("%s: %w", src.Name(), err))
+		}
+		logging.Warnf(src.Name(), "%v, retrying", err)
+	}
+	defer os.Remove(c.Path)
+	m.clearChallenge(src.Name())
+
+	entry := history.Entry{Source: src.Name(), SourceURL: c.SourceURL, Title: c.Title, Author: c.Author, Size: c.Size,
+		Initiator: string(by), Added: m.now()}
+	if by == InitiatorScheduled && cfg.ApplyOn != "now" {
+		return m.stagePending(appDir, cfg, c.Path, img, entry)
+	}
+	m.dropPending(appDir)
+
+	// The copies wait until the wallpaper is visible; they aren't needed
+	// to set it.
+	if err := m.applyImage(appDir, img, processSettings(cfg)); err != nil {
+		return err
+	}
+	// The bookkeeping from here on reaches the disk in one go, once the
+	// wallpaper shows.
+	done := m.store.Batch()
+	defer done()
+	if err := copyFile(c.Path, filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+
+	_ = m.store.MarkUpdated(m.now())
+	m.fellBack = false
+
+	m.notifyChanged(entry, false)
+	m.protectCurrent(appDir, m.addToHistory(appDir, c.Path, entry)...)
+	return nil
+}
+
+// addToHistory adds the image at path to the history, returning the
+// history file it was stored as, if any, for protectCurrent.
+func (m *Manager) addToHistory(appDir, path string, entry history.Entry) []string {
+	h, err := m.openHistory(appDir)
+	if err != nil {
+		fmt.Println("failed to open history:", err)
+		return nil
+	}
+	if err := h.Add(path, entry); err != nil {
+		fmt.Println("failed to add wallpaper to history:", err)
+		return nil
+	}
+	if m.config.Current().HistoryExplorerInfo {
+		if err := h.WriteExplorerInfo(); err != nil {
+			fmt.Println("failed to describe history for Explorer:", err)
+		}
+	}
+	return []string{h.Path(h.Recent(1)[0])}
+}
+
+// fallBack handles err, a failed change. If the app never set a wallpaper
+// yet, a starter image shows it works. Otherwise the user, who asked for a
+// new wallpaper, gets err and keeps the current one, and other changes get
+// a random one from history, or a built-in Windows one when the history has
+// none, only on the first of a series of failures.
+// err is returned all the same so the caller can retry.
+func (m *Manager) fallBack(appDir string, by Initiator, err error) error {
+	if _, serr := os.Stat(filepath.Join(appDir, wallpaperFileName)); errors.Is(serr, os.ErrNotExist) {
+		if serr := m.applyStarter(appDir); serr != nil {
+			return fmt.Errorf("%w (starter image: %v)", err, serr)
+		}
+		m.fellBack = true
+		return fmt.Errorf("%w - set a starter image instead", err)
+	}
+	if by.interactive() || m.fellBack {
+		return err
+	}
+	if ferr := m.applyFromHistory(appDir); ferr != nil {
+		if berr := m.applyBuiltIn(appDir); berr != nil {
+			return fmt.Errorf("%w (history fallback: %v; built-in fallback: %v)", err, ferr, berr)
+		}
+		m.fellBack = true
+		return fmt.Errorf("%w - used a built-in Windows wallpaper instead", err)
+	}
+	m.fellBack = true
+	return fmt.Errorf("%w - used a wallpaper from history instead", err)
+}
+
+// applyBuiltIn sets one of the images Windows ships with, the last resort
+// when neither the source nor the history has a wallpaper to offer. Like
+// applyFromHistory it leaves the daily marker alone.
+func (m *Manager) applyBuiltIn(appDir string) error {
+	cfg := m.config.Current()
+	src := source.OfflineFallback(cfg)
+	c, img, err := fetchValidated(m.client, src, cfg.ColorManage)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(c.Path)
+	if err := copyFile(c.Path, filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(cfg)); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir)
+	m.notifyChanged(history.Entry{Source: src.Name(), Title: c.Title, Author: c.Author, Added: m.now()}, true)
+	return nil
+}
+
+// confirmed asks the user about c in preview mode. Once skipping would spend
+// the last of the rejection budget c is applied without asking, so the day
+// never ends without a wallpaper.
+func (m *Manager) confirmed(cfg config.Config, c *source.Candidate, rejections int) bool {
+	if !cfg.PreviewBeforeApply || m.hooks.Confirm == nil || rejections >= maxRejections {
+		return true
+	}
+	return m.hooks.Confirm(c, time.Duration(cfg.PreviewTimeoutSeconds)*time.Second)
+}
+
+// applyFromHistory sets a random past wallpaper when the source failed. The
+// daily marker is left alone so the next start tries the source again.
+func (m *Manager) applyFromHistory(appDir string) error {
+	h, err := m.openHistory(appDir)
+	if err != nil {
+		return err
+	}
+	e, img, err := h.Random()
+	if err != nil {
+		return err
+	}
+	if err := copyFile(h.Path(e), filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(m.config.Current())); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir, h.Path(e))
+	m.notifyChanged(e, true)
+	return nil
+}
+
+// openHistory opens the history in appDir with its deletions going through
+// the store, which spares protected files.
+func (m *Manager) openHistory(appDir string) (*history.History, error) {
+	h, err := history.Open(filepath.Join(appDir, history.DirName))
+	if err != nil {
+		return nil, err
+	}
+	h.Files = m.store
+	h.ManageColor = m.config.Current().ColorManage
+	if m.config.Current().VerifyBeforeReapply {
+		h.Verify = m.sourceStillThere
+	}
+	return h, nil
+}
+
+// sourceStillThere reports whether e's source page still exists. Only a
+// definite "not found" counts against it: offline, the history is what the
+// change falls back to.
+func (m *Manager) sourceStillThere(e history.Entry) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	err := m.client.Check(ctx, e.SourceURL)
+	if errors.Is(err, fetch.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		logging.Warnf("history", "could not verify %s, setting it anyway: %v", e.SourceURL, err)
+	}
+	return true
+}
+
+// protectCurrent protects the files of the wallpaper just set from
+// deletion: the image Windows shows, its original and the history files it
+// came from, if any.
+func (m *Manager) protectCurrent(appDir string, historyFiles ...string) {
+	paths := append([]string{filepath.Join(appDir, wallpaperFileName), filepath.Join(appDir, originalFileName)}, historyFiles...)
+	m.store.Protect(protectCurrentOwner, paths...)
+}
+
+func (m *Manager) notifyFailed(reason string) {
+	if m.hooks.Failed != nil {
+		m.hooks.Failed(reason)
+	}
+}
+
+func (m *Manager) notifyChanged(e history.Entry, fromHistory bool) {
+	if m.hooks.Changed != nil {
+		m.hooks.Changed(e, fromHistory)
+	}
+}
+
+func (m *Manager) applyHistoryEntry(appDir, file string) error {
+	h, err := m.openHistory(appDir)
+	if err != nil {
+		return err
+	}
+	e, img, err := h.Load(file)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(h.Path(e), filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(m.config.Current())); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir, h.Path(e))
+	return nil
+}
+
+func (m *Manager) applyOverride(appDir, ref string) error {
+	path := ref
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		tmp, err := m.client.DownloadToTemp(context.Background(), ref)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		path = tmp
+	}
+	if err := imaging.ValidateFile(path); err != nil {
+		return err
+	}
+	img, err := imaging.DecodeManaged(path, m.config.Current().ColorManage)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(path, filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(m.config.Current())); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir)
+	return nil
+}
+
+func (m *Manager) sweepHistory(appDir string) error {
+	h, err := m.openHistory(appDir)
+	if err != nil {
+		return err
+	}
+	if n := h.Sweep(); n > 0 {
+		fmt.Printf("history: integrity sweep quarantined %d of %d entries\n", n, n+h.Len())
+	}
+	return nil
+}
+
+// fetchValidated fetches one candidate and decodes it, rejecting empty or
+// truncated files with imaging.ErrCorrupt and converting wide-gamut images
+// to sRGB when manageColor is set. The source's host is resolved first, so
+// a DNS outage fails fast and recognisably, unless client reaches it
+// through a proxy, which does its own lookups.
+func fetchValidated(client *fetch.Client, src source.WallpaperSource, manageColor bool) (*source.Candidate, image.Image, error) {
+	if h, ok := src.(source.Hosted); ok && h.Host() != "" && !client.Proxied(h.Host()) {
+		if err := resolve(context.Background(), h.Host()); err != nil {
+			return nil, nil, err
+		}
+	}
+	c, err := src.Fetch(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := imaging.ValidateFile(c.Path); err != nil {
+		os.Remove(c.Path)
+		return nil, nil, err
+	}
+	img, err := imaging.DecodeManaged(c.Path, manageColor)
+	if err != nil {
+		os.Remove(c.Path)
+		return nil, nil, err
+	}
+	return c, img, nil
+}
+
+func (m *Manager) reprocessWallpaper(appDir string) error {
+	img, err := imaging.DecodeManaged(filepath.Join(appDir, originalFileName), m.config.Current().ColorManage)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no downloaded wallpaper to reprocess yet")
+	}
+	if err != nil {
+		return err
+	}
+	return m.applyImage(appDir, img, processSettings(m.config.Current()))
+}
+
+// processSettings is cfg's pipeline settings with the "dim" filter swapped
+// in while dark mode asks for it.
+func processSettings(cfg config.Config) config.ProcessSettings {
+	s := cfg.ProcessSettings()
+	if cfg.DarkModeDim && s.Filter == "none" && setter.DarkModeOn() {
+		s.Filter = "dim"
+	}
+	return s
+}
+
+// applyImage runs the processing pipeline on the original image and sets the result.
+func (m *Manager) applyImage(appDir string, original image.Image, s config.ProcessSettings) error {
+	// Fail before the processing and encoding work if Windows can't take it.
+	if err := setter.ValidatePath(filepath.Join(appDir, wallpaperFileName)); err != nil {
+		return err
+	}
+	return m.setProcessed(appDir, processImage(original, s), s)
+}
+
+// processImage runs the processing pipeline on the original image.
+func processImage(original image.Image, s config.ProcessSettings) image.Image {
+	img := imaging.Process(original, s.Filter)
+	if d := s.IconDim; d.Strength > 0 {
+		img = imaging.DimIconRegion(img, d.RegionFraction, d.MinContrast, d.Strength)
+	}
+	return img
+}
+
+// setProcessed saves img, already processed, as wallpaper.bmp and sets it.
+func (m *Manager) setProcessed(appDir string, img image.Image, s config.ProcessSettings) error {
+	wallPath := filepath.Join(appDir, wallpaperFileName)
+	var previous image.Image
+	if m.config.Current().FadeTransition {
+		// Best effort: without the old frame there's just no fade.
+		previous, _ = imaging.DecodeFile(wallPath)
+	}
+	if err := imaging.EncodeBMPFile(wallPath, img); err != nil {
+		return err
+	}
+
+	if err := m.setter.ApplyFitMode(s.FitMode); err != nil {
+		return err
+	}
+	if previous != nil {
+		m.fade(appDir, previous, img)
+	}
+	if err := m.setter.SetWallpaper(wallPath); err != nil {
+		return err
+	}
+	if s.MatchBackgroundColor && (s.FitMode == "fit" || s.FitMode == "center") {
+		if err := m.setter.MatchBackgroundColor(imaging.AverageEdgeColor(img, imaging.EdgeSampleFraction)); err != nil {
+			fmt.Println("failed to set background color:", err)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
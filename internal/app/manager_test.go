@@ -0,0 +1,423 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/store"
+)
+
+// fakeSetter records the wallpapers it was asked to set. With a gate, each
+// call waits for a receive from it first.
+type fakeSetter struct {
+	mu    sync.Mutex
+	paths []string
+	err   error
+	gate  chan struct{}
+}
+
+func (f *fakeSetter) SetWallpaper(path string) error {
+	if f.gate != nil {
+		<-f.gate
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, path)
+	return f.err
+}
+
+func (f *fakeSetter) ApplyFitMode(string) error               { return nil }
+func (f *fakeSetter) MatchBackgroundColor(c color.RGBA) error { return nil }
+
+func (f *fakeSetter) set() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.paths...)
+}
+
+// noNetwork fails the test on any request.
+type noNetwork struct{ t *testing.T }
+
+func (n noNetwork) RoundTrip(req *http.Request) (*http.Response, error) {
+	n.t.Errorf("unexpected request to %s", req.URL)
+	return nil, errors.New("no network in tests")
+}
+
+// testManager returns a running Manager on a temp app dir with cfg, no
+// network and a fake setter. It stops when the test ends.
+func testManager(t *testing.T, cfg config.Config) (*Manager, *fakeSetter, string) {
+	t.Helper()
+	return testManagerWith(t, cfg, fetch.New(&http.Client{Transport: noNetwork{t}}, "test"))
+}
+
+// testManagerWith is testManager with client for the network.
+func testManagerWith(t *testing.T, cfg config.Config, client *fetch.Client) (*Manager, *fakeSetter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	set := &fakeSetter{}
+	now := func() time.Time { return time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local) }
+	monitor := func(string) (display.Monitor, error) {
+		return display.Monitor{Name: "test", Width: 1920, Height: 1080}, nil
+	}
+	m := NewManager(config.NewLive(cfg), store.New(dir, store.Hooks{}), set, client, now, monitor, Hooks{})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go m.Run(ctx)
+	return m, set, dir
+}
+
+// writePNG saves a w by h image of c to path.
+func writePNG(t *testing.T, path string, w, h int, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReprocessStaysOffline(t *testing.T) {
+	cfg := config.Default()
+	cfg.Filter = "grayscale"
+	m, set, dir := testManager(t, cfg)
+	writePNG(t, filepath.Join(dir, originalFileName), 64, 36, color.RGBA{0xc0, 0x40, 0x20, 0xff})
+
+	if err := m.ReprocessNow(); err != nil {
+		t.Fatalf("ReprocessNow: %v", err)
+	}
+	if got := set.set(); len(got) != 1 || got[0] != filepath.Join(dir, wallpaperFileName) {
+		t.Fatalf("set %v, want the reprocessed wallpaper once", got)
+	}
+	if m.store.WasUpdatedToday(m.now()) {
+		t.Error("reprocess marked the day as updated")
+	}
+	if _, err := os.Stat(filepath.Join(dir, history.DirName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("reprocess touched the history: %v", err)
+	}
+}
+
+func TestReprocessWithoutOriginal(t *testing.T) {
+	m, set, _ := testManager(t, config.Default())
+	if err := m.ReprocessNow(); err == nil {
+		t.Fatal("ReprocessNow succeeded without a downloaded original")
+	}
+	if got := set.set(); len(got) != 0 {
+		t.Errorf("set %v without an original", got)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	kinds := []struct {
+		name string
+		kind changeKind
+	}{
+		{"new", changeNewWallpaper},
+		{"history entry", changeHistoryEntry},
+		{"override", changeOverride},
+		{"refresh", changeRefresh},
+		{"reprocess", changeReprocess},
+		{"sweep", changeSweepHistory},
+	}
+	initiators := []Initiator{InitiatorManual, InitiatorAPI, InitiatorScheduled, InitiatorTrigger}
+	for _, k := range kinds {
+		for _, by := range initiators {
+			req := changeRequest{kind: k.kind, by: by}
+			want := map[policy.Level]bool{
+				policy.None:      true,
+				policy.Automatic: by.interactive() || k.kind == changeSweepHistory || k.kind == changeReprocess,
+				policy.Full:      k.kind == changeSweepHistory,
+			}
+			for level, ok := range want {
+				err := allowed(req, level)
+				if (err == nil) != ok {
+					t.Errorf("%s by %s at %v: allowed = %v, want ok %v", k.name, by, level, err, ok)
+				}
+				if err != nil && !errors.Is(err, policy.ErrDisabled) {
+					t.Errorf("%s by %s at %v: %v is not ErrDisabled", k.name, by, level, err)
+				}
+			}
+		}
+	}
+}
+
+// TestPolicyOverridesConfig checks the DISABLE file is read at each change
+// and wins over settings that would otherwise allow it.
+func TestPolicyOverridesConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Source = "starfield"
+	cfg.RespectExternalChanges = false
+	m, set, dir := testManager(t, cfg)
+	disable := filepath.Join(dir, policy.FileName)
+
+	os.WriteFile(disable, nil, 0o644)
+	if err := m.ChangeNow(InitiatorScheduled); !errors.Is(err, policy.ErrDisabled) {
+		t.Fatalf("scheduled change under the kill switch = %v", err)
+	}
+	if err := m.ChangeNow(InitiatorManual); err != nil {
+		t.Fatalf("manual change under automatic policy = %v", err)
+	}
+
+	os.WriteFile(disable, []byte("full"), 0o644)
+	if err := m.ChangeNow(InitiatorManual); !errors.Is(err, policy.ErrDisabled) {
+		t.Fatalf("manual change under full policy = %v", err)
+	}
+
+	os.Remove(disable)
+	if err := m.ChangeNow(InitiatorScheduled); err != nil {
+		t.Fatalf("scheduled change once the file is gone = %v", err)
+	}
+	if got := len(set.set()); got != 2 {
+		t.Errorf("set %d wallpapers, want 2", got)
+	}
+}
+
+// dnsDown fails every lookup as finding no such host, and counts them.
+type dnsDown struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (d *dnsDown) resolve(ctx context.Context, host string) error {
+	d.mu.Lock()
+	d.count++
+	d.mu.Unlock()
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (d *dnsDown) lookups() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// withDNSDown makes every source host lookup fail for the test.
+func withDNSDown(t *testing.T) *dnsDown {
+	d := &dnsDown{}
+	saved := resolve
+	resolve = d.resolve
+	t.Cleanup(func() { resolve = saved })
+	return d
+}
+
+// noWait replaces the retry waits for the test with ch.
+func noWait(t *testing.T, ch <-chan time.Time) {
+	saved := after
+	after = func(time.Duration) <-chan time.Time { return ch }
+	t.Cleanup(func() { after = saved })
+}
+
+func dnsConfig() config.Config {
+	cfg := config.Default()
+	cfg.Source = "wallscloud"
+	cfg.RespectExternalChanges = false
+	return cfg
+}
+
+// TestDNSRetries checks that a change the user asked for fails after one
+// attempt, while background changes retry the lookup maxDNSRetries times.
+func TestDNSRetries(t *testing.T) {
+	fired := make(chan time.Time)
+	close(fired)
+	noWait(t, fired)
+	tests := []struct {
+		by   Initiator
+		want int
+	}{
+		{InitiatorManual, 1},
+		{InitiatorAPI, 1},
+		{InitiatorScheduled, 1 + maxDNSRetries},
+		{InitiatorTrigger, 1 + maxDNSRetries},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.by), func(t *testing.T) {
+			down := withDNSDown(t)
+			m, _, _ := testManager(t, dnsConfig())
+			err := m.ChangeNow(tt.by)
+			if _, ok := fetch.DNSFailure(err); !ok {
+				t.Fatalf("ChangeNow = %v, want the DNS failure", err)
+			}
+			if got := down.lookups(); got != tt.want {
+				t.Errorf("%d attempts, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDNSBehindProxy checks that a source reached through a proxy isn't
+// looked up locally, which fails by design on proxy-only networks.
+func TestDNSBehindProxy(t *testing.T) {
+	noWait(t, make(chan time.Time))
+	down := withDNSDown(t)
+	var mu sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host) // CONNECT host:443 for https
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fetch.New(&http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, "test")
+	m, _, _ := testManagerWith(t, dnsConfig(), client)
+
+	err = m.ChangeNow(InitiatorScheduled)
+	if _, ok := fetch.DNSFailure(err); ok || err == nil {
+		t.Errorf("ChangeNow = %v, want the proxy's error", err)
+	}
+	if got := down.lookups(); got != 0 {
+		t.Errorf("%d local lookups, want none behind the proxy", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hosts) == 0 || hosts[0] != "wallscloud.net:443" {
+		t.Errorf("the proxy was asked for %v, want wallscloud.net:443", hosts)
+	}
+}
+
+// TestDNSRetryGivesWay checks that a scheduled change waiting to retry a
+// lookup gives way to a manual change rather than holding it up.
+func TestDNSRetryGivesWay(t *testing.T) {
+	noWait(t, make(chan time.Time)) // the retry never comes due
+	down := withDNSDown(t)
+	m, _, _ := testManager(t, dnsConfig())
+
+	scheduled := make(chan error, 1)
+	go func() { scheduled <- m.ChangeNow(InitiatorScheduled) }()
+	for deadline := time.Now().Add(5 * time.Second); down.lookups() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the scheduled change looked nothing up")
+		}
+	}
+
+	manual := make(chan error, 1)
+	go func() { manual <- m.ChangeNow(InitiatorManual) }()
+	select {
+	case err := <-manual:
+		if err == nil {
+			t.Error("manual change succeeded without DNS")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the manual change waited for the scheduled retry")
+	}
+	if err := <-scheduled; err != nil {
+		t.Errorf("scheduled change = %v, want nil once it gave way", err)
+	}
+	if got := down.lookups(); got != 2 {
+		t.Errorf("%d lookups, want one each", got)
+	}
+}
+
+// TestVerifyBeforeReapply sets wallpapers from a history whose entries'
+// source pages answer with the given statuses, and checks which get set
+// and which are retired.
+func TestVerifyBeforeReapply(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]int // by source page; missing pages answer 200
+		offline bool
+		set     []string // the entries that may be set
+		retired []string
+	}{
+		{"404 is skipped for another", map[string]int{"/gone": http.StatusNotFound}, false, []string{"kept"}, []string{"gone"}},
+		{"410 is skipped too", map[string]int{"/gone": http.StatusGone}, false, []string{"kept"}, []string{"gone"}},
+		{"200 is set", nil, false, []string{"gone", "kept"}, nil},
+		{"server error allows it", map[string]int{"/gone": http.StatusInternalServerError}, false, []string{"gone", "kept"}, nil},
+		{"offline allows it", nil, true, []string{"gone", "kept"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead {
+					t.Errorf("%s %s, want HEAD", r.Method, r.URL.Path)
+				}
+				if code, ok := tt.status[r.URL.Path]; ok {
+					w.WriteHeader(code)
+				}
+			}))
+			defer srv.Close()
+			if tt.offline {
+				srv.Close() // connections are refused from here on
+			}
+
+			dir := t.TempDir()
+			img := filepath.Join(t.TempDir(), "wall.png")
+			writePNG(t, img, 16, 9, color.RGBA{0x20, 0x40, 0x60, 0xff})
+			h, err := history.Open(filepath.Join(dir, history.DirName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, name := range []string{"gone", "kept"} {
+				e := history.Entry{Source: name, SourceURL: srv.URL + "/" + name, Added: time.Date(2025, 11, 2, i, 0, 0, 0, time.Local)}
+				if err := h.Add(img, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := config.Default()
+			cfg.VerifyBeforeReapply = true
+			var changed []history.Entry
+			hooks := Hooks{Changed: func(e history.Entry, _ bool) { changed = append(changed, e) }}
+			monitor := func(string) (display.Monitor, error) {
+				return display.Monitor{Name: "test", Width: 1920, Height: 1080}, nil
+			}
+			m := NewManager(config.NewLive(cfg), store.New(dir, store.Hooks{}), &fakeSetter{}, fetch.New(nil, "test"), time.Now, monitor, hooks)
+
+			for range 10 { // history picks at random; the outcome must not depend on it
+				if err := m.applyFromHistory(dir); err != nil {
+					t.Fatalf("applyFromHistory: %v", err)
+				}
+			}
+			for _, e := range changed {
+				if !slices.Contains(tt.set, e.Source) {
+					t.Errorf("set %s, want one of %v", e.Source, tt.set)
+				}
+			}
+
+			h, err = history.Open(filepath.Join(dir, history.DirName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Recent leaves out the retired entries; reopening shows the
+			// retirement was saved.
+			var retired []string
+			recent := h.Recent(10)
+			for _, name := range []string{"gone", "kept"} {
+				if !slices.ContainsFunc(recent, func(e history.Entry) bool { return e.Source == name }) {
+					retired = append(retired, name)
+				}
+			}
+			if !slices.Equal(retired, tt.retired) {
+				t.Errorf("retired %v, want %v", retired, tt.retired)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/source"
+)
+
+// withoutOverused draws from cfg's weighted sources without the one that
+// set the wallpaper on each of the last MaxConsecutiveSourceDays days, or
+// returns nil to draw from all of them. The others may not set up, such as
+// for missing credentials; then the overused source is better than none.
+func (m *Manager) withoutOverused(cfg config.Config, deps source.Deps) source.WallpaperSource {
+	name := m.overusedSource(deps.AppDir, cfg.MaxConsecutiveSourceDays)
+	if name == "" || cfg.SourceWeights[name] <= 0 {
+		return nil
+	}
+	rest := cfg
+	rest.SourceWeights = maps.Clone(cfg.SourceWeights)
+	delete(rest.SourceWeights, name)
+	src, err := source.New(rest, deps)
+	if err != nil {
+		fmt.Printf("%s set the wallpaper %d days in a row, but keeping it: %v\n", name, cfg.MaxConsecutiveSourceDays, err)
+		return nil
+	}
+	fmt.Printf("%s set the wallpaper %d days in a row, leaving it out this time\n", name, cfg.MaxConsecutiveSourceDays)
+	return src
+}
+
+// overusedSource returns the source that set the wallpaper on each of the
+// last days days with a change, or "" if there is none.
+func (m *Manager) overusedSource(appDir string, days int) string {
+	if days == 0 || appDir == "" {
+		return ""
+	}
+	h, err := history.Open(filepath.Join(appDir, history.DirName))
+	if err != nil {
+		return ""
+	}
+	return sameSourceDays(h.Recent(h.Len()), days)
+}
+
+// sameSourceDays returns the source of every entry, newest first, on the
+// last days dates that have entries, or "" if they are not all the same or
+// there are fewer dates.
+func sameSourceDays(entries []history.Entry, days int) string {
+	src, seen, last := "", 0, ""
+	for _, e := range entries {
+		if e.Initiator == string(InitiatorManual) || e.Initiator == string(InitiatorAPI) {
+			continue // the user's own picks don't count against the rotation
+		}
+		if date := e.Added.Format(streakDateLayout); date != last {
+			if seen == days {
+				break
+			}
+			seen++
+			last = date
+		}
+		if src == "" {
+			src = e.Source
+		} else if e.Source != src {
+			return ""
+		}
+	}
+	if seen < days {
+		return ""
+	}
+	return src
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/source"
+)
+
+// dayEntry returns an entry of src added n days before 2026-03-14 at hour.
+func dayEntry(src string, n, hour int, by Initiator) history.Entry {
+	return history.Entry{Source: src, Initiator: string(by),
+		Added: time.Date(2026, 3, 14-n, hour, 0, 0, 0, time.Local)}
+}
+
+func TestSameSourceDays(t *testing.T) {
+	sched := InitiatorScheduled
+	tests := []struct {
+		name    string
+		entries []history.Entry // newest first
+		days    int
+		want    string
+	}{
+		{"empty", nil, 3, ""},
+		{"fewer days than the limit", []history.Entry{dayEntry("a", 0, 9, sched), dayEntry("a", 1, 9, sched)}, 3, ""},
+		{"three days of one source", []history.Entry{
+			dayEntry("a", 0, 9, sched), dayEntry("a", 1, 9, sched), dayEntry("a", 2, 9, sched),
+		}, 3, "a"},
+		{"another source in between", []history.Entry{
+			dayEntry("a", 0, 9, sched), dayEntry("b", 1, 9, sched), dayEntry("a", 2, 9, sched),
+		}, 3, ""},
+		{"several changes a day count once", []history.Entry{
+			dayEntry("a", 0, 18, sched), dayEntry("a", 0, 9, sched), dayEntry("a", 1, 9, sched),
+		}, 2, "a"},
+		{"a second source the same day", []history.Entry{
+			dayEntry("a", 0, 18, sched), dayEntry("b", 0, 9, sched), dayEntry("a", 1, 9, sched),
+		}, 2, ""},
+		{"manual picks don't count", []history.Entry{
+			dayEntry("b", 0, 12, InitiatorManual), dayEntry("a", 0, 9, sched),
+			dayEntry("c", 1, 12, InitiatorAPI), dayEntry("a", 1, 9, sched),
+		}, 2, "a"},
+		{"older days are outside the window", []history.Entry{
+			dayEntry("a", 0, 9, sched), dayEntry("a", 1, 9, sched), dayEntry("b", 2, 9, sched),
+		}, 2, "a"},
+		{"gaps between dates", []history.Entry{
+			dayEntry("a", 0, 9, sched), dayEntry("a", 5, 9, sched), dayEntry("a", 9, 9, sched),
+		}, 3, "a"},
+		{"one day", []history.Entry{dayEntry("b", 0, 9, InitiatorTrigger)}, 1, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameSourceDays(tt.entries, tt.days); got != tt.want {
+				t.Errorf("sameSourceDays = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewSourceOverused checks that an overused source is left out only when
+// another weighted source actually sets up.
+func TestNewSourceOverused(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]float64
+		blocked string
+		want    string
+	}{
+		{"left out for another", map[string]float64{"starfield": 1, "voronoi": 1}, "", "voronoi"},
+		{"the only source", map[string]float64{"starfield": 1}, "", "starfield"},
+		{"the other has no weight", map[string]float64{"starfield": 1, "voronoi": 0}, "", "starfield"},
+		{"the other can't set up", map[string]float64{"starfield": 1, "dalle": 5}, "", "starfield"},
+		{"the other is backing off", map[string]float64{"starfield": 1, "voronoi": 1}, "voronoi", "starfield"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.WeightedRandomSelection = true
+			cfg.SourceWeights = tt.weights
+			cfg.MaxConsecutiveSourceDays = 3
+			m, _, dir := testManager(t, cfg)
+			img := filepath.Join(t.TempDir(), "starfield.png")
+			writePNG(t, img, 16, 9, color.RGBA{0x10, 0x10, 0x40, 0xff})
+			h, err := history.Open(filepath.Join(dir, history.DirName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for n := 3; n >= 1; n-- {
+				if err := h.Add(img, dayEntry("starfield", n, 9, InitiatorScheduled)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.blocked != "" {
+				m.blocked[tt.blocked] = m.now().Add(time.Hour)
+			}
+			for range 20 { // draws are random; the outcome must not be
+				src, err := m.newSource(cfg, source.Deps{Client: m.client, AppDir: dir, Now: m.now})
+				if err != nil {
+					t.Fatalf("newSource: %v", err)
+				}
+				if src.Name() != tt.want {
+					t.Fatalf("newSource = %s, want %s", src.Name(), tt.want)
+				}
+			}
+		})
+	}
+}
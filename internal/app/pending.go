@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/schedule"
+	"wallpaper-changer/internal/setter"
+)
+
+const (
+	// pendingFileName describes the pending slot; the slot's images sit
+	// next to it in the app dir so it survives restarts.
+	pendingFileName          = "pending.json"
+	pendingSchemaVersion     = 1
+	pendingWallpaperFileName = "pending_wallpaper.bmp"
+	pendingOriginalFileName  = "pending_original"
+	// protectPendingOwner owns the store's protected paths of the pending
+	// slot.
+	protectPendingOwner = "pending_wallpaper"
+)
+
+// Pending is a scheduled change's wallpaper, downloaded and processed on
+// time, waiting for the session event of apply_on.
+type Pending struct {
+	Entry history.Entry `json:"entry"`
+	// On is the "lock" or "unlock" it waits for.
+	On string `json:"on"`
+	// Due is the pending_fallback_time it is set at if no such event came.
+	Due time.Time `json:"due"`
+}
+
+// ApplyPending sets the pending wallpaper, if there is one, for a session
+// lock or unlock.
+func (m *Manager) ApplyPending() error {
+	return m.submit(changeApplyPending, InitiatorScheduled)
+}
+
+// PendingLoop takes over a pending slot left by an earlier run, setting it
+// if its fallback time passed meanwhile, then sets pending wallpapers at
+// pending_fallback_time and as soon as apply_on is switched to "now".
+func (m *Manager) PendingLoop(ctx context.Context) {
+	logPending(m.submit(changeResumePending, InitiatorScheduled))
+	for {
+		changed := m.config.Changed()
+		cfg := m.config.Current()
+		var due <-chan time.Time
+		if cfg.ApplyOn == "now" {
+			logPending(m.ApplyPending())
+		} else {
+			hour, minute := cfg.PendingClock()
+			now := m.now()
+			due = time.After(schedule.NextChangeTime(now, hour, minute).Sub(now))
+		}
+		select {
+		case <-due:
+			logPending(m.ApplyPending())
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func logPending(err error) {
+	if err != nil && !errors.Is(err, policy.ErrDisabled) {
+		logging.Errorf("pending", "%v", err)
+	}
+}
+
+// stagePending fills the pending slot with the image at path, decoded as
+// img, instead of setting it. The daily marker is updated as for a change
+// that happened; the history gets the entry once it is set.
+func (m *Manager) stagePending(appDir string, cfg config.Config, path string, img image.Image, entry history.Entry) error {
+	original := filepath.Join(appDir, pendingOriginalFileName)
+	wallPath := filepath.Join(appDir, pendingWallpaperFileName)
+	m.store.Protect(protectPendingOwner, original, wallPath)
+	if err := copyFile(path, original); err != nil {
+		return err
+	}
+	if err := imaging.EncodeBMPFile(wallPath, processImage(img, processSettings(cfg))); err != nil {
+		return err
+	}
+	hour, minute := cfg.PendingClock()
+	p := Pending{Entry: entry, On: cfg.ApplyOn, Due: schedule.NextChangeTime(m.now(), hour, minute)}
+	if err := m.store.WriteJSON(pendingFileName, pendingSchemaVersion, p); err != nil {
+		return err
+	}
+	_ = m.store.MarkUpdated(m.now())
+	m.fellBack = false
+	fmt.Printf("wallpaper %s pending, will apply at next %s or at %s\n", entry.Label(), p.On, p.Due.Format("Jan 2 15:04"))
+	m.notifyPending(&p)
+	return nil
+}
+
+// applyPending sets the pending wallpaper, with dueOnly only once its
+// fallback time has passed; one that isn't due yet is just reported. With
+// nothing pending it does nothing.
+func (m *Manager) applyPending(appDir string, dueOnly bool) error {
+	var p Pending
+	err := m.store.ReadJSON(pendingFileName, pendingSchemaVersion, &p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		m.dropPending(appDir)
+		return fmt.Errorf("pending wallpaper dropped: %w", err)
+	}
+	if dueOnly && m.now().Before(p.Due) {
+		m.notifyPending(&p)
+		return nil
+	}
+	if err := setter.ValidatePath(filepath.Join(appDir, wallpaperFileName)); err != nil {
+		return err
+	}
+	pendingOriginal := filepath.Join(appDir, pendingOriginalFileName)
+	img, err := imaging.DecodeFile(filepath.Join(appDir, pendingWallpaperFileName))
+	if err == nil {
+		err = copyFile(pendingOriginal, filepath.Join(appDir, originalFileName))
+	}
+	if err != nil {
+		m.dropPending(appDir)
+		return fmt.Errorf("pending wallpaper dropped: %w", err)
+	}
+	if err := m.setProcessed(appDir, img, processSettings(m.config.Current())); err != nil {
+		return err
+	}
+
+	p.Entry.Added = m.now()
+	m.notifyChanged(p.Entry, false)
+	m.protectCurrent(appDir, m.addToHistory(appDir, pendingOriginal, p.Entry)...)
+	m.dropPending(appDir)
+	return nil
+}
+
+// dropPending empties the pending slot, if it holds anything.
+func (m *Manager) dropPending(appDir string) {
+	if _, err := m.store.Read(pendingFileName); errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err := m.store.Remove(pendingFileName); err != nil {
+		fmt.Println("failed to clear pending wallpaper:", err)
+	}
+	m.store.Protect(protectPendingOwner)
+	os.Remove(filepath.Join(appDir, pendingWallpaperFileName))
+	os.Remove(filepath.Join(appDir, pendingOriginalFileName))
+	m.notifyPending(nil)
+}
+
+func (m *Manager) notifyPending(p *Pending) {
+	if m.hooks.Pending != nil {
+		m.hooks.Pending(p)
+	}
+}
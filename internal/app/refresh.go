@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/source"
+)
+
+// RefreshLoop redraws the wallpaper on the boundaries of the selected
+// source's RefreshInterval, e.g. every minute for the clock. Sources without
+// one are left to the daily schedule.
+func (m *Manager) RefreshLoop(ctx context.Context) {
+	for {
+		changed := m.config.Changed()
+		cfg := m.config.Current()
+		var tick <-chan time.Time
+		if iv := source.RefreshInterval(cfg.Source); iv > 0 && !cfg.WeightedRandomSelection {
+			now := m.now()
+			tick = time.After(now.Truncate(iv).Add(iv).Sub(now))
+		}
+		select {
+		case <-tick:
+			if err := m.submit(changeRefresh, InitiatorScheduled); err != nil && !errors.Is(err, policy.ErrDisabled) {
+				logging.Errorf("refresh", "%v", err)
+			}
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshWallpaper redraws the current source's picture without touching the
+// daily marker or history, for sources with a RefreshInterval.
+func (m *Manager) refreshWallpaper(appDir string) error {
+	cfg := m.config.Current()
+	if cfg.WeightedRandomSelection || source.RefreshInterval(cfg.Source) == 0 {
+		return nil
+	}
+	src, err := source.New(cfg, source.Deps{Client: m.client, AppDir: appDir, Now: m.now, Notify: m.hooks.Notice})
+	if err != nil {
+		return err
+	}
+	c, img, err := fetchValidated(m.client, src, cfg.ColorManage)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src.Name(), err)
+	}
+	defer os.Remove(c.Path)
+	if err := copyFile(c.Path, filepath.Join(appDir, originalFileName)); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(cfg)); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir)
+	return nil
+}
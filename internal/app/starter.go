@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/store"
+)
+
+// StarterSource labels the starter images in the tooltip and webhook.
+const StarterSource = "starter image"
+
+const (
+	starterBagFileName      = "starter_bag.json"
+	starterBagSchemaVersion = 1
+)
+
+// starterImages are a few pictures made for the app, so the very first
+// change shows something even with every source unreachable.
+//
+//go:embed starter/*.jpg
+var starterImages embed.FS
+
+// applyStarter sets the next starter image. They are drawn from a shuffle
+// bag kept in the app dir, so repeated offline starts go through all of
+// them before one comes back.
+func (m *Manager) applyStarter(appDir string) error {
+	names, err := starterImages.ReadDir("starter")
+	if err != nil {
+		return err
+	}
+	var bag []string
+	if err := m.store.ReadJSON(starterBagFileName, starterBagSchemaVersion, &bag); err != nil || len(bag) == 0 {
+		bag = bag[:0]
+		for _, n := range names {
+			bag = append(bag, n.Name())
+		}
+		random.Shuffle(len(bag), func(i, j int) { bag[i], bag[j] = bag[j], bag[i] })
+	}
+	name := bag[0]
+	if err := m.store.WriteJSON(starterBagFileName, starterBagSchemaVersion, bag[1:]); err != nil {
+		fmt.Println("failed to save the starter image bag:", err)
+	}
+
+	b, err := starterImages.ReadFile(path.Join("starter", name))
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if err := store.WriteFileAtomic(filepath.Join(appDir, originalFileName), b); err != nil {
+		return err
+	}
+	if err := m.applyImage(appDir, img, processSettings(m.config.Current())); err != nil {
+		return err
+	}
+	m.protectCurrent(appDir)
+	title := strings.ReplaceAll(strings.TrimSuffix(name, path.Ext(name)), "_", " ")
+	m.notifyChanged(history.Entry{Source: StarterSource, Title: "Starter image: " + title, Added: m.now()}, true)
+	return nil
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/setter"
+)
+
+const (
+	// fadeFrames and fadeDuration cap the transition: each frame is a full
+	// SystemParametersInfoW call, which is heavyweight.
+	fadeFrames   = 5
+	fadeDuration = time.Second
+)
+
+// The system checks and the clock are variables so tests can replace them.
+var (
+	batterySaverOn = setter.BatterySaverOn
+	remoteSession  = setter.RemoteSession
+	sleep          = time.Sleep
+)
+
+// fadeFrameFiles alternate so Windows never reloads the file it is showing.
+var fadeFrameFiles = [2]string{"fade_0.bmp", "fade_1.bmp"}
+
+// fadeStep is one intermediate frame: the blend amount and how long to show
+// the previous frame before it.
+type fadeStep struct {
+	t     float64
+	delay time.Duration
+}
+
+// fadeSchedule spreads frames evenly over total; the final image, shown
+// after the last step, takes the last slot.
+func fadeSchedule(frames int, total time.Duration) []fadeStep {
+	if frames <= 0 {
+		return nil
+	}
+	slot := total / time.Duration(frames+1)
+	steps := make([]fadeStep, frames)
+	for i := range steps {
+		steps[i] = fadeStep{t: float64(i+1) / float64(frames+1), delay: slot}
+	}
+	return steps
+}
+
+// canFade reports why a fade should be skipped, if it should.
+func canFade() (bool, string) {
+	switch {
+	case batterySaverOn():
+		return false, "battery saver is on"
+	case remoteSession():
+		return false, "remote desktop session"
+	}
+	return true, ""
+}
+
+// fade shows blended frames from the old to the new wallpaper. It stops at
+// the first error; the caller sets the final image either way.
+func (m *Manager) fade(appDir string, from, to image.Image) {
+	if ok, why := canFade(); !ok {
+		fmt.Println("skipping fade transition:", why)
+		return
+	}
+	defer func() {
+		for _, name := range fadeFrameFiles {
+			os.Remove(filepath.Join(appDir, name))
+		}
+	}()
+	for i, step := range fadeSchedule(fadeFrames, fadeDuration) {
+		start := time.Now()
+		path := filepath.Join(appDir, fadeFrameFiles[i%len(fadeFrameFiles)])
+		if err := imaging.EncodeBMPFile(path, imaging.Blend(from, to, step.t)); err != nil {
+			fmt.Println("fade transition aborted:", err)
+			return
+		}
+		// Blending a large frame can take most of the slot; sleep the rest.
+		sleep(step.delay - time.Since(start))
+		if err := m.setter.SetWallpaper(path); err != nil {
+			fmt.Println("fade transition aborted:", err)
+			return
+		}
+	}
+	sleep(fadeDuration / (fadeFrames + 1))
+}
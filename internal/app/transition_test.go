@@ -0,0 +1,104 @@
+package app
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/imaging"
+)
+
+func TestFadeSchedule(t *testing.T) {
+	tests := []struct {
+		frames int
+		total  time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{fadeFrames, fadeDuration},
+		{9, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		steps := fadeSchedule(tt.frames, tt.total)
+		if len(steps) != tt.frames {
+			t.Fatalf("fadeSchedule(%d) gave %d steps", tt.frames, len(steps))
+		}
+		var sum time.Duration
+		prev := 0.0
+		for i, s := range steps {
+			if s.t <= prev || s.t >= 1 {
+				t.Errorf("fadeSchedule(%d) step %d blends %v after %v", tt.frames, i, s.t, prev)
+			}
+			prev = s.t
+			sum += s.delay
+		}
+		// The final image takes the last slot.
+		if tt.frames > 0 && sum+tt.total/time.Duration(tt.frames+1) > tt.total {
+			t.Errorf("fadeSchedule(%d) runs %v, over %v", tt.frames, sum, tt.total)
+		}
+	}
+}
+
+// stubSystem replaces the battery, RDP and sleep checks for one test and
+// returns the total time slept.
+func stubSystem(t *testing.T, battery, rdp bool) *time.Duration {
+	t.Helper()
+	slept := new(time.Duration)
+	oldBattery, oldRDP, oldSleep := batterySaverOn, remoteSession, sleep
+	batterySaverOn = func() bool { return battery }
+	remoteSession = func() bool { return rdp }
+	sleep = func(d time.Duration) { *slept += max(d, 0) }
+	t.Cleanup(func() { batterySaverOn, remoteSession, sleep = oldBattery, oldRDP, oldSleep })
+	return slept
+}
+
+func solid(c color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	imaging.FillRect(img, img.Bounds(), c)
+	return img
+}
+
+func TestFade(t *testing.T) {
+	tests := []struct {
+		name         string
+		battery, rdp bool
+		setErr       error
+		wantFrames   int
+	}{
+		{"fades", false, false, nil, fadeFrames},
+		{"battery saver", true, false, nil, 0},
+		{"remote desktop", false, true, nil, 0},
+		{"setter fails", false, false, errors.New("busy"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slept := stubSystem(t, tt.battery, tt.rdp)
+			dir := t.TempDir()
+			set := &fakeSetter{err: tt.setErr}
+			m := &Manager{setter: set}
+			m.fade(dir, solid(color.RGBA{A: 0xff}), solid(color.RGBA{0xff, 0xff, 0xff, 0xff}))
+
+			got := set.set()
+			if len(got) != tt.wantFrames {
+				t.Fatalf("set %d frames, want %d", len(got), tt.wantFrames)
+			}
+			for i, path := range got {
+				if want := filepath.Join(dir, fadeFrameFiles[i%2]); path != want {
+					t.Errorf("frame %d = %s, want %s", i, path, want)
+				}
+			}
+			if tt.wantFrames == fadeFrames && *slept > fadeDuration {
+				t.Errorf("slept %v, over the %v cap", *slept, fadeDuration)
+			}
+			for _, name := range fadeFrameFiles {
+				if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s left behind: %v", name, err)
+				}
+			}
+		})
+	}
+}
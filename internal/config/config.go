@@ -0,0 +1,1505 @@
+// Package config defines the user settings, how they are loaded from and
+// saved to config.json (or config.yaml), and how they are validated.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// FileName is the config file name inside the app dir.
+	FileName = "config.json"
+
+	defaultChangeTime  = "09:00"
+	defaultSource      = "wallscloud"
+	defaultSummaryTime = "18:00"
+	defaultPendingTime = "23:00"
+	changeTimeLayout   = "15:04"
+	maxSuggestDistance = 2
+	// maxHistoryMenuItems bounds max_history_menu_items; the history keeps
+	// no more than this many entries anyway.
+	maxHistoryMenuItems = 50
+	// maxStarDensity keeps the starfield a sky rather than a white sheet.
+	maxStarDensity = 20.0
+	// maxStaticMapZoom is MapTiler's closest zoom level.
+	maxStaticMapZoom = 22
+	// maxKp is the top of the Kp geomagnetic scale.
+	maxKp = 9
+	// Below zoom 3 the watercolor world is narrower than the picture; past
+	// 16 Stamen has no watercolor tiles.
+	minWatercolorZoom = 3
+	maxWatercolorZoom = 16
+	// Stable Diffusion sizes are multiples of 8; past 2048 a consumer GPU
+	// runs out of memory. More than 150 steps adds nothing, and beyond a
+	// CFG scale of 30 the images burn out.
+	minSDSize     = 64
+	maxSDSize     = 2048
+	maxSDSteps    = 150
+	minSDCFGScale = 1.0
+	maxSDCFGScale = 30.0
+	// The iso_city grid needs a few tiles to look like a city, and tiles
+	// several pixels wide to look like anything.
+	minIsoCityGridSize = 4
+	maxIsoCityGridSize = 40
+	// Fewer than three cells is barely a diagram; past a few thousand they
+	// shrink to noise.
+	minVoronoiCells = 3
+	maxVoronoiCells = 3000
+	// maxBookCoverGridPadding leaves the covers most of the picture.
+	maxBookCoverGridPadding = 100
+	// minPreviewTimeoutSeconds leaves time to open the preview at all.
+	minPreviewTimeoutSeconds = 10
+	// maxHNStories is the length of the Hacker News front page.
+	maxHNStories = 30
+	// maxStartupDelaySeconds is an hour; a later change is what change_time
+	// is for.
+	maxStartupDelaySeconds = 3600
+)
+
+// Vocabularies accepted by the enumerated fields. Packages that act on these
+// values (setter, imaging, source) map them to their own representation.
+var (
+	FitModes              = []string{"fill", "fit", "stretch", "tile", "center", "span"}
+	Filters               = []string{"none", "grayscale", "sepia", "dim"}
+	TimesOfDay            = []string{"dawn", "day", "dusk", "night"}
+	FinanceProviders      = []string{"finnhub", "alphavantage"}
+	TimestampModes        = []string{"random", "fixed"}
+	ChartTypes            = []string{"line", "candlestick"}
+	CoolorsModes          = []string{"generate", "download"}
+	IconThemes            = []string{"auto", "light", "dark", "color"}
+	AQIPollutants         = []string{"aqi", "pm25", "pm10", "o3", "no2", "so2", "co"}
+	HeadingModes          = []string{"random", "north", "south"}
+	StarColorModes        = []string{"white", "realistic"}
+	GeneratorModes        = []string{"daily", "random"}
+	IsoCitySchemes        = []string{"day", "sunset", "night"}
+	CERNExperiments       = []string{"CMS", "ATLAS", "ALICE", "LHCb"}
+	DALLEModels           = []string{"dall-e-3", "dall-e-2"}
+	FiveHundredPxFeatures = []string{"popular", "fresh", "editors"}
+	NotificationEvents    = []string{"success", "failure", "offline_fallback", "rate_limited", "update_available", "source_blocked"}
+	NotificationModes     = []string{"always", "never", "daily_summary"}
+	OverlayPositions      = []string{"bottom-left", "bottom-right", "top-left", "top-right"}
+	ApplyOnEvents         = []string{"now", "lock", "unlock"}
+	ChessPieceStyles      = []string{"classic", "flat", "neon"}
+	EarthgazingRegions    = []string{"any", "africa", "asia", "europe", "north_america", "oceania", "south_america"}
+	VoronoiPalettes       = []string{"pastel", "sunset", "ocean", "forest"}
+	ClockStyles           = []string{"analog", "digital", "word-clock"}
+	ClockFonts            = []string{"go", "go-bold", "go-medium", "go-mono", "go-smallcaps"}
+	SysMonMetricNames     = []string{"cpu", "mem", "disk", "net"}
+	SysMonColorSchemes    = []string{"dark", "matrix", "solarized"}
+	SourceNames           = []string{"500px", "aerial", "aqi_map", "aurora", "book_covers", "cern_events", "chess", "cityscape", "clock", "coolors", "crypto_chart", "dalle", "deviantart", "earthgazing", "ftp", "github_trending", "google_calendar", "google_photos", "hacker_news", "iso_city", "iss_live", "lichess_puzzle", "onedrive", "poetry", "quote", "s3_bucket", "screenshot", "stable_diffusion", "starfield", "static_map", "stock_heatmap", "street_view", "sysmon", "voronoi", "wallscloud", "watercolor_map", "webcam", "wikimedia_potd", "wikipedia_featured", "wikipedia_random", "windows_builtin", "world_clock"}
+)
+
+// dalleSizes are the sizes each of DALLEModels makes, widest first.
+var dalleSizes = map[string][]string{
+	"dall-e-3": {"1792x1024", "1024x1024", "1024x1792"},
+	"dall-e-2": {"1024x1024", "512x512", "256x256"},
+}
+
+// Config holds user-tunable settings. It is read from config.json in the app dir;
+// missing fields keep their defaults.
+type Config struct {
+	// ChangeTime is the local "HH:MM" at which the daily change happens.
+	ChangeTime string `json:"change_time"`
+	// StartupDelaySeconds holds the catch-up change at startup back that
+	// long, so an autostarted app doesn't compete with the rest of logon;
+	// 60 is plenty. A manual change meanwhile replaces the catch-up.
+	StartupDelaySeconds int `json:"startup_delay_seconds"`
+	// IdleMinutes defers the scheduled change until there has been no
+	// input for that long, at most IdleMaxWaitMinutes past change_time.
+	// 0 changes on time.
+	IdleMinutes        int `json:"idle_minutes"`
+	IdleMaxWaitMinutes int `json:"idle_max_wait_minutes"`
+	// ApplyOn is when a scheduled change's wallpaper, downloaded and
+	// processed on time, is actually set: "now", or at the next session
+	// "lock" or "unlock" so it is never swapped in front of the user. A
+	// wallpaper still pending at PendingFallbackTime, a local "HH:MM", is
+	// set then.
+	ApplyOn             string `json:"apply_on"`
+	PendingFallbackTime string `json:"pending_fallback_time"`
+	// MaxHTMLBodyBytes caps how much of the source page is read before parsing.
+	MaxHTMLBodyBytes int64 `json:"max_html_body_bytes"`
+	// FitMode is "fill", "fit", "stretch", "tile", "center" or "span";
+	// empty keeps the current Windows setting.
+	FitMode string `json:"fit_mode"`
+	// MatchBackgroundColor paints the desktop color behind "fit"/"center"
+	// images with the image's average edge color.
+	MatchBackgroundColor bool `json:"match_background_color"`
+	// TargetMonitor is the display.Monitor ID whose resolution sizes
+	// downloads; empty means the primary monitor.
+	TargetMonitor string `json:"target_monitor"`
+	// FadeTransition cross-fades from the old wallpaper in a few quick
+	// steps instead of a hard cut. Skipped on battery saver and over RDP.
+	FadeTransition bool `json:"fade_transition"`
+	// Filter is applied to the image before it is set: "none", "grayscale",
+	// "sepia" or "dim".
+	Filter string `json:"filter"`
+	// ColorManage converts images with an embedded Display P3 or Adobe RGB
+	// profile to sRGB, which Windows assumes, so they don't look washed out.
+	ColorManage bool `json:"color_manage"`
+	// IconContrastDim darkens the left IconRegionFraction of the image,
+	// where desktop icons live, when their white labels would have a
+	// contrast ratio (1-21) below IconMinContrast there. The gradient
+	// darkens by IconDimStrength (0-1) at the edge, fading out to the right.
+	IconContrastDim    bool    `json:"icon_contrast_dim"`
+	IconRegionFraction float64 `json:"icon_region_fraction"`
+	IconMinContrast    float64 `json:"icon_min_contrast"`
+	IconDimStrength    float64 `json:"icon_dim_strength"`
+	// ExcludeKeywords rejects candidates whose title, category or tags
+	// contain any of these words (case-insensitive, whole words only).
+	ExcludeKeywords []string `json:"exclude_keywords"`
+	// Source selects where wallpapers come from, one of SourceNames.
+	Source string `json:"source"`
+	// WeightedRandomSelection picks the source for each change at random
+	// using SourceWeights instead of always using Source.
+	WeightedRandomSelection bool `json:"weighted_random_selection"`
+	// SourceWeights maps source names to relative weights, e.g.
+	// {"wallscloud": 7, "aerial": 2, "cityscape": 1}. Without weighted
+	// selection, these are what Source fails over to while it backs off
+	// an anti-bot challenge.
+	SourceWeights map[string]float64 `json:"source_weights"`
+	// MaxConsecutiveSourceDays leaves a weighted source out of the draw
+	// once it set the wallpaper on this many days in a row, as long as
+	// another one can be drawn; 0 never does.
+	MaxConsecutiveSourceDays int `json:"max_consecutive_source_days"`
+
+	// OverpassBBox is the "south,west,north,east" area rendered by the cityscape source.
+	OverpassBBox string `json:"overpass_bbox"`
+	// CityscapeTimeOfDay picks the sky: "dawn", "day", "dusk" or "night".
+	CityscapeTimeOfDay string `json:"cityscape_time_of_day"`
+
+	// RemoteConfigURL is an HTTPS URL of a JSON config merged over the local
+	// one; non-empty remote values win.
+	RemoteConfigURL string `json:"remote_config_url"`
+	// WebhookURL receives a JSON POST for every wallpaper change. Events
+	// raised while offline are queued and delivered later.
+	WebhookURL string `json:"webhook_url"`
+	// EventLog writes failed changes, blocked sources, a full disk and a
+	// daily summary to the Windows Application log. It is always on while
+	// running headless. The event source must be installed first with
+	// "go-wallpaper-tray eventlog install".
+	EventLog bool `json:"event_log"`
+	// Debug logs details useful when reporting a problem, such as the
+	// random seed of the run.
+	Debug bool `json:"debug"`
+	// Seed, when not 0, seeds every random choice at startup, so a run with
+	// the same seed and config makes the same ones; the --seed flag
+	// overrides it.
+	Seed int64 `json:"seed"`
+	// RemoteConfigPollIntervalMinutes is how often RemoteConfigURL is fetched.
+	RemoteConfigPollIntervalMinutes int `json:"remote_config_poll_interval_minutes"`
+
+	// GitHubTrendingLanguage limits the github_trending source to one language.
+	GitHubTrendingLanguage string `json:"github_trending_language"`
+	// HNNumStories is how many Hacker News front page stories the
+	// hacker_news source lists, skipping those below HNMinScore points.
+	HNNumStories int `json:"hn_num_stories"`
+	HNMinScore   int `json:"hn_min_score"`
+
+	// FinanceAPIKey and FinanceAPIProvider ("finnhub" or "alphavantage")
+	// configure the stock_heatmap source.
+	FinanceAPIKey      string `json:"finance_api_key"`
+	FinanceAPIProvider string `json:"finance_api_provider"`
+
+	// WikipediaLanguage is the Wikipedia edition ("en", "de", "pt-br") the
+	// wikipedia_featured and wikipedia_random sources pick random articles
+	// from. WikipediaMinThumbWidth is the narrowest lead-image thumbnail
+	// wikipedia_random accepts; it sets the full-size image behind it.
+	WikipediaLanguage      string `json:"wikipedia_language"`
+	WikipediaMinThumbWidth int    `json:"wikipedia_min_thumb_width"`
+
+	// WikimediaPotdOverlay has the wikimedia_potd source, Wikimedia
+	// Commons' picture of the day, print the picture's title and credit in
+	// the WikimediaPotdOverlayPosition corner, one of OverlayPositions.
+	WikimediaPotdOverlay         bool   `json:"wikimedia_potd_overlay"`
+	WikimediaPotdOverlayPosition string `json:"wikimedia_potd_overlay_position"`
+
+	// CERNExperiment is the LHC experiment, one of CERNExperiments, whose
+	// event displays the cern_events source picks from.
+	CERNExperiment string `json:"cern_experiment"`
+
+	// EarthgazingRegion, one of EarthgazingRegions, is where the ISS was
+	// over when the earthgazing source's horizon photos were taken.
+	// EarthgazingDaylightOnly skips photos taken with the sun below the
+	// horizon.
+	EarthgazingRegion       string `json:"earthgazing_region"`
+	EarthgazingDaylightOnly bool   `json:"earthgazing_daylight_only"`
+
+	// SDPrompt and SDNegativePrompt drive the stable_diffusion source, which
+	// renders SDWidth x SDHeight (64-2048, multiples of 8) in SDSteps steps
+	// (1-150) at SDCFGScale (1-30) with a local Automatic1111 web UI. While
+	// its API is unreachable SDFallbackSource is used instead.
+	SDPrompt         string  `json:"sd_prompt"`
+	SDNegativePrompt string  `json:"sd_negative_prompt"`
+	SDWidth          int     `json:"sd_width"`
+	SDHeight         int     `json:"sd_height"`
+	SDSteps          int     `json:"sd_steps"`
+	SDCFGScale       float64 `json:"sd_cfg_scale"`
+	SDFallbackSource string  `json:"sd_fallback_source"`
+
+	// OpenAIAPIKey authorizes the dalle source, which generates a picture
+	// from DALLEPrompt with DALLEModel (one of DALLEModels) in DALLESize,
+	// one the model supports. It generates one a day and reuses it for the
+	// day's later changes, since every picture is billed.
+	OpenAIAPIKey string `json:"openai_api_key"`
+	DALLEModel   string `json:"dalle_model"`
+	DALLEPrompt  string `json:"dalle_prompt"`
+	DALLESize    string `json:"dalle_size"`
+
+	// S3Bucket and S3Prefix are where the s3_bucket source picks images,
+	// authorized by S3AccessKey and S3SecretKey. S3Endpoint is the base URL
+	// of an S3-compatible store such as MinIO ("http://localhost:9000") or
+	// Backblaze B2 ("https://s3.us-west-004.backblazeb2.com"); empty means
+	// AWS in S3Region.
+	S3Endpoint  string `json:"s3_endpoint"`
+	S3Bucket    string `json:"s3_bucket"`
+	S3Prefix    string `json:"s3_prefix"`
+	S3Region    string `json:"s3_region"`
+	S3AccessKey string `json:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key"`
+
+	// FTPHost, FTPPort and FTPPath are the server and folder the ftp source
+	// picks images from, logging in as FTPUser with FTPPassword (anonymous
+	// without a user). FTPUseSFTP uses SFTP instead, on port 22 while
+	// FTPPort is left at 21; it logs in with FTPPassword or the user's SSH
+	// keys, and only to hosts already in known_hosts.
+	FTPHost     string `json:"ftp_host"`
+	FTPPort     int    `json:"ftp_port"`
+	FTPUser     string `json:"ftp_user"`
+	FTPPassword string `json:"ftp_password"`
+	FTPPath     string `json:"ftp_path"`
+	FTPUseSFTP  bool   `json:"ftp_use_sftp"`
+
+	// DeviantArtClientID and DeviantArtClientSecret are the credentials of
+	// a DeviantArt API application, used by the deviantart source to browse
+	// the popular deviations of DeviantArtCategory, a category path such as
+	// "digitalart/wallpaper".
+	DeviantArtClientID     string `json:"deviantart_client_id"`
+	DeviantArtClientSecret string `json:"deviantart_client_secret"`
+	DeviantArtCategory     string `json:"deviantart_category"`
+
+	// FiveHundredPxAPIKey is the consumer key the 500px source browses
+	// FiveHundredPxFeature ("popular", "fresh" or "editors") with,
+	// optionally only in FiveHundredPxCategory, such as "Landscapes".
+	FiveHundredPxAPIKey   string `json:"fivehundredpx_api_key"`
+	FiveHundredPxFeature  string `json:"fivehundredpx_feature"`
+	FiveHundredPxCategory string `json:"fivehundredpx_category"`
+
+	// AuroraNotify has the aurora source tell, once a night, when NOAA's
+	// planetary Kp index reaches AuroraKpThreshold; the Kp an aurora needs
+	// to be seen depends on the latitude, from about 3 in the far north to
+	// 7 or more in central Europe.
+	AuroraNotify      bool    `json:"aurora_notify"`
+	AuroraKpThreshold float64 `json:"aurora_kp_threshold"`
+
+	// OpenLibraryGenre is the Open Library search, usually a subject such as
+	// "science fiction", whose covers the book_covers source tiles with
+	// BookCoverGridPadding pixels between and around them.
+	OpenLibraryGenre     string `json:"open_library_genre"`
+	BookCoverGridPadding int    `json:"book_cover_grid_padding"`
+
+	// CoolorsMatchMode is "generate" to render a trending Coolors palette
+	// as a gradient, or "download" to fetch an Unsplash photo matching its
+	// dominant hue, which needs UnsplashAccessKey.
+	CoolorsMatchMode  string `json:"coolors_match_mode"`
+	UnsplashAccessKey string `json:"unsplash_access_key"`
+
+	// AQIAPIToken is the aqicn.org data token for the aqi_map source, which
+	// maps AQIPollutant ("aqi" for the overall index, or "pm25", "pm10",
+	// "o3", "no2", "so2", "co") over AQIBoundingBox
+	// ("south,west,north,east").
+	AQIAPIToken    string `json:"aqi_api_token"`
+	AQIBoundingBox string `json:"aqi_bounding_box"`
+	AQIPollutant   string `json:"aqi_pollutant"`
+
+	// GeneratorMode "daily" seeds the offline generators (starfield,
+	// iso_city, voronoi) by the date, so a day keeps its picture; "random" draws a
+	// new one each time.
+	GeneratorMode string `json:"generator_mode"`
+
+	// StarDensity is how many stars per thousand pixels the starfield source
+	// draws, colored "white" or "realistic" by spectral type per
+	// StarColorMode, over Perlin-noise nebula clouds if NebulaEnabled.
+	StarDensity   float64 `json:"star_density"`
+	StarColorMode string  `json:"star_color_mode"`
+	NebulaEnabled bool    `json:"nebula_enabled"`
+
+	// IsoCityGridSize is the side of the iso_city source's square grid in
+	// tiles, IsoCityDensity the share of tiles (0-1) with a building and
+	// IsoCityColorScheme the lighting: "day", "sunset" or "night".
+	IsoCityGridSize    int     `json:"iso_city_grid_size"`
+	IsoCityDensity     float64 `json:"iso_city_density"`
+	IsoCityColorScheme string  `json:"iso_city_color_scheme"`
+
+	// VoronoiNumCells is how many cells the voronoi source's diagram has.
+	// VoronoiColorPalette is one of VoronoiPalettes or comma-separated
+	// "#rrggbb" colors to fill them with; VoronoiShowEdges outlines them in
+	// a contrasting color.
+	VoronoiNumCells     int    `json:"voronoi_num_cells"`
+	VoronoiColorPalette string `json:"voronoi_color_palette"`
+	VoronoiShowEdges    bool   `json:"voronoi_show_edges"`
+
+	// ClockStyle is what the clock source draws: an "analog" face, "digital"
+	// figures or a "word-clock" grid. ClockFont is one of ClockFonts or the
+	// path of a .ttf/.otf file. ClockForeground and ClockBackground are
+	// "#rrggbb" colors; two comma-separated backgrounds make a gradient.
+	// The clock is redrawn every minute while it is the source.
+	ClockStyle      string `json:"clock_style"`
+	ClockFont       string `json:"clock_font"`
+	ClockForeground string `json:"clock_foreground"`
+	ClockBackground string `json:"clock_background"`
+
+	// SysMonMetrics are the panels of the sysmon dashboard, from
+	// SysMonMetricNames in the order given, and SysMonColorScheme its palette,
+	// one of SysMonColorSchemes. The dashboard is redrawn every five minutes
+	// while it is the source.
+	SysMonMetrics     []string `json:"sysmon_metrics"`
+	SysMonColorScheme string   `json:"sysmon_color_scheme"`
+
+	// StaticMapAPIKey is the MapTiler key for the static_map source, which
+	// renders map style StaticMapStyle centred on StaticMapLat/StaticMapLon
+	// at StaticMapZoom (0-22).
+	StaticMapAPIKey string  `json:"static_map_api_key"`
+	StaticMapStyle  string  `json:"static_map_style"`
+	StaticMapLat    float64 `json:"static_map_lat"`
+	StaticMapLon    float64 `json:"static_map_lon"`
+	StaticMapZoom   int     `json:"static_map_zoom"`
+
+	// WatercolorCenterLat/WatercolorCenterLon is the centre of the Stamen
+	// watercolor map the watercolor_map source composes from tiles at
+	// WatercolorZoom (3-16).
+	WatercolorCenterLat float64 `json:"watercolor_center_lat"`
+	WatercolorCenterLon float64 `json:"watercolor_center_lon"`
+	WatercolorZoom      int     `json:"watercolor_zoom"`
+
+	// WebcamURL is the static snapshot URL the webcam source downloads,
+	// with HTTP Basic credentials if the camera wants them.
+	WebcamURL      string `json:"webcam_url"`
+	WebcamUsername string `json:"webcam_username"`
+	WebcamPassword string `json:"webcam_password"`
+
+	// StreetViewAPIKey is the Google Maps Platform key for the street_view
+	// source, which looks at a random point in StreetViewBoundingBox
+	// ("south,west,north,east") facing StreetViewHeadingMode ("random",
+	// "north" or "south").
+	StreetViewAPIKey      string `json:"street_view_api_key"`
+	StreetViewBoundingBox string `json:"street_view_bounding_box"`
+	StreetViewHeadingMode string `json:"street_view_heading_mode"`
+
+	// CryptoSymbol is the CoinGecko coin id ("bitcoin", "ethereum") the
+	// crypto_chart source plots against CryptoCurrency ("usd", "eur") as a
+	// ChartType "line" or "candlestick" chart.
+	CryptoSymbol   string `json:"crypto_symbol"`
+	CryptoCurrency string `json:"crypto_currency"`
+	ChartType      string `json:"chart_type"`
+
+	// GooglePhotosCredentialsFile is the OAuth client JSON downloaded from the
+	// Google Cloud console for the google_photos source.
+	GooglePhotosCredentialsFile string `json:"google_photos_credentials_file"`
+
+	// GCalCredentialsFile is the OAuth client JSON for the google_calendar
+	// source, which lays today's events from the primary calendar over the
+	// last wallpaper and is redrawn every 15 minutes while it is the source.
+	GCalCredentialsFile string `json:"gcal_credentials_file"`
+
+	// OneDriveTenantID and OneDriveClientID identify the Azure app
+	// registration (a public client with a http://localhost redirect) the
+	// onedrive source signs in with. The tenant defaults to "common".
+	OneDriveTenantID string `json:"onedrive_tenant_id"`
+	OneDriveClientID string `json:"onedrive_client_id"`
+	// OneDriveFolderPath is a folder under the drive root, e.g.
+	// "Pictures/Wallpapers"; empty uses the special Photos folder.
+	OneDriveFolderPath string `json:"onedrive_folder_path"`
+
+	// ScreenshotDir is a folder the screenshot source searches besides
+	// Pictures\Screenshots; ScreenshotAvoidRepeatDays is how long a
+	// screenshot it used stays out of the draw (0 allows repeats).
+	ScreenshotDir             string `json:"screenshot_dir"`
+	ScreenshotAvoidRepeatDays int    `json:"screenshot_avoid_repeat_days"`
+
+	// ChessUsername is the Lichess player whose recent classical games the
+	// chess source shows the final positions of, drawn in ChessPieceStyle,
+	// one of ChessPieceStyles.
+	ChessUsername   string `json:"chess_username"`
+	ChessPieceStyle string `json:"chess_piece_style"`
+	// LichessPuzzleQRCode prints a QR code of the puzzle's page beside the
+	// lichess_puzzle source's board, which is also drawn in
+	// ChessPieceStyle.
+	LichessPuzzleQRCode bool `json:"lichess_puzzle_qr_code"`
+
+	// PoetryAuthorFilter limits the poetry source to poems by authors whose
+	// name contains it, e.g. "Dickinson"; empty allows any.
+	PoetryAuthorFilter string `json:"poetry_author_filter"`
+
+	// QuoteTags are the Quotable tags the quote source draws from, any of
+	// them matching; empty allows every quote. QuoteMinLength and
+	// QuoteMaxLength bound the quote's length in characters, 0 leaving
+	// that end open.
+	QuoteTags      []string `json:"quote_tags"`
+	QuoteMinLength int      `json:"quote_min_length"`
+	QuoteMaxLength int      `json:"quote_max_length"`
+
+	// WorldClockTimezones are the IANA time zones, e.g. "Asia/Tokyo", whose
+	// local time the world_clock source prints under its day and night
+	// map.
+	WorldClockTimezones []string `json:"world_clock_timezones"`
+
+	// WindowsBuiltInPath is the Windows "Web" folder whose Wallpaper and
+	// Screen subfolders the windows_builtin source, and the last offline
+	// fallback, pick from.
+	WindowsBuiltInPath string `json:"windows_builtin_path"`
+
+	// AerialFFmpegPath is the ffmpeg executable the aerial source extracts
+	// frames with; a bare name is looked up in PATH.
+	AerialFFmpegPath string `json:"aerial_ffmpeg_path"`
+	// AerialTimestampMode picks the frame: "random" anywhere in the video or
+	// "fixed" at the same offset every time.
+	AerialTimestampMode string `json:"aerial_timestamp_mode"`
+
+	// ISSCameraMaxDarkPixelFraction is the share of dark pixels above which
+	// an ISS camera snapshot counts as night side and is skipped.
+	ISSCameraMaxDarkPixelFraction float64 `json:"iss_camera_max_dark_pixel_fraction"`
+
+	// ChangeOnNewEmail changes the wallpaper whenever a message arrives in
+	// IMAPMailbox, watched with IMAP IDLE over TLS.
+	ChangeOnNewEmail bool   `json:"change_on_new_email"`
+	IMAPHost         string `json:"imap_host"`
+	IMAPPort         int    `json:"imap_port"`
+	IMAPUsername     string `json:"imap_username"`
+	IMAPPassword     string `json:"imap_password"`
+	IMAPMailbox      string `json:"imap_mailbox"`
+
+	// PreviewBeforeApply offers each new wallpaper in the tray menu with
+	// Apply and Skip before setting it; with no answer within
+	// PreviewTimeoutSeconds it is applied.
+	PreviewBeforeApply    bool `json:"preview_before_apply"`
+	PreviewTimeoutSeconds int  `json:"preview_timeout_seconds"`
+
+	// RespectExternalChanges skips scheduled and triggered changes while the
+	// desktop shows a wallpaper another app set. ConflictingApps are the
+	// executables of such apps (Bing Wallpaper, Wallpaper Engine, John's
+	// Background Switcher, ...); when one is found running the tray offers to
+	// turn RespectExternalChanges on.
+	RespectExternalChanges bool     `json:"respect_external_changes"`
+	ConflictingApps        []string `json:"conflicting_apps"`
+
+	// IconTheme picks the tray icon: "light" or "dark" glyphs, the "color"
+	// icon, or "auto" to match the taskbar theme.
+	IconTheme string `json:"icon_theme"`
+
+	// DarkModeWallpapers prefers dark images while Windows apps use the dark
+	// theme: searching sources ask for dark photos and candidates with a mean
+	// luminance (0-1) above DarkModeMaxLuminance are rejected. DarkModeDim
+	// also applies the "dim" filter while dark mode is on, reprocessing the
+	// wallpaper when the theme flips.
+	DarkModeWallpapers   bool    `json:"dark_mode_wallpapers"`
+	DarkModeMaxLuminance float64 `json:"dark_mode_max_luminance"`
+	DarkModeDim          bool    `json:"dark_mode_dim"`
+
+	// WallpaperInfoTooltip shows the current wallpaper's title, source and
+	// the next change time in the tray tooltip.
+	WallpaperInfoTooltip bool `json:"wallpaper_info_tooltip"`
+
+	// AllowUSBOverride sets the image named by wallpaper_override.json in
+	// the root of a USB drive as soon as the drive is plugged in.
+	AllowUSBOverride bool `json:"allow_usb_override"`
+
+	// AllowDragDrop sets image files dropped on the app's window. Several
+	// dropped at once are set one after another, DropIntervalSeconds apart.
+	AllowDragDrop       bool `json:"allow_drag_drop"`
+	DropIntervalSeconds int  `json:"drop_interval_seconds"`
+
+	// Notifications maps each of NotificationEvents to one of
+	// NotificationModes; events left out are shown "always". The
+	// "daily_summary" ones are counted and shown together at
+	// NotificationSummaryTime, a local "HH:MM".
+	Notifications           map[string]string `json:"notifications"`
+	NotificationSummaryTime string            `json:"notification_summary_time"`
+
+	// MaxHistoryMenuItems is how many recent wallpapers the History
+	// submenu lists.
+	MaxHistoryMenuItems int `json:"max_history_menu_items"`
+	// HistoryIntegritySweep validates every history entry at startup and
+	// quarantines the unreadable ones before they are needed.
+	HistoryIntegritySweep bool `json:"history_integrity_sweep"`
+	// HistoryExplorerInfo gives the history dir an icon and tooltip in
+	// Explorer and stores each JPEG's title and source URL as its Comments.
+	HistoryExplorerInfo bool `json:"history_explorer_info"`
+	// VerifyBeforeReapply checks that a history entry's source page still
+	// exists before setting it again; entries whose page is gone are
+	// retired. When the check itself fails the entry is set anyway.
+	VerifyBeforeReapply bool `json:"verify_before_reapply"`
+	// MirrorFavorites keeps a copy of the favorites, with their sidecars,
+	// in Pictures\Wallpapers\GoWallpaperTray, by year and month, where
+	// profile cleanups don't reach.
+	MirrorFavorites bool `json:"mirror_favorites"`
+}
+
+// ProcessSettings is everything that affects how an original image ends up on
+// the desktop. Changing any of it triggers a reprocess rather than a download.
+type ProcessSettings struct {
+	FitMode              string
+	Filter               string
+	MatchBackgroundColor bool
+	// IconDim is the icon_contrast_dim setup; zero when it is off.
+	IconDim IconDim
+}
+
+// IconDim configures the darkening behind desktop icons.
+type IconDim struct {
+	RegionFraction, MinContrast, Strength float64
+}
+
+// Problem is a single issue found while loading a config file.
+type Problem struct {
+	Line  int // 1-based; 0 when unknown
+	Field string
+	Msg   string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", p.Line)
+	}
+	if p.Field != "" {
+		fmt.Fprintf(&b, "%q: ", p.Field)
+	}
+	b.WriteString(p.Msg)
+	return b.String()
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		ChangeTime:       defaultChangeTime,
+		MaxHTMLBodyBytes: 5 << 20, // 5 MB
+		Filter:           "none",
+
+		IconRegionFraction: 0.15,
+		IconMinContrast:    4.5,
+		IconDimStrength:    0.5,
+
+		IdleMaxWaitMinutes: 120,
+
+		ApplyOn:             "now",
+		PendingFallbackTime: defaultPendingTime,
+
+		RemoteConfigPollIntervalMinutes: 60,
+
+		Source: defaultSource,
+
+		MaxConsecutiveSourceDays: 3,
+
+		OverpassBBox:       "55.745,37.600,55.760,37.640", // central Moscow
+		CityscapeTimeOfDay: "dusk",
+
+		FinanceAPIProvider: "finnhub",
+
+		WikipediaLanguage:      "en",
+		WikipediaMinThumbWidth: 800,
+
+		WikimediaPotdOverlayPosition: "bottom-left",
+
+		CERNExperiment: "CMS",
+
+		EarthgazingRegion:       "any",
+		EarthgazingDaylightOnly: true,
+
+		SDPrompt:         "a serene mountain lake at sunrise, highly detailed landscape photography",
+		SDNegativePrompt: "text, watermark, people, blurry",
+		SDWidth:          1024,
+		SDHeight:         576,
+		SDSteps:          25,
+		SDCFGScale:       7,
+		SDFallbackSource: "starfield",
+
+		DALLEModel:  "dall-e-3",
+		DALLEPrompt: "a wide panoramic landscape painting, soft morning light, no text",
+		DALLESize:   "1792x1024",
+
+		S3Region: "us-east-1",
+
+		FTPPort: 21,
+
+		DeviantArtCategory: "digitalart/wallpaper",
+
+		FiveHundredPxFeature: "popular",
+
+		AuroraNotify:      true,
+		AuroraKpThreshold: 5,
+
+		OpenLibraryGenre:     "science fiction",
+		BookCoverGridPadding: 12,
+
+		CoolorsMatchMode: "generate",
+
+		AQIBoundingBox: "55.40,37.00,56.10,38.20", // Moscow region
+		AQIPollutant:   "aqi",
+
+		GeneratorMode: "daily",
+
+		StarDensity:   1,
+		StarColorMode: "realistic",
+
+		IsoCityGridSize:    12,
+		IsoCityDensity:     0.7,
+		IsoCityColorScheme: "sunset",
+
+		VoronoiNumCells:     80,
+		VoronoiColorPalette: "pastel",
+		VoronoiShowEdges:    true,
+
+		ClockStyle:      "digital",
+		ClockFont:       "go-medium",
+		ClockForeground: "#f5f5f5",
+		ClockBackground: "#0f2027,#2c5364",
+
+		SysMonMetrics:     []string{"cpu", "mem", "disk", "net"},
+		SysMonColorScheme: "dark",
+
+		StaticMapStyle: "streets-v2",
+		StaticMapLat:   55.7558, // Moscow
+		StaticMapLon:   37.6173,
+		StaticMapZoom:  12,
+
+		WatercolorCenterLat: 55.7558, // Moscow
+		WatercolorCenterLon: 37.6173,
+		WatercolorZoom:      12,
+
+		StreetViewBoundingBox: "55.57,37.36,55.91,37.85", // Moscow
+		StreetViewHeadingMode: "random",
+
+		CryptoSymbol:   "bitcoin",
+		CryptoCurrency: "usd",
+		ChartType:      "line",
+
+		OneDriveTenantID: "common",
+
+		ScreenshotAvoidRepeatDays: 30,
+
+		WindowsBuiltInPath: `C:\Windows\Web`,
+
+		ChessPieceStyle:     "classic",
+		LichessPuzzleQRCode: true,
+
+		HNNumStories: 10,
+
+		QuoteTags: []string{"technology", "science", "art"},
+
+		WorldClockTimezones: []string{"America/Los_Angeles", "America/New_York", "Europe/London", "Europe/Moscow", "Asia/Tokyo"},
+
+		AerialFFmpegPath:    "ffmpeg",
+		AerialTimestampMode: "random",
+
+		ISSCameraMaxDarkPixelFraction: 0.8,
+
+		PreviewTimeoutSeconds: 120,
+
+		DropIntervalSeconds: 10,
+
+		NotificationSummaryTime: defaultSummaryTime,
+
+		ConflictingApps: []string{"BingWallpaper.exe", "wallpaper32.exe", "wallpaper64.exe", "JohnsBackgroundSwitcher.exe"},
+
+		IconTheme: "auto",
+
+		DarkModeMaxLuminance: 0.35,
+
+		MaxHistoryMenuItems:  10,
+		WallpaperInfoTooltip: true,
+
+		IMAPPort:    993,
+		IMAPMailbox: "INBOX",
+	}
+}
+
+// ProcessSettings extracts the settings the processing pipeline depends on.
+func (c Config) ProcessSettings() ProcessSettings {
+	s := ProcessSettings{FitMode: c.FitMode, Filter: c.Filter, MatchBackgroundColor: c.MatchBackgroundColor}
+	if c.IconContrastDim {
+		s.IconDim = IconDim{RegionFraction: c.IconRegionFraction, MinContrast: c.IconMinContrast, Strength: c.IconDimStrength}
+	}
+	return s
+}
+
+// NotificationMode returns how notifications of event are shown, one of
+// NotificationModes.
+func (c Config) NotificationMode(event string) string {
+	if mode, ok := c.Notifications[event]; ok {
+		return mode
+	}
+	return "always"
+}
+
+// SummaryClock returns the hour and minute of the daily notification
+// summary.
+func (c Config) SummaryClock() (hour, min int) {
+	t, err := time.Parse(changeTimeLayout, c.NotificationSummaryTime)
+	if err != nil {
+		t, _ = time.Parse(changeTimeLayout, defaultSummaryTime)
+	}
+	return t.Hour(), t.Minute()
+}
+
+// PendingClock returns the hour and minute at which a pending wallpaper is
+// set at the latest.
+func (c Config) PendingClock() (hour, min int) {
+	t, err := time.Parse(changeTimeLayout, c.PendingFallbackTime)
+	if err != nil {
+		t, _ = time.Parse(changeTimeLayout, defaultPendingTime)
+	}
+	return t.Hour(), t.Minute()
+}
+
+// ChangeClock returns the configured change hour and minute.
+func (c Config) ChangeClock() (hour, min int) {
+	t, err := time.Parse(changeTimeLayout, c.ChangeTime)
+	if err != nil {
+		t, _ = time.Parse(changeTimeLayout, defaultChangeTime)
+	}
+	return t.Hour(), t.Minute()
+}
+
+// BBox is a geographic bounding box in degrees.
+type BBox struct {
+	South, West, North, East float64
+}
+
+// ParseBBox parses "south,west,north,east" in degrees.
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, fmt.Errorf("bbox %q: want south,west,north,east", s)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		var err error
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+			return BBox{}, fmt.Errorf("bbox %q: %w", s, err)
+		}
+	}
+	if v[0] >= v[2] || v[1] >= v[3] {
+		return BBox{}, fmt.Errorf("bbox %q: south/west must be less than north/east", s)
+	}
+	return BBox{South: v[0], West: v[1], North: v[2], East: v[3]}, nil
+}
+
+// ParseColors parses comma-separated "#rrggbb" colors.
+func ParseColors(s string) ([]color.RGBA, error) {
+	var out []color.RGBA
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "#")
+		v, err := strconv.ParseUint(p, 16, 32)
+		if err != nil || len(p) != 6 {
+			return nil, fmt.Errorf("color %q: want #rrggbb", p)
+		}
+		out = append(out, color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff})
+	}
+	return out, nil
+}
+
+// Load reads path over the defaults. A missing file is not an error.
+// Problems are non-fatal: offending values fall back to their defaults.
+// Startup, live reload and "config validate" all go through here.
+func Load(path string) (Config, []Problem, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil, nil
+	}
+	if err != nil {
+		return Default(), nil, err
+	}
+	if isYAML(path) {
+		return ParseYAML(b)
+	}
+	return Parse(b)
+}
+
+// Parse decodes data over the defaults and reports unknown fields and
+// invalid values with their line numbers.
+func Parse(data []byte) (Config, []Problem, error) {
+	cfg := Default()
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		var se *json.SyntaxError
+		var te *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &se):
+			return Default(), nil, fmt.Errorf("line %d: %v", lineAt(data, se.Offset), se)
+		case errors.As(err, &te):
+			return Default(), nil, fmt.Errorf("line %d: %q: expected %s, got %s",
+				lineAt(data, te.Offset), te.Field, te.Type, te.Value)
+		}
+		return Default(), nil, err
+	}
+
+	lines, err := fieldLines(data)
+	if err != nil {
+		return Default(), nil, err
+	}
+	problems := unknownFields(lines, true)
+	for _, p := range Validate(&cfg) {
+		p.Line = lines[p.Field]
+		problems = append(problems, p)
+	}
+	sortProblems(problems)
+	return cfg, problems, nil
+}
+
+// isLanguageCode accepts Wikipedia subdomains such as "en" or "zh-yue".
+func isLanguageCode(s string) bool {
+	if len(s) < 2 || len(s) > 12 {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func unknownFields(lines map[string]int, suggest bool) []Problem {
+	known := fieldNames()
+	var problems []Problem
+	for key, line := range lines {
+		if _, ok := known[key]; ok {
+			continue
+		}
+		msg := "unknown field"
+		if s := suggestField(key, known); suggest && s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		}
+		problems = append(problems, Problem{Line: line, Field: key, Msg: msg})
+	}
+	return problems
+}
+
+// Validate checks values and resets invalid ones to their defaults.
+func Validate(cfg *Config) []Problem {
+	def := Default()
+	var problems []Problem
+	if _, err := time.Parse(changeTimeLayout, cfg.ChangeTime); err != nil {
+		problems = append(problems, Problem{Field: "change_time",
+			Msg: fmt.Sprintf("%q is not a HH:MM time, using %s", cfg.ChangeTime, def.ChangeTime)})
+		cfg.ChangeTime = def.ChangeTime
+	}
+	if cfg.StartupDelaySeconds < 0 || cfg.StartupDelaySeconds > maxStartupDelaySeconds {
+		problems = append(problems, Problem{Field: "startup_delay_seconds",
+			Msg: fmt.Sprintf("must be between 0 and %d, using %d", maxStartupDelaySeconds, def.StartupDelaySeconds)})
+		cfg.StartupDelaySeconds = def.StartupDelaySeconds
+	}
+	if cfg.IdleMinutes < 0 {
+		problems = append(problems, Problem{Field: "idle_minutes",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.IdleMinutes)})
+		cfg.IdleMinutes = def.IdleMinutes
+	}
+	if cfg.IdleMaxWaitMinutes < 1 {
+		problems = append(problems, Problem{Field: "idle_max_wait_minutes",
+			Msg: fmt.Sprintf("must be at least 1, using %d", def.IdleMaxWaitMinutes)})
+		cfg.IdleMaxWaitMinutes = def.IdleMaxWaitMinutes
+	}
+	if !slices.Contains(ApplyOnEvents, cfg.ApplyOn) {
+		problems = append(problems, Problem{Field: "apply_on",
+			Msg: fmt.Sprintf("%q is not one of %s, using %s", cfg.ApplyOn, strings.Join(ApplyOnEvents, ", "), def.ApplyOn)})
+		cfg.ApplyOn = def.ApplyOn
+	}
+	if _, err := time.Parse(changeTimeLayout, cfg.PendingFallbackTime); err != nil {
+		problems = append(problems, Problem{Field: "pending_fallback_time",
+			Msg: fmt.Sprintf("%q is not a HH:MM time, using %s", cfg.PendingFallbackTime, def.PendingFallbackTime)})
+		cfg.PendingFallbackTime = def.PendingFallbackTime
+	}
+	if cfg.MaxHTMLBodyBytes <= 0 {
+		problems = append(problems, Problem{Field: "max_html_body_bytes",
+			Msg: fmt.Sprintf("must be positive, using %d", def.MaxHTMLBodyBytes)})
+		cfg.MaxHTMLBodyBytes = def.MaxHTMLBodyBytes
+	}
+	if cfg.FitMode != "" && !slices.Contains(FitModes, cfg.FitMode) {
+		problems = append(problems, Problem{Field: "fit_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.FitMode, strings.Join(FitModes, ", "))})
+		cfg.FitMode = def.FitMode
+	}
+	if cfg.RemoteConfigURL != "" && !strings.HasPrefix(cfg.RemoteConfigURL, "https://") {
+		problems = append(problems, Problem{Field: "remote_config_url",
+			Msg: "must be an https:// URL, remote config disabled"})
+		cfg.RemoteConfigURL = ""
+	}
+	if cfg.WebhookURL != "" && !strings.HasPrefix(cfg.WebhookURL, "https://") && !strings.HasPrefix(cfg.WebhookURL, "http://") {
+		problems = append(problems, Problem{Field: "webhook_url",
+			Msg: "must be an http(s):// URL, webhook disabled"})
+		cfg.WebhookURL = ""
+	}
+	if !slices.Contains(ClockStyles, cfg.ClockStyle) {
+		problems = append(problems, Problem{Field: "clock_style",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.ClockStyle, strings.Join(ClockStyles, ", "))})
+		cfg.ClockStyle = def.ClockStyle
+	}
+	if ext := strings.ToLower(filepath.Ext(cfg.ClockFont)); !slices.Contains(ClockFonts, cfg.ClockFont) && ext != ".ttf" && ext != ".otf" {
+		problems = append(problems, Problem{Field: "clock_font",
+			Msg: fmt.Sprintf("%q is neither one of %s nor a .ttf/.otf file", cfg.ClockFont, strings.Join(ClockFonts, ", "))})
+		cfg.ClockFont = def.ClockFont
+	}
+	if c, err := ParseColors(cfg.ClockForeground); err != nil || len(c) != 1 {
+		problems = append(problems, Problem{Field: "clock_foreground",
+			Msg: fmt.Sprintf("%q is not a #rrggbb color, using %s", cfg.ClockForeground, def.ClockForeground)})
+		cfg.ClockForeground = def.ClockForeground
+	}
+	if c, err := ParseColors(cfg.ClockBackground); err != nil || len(c) > 2 {
+		problems = append(problems, Problem{Field: "clock_background",
+			Msg: fmt.Sprintf("%q is not one or two #rrggbb colors, using %s", cfg.ClockBackground, def.ClockBackground)})
+		cfg.ClockBackground = def.ClockBackground
+	}
+	var metrics []string
+	for _, m := range cfg.SysMonMetrics {
+		switch {
+		case !slices.Contains(SysMonMetricNames, m):
+			problems = append(problems, Problem{Field: "sysmon_metrics",
+				Msg: fmt.Sprintf("%q is not one of %s, ignoring it", m, strings.Join(SysMonMetricNames, ", "))})
+		case !slices.Contains(metrics, m):
+			metrics = append(metrics, m)
+		}
+	}
+	if len(metrics) == 0 {
+		problems = append(problems, Problem{Field: "sysmon_metrics",
+			Msg: "no metrics selected, showing all"})
+		metrics = def.SysMonMetrics
+	}
+	cfg.SysMonMetrics = metrics
+	if !slices.Contains(SysMonColorSchemes, cfg.SysMonColorScheme) {
+		problems = append(problems, Problem{Field: "sysmon_color_scheme",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.SysMonColorScheme, strings.Join(SysMonColorSchemes, ", "))})
+		cfg.SysMonColorScheme = def.SysMonColorScheme
+	}
+	apps := cfg.ConflictingApps[:0:0]
+	for _, name := range cfg.ConflictingApps {
+		if name == "" || strings.ContainsAny(name, `\/`) {
+			problems = append(problems, Problem{Field: "conflicting_apps",
+				Msg: fmt.Sprintf("%q is not an executable name such as BingWallpaper.exe, ignoring it", name)})
+			continue
+		}
+		apps = append(apps, name)
+	}
+	cfg.ConflictingApps = apps
+	if cfg.StaticMapStyle == "" {
+		problems = append(problems, Problem{Field: "static_map_style",
+			Msg: fmt.Sprintf("must not be empty, using %q", def.StaticMapStyle)})
+		cfg.StaticMapStyle = def.StaticMapStyle
+	}
+	if cfg.StaticMapLat < -90 || cfg.StaticMapLat > 90 || cfg.StaticMapLon < -180 || cfg.StaticMapLon > 180 {
+		problems = append(problems, Problem{Field: "static_map_lat",
+			Msg: fmt.Sprintf("%g,%g is not a valid position, using %g,%g", cfg.StaticMapLat, cfg.StaticMapLon, def.StaticMapLat, def.StaticMapLon)})
+		cfg.StaticMapLat, cfg.StaticMapLon = def.StaticMapLat, def.StaticMapLon
+	}
+	if cfg.StaticMapZoom < 0 || cfg.StaticMapZoom > maxStaticMapZoom {
+		problems = append(problems, Problem{Field: "static_map_zoom",
+			Msg: fmt.Sprintf("must be between 0 and %d, using %d", maxStaticMapZoom, def.StaticMapZoom)})
+		cfg.StaticMapZoom = def.StaticMapZoom
+	}
+	if cfg.WatercolorCenterLat < -85 || cfg.WatercolorCenterLat > 85 || cfg.WatercolorCenterLon < -180 || cfg.WatercolorCenterLon > 180 {
+		problems = append(problems, Problem{Field: "watercolor_center_lat",
+			Msg: fmt.Sprintf("%g,%g is not a position on the map, using %g,%g", cfg.WatercolorCenterLat, cfg.WatercolorCenterLon, def.WatercolorCenterLat, def.WatercolorCenterLon)})
+		cfg.WatercolorCenterLat, cfg.WatercolorCenterLon = def.WatercolorCenterLat, def.WatercolorCenterLon
+	}
+	if cfg.WatercolorZoom < minWatercolorZoom || cfg.WatercolorZoom > maxWatercolorZoom {
+		problems = append(problems, Problem{Field: "watercolor_zoom",
+			Msg: fmt.Sprintf("must be between %d and %d, using %d", minWatercolorZoom, maxWatercolorZoom, def.WatercolorZoom)})
+		cfg.WatercolorZoom = def.WatercolorZoom
+	}
+	if cfg.WebcamURL != "" && !strings.HasPrefix(cfg.WebcamURL, "https://") && !strings.HasPrefix(cfg.WebcamURL, "http://") {
+		problems = append(problems, Problem{Field: "webcam_url",
+			Msg: "must be an http(s):// URL"})
+		cfg.WebcamURL = ""
+	}
+	if cfg.RemoteConfigPollIntervalMinutes < 1 {
+		problems = append(problems, Problem{Field: "remote_config_poll_interval_minutes",
+			Msg: fmt.Sprintf("must be at least 1, using %d", def.RemoteConfigPollIntervalMinutes)})
+		cfg.RemoteConfigPollIntervalMinutes = def.RemoteConfigPollIntervalMinutes
+	}
+	if !slices.Contains(Filters, cfg.Filter) {
+		problems = append(problems, Problem{Field: "filter",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.Filter, strings.Join(Filters, ", "))})
+		cfg.Filter = def.Filter
+	}
+	if cfg.IconRegionFraction <= 0 || cfg.IconRegionFraction > 1 {
+		problems = append(problems, Problem{Field: "icon_region_fraction",
+			Msg: fmt.Sprintf("must be in (0, 1], using %g", def.IconRegionFraction)})
+		cfg.IconRegionFraction = def.IconRegionFraction
+	}
+	if cfg.IconMinContrast < 1 || cfg.IconMinContrast > 21 {
+		problems = append(problems, Problem{Field: "icon_min_contrast",
+			Msg: fmt.Sprintf("must be a contrast ratio between 1 and 21, using %g", def.IconMinContrast)})
+		cfg.IconMinContrast = def.IconMinContrast
+	}
+	if cfg.IconDimStrength <= 0 || cfg.IconDimStrength > 1 {
+		problems = append(problems, Problem{Field: "icon_dim_strength",
+			Msg: fmt.Sprintf("must be in (0, 1], using %g", def.IconDimStrength)})
+		cfg.IconDimStrength = def.IconDimStrength
+	}
+	if !slices.Contains(SourceNames, cfg.Source) {
+		problems = append(problems, Problem{Field: "source",
+			Msg: fmt.Sprintf("unknown source %q (known: %s), using %s",
+				cfg.Source, strings.Join(SourceNames, ", "), def.Source)})
+		cfg.Source = def.Source
+	}
+	for name, w := range cfg.SourceWeights {
+		switch {
+		case !slices.Contains(SourceNames, name):
+			problems = append(problems, Problem{Field: "source_weights",
+				Msg: fmt.Sprintf("unknown source %q (known: %s), ignoring it", name, strings.Join(SourceNames, ", "))})
+			delete(cfg.SourceWeights, name)
+		case w < 0:
+			problems = append(problems, Problem{Field: "source_weights",
+				Msg: fmt.Sprintf("weight for %q must not be negative, ignoring it", name)})
+			delete(cfg.SourceWeights, name)
+		}
+	}
+	if cfg.WeightedRandomSelection && !hasPositiveWeight(cfg.SourceWeights) {
+		problems = append(problems, Problem{Field: "weighted_random_selection",
+			Msg: fmt.Sprintf("source_weights has no positive weights, using source %q", cfg.Source)})
+		cfg.WeightedRandomSelection = false
+	}
+	if cfg.MaxConsecutiveSourceDays < 0 {
+		problems = append(problems, Problem{Field: "max_consecutive_source_days",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.MaxConsecutiveSourceDays)})
+		cfg.MaxConsecutiveSourceDays = def.MaxConsecutiveSourceDays
+	}
+	if _, err := ParseBBox(cfg.OverpassBBox); err != nil {
+		problems = append(problems, Problem{Field: "overpass_bbox", Msg: err.Error()})
+		cfg.OverpassBBox = def.OverpassBBox
+	}
+	if !slices.Contains(TimesOfDay, cfg.CityscapeTimeOfDay) {
+		problems = append(problems, Problem{Field: "cityscape_time_of_day",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.CityscapeTimeOfDay, strings.Join(TimesOfDay, ", "))})
+		cfg.CityscapeTimeOfDay = def.CityscapeTimeOfDay
+	}
+	if !slices.Contains(FinanceProviders, cfg.FinanceAPIProvider) {
+		problems = append(problems, Problem{Field: "finance_api_provider",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.FinanceAPIProvider, strings.Join(FinanceProviders, ", "))})
+		cfg.FinanceAPIProvider = def.FinanceAPIProvider
+	}
+	if !isLanguageCode(cfg.WikipediaLanguage) {
+		problems = append(problems, Problem{Field: "wikipedia_language",
+			Msg: fmt.Sprintf("%q is not a Wikipedia language code, using %q", cfg.WikipediaLanguage, def.WikipediaLanguage)})
+		cfg.WikipediaLanguage = def.WikipediaLanguage
+	}
+	if cfg.WikipediaMinThumbWidth <= 0 {
+		problems = append(problems, Problem{Field: "wikipedia_min_thumb_width",
+			Msg: fmt.Sprintf("must be positive, using %d", def.WikipediaMinThumbWidth)})
+		cfg.WikipediaMinThumbWidth = def.WikipediaMinThumbWidth
+	}
+	if !slices.Contains(CoolorsModes, cfg.CoolorsMatchMode) {
+		problems = append(problems, Problem{Field: "coolors_match_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.CoolorsMatchMode, strings.Join(CoolorsModes, ", "))})
+		cfg.CoolorsMatchMode = def.CoolorsMatchMode
+	}
+	if _, err := ParseBBox(cfg.AQIBoundingBox); err != nil {
+		problems = append(problems, Problem{Field: "aqi_bounding_box", Msg: err.Error()})
+		cfg.AQIBoundingBox = def.AQIBoundingBox
+	}
+	if !slices.Contains(AQIPollutants, cfg.AQIPollutant) {
+		problems = append(problems, Problem{Field: "aqi_pollutant",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.AQIPollutant, strings.Join(AQIPollutants, ", "))})
+		cfg.AQIPollutant = def.AQIPollutant
+	}
+	if cfg.StarDensity <= 0 || cfg.StarDensity > maxStarDensity {
+		problems = append(problems, Problem{Field: "star_density",
+			Msg: fmt.Sprintf("must be in (0, %g], using %g", maxStarDensity, def.StarDensity)})
+		cfg.StarDensity = def.StarDensity
+	}
+	if !slices.Contains(StarColorModes, cfg.StarColorMode) {
+		problems = append(problems, Problem{Field: "star_color_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.StarColorMode, strings.Join(StarColorModes, ", "))})
+		cfg.StarColorMode = def.StarColorMode
+	}
+	if !slices.Contains(GeneratorModes, cfg.GeneratorMode) {
+		problems = append(problems, Problem{Field: "generator_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.GeneratorMode, strings.Join(GeneratorModes, ", "))})
+		cfg.GeneratorMode = def.GeneratorMode
+	}
+	if !slices.Contains(OverlayPositions, cfg.WikimediaPotdOverlayPosition) {
+		problems = append(problems, Problem{Field: "wikimedia_potd_overlay_position",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.WikimediaPotdOverlayPosition, strings.Join(OverlayPositions, ", "))})
+		cfg.WikimediaPotdOverlayPosition = def.WikimediaPotdOverlayPosition
+	}
+	if !slices.Contains(CERNExperiments, cfg.CERNExperiment) {
+		problems = append(problems, Problem{Field: "cern_experiment",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.CERNExperiment, strings.Join(CERNExperiments, ", "))})
+		cfg.CERNExperiment = def.CERNExperiment
+	}
+	if !slices.Contains(EarthgazingRegions, cfg.EarthgazingRegion) {
+		problems = append(problems, Problem{Field: "earthgazing_region",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.EarthgazingRegion, strings.Join(EarthgazingRegions, ", "))})
+		cfg.EarthgazingRegion = def.EarthgazingRegion
+	}
+	if cfg.SDWidth < minSDSize || cfg.SDWidth > maxSDSize || cfg.SDWidth%8 != 0 {
+		problems = append(problems, Problem{Field: "sd_width",
+			Msg: fmt.Sprintf("must be a multiple of 8 between %d and %d, using %d", minSDSize, maxSDSize, def.SDWidth)})
+		cfg.SDWidth = def.SDWidth
+	}
+	if cfg.SDHeight < minSDSize || cfg.SDHeight > maxSDSize || cfg.SDHeight%8 != 0 {
+		problems = append(problems, Problem{Field: "sd_height",
+			Msg: fmt.Sprintf("must be a multiple of 8 between %d and %d, using %d", minSDSize, maxSDSize, def.SDHeight)})
+		cfg.SDHeight = def.SDHeight
+	}
+	if cfg.SDSteps < 1 || cfg.SDSteps > maxSDSteps {
+		problems = append(problems, Problem{Field: "sd_steps",
+			Msg: fmt.Sprintf("must be between 1 and %d, using %d", maxSDSteps, def.SDSteps)})
+		cfg.SDSteps = def.SDSteps
+	}
+	if cfg.SDCFGScale < minSDCFGScale || cfg.SDCFGScale > maxSDCFGScale {
+		problems = append(problems, Problem{Field: "sd_cfg_scale",
+			Msg: fmt.Sprintf("must be between %g and %g, using %g", minSDCFGScale, maxSDCFGScale, def.SDCFGScale)})
+		cfg.SDCFGScale = def.SDCFGScale
+	}
+	if cfg.SDFallbackSource == "stable_diffusion" || !slices.Contains(SourceNames, cfg.SDFallbackSource) {
+		problems = append(problems, Problem{Field: "sd_fallback_source",
+			Msg: fmt.Sprintf("%q is not another of %s, using %s", cfg.SDFallbackSource, strings.Join(SourceNames, ", "), def.SDFallbackSource)})
+		cfg.SDFallbackSource = def.SDFallbackSource
+	}
+	if !slices.Contains(DALLEModels, cfg.DALLEModel) {
+		problems = append(problems, Problem{Field: "dalle_model",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.DALLEModel, strings.Join(DALLEModels, ", "))})
+		cfg.DALLEModel = def.DALLEModel
+	}
+	if sizes := dalleSizes[cfg.DALLEModel]; !slices.Contains(sizes, cfg.DALLESize) {
+		problems = append(problems, Problem{Field: "dalle_size",
+			Msg: fmt.Sprintf("%s makes %s, using %s", cfg.DALLEModel, strings.Join(sizes, ", "), sizes[0])})
+		cfg.DALLESize = sizes[0]
+	}
+	if cfg.S3Endpoint != "" && !strings.HasPrefix(cfg.S3Endpoint, "https://") && !strings.HasPrefix(cfg.S3Endpoint, "http://") {
+		problems = append(problems, Problem{Field: "s3_endpoint",
+			Msg: "must be an http(s):// URL, using AWS"})
+		cfg.S3Endpoint = ""
+	}
+	if cfg.S3Region == "" {
+		problems = append(problems, Problem{Field: "s3_region",
+			Msg: fmt.Sprintf("must not be empty, using %s", def.S3Region)})
+		cfg.S3Region = def.S3Region
+	}
+	if cfg.FTPPort < 1 || cfg.FTPPort > 65535 {
+		problems = append(problems, Problem{Field: "ftp_port",
+			Msg: fmt.Sprintf("must be between 1 and 65535, using %d", def.FTPPort)})
+		cfg.FTPPort = def.FTPPort
+	}
+	if strings.Trim(cfg.DeviantArtCategory, "/ ") == "" {
+		problems = append(problems, Problem{Field: "deviantart_category",
+			Msg: fmt.Sprintf("must not be empty, using %q", def.DeviantArtCategory)})
+		cfg.DeviantArtCategory = def.DeviantArtCategory
+	}
+	if !slices.Contains(FiveHundredPxFeatures, cfg.FiveHundredPxFeature) {
+		problems = append(problems, Problem{Field: "fivehundredpx_feature",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.FiveHundredPxFeature, strings.Join(FiveHundredPxFeatures, ", "))})
+		cfg.FiveHundredPxFeature = def.FiveHundredPxFeature
+	}
+	if cfg.AuroraKpThreshold < 0 || cfg.AuroraKpThreshold > maxKp {
+		problems = append(problems, Problem{Field: "aurora_kp_threshold",
+			Msg: fmt.Sprintf("must be between 0 and %d, using %g", maxKp, def.AuroraKpThreshold)})
+		cfg.AuroraKpThreshold = def.AuroraKpThreshold
+	}
+	if strings.TrimSpace(cfg.OpenLibraryGenre) == "" {
+		problems = append(problems, Problem{Field: "open_library_genre",
+			Msg: fmt.Sprintf("must not be empty, using %q", def.OpenLibraryGenre)})
+		cfg.OpenLibraryGenre = def.OpenLibraryGenre
+	}
+	if cfg.BookCoverGridPadding < 0 || cfg.BookCoverGridPadding > maxBookCoverGridPadding {
+		problems = append(problems, Problem{Field: "book_cover_grid_padding",
+			Msg: fmt.Sprintf("must be between 0 and %d, using %d", maxBookCoverGridPadding, def.BookCoverGridPadding)})
+		cfg.BookCoverGridPadding = def.BookCoverGridPadding
+	}
+	if cfg.IsoCityGridSize < minIsoCityGridSize || cfg.IsoCityGridSize > maxIsoCityGridSize {
+		problems = append(problems, Problem{Field: "iso_city_grid_size",
+			Msg: fmt.Sprintf("must be between %d and %d, using %d", minIsoCityGridSize, maxIsoCityGridSize, def.IsoCityGridSize)})
+		cfg.IsoCityGridSize = def.IsoCityGridSize
+	}
+	if cfg.IsoCityDensity <= 0 || cfg.IsoCityDensity > 1 {
+		problems = append(problems, Problem{Field: "iso_city_density",
+			Msg: fmt.Sprintf("must be in (0, 1], using %g", def.IsoCityDensity)})
+		cfg.IsoCityDensity = def.IsoCityDensity
+	}
+	if !slices.Contains(IsoCitySchemes, cfg.IsoCityColorScheme) {
+		problems = append(problems, Problem{Field: "iso_city_color_scheme",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.IsoCityColorScheme, strings.Join(IsoCitySchemes, ", "))})
+		cfg.IsoCityColorScheme = def.IsoCityColorScheme
+	}
+	if cfg.VoronoiNumCells < minVoronoiCells || cfg.VoronoiNumCells > maxVoronoiCells {
+		problems = append(problems, Problem{Field: "voronoi_num_cells",
+			Msg: fmt.Sprintf("must be between %d and %d, using %d", minVoronoiCells, maxVoronoiCells, def.VoronoiNumCells)})
+		cfg.VoronoiNumCells = def.VoronoiNumCells
+	}
+	if _, err := ParseColors(cfg.VoronoiColorPalette); err != nil && !slices.Contains(VoronoiPalettes, cfg.VoronoiColorPalette) {
+		problems = append(problems, Problem{Field: "voronoi_color_palette",
+			Msg: fmt.Sprintf("%q is neither one of %s nor #rrggbb colors, using %q", cfg.VoronoiColorPalette, strings.Join(VoronoiPalettes, ", "), def.VoronoiColorPalette)})
+		cfg.VoronoiColorPalette = def.VoronoiColorPalette
+	}
+	if _, err := ParseBBox(cfg.StreetViewBoundingBox); err != nil {
+		problems = append(problems, Problem{Field: "street_view_bounding_box", Msg: err.Error()})
+		cfg.StreetViewBoundingBox = def.StreetViewBoundingBox
+	}
+	if !slices.Contains(HeadingModes, cfg.StreetViewHeadingMode) {
+		problems = append(problems, Problem{Field: "street_view_heading_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.StreetViewHeadingMode, strings.Join(HeadingModes, ", "))})
+		cfg.StreetViewHeadingMode = def.StreetViewHeadingMode
+	}
+	if !slices.Contains(ChartTypes, cfg.ChartType) {
+		problems = append(problems, Problem{Field: "chart_type",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.ChartType, strings.Join(ChartTypes, ", "))})
+		cfg.ChartType = def.ChartType
+	}
+	if cfg.ScreenshotAvoidRepeatDays < 0 {
+		problems = append(problems, Problem{Field: "screenshot_avoid_repeat_days",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.ScreenshotAvoidRepeatDays)})
+		cfg.ScreenshotAvoidRepeatDays = def.ScreenshotAvoidRepeatDays
+	}
+	if !slices.Contains(ChessPieceStyles, cfg.ChessPieceStyle) {
+		problems = append(problems, Problem{Field: "chess_piece_style",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.ChessPieceStyle, strings.Join(ChessPieceStyles, ", "))})
+		cfg.ChessPieceStyle = def.ChessPieceStyle
+	}
+	if cfg.WindowsBuiltInPath == "" {
+		problems = append(problems, Problem{Field: "windows_builtin_path",
+			Msg: fmt.Sprintf("must not be empty, using %s", def.WindowsBuiltInPath)})
+		cfg.WindowsBuiltInPath = def.WindowsBuiltInPath
+	}
+	if cfg.HNNumStories < 1 || cfg.HNNumStories > maxHNStories {
+		problems = append(problems, Problem{Field: "hn_num_stories",
+			Msg: fmt.Sprintf("must be between 1 and %d, using %d", maxHNStories, def.HNNumStories)})
+		cfg.HNNumStories = def.HNNumStories
+	}
+	if cfg.HNMinScore < 0 {
+		problems = append(problems, Problem{Field: "hn_min_score",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.HNMinScore)})
+		cfg.HNMinScore = def.HNMinScore
+	}
+	var tags []string
+	for _, t := range cfg.QuoteTags {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	cfg.QuoteTags = tags
+	if cfg.QuoteMinLength < 0 {
+		problems = append(problems, Problem{Field: "quote_min_length",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.QuoteMinLength)})
+		cfg.QuoteMinLength = def.QuoteMinLength
+	}
+	if cfg.QuoteMaxLength < 0 {
+		problems = append(problems, Problem{Field: "quote_max_length",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.QuoteMaxLength)})
+		cfg.QuoteMaxLength = def.QuoteMaxLength
+	}
+	if cfg.QuoteMaxLength > 0 && cfg.QuoteMinLength > cfg.QuoteMaxLength {
+		problems = append(problems, Problem{Field: "quote_max_length",
+			Msg: fmt.Sprintf("must not be below quote_min_length %d, using %d", cfg.QuoteMinLength, def.QuoteMaxLength)})
+		cfg.QuoteMaxLength = def.QuoteMaxLength
+	}
+	var zones []string
+	for _, z := range cfg.WorldClockTimezones {
+		if _, err := time.LoadLocation(z); err != nil || z == "" {
+			problems = append(problems, Problem{Field: "world_clock_timezones",
+				Msg: fmt.Sprintf("%q is not an IANA time zone, leaving it out", z)})
+			continue
+		}
+		zones = append(zones, z)
+	}
+	cfg.WorldClockTimezones = zones
+	if cfg.AerialFFmpegPath == "" {
+		problems = append(problems, Problem{Field: "aerial_ffmpeg_path",
+			Msg: fmt.Sprintf("must not be empty, using %s", def.AerialFFmpegPath)})
+		cfg.AerialFFmpegPath = def.AerialFFmpegPath
+	}
+	if !slices.Contains(TimestampModes, cfg.AerialTimestampMode) {
+		problems = append(problems, Problem{Field: "aerial_timestamp_mode",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.AerialTimestampMode, strings.Join(TimestampModes, ", "))})
+		cfg.AerialTimestampMode = def.AerialTimestampMode
+	}
+	if cfg.ISSCameraMaxDarkPixelFraction <= 0 || cfg.ISSCameraMaxDarkPixelFraction > 1 {
+		problems = append(problems, Problem{Field: "iss_camera_max_dark_pixel_fraction",
+			Msg: fmt.Sprintf("must be in (0, 1], using %g", def.ISSCameraMaxDarkPixelFraction)})
+		cfg.ISSCameraMaxDarkPixelFraction = def.ISSCameraMaxDarkPixelFraction
+	}
+	if cfg.PreviewTimeoutSeconds < minPreviewTimeoutSeconds {
+		problems = append(problems, Problem{Field: "preview_timeout_seconds",
+			Msg: fmt.Sprintf("must be at least %d, using %d", minPreviewTimeoutSeconds, def.PreviewTimeoutSeconds)})
+		cfg.PreviewTimeoutSeconds = def.PreviewTimeoutSeconds
+	}
+	if cfg.DropIntervalSeconds < 0 {
+		problems = append(problems, Problem{Field: "drop_interval_seconds",
+			Msg: fmt.Sprintf("must not be negative, using %d", def.DropIntervalSeconds)})
+		cfg.DropIntervalSeconds = def.DropIntervalSeconds
+	}
+	for event, mode := range cfg.Notifications {
+		switch {
+		case !slices.Contains(NotificationEvents, event):
+			problems = append(problems, Problem{Field: "notifications",
+				Msg: fmt.Sprintf("unknown event %q (known: %s), ignoring it", event, strings.Join(NotificationEvents, ", "))})
+			delete(cfg.Notifications, event)
+		case !slices.Contains(NotificationModes, mode):
+			problems = append(problems, Problem{Field: "notifications",
+				Msg: fmt.Sprintf("%s: %q is not one of %s, using always", event, mode, strings.Join(NotificationModes, ", "))})
+			delete(cfg.Notifications, event)
+		}
+	}
+	if _, err := time.Parse(changeTimeLayout, cfg.NotificationSummaryTime); err != nil {
+		problems = append(problems, Problem{Field: "notification_summary_time",
+			Msg: fmt.Sprintf("%q is not a HH:MM time, using %s", cfg.NotificationSummaryTime, def.NotificationSummaryTime)})
+		cfg.NotificationSummaryTime = def.NotificationSummaryTime
+	}
+	if !slices.Contains(IconThemes, cfg.IconTheme) {
+		problems = append(problems, Problem{Field: "icon_theme",
+			Msg: fmt.Sprintf("%q is not one of %s", cfg.IconTheme, strings.Join(IconThemes, ", "))})
+		cfg.IconTheme = def.IconTheme
+	}
+	if cfg.DarkModeMaxLuminance <= 0 || cfg.DarkModeMaxLuminance > 1 {
+		problems = append(problems, Problem{Field: "dark_mode_max_luminance",
+			Msg: fmt.Sprintf("must be in (0, 1], using %g", def.DarkModeMaxLuminance)})
+		cfg.DarkModeMaxLuminance = def.DarkModeMaxLuminance
+	}
+	if cfg.MaxHistoryMenuItems < 1 || cfg.MaxHistoryMenuItems > maxHistoryMenuItems {
+		problems = append(problems, Problem{Field: "max_history_menu_items",
+			Msg: fmt.Sprintf("must be between 1 and %d, using %d", maxHistoryMenuItems, def.MaxHistoryMenuItems)})
+		cfg.MaxHistoryMenuItems = def.MaxHistoryMenuItems
+	}
+	if cfg.IMAPPort < 1 || cfg.IMAPPort > 65535 {
+		problems = append(problems, Problem{Field: "imap_port",
+			Msg: fmt.Sprintf("%d is not a port number, using %d", cfg.IMAPPort, def.IMAPPort)})
+		cfg.IMAPPort = def.IMAPPort
+	}
+	if cfg.ChangeOnNewEmail && cfg.IMAPHost == "" {
+		problems = append(problems, Problem{Field: "change_on_new_email",
+			Msg: "needs imap_host, not watching mail"})
+		cfg.ChangeOnNewEmail = false
+	}
+	return problems
+}
+
+func hasPositiveWeight(weights map[string]float64) bool {
+	for _, w := range weights {
+		if w > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldLines maps each top-level key in data to the line it appears on.
+func fieldLines(data []byte) (map[string]int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	lines := map[string]int{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		lines[key] = lineAt(data, dec.InputOffset())
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func fieldNames() map[string]struct{} {
+	names := map[string]struct{}{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// suggestField returns the known field closest to key, or "" if none is close.
+func suggestField(key string, known map[string]struct{}) string {
+	best, bestDist := "", maxSuggestDistance+1
+	for name := range known {
+		if d := levenshtein(strings.ToLower(key), name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	if bestDist > maxSuggestDistance {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func sortProblems(ps []Problem) {
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Line != ps[j].Line {
+			return ps[i].Line < ps[j].Line
+		}
+		return ps[i].Field < ps[j].Field
+	})
+}
+
+// WriteDefault writes a fully-populated config with default values.
+func WriteDefault(path string, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+	}
+	return Save(path, Default())
+}
+
+// Save writes cfg as indented JSON, or as YAML for a .yaml/.yml path.
+func Save(path string, cfg Config) error {
+	if isYAML(path) {
+		b, err := marshalYAML(cfg)
+		if err != nil {
+			return err
+		}
+		return store.WriteFileAtomic(path, b)
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, append(b, '\n'))
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if problems := Validate(&cfg); len(problems) != 0 {
+		t.Errorf("Validate(Default()) = %v", problems)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		field   string // of the one problem expected, "" for none
+		line    int
+		checkFn func(Config) bool
+	}{
+		{"empty object", `{}`, "", 0, func(c Config) bool { return c.ChangeTime == defaultChangeTime }},
+		{"value kept", `{"change_time": "07:30"}`, "", 0, func(c Config) bool { return c.ChangeTime == "07:30" }},
+		{"invalid value reset", "{\n  \"change_time\": \"25:00\"\n}", "change_time", 2,
+			func(c Config) bool { return c.ChangeTime == defaultChangeTime }},
+		{"unknown field", "{\n  \"chnage_time\": \"07:30\"\n}", "chnage_time", 2,
+			func(c Config) bool { return c.ChangeTime == defaultChangeTime }},
+		{"unknown source", `{"source": "nowhere"}`, "source", 1,
+			func(c Config) bool { return c.Source == defaultSource }},
+		{"update notifications", `{"notifications": {"update_available": "daily_summary"}}`, "", 0,
+			func(c Config) bool { return c.NotificationMode("update_available") == "daily_summary" }},
+		{"unknown notification event", `{"notifications": {"updates": "never"}}`, "notifications", 1,
+			func(c Config) bool { return len(c.Notifications) == 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, problems, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			switch {
+			case tt.field == "" && len(problems) != 0:
+				t.Errorf("problems = %v, want none", problems)
+			case tt.field != "" && (len(problems) != 1 || problems[0].Field != tt.field || problems[0].Line != tt.line):
+				t.Errorf("problems = %v, want one for %q on line %d", problems, tt.field, tt.line)
+			}
+			if !tt.checkFn(cfg) {
+				t.Errorf("unexpected config after parsing %s", tt.data)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	_, _, err := Parse([]byte("{\n  \"change_time\": \"07:30\",\n}"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3") {
+		t.Errorf("Parse = %v, want a syntax error on line 3", err)
+	}
+}
+
+func TestSourceNamesSorted(t *testing.T) {
+	if !slices.IsSorted(SourceNames) {
+		t.Error("SourceNames is not sorted")
+	}
+}
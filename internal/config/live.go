@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Live holds the running configuration as two layers: the local file and an
+// optional remote override. Readers always see the merged, validated result.
+type Live struct {
+	mu        sync.RWMutex
+	effective Config
+	local     Config
+	remote    *Config
+	// changed is closed and replaced on every publish.
+	changed chan struct{}
+}
+
+// NewLive starts with cfg as both the local and effective config.
+func NewLive(cfg Config) *Live {
+	return &Live{effective: cfg, local: cfg, changed: make(chan struct{})}
+}
+
+// Current returns the effective config.
+func (l *Live) Current() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.effective
+}
+
+// Local returns the config as loaded from disk, without remote values.
+func (l *Live) Local() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.local
+}
+
+// Changed returns a channel that is closed the next time the effective
+// config is republished.
+func (l *Live) Changed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.changed
+}
+
+// Init replaces the local layer without notifying anyone.
+func (l *Live) Init(cfg Config) {
+	l.mu.Lock()
+	l.local, l.effective = cfg, cfg
+	l.mu.Unlock()
+}
+
+// SetLocal replaces the on-disk layer and republishes the effective config.
+// It reports whether the change affects how the current wallpaper is processed.
+func (l *Live) SetLocal(cfg Config) (reprocess bool) {
+	l.mu.Lock()
+	l.local = cfg
+	l.mu.Unlock()
+	return l.Publish()
+}
+
+// SetRemote replaces the remote layer (nil removes it).
+func (l *Live) SetRemote(cfg *Config) (reprocess bool) {
+	l.mu.Lock()
+	l.remote = cfg
+	l.mu.Unlock()
+	return l.Publish()
+}
+
+// Publish recomputes the effective config and wakes Changed waiters.
+func (l *Live) Publish() (reprocess bool) {
+	l.mu.Lock()
+	eff := l.local
+	if l.remote != nil {
+		eff = Merge(l.local, *l.remote)
+		for _, p := range Validate(&eff) {
+			fmt.Println("remote config warning:", p)
+		}
+	}
+	old := l.effective
+	l.effective = eff
+	close(l.changed)
+	l.changed = make(chan struct{})
+	l.mu.Unlock()
+
+	return old.ProcessSettings() != eff.ProcessSettings()
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// RegistryPath is the HKCU key "config export-registry" writes and Group
+// Policy can pre-populate: one value per config field, named like the JSON
+// key. Strings and floats are REG_SZ, bools and ints REG_DWORD,
+// max_html_body_bytes REG_QWORD, and lists and maps REG_SZ holding JSON.
+const RegistryPath = `Software\GoWallpaperTray`
+
+// ExportRegistry writes every field of cfg under RegistryPath.
+func ExportRegistry(cfg Config) error {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, RegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := jsonName(v.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		if err := setRegistryValue(k, name, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setRegistryValue(k registry.Key, name string, f reflect.Value) error {
+	switch f.Kind() {
+	case reflect.String:
+		return k.SetStringValue(name, f.String())
+	case reflect.Bool:
+		var d uint32
+		if f.Bool() {
+			d = 1
+		}
+		return k.SetDWordValue(name, d)
+	case reflect.Int64:
+		return k.SetQWordValue(name, uint64(f.Int()))
+	case reflect.Int:
+		return k.SetDWordValue(name, uint32(f.Int()))
+	case reflect.Float64:
+		return k.SetStringValue(name, strconv.FormatFloat(f.Float(), 'g', -1, 64))
+	default:
+		b, err := json.Marshal(f.Interface())
+		if err != nil {
+			return err
+		}
+		return k.SetStringValue(name, string(b))
+	}
+}
+
+// ImportRegistry reads the values under RegistryPath over the defaults,
+// with the same validation as Parse. Missing values keep their defaults.
+func ImportRegistry() (Config, []Problem, error) {
+	k, err := registry.OpenKey(registry.CURRENT_USER, RegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return Default(), nil, err
+	}
+	defer k.Close()
+	fields := map[string]reflect.StructField{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			fields[name] = t.Field(i)
+		}
+	}
+	names, err := k.ReadValueNames(0)
+	if err != nil {
+		return Default(), nil, err
+	}
+	obj := map[string]json.RawMessage{}
+	var problems []Problem
+	for _, name := range names {
+		f, ok := fields[name]
+		if !ok {
+			problems = append(problems, Problem{Field: name, Msg: "unknown registry value"})
+			continue
+		}
+		raw, err := registryValueJSON(k, name, f.Type.Kind())
+		if err != nil {
+			return Default(), nil, fmt.Errorf("%s: %w", name, err)
+		}
+		obj[name] = raw
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return Default(), nil, err
+	}
+	cfg, more, err := Parse(b)
+	if err != nil {
+		return Default(), nil, err
+	}
+	problems = append(problems, more...)
+	for i := range problems {
+		problems[i].Line = 0 // the synthesized JSON has no meaningful lines
+	}
+	sortProblems(problems)
+	return cfg, problems, nil
+}
+
+// registryValueJSON renders value name as the JSON of a field of kind.
+func registryValueJSON(k registry.Key, name string, kind reflect.Kind) (json.RawMessage, error) {
+	switch kind {
+	case reflect.Bool:
+		d, _, err := k.GetIntegerValue(name)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatBool(d != 0)), nil
+	case reflect.Int, reflect.Int64:
+		if d, _, err := k.GetIntegerValue(name); err == nil {
+			return json.RawMessage(strconv.FormatInt(int64(d), 10)), nil
+		} else if !errors.Is(err, registry.ErrUnexpectedType) {
+			return nil, err
+		}
+		// Group Policy preferences often push numbers as strings.
+		s, _, err := k.GetStringValue(name)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatInt(n, 10)), nil
+	case reflect.String:
+		s, _, err := k.GetStringValue(name)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+	default: // floats, lists and maps are stored as their JSON text
+		s, _, err := k.GetStringValue(name)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("%q is not valid JSON", s)
+		}
+		return json.RawMessage(s), nil
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+
+	"wallpaper-changer/internal/logging"
+)
+
+const remoteMaxBytes = 1 << 20
+
+// Merge returns base with every non-zero field of override applied.
+// Zero values (empty strings, 0, false, nil) in override never replace base,
+// so a remote config can only set values, not clear them.
+func Merge(base, override Config) Config {
+	out := base
+	dst := reflect.ValueOf(&out).Elem()
+	src := reflect.ValueOf(override)
+	for i := 0; i < src.NumField(); i++ {
+		if f := src.Field(i); !f.IsZero() {
+			dst.Field(i).Set(f)
+		}
+	}
+	return out
+}
+
+// ParseOverride decodes a remote config without defaults, so unset fields stay
+// zero and don't override the local config.
+func ParseOverride(data []byte) (Config, []Problem, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, nil, err
+	}
+	lines, err := fieldLines(data)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	problems := unknownFields(lines, false)
+	sortProblems(problems)
+	// The remote config can't redirect itself.
+	cfg.RemoteConfigURL = ""
+	cfg.RemoteConfigPollIntervalMinutes = 0
+	return cfg, problems, nil
+}
+
+// PollRemote fetches the local config's remote_config_url on its interval
+// and merges it over the local config. Errors keep the last good remote config.
+func PollRemote(ctx context.Context, client *http.Client, live *Live, onReprocess func()) {
+	var last []byte
+	for {
+		cfg := live.Local()
+		if cfg.RemoteConfigURL == "" {
+			if last != nil {
+				last = nil
+				if live.SetRemote(nil) {
+					onReprocess()
+				}
+			}
+		} else if body, err := fetchRemote(ctx, client, cfg.RemoteConfigURL); err != nil {
+			logging.Warnf("remote config", "fetch failed: %v", err)
+		} else if !bytes.Equal(body, last) {
+			remote, problems, err := ParseOverride(body)
+			if err != nil {
+				fmt.Println("remote config invalid, ignoring:", err)
+			} else {
+				for _, p := range problems {
+					fmt.Println("remote config warning:", p)
+				}
+				last = body
+				if live.SetRemote(&remote) {
+					onReprocess()
+				}
+			}
+		}
+
+		if !waitRemotePoll(ctx, live, cfg) {
+			return
+		}
+	}
+}
+
+// waitRemotePoll sleeps for the poll interval, returning early when the local
+// remote-config settings change. It returns false when ctx is done.
+func waitRemotePoll(ctx context.Context, live *Live, cfg Config) bool {
+	timer := time.NewTimer(time.Duration(cfg.RemoteConfigPollIntervalMinutes) * time.Minute)
+	defer timer.Stop()
+	for {
+		changed := live.Changed()
+		select {
+		case <-timer.C:
+			return true
+		case <-changed:
+			now := live.Local()
+			if now.RemoteConfigURL != cfg.RemoteConfigURL ||
+				now.RemoteConfigPollIntervalMinutes != cfg.RemoteConfigPollIntervalMinutes {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func fetchRemote(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, remoteMaxBytes))
+}
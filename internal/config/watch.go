@@ -0,0 +1,42 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const pollInterval = 5 * time.Second
+
+// Watch polls path and applies changes to live through Load, the same
+// validation used at startup. onReprocess runs when processing settings changed.
+func Watch(ctx context.Context, path string, live *Live, onReprocess func()) {
+	var lastMod time.Time
+	if fi, err := os.Stat(path); err == nil {
+		lastMod = fi.ModTime()
+	}
+	for {
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return
+		}
+		fi, err := os.Stat(path)
+		if err != nil || !fi.ModTime().After(lastMod) {
+			continue
+		}
+		lastMod = fi.ModTime()
+		cfg, problems, err := Load(path)
+		if err != nil {
+			fmt.Println("config reload failed, keeping previous config:", err)
+			continue
+		}
+		for _, p := range problems {
+			fmt.Println("config warning:", p)
+		}
+		if live.SetLocal(cfg) {
+			onReprocess()
+		}
+	}
+}
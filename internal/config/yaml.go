@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML config files. When one exists it is used instead of config.json.
+const (
+	YAMLFileName = "config.yaml"
+	ymlFileName  = "config.yml"
+)
+
+// Formats accepted by "config init --format".
+var Formats = []string{"json", "yaml"}
+
+// FilePath returns the config file in appDir: config.yaml or config.yml if
+// present, config.json otherwise. Keeping a JSON file next to a YAML one only
+// draws a warning.
+func FilePath(appDir string) string {
+	jsonPath := filepath.Join(appDir, FileName)
+	for _, name := range []string{YAMLFileName, ymlFileName} {
+		path := filepath.Join(appDir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if _, err := os.Stat(jsonPath); err == nil {
+			fmt.Printf("config warning: both %s and %s exist, using %s\n", name, FileName, name)
+		}
+		return path
+	}
+	return jsonPath
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ParseYAML is Parse for YAML: the same keys, defaults, validation and
+// line-numbered problems.
+func ParseYAML(data []byte) (Config, []Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Default(), nil, err
+	}
+	cfg := Default()
+	if len(doc.Content) == 0 { // empty file
+		return cfg, Validate(&cfg), nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return Default(), nil, fmt.Errorf("line %d: expected a mapping of settings", root.Line)
+	}
+	lines := map[string]int{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		lines[root.Content[i].Value] = root.Content[i].Line
+	}
+
+	// Decoding through JSON keeps the json struct tags the single source of
+	// field names for both formats.
+	var generic any
+	if err := root.Decode(&generic); err != nil {
+		return Default(), nil, err
+	}
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return Default(), nil, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return Default(), nil, fmt.Errorf("line %d: %q: expected %s, got %s",
+				lines[te.Field], te.Field, te.Type, te.Value)
+		}
+		return Default(), nil, err
+	}
+
+	problems := unknownFields(lines, true)
+	for _, p := range Validate(&cfg) {
+		p.Line = lines[p.Field]
+		problems = append(problems, p)
+	}
+	sortProblems(problems)
+	return cfg, problems, nil
+}
+
+// marshalYAML renders cfg with the same keys and order as the JSON file.
+func marshalYAML(cfg Config) ([]byte, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil { // JSON is valid YAML
+		return nil, err
+	}
+	blockStyle(&doc)
+	return yaml.Marshal(&doc)
+}
+
+// blockStyle drops the flow style and quoting the nodes got from JSON.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
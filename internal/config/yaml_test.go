@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// equivalent holds the same settings written as JSON and as YAML, comments
+// and all.
+var equivalent = []struct {
+	name       string
+	json, yaml string
+}{
+	{"empty", `{}`, ``},
+	{"scalars",
+		`{"change_time": "07:30", "source": "wallscloud", "startup_delay_seconds": 20, "static_map_lat": 55.75}`,
+		"# morning change\nchange_time: \"07:30\"\nsource: wallscloud\nstartup_delay_seconds: 20\nstatic_map_lat: 55.75\n"},
+	{"lists and maps",
+		`{"exclude_keywords": ["car", "клоун"], "source_weights": {"clock": 1, "starfield": 2.5},
+		  "notifications": {"success": "never", "failure": "always"}}`,
+		"exclude_keywords:\n  - car\n  - клоун\nsource_weights:\n  clock: 1\n  starfield: 2.5\n" +
+			"notifications:\n  success: never # too chatty\n  failure: always\n"},
+	{"invalid value",
+		`{"change_time": "25:00"}`,
+		"change_time: \"25:00\"\n"},
+}
+
+func TestParseYAMLMatchesJSON(t *testing.T) {
+	for _, tt := range equivalent {
+		t.Run(tt.name, func(t *testing.T) {
+			fromJSON, jp, err := Parse([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			fromYAML, yp, err := ParseYAML([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("ParseYAML: %v", err)
+			}
+			if !reflect.DeepEqual(fromJSON, fromYAML) {
+				t.Errorf("configs differ:\njson %+v\nyaml %+v", fromJSON, fromYAML)
+			}
+			if len(jp) != len(yp) {
+				t.Errorf("problems differ: json %v, yaml %v", jp, yp)
+			}
+		})
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	cfg := Default()
+	cfg.ChangeTime = "06:45"
+	cfg.ExcludeKeywords = []string{"gun", "машина"}
+	cfg.SourceWeights = map[string]float64{"clock": 3, "voronoi": 0.5}
+	cfg.Notifications = map[string]string{"success": "never"}
+	for _, name := range []string{"config.json", "config.yaml", "config.yml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := Save(path, cfg); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			got, problems, err := Load(path)
+			if err != nil || len(problems) != 0 {
+				t.Fatalf("Load = %v, %v", problems, err)
+			}
+			if !reflect.DeepEqual(got, cfg) {
+				t.Errorf("round trip changed the config:\nsaved  %+v\nloaded %+v", cfg, got)
+			}
+		})
+	}
+}
+
+func TestParseYAMLProblems(t *testing.T) {
+	data := "source: clock\n\n# typo below\nchnage_time: \"07:30\"\nchange_time: 7am\n"
+	_, problems, err := ParseYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Problem{{Line: 4, Field: "chnage_time"}, {Line: 5, Field: "change_time"}}
+	if len(problems) != len(want) {
+		t.Fatalf("problems = %v, want %v", problems, want)
+	}
+	for i, p := range problems {
+		if p.Line != want[i].Line || p.Field != want[i].Field {
+			t.Errorf("problem %d = %v, want %s on line %d", i, p, want[i].Field, want[i].Line)
+		}
+	}
+	for _, bad := range []string{"- a\n- b\n", "change_time: [\n"} {
+		if _, _, err := ParseYAML([]byte(bad)); err == nil {
+			t.Errorf("ParseYAML(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFilePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"none", nil, FileName},
+		{"json", []string{FileName}, FileName},
+		{"yaml", []string{YAMLFileName}, YAMLFileName},
+		{"yml", []string{ymlFileName}, ymlFileName},
+		{"yaml preferred", []string{FileName, YAMLFileName}, YAMLFileName},
+		{"yaml before yml", []string{ymlFileName, YAMLFileName}, YAMLFileName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := FilePath(dir); got != filepath.Join(dir, tt.want) {
+				t.Errorf("FilePath = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package display lists the connected monitors and their resolutions.
+package display
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	monitorInfoFPrimary       = 1          // MONITORINFOF_PRIMARY
+	eddGetDeviceInterfaceName = 1          // EDD_GET_DEVICE_INTERFACE_NAME
+	enumCurrentSettings       = 0xFFFFFFFF // ENUM_CURRENT_SETTINGS
+)
+
+var (
+	user32                  = syscall.NewLazyDLL("user32.dll")
+	procEnumDisplayMonitors = user32.NewProc("EnumDisplayMonitors")
+	procGetMonitorInfo      = user32.NewProc("GetMonitorInfoW")
+	procEnumDisplayDevices  = user32.NewProc("EnumDisplayDevicesW")
+	procEnumDisplaySettings = user32.NewProc("EnumDisplaySettingsW")
+)
+
+// Monitor is one attached display.
+type Monitor struct {
+	// ID is the monitor's device interface path, stable across reboots and
+	// reordering; it is what config "target_monitor" stores.
+	ID string
+	// Name is the monitor's friendly name, e.g. "Generic PnP Monitor".
+	Name string
+	// Device is the GDI device name, e.g. `\\.\DISPLAY1`; it can change.
+	Device  string
+	Width   int // physical pixels of the current mode
+	Height  int
+	Primary bool
+}
+
+// Label is the text shown for m in menus.
+func (m Monitor) Label() string {
+	s := fmt.Sprintf("%s (%dx%d)", m.Name, m.Width, m.Height)
+	if m.Primary {
+		s += " - primary"
+	}
+	return s
+}
+
+type rect struct{ Left, Top, Right, Bottom int32 }
+
+type monitorInfoEx struct {
+	CbSize    uint32
+	RcMonitor rect
+	RcWork    rect
+	DwFlags   uint32
+	SzDevice  [32]uint16
+}
+
+type displayDevice struct {
+	Cb           uint32
+	DeviceName   [32]uint16
+	DeviceString [128]uint16
+	StateFlags   uint32
+	DeviceID     [128]uint16
+	DeviceKey    [128]uint16
+}
+
+// devMode is DEVMODEW with the display half of its unions.
+type devMode struct {
+	DmDeviceName       [32]uint16
+	DmSpecVersion      uint16
+	DmDriverVersion    uint16
+	DmSize             uint16
+	DmDriverExtra      uint16
+	DmFields           uint32
+	DmPositionX        int32
+	DmPositionY        int32
+	DmDisplayOrient    uint32
+	DmDisplayFixedOut  uint32
+	DmColor            int16
+	DmDuplex           int16
+	DmYResolution      int16
+	DmTTOption         int16
+	DmCollate          int16
+	DmFormName         [32]uint16
+	DmLogPixels        uint16
+	DmBitsPerPel       uint32
+	DmPelsWidth        uint32
+	DmPelsHeight       uint32
+	DmDisplayFlags     uint32
+	DmDisplayFrequency uint32
+	DmICMMethod        uint32
+	DmICMIntent        uint32
+	DmMediaType        uint32
+	DmDitherType       uint32
+	DmReserved1        uint32
+	DmReserved2        uint32
+	DmPanningWidth     uint32
+	DmPanningHeight    uint32
+}
+
+var (
+	// enumMu guards enumResult; the callback is created once because Windows
+	// callbacks made by syscall.NewCallback are never freed.
+	enumMu       sync.Mutex
+	enumResult   []uintptr
+	enumCallback = syscall.NewCallback(func(hmon, hdc, lprc, lparam uintptr) uintptr {
+		enumResult = append(enumResult, hmon)
+		return 1
+	})
+)
+
+// List returns the attached monitors in enumeration order.
+func List() ([]Monitor, error) {
+	enumMu.Lock()
+	enumResult = nil
+	ret, _, callErr := procEnumDisplayMonitors.Call(0, 0, enumCallback, 0)
+	handles := enumResult
+	enumMu.Unlock()
+	if ret == 0 {
+		return nil, fmt.Errorf("EnumDisplayMonitors failed: %v", callErr)
+	}
+
+	var out []Monitor
+	for _, h := range handles {
+		m, err := describe(h)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no monitors found")
+	}
+	return out, nil
+}
+
+func describe(hmon uintptr) (Monitor, error) {
+	mi := monitorInfoEx{CbSize: uint32(unsafe.Sizeof(monitorInfoEx{}))}
+	if ret, _, callErr := procGetMonitorInfo.Call(hmon, uintptr(unsafe.Pointer(&mi))); ret == 0 {
+		return Monitor{}, fmt.Errorf("GetMonitorInfoW failed: %v", callErr)
+	}
+	m := Monitor{
+		Device:  syscall.UTF16ToString(mi.SzDevice[:]),
+		Width:   int(mi.RcMonitor.Right - mi.RcMonitor.Left),
+		Height:  int(mi.RcMonitor.Bottom - mi.RcMonitor.Top),
+		Primary: mi.DwFlags&monitorInfoFPrimary != 0,
+	}
+	m.ID, m.Name = m.Device, m.Device
+
+	// The rectangle is DPI-virtualized for unaware processes; the current
+	// display mode has the real pixel size.
+	dm := devMode{DmSize: uint16(unsafe.Sizeof(devMode{}))}
+	if ret, _, _ := procEnumDisplaySettings.Call(uintptr(unsafe.Pointer(&mi.SzDevice[0])),
+		enumCurrentSettings, uintptr(unsafe.Pointer(&dm))); ret != 0 && dm.DmPelsWidth > 0 {
+		m.Width, m.Height = int(dm.DmPelsWidth), int(dm.DmPelsHeight)
+	}
+
+	dd := displayDevice{Cb: uint32(unsafe.Sizeof(displayDevice{}))}
+	if ret, _, _ := procEnumDisplayDevices.Call(uintptr(unsafe.Pointer(&mi.SzDevice[0])), 0,
+		uintptr(unsafe.Pointer(&dd)), eddGetDeviceInterfaceName); ret != 0 {
+		if id := syscall.UTF16ToString(dd.DeviceID[:]); id != "" {
+			m.ID = id
+		}
+		if name := syscall.UTF16ToString(dd.DeviceString[:]); name != "" {
+			m.Name = name
+		}
+	}
+	return m, nil
+}
+
+// Select returns the monitor with id, or the primary one when id is empty or
+// no longer attached.
+func Select(monitors []Monitor, id string) (Monitor, bool) {
+	var primary Monitor
+	found := false
+	for _, m := range monitors {
+		if id != "" && m.ID == id {
+			return m, true
+		}
+		if m.Primary || !found {
+			primary, found = m, true
+		}
+	}
+	return primary, found
+}
+
+// Target returns the monitor config "target_monitor" names, falling back to
+// the primary monitor.
+func Target(id string) (Monitor, error) {
+	monitors, err := List()
+	if err != nil {
+		return Monitor{}, err
+	}
+	m, _ := Select(monitors, id)
+	return m, nil
+}
@@ -0,0 +1,31 @@
+package display
+
+import "testing"
+
+func TestSelect(t *testing.T) {
+	left := Monitor{ID: "left", Width: 1920, Height: 1080}
+	main := Monitor{ID: "main", Width: 3440, Height: 1440, Primary: true}
+	side := Monitor{ID: "side", Width: 1080, Height: 1920}
+	all := []Monitor{left, main, side}
+	tests := []struct {
+		name     string
+		monitors []Monitor
+		id       string
+		want     Monitor
+		found    bool
+	}{
+		{"by id", all, "side", side, true},
+		{"empty id is the primary", all, "", main, true},
+		{"detached id is the primary", all, "gone", main, true},
+		{"no primary flagged", []Monitor{left, side}, "", left, true},
+		{"none attached", nil, "main", Monitor{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := Select(tt.monitors, tt.id)
+			if got != tt.want || found != tt.found {
+				t.Errorf("Select = %v, %v; want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
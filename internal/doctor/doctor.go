@@ -0,0 +1,256 @@
+// Package doctor checks the environment the tray depends on and explains how
+// to fix what is broken.
+package doctor
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/source"
+)
+
+const (
+	// connectivityURL is the endpoint Windows itself probes for internet access.
+	connectivityURL = "http://www.msftconnecttest.com/connecttest.txt"
+	runRegistryPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+	checkTimeout    = 30 * time.Second
+	// sourceTimeout is longer: sources may render or sign in first.
+	sourceTimeout = 2 * time.Minute
+)
+
+//go:embed testpattern.png
+var testPattern []byte
+
+// Check is one diagnostic.
+type Check struct {
+	Name string
+	// Hint tells the user how to fix a failure.
+	Hint string
+	// Startup checks are cheap and offline; they also run when the tray starts.
+	Startup bool
+	Run     func(ctx context.Context) error
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Check
+	Err error
+}
+
+// Env is everything the checks touch, so each can be exercised with fakes.
+type Env struct {
+	AppDir     string
+	ConfigPath string
+	Client     *fetch.Client
+	// NewSource builds the configured source.
+	NewSource func() (source.WallpaperSource, error)
+	// CurrentWallpaper reads the wallpaper path from Windows.
+	CurrentWallpaper func() (string, error)
+	// AutostartCommand returns the Run-key command line, if there is one.
+	AutostartCommand func() (cmd string, ok bool, err error)
+	Executable       func() (string, error)
+}
+
+// Checks returns every diagnostic over env, in the order they should run.
+func Checks(env Env) []Check {
+	return []Check{
+		{
+			Name:    "data dir writable",
+			Hint:    "check that %APPDATA% exists and is not read-only or out of space",
+			Startup: true,
+			Run:     func(context.Context) error { return checkWritable(env.AppDir) },
+		},
+		{
+			Name:    "config valid",
+			Hint:    `run "go-wallpaper-tray config validate" and fix the reported lines`,
+			Startup: true,
+			Run:     func(context.Context) error { return checkConfig(env.ConfigPath) },
+		},
+		{
+			Name:    "image decoding",
+			Hint:    "the build is missing image decoders; reinstall the program",
+			Startup: true,
+			Run:     func(context.Context) error { return checkDecode(testPattern) },
+		},
+		{
+			Name:    "wallpaper API",
+			Hint:    "another program or a group policy may be locking the wallpaper",
+			Startup: true,
+			Run:     func(context.Context) error { return checkSetter(env.CurrentWallpaper) },
+		},
+		{
+			Name:    "autostart entry",
+			Hint:    `re-create the GoWallpaperTray value under HKCU\` + runRegistryPath + " pointing at this exe",
+			Startup: true,
+			Run:     func(context.Context) error { return checkAutostart(env.AutostartCommand, env.Executable) },
+		},
+		{
+			Name: "network reachable",
+			Hint: "check the internet connection, proxy and firewall",
+			Run:  func(ctx context.Context) error { return checkNetwork(ctx, env.Client) },
+		},
+		{
+			Name: "active source responding",
+			Hint: `check the source settings in config.json, or switch "source" to another one`,
+			Run:  func(ctx context.Context) error { return checkSource(ctx, env.NewSource) },
+		},
+	}
+}
+
+// Run executes checks in order; with startupOnly it skips those that aren't
+// Startup checks.
+func Run(ctx context.Context, checks []Check, startupOnly bool) []Result {
+	var out []Result
+	for _, c := range checks {
+		if startupOnly && !c.Startup {
+			continue
+		}
+		timeout := checkTimeout
+		if !c.Startup {
+			timeout = sourceTimeout
+		}
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		out = append(out, Result{Check: c, Err: c.Run(cctx)})
+		cancel()
+	}
+	return out
+}
+
+// Failed returns the failed results.
+func Failed(results []Result) []Result {
+	var out []Result
+	for _, r := range results {
+		if r.Err != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func checkWritable(dir string) error {
+	if dir == "" {
+		return errors.New("app dir could not be resolved")
+	}
+	f, err := os.CreateTemp(dir, "doctor_*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, werr := f.WriteString("ok")
+	cerr := f.Close()
+	os.Remove(name)
+	return errors.Join(werr, cerr)
+}
+
+func checkConfig(path string) error {
+	_, problems, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		msgs := make([]string, len(problems))
+		for i, p := range problems {
+			msgs[i] = p.String()
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func checkDecode(data []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 16 {
+		return fmt.Errorf("test pattern decoded to %dx%d, want 16x16", b.Dx(), b.Dy())
+	}
+	return nil
+}
+
+// checkSetter makes a read-only call into the same API the setter writes with.
+func checkSetter(current func() (string, error)) error {
+	path, err := current()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return nil // solid color desktop
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("current wallpaper %s is missing: %w", path, err)
+	}
+	return nil
+}
+
+func checkAutostart(command func() (string, bool, error), executable func() (string, error)) error {
+	cmd, ok, err := command()
+	if err != nil || !ok {
+		return err // no entry is fine: autostart is optional
+	}
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+	target := strings.Trim(strings.TrimSpace(cmd), `"`)
+	if i := strings.Index(strings.ToLower(target), ".exe"); i >= 0 {
+		target = target[:i+len(".exe")]
+	}
+	if _, err := os.Stat(target); err != nil {
+		return fmt.Errorf("autostart points at missing %s", target)
+	}
+	if !strings.EqualFold(filepath.Clean(target), filepath.Clean(exe)) {
+		return fmt.Errorf("autostart runs %s, not this exe (%s)", target, exe)
+	}
+	return nil
+}
+
+func checkNetwork(ctx context.Context, client *fetch.Client) error {
+	resp, err := client.Get(ctx, connectivityURL)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func checkSource(ctx context.Context, newSource func() (source.WallpaperSource, error)) error {
+	src, err := newSource()
+	if err != nil {
+		return err
+	}
+	c, err := src.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src.Name(), err)
+	}
+	return os.Remove(c.Path)
+}
+
+// RunKeyCommand reads the autostart command registered under name.
+func RunKeyCommand(name string) (string, bool, error) {
+	k, err := registry.OpenKey(registry.CURRENT_USER, runRegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return "", false, err
+	}
+	defer k.Close()
+	v, _, err := k.GetStringValue(name)
+	if errors.Is(err, registry.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
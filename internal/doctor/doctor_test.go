@@ -0,0 +1,161 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/source"
+)
+
+// roundTrip answers every request with status, or fails with err.
+type roundTrip struct {
+	status int
+	err    error
+}
+
+func (r roundTrip) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &http.Response{StatusCode: r.status, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+}
+
+// fakeSource writes an empty candidate, or fails with err.
+type fakeSource struct {
+	dir string
+	err error
+}
+
+func (s fakeSource) Name() string { return "fake" }
+
+func (s fakeSource) Fetch(context.Context) (*source.Candidate, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	path := filepath.Join(s.dir, "candidate.png")
+	return &source.Candidate{Path: path}, os.WriteFile(path, nil, 0o644)
+}
+
+// healthy returns an Env every check passes on.
+func healthy(t *testing.T) Env {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "go-wallpaper-tray.exe")
+	wall := filepath.Join(dir, "wallpaper.bmp")
+	cfg := filepath.Join(dir, "config.json")
+	for _, f := range []string{exe, wall} {
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(cfg, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Env{
+		AppDir:           dir,
+		ConfigPath:       cfg,
+		Client:           fetch.New(&http.Client{Transport: roundTrip{status: http.StatusOK}}, "test"),
+		NewSource:        func() (source.WallpaperSource, error) { return fakeSource{dir: dir}, nil },
+		CurrentWallpaper: func() (string, error) { return wall, nil },
+		AutostartCommand: func() (string, bool, error) { return `"` + exe + `" --autostart`, true, nil },
+		Executable:       func() (string, error) { return exe, nil },
+	}
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		check    string
+		breakEnv func(t *testing.T, env *Env)
+	}{
+		{"data dir writable", func(t *testing.T, env *Env) { env.AppDir = filepath.Join(env.AppDir, "missing") }},
+		{"data dir writable", func(t *testing.T, env *Env) { env.AppDir = "" }},
+		{"config valid", func(t *testing.T, env *Env) {
+			os.WriteFile(env.ConfigPath, []byte("{\n  \"chnage_time\": \"09:00\"\n}"), 0o644)
+		}},
+		{"config valid", func(t *testing.T, env *Env) { os.WriteFile(env.ConfigPath, []byte("{"), 0o644) }},
+		{"wallpaper API", func(t *testing.T, env *Env) {
+			env.CurrentWallpaper = func() (string, error) { return "", errors.New("SystemParametersInfoW failed") }
+		}},
+		{"wallpaper API", func(t *testing.T, env *Env) {
+			env.CurrentWallpaper = func() (string, error) { return filepath.Join(env.AppDir, "gone.bmp"), nil }
+		}},
+		{"autostart entry", func(t *testing.T, env *Env) {
+			env.AutostartCommand = func() (string, bool, error) { return `C:\old\go-wallpaper-tray.exe`, true, nil }
+		}},
+		{"autostart entry", func(t *testing.T, env *Env) {
+			other := filepath.Join(env.AppDir, "other.exe")
+			os.WriteFile(other, nil, 0o644)
+			env.AutostartCommand = func() (string, bool, error) { return other, true, nil }
+		}},
+		{"network reachable", func(t *testing.T, env *Env) {
+			env.Client = fetch.New(&http.Client{Transport: roundTrip{err: errors.New("no route to host")}}, "test")
+		}},
+		{"active source responding", func(t *testing.T, env *Env) {
+			env.NewSource = func() (source.WallpaperSource, error) { return fakeSource{err: errors.New("503")}, nil }
+		}},
+		{"active source responding", func(t *testing.T, env *Env) {
+			env.NewSource = func() (source.WallpaperSource, error) { return nil, errors.New("unknown source") }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.check, func(t *testing.T) {
+			env := healthy(t)
+			for _, r := range Run(context.Background(), Checks(env), false) {
+				if r.Err != nil {
+					t.Fatalf("healthy %q failed: %v", r.Name, r.Err)
+				}
+			}
+			tt.breakEnv(t, &env)
+			for _, r := range Run(context.Background(), Checks(env), false) {
+				if failed := r.Err != nil; failed != (r.Name == tt.check) {
+					t.Errorf("%q err = %v", r.Name, r.Err)
+				}
+				if r.Err != nil && r.Hint == "" {
+					t.Errorf("%q failed without a hint", r.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionalChecksPass(t *testing.T) {
+	env := healthy(t)
+	env.CurrentWallpaper = func() (string, error) { return "", nil }
+	env.AutostartCommand = func() (string, bool, error) { return "", false, nil }
+	if failed := Failed(Run(context.Background(), Checks(env), false)); len(failed) != 0 {
+		t.Errorf("failed %v with a solid color desktop and no autostart", failed)
+	}
+}
+
+func TestRunStartupOnly(t *testing.T) {
+	env := healthy(t)
+	env.NewSource = func() (source.WallpaperSource, error) {
+		t.Error("startup run fetched from the source")
+		return nil, errors.New("unexpected")
+	}
+	env.Client = fetch.New(&http.Client{Transport: roundTrip{err: errors.New("offline")}}, "test")
+	results := Run(context.Background(), Checks(env), true)
+	for _, r := range results {
+		if !r.Startup {
+			t.Errorf("startup run included %q", r.Name)
+		}
+	}
+	if len(results) != 5 || len(Failed(results)) != 0 {
+		t.Errorf("startup run = %d results, %v failed", len(results), Failed(results))
+	}
+}
+
+func TestCheckDecode(t *testing.T) {
+	if err := checkDecode(testPattern); err != nil {
+		t.Errorf("bundled test pattern: %v", err)
+	}
+	if err := checkDecode(testPattern[:len(testPattern)/2]); err == nil {
+		t.Error("truncated test pattern decoded")
+	}
+}
@@ -0,0 +1,205 @@
+// Package favorites keeps the wallpapers the user marked as favorites, each
+// with a JSON sidecar describing it, and mirrors them into the Pictures
+// folder.
+package favorites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// DirName is the favorites dir inside the app dir.
+	DirName = "favorites"
+	// SidecarExt is appended to a favorite's file name for its sidecar.
+	SidecarExt = ".json"
+
+	fileTimeStamp = "20060102_150405"
+)
+
+// formatExts maps image.DecodeConfig format names to file extensions.
+var formatExts = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+	"bmp":  ".bmp",
+	"webp": ".webp",
+}
+
+// Favorites is the favorites dir. Its sidecars are the index, so files
+// copied in or out along with theirs stay consistent.
+type Favorites struct {
+	dir string
+}
+
+// Open returns the favorites kept in dir, which is created on the first Add.
+func Open(dir string) *Favorites {
+	return &Favorites{dir: dir}
+}
+
+// Path returns where e's file is stored.
+func (f *Favorites) Path(e history.Entry) string { return filepath.Join(f.dir, e.File) }
+
+// List returns every favorite, oldest first. Sidecars that can't be read
+// are skipped.
+func (f *Favorites) List() ([]history.Entry, error) {
+	des, err := os.ReadDir(f.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []history.Entry
+	for _, de := range des {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), SidecarExt) {
+			continue
+		}
+		var e history.Entry
+		b, err := os.ReadFile(filepath.Join(f.dir, de.Name()))
+		if err == nil {
+			err = json.Unmarshal(b, &e)
+		}
+		if err != nil || e.File != strings.TrimSuffix(de.Name(), SidecarExt) {
+			fmt.Printf("favorites: skipping sidecar %s: %v\n", de.Name(), err)
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Added.Before(out[j].Added) })
+	return out, nil
+}
+
+// Find returns the favorite made of the wallpaper e describes, if any; a
+// wallpaper is known by its source and when it was set.
+func (f *Favorites) Find(e history.Entry) (history.Entry, bool) {
+	all, err := f.List()
+	if err != nil {
+		return history.Entry{}, false
+	}
+	for _, x := range all {
+		if x.Source == e.Source && x.Added.Equal(e.Added) {
+			return x, true
+		}
+	}
+	return history.Entry{}, false
+}
+
+// Add copies the image at path into the favorites as e, whose File is
+// filled in from e.Added, e.Source and the image format, and writes its
+// sidecar.
+func (f *Favorites) Add(path string, e history.Entry) (history.Entry, error) {
+	ext, err := imageExt(path)
+	if err != nil {
+		return e, err
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return e, err
+	}
+	e.File = e.Added.Format(fileTimeStamp) + "_" + e.Source + ext
+	if err := copyFile(path, f.Path(e)); err != nil {
+		return e, err
+	}
+	if err := f.writeSidecar(e); err != nil {
+		os.Remove(f.Path(e))
+		return e, err
+	}
+	return e, nil
+}
+
+// Replace swaps the image of favorite e for the one at path, downloaded at
+// size (see history.Entry.Size), and rewrites its sidecar. The file takes
+// the extension of the new image's format, so it is renamed when that
+// changed.
+func (f *Favorites) Replace(e history.Entry, path, size string) (history.Entry, error) {
+	ext, err := imageExt(path)
+	if err != nil {
+		return e, err
+	}
+	old := e.File
+	e.File = strings.TrimSuffix(old, filepath.Ext(old)) + ext
+	e.Size = size
+	tmp := f.Path(e) + ".tmp"
+	if err := copyFile(path, tmp); err != nil {
+		os.Remove(tmp)
+		return e, err
+	}
+	if err := os.Rename(tmp, f.Path(e)); err != nil {
+		os.Remove(tmp)
+		return e, err
+	}
+	if err := f.writeSidecar(e); err != nil {
+		return e, err
+	}
+	if e.File != old {
+		if err := f.Remove(old); err != nil {
+			fmt.Printf("favorites: removing replaced %s: %v\n", old, err)
+		}
+	}
+	return e, nil
+}
+
+func (f *Favorites) writeSidecar(e history.Entry) error {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(f.Path(e)+SidecarExt, b)
+}
+
+// Remove deletes the favorite stored as file along with its sidecar.
+func (f *Favorites) Remove(file string) error {
+	path := filepath.Join(f.dir, file)
+	if err := os.Remove(path + SidecarExt); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// imageExt names the extension of the image at path by its content, since
+// the app keeps the current original without one.
+func imageExt(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	_, format, err := image.DecodeConfig(in)
+	if err != nil {
+		return "", err
+	}
+	if ext, ok := formatExts[format]; ok {
+		return ext, nil
+	}
+	return "." + format, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
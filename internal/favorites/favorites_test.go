@@ -0,0 +1,72 @@
+package favorites
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/history"
+)
+
+// writePNG saves a small solid image to path, which has no extension like
+// the app's current original.
+func writePNG(t *testing.T, path string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	img.Set(0, 0, color.White)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddFindRemove(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "current_original")
+	writePNG(t, src)
+	f := Open(filepath.Join(t.TempDir(), DirName))
+	if all, err := f.List(); err != nil || len(all) != 0 {
+		t.Fatalf("List of a missing dir = %v, %v", all, err)
+	}
+
+	added := time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+	e, err := f.Add(src, history.Entry{Source: "wallscloud", Title: "Lake", Added: added})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if want := "20260314_090000_wallscloud.png"; e.File != want {
+		t.Errorf("File = %q, want %q", e.File, want)
+	}
+	if got, ok := f.Find(history.Entry{Source: "wallscloud", Added: added}); !ok || got.Title != "Lake" {
+		t.Errorf("Find = %v, %v", got, ok)
+	}
+	if _, ok := f.Find(history.Entry{Source: "clock", Added: added}); ok {
+		t.Error("Find matched another source")
+	}
+
+	if err := f.Remove(e.File); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if all, _ := f.List(); len(all) != 0 {
+		t.Errorf("List after Remove = %v", all)
+	}
+}
+
+func TestListSkipsBadSidecars(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.png"+SidecarExt), []byte("{"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.png"+SidecarExt), []byte(`{"file": "other.png"}`), 0o644)
+	if all, err := Open(dir).List(); err != nil || len(all) != 0 {
+		t.Errorf("List = %v, %v; want the bad sidecars skipped", all, err)
+	}
+}
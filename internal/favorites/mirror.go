@@ -0,0 +1,202 @@
+package favorites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// manifestFileName, kept in the favorites dir, lists the files Mirror
+	// created, so it never deletes one the user put in the mirror.
+	manifestFileName = "mirror_manifest.json"
+	// Files in a OneDrive-backed Pictures folder are locked for a moment
+	// while they upload; a copy or delete is retried this often, with the
+	// delay doubling from lockRetryDelay.
+	lockRetries    = 4
+	lockRetryDelay = 250 * time.Millisecond
+
+	errCloudFileInUse syscall.Errno = 362 // ERROR_CLOUD_FILE_IN_USE
+)
+
+// mirrorSubdir is the mirror folder inside Pictures.
+var mirrorSubdir = filepath.Join("Wallpapers", "GoWallpaperTray")
+
+// MirrorReport counts what a Mirror pass did.
+type MirrorReport struct {
+	Copied, Removed int
+	// Kept are files in the way that Mirror didn't create, left alone.
+	Kept   int
+	Failed int
+}
+
+func (r MirrorReport) String() string {
+	return fmt.Sprintf("%d copied, %d removed, %d kept, %d failed", r.Copied, r.Removed, r.Kept, r.Failed)
+}
+
+// MirrorDir returns the mirror folder in the user's Pictures folder,
+// wherever it is redirected to, such as OneDrive.
+func MirrorDir() (string, error) {
+	pictures, err := windows.KnownFolderPath(windows.FOLDERID_Pictures, windows.KF_FLAG_DEFAULT)
+	if err != nil {
+		return "", fmt.Errorf("locating the Pictures folder: %w", err)
+	}
+	return filepath.Join(pictures, mirrorSubdir), nil
+}
+
+// Mirror brings root in line with the favorites: each favorite and its
+// sidecar are copied to root\YYYY\MM by when the wallpaper was set, and
+// the files an earlier pass copied whose favorite is gone are deleted, as
+// are the month and year folders they leave empty. Files Mirror didn't
+// create, or that changed since it did, are never deleted or overwritten.
+// Paths past MAX_PATH, likely deep in a OneDrive folder, work because
+// package os adds the \\?\ prefix they need.
+func (f *Favorites) Mirror(root string) (MirrorReport, error) {
+	var r MirrorReport
+	all, err := f.List()
+	if err != nil {
+		return r, err
+	}
+	manifest := f.loadManifest()
+
+	// want maps the mirror's relative paths to the favorites files.
+	want := map[string]string{}
+	for _, e := range all {
+		dir := filepath.Join(e.Added.Format("2006"), e.Added.Format("01"))
+		want[filepath.Join(dir, e.File)] = f.Path(e)
+		want[filepath.Join(dir, e.File+SidecarExt)] = f.Path(e) + SidecarExt
+	}
+
+	for _, rel := range sortedKeys(want) {
+		src, dst := want[rel], filepath.Join(root, rel)
+		si, err := os.Stat(src)
+		if err != nil {
+			r.Failed++
+			fmt.Printf("favorites mirror: %s: %v\n", rel, err)
+			continue
+		}
+		di, err := os.Stat(dst)
+		switch size, ours := manifest[rel]; {
+		case err == nil && !ours:
+			r.Kept++
+			continue
+		case err == nil && di.Size() != size:
+			// Changed since it was copied: the user's now.
+			delete(manifest, rel)
+			r.Kept++
+			continue
+		case err == nil && size == si.Size():
+			continue
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			r.Failed++
+			fmt.Printf("favorites mirror: %s: %v\n", rel, err)
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(dst), 0o755)
+		if err == nil {
+			err = retryLocked(func() error { return copyFile(src, dst) })
+		}
+		if err != nil {
+			r.Failed++
+			fmt.Printf("favorites mirror: copying %s: %v\n", rel, err)
+			continue
+		}
+		manifest[rel] = si.Size()
+		r.Copied++
+	}
+
+	for _, rel := range sortedKeys(manifest) {
+		if _, ok := want[rel]; ok {
+			continue
+		}
+		dst := filepath.Join(root, rel)
+		di, err := os.Stat(dst)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			r.Failed++
+			fmt.Printf("favorites mirror: %s: %v\n", rel, err)
+			continue
+		case di.Size() != manifest[rel]:
+			r.Kept++
+		default:
+			if err := retryLocked(func() error { return os.Remove(dst) }); err != nil {
+				r.Failed++
+				fmt.Printf("favorites mirror: deleting %s: %v\n", rel, err)
+				continue
+			}
+			r.Removed++
+			// Fails, as it should, while anything else is in there.
+			month := filepath.Dir(dst)
+			if os.Remove(month) == nil {
+				os.Remove(filepath.Dir(month))
+			}
+		}
+		delete(manifest, rel)
+	}
+	return r, f.saveManifest(manifest)
+}
+
+// retryLocked runs op until it stops failing on a file another process
+// holds.
+func retryLocked(op func() error) error {
+	delay := lockRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt == lockRetries || !locked(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func locked(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION) ||
+		errors.Is(err, errCloudFileInUse)
+}
+
+// loadManifest returns the mirror's files Mirror created, by relative
+// path, with the size each had.
+func (f *Favorites) loadManifest() map[string]int64 {
+	manifest := map[string]int64{}
+	b, err := os.ReadFile(filepath.Join(f.dir, manifestFileName))
+	if err != nil {
+		return manifest
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		// Forgetting what was copied only means nothing gets deleted.
+		fmt.Println("favorites mirror: discarding unreadable manifest:", err)
+		return map[string]int64{}
+	}
+	return manifest
+}
+
+func (f *Favorites) saveManifest(manifest map[string]int64) error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(filepath.Join(f.dir, manifestFileName), b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
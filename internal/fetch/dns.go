@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// resolveTimeout bounds the pre-resolution of a source's host, so a resolver
+// stuck mid-VPN-reconnect fails fast instead of stalling the request.
+const resolveTimeout = 3 * time.Second
+
+// Resolve looks up host with a short timeout. Failures are *net.DNSError,
+// recognisable with DNSFailure.
+func Resolve(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	_, err := net.DefaultResolver.LookupHost(ctx, host)
+	var dnsErr *net.DNSError
+	if err != nil && !errors.As(err, &dnsErr) {
+		// Deadline errors from the context aren't DNSErrors; make them one.
+		err = &net.DNSError{Err: err.Error(), Name: host, IsTimeout: errors.Is(err, context.DeadlineExceeded)}
+	}
+	return err
+}
+
+// Proxied reports whether c's requests to host go through a proxy, from
+// its transport's Proxy or, for other transports, the environment. The
+// proxy resolves the host then, and a local lookup may fail by design, as
+// on networks only the proxy can reach out of.
+func (c *Client) Proxied(host string) bool {
+	proxy := http.ProxyFromEnvironment
+	switch t := c.HTTP.Transport.(type) {
+	case nil:
+		if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+			proxy = dt.Proxy
+		}
+	case *http.Transport:
+		proxy = t.Proxy
+	}
+	if proxy == nil {
+		return false
+	}
+	u, err := proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: host}})
+	return err == nil && u != nil
+}
+
+// DNSFailure describes err if it is a failed DNS lookup, such as "DNS
+// lookup of wallscloud.net timed out", and reports whether it is one.
+func DNSFailure(err error) (string, bool) {
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return "", false
+	}
+	switch {
+	case dnsErr.IsTimeout:
+		return fmt.Sprintf("DNS lookup of %s timed out", dnsErr.Name), true
+	case dnsErr.IsNotFound:
+		return fmt.Sprintf("DNS lookup of %s found no such host", dnsErr.Name), true
+	}
+	return fmt.Sprintf("DNS lookup of %s failed: %s", dnsErr.Name, dnsErr.Err), true
+}
+
+// Resolver names the resolver a DNS failure came from, for logs: the DNS
+// server Go queried, or the system resolver when Windows did the lookup.
+func Resolver(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.Server != "" {
+		return "DNS server " + dnsErr.Server
+	}
+	return "system resolver"
+}
+
+// Describe is DNSFailure's description for DNS errors and err's own text
+// otherwise.
+func Describe(err error) string {
+	if msg, ok := DNSFailure(err); ok {
+		return msg
+	}
+	return err.Error()
+}
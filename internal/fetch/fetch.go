@@ -0,0 +1,220 @@
+// Package fetch wraps the HTTP client every source downloads through.
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrCorrupt marks a download that arrived empty or short; callers retry it.
+var ErrCorrupt = errors.New("corrupted download")
+
+// ErrChallenge marks an anti-bot challenge page (Cloudflare) served instead
+// of the content. Retrying soon doesn't help.
+var ErrChallenge = errors.New("source temporarily protected by anti-bot checks")
+
+// ErrNotFound marks a 404 response.
+var ErrNotFound = errors.New("not found")
+
+// ErrRateLimited marks a 429 response: the host wants fewer requests.
+var ErrRateLimited = errors.New("rate limited")
+
+// sniffLen is how much of a download http.DetectContentType looks at.
+const sniffLen = 512
+
+// challengeSniffBytes is how much of a 403/503 body is searched for
+// challenge markers.
+const challengeSniffBytes = 64 << 10
+
+// challengeMarkers appear in Cloudflare challenge pages.
+var challengeMarkers = []string{"cf-chl", "cf_chl_opt", "challenge-platform", "<title>Just a moment...</title>"}
+
+// Client issues requests with a shared *http.Client and User-Agent.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+}
+
+// New returns a Client using hc, or http.DefaultClient when hc is nil.
+func New(hc *http.Client, userAgent string) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{HTTP: hc, UserAgent: userAgent}
+}
+
+// Do sends req with the client's User-Agent unless one is set.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	return c.HTTP.Do(req)
+}
+
+// Get fetches url and fails on any status other than 200 OK. The caller
+// closes the body.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if isChallenge(resp) {
+			return nil, fmt.Errorf("%w (%s)", ErrChallenge, resp.Status)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("bad status: %w (%s)", ErrNotFound, resp.Status)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, fmt.Errorf("bad status: %w (%s)", ErrRateLimited, resp.Status)
+		}
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// Check asks whether url still exists, with a HEAD request, or a GET for
+// servers that don't take HEAD, without downloading the body. It returns
+// ErrNotFound for 404 and 410 Gone, and any other failure as is.
+func (c *Client) Check(ctx context.Context, url string) error {
+	status, err := c.status(ctx, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.status(ctx, http.MethodGet, url)
+	}
+	switch {
+	case err != nil:
+		return err
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return fmt.Errorf("%w (%d)", ErrNotFound, status)
+	case status >= 400:
+		return fmt.Errorf("bad status: %d", status)
+	}
+	return nil
+}
+
+func (c *Client) status(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// isChallenge reports whether resp is a Cloudflare challenge rather than a
+// real error page.
+func isChallenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	if resp.Header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+	if !strings.EqualFold(resp.Header.Get("Server"), "cloudflare") {
+		return false
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, challengeSniffBytes))
+	for _, m := range challengeMarkers {
+		if bytes.Contains(b, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetJSON GETs url and decodes at most maxBytes of the response into v.
+func (c *Client) GetJSON(ctx context.Context, url string, maxBytes int64, v any) error {
+	resp, err := c.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(io.LimitReader(resp.Body, maxBytes)).Decode(v)
+}
+
+// DownloadToTemp saves url to a temp file and returns its path. Empty and
+// short bodies are rejected with ErrCorrupt.
+func (c *Client) DownloadToTemp(ctx context.Context, url string) (string, error) {
+	resp, err := c.Get(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	return SaveToTemp(resp)
+}
+
+// imageExts are the extensions of the image types sources serve. Files
+// keep theirs through the history, where Explorer goes by it.
+var imageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/bmp":  ".bmp",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageExt returns the extension for resp's Content-Type or, for servers
+// sending application/octet-stream and the like, for the type sniffed
+// from head, the start of the body. It is "" if neither is an image.
+func imageExt(resp *http.Response, head []byte) string {
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		if ext, ok := imageExts[mt]; ok {
+			return ext
+		}
+	}
+	return imageExts[http.DetectContentType(head)]
+}
+
+// SaveToTemp writes resp.Body to a temp file named with the image's
+// extension, checking it against Content-Length. It doesn't close the
+// body.
+func SaveToTemp(resp *http.Response) (string, error) {
+	body := bufio.NewReaderSize(resp.Body, sniffLen)
+	head, err := body.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "wall_*"+imageExt(resp, head))
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	n, err := io.Copy(tmp, body)
+	if err != nil {
+		os.Remove(tmp.Name())
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return "", err
+	}
+	if n == 0 {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: empty body from %s", ErrCorrupt, resp.Request.URL)
+	}
+	if resp.ContentLength > 0 && n != resp.ContentLength {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: got %d of %d bytes", ErrCorrupt, n, resp.ContentLength)
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDownloadToTemp(t *testing.T) {
+	body := []byte("not really an image, but long enough")
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+	}{
+		{"complete", func(w http.ResponseWriter, r *http.Request) { w.Write(body) }, nil},
+		{"empty body", func(w http.ResponseWriter, r *http.Request) {}, ErrCorrupt},
+		{"cut at half", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:len(body)/2])
+		}, ErrCorrupt},
+		{"not found", func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }, ErrNotFound},
+		{"rate limited", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}, ErrRateLimited},
+		{"challenge", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.WriteHeader(http.StatusForbidden)
+		}, ErrChallenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			path, err := New(srv.Client(), "test").DownloadToTemp(context.Background(), srv.URL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DownloadToTemp = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DownloadToTemp: %v", err)
+			}
+			defer os.Remove(path)
+			got, err := os.ReadFile(path)
+			if err != nil || string(got) != string(body) {
+				t.Errorf("saved %q, %v; want %q", got, err, body)
+			}
+		})
+	}
+}
+
+// roundTripFunc is a transport that isn't an *http.Transport.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestProxied(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.corp.example:8080")
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		want      bool
+	}{
+		{"proxy", &http.Transport{Proxy: http.ProxyURL(proxy)}, true},
+		{"bypassed", &http.Transport{Proxy: func(*http.Request) (*url.URL, error) { return nil, nil }}, false},
+		{"proxy error", &http.Transport{Proxy: func(*http.Request) (*url.URL, error) { return nil, errors.New("bad PAC") }}, false},
+		{"no proxy", &http.Transport{}, false},
+		{"other transport, no proxy in the environment", roundTripFunc(nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&http.Client{Transport: tt.transport}, "test")
+			if got := c.Proxied("wallscloud.net"); got != tt.want {
+				t.Errorf("Proxied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
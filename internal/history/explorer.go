@@ -0,0 +1,170 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	desktopININame = "desktop.ini"
+	// picturesIcon is the icon of the Pictures folder.
+	picturesIcon = `%SystemRoot%\system32\imageres.dll,-113`
+
+	gpsReadWrite        = 2 // GPS_READWRITE
+	vtLPWStr            = 31
+	coinitApartment     = 0x2
+	rpcEChangedMode     = 0x80010106
+	propertyStoreSetVal = 6 // IPropertyStore vtable slots after IUnknown's three
+	propertyStoreCommit = 7
+	unknownRelease      = 2
+)
+
+var (
+	procSHGetPropertyStoreFromParsingName = syscall.NewLazyDLL("shell32.dll").NewProc("SHGetPropertyStoreFromParsingName")
+	ole32                                 = syscall.NewLazyDLL("ole32.dll")
+	procCoInitializeEx                    = ole32.NewProc("CoInitializeEx")
+	procCoUninitialize                    = ole32.NewProc("CoUninitialize")
+	procCopyFileExW                       = syscall.NewLazyDLL("kernel32.dll").NewProc("CopyFileExW")
+
+	iidPropertyStore = windows.GUID{Data1: 0x886d8eeb, Data2: 0x8cf2, Data3: 0x4446, Data4: [8]byte{0x8d, 0x02, 0xcd, 0xba, 0x1d, 0xbd, 0xcf, 0x99}}
+	// pkeyComment is PKEY_Comment, shown as "Comments" in Explorer.
+	pkeyComment = propertyKey{
+		fmtid: windows.GUID{Data1: 0xf29f85e0, Data2: 0x4ff9, Data3: 0x1068, Data4: [8]byte{0xab, 0x91, 0x08, 0x00, 0x2b, 0x27, 0xb3, 0xd9}},
+		pid:   6,
+	}
+)
+
+type propertyKey struct {
+	fmtid windows.GUID
+	pid   uint32
+}
+
+// propVariant is a PROPVARIANT holding a pointer.
+type propVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	val      uintptr
+	_        uintptr
+}
+
+// WriteExplorerInfo makes the history dir informative in Explorer: a
+// desktop.ini gives the folder the Pictures icon and a tooltip, and the
+// newest file, if it is a JPEG, gets its title and source URL as its
+// Comments property. The other formats' property handlers can't store one.
+func (h *History) WriteExplorerInfo() error {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	newest := h.entries[len(h.entries)-1]
+	if err := h.writeDesktopINI(newest); err != nil {
+		return err
+	}
+	if ext := strings.ToLower(filepath.Ext(newest.File)); ext != ".jpg" && ext != ".jpeg" {
+		return nil
+	}
+	var comment []string
+	if newest.Title != "" {
+		comment = append(comment, newest.Title)
+	}
+	if newest.SourceURL != "" {
+		comment = append(comment, newest.SourceURL)
+	}
+	if len(comment) == 0 {
+		return nil
+	}
+	return setComment(h.Path(newest), strings.Join(comment, " - "))
+}
+
+func (h *History) writeDesktopINI(newest Entry) error {
+	tip := fmt.Sprintf("%d past wallpapers kept by GoWallpaper; newest from %s", len(h.entries), newest.Source)
+	if newest.Title != "" {
+		tip += ": " + newest.Title
+	}
+	ini := "[.ShellClassInfo]\r\nIconResource=" + picturesIcon + "\r\nInfoTip=" + tip + "\r\n"
+	path := filepath.Join(h.dir, desktopININame)
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	// A hidden system file can't be replaced.
+	_ = windows.SetFileAttributes(p, windows.FILE_ATTRIBUTE_NORMAL)
+	// UTF-16 with a BOM so titles in any script survive.
+	b := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(ini)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	if err := store.WriteFileAtomic(path, b); err != nil {
+		return err
+	}
+	if err := windows.SetFileAttributes(p, windows.FILE_ATTRIBUTE_HIDDEN|windows.FILE_ATTRIBUTE_SYSTEM); err != nil {
+		return err
+	}
+	// Explorer only reads desktop.ini in read-only or system folders; for a
+	// folder read-only just means "customized".
+	dir, err := windows.UTF16PtrFromString(h.dir)
+	if err != nil {
+		return err
+	}
+	attrs, err := windows.GetFileAttributes(dir)
+	if err != nil {
+		return err
+	}
+	return windows.SetFileAttributes(dir, attrs|windows.FILE_ATTRIBUTE_READONLY)
+}
+
+// setComment writes a file's Comments property. Tests catch what it is
+// given.
+var setComment = shellSetComment
+
+// shellSetComment writes PKEY_Comment through the shell property system.
+// COM is initialized on the calling thread, which stays locked for the
+// duration.
+func shellSetComment(path, comment string) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if hr, _, _ := procCoInitializeEx.Call(0, coinitApartment); int32(hr) < 0 {
+		if uint32(hr) != rpcEChangedMode {
+			return fmt.Errorf("CoInitializeEx: %#x", uint32(hr))
+		}
+	} else {
+		defer procCoUninitialize.Call()
+	}
+
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var store *struct {
+		vtbl *[propertyStoreCommit + 1]uintptr
+	} // IPropertyStore
+	if hr, _, _ := procSHGetPropertyStoreFromParsingName.Call(uintptr(unsafe.Pointer(p)), 0, gpsReadWrite,
+		uintptr(unsafe.Pointer(&iidPropertyStore)), uintptr(unsafe.Pointer(&store))); int32(hr) < 0 || store == nil {
+		return fmt.Errorf("no writable properties for %s: %#x", filepath.Base(path), uint32(hr))
+	}
+	vtbl := store.vtbl
+	defer syscall.SyscallN(vtbl[unknownRelease], uintptr(unsafe.Pointer(store)))
+
+	text, err := syscall.UTF16PtrFromString(comment)
+	if err != nil {
+		return err
+	}
+	v := propVariant{vt: vtLPWStr, val: uintptr(unsafe.Pointer(text))}
+	if hr, _, _ := syscall.SyscallN(vtbl[propertyStoreSetVal], uintptr(unsafe.Pointer(store)),
+		uintptr(unsafe.Pointer(&pkeyComment)), uintptr(unsafe.Pointer(&v))); int32(hr) < 0 {
+		return fmt.Errorf("setting comment of %s: %#x", filepath.Base(path), uint32(hr))
+	}
+	if hr, _, _ := syscall.SyscallN(vtbl[propertyStoreCommit], uintptr(unsafe.Pointer(store))); int32(hr) < 0 {
+		return fmt.Errorf("saving comment of %s: %#x", filepath.Base(path), uint32(hr))
+	}
+	runtime.KeepAlive(text)
+	return nil
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wallpaper-changer/internal/fetch"
+)
+
+// TestExplorerCommentOnFetchedJPEG runs a downloaded JPEG through Add and
+// WriteExplorerInfo, whether the server names its type or not, and checks
+// the newest file gets its title and source URL as its comment.
+func TestExplorerCommentOnFetchedJPEG(t *testing.T) {
+	jpeg, err := os.ReadFile(filepath.Join("testdata", "valid.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, contentType := range []string{"image/jpeg", "application/octet-stream"} {
+		t.Run(contentType, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				w.Write(jpeg)
+			}))
+			defer srv.Close()
+			tmp, err := fetch.New(srv.Client(), "test").DownloadToTemp(context.Background(), srv.URL+"/dunes")
+			if err != nil {
+				t.Fatalf("DownloadToTemp: %v", err)
+			}
+			defer os.Remove(tmp)
+			if ext := filepath.Ext(tmp); ext != ".jpg" {
+				t.Errorf("temp file %s, want a .jpg", filepath.Base(tmp))
+			}
+
+			var gotPath, gotComment string
+			orig := setComment
+			setComment = func(path, comment string) error {
+				gotPath, gotComment = path, comment
+				return nil
+			}
+			t.Cleanup(func() { setComment = orig })
+
+			h, err := Open(filepath.Join(t.TempDir(), DirName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := Entry{Source: "wallscloud", SourceURL: srv.URL + "/dunes", Title: "Dunes", Added: day}
+			if err := h.Add(tmp, e); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if err := h.WriteExplorerInfo(); err != nil {
+				t.Fatalf("WriteExplorerInfo: %v", err)
+			}
+			newest := h.Path(h.Recent(1)[0])
+			if gotPath != newest || !strings.HasSuffix(newest, ".jpg") {
+				t.Errorf("comment written to %q, want the newest file %q as a .jpg", gotPath, newest)
+			}
+			if want := "Dunes - " + srv.URL + "/dunes"; gotComment != want {
+				t.Errorf("comment = %q, want %q", gotComment, want)
+			}
+		})
+	}
+}
+
+// TestAddNamesByFormat checks that a file saved without an extension is
+// kept under the one of its format.
+func TestAddNamesByFormat(t *testing.T) {
+	for fixture, want := range map[string]string{"valid.jpeg": ".jpg", "half.png": ".png"} {
+		b, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bare := filepath.Join(t.TempDir(), "wall_123")
+		if err := os.WriteFile(bare, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		h, err := Open(filepath.Join(t.TempDir(), DirName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Add(bare, Entry{Source: "bing", Added: day}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if got := filepath.Ext(h.Recent(1)[0].File); got != want {
+			t.Errorf("%s kept as %s, want %s", fixture, h.Recent(1)[0].File, want)
+		}
+	}
+}
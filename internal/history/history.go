@@ -0,0 +1,310 @@
+// Package history keeps the originals of past wallpapers in the app dir so a
+// change can fall back to one of them when no source is reachable.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// DirName is the history dir inside the app dir.
+	DirName = "history"
+	// CorruptDirName is where unreadable entries are moved, inside DirName.
+	CorruptDirName = "corrupt"
+
+	indexFileName      = "index.json"
+	indexSchemaVersion = 1
+	maxEntries         = 50
+	fileTimeStamp      = "20060102-150405"
+)
+
+// Entry is one wallpaper original kept in the history dir.
+type Entry struct {
+	File      string    `json:"file"` // name inside the history dir
+	Source    string    `json:"source"`
+	SourceURL string    `json:"source_url,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Size      string    `json:"size,omitempty"`      // substituted download size, if any
+	Initiator string    `json:"initiator,omitempty"` // who asked for the change
+	Added     time.Time `json:"added"`
+	// Retired entries' source pages are gone, such as when the image was
+	// taken down; they are kept but never set again.
+	Retired bool `json:"retired,omitempty"`
+}
+
+// Label is a short description for menus.
+func (e Entry) Label() string {
+	s := e.Added.Format("2006-01-02 15:04") + " - " + e.Source
+	if e.Title != "" {
+		s += ": " + e.Title
+	}
+	return s
+}
+
+// Files carries out the history's deletions and moves, refusing those of
+// files in use; store.Store implements it.
+type Files interface {
+	RemoveFile(path string) error
+	MoveFile(from, to string) error
+}
+
+// osFiles deletes and moves without checking, for a History without Files.
+type osFiles struct{}
+
+func (osFiles) RemoveFile(path string) error   { return os.Remove(path) }
+func (osFiles) MoveFile(from, to string) error { return os.Rename(from, to) }
+
+// History is the index of the history dir. It isn't safe for concurrent use;
+// the change manager is its only user.
+type History struct {
+	// ManageColor has Load and Random convert wide-gamut images to sRGB,
+	// as imaging.ManageColor does for fresh downloads.
+	ManageColor bool
+	// Files, when set, performs the pruning and quarantining so that the
+	// file of the current wallpaper is never deleted.
+	Files Files
+	// Verify, when set, is asked before Load and Random hand out an entry
+	// with a SourceURL; the entries it rejects are retired.
+	Verify func(e Entry) bool
+
+	dir     string
+	entries []Entry
+	// index keeps index.json, written atomically with a backup.
+	index *store.Store
+}
+
+// Open loads the index in dir. A missing dir or index is an empty history,
+// and so is a corrupt index without a usable backup, which is set aside.
+func Open(dir string) (*History, error) {
+	h := &History{dir: dir, index: store.New(dir, store.Hooks{})}
+	err := h.index.ReadJSON(indexFileName, indexSchemaVersion, &h.entries)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("history index: %w", err)
+	}
+	return h, nil
+}
+
+// Len returns the number of entries.
+func (h *History) Len() int { return len(h.entries) }
+
+// Add copies the file at path into the history and records it as e, whose
+// File is filled in from e.Added and e.Source. The oldest entries beyond the
+// limit are dropped, skipping those whose file is in use.
+func (h *History) Add(path string, e Entry) error {
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return err
+	}
+	e.File = e.Added.Format(fileTimeStamp) + "_" + e.Source + fileExt(path)
+	if err := copyFile(path, filepath.Join(h.dir, e.File)); err != nil {
+		return err
+	}
+	h.entries = append(h.entries, e)
+	for i := 0; len(h.entries) > maxEntries && i < len(h.entries)-1; {
+		err := h.files().RemoveFile(filepath.Join(h.dir, h.entries[i].File))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// In use, or locked by e.g. a virus scanner: try the next.
+			fmt.Printf("history: keeping %s: %v\n", h.entries[i].File, err)
+			i++
+			continue
+		}
+		h.entries = append(h.entries[:i], h.entries[i+1:]...)
+	}
+	return h.save()
+}
+
+// fileExt returns path's extension or, for a temp file saved without one,
+// the extension of the format image.DecodeConfig finds in it.
+func fileExt(path string) string {
+	if ext := filepath.Ext(path); ext != "" {
+		return ext
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	_, format, err := image.DecodeConfig(f)
+	switch {
+	case err != nil:
+		return ""
+	case format == "jpeg":
+		return ".jpg"
+	default:
+		return "." + format
+	}
+}
+
+// Recent returns up to n entries that aren't retired, newest first.
+func (h *History) Recent(n int) []Entry {
+	out := make([]Entry, 0, min(n, len(h.entries)))
+	for i := len(h.entries) - 1; i >= 0 && len(out) < n; i-- {
+		if !h.entries[i].Retired {
+			out = append(out, h.entries[i])
+		}
+	}
+	return out
+}
+
+// Load decodes the entry stored as file, quarantining it if it is corrupt.
+func (h *History) Load(file string) (Entry, image.Image, error) {
+	for _, e := range h.entries {
+		if e.File != file {
+			continue
+		}
+		if e.Retired || !h.verified(e) {
+			return e, nil, fmt.Errorf("%s was removed from its source", file)
+		}
+		img, err := h.decode(e)
+		if errors.Is(err, imaging.ErrCorrupt) || errors.Is(err, os.ErrNotExist) {
+			h.quarantine(e, err)
+		}
+		if err != nil {
+			return e, nil, err
+		}
+		return e, imaging.ManageColor(h.Path(e), img, h.ManageColor), nil
+	}
+	return Entry{}, nil, fmt.Errorf("%s is no longer in history", file)
+}
+
+// Path returns where e's file is stored.
+func (h *History) Path(e Entry) string { return filepath.Join(h.dir, e.File) }
+
+// Random decodes entries in random order and returns the first readable one
+// that isn't retired. Entries that fail to decode are quarantined and
+// skipped rather than failing the change, as are those Verify rejects.
+func (h *History) Random() (Entry, image.Image, error) {
+	order := random.Perm(len(h.entries))
+	candidates := make([]Entry, len(order))
+	for i, j := range order {
+		candidates[i] = h.entries[j]
+	}
+	for _, e := range candidates {
+		if e.Retired {
+			continue
+		}
+		img, err := h.decode(e)
+		if err == nil && !h.verified(e) {
+			continue
+		}
+		if err == nil {
+			return e, imaging.ManageColor(h.Path(e), img, h.ManageColor), nil
+		}
+		if !errors.Is(err, imaging.ErrCorrupt) && !errors.Is(err, os.ErrNotExist) {
+			return Entry{}, nil, err
+		}
+		h.quarantine(e, err)
+	}
+	return Entry{}, nil, errors.New("no usable wallpapers in history")
+}
+
+// Sweep validates every entry up front and quarantines the corrupt ones. It
+// returns how many were quarantined.
+func (h *History) Sweep() int {
+	n := 0
+	for _, e := range append([]Entry(nil), h.entries...) {
+		if _, err := h.decode(e); errors.Is(err, imaging.ErrCorrupt) || errors.Is(err, os.ErrNotExist) {
+			h.quarantine(e, err)
+			n++
+		}
+	}
+	return n
+}
+
+// verified asks Verify about e, retiring it if rejected.
+func (h *History) verified(e Entry) bool {
+	if h.Verify == nil || e.SourceURL == "" || h.Verify(e) {
+		return true
+	}
+	for i := range h.entries {
+		if h.entries[i].File == e.File {
+			h.entries[i].Retired = true
+		}
+	}
+	fmt.Printf("history: retired %s, its source page is gone\n", e.File)
+	if err := h.save(); err != nil {
+		fmt.Println("history: failed to save index:", err)
+	}
+	return false
+}
+
+func (h *History) decode(e Entry) (image.Image, error) {
+	path := filepath.Join(h.dir, e.File)
+	if err := imaging.ValidateFile(path); err != nil {
+		return nil, err
+	}
+	return imaging.DecodeFile(path)
+}
+
+// quarantine moves e's file to the corrupt dir, where it can be inspected,
+// and drops its record from the index.
+func (h *History) quarantine(e Entry, cause error) {
+	corruptDir := filepath.Join(h.dir, CorruptDirName)
+	src := filepath.Join(h.dir, e.File)
+	if _, err := os.Stat(src); err == nil {
+		err = os.MkdirAll(corruptDir, 0o755)
+		if err == nil {
+			err = h.files().MoveFile(src, filepath.Join(corruptDir, e.File))
+		}
+		if err != nil {
+			fmt.Printf("history: failed to quarantine %s: %v\n", e.File, err)
+		}
+	}
+	fmt.Printf("history: quarantined %s: %v\n", e.File, cause)
+
+	for i, x := range h.entries {
+		if x.File == e.File {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	if err := h.save(); err != nil {
+		fmt.Println("history: failed to save index:", err)
+	}
+}
+
+func (h *History) files() Files {
+	if h.Files == nil {
+		return osFiles{}
+	}
+	return h.Files
+}
+
+func (h *History) save() error {
+	if err := h.index.WriteJSON(indexFileName, indexSchemaVersion, h.entries); err != nil {
+		return err
+	}
+	// Retry what an earlier outage queued, this write included.
+	h.index.Flush()
+	return nil
+}
+
+// copyFile copies src to dst with CopyFileExW, which lets the cache manager
+// write the copy behind, at its own pace, instead of the copy going through
+// this process a 32 KB buffer at a time. The copy is buffered: the image is
+// read from the cache it was just set from.
+func copyFile(src, dst string) error {
+	from, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	to, err := syscall.UTF16PtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	if ok, _, e := procCopyFileExW.Call(uintptr(unsafe.Pointer(from)), uintptr(unsafe.Pointer(to)), 0, 0, 0, 0); ok == 0 {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: e}
+	}
+	return nil
+}
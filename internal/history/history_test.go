@@ -0,0 +1,394 @@
+package history
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/store"
+)
+
+// writePNG saves a small solid image to path.
+func writePNG(t *testing.T, path string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 9))
+	for x := range 16 {
+		for y := range 9 {
+			img.Set(x, y, color.RGBA{0x30, 0x60, 0x90, 0xff})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var day = time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+
+func TestAddAndReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DirName)
+	src := filepath.Join(t.TempDir(), "candidate.png")
+	writePNG(t, src)
+
+	h, err := Open(dir)
+	if err != nil || h.Len() != 0 {
+		t.Fatalf("Open of a missing dir = %v entries, %v; want an empty history", h.Len(), err)
+	}
+	for i := range 3 {
+		e := Entry{Source: "wallscloud", Title: "title", Added: day.Add(time.Duration(i) * time.Hour)}
+		if err := h.Add(src, e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	h, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	recent := h.Recent(2)
+	if len(recent) != 2 || !recent[0].Added.Equal(day.Add(2*time.Hour)) {
+		t.Fatalf("Recent(2) = %v, want the two newest entries", recent)
+	}
+	if want := "20260314-110000_wallscloud.png"; recent[0].File != want {
+		t.Errorf("newest file = %q, want %q", recent[0].File, want)
+	}
+	if _, img, err := h.Load(recent[0].File); err != nil || img.Bounds().Dx() != 16 {
+		t.Errorf("Load = %v, %v", img, err)
+	}
+}
+
+func TestAddPrunesOldest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DirName)
+	src := filepath.Join(t.TempDir(), "candidate.png")
+	writePNG(t, src)
+	h, _ := Open(dir)
+	for i := range maxEntries + 3 {
+		if err := h.Add(src, Entry{Source: "clock", Added: day.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if h.Len() != maxEntries {
+		t.Fatalf("Len = %d, want %d", h.Len(), maxEntries)
+	}
+	oldest := filepath.Join(dir, day.Format(fileTimeStamp)+"_clock.png")
+	if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+		t.Errorf("oldest file not pruned: %v", err)
+	}
+}
+
+// TestOpenCorruptIndex checks that a torn index.json falls back to the
+// index as it was before the last Add, and without a backup to an empty
+// history, rather than failing every change.
+func TestOpenCorruptIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		backup  bool
+		wantLen int
+		legacy  bool
+	}{
+		{"backup", true, 1, false},
+		{"no backup", false, 0, false},
+		{"unversioned index before the backups", false, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), DirName)
+			src := filepath.Join(t.TempDir(), "candidate.png")
+			writePNG(t, src)
+			h, _ := Open(dir)
+			for i := range 2 {
+				if err := h.Add(src, Entry{Source: "clock", Added: day.Add(time.Duration(i) * time.Minute)}); err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+			}
+			index := filepath.Join(dir, indexFileName)
+			switch {
+			case tt.legacy:
+				os.WriteFile(index, []byte(`[{"file":"a.png","source":"clock"},{"file":"b.png","source":"clock"}]`), 0o644)
+			default:
+				b, _ := os.ReadFile(index)
+				os.WriteFile(index, b[:len(b)/2], 0o644)
+			}
+			if !tt.backup {
+				os.Remove(index + ".bak")
+			}
+			h, err := Open(dir)
+			if err != nil {
+				t.Fatalf("Open = %v, want a recovered history", err)
+			}
+			if h.Len() != tt.wantLen {
+				t.Errorf("Len = %d, want %d", h.Len(), tt.wantLen)
+			}
+			if err := h.Add(src, Entry{Source: "clock", Added: day.Add(time.Hour)}); err != nil {
+				t.Fatalf("Add after recovery: %v", err)
+			}
+			if h, err = Open(dir); err != nil || h.Len() != tt.wantLen+1 {
+				t.Errorf("reopen = %d entries, %v; want %d", h.Len(), err, tt.wantLen+1)
+			}
+		})
+	}
+}
+
+// seeded returns a history holding a copy of each testdata fixture, one
+// minute apart, and the files they were stored as.
+func seeded(t *testing.T, fixtures ...string) (*History, []string) {
+	t.Helper()
+	h, err := Open(filepath.Join(t.TempDir(), DirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for i, f := range fixtures {
+		e := Entry{Source: "fixture", Added: day.Add(time.Duration(i) * time.Minute)}
+		if err := h.Add(filepath.Join("testdata", f), e); err != nil {
+			t.Fatalf("Add %s: %v", f, err)
+		}
+		files = append(files, h.Recent(1)[0].File)
+	}
+	return h, files
+}
+
+// quarantined reports whether file was moved to the corrupt dir and dropped
+// from the index, both in memory and on disk.
+func quarantined(t *testing.T, h *History, file string) bool {
+	t.Helper()
+	if _, err := os.Stat(filepath.Join(h.dir, CorruptDirName, file)); err != nil {
+		return false
+	}
+	reopened, err := Open(h.dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for _, x := range [...]*History{h, reopened} {
+		for _, e := range x.entries {
+			if e.File == file {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRandomSkipsTruncated(t *testing.T) {
+	h, files := seeded(t, "half.jpeg", "valid.jpeg", "half.png")
+	for range 5 {
+		e, img, err := h.Random()
+		if err != nil {
+			t.Fatalf("Random: %v", err)
+		}
+		if e.File != files[1] || img.Bounds().Dx() != 150 {
+			t.Fatalf("Random = %s, want the intact %s", e.File, files[1])
+		}
+	}
+	for _, f := range []string{files[0], files[2]} {
+		if h.Len() == 1 && !quarantined(t, h, f) {
+			t.Errorf("%s dropped but not quarantined", f)
+		}
+	}
+}
+
+func TestRandomAllCorrupt(t *testing.T) {
+	h, files := seeded(t, "half.jpeg", "half.png")
+	if _, _, err := h.Random(); err == nil {
+		t.Fatal("Random succeeded with only truncated files")
+	}
+	for _, f := range files {
+		if !quarantined(t, h, f) {
+			t.Errorf("%s not quarantined", f)
+		}
+	}
+	if h.Len() != 0 {
+		t.Errorf("Len = %d, want 0", h.Len())
+	}
+}
+
+func TestSweep(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures []string
+		want     int
+	}{
+		{"intact", []string{"valid.jpeg", "valid.jpeg"}, 0},
+		{"truncated jpeg", []string{"valid.jpeg", "half.jpeg"}, 1},
+		{"truncated both", []string{"half.png", "valid.jpeg", "half.jpeg"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, files := seeded(t, tt.fixtures...)
+			if n := h.Sweep(); n != tt.want {
+				t.Fatalf("Sweep = %d, want %d", n, tt.want)
+			}
+			if h.Len() != len(files)-tt.want {
+				t.Errorf("Len = %d, want %d", h.Len(), len(files)-tt.want)
+			}
+			for i, f := range files {
+				corrupt := tt.fixtures[i] != "valid.jpeg"
+				if got := quarantined(t, h, f); got != corrupt {
+					t.Errorf("%s quarantined = %v, want %v", f, got, corrupt)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadQuarantines(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove bool
+	}{
+		{"truncated", false},
+		{"missing", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, files := seeded(t, "half.png")
+			if tt.remove {
+				os.Remove(h.Path(h.entries[0]))
+			}
+			if _, _, err := h.Load(files[0]); err == nil {
+				t.Fatal("Load succeeded")
+			}
+			if h.Len() != 0 {
+				t.Errorf("Len = %d, want the record dropped", h.Len())
+			}
+			if !tt.remove && !quarantined(t, h, files[0]) {
+				t.Errorf("%s not quarantined", files[0])
+			}
+		})
+	}
+}
+
+func TestUnicodePaths(t *testing.T) {
+	for _, name := range []string{"Пользователь", "with space", "фото 🌄 wallpapers"} {
+		t.Run(name, func(t *testing.T) {
+			base := filepath.Join(t.TempDir(), name)
+			if err := os.MkdirAll(base, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			src := filepath.Join(base, "кандидат 🖼.png")
+			writePNG(t, src)
+			h, err := Open(filepath.Join(base, DirName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Add(src, Entry{Source: "local", Added: day}); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			e := h.Recent(1)[0]
+			want, _ := os.ReadFile(src)
+			if got, err := os.ReadFile(h.Path(e)); err != nil || string(got) != string(want) {
+				t.Fatalf("copy holds %d bytes, %v; want %d", len(got), err, len(want))
+			}
+			if _, img, err := h.Load(e.File); err != nil || img.Bounds().Dx() != 16 {
+				t.Errorf("Load = %v", err)
+			}
+		})
+	}
+}
+
+// TestPruneStress adds entries, pruning the oldest, while another goroutine
+// deletes whatever history files it finds and a third protects random ones
+// the way a prefetched wallpaper is. Run it with -race: a file that still
+// exists once Protect returns must survive until it is released.
+func TestPruneStress(t *testing.T) {
+	app := t.TempDir()
+	s := store.New(app, store.Hooks{})
+	h, err := Open(filepath.Join(app, DirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Files = s
+	src := filepath.Join(t.TempDir(), "candidate.png")
+	writePNG(t, src)
+
+	// images lists the history files there are now.
+	images := func() []string {
+		dirents, _ := os.ReadDir(h.dir)
+		var paths []string
+		for _, d := range dirents {
+			if strings.HasSuffix(d.Name(), ".png") {
+				paths = append(paths, filepath.Join(h.dir, d.Name()))
+			}
+		}
+		return paths
+	}
+	// hold protects path for owner and, if it was still there, checks it
+	// survives a moment of pruning before releasing it.
+	hold := func(owner, path string) {
+		s.Protect(owner, path)
+		defer s.Protect(owner)
+		if _, err := os.Stat(path); err != nil {
+			return // pruned before it was protected
+		}
+		time.Sleep(50 * time.Microsecond)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s deleted while %s protected it: %v", filepath.Base(path), owner, err)
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { // changes: each new wallpaper becomes the current one
+		defer wg.Done()
+		defer close(done)
+		for i := range 3 * maxEntries {
+			e := Entry{Source: "clock", Added: day.Add(time.Duration(i) * time.Minute)}
+			// Protected before it lands, or the cleanup could take it
+			// between Add and Protect.
+			s.Protect("current", filepath.Join(h.dir, e.Added.Format(fileTimeStamp)+"_clock.png"))
+			if err := h.Add(src, e); err != nil {
+				t.Errorf("Add: %v", err)
+				return
+			}
+		}
+	}()
+	go func() { // a cleanup deleting every file it may
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, p := range images() {
+				if err := s.RemoveFile(p); err != nil && !errors.Is(err, store.ErrProtected) && !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("RemoveFile: %v", err)
+				}
+			}
+		}
+	}()
+	go func() { // prefetches and favorites holding older files
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if paths := images(); len(paths) > 0 {
+				hold([]string{"pending", "favorites"}[i%2], paths[i%len(paths)])
+			}
+		}
+	}()
+	wg.Wait()
+
+	current := h.Path(h.Recent(1)[0])
+	if _, err := os.Stat(current); err != nil {
+		t.Errorf("the current wallpaper's file is gone: %v", err)
+	}
+	if err := s.RemoveFile(current); !errors.Is(err, store.ErrProtected) {
+		t.Errorf("RemoveFile of the current file = %v, want ErrProtected", err)
+	}
+}
@@ -0,0 +1,299 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Color spaces of embedded ICC profiles, as ProfileSpace reports them.
+const (
+	SpaceSRGB      = "sRGB"
+	SpaceDisplayP3 = "Display P3"
+	SpaceAdobeRGB  = "Adobe RGB"
+)
+
+const (
+	iccHeaderSize = 128
+	// maxICCProfile bounds a decompressed PNG profile; real ones are a few
+	// KB, a few hundred for the big printer profiles.
+	maxICCProfile = 1 << 20
+	// encodeLUTSize is the resolution of the linear-to-sRGB table, fine
+	// enough that neighbouring entries never differ by more than one level
+	// where it matters.
+	encodeLUTSize = 4096
+)
+
+var iccMarker = []byte("ICC_PROFILE\x00")
+
+// toSRGB are the primaries conversions from linear light in each space to
+// linear sRGB. All three share the D65 white point, so no adaptation is
+// needed.
+var toSRGB = map[string][3][3]float64{
+	SpaceDisplayP3: {
+		{1.2249401, -0.2249404, 0},
+		{-0.0420569, 1.0420571, 0},
+		{-0.0196376, -0.0786361, 1.0982735},
+	},
+	SpaceAdobeRGB: {
+		{1.3982832, -0.3982831, 0},
+		{0, 1, 0},
+		{0, -0.0429383, 1.0429383},
+	},
+}
+
+// adobeGamma is Adobe RGB (1998)'s pure power transfer curve; Display P3
+// uses the sRGB curve.
+const adobeGamma = 563.0 / 256
+
+// DecodeManaged decodes the image at path like DecodeFile and passes it
+// through ManageColor.
+func DecodeManaged(path string, manage bool) (image.Image, error) {
+	img, err := DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ManageColor(path, img, manage), nil
+}
+
+// ManageColor looks for an ICC profile embedded in the file at path, which
+// image/jpeg and image/png ignore, and with manage set converts img, decoded
+// from it, to sRGB when the profile is Display P3 or Adobe RGB so it doesn't
+// show washed out. Other profiles, and all of them without manage, are only
+// logged.
+func ManageColor(path string, img image.Image, manage bool) image.Image {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return img
+	}
+	profile := embeddedProfile(b)
+	if profile == nil {
+		return img
+	}
+	space, desc := ProfileSpace(profile)
+	switch {
+	case space == SpaceSRGB:
+		return img
+	case space == "":
+		fmt.Printf("%s has an unsupported color profile %q, left as is\n", filepath.Base(path), desc)
+		return img
+	case !manage:
+		fmt.Printf("%s has a %s color profile, left as is (color_manage is off)\n", filepath.Base(path), space)
+		return img
+	}
+	fmt.Printf("%s has a %s color profile, converting to sRGB\n", filepath.Base(path), space)
+	return ConvertToSRGB(img, space)
+}
+
+// embeddedProfile returns the ICC profile of a JPEG (APP2 segments) or PNG
+// (iCCP chunk), or nil when there is none.
+func embeddedProfile(b []byte) []byte {
+	switch {
+	case bytes.HasPrefix(b, jpegSOI):
+		return jpegProfile(b)
+	case bytes.HasPrefix(b, pngMagic):
+		return pngProfile(b)
+	}
+	return nil
+}
+
+// jpegProfile joins the profile chunks of the APP2 segments before the
+// first scan. Each chunk carries its 1-based sequence number, so they are
+// put in order rather than trusted to come in it.
+func jpegProfile(b []byte) []byte {
+	type chunk struct {
+		seq  byte
+		data []byte
+	}
+	var chunks []chunk
+	i := len(jpegSOI)
+	for i+3 < len(b) {
+		if b[i] != 0xff {
+			break
+		}
+		marker := b[i+1]
+		if marker == 0xff {
+			i++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 { // SOS, EOI: no more headers
+			break
+		}
+		n := int(b[i+2])<<8 | int(b[i+3])
+		end := i + 2 + n
+		if n < 2 || end > len(b) {
+			break
+		}
+		seg := b[i+4 : end]
+		if marker == 0xe2 && len(seg) > len(iccMarker)+2 && bytes.HasPrefix(seg, iccMarker) {
+			chunks = append(chunks, chunk{seg[len(iccMarker)], seg[len(iccMarker)+2:]})
+		}
+		i = end
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	sort.SliceStable(chunks, func(a, b int) bool { return chunks[a].seq < chunks[b].seq })
+	var out []byte
+	for _, c := range chunks {
+		out = append(out, c.data...)
+	}
+	return out
+}
+
+// pngProfile inflates the iCCP chunk, which must come before the image data.
+func pngProfile(b []byte) []byte {
+	i := len(pngMagic)
+	for i+8 <= len(b) {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		if n < 0 || i+12+n > len(b) || typ == "IDAT" {
+			return nil
+		}
+		data := b[i+8 : i+8+n]
+		if typ == "iCCP" {
+			// Profile name, NUL, compression method (always zlib), data.
+			nul := bytes.IndexByte(data, 0)
+			if nul < 0 || nul+2 > len(data) {
+				return nil
+			}
+			r, err := zlib.NewReader(bytes.NewReader(data[nul+2:]))
+			if err != nil {
+				return nil
+			}
+			defer r.Close()
+			profile, err := io.ReadAll(io.LimitReader(r, maxICCProfile))
+			if err != nil {
+				return nil
+			}
+			return profile
+		}
+		i += 12 + n
+	}
+	return nil
+}
+
+// ProfileSpace classifies an ICC profile by its description, returning one
+// of the Space constants or "" when it is none of them, along with the
+// description itself.
+func ProfileSpace(profile []byte) (space, desc string) {
+	if len(profile) < iccHeaderSize+4 || string(profile[16:20]) != "RGB " {
+		return "", "not an RGB profile"
+	}
+	desc = profileDescription(profile)
+	d := strings.ToLower(desc)
+	switch {
+	case strings.Contains(d, "srgb"), strings.Contains(d, "iec61966-2"):
+		return SpaceSRGB, desc
+	case strings.Contains(d, "p3"):
+		return SpaceDisplayP3, desc
+	case strings.Contains(d, "adobe rgb"), strings.Contains(d, "adobergb"):
+		return SpaceAdobeRGB, desc
+	}
+	return "", desc
+}
+
+// profileDescription returns the text of the profile's 'desc' tag, stored
+// as ASCII in v2 profiles and as UTF-16 in v4 ones ('mluc').
+func profileDescription(p []byte) string {
+	count := int(binary.BigEndian.Uint32(p[iccHeaderSize:]))
+	for t := 0; t < count; t++ {
+		at := iccHeaderSize + 4 + 12*t
+		if at+12 > len(p) {
+			break
+		}
+		if string(p[at:at+4]) != "desc" {
+			continue
+		}
+		off := int(binary.BigEndian.Uint32(p[at+4:]))
+		size := int(binary.BigEndian.Uint32(p[at+8:]))
+		if off < 0 || size < 12 || off+size > len(p) {
+			return ""
+		}
+		tag := p[off : off+size]
+		switch string(tag[:4]) {
+		case "desc":
+			n := int(binary.BigEndian.Uint32(tag[8:]))
+			if n <= 0 || 12+n > len(tag) {
+				return ""
+			}
+			return strings.TrimRight(string(tag[12:12+n]), "\x00")
+		case "mluc":
+			if len(tag) < 28 {
+				return ""
+			}
+			// First record; the language doesn't matter for matching.
+			n := int(binary.BigEndian.Uint32(tag[20:]))
+			o := int(binary.BigEndian.Uint32(tag[24:]))
+			if n < 0 || o < 0 || o+n > len(tag) {
+				return ""
+			}
+			u := make([]uint16, n/2)
+			for k := range u {
+				u[k] = binary.BigEndian.Uint16(tag[o+2*k:])
+			}
+			return string(utf16.Decode(u))
+		}
+		return ""
+	}
+	return ""
+}
+
+// ConvertToSRGB converts img from space, one of the Space constants, to
+// sRGB: channels are linearized with the space's curve, mapped through the
+// primaries matrix, clipped to the sRGB gamut and re-encoded. It returns
+// img as it is for sRGB or an unknown space.
+func ConvertToSRGB(img image.Image, space string) image.Image {
+	m, ok := toSRGB[space]
+	if !ok {
+		return img
+	}
+	var decode [256]float64
+	for i := range decode {
+		if space == SpaceAdobeRGB {
+			decode[i] = math.Pow(float64(i)/255, adobeGamma)
+		} else {
+			decode[i] = linear(uint32(i) * 0x101)
+		}
+	}
+	var encode [encodeLUTSize + 1]uint8
+	for i := range encode {
+		encode[i] = clamp8(linearToSRGB(float64(i)/encodeLUTSize) * 255)
+	}
+	enc := func(v float64) uint8 {
+		return encode[int(math.Round(min(max(v, 0), 1)*encodeLUTSize))]
+	}
+
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			r, g, bl := decode[cr>>8], decode[cg>>8], decode[cb>>8]
+			out.SetRGBA(x, y, color.RGBA{
+				R: enc(m[0][0]*r + m[0][1]*g + m[0][2]*bl),
+				G: enc(m[1][0]*r + m[1][1]*g + m[1][2]*bl),
+				B: enc(m[2][0]*r + m[2][1]*g + m[2][2]*bl),
+				A: uint8(ca >> 8),
+			})
+		}
+	}
+	return out
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
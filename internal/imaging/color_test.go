@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// srgbPatches are the colors of the 16x16 patches, left to right, that the
+// wide-gamut fixtures should come out as. The fixtures hold them converted
+// to each space from its published primaries, so P3's red patch is the
+// (234, 51, 35) CSS Color 4 gives for sRGB red.
+var srgbPatches = []color.RGBA{
+	{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255},
+	{128, 128, 128, 255}, {200, 120, 60, 255}, {40, 90, 160, 255},
+}
+
+const patchSize = 16
+
+// colorTolerance allows for JPEG rounding, which the conversion amplifies a
+// little in the saturated channels.
+const colorTolerance = 4
+
+// patch returns the color at the middle of patch i.
+func patch(img image.Image, i int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(i*patchSize+patchSize/2, patchSize/2)).(color.RGBA)
+}
+
+func TestEmbeddedProfile(t *testing.T) {
+	tests := []struct {
+		file  string
+		space string
+		found bool
+	}{
+		{"p3.jpeg", SpaceDisplayP3, true},
+		{"p3.chunked.jpeg", SpaceDisplayP3, true},
+		{"p3.png", SpaceDisplayP3, true},
+		{"adobergb.jpeg", SpaceAdobeRGB, true},
+		{"unknown.jpeg", "", true},
+		{"valid.jpeg", "", false},
+		{"valid.png", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			profile := embeddedProfile(b)
+			if (profile != nil) != tt.found {
+				t.Fatalf("found profile %v, want %v", profile != nil, tt.found)
+			}
+			if profile == nil {
+				return
+			}
+			if space, desc := ProfileSpace(profile); space != tt.space {
+				t.Errorf("ProfileSpace = %q (%q), want %q", space, desc, tt.space)
+			}
+		})
+	}
+}
+
+// TestManageColorFixtures decodes each wide-gamut fixture with color
+// management and checks every patch against its sRGB value.
+func TestManageColorFixtures(t *testing.T) {
+	for _, file := range []string{"p3.jpeg", "p3.chunked.jpeg", "p3.png", "adobergb.jpeg"} {
+		t.Run(file, func(t *testing.T) {
+			img, err := DecodeManaged(filepath.Join("testdata", file), true)
+			if err != nil {
+				t.Fatalf("DecodeManaged: %v", err)
+			}
+			for i, want := range srgbPatches {
+				got := patch(img, i)
+				if !near(got.R, want.R, colorTolerance) || !near(got.G, want.G, colorTolerance) ||
+					!near(got.B, want.B, colorTolerance) || got.A != want.A {
+					t.Errorf("patch %d = %v, want %v ±%d", i, got, want, colorTolerance)
+				}
+			}
+		})
+	}
+}
+
+// TestManageColorOff checks that without color_manage, or with a profile
+// it doesn't know, the pixels are left as decoded.
+func TestManageColorOff(t *testing.T) {
+	tests := []struct {
+		file   string
+		manage bool
+	}{
+		{"p3.jpeg", false},
+		{"adobergb.jpeg", false},
+		{"unknown.jpeg", true},
+		{"valid.jpeg", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+			got, err := DecodeManaged(path, tt.manage)
+			if err != nil {
+				t.Fatalf("DecodeManaged: %v", err)
+			}
+			want, err := DecodeFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameImage(t, got, want, 0)
+		})
+	}
+}
+
+func TestConvertToSRGBNeutral(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{128, 128, 128, 255})
+	for _, space := range []string{SpaceDisplayP3, SpaceAdobeRGB} {
+		got := color.RGBAModel.Convert(ConvertToSRGB(img, space).At(0, 0)).(color.RGBA)
+		// Both spaces share sRGB's white, so neutral gray stays gray.
+		if got.R != got.G || got.G != got.B || got.A != 0xff || !near(got.R, 128, 1) {
+			t.Errorf("%s gray = %v, want gray near 128", space, got)
+		}
+	}
+	if got := ConvertToSRGB(img, SpaceSRGB); got != image.Image(img) {
+		t.Error("ConvertToSRGB copied an sRGB image")
+	}
+}
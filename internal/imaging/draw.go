@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"embed"
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Default size of generated wallpapers.
+const (
+	RenderWidth  = 1920
+	RenderHeight = 1080
+)
+
+// fontFiles are the Go fonts generated text is set in.
+//
+//go:embed fonts/*.ttf
+var fontFiles embed.FS
+
+var (
+	fontOnce              sync.Once
+	regularFont, boldFont *opentype.Font
+	fontErr               error
+)
+
+// NewFace returns a Go font face at size points (72 DPI, so points are pixels).
+func NewFace(size float64, bold bool) (font.Face, error) {
+	fontOnce.Do(func() {
+		if regularFont, fontErr = parseFont("Go-Regular.ttf"); fontErr != nil {
+			return
+		}
+		boldFont, fontErr = parseFont("Go-Bold.ttf")
+	})
+	if fontErr != nil {
+		return nil, fontErr
+	}
+	f := regularFont
+	if bold {
+		f = boldFont
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func parseFont(name string) (*opentype.Font, error) {
+	b, err := fontFiles.ReadFile("fonts/" + name)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.Parse(b)
+}
+
+// DrawText draws s with its baseline starting at (x, y).
+func DrawText(dst *image.RGBA, face font.Face, x, y int, s string, c color.Color) {
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// TextWidth returns the advance width of s in pixels.
+func TextWidth(face font.Face, s string) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// TruncateText shortens s with an ellipsis so it fits in maxWidth pixels.
+func TruncateText(face font.Face, s string, maxWidth int) string {
+	if TextWidth(face, s) <= maxWidth {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && TextWidth(face, string(r)+"…") > maxWidth {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// FillVerticalGradient fills img from top to bottom color.
+func FillVerticalGradient(img *image.RGBA, top, bottom color.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		t := float64(y-b.Min.Y) / float64(max(1, b.Dy()-1))
+		draw.Draw(img, image.Rect(b.Min.X, y, b.Max.X, y+1), image.NewUniform(LerpColor(top, bottom, t)), image.Point{}, draw.Src)
+	}
+}
+
+// FillRect paints r with c.
+func FillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// LerpColor blends a towards b by t in [0, 1].
+func LerpColor(a, b color.RGBA, t float64) color.RGBA {
+	return color.RGBA{R: Lerp8(a.R, b.R, t), G: Lerp8(a.G, b.G, t), B: Lerp8(a.B, b.B, t), A: Lerp8(a.A, b.A, t)}
+}
+
+// Lerp8 interpolates between two channel values.
+func Lerp8(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
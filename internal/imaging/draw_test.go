@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewFace(t *testing.T) {
+	regular, err := NewFace(40, false)
+	if err != nil {
+		t.Fatalf("NewFace: %v", err)
+	}
+	bold, err := NewFace(40, true)
+	if err != nil {
+		t.Fatalf("NewFace bold: %v", err)
+	}
+	if r, b := TextWidth(regular, "Wallpaper"), TextWidth(bold, "Wallpaper"); r <= 0 || b <= r {
+		t.Errorf("widths regular %d, bold %d; want bold wider", r, b)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	face, err := NewFace(32, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 200, 60))
+	text := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	DrawText(img, face, 10, 44, "Привет", text)
+	lit := 0
+	for y := range 60 {
+		for x := range 200 {
+			if img.RGBAAt(x, y) == text {
+				lit++
+			}
+			if x < 8 && img.RGBAAt(x, y).A != 0 {
+				t.Fatalf("ink at (%d,%d), left of the text", x, y)
+			}
+		}
+	}
+	if lit < 100 {
+		t.Errorf("%d pixels inked for Cyrillic text", lit)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	face, err := NewFace(20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := "A very long wallpaper title that will not fit"
+	tests := []struct {
+		s        string
+		maxWidth int
+	}{
+		{"Short", 500},
+		{long, 150},
+		{long, 1},
+	}
+	for _, tt := range tests {
+		got := TruncateText(face, tt.s, tt.maxWidth)
+		if TextWidth(face, tt.s) <= tt.maxWidth {
+			if got != tt.s {
+				t.Errorf("TruncateText(%q) = %q, want it unchanged", tt.s, got)
+			}
+			continue
+		}
+		if r := []rune(got); r[len(r)-1] != '…' {
+			t.Errorf("TruncateText(%q, %d) = %q, want an ellipsis", tt.s, tt.maxWidth, got)
+		}
+		if w := TextWidth(face, got); w > tt.maxWidth && got != "…" {
+			t.Errorf("TruncateText(%q, %d) = %q, %d wide", tt.s, tt.maxWidth, got, w)
+		}
+	}
+}
+
+func TestFillVerticalGradient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 101))
+	top, bottom := color.RGBA{0, 0, 0, 0xff}, color.RGBA{200, 100, 0, 0xff}
+	FillVerticalGradient(img, top, bottom)
+	if got := img.RGBAAt(2, 0); got != top {
+		t.Errorf("top = %v", got)
+	}
+	if got := img.RGBAAt(2, 100); got != bottom {
+		t.Errorf("bottom = %v", got)
+	}
+	if got := img.RGBAAt(2, 50); got.R != 100 || got.G != 50 {
+		t.Errorf("middle = %v, want halfway", got)
+	}
+}
@@ -0,0 +1,185 @@
+// Package imaging decodes, validates, processes and encodes wallpaper images.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/image/bmp"
+)
+
+const (
+	bmpHeaderSize    = 54
+	trailerCheckSize = 64
+)
+
+// ErrCorrupt marks an image that is empty, truncated or undecodable; the
+// change is retried with a fresh download.
+var ErrCorrupt = errors.New("corrupted image")
+
+var (
+	jpegSOI  = []byte{0xff, 0xd8}
+	pngMagic = []byte("\x89PNG\r\n\x1a\n")
+	pngIEND  = []byte("IEND")
+)
+
+// ValidateFile rejects files that are empty or whose JPEG EOI marker or PNG
+// IEND chunk is missing, which is how a download cut short usually looks.
+func ValidateFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return fmt.Errorf("%w: zero-byte file", ErrCorrupt)
+	}
+	tail := b[max(0, len(b)-trailerCheckSize):]
+	switch {
+	case bytes.HasPrefix(b, jpegSOI):
+		if !jpegComplete(b) {
+			return fmt.Errorf("%w: JPEG has no EOI marker (%d bytes)", ErrCorrupt, len(b))
+		}
+	case bytes.HasPrefix(b, pngMagic):
+		if !bytes.Contains(tail, pngIEND) {
+			return fmt.Errorf("%w: PNG has no IEND chunk (%d bytes)", ErrCorrupt, len(b))
+		}
+	}
+	return nil
+}
+
+// jpegComplete walks b's JPEG segments and reports whether they end in an
+// EOI marker. Walking rather than looking near the end of the file accepts
+// progressive JPEGs, whose several scans share one EOI, and files with data
+// appended after EOI (padding, vendor trailers, motion-photo video), while not
+// mistaking the EOI of an embedded Exif thumbnail for the image's own.
+func jpegComplete(b []byte) bool {
+	i := len(jpegSOI)
+	for i+1 < len(b) {
+		if b[i] != 0xff {
+			return false
+		}
+		marker := b[i+1]
+		switch {
+		case marker == 0xff: // fill byte
+			i++
+			continue
+		case marker == 0xd9: // EOI
+			return true
+		case marker >= 0xd0 && marker <= 0xd7, marker == 0x01: // RSTn, TEM: no length
+			i += 2
+			continue
+		}
+		if i+3 >= len(b) {
+			return false
+		}
+		i += 2 + (int(b[i+2])<<8 | int(b[i+3]))
+		if marker != 0xda { // SOS is followed by entropy-coded data
+			continue
+		}
+		// Entropy-coded data runs to the next marker other than a stuffed
+		// 0x00 or a restart marker.
+		for ; i+1 < len(b); i++ {
+			if b[i] == 0xff && b[i+1] != 0x00 && (b[i+1] < 0xd0 || b[i+1] > 0xd7) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// DecodeFile decodes the image at path, rejecting zero-sized results.
+func DecodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		if undecodable(err) {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return nil, err
+	}
+	if b := img.Bounds(); b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, fmt.Errorf("%w: decoded to %dx%d", ErrCorrupt, b.Dx(), b.Dy())
+	}
+	if cmyk, ok := img.(*image.CMYK); ok {
+		img = cmykToRGBA(cmyk)
+	}
+	return img, nil
+}
+
+// undecodable reports whether err says the data is bad rather than that it
+// couldn't be read. A PNG cut short fails with a FormatError, not an EOF.
+func undecodable(err error) bool {
+	var jerr jpeg.FormatError
+	var perr png.FormatError
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, image.ErrFormat) ||
+		errors.As(err, &jerr) || errors.As(err, &perr)
+}
+
+// cmykToRGBA converts a CMYK JPEG, common from photo sites, to RGB up front.
+// image/jpeg has already undone the YCCK transform and Adobe's inverted ink
+// values; converting here means the filters, scalers and encoders see RGBA
+// rather than each treating CMYK differently.
+func cmykToRGBA(m *image.CMYK) *image.RGBA {
+	b := m.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := m.CMYKAt(x, y)
+			r, g, bl := color.CMYKToRGB(c.C, c.M, c.Y, c.K)
+			out.SetRGBA(x, y, color.RGBA{R: r, G: g, B: bl, A: 0xff})
+		}
+	}
+	return out
+}
+
+// EncodeBMPFile writes img as BMP and checks the file is at least as large as
+// the smallest BMP the encoder can produce for these dimensions (8 bpp).
+func EncodeBMPFile(path string, img image.Image) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := bmp.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	if want := int64(bmpHeaderSize + b.Dx()*b.Dy()); fi.Size() < want {
+		return fmt.Errorf("encoded %s is %d bytes, expected at least %d for %dx%d",
+			path, fi.Size(), want, b.Dx(), b.Dy())
+	}
+	return nil
+}
+
+// WriteTempBMP saves a generated image to a temp file.
+func WriteTempBMP(img image.Image) (string, error) {
+	tmp, err := os.CreateTemp("", "wall_*.bmp")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if err := bmp.Encode(tmp, img); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
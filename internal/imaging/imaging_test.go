@@ -0,0 +1,196 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		corrupt bool
+	}{
+		{"valid.jpeg", false},
+		{"valid.png", false},
+		{"tiny.png", false},
+		{"progressive.jpeg", false},
+		{"cmyk.jpeg", false},
+		{"empty.jpeg", true},
+		{"half.jpeg", true},
+		{"half.png", true},
+		{"progressive.half.jpeg", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			err := ValidateFile(filepath.Join("testdata", tt.file))
+			if got := errors.Is(err, ErrCorrupt); got != tt.corrupt {
+				t.Errorf("ValidateFile = %v, want corrupt %v", err, tt.corrupt)
+			}
+		})
+	}
+}
+
+func TestValidateFileMissing(t *testing.T) {
+	err := ValidateFile(filepath.Join("testdata", "missing.jpeg"))
+	if !errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrCorrupt) {
+		t.Errorf("ValidateFile = %v, want a not-exist error", err)
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		corrupt bool
+		w, h    int
+	}{
+		{"valid.jpeg", false, 150, 103},
+		{"valid.png", false, 150, 103},
+		{"tiny.png", false, 1, 1},
+		{"progressive.jpeg", false, 150, 103},
+		{"cmyk.jpeg", false, 150, 103},
+		{"empty.jpeg", true, 0, 0},
+		{"half.jpeg", true, 0, 0},
+		{"half.png", true, 0, 0},
+		{"progressive.half.jpeg", true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			img, err := DecodeFile(filepath.Join("testdata", tt.file))
+			if tt.corrupt {
+				if !errors.Is(err, ErrCorrupt) {
+					t.Errorf("DecodeFile = %v, want ErrCorrupt", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeFile: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+				t.Errorf("decoded %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestEncodeBMPFile(t *testing.T) {
+	img, err := DecodeFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out.bmp")
+	if err := EncodeBMPFile(path, img); err != nil {
+		t.Fatalf("EncodeBMPFile: %v", err)
+	}
+	if err := ValidateFile(path); err != nil {
+		t.Errorf("ValidateFile of the encoded BMP: %v", err)
+	}
+}
+
+// goldenTolerance is how far, per 8-bit channel, a decoded JPEG may stray
+// from its PNG reference; decoders differ in IDCT rounding and upsampling.
+const goldenTolerance = 8
+
+// sameImage fails the test at the first pixel of got more than tolerance
+// away from want in any channel.
+func sameImage(t *testing.T, got, want image.Image, tolerance int) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			if !near(g.R, w.R, tolerance) || !near(g.G, w.G, tolerance) ||
+				!near(g.B, w.B, tolerance) || g.A != w.A {
+				t.Fatalf("pixel (%d,%d) = %v, want %v ±%d", x, y, g, w, tolerance)
+			}
+		}
+	}
+}
+
+func near(a, b uint8, tolerance int) bool {
+	d := int(a) - int(b)
+	return -tolerance <= d && d <= tolerance
+}
+
+// TestDecodeGolden decodes each JPEG fixture and compares every pixel with a
+// lossless reference of the same frame. cmyk.png holds the RGB the CMYK
+// JPEG should come out as, so an inverted Adobe ink or skipped YCCK
+// transform shows up as a mismatch rather than a plausible-looking image.
+func TestDecodeGolden(t *testing.T) {
+	tests := []struct {
+		file, golden string
+	}{
+		{"valid.jpeg", "valid.png"},
+		{"progressive.jpeg", "valid.png"},
+		{"cmyk.jpeg", "cmyk.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := DecodeFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("DecodeFile: %v", err)
+			}
+			want, err := DecodeFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameImage(t, got, want, goldenTolerance)
+		})
+	}
+}
+
+func TestDecodeFileCMYKIsRGBA(t *testing.T) {
+	img, err := DecodeFile(filepath.Join("testdata", "cmyk.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.RGBA); !ok {
+		t.Errorf("decoded a %T, want *image.RGBA", img)
+	}
+}
+
+// TestConvertPipeline runs each fixture the way a change does: validate,
+// decode, process, encode the BMP handed to Windows and read that back. With
+// no filter the BMP must match the reference within the JPEG tolerance.
+func TestConvertPipeline(t *testing.T) {
+	tests := []struct {
+		file, golden string
+	}{
+		{"valid.png", "valid.png"},
+		{"valid.jpeg", "valid.png"},
+		{"progressive.jpeg", "valid.png"},
+		{"cmyk.jpeg", "cmyk.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+			if err := ValidateFile(path); err != nil {
+				t.Fatalf("ValidateFile: %v", err)
+			}
+			img, err := DecodeManaged(path, true)
+			if err != nil {
+				t.Fatalf("DecodeManaged: %v", err)
+			}
+			out := filepath.Join(t.TempDir(), "wallpaper.bmp")
+			if err := EncodeBMPFile(out, Process(img, "")); err != nil {
+				t.Fatalf("EncodeBMPFile: %v", err)
+			}
+			got, err := DecodeFile(out)
+			if err != nil {
+				t.Fatalf("decoding the BMP: %v", err)
+			}
+			want, err := DecodeFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameImage(t, got, want, goldenTolerance)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// EdgeSampleFraction is the share of width/height sampled along each edge.
+	EdgeSampleFraction = 0.02
+	dimFactor          = 0.7
+)
+
+// Process is the pure pixel pipeline: the same original and filter always
+// produce the same output, and the original is never modified.
+func Process(original image.Image, filter string) image.Image {
+	switch filter {
+	case "grayscale":
+		return mapPixels(original, func(r, g, b float64) (float64, float64, float64) {
+			y := 0.299*r + 0.587*g + 0.114*b
+			return y, y, y
+		})
+	case "sepia":
+		return mapPixels(original, func(r, g, b float64) (float64, float64, float64) {
+			return 0.393*r + 0.769*g + 0.189*b, 0.349*r + 0.686*g + 0.168*b, 0.272*r + 0.534*g + 0.131*b
+		})
+	case "dim":
+		return mapPixels(original, func(r, g, b float64) (float64, float64, float64) {
+			return r * dimFactor, g * dimFactor, b * dimFactor
+		})
+	default:
+		return original
+	}
+}
+
+// Luma is c's perceived brightness on the 0-255 scale (ITU-R BT.601).
+func Luma(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+}
+
+// relativeLuminance is c's WCAG relative luminance in [0, 1]: linear light
+// weighted by BT.709, what contrast ratios are defined on, where Luma is
+// the gamma-encoded BT.601 brightness.
+func relativeLuminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// MeanLuminance is the average luma of img scaled to [0, 1].
+func MeanLuminance(img image.Image) float64 {
+	return sampleMean(img, img.Bounds(), Luma) / 255
+}
+
+// sampleMean averages f over r of img, sampling every 4th pixel in each
+// direction. It is 0 for an empty r.
+func sampleMean(img image.Image, r image.Rectangle, f func(color.Color) float64) float64 {
+	const step = 4
+	var sum float64
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y += step {
+		for x := r.Min.X; x < r.Max.X; x += step {
+			sum += f(img.At(x, y))
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// DimIconRegion darkens the left frac of img with a gradient, strongest
+// (by strength) at the left edge and fading to nothing at the region's right
+// edge, if white text there would have a contrast ratio below minContrast.
+// Otherwise, and for the rest of the image, img is returned untouched.
+func DimIconRegion(img image.Image, frac, minContrast, strength float64) image.Image {
+	b := img.Bounds()
+	region := image.Rect(b.Min.X, b.Min.Y, b.Min.X+max(1, int(float64(b.Dx())*frac)), b.Max.Y).Intersect(b)
+	if region.Empty() || whiteTextContrast(img, region) >= minContrast {
+		return img
+	}
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	w := float64(region.Dx())
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			k := 1 - strength*(1-float64(x-region.Min.X)/w)
+			p := out.RGBAAt(x, y)
+			out.SetRGBA(x, y, color.RGBA{R: clamp8(float64(p.R) * k), G: clamp8(float64(p.G) * k), B: clamp8(float64(p.B) * k), A: p.A})
+		}
+	}
+	return out
+}
+
+// whiteTextContrast is the WCAG contrast ratio of white against the mean
+// relative luminance of r; an empty r counts as black.
+func whiteTextContrast(img image.Image, r image.Rectangle) float64 {
+	return 1.05 / (sampleMean(img, r, relativeLuminance) + 0.05)
+}
+
+// linear converts a 16-bit sRGB channel to linear light in [0, 1].
+func linear(c uint32) float64 {
+	v := float64(c) / 0xffff
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// mapPixels returns a copy of img with f applied to each pixel's 0-255 RGB.
+func mapPixels(img image.Image, f func(r, g, b float64) (float64, float64, float64)) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			r, g, bl := f(float64(cr>>8), float64(cg>>8), float64(cb>>8))
+			out.SetRGBA(x, y, color.RGBA{R: clamp8(r), G: clamp8(g), B: clamp8(bl), A: uint8(ca >> 8)})
+		}
+	}
+	return out
+}
+
+func clamp8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// AverageEdgeColor averages the pixels in strips along all four edges, each
+// strip being frac of the image's width or height (at least one pixel).
+func AverageEdgeColor(img image.Image, frac float64) color.RGBA {
+	b := img.Bounds()
+	if b.Empty() {
+		return color.RGBA{A: 0xff}
+	}
+	sx := max(1, int(float64(b.Dx())*frac))
+	sy := max(1, int(float64(b.Dy())*frac))
+
+	var r, g, bl, n uint64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		inRow := y < b.Min.Y+sy || y >= b.Max.Y-sy
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !inRow && x >= b.Min.X+sx && x < b.Max.X-sx {
+				x = b.Max.X - sx - 1 // jump to the right strip
+				continue
+			}
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr >> 8)
+			g += uint64(cg >> 8)
+			bl += uint64(cb >> 8)
+			n++
+		}
+	}
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 0xff}
+}
+
+// Blend returns from mixed towards to by t in [0, 1], in to's bounds. from is
+// scaled to fit first if the sizes differ.
+func Blend(from, to image.Image, t float64) *image.RGBA {
+	b := to.Bounds()
+	if from.Bounds() != b {
+		from = Resize(from, b)
+	}
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			fr, fg, fb, _ := from.At(x, y).RGBA()
+			tr, tg, tb, _ := to.At(x, y).RGBA()
+			out.SetRGBA(x, y, color.RGBA{
+				R: clamp8(mix(fr, tr, t)),
+				G: clamp8(mix(fg, tg, t)),
+				B: clamp8(mix(fb, tb, t)),
+				A: 0xff,
+			})
+		}
+	}
+	return out
+}
+
+func mix(a, b uint32, t float64) float64 {
+	return float64(a>>8)*(1-t) + float64(b>>8)*t
+}
+
+// CropToAspect cuts the largest centred region of img with the aspect ratio
+// of size.
+func CropToAspect(img image.Image, size image.Point) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w*size.Y > h*size.X {
+		w = h * size.X / size.Y
+	} else {
+		h = w * size.Y / size.X
+	}
+	r := image.Rect(0, 0, w, h).Add(b.Min).Add(image.Pt((b.Dx()-w)/2, (b.Dy()-h)/2))
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(out, out.Bounds(), img, r.Min, draw.Src)
+	return out
+}
+
+// Resize scales img to fill r, ignoring aspect ratio.
+func Resize(img image.Image, r image.Rectangle) *image.RGBA {
+	out := image.NewRGBA(r)
+	draw.ApproxBiLinear.Scale(out, r, img, img.Bounds(), draw.Src, nil)
+	return out
+}
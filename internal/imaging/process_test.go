@@ -0,0 +1,221 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// framed returns a w by h image filled with inner, with a border th pixels
+// wide of edge.
+func framed(w, h, th int, edge, inner color.RGBA) *image.RGBA {
+	return framedAt(image.Pt(0, 0), w, h, th, edge, inner)
+}
+
+// framedAt is framed with its bounds starting at min.
+func framedAt(min image.Point, w, h, th int, edge, inner color.RGBA) *image.RGBA {
+	r := image.Rect(0, 0, w, h).Add(min)
+	img := image.NewRGBA(r)
+	FillRect(img, r, edge)
+	FillRect(img, r.Inset(th), inner)
+	return img
+}
+
+func TestAverageEdgeColor(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	tests := []struct {
+		name string
+		img  image.Image
+		frac float64
+		want color.RGBA
+	}{
+		{"uniform", framed(100, 50, 0, red, red), EdgeSampleFraction, red},
+		{"border only is sampled", framed(100, 100, 2, red, blue), EdgeSampleFraction, red},
+		{"at least one pixel", framed(10, 10, 1, blue, red), 0.001, blue},
+		{"offset bounds", framedAt(image.Pt(30, 40), 100, 100, 2, red, blue), EdgeSampleFraction, red},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), EdgeSampleFraction, color.RGBA{A: 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AverageEdgeColor(tt.img, tt.frac); got != tt.want {
+				t.Errorf("AverageEdgeColor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAverageEdgeColorMixesEdges(t *testing.T) {
+	// Left half black, right half white: the strips hold as many of each.
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	FillRect(img, image.Rect(50, 0, 100, 100), color.White)
+	FillRect(img, image.Rect(0, 0, 50, 100), color.Black)
+	got := AverageEdgeColor(img, 0.1)
+	if got.R < 0x7e || got.R > 0x80 || got.R != got.G || got.G != got.B {
+		t.Errorf("AverageEdgeColor = %v, want mid gray", got)
+	}
+}
+
+func TestBlend(t *testing.T) {
+	black := framed(4, 4, 0, color.RGBA{A: 0xff}, color.RGBA{A: 0xff})
+	white := framed(4, 4, 0, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	tests := []struct {
+		t    float64
+		want uint8
+	}{
+		{0, 0},
+		{0.25, 0x40},
+		{0.5, 0x80},
+		{1, 0xff},
+	}
+	for _, tt := range tests {
+		got := Blend(black, white, tt.t).RGBAAt(2, 2)
+		if d := int(got.R) - int(tt.want); d < -1 || d > 1 || got.R != got.G || got.G != got.B {
+			t.Errorf("Blend(%v) = %v, want gray %#x", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestBlendResizesFrom(t *testing.T) {
+	from := framed(2, 2, 0, color.RGBA{A: 0xff}, color.RGBA{A: 0xff})
+	to := framed(6, 4, 0, color.RGBA{A: 0xff}, color.RGBA{A: 0xff})
+	if b := Blend(from, to, 0.5).Bounds(); b != to.Bounds() {
+		t.Errorf("Blend bounds = %v, want %v", b, to.Bounds())
+	}
+}
+
+func TestDimIconRegion(t *testing.T) {
+	light := color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	dark := color.RGBA{0x20, 0x20, 0x20, 0xff}
+	const frac, minContrast, strength = 0.25, 3.0, 0.5
+	tests := []struct {
+		name string
+		img  *image.RGBA
+		dims bool
+	}{
+		{"light", framed(200, 100, 0, light, light), true},
+		{"dark", framed(200, 100, 0, dark, dark), false},
+		{"offset bounds", framedAt(image.Pt(-50, 30), 200, 100, 0, light, light), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.img.Bounds()
+			got := DimIconRegion(tt.img, frac, minContrast, strength)
+			edge := b.Min.X + int(float64(b.Dx())*frac)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					was := tt.img.RGBAAt(x, y)
+					now := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+					switch {
+					case !tt.dims || x >= edge:
+						if now != was {
+							t.Fatalf("pixel (%d,%d) outside the region changed: %v -> %v", x, y, was, now)
+						}
+					case now.R >= was.R:
+						t.Fatalf("pixel (%d,%d) in the region not darkened: %v -> %v", x, y, was, now)
+					}
+				}
+			}
+			if !tt.dims {
+				return
+			}
+			// The gradient is strongest at the left edge and fades rightwards.
+			y := b.Min.Y + b.Dy()/2
+			left := color.RGBAModel.Convert(got.At(b.Min.X, y)).(color.RGBA)
+			if want := uint8(float64(light.R) * (1 - strength)); left.R < want-1 || left.R > want+1 {
+				t.Errorf("left edge = %v, want R %d", left, want)
+			}
+			prev := left.R
+			for x := b.Min.X + 1; x < edge; x++ {
+				p := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+				if p.R < prev {
+					t.Fatalf("gradient darkens again at x=%d: %d after %d", x, p.R, prev)
+				}
+				prev = p.R
+			}
+		})
+	}
+}
+
+func TestDimIconRegionUntouchedSource(t *testing.T) {
+	img := framed(40, 20, 0, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	before := append([]uint8(nil), img.Pix...)
+	DimIconRegion(img, 0.5, 4.5, 0.4)
+	if string(before) != string(img.Pix) {
+		t.Error("DimIconRegion modified its input")
+	}
+}
+
+func TestLuma(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		want float64
+	}{
+		{color.Black, 0},
+		{color.White, 255},
+		{color.RGBA{128, 128, 128, 255}, 128},
+		{color.RGBA{255, 0, 0, 255}, 0.299 * 255},
+		{color.RGBA{0, 255, 0, 255}, 0.587 * 255},
+		{color.RGBA{0, 0, 255, 255}, 0.114 * 255},
+	}
+	for _, tt := range tests {
+		if got := Luma(tt.c); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Luma(%v) = %g, want %g", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestMeanLuminance(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	gray := color.RGBA{128, 128, 128, 255}
+	// halves is white on the left half and black on the right.
+	halves := framed(16, 16, 0, black, black)
+	FillRect(halves, image.Rect(0, 0, 8, 16), white)
+	tests := []struct {
+		name string
+		img  image.Image
+		want float64
+	}{
+		{"black", framed(32, 18, 0, black, black), 0},
+		{"white", framed(32, 18, 0, white, white), 1},
+		{"mid-gray", framed(32, 18, 0, gray, gray), 128.0 / 255},
+		{"halves", halves, 0.5},
+		{"origin not at zero", framedAt(image.Pt(-13, 7), 32, 18, 0, gray, gray), 128.0 / 255},
+		{"one pixel wide", framed(1, 9, 0, white, white), 1},
+		{"one pixel tall", framedAt(image.Pt(5, -3), 9, 1, 0, gray, gray), 128.0 / 255},
+		{"empty", image.NewRGBA(image.Rect(4, 4, 4, 4)), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MeanLuminance(tt.img); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("MeanLuminance = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhiteTextContrast(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	mid := color.RGBA{119, 119, 119, 255} // sRGB gray with 4.5:1 against white
+	tests := []struct {
+		name string
+		img  image.Image
+		r    image.Rectangle
+		want float64
+	}{
+		{"black", framed(16, 16, 0, black, black), image.Rect(0, 0, 16, 16), 21},
+		{"white", framed(16, 16, 0, white, white), image.Rect(0, 0, 16, 16), 1},
+		{"gray", framedAt(image.Pt(-8, 3), 16, 16, 0, mid, mid), image.Rect(-8, 3, 0, 19), 4.48},
+		{"empty region", framed(16, 16, 0, white, white), image.Rect(4, 0, 4, 16), 21},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := whiteTextContrast(tt.img, tt.r); math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("whiteTextContrast = %.3f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
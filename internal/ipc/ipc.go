@@ -0,0 +1,160 @@
+// Package ipc is the named pipe through which CLI commands such as
+// "go-wallpaper-tray change" control the running instance, which is the only
+// way to reach it when the tray icon can't be shown.
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipePrefix is followed by the session ID, so each signed-in user (and each
+// Remote Desktop session) gets a pipe of its own rather than the first
+// instance on the machine answering for everyone. Remote clients are
+// rejected.
+const pipePrefix = `\\.\pipe\GoWallpaperTray-`
+
+const (
+	pipeBufferSize = 4096
+	maxCommandLen  = 256
+	maxReplyLen    = 64 << 10
+)
+
+// ErrNotRunning is returned by Send when no instance is listening.
+var ErrNotRunning = errors.New("go-wallpaper-tray is not running")
+
+// Handler answers one command. after, if not nil, runs once the reply has
+// been delivered, for commands such as "exit" that end the process.
+type Handler func(cmd string) (reply string, after func())
+
+// PipeName is the pipe of the current session.
+func PipeName() (string, error) {
+	var session uint32
+	if err := windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &session); err != nil {
+		return "", fmt.Errorf("session id: %w", err)
+	}
+	return pipeNameFor(session), nil
+}
+
+func pipeNameFor(session uint32) string {
+	return pipePrefix + strconv.FormatUint(uint64(session), 10)
+}
+
+// pipeSDDL grants sid, and no one else, full access. Without it the pipe gets
+// the default DACL, which lets other users and services send commands.
+func pipeSDDL(sid string) string {
+	return "D:P(A;;GA;;;" + sid + ")"
+}
+
+// ownerOnly returns security attributes restricting the pipe to the user
+// running this process.
+func ownerOnly() (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, fmt.Errorf("token user: %w", err)
+	}
+	sd, err := windows.SecurityDescriptorFromString(pipeSDDL(user.User.Sid.String()))
+	if err != nil {
+		return nil, fmt.Errorf("pipe security descriptor: %w", err)
+	}
+	sa := &windows.SecurityAttributes{SecurityDescriptor: sd}
+	sa.Length = uint32(unsafe.Sizeof(*sa))
+	return sa, nil
+}
+
+// Serve answers commands on PipeName until ctx is done. It fails at once if
+// another instance in this session already owns the pipe.
+func Serve(ctx context.Context, h Handler) error {
+	pipe, err := PipeName()
+	if err != nil {
+		return err
+	}
+	name, err := windows.UTF16PtrFromString(pipe)
+	if err != nil {
+		return err
+	}
+	sa, err := ownerOnly()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		// Unblock the pending ConnectNamedPipe.
+		if f, err := os.OpenFile(pipe, os.O_RDWR, 0); err == nil {
+			f.Close()
+		}
+	}()
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_FIRST_PIPE_INSTANCE)
+	for {
+		p, err := windows.CreateNamedPipe(name, flags,
+			windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+			windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, sa)
+		if err != nil {
+			return fmt.Errorf("create pipe %s: %w", pipe, err)
+		}
+		flags &^= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+		if err := windows.ConnectNamedPipe(p, nil); err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+			windows.CloseHandle(p)
+			return fmt.Errorf("connect pipe: %w", err)
+		}
+		if ctx.Err() != nil {
+			windows.CloseHandle(p)
+			return nil
+		}
+		go serveConn(p, pipe, h)
+	}
+}
+
+func serveConn(p windows.Handle, pipe string, h Handler) {
+	f := os.NewFile(uintptr(p), pipe)
+	line, err := bufio.NewReader(io.LimitReader(f, maxCommandLen)).ReadString('\n')
+	if err != nil && line == "" {
+		f.Close()
+		return
+	}
+	reply, after := h(strings.TrimSpace(line))
+	if _, err := io.WriteString(f, strings.TrimRight(reply, "\n")+"\n"); err != nil {
+		fmt.Println("ipc: failed to reply:", err)
+	}
+	// Let the client read the reply before the handle goes.
+	windows.FlushFileBuffers(p)
+	f.Close()
+	if after != nil {
+		after()
+	}
+}
+
+// Send delivers cmd to the instance running in this session and returns its
+// reply.
+func Send(cmd string) (string, error) {
+	pipe, err := PipeName()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(pipe, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotRunning
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, cmd+"\n"); err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(io.LimitReader(f, maxReplyLen))
+	if err != nil && !errors.Is(err, windows.ERROR_BROKEN_PIPE) {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n"), nil
+}
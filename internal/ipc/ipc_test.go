@@ -0,0 +1,55 @@
+package ipc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeNameFor(t *testing.T) {
+	tests := []struct {
+		session uint32
+		want    string
+	}{
+		{0, `\\.\pipe\GoWallpaperTray-0`},
+		{1, `\\.\pipe\GoWallpaperTray-1`},
+		{4294967295, `\\.\pipe\GoWallpaperTray-4294967295`},
+	}
+	for _, tt := range tests {
+		if got := pipeNameFor(tt.session); got != tt.want {
+			t.Errorf("pipeNameFor(%d) = %q, want %q", tt.session, got, tt.want)
+		}
+	}
+}
+
+func TestPipeSDDL(t *testing.T) {
+	const sid = "S-1-5-21-1004336348-1177238915-682003330-512"
+	got := pipeSDDL(sid)
+	// Protected, so no inherited ACEs, and a single allow ACE for sid.
+	if !strings.HasPrefix(got, "D:P(") || strings.Count(got, "(") != 1 || !strings.Contains(got, ";;;"+sid+")") {
+		t.Errorf("pipeSDDL = %q, want one protected ACE for %s", got, sid)
+	}
+}
+
+func TestServeSend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, func(cmd string) (string, func()) { return "got " + cmd, nil })
+	}()
+	var reply string
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if reply, err = Send("status"); err != ErrNotRunning {
+			break
+		}
+	}
+	if err != nil || reply != "got status" {
+		t.Errorf("Send = %q, %v; want %q", reply, err, "got status")
+	}
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package logging prints warnings and errors, folding repeats of the same
+// message so a retry loop failing all night doesn't bury everything else.
+// Informational lines are plain fmt.Println calls and are never folded.
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Window is how long identical messages are folded after the first one.
+const Window = 10 * time.Minute
+
+// debugFlushEvery caps how often debug lines are written out: a change logs
+// dozens of them, and with the output redirected to a file on a busy hard
+// disk each write could hold up the change.
+const debugFlushEvery = time.Second
+
+type key struct{ level, category, msg string }
+
+// repeat tracks a message seen again within its window.
+type repeat struct {
+	count int // repeats after the first, not yet printed
+	timer *time.Timer
+}
+
+// Logger folds identical (level, category, message) lines: the first is
+// printed at once, repeats within the window are counted, and when the
+// window ends the count is printed followed by the last repeat in full.
+type Logger struct {
+	out    io.Writer
+	window time.Duration
+
+	mu      sync.Mutex
+	repeats map[key]*repeat
+	// debugBuf holds DEBUG lines until debugFlush fires or another level
+	// is printed, which keeps the lines in order.
+	debugBuf   *bufio.Writer
+	debugFlush *time.Timer
+}
+
+// New returns a Logger writing to out.
+func New(out io.Writer, window time.Duration) *Logger {
+	return &Logger{out: out, window: window, repeats: map[key]*repeat{}, debugBuf: bufio.NewWriter(out)}
+}
+
+var std = New(os.Stdout, Window)
+
+// Warnf logs a warning under category, e.g. a source name.
+func Warnf(category, format string, args ...any) { std.Log("WARN", category, format, args...) }
+
+// Errorf logs an error under category.
+func Errorf(category, format string, args ...any) { std.Log("ERROR", category, format, args...) }
+
+var debug atomic.Bool
+
+// SetDebug turns Debugf output on or off.
+func SetDebug(on bool) { debug.Store(on) }
+
+// Debugf logs a detail useful when reporting a problem under category, only
+// while SetDebug is on.
+func Debugf(category, format string, args ...any) {
+	if debug.Load() {
+		std.Log("DEBUG", category, format, args...)
+	}
+}
+
+// Flush prints the counts of folded messages and any buffered debug lines
+// now, as on exit.
+func Flush() { std.Flush() }
+
+// Log prints or folds one line. Only warnings and errors are folded; debug
+// lines are the detail of a single change and are always printed.
+func (l *Logger) Log(level, category, format string, args ...any) {
+	k := key{level, category, fmt.Sprintf(format, args...)}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level == "DEBUG" {
+		l.print(k)
+		return
+	}
+	if r, ok := l.repeats[k]; ok {
+		r.count++
+		return
+	}
+	l.print(k)
+	l.repeats[k] = &repeat{timer: time.AfterFunc(l.window, func() { l.expire(k) })}
+}
+
+// expire ends k's window.
+func (l *Logger) expire(k key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.repeats[k]; ok {
+		l.finish(k, r)
+	}
+}
+
+// Flush ends every open window and writes out buffered debug lines.
+func (l *Logger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, r := range l.repeats {
+		r.timer.Stop()
+		l.finish(k, r)
+	}
+	l.flushDebug()
+}
+
+// flushDebug writes out buffered debug lines. l.mu must be held.
+func (l *Logger) flushDebug() {
+	if l.debugFlush != nil {
+		l.debugFlush.Stop()
+		l.debugFlush = nil
+	}
+	l.debugBuf.Flush()
+}
+
+// finish prints what k's window folded and forgets it. l.mu must be held.
+func (l *Logger) finish(k key, r *repeat) {
+	delete(l.repeats, k)
+	if r.count == 0 {
+		return
+	}
+	if r.count > 1 {
+		fmt.Fprintf(l.writer(k.level), "%s %s: previous message repeated %d times\n", k.level, k.category, r.count-1)
+	}
+	l.print(k)
+}
+
+func (l *Logger) print(k key) {
+	fmt.Fprintf(l.writer(k.level), "%s %s: %s\n", k.level, k.category, k.msg)
+}
+
+// writer returns where a line of level goes: DEBUG lines are buffered and
+// written out at most every debugFlushEvery, other lines are written at
+// once, after the buffered ones. l.mu must be held.
+func (l *Logger) writer(level string) io.Writer {
+	if level != "DEBUG" {
+		l.flushDebug()
+		return l.out
+	}
+	if l.debugFlush == nil {
+		l.debugFlush = time.AfterFunc(debugFlushEvery, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.flushDebug()
+		})
+	}
+	return l.debugBuf
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is written from the window timers and read by the test.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
+
+func TestFold(t *testing.T) {
+	refused := "dial tcp: connection refused"
+	tests := []struct {
+		name  string
+		level string
+		times int
+		want  []string
+	}{
+		{"once", "WARN", 1, []string{"WARN net: " + refused}},
+		{"twice", "WARN", 2, []string{"WARN net: " + refused, "WARN net: " + refused}},
+		{"many", "ERROR", 49, []string{
+			"ERROR net: " + refused,
+			"ERROR net: previous message repeated 47 times",
+			"ERROR net: " + refused,
+		}},
+		{"debug is not folded", "DEBUG", 3, []string{
+			"DEBUG net: " + refused, "DEBUG net: " + refused, "DEBUG net: " + refused,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out lockedBuffer
+			l := New(&out, time.Hour)
+			for range tt.times {
+				l.Log(tt.level, "net", "%s", refused)
+			}
+			l.Flush()
+			if got := out.lines(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("logged\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFoldKeys checks that level, category and message each count as
+// distinct, and that the first of each prints at once.
+func TestFoldKeys(t *testing.T) {
+	var out lockedBuffer
+	l := New(&out, time.Hour)
+	l.Log("WARN", "a", "x")
+	l.Log("ERROR", "a", "x")
+	l.Log("WARN", "b", "x")
+	l.Log("WARN", "a", "y")
+	l.Log("WARN", "a", "x")
+	want := []string{"WARN a: x", "ERROR a: x", "WARN b: x", "WARN a: y"}
+	if got := out.lines(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("before the window ends logged %q, want %q", got, want)
+	}
+	l.Flush()
+	if got := out.lines(); len(got) != 5 || got[4] != "WARN a: x" {
+		t.Errorf("after Flush logged %q, want the repeat last", got)
+	}
+}
+
+// TestWindowExpires checks that a window ends on its own, and that the same
+// message afterwards opens a new one and prints at once.
+func TestWindowExpires(t *testing.T) {
+	var out lockedBuffer
+	const window = 20 * time.Millisecond
+	l := New(&out, window)
+	for range 5 {
+		l.Log("WARN", "net", "down")
+	}
+	want := []string{"WARN net: down", "WARN net: previous message repeated 3 times", "WARN net: down"}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(out.lines()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(window)
+	}
+	if got := out.lines(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("after the window logged %q, want %q", got, want)
+	}
+	l.Log("WARN", "net", "down")
+	if got := out.lines(); len(got) != 4 || got[3] != "WARN net: down" {
+		t.Errorf("after a new first occurrence logged %q", got)
+	}
+	l.Flush()
+}
+
+// sleepyWriter is a slow disk: every Write takes delay.
+type sleepyWriter struct {
+	lockedBuffer
+	delay  time.Duration
+	writes int
+}
+
+func (w *sleepyWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	w.writes++
+	w.mu.Unlock()
+	return w.lockedBuffer.Write(p)
+}
+
+// TestDebugSlowWriter checks that a change's debug lines don't wait for a
+// slow output and reach it together, in order, before the next warning.
+func TestDebugSlowWriter(t *testing.T) {
+	out := &sleepyWriter{delay: 20 * time.Millisecond}
+	l := New(out, Window)
+	start := time.Now()
+	for i := range 30 {
+		l.Log("DEBUG", "change", "step %d", i)
+	}
+	if took := time.Since(start); took >= out.delay {
+		t.Errorf("30 debug lines took %s, want them not to wait for the %s writer", took, out.delay)
+	}
+	l.Log("WARN", "net", "down")
+	got := out.lines()
+	if len(got) != 31 || got[0] != "DEBUG change: step 0" || got[29] != "DEBUG change: step 29" || got[30] != "WARN net: down" {
+		t.Fatalf("logged %q", got)
+	}
+	out.mu.Lock()
+	writes := out.writes
+	out.mu.Unlock()
+	if writes != 2 {
+		t.Errorf("%d writes, want the debug lines in one and the warning in another", writes)
+	}
+	l.Flush()
+}
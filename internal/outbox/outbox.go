@@ -0,0 +1,263 @@
+// Package outbox queues outbound notifications (webhooks) on disk so events
+// raised while offline are delivered, in order, once the network is back.
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// FileName is the JSON lines queue inside the app dir.
+	FileName = "outbox.jsonl"
+
+	// maxEvents caps the queue; the oldest events are dropped beyond it.
+	maxEvents = 200
+	// maxAge expires events nobody could deliver for this long.
+	maxAge = 72 * time.Hour
+
+	retryInterval = 30 * time.Second
+	maxBackoff    = 15 * time.Minute
+	// sentMemory is how many delivered IDs are remembered to suppress
+	// duplicates when retries overlap.
+	sentMemory = 500
+)
+
+// Event is one outbound notification.
+type Event struct {
+	ID   string            `json:"id"`
+	Type string            `json:"type"`
+	Time time.Time         `json:"time"`
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// NewEvent returns an event with a fresh random ID.
+func NewEvent(typ string, now time.Time, data map[string]string) Event {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return Event{ID: hex.EncodeToString(b), Type: typ, Time: now, Data: data}
+}
+
+// Sender delivers one event; an error leaves it queued for a retry.
+type Sender func(ctx context.Context, e Event) error
+
+// Outbox is the persistent queue. Events are kept in memory while the app
+// dir is unavailable and written out once it is back.
+type Outbox struct {
+	dir  func() string
+	send Sender
+	now  func() time.Time
+	wake chan struct{}
+
+	// flushing serializes flushes; mu is not held while sending, so a slow
+	// receiver doesn't block Enqueue.
+	flushing sync.Mutex
+
+	mu     sync.Mutex
+	mem    []Event // not yet on disk
+	sent   map[string]bool
+	sentID []string // delivery order, to forget the oldest
+}
+
+// New returns an Outbox storing its queue in the dir returned by dir.
+func New(dir func() string, send Sender, now func() time.Time) *Outbox {
+	return &Outbox{dir: dir, send: send, now: now, wake: make(chan struct{}, 1), sent: map[string]bool{}}
+}
+
+// Enqueue queues e and wakes the flusher.
+func (o *Outbox) Enqueue(e Event) {
+	o.mu.Lock()
+	o.mem = append(o.mem, e)
+	if err := o.persistLocked(); err != nil {
+		fmt.Println("outbox: keeping events in memory:", err)
+	}
+	o.mu.Unlock()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run flushes the queue whenever an event is queued and retries with
+// backoff while delivery fails, until ctx is done.
+func (o *Outbox) Run(ctx context.Context) {
+	backoff := retryInterval
+	for {
+		wait := retryInterval
+		if err := o.Flush(ctx); err != nil {
+			logging.Warnf("outbox", "delivery failed, retrying in %s: %v", backoff, err)
+			wait = backoff
+			backoff = min(backoff*2, maxBackoff)
+		} else {
+			backoff = retryInterval
+		}
+		select {
+		case <-o.wake:
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush delivers queued events oldest first, stopping at the first failure
+// so order is kept. Expired events and IDs already delivered are dropped.
+// Events queued during the flush wait for the next one.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.flushing.Lock()
+	defer o.flushing.Unlock()
+
+	o.mu.Lock()
+	_ = o.persistLocked()
+	queue, err := o.loadLocked()
+	var pending []Event
+	for _, e := range queue {
+		if !o.sent[e.ID] {
+			pending = append(pending, e)
+		}
+	}
+	o.mu.Unlock()
+	if err != nil || len(queue) == 0 {
+		return err
+	}
+
+	var delivered []string
+	var sendErr error
+	for _, e := range pending {
+		if sendErr = o.send(ctx, e); sendErr != nil {
+			break
+		}
+		delivered = append(delivered, e.ID)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range delivered {
+		o.rememberLocked(id)
+	}
+	// Reload: events may have been queued while sending.
+	_ = o.persistLocked()
+	if queue, err = o.loadLocked(); err != nil {
+		return err
+	}
+	rest := queue[:0]
+	for _, e := range queue {
+		if !o.sent[e.ID] {
+			rest = append(rest, e)
+		}
+	}
+	if err := o.writeLocked(rest); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+func (o *Outbox) rememberLocked(id string) {
+	o.sent[id] = true
+	o.sentID = append(o.sentID, id)
+	if len(o.sentID) > sentMemory {
+		delete(o.sent, o.sentID[0])
+		o.sentID = o.sentID[1:]
+	}
+}
+
+func (o *Outbox) path() (string, error) {
+	dir := o.dir()
+	if dir == "" {
+		return "", errors.New("app dir not resolved yet")
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// persistLocked moves the in-memory events to the file.
+func (o *Outbox) persistLocked() error {
+	if len(o.mem) == 0 {
+		return nil
+	}
+	queue, err := o.loadLocked()
+	if err != nil {
+		return err
+	}
+	if err := o.writeLocked(append(queue, o.mem...)); err != nil {
+		return err
+	}
+	o.mem = nil
+	return nil
+}
+
+// loadLocked reads the file, dropping expired events, duplicate IDs and
+// unreadable lines.
+func (o *Outbox) loadLocked() ([]Event, error) {
+	path, err := o.path()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cutoff := o.now().Add(-maxAge)
+	seen := map[string]bool{}
+	var out []Event
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.ID == "" {
+			continue
+		}
+		if seen[e.ID] || e.Time.Before(cutoff) {
+			continue
+		}
+		seen[e.ID] = true
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// writeLocked replaces the file with queue, keeping the newest maxEvents.
+func (o *Outbox) writeLocked(queue []Event) error {
+	path, err := o.path()
+	if err != nil {
+		return err
+	}
+	if len(queue) > maxEvents {
+		fmt.Printf("outbox: full, dropping %d oldest events\n", len(queue)-maxEvents)
+		queue = queue[len(queue)-maxEvents:]
+	}
+	if len(queue) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var buf bytes.Buffer
+	for _, e := range queue {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, buf.Bytes())
+}
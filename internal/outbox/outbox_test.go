@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver records delivered event IDs; while down it fails every send.
+type receiver struct {
+	mu   sync.Mutex
+	down bool
+	got  []string
+	// failAfter fails once this many more events have been delivered;
+	// negative never.
+	failAfter int
+}
+
+func (r *receiver) send(ctx context.Context, e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down || r.failAfter == 0 {
+		return errors.New("network is unreachable")
+	}
+	r.failAfter--
+	r.got = append(r.got, e.ID)
+	return nil
+}
+
+func (r *receiver) setDown(down bool) {
+	r.mu.Lock()
+	r.down = down
+	r.mu.Unlock()
+}
+
+func (r *receiver) delivered() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.got...)
+}
+
+var start = time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+
+// event returns an event with a readable ID, i minutes after start.
+func event(i int) Event {
+	return Event{ID: fmt.Sprintf("e%03d", i), Type: "wallpaper_changed", Time: start.Add(time.Duration(i) * time.Minute)}
+}
+
+func ids(from, to int) []string {
+	var out []string
+	for i := from; i < to; i++ {
+		out = append(out, event(i).ID)
+	}
+	return out
+}
+
+func newTest(t *testing.T, r *receiver) (*Outbox, string) {
+	t.Helper()
+	dir := t.TempDir()
+	r.failAfter = -1
+	return New(func() string { return dir }, r.send, func() time.Time { return start.Add(time.Hour) }), dir
+}
+
+func TestOfflineThenFlushInOrder(t *testing.T) {
+	r := &receiver{}
+	o, dir := newTest(t, r)
+	r.setDown(true)
+	for i := range 5 {
+		o.Enqueue(event(i))
+		if err := o.Flush(context.Background()); err == nil {
+			t.Fatal("Flush succeeded while offline")
+		}
+	}
+	if len(r.delivered()) != 0 {
+		t.Fatalf("delivered %v while offline", r.delivered())
+	}
+
+	// A restart keeps the queue.
+	o = New(func() string { return dir }, r.send, func() time.Time { return start.Add(time.Hour) })
+	r.setDown(false)
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := r.delivered(); !slices.Equal(got, ids(0, 5)) {
+		t.Errorf("delivered %v, want %v", got, ids(0, 5))
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("queue file left after a full flush: %v", err)
+	}
+}
+
+func TestFlushStopsAtFailureAndRetries(t *testing.T) {
+	r := &receiver{}
+	o, _ := newTest(t, r)
+	r.setDown(true)
+	for i := range 6 {
+		o.Enqueue(event(i))
+	}
+	r.setDown(false)
+	r.failAfter = 2
+	if err := o.Flush(context.Background()); err == nil {
+		t.Fatal("Flush hid the failure")
+	}
+	if got := r.delivered(); !slices.Equal(got, ids(0, 2)) {
+		t.Fatalf("delivered %v before the failure, want %v", got, ids(0, 2))
+	}
+	r.failAfter = -1
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if got := r.delivered(); !slices.Equal(got, ids(0, 6)) {
+		t.Errorf("delivered %v after the retry, want each once in order", got)
+	}
+}
+
+func TestDuplicatesSuppressed(t *testing.T) {
+	r := &receiver{}
+	o, _ := newTest(t, r)
+	o.Enqueue(event(0))
+	o.Enqueue(event(1))
+	o.Enqueue(event(0)) // an overlapping retry
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	o.Enqueue(event(1)) // already delivered
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.delivered(); !slices.Equal(got, ids(0, 2)) {
+		t.Errorf("delivered %v, want %v", got, ids(0, 2))
+	}
+}
+
+func TestCapAndExpiry(t *testing.T) {
+	r := &receiver{}
+	o, _ := newTest(t, r)
+	r.setDown(true)
+	old := event(0)
+	old.Time = start.Add(-maxAge)
+	o.Enqueue(old)
+	for i := 1; i <= maxEvents+10; i++ {
+		o.Enqueue(event(i))
+	}
+	r.setDown(false)
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := ids(11, maxEvents+11)
+	if got := r.delivered(); !slices.Equal(got, want) {
+		t.Errorf("delivered %d events, want the newest %d from %s", len(got), maxEvents, want[0])
+	}
+}
+
+func TestQueuedWithoutAppDir(t *testing.T) {
+	r := &receiver{failAfter: -1}
+	var dir string
+	o := New(func() string { return dir }, r.send, func() time.Time { return start })
+	o.Enqueue(event(0))
+	if err := o.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded without an app dir")
+	}
+	dir = t.TempDir()
+	o.Enqueue(event(1))
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.delivered(); !slices.Equal(got, ids(0, 2)) {
+		t.Errorf("delivered %v, want %v", got, ids(0, 2))
+	}
+}
+
+func TestEnqueueDuringSlowSend(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	send := func(ctx context.Context, e Event) error {
+		if e.ID == event(0).ID {
+			close(entered)
+			<-release
+		}
+		mu.Lock()
+		got = append(got, e.ID)
+		mu.Unlock()
+		return nil
+	}
+	dir := t.TempDir()
+	o := New(func() string { return dir }, send, func() time.Time { return start })
+	o.Enqueue(event(0))
+	flushed := make(chan error)
+	go func() { flushed <- o.Flush(context.Background()) }()
+	<-entered
+
+	queued := make(chan struct{})
+	go func() {
+		o.Enqueue(event(1))
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enqueue blocked behind a send")
+	}
+	close(release)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, ids(0, 2)) {
+		t.Errorf("delivered %v, want %v", got, ids(0, 2))
+	}
+}
+
+func TestRunRetriesUntilBack(t *testing.T) {
+	r := &receiver{}
+	o, _ := newTest(t, r)
+	r.setDown(true)
+	o.Enqueue(event(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+	r.setDown(false)
+	o.Enqueue(event(1)) // wakes the flusher
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(r.delivered(), ids(0, 2)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %v, want %v", r.delivered(), ids(0, 2))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
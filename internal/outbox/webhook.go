@@ -0,0 +1,52 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sendTimeout bounds one delivery, so a receiver that accepts the
+// connection and never answers can't stall the queue.
+const sendTimeout = 30 * time.Second
+
+// Webhook returns a Sender that POSTs each event as JSON to the URL url
+// returns; with no URL configured events are discarded as delivered. It
+// uses client's transport with a timeout of at most sendTimeout.
+func Webhook(client *http.Client, url func() string) Sender {
+	c := *client
+	if c.Timeout == 0 || c.Timeout > sendTimeout {
+		c.Timeout = sendTimeout
+	}
+	return func(ctx context.Context, e Event) error {
+		u := url()
+		if u == "" {
+			return nil
+		}
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// Receivers can drop retries they already processed.
+		req.Header.Set("Idempotency-Key", e.ID)
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var got Event
+	var key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("Idempotency-Key")
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Type == "reject" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	send := Webhook(srv.Client(), func() string { return srv.URL })
+	e := event(3)
+	if err := send(context.Background(), e); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got.ID != e.ID || key != e.ID {
+		t.Errorf("received %+v with key %q, want %s", got, key, e.ID)
+	}
+	e.Type = "reject"
+	if err := send(context.Background(), e); err == nil {
+		t.Error("a 502 counted as delivered")
+	}
+	if err := Webhook(srv.Client(), func() string { return "" })(context.Background(), e); err != nil {
+		t.Errorf("no URL: %v", err)
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-hang }))
+	defer srv.Close()
+	defer close(hang)
+
+	client := srv.Client()
+	client.Timeout = 50 * time.Millisecond // below sendTimeout, so kept
+	done := make(chan error)
+	go func() { done <- Webhook(client, func() string { return srv.URL })(context.Background(), event(0)) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("a hung receiver counted as delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send did not time out")
+	}
+}
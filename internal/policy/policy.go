@@ -0,0 +1,97 @@
+// Package policy reads the administrator kill switch. Policy always wins
+// over config.json and the tray menu: no setting can re-enable what it
+// disables.
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Level is how much the policy disables.
+type Level int
+
+const (
+	// None leaves everything enabled.
+	None Level = iota
+	// Automatic stops scheduled and triggered changes; the user can still
+	// change the wallpaper from the tray.
+	Automatic
+	// Full stops the user's changes too.
+	Full
+)
+
+const (
+	// FileName in the app dir disables changes; a file reading "full"
+	// disables manual ones as well.
+	FileName = "DISABLE"
+	// RegistryPath holds the machine-wide policy value RegistryValue: the
+	// string "automatic" or "full", or a non-zero DWORD for "automatic".
+	RegistryPath  = `Software\Policies\GoWallpaperTray`
+	RegistryValue = "DisableChanges"
+)
+
+// ErrDisabled is returned for changes the policy blocks.
+var ErrDisabled = errors.New("disabled by policy")
+
+func (l Level) String() string {
+	switch l {
+	case Automatic:
+		return "automatic"
+	case Full:
+		return "full"
+	}
+	return "none"
+}
+
+// Current returns the stricter of the registry policy and the DISABLE file
+// in appDir. It is cheap enough to call before every change.
+func Current(appDir string) Level {
+	return max(registryLevel(), fileLevel(appDir))
+}
+
+func fileLevel(appDir string) Level {
+	if appDir == "" {
+		return None
+	}
+	b, err := os.ReadFile(filepath.Join(appDir, FileName))
+	if err != nil {
+		return None
+	}
+	return parseLevel(string(b))
+}
+
+// registryLevel reads the machine policy; tests replace it.
+var registryLevel = readRegistryLevel
+
+func readRegistryLevel() Level {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, RegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return None
+	}
+	defer k.Close()
+	if s, _, err := k.GetStringValue(RegistryValue); err == nil {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "", "0", "none":
+			return None
+		}
+		return parseLevel(s)
+	}
+	if v, _, err := k.GetIntegerValue(RegistryValue); err == nil && v != 0 {
+		return Automatic
+	}
+	return None
+}
+
+// parseLevel reads "full" as Full and anything else, including nothing, as
+// Automatic: the switch exists, so something is disabled.
+func parseLevel(s string) Level {
+	if strings.EqualFold(strings.TrimSpace(s), "full") {
+		return Full
+	}
+	return Automatic
+}
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCurrent(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     *string // DISABLE's content, nil for no file
+		registry Level
+		want     Level
+	}{
+		{"nothing", nil, None, None},
+		{"empty file", ptr(""), None, Automatic},
+		{"file full", ptr(" FULL\r\n"), None, Full},
+		{"file other text", ptr("please stop"), None, Automatic},
+		{"registry automatic", nil, Automatic, Automatic},
+		{"registry full", nil, Full, Full},
+		{"registry stricter", ptr(""), Full, Full},
+		{"file stricter", ptr("full"), Automatic, Full},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := registryLevel
+			registryLevel = func() Level { return tt.registry }
+			defer func() { registryLevel = old }()
+			dir := t.TempDir()
+			if tt.file != nil {
+				if err := os.WriteFile(filepath.Join(dir, FileName), []byte(*tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := Current(dir); got != tt.want {
+				t.Errorf("Current = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentWithoutAppDir(t *testing.T) {
+	old := registryLevel
+	registryLevel = func() Level { return Automatic }
+	defer func() { registryLevel = old }()
+	if got := Current(""); got != Automatic {
+		t.Errorf("Current(\"\") = %v, want the registry's %v", got, Automatic)
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	for l, want := range map[Level]string{None: "none", Automatic: "automatic", Full: "full"} {
+		if l.String() != want {
+			t.Errorf("%d.String() = %q, want %q", l, l.String(), want)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
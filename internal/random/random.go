@@ -0,0 +1,85 @@
+// Package random is the app's one source of randomness. Every choice, from
+// the weighted source draw to backoff jitter and shuffle bags, goes through
+// it, so a run seeded like an earlier one makes the same choices.
+package random
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	mu   sync.Mutex
+	seed = cryptoSeed()
+	rng  = rand.New(rand.NewSource(seed))
+)
+
+// cryptoSeed returns an unpredictable default seed.
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// Seed restarts the sequence from s.
+func Seed(s int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	seed = s
+	rng = rand.New(rand.NewSource(s))
+}
+
+// CurrentSeed returns the seed the sequence started from, to replay it.
+func CurrentSeed() int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return seed
+}
+
+// Intn is rand.Intn on the app's source.
+func Intn(n int) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Intn(n)
+}
+
+// Int63 is rand.Int63 on the app's source.
+func Int63() int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Int63()
+}
+
+// Int63n is rand.Int63n on the app's source.
+func Int63n(n int64) int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Int63n(n)
+}
+
+// Float64 is rand.Float64 on the app's source.
+func Float64() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Float64()
+}
+
+// Perm is rand.Perm on the app's source.
+func Perm(n int) []int {
+	mu.Lock()
+	defer mu.Unlock()
+	return rng.Perm(n)
+}
+
+// Shuffle is rand.Shuffle on the app's source; swap must not call back
+// into this package.
+func Shuffle(n int, swap func(i, j int)) {
+	mu.Lock()
+	defer mu.Unlock()
+	rng.Shuffle(n, swap)
+}
@@ -0,0 +1,46 @@
+package random
+
+import (
+	"slices"
+	"testing"
+)
+
+// draws makes one of each kind of choice the app makes and returns them in
+// order, so two runs can be compared.
+func draws() []int64 {
+	var out []int64
+	for range 5 {
+		out = append(out, int64(Intn(100)), Int63(), Int63n(1000), int64(Float64()*1e9))
+	}
+	for _, p := range Perm(8) {
+		out = append(out, int64(p))
+	}
+	bag := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	Shuffle(len(bag), func(i, j int) { bag[i], bag[j] = bag[j], bag[i] })
+	return append(out, bag...)
+}
+
+func TestSeedReplays(t *testing.T) {
+	saved := CurrentSeed()
+	t.Cleanup(func() { Seed(saved) })
+
+	Seed(42)
+	first := draws()
+	Seed(42)
+	if again := draws(); !slices.Equal(first, again) {
+		t.Errorf("seed 42 drew\n%v\nthen\n%v", first, again)
+	}
+	if got := CurrentSeed(); got != 42 {
+		t.Errorf("CurrentSeed = %d, want 42", got)
+	}
+	Seed(43)
+	if other := draws(); slices.Equal(first, other) {
+		t.Error("seeds 42 and 43 drew the same")
+	}
+}
+
+func TestDefaultSeedsDiffer(t *testing.T) {
+	if a, b := cryptoSeed(), cryptoSeed(); a == b {
+		t.Errorf("two default seeds are both %d", a)
+	}
+}
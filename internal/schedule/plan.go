@@ -0,0 +1,111 @@
+package schedule
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/policy"
+	"wallpaper-changer/internal/source"
+)
+
+// PreviewLength is how many upcoming changes the schedule preview lists.
+const PreviewLength = 7
+
+// State is what, besides the config, decides the upcoming changes.
+type State struct {
+	// UpdatedToday reports whether today's change already happened.
+	UpdatedToday bool
+	Policy       policy.Level
+	// Running reports whether the Worker is running. It only catches up
+	// at startup, so a change missed while it runs, such as after
+	// change_time moved earlier, waits for the next change time.
+	Running bool
+}
+
+// Planned is one upcoming change.
+type Planned struct {
+	At time.Time
+	// Source names the source the change will use, or the odds of each
+	// with weighted_random_selection.
+	Source string
+	// Notes explain anything that moves or stops the change.
+	Notes []string
+}
+
+func (p Planned) String() string {
+	s := p.At.Format("Mon Jan 2 15:04") + "  " + p.Source
+	if len(p.Notes) > 0 {
+		s += " (" + strings.Join(p.Notes, "; ") + ")"
+	}
+	return s
+}
+
+// Plan returns the next n changes the Worker will make after now, the same
+// way it computes them. A change missed today is listed first, as the
+// catch-up the next start makes, unless the Worker is running: then it is
+// only noted on the next change.
+func Plan(cfg config.Config, st State, now time.Time, n int) []Planned {
+	h, m := cfg.ChangeClock()
+	src, refresh := plannedSource(cfg)
+	var notes []string
+	if cfg.IdleMinutes > 0 {
+		notes = append(notes, fmt.Sprintf("waits for %d min idle, at most %d min", cfg.IdleMinutes, cfg.IdleMaxWaitMinutes))
+	}
+	if refresh != "" {
+		notes = append(notes, refresh)
+	}
+	if cfg.RespectExternalChanges {
+		notes = append(notes, "skipped while another app's wallpaper is showing")
+	}
+	if st.Policy != policy.None {
+		notes = append(notes, "blocked by policy ("+st.Policy.String()+")")
+	}
+
+	var out []Planned
+	todayAt := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+	missed := !now.Before(todayAt) && !st.UpdatedToday
+	if missed && !st.Running {
+		out = append(out, Planned{At: now, Source: src,
+			Notes: append([]string{"catch-up for today's " + cfg.ChangeTime + " at the next start"}, notes...)})
+	}
+	for t := now; len(out) < n; {
+		t = NextChangeTime(t, h, m)
+		p := Planned{At: t, Source: src, Notes: notes}
+		if missed && st.Running && len(out) == 0 {
+			p.Notes = append([]string{"today's " + cfg.ChangeTime + " was missed; only a restart catches it up"}, notes...)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// plannedSource describes the source of a scheduled change and, for sources
+// redrawn during the day, how often.
+func plannedSource(cfg config.Config) (name, refresh string) {
+	if !cfg.WeightedRandomSelection {
+		if iv := source.RefreshInterval(cfg.Source); iv > 0 {
+			refresh = "redrawn every " + strings.TrimSuffix(iv.String(), "0s")
+		}
+		return cfg.Source, refresh
+	}
+	var total float64
+	names := make([]string, 0, len(cfg.SourceWeights))
+	for name, w := range cfg.SourceWeights {
+		if w > 0 {
+			total += w
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(cfg.SourceWeights[b], cfg.SourceWeights[a]), cmp.Compare(a, b))
+	})
+	odds := make([]string, len(names))
+	for i, name := range names {
+		odds[i] = fmt.Sprintf("%s %.0f%%", name, 100*cfg.SourceWeights[name]/total)
+	}
+	return "random: " + strings.Join(odds, ", "), ""
+}
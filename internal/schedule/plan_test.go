@@ -0,0 +1,154 @@
+package schedule
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/policy"
+)
+
+func TestPlan(t *testing.T) {
+	cfg := config.Default()
+	cfg.ChangeTime = "09:00"
+	cfg.Source = "wallscloud"
+	cfg.IdleMinutes = 0
+	cfg.RespectExternalChanges = false
+	at := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		name    string
+		now     time.Time
+		st      State
+		first   time.Time
+		catchUp string
+	}{
+		{"before the change time", at(14, 8, 0), State{}, at(14, 9, 0), ""},
+		{"done today", at(14, 10, 0), State{UpdatedToday: true}, at(15, 9, 0), ""},
+		{"missed, not running", at(14, 10, 0), State{}, at(14, 10, 0), "catch-up for today's 09:00 at the next start"},
+		// The running Worker doesn't catch up: the next change is tomorrow's.
+		{"missed, running", at(14, 10, 0), State{Running: true}, at(15, 9, 0), "today's 09:00 was missed; only a restart catches it up"},
+		{"done today, running", at(14, 10, 0), State{UpdatedToday: true, Running: true}, at(15, 9, 0), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Plan(cfg, tt.st, tt.now, PreviewLength)
+			if len(got) != PreviewLength {
+				t.Fatalf("planned %d changes, want %d", len(got), PreviewLength)
+			}
+			if !got[0].At.Equal(tt.first) {
+				t.Errorf("first change at %s, want %s", got[0].At, tt.first)
+			}
+			var note string
+			if len(got[0].Notes) > 0 {
+				note = got[0].Notes[0]
+			}
+			if note != tt.catchUp {
+				t.Errorf("first note %q, want %q", note, tt.catchUp)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].At.Hour() != 9 || !got[i].At.After(got[i-1].At) {
+					t.Errorf("change %d at %s after %s", i, got[i].At, got[i-1].At)
+				}
+				if got[i].Source != "wallscloud" {
+					t.Errorf("change %d source %q", i, got[i].Source)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanNotes(t *testing.T) {
+	cfg := config.Default()
+	cfg.IdleMinutes = 5
+	cfg.IdleMaxWaitMinutes = 60
+	cfg.RespectExternalChanges = true
+	now := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	got := Plan(cfg, State{Policy: policy.Automatic}, now, 1)[0].String()
+	for _, want := range []string{"waits for 5 min idle, at most 60 min", "another app's wallpaper", "blocked by policy"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q does not mention %q", got, want)
+		}
+	}
+}
+
+func TestPlanWeighted(t *testing.T) {
+	cfg := config.Default()
+	cfg.WeightedRandomSelection = true
+	cfg.SourceWeights = map[string]float64{"starfield": 1, "wallscloud": 3, "voronoi": 0}
+	now := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	if got, want := Plan(cfg, State{}, now, 1)[0].Source, "random: wallscloud 75%, starfield 25%"; got != want {
+		t.Errorf("source %q, want %q", got, want)
+	}
+}
+
+// heldClock reports each wait asked for and lets none of them pass.
+type heldClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits chan time.Duration
+}
+
+func (c *heldClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *heldClock) After(d time.Duration) <-chan time.Time {
+	c.waits <- d
+	return nil
+}
+
+func (c *heldClock) set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// TestPlanMatchesRunningWorker moves change_time into the past under a
+// running Worker and checks it waits for the change Plan lists first.
+func TestPlanMatchesRunningWorker(t *testing.T) {
+	cfg := config.Default()
+	cfg.ChangeTime = "12:00"
+	cfg.StartupDelaySeconds = 0
+	cfg.IdleMinutes = 0
+	live := config.NewLive(cfg)
+	clock := &heldClock{now: time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC), waits: make(chan time.Duration)}
+	w := &Worker{
+		Clock:        clock,
+		Config:       live,
+		UpdatedToday: func(time.Time) bool { return false },
+		Change: func() error {
+			t.Error("the running Worker changed the wallpaper")
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	wait := func() time.Duration {
+		select {
+		case d := <-clock.waits:
+			return d
+		case <-time.After(time.Second):
+			t.Fatal("the Worker waited for nothing")
+			return 0
+		}
+	}
+	if got := wait(); got != 4*time.Hour {
+		t.Fatalf("first wait %s, want until 12:00", got)
+	}
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	clock.set(now)
+	cfg.ChangeTime = "09:00"
+	live.SetLocal(cfg)
+
+	next := Plan(cfg, State{Running: true}, now, 1)[0]
+	if got := wait(); now.Add(got) != next.At {
+		t.Errorf("the Worker waits until %s, Plan lists %s first", now.Add(got), next.At)
+	}
+}
@@ -0,0 +1,177 @@
+// Package schedule decides when the daily wallpaper change runs.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/logging"
+	"wallpaper-changer/internal/policy"
+)
+
+// Clock is the time source the worker runs on.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// idlePollInterval is how often a deferred change rechecks the idle time.
+const idlePollInterval = time.Minute
+
+// retryBackoff are the waits before each retry of a failed change; after
+// the last the change waits for the next day.
+var retryBackoff = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 3 * time.Hour}
+
+// Worker triggers Change at the configured change_time every day.
+type Worker struct {
+	Clock  Clock
+	Config *config.Live
+	// UpdatedToday reports whether today's change already happened.
+	UpdatedToday func(now time.Time) bool
+	Change       func() error
+	// IdleTime reports how long the user has been idle, for idle_minutes;
+	// nil changes on time.
+	IdleTime func() time.Duration
+	// Deferred is told when a change waits for the user to go idle, with
+	// the latest time it will run, and with the zero time once it ran. It
+	// may be nil.
+	Deferred func(deadline time.Time)
+	// Failed is told about a change that failed even after the retries. It
+	// may be nil.
+	Failed func(err error)
+	// SkipStartup receives when a manual change makes the catch-up held
+	// back by startup_delay_seconds moot. It may be nil.
+	SkipStartup <-chan struct{}
+	// Starting is told, every second of startup_delay_seconds, how long
+	// until the catch-up check, and 0 once the wait is over. It may be nil.
+	Starting func(remaining time.Duration)
+}
+
+// Run performs the startup catch-up, after startup_delay_seconds, and then
+// waits for each change time, recomputing it whenever the config changes.
+func (w *Worker) Run(ctx context.Context) {
+	skipped, ok := w.startupDelay(ctx)
+	if !ok {
+		return
+	}
+	now := w.Clock.Now()
+	h, m := w.Config.Current().ChangeClock()
+	todayAt := time.Date(now.Year(), now.Month(), now.Day(), h, m, 0, 0, now.Location())
+	if !skipped && (now.After(todayAt) || now.Equal(todayAt)) {
+		if !w.UpdatedToday(now) {
+			w.changeWhenIdle(ctx, todayAt)
+		}
+	}
+
+	for {
+		changed := w.Config.Changed()
+		h, m := w.Config.Current().ChangeClock()
+		now := w.Clock.Now()
+		next := NextChangeTime(now, h, m)
+		select {
+		case <-w.Clock.After(next.Sub(now)):
+			w.changeWhenIdle(ctx, next)
+		case <-changed:
+			// change_time may have moved; recompute
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// startupDelay waits out startup_delay_seconds, counting down through
+// Starting. It reports whether SkipStartup cut it short, in which case the
+// manual change stands in for the catch-up, and ok false if ctx ended
+// first.
+func (w *Worker) startupDelay(ctx context.Context) (skipped, ok bool) {
+	delay := time.Duration(w.Config.Current().StartupDelaySeconds) * time.Second
+	if delay <= 0 {
+		return false, true
+	}
+	defer w.starting(0)
+	for ; delay > 0; delay -= time.Second {
+		w.starting(delay)
+		select {
+		case <-w.Clock.After(min(delay, time.Second)):
+		case <-w.SkipStartup:
+			return true, true
+		case <-ctx.Done():
+			return false, false
+		}
+	}
+	return false, true
+}
+
+func (w *Worker) starting(remaining time.Duration) {
+	if w.Starting != nil {
+		w.Starting(remaining)
+	}
+}
+
+// changeWhenIdle runs the change that was due at due once the user has been
+// idle for idle_minutes, or anyway idle_max_wait_minutes after due.
+func (w *Worker) changeWhenIdle(ctx context.Context, due time.Time) {
+	deferred := false
+	for {
+		cfg := w.Config.Current()
+		idle := time.Duration(cfg.IdleMinutes) * time.Minute
+		deadline := due.Add(time.Duration(cfg.IdleMaxWaitMinutes) * time.Minute)
+		if w.IdleTime == nil || idle == 0 || w.IdleTime() >= idle || !w.Clock.Now().Before(deadline) {
+			break
+		}
+		if !deferred && w.Deferred != nil {
+			w.Deferred(deadline)
+		}
+		deferred = true
+		select {
+		case <-w.Clock.After(idlePollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+	if deferred && w.Deferred != nil {
+		w.Deferred(time.Time{})
+	}
+	w.changeWithRetries(ctx)
+}
+
+// changeWithRetries runs Change, retrying failures after each retryBackoff
+// wait. A change disabled by policy is not retried.
+func (w *Worker) changeWithRetries(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		err := w.Change()
+		if err == nil || errors.Is(err, policy.ErrDisabled) {
+			return
+		}
+		if attempt == len(retryBackoff) {
+			logging.Errorf("schedule", "change failed %d times, giving up until the next change time: %v", attempt+1, err)
+			if w.Failed != nil {
+				w.Failed(err)
+			}
+			return
+		}
+		logging.Warnf("schedule", "change failed, retrying in %s: %v", retryBackoff[attempt], err)
+		select {
+		case <-w.Clock.After(retryBackoff[attempt]):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// NextChangeTime returns the first hour:min strictly after now.
+func NextChangeTime(now time.Time, hour, min int) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, now.Location())
+	if !now.Before(t) {
+		t = t.Add(24 * time.Hour)
+	}
+	return t
+}
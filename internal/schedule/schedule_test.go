@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/policy"
+)
+
+func TestNextChangeTime(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before the time", at(14, 8, 59), at(14, 9, 0)},
+		{"exactly at it", at(14, 9, 0), at(15, 9, 0)},
+		{"after it", at(14, 17, 30), at(15, 9, 0)},
+		{"end of month", at(31, 23, 0), time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextChangeTime(tt.now, 9, 0); !got.Equal(tt.want) {
+				t.Errorf("NextChangeTime(%s) = %s, want %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+// waitClock records the waits asked for and lets each pass at once.
+type waitClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *waitClock) Now() time.Time { return c.now }
+
+func (c *waitClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestChangeWithRetries(t *testing.T) {
+	failing := errors.New("source down")
+	tests := []struct {
+		name     string
+		errs     []error // Change's results in turn; the last repeats
+		attempts int
+		waits    []time.Duration
+		failed   bool
+	}{
+		{"succeeds", []error{nil}, 1, nil, false},
+		{"second attempt", []error{failing, nil}, 2, retryBackoff[:1], false},
+		{"always failing", []error{failing}, len(retryBackoff) + 1, retryBackoff, true},
+		{"disabled by policy", []error{policy.ErrDisabled}, 1, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &waitClock{now: time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)}
+			attempts, failed := 0, false
+			w := &Worker{
+				Clock: clock,
+				Change: func() error {
+					err := tt.errs[min(attempts, len(tt.errs)-1)]
+					attempts++
+					return err
+				},
+				Failed: func(error) { failed = true },
+			}
+			w.changeWithRetries(context.Background())
+			if attempts != tt.attempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.attempts)
+			}
+			if !slices.Equal(clock.waits, tt.waits) {
+				t.Errorf("waited %v, want %v", clock.waits, tt.waits)
+			}
+			if failed != tt.failed {
+				t.Errorf("Failed called %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
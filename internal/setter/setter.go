@@ -0,0 +1,212 @@
+// Package setter applies wallpapers and desktop settings through the Win32
+// API and the registry.
+package setter
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	colorBackground        = 1 // COLOR_BACKGROUND
+	originalColorFileName  = "original_background.txt"
+	desktopRegistryPath    = `Control Panel\Desktop`
+	colorsRegistryPath     = `Control Panel\Colors`
+	backgroundRegistryName = "Background"
+)
+
+// State persists the little the setter needs to undo its changes.
+type State interface {
+	Read(name string) ([]byte, error)
+	Write(name string, data []byte) error
+	Remove(name string) error
+}
+
+// Setter changes the desktop wallpaper and related settings.
+type Setter struct {
+	state State
+}
+
+// New returns a Setter that keeps its undo state in state.
+func New(state State) *Setter {
+	return &Setter{state: state}
+}
+
+// fitModes maps config "fit_mode" values to WallpaperStyle/TileWallpaper.
+var fitModes = map[string][2]string{
+	"fill":    {"10", "0"},
+	"fit":     {"6", "0"},
+	"stretch": {"2", "0"},
+	"tile":    {"0", "1"},
+	"center":  {"0", "0"},
+	"span":    {"22", "0"},
+}
+
+var (
+	user32          = syscall.NewLazyDLL("user32.dll")
+	procSPI         = user32.NewProc("SystemParametersInfoW")
+	procSetSysColor = user32.NewProc("SetSysColors")
+	procGetSysColor = user32.NewProc("GetSysColor")
+)
+
+// ValidatePath checks that path can be handed to SystemParametersInfoW:
+// absolute, and shorter than MAX_PATH in UTF-16 units, since
+// SPI_SETDESKWALLPAPER doesn't take \\?\ long paths.
+func ValidatePath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("wallpaper path %q is not absolute", path)
+	}
+	if n := len(utf16.Encode([]rune(path))); n >= syscall.MAX_PATH {
+		return fmt.Errorf("wallpaper path is %d characters, Windows allows at most %d: %s", n, syscall.MAX_PATH-1, path)
+	}
+	return nil
+}
+
+// SetWallpaper makes the image at path the desktop wallpaper.
+func (s *Setter) SetWallpaper(path string) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	// Always the W API: the path may hold Cyrillic or emoji that no ANSI
+	// code page can represent.
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	ret, _, callErr := procSPI.Call(
+		uintptr(20), // SPI_SETDESKWALLPAPER
+		uintptr(0),
+		uintptr(unsafe.Pointer(p)),
+		uintptr(0x01|0x02), // SPIF_UPDATEINIFILE | SPIF_SENDWININICHANGE
+	)
+	if ret == 0 {
+		if callErr != nil {
+			return callErr
+		}
+		return errors.New("SystemParametersInfoW failed")
+	}
+	return nil
+}
+
+// CurrentWallpaper returns the wallpaper path Windows reports
+// (SPI_GETDESKWALLPAPER) without changing anything.
+func CurrentWallpaper() (string, error) {
+	buf := make([]uint16, syscall.MAX_PATH)
+	ret, _, callErr := procSPI.Call(
+		uintptr(0x0073), // SPI_GETDESKWALLPAPER
+		uintptr(len(buf)),
+		uintptr(unsafe.Pointer(&buf[0])),
+		0,
+	)
+	if ret == 0 {
+		if callErr != nil {
+			return "", callErr
+		}
+		return "", errors.New("SystemParametersInfoW failed")
+	}
+	return syscall.UTF16ToString(buf), nil
+}
+
+// ApplyFitMode writes the wallpaper style to the registry; Windows picks it up
+// on the next SPI_SETDESKWALLPAPER. An empty mode leaves the user's setting.
+func (s *Setter) ApplyFitMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	v, ok := fitModes[mode]
+	if !ok {
+		return fmt.Errorf("unknown fit mode %q", mode)
+	}
+	k, err := registry.OpenKey(registry.CURRENT_USER, desktopRegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	if err := k.SetStringValue("WallpaperStyle", v[0]); err != nil {
+		return err
+	}
+	return k.SetStringValue("TileWallpaper", v[1])
+}
+
+// MatchBackgroundColor sets the desktop color behind letterboxed images. The
+// user's original color is saved once so RestoreBackgroundColor can put it back.
+func (s *Setter) MatchBackgroundColor(c color.RGBA) error {
+	if _, err := s.state.Read(originalColorFileName); errors.Is(err, os.ErrNotExist) {
+		ret, _, _ := procGetSysColor.Call(colorBackground)
+		orig := colorFromRef(uint32(ret))
+		if err := s.state.Write(originalColorFileName, []byte(formatRegistryColor(orig))); err != nil {
+			return err
+		}
+	}
+	return setDesktopColor(c)
+}
+
+// RestoreBackgroundColor puts back the color saved by MatchBackgroundColor.
+func (s *Setter) RestoreBackgroundColor() error {
+	b, err := s.state.Read(originalColorFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c, err := parseRegistryColor(string(b))
+	if err != nil {
+		return err
+	}
+	if err := setDesktopColor(c); err != nil {
+		return err
+	}
+	return s.state.Remove(originalColorFileName)
+}
+
+// setDesktopColor changes COLOR_BACKGROUND for the session and persists it.
+func setDesktopColor(c color.RGBA) error {
+	elem := int32(colorBackground)
+	ref := uint32(c.R) | uint32(c.G)<<8 | uint32(c.B)<<16 // COLORREF is 0x00BBGGRR
+	ret, _, callErr := procSetSysColor.Call(1, uintptr(unsafe.Pointer(&elem)), uintptr(unsafe.Pointer(&ref)))
+	if ret == 0 {
+		return fmt.Errorf("SetSysColors failed: %v", callErr)
+	}
+	k, err := registry.OpenKey(registry.CURRENT_USER, colorsRegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	return k.SetStringValue(backgroundRegistryName, formatRegistryColor(c))
+}
+
+func colorFromRef(ref uint32) color.RGBA {
+	return color.RGBA{R: uint8(ref), G: uint8(ref >> 8), B: uint8(ref >> 16), A: 0xff}
+}
+
+// formatRegistryColor renders c the way Control Panel\Colors stores it: "R G B".
+func formatRegistryColor(c color.RGBA) string {
+	return fmt.Sprintf("%d %d %d", c.R, c.G, c.B)
+}
+
+func parseRegistryColor(v string) (color.RGBA, error) {
+	f := strings.Fields(v)
+	if len(f) != 3 {
+		return color.RGBA{}, fmt.Errorf("bad color %q", v)
+	}
+	var c [3]uint8
+	for i, p := range f {
+		n, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return color.RGBA{}, fmt.Errorf("bad color %q: %w", v, err)
+		}
+		c[i] = uint8(n)
+	}
+	return color.RGBA{R: c[0], G: c[1], B: c[2], A: 0xff}, nil
+}
@@ -0,0 +1,49 @@
+package setter
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{"absolute", `C:\Users\me\AppData\Roaming\GoWallpaperTray\wallpaper.bmp`, true},
+		{"relative", `GoWallpaperTray\wallpaper.bmp`, false},
+		{"longest allowed", `C:\` + strings.Repeat("a", 256), true},
+		{"too long", `C:\` + strings.Repeat("a", 257), false},
+		{"cyrillic", `C:\Users\Пользователь\AppData\Roaming\GoWallpaperTray\wallpaper.bmp`, true},
+		{"spaces and emoji", `C:\Users\Мой профиль 🌄\wallpaper.bmp`, true},
+		// Characters outside the BMP take two UTF-16 units each.
+		{"emoji at the limit", `C:\` + strings.Repeat("🌄", 128), true},
+		{"emoji over the limit", `C:\` + strings.Repeat("a", 255) + "🌄", false},
+		{"cyrillic over the limit", `C:\` + strings.Repeat("я", 257), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePath(tt.path); (err == nil) != tt.ok {
+				t.Errorf("ValidatePath = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRegistryColor(t *testing.T) {
+	c := color.RGBA{R: 12, G: 200, B: 255, A: 0xff}
+	got, err := parseRegistryColor(formatRegistryColor(c))
+	if err != nil || got != c {
+		t.Fatalf("round trip = %v, %v; want %v", got, err, c)
+	}
+	for _, bad := range []string{"", "1 2", "1 2 3 4", "1 2 256", "red green blue"} {
+		if _, err := parseRegistryColor(bad); err == nil {
+			t.Errorf("parseRegistryColor(%q) succeeded", bad)
+		}
+	}
+	if got := colorFromRef(0x00ff8001); got != (color.RGBA{R: 0x01, G: 0x80, B: 0xff, A: 0xff}) {
+		t.Errorf("colorFromRef = %v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package setter
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	smRemoteSession     = 0x1000 // SM_REMOTESESSION
+	systemStatusSaverOn = 1      // SYSTEM_POWER_STATUS.SystemStatusFlag
+	qunsNotPresent      = 1      // QUNS_NOT_PRESENT: locked or screen saver
+	qunsAcceptsNotices  = 5      // QUNS_ACCEPTS_NOTIFICATIONS
+	// wnfQuietHoursProfile is WNF_SHEL_QUIETHOURS_ACTIVE_PROFILE_CHANGED,
+	// the undocumented state holding the Focus Assist level: 0 off,
+	// 1 priority only, 2 alarms only.
+	wnfQuietHoursProfile = 0x0d83063ea3bf1c75
+)
+
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procGetSystemPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+	procGetSystemMetrics     = user32.NewProc("GetSystemMetrics")
+	procGetLastInputInfo     = user32.NewProc("GetLastInputInfo")
+	procGetTickCount         = kernel32.NewProc("GetTickCount")
+
+	procQueryUserNotificationState = syscall.NewLazyDLL("shell32.dll").NewProc("SHQueryUserNotificationState")
+	procNtQueryWnfStateData        = syscall.NewLazyDLL("ntdll.dll").NewProc("NtQueryWnfStateData")
+)
+
+type lastInputInfo struct {
+	size uint32
+	time uint32 // GetTickCount at the last input
+}
+
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// BatterySaverOn reports whether Windows battery saver is active.
+func BatterySaverOn() bool {
+	var st systemPowerStatus
+	if ret, _, _ := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&st))); ret == 0 {
+		return false
+	}
+	return st.SystemStatusFlag == systemStatusSaverOn
+}
+
+// RemoteSession reports whether the session is a Remote Desktop session.
+func RemoteSession() bool {
+	ret, _, _ := procGetSystemMetrics.Call(smRemoteSession)
+	return ret != 0
+}
+
+// IdleTime returns how long the session has had no keyboard or mouse input,
+// or 0 if Windows can't tell.
+func IdleTime() time.Duration {
+	li := lastInputInfo{size: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ret, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&li))); ret == 0 {
+		return 0
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Both are 32-bit tick counts; the subtraction survives the 49-day wrap.
+	return time.Duration(uint32(now)-li.time) * time.Millisecond
+}
+
+// NotificationsSuppressed reports whether the user shouldn't be interrupted:
+// Focus Assist is on, or Windows says the session is busy, presenting or in
+// quiet hours. Unknown states count as not suppressed.
+func NotificationsSuppressed() bool {
+	if focusAssistLevel() > 0 {
+		return true
+	}
+	if err := procQueryUserNotificationState.Find(); err != nil {
+		return false
+	}
+	var state int32
+	if ret, _, _ := procQueryUserNotificationState.Call(uintptr(unsafe.Pointer(&state))); ret != 0 {
+		return false
+	}
+	switch state {
+	case qunsNotPresent, qunsAcceptsNotices:
+		return false
+	}
+	return true
+}
+
+// focusAssistLevel reads the Focus Assist level from WNF, 0 when it is off
+// or can't be read.
+func focusAssistLevel() uint32 {
+	if err := procNtQueryWnfStateData.Find(); err != nil {
+		return 0
+	}
+	name := uint64(wnfQuietHoursProfile)
+	var stamp, level uint32
+	size := uint32(unsafe.Sizeof(level))
+	ret, _, _ := procNtQueryWnfStateData.Call(uintptr(unsafe.Pointer(&name)), 0, 0,
+		uintptr(unsafe.Pointer(&stamp)), uintptr(unsafe.Pointer(&level)), uintptr(unsafe.Pointer(&size)))
+	if ret != 0 { // NTSTATUS failure
+		return 0
+	}
+	return level
+}
+
+// RunningProcesses returns the lower-cased executable names of the running
+// processes, from one Toolhelp snapshot.
+func RunningProcesses() (map[string]bool, error) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snap)
+	names := map[string]bool{}
+	var pe windows.ProcessEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	for err = windows.Process32First(snap, &pe); err == nil; err = windows.Process32Next(snap, &pe) {
+		names[strings.ToLower(windows.UTF16ToString(pe.ExeFile[:]))] = true
+	}
+	if !errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,30 @@
+package setter
+
+import "golang.org/x/sys/windows/registry"
+
+const personalizeRegistryPath = `Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`
+
+// SystemUsesLightTheme reports whether the taskbar uses the light theme.
+// Windows before 1903 has no such setting and counts as dark.
+func SystemUsesLightTheme() bool {
+	return personalizeFlag("SystemUsesLightTheme", false)
+}
+
+// DarkModeOn reports whether apps use the dark theme. Windows before 1809
+// has no such setting and counts as light.
+func DarkModeOn() bool {
+	return !personalizeFlag("AppsUseLightTheme", true)
+}
+
+func personalizeFlag(name string, def bool) bool {
+	k, err := registry.OpenKey(registry.CURRENT_USER, personalizeRegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		return def
+	}
+	defer k.Close()
+	v, _, err := k.GetIntegerValue(name)
+	if err != nil {
+		return def
+	}
+	return v != 0
+}
@@ -0,0 +1,188 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	aerialManifestURL   = "https://sylvan.apple.com/Aerials/resources-15sdr.tar"
+	aerialManifestEntry = "entries.json"
+	aerialManifestMax   = 32 << 20
+	aerialFixedOffset   = 30 * time.Second
+	// aerialEdgeMargin keeps random frames away from the fades at either end.
+	aerialEdgeMargin = 5 * time.Second
+	ffmpegTimeout    = 2 * time.Minute
+	createNoWindow   = 0x08000000 // CREATE_NO_WINDOW, so no console flashes up
+)
+
+// aerialURLKeys are the manifest video fields tried in order; 1080p keeps the
+// download ffmpeg has to seek through reasonable.
+var aerialURLKeys = []string{"url-1080-SDR", "url-1080-H264", "url-4K-SDR"}
+
+var ffmpegDuration = regexp.MustCompile(`Duration: (\d+):(\d\d):(\d\d(?:\.\d+)?)`)
+
+// aerialSource grabs a frame from a random Apple TV aerial screensaver video.
+type aerialSource struct {
+	client     *fetch.Client
+	ffmpegPath string
+	randomTime bool
+}
+
+func newAerialSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &aerialSource{
+		client:     deps.Client,
+		ffmpegPath: cfg.AerialFFmpegPath,
+		randomTime: cfg.AerialTimestampMode == "random",
+	}, nil
+}
+
+func (s *aerialSource) Name() string { return "aerial" }
+
+func (s *aerialSource) Host() string { return hostOf(aerialManifestURL) }
+
+// aerialAsset is one video in entries.json.
+type aerialAsset struct {
+	Label string
+	URL   string
+}
+
+func (s *aerialSource) Fetch(ctx context.Context) (*Candidate, error) {
+	if _, err := exec.LookPath(s.ffmpegPath); err != nil {
+		return nil, fmt.Errorf("aerial source needs ffmpeg (aerial_ffmpeg_path): %w", err)
+	}
+	assets, err := s.manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a := assets[random.Intn(len(assets))]
+
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	offset := aerialFixedOffset
+	if s.randomTime {
+		d, err := s.duration(ctx, a.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Label, err)
+		}
+		if span := d - 2*aerialEdgeMargin; span > 0 {
+			offset = aerialEdgeMargin + time.Duration(random.Int63n(int64(span)))
+		} else {
+			offset = d / 2
+		}
+	}
+	path, err := s.extractFrame(ctx, a.URL, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Label, err)
+	}
+	return &Candidate{Path: path, SourceURL: a.URL, Title: a.Label}, nil
+}
+
+// manifest downloads the resources tar and returns the videos in entries.json.
+func (s *aerialSource) manifest(ctx context.Context) ([]aerialAsset, error) {
+	resp, err := s.client.Get(ctx, aerialManifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("aerial manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	tr := tar.NewReader(io.LimitReader(resp.Body, aerialManifestMax))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("aerial manifest has no %s", aerialManifestEntry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("aerial manifest: %w", err)
+		}
+		if strings.TrimPrefix(h.Name, "./") == aerialManifestEntry {
+			return parseAerialEntries(tr)
+		}
+	}
+}
+
+func parseAerialEntries(r io.Reader) ([]aerialAsset, error) {
+	var body struct {
+		Assets []map[string]any `json:"assets"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, fmt.Errorf("aerial %s: %w", aerialManifestEntry, err)
+	}
+	var out []aerialAsset
+	for _, m := range body.Assets {
+		label, _ := m["accessibilityLabel"].(string)
+		for _, k := range aerialURLKeys {
+			if u, _ := m[k].(string); u != "" {
+				out = append(out, aerialAsset{Label: label, URL: u})
+				break
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("aerial manifest lists no videos")
+	}
+	return out, nil
+}
+
+// duration asks ffmpeg for the video length; with no output file ffmpeg
+// exits non-zero, so only the probe output on stderr matters.
+func (s *aerialSource) duration(ctx context.Context, url string) (time.Duration, error) {
+	var stderr bytes.Buffer
+	cmd := s.command(ctx, "-hide_banner", "-i", url)
+	cmd.Stderr = &stderr
+	_ = cmd.Run()
+	m := ffmpegDuration.FindStringSubmatch(stderr.String())
+	if m == nil {
+		return 0, fmt.Errorf("ffmpeg reported no duration: %s", lastLine(stderr.String()))
+	}
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.ParseFloat(m[3], 64)
+	return time.Duration(h)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec*float64(time.Second)), nil
+}
+
+// extractFrame saves the frame at offset as a high-quality JPEG.
+func (s *aerialSource) extractFrame(ctx context.Context, url string, offset time.Duration) (string, error) {
+	tmp, err := os.CreateTemp("", "wall_*.jpg")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	var stderr bytes.Buffer
+	// -ss before -i seeks on the input, so only the part around offset is fetched.
+	cmd := s.command(ctx, "-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64), "-i", url,
+		"-frames:v", "1", "-q:v", "2", "-y", tmp.Name())
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("ffmpeg: %v: %s", err, lastLine(stderr.String()))
+	}
+	return tmp.Name(), nil
+}
+
+func (s *aerialSource) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+	return cmd
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndex(s, "\n")+1:]
+}
@@ -0,0 +1,267 @@
+package source
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"strconv"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	waqiBoundsURL = "https://api.waqi.info/map/bounds/"
+	waqiFeedURL   = "https://api.waqi.info/feed/@%d/"
+	waqiMaxBytes  = 4 << 20
+	// aqiMaxFeeds caps the per-station requests made for a single
+	// pollutant; the bounds call only carries the overall index.
+	aqiMaxFeeds = 40
+	// aqiCell is the side in pixels of the interpolation grid's cells.
+	aqiCell = 8
+	// aqiIDWPower is the inverse-distance weighting exponent.
+	aqiIDWPower = 2
+)
+
+//go:embed cities.geojson
+var citiesGeoJSON []byte
+
+// aqiCategories are the US EPA AQI bands with their map colors.
+var aqiCategories = []struct {
+	Max   float64
+	Name  string
+	Color color.RGBA
+}{
+	{50, "Good", color.RGBA{0x00, 0x99, 0x66, 0xff}},
+	{100, "Moderate", color.RGBA{0xff, 0xde, 0x33, 0xff}},
+	{150, "Unhealthy for sensitive groups", color.RGBA{0xff, 0x99, 0x33, 0xff}},
+	{200, "Unhealthy", color.RGBA{0xcc, 0x00, 0x33, 0xff}},
+	{300, "Very unhealthy", color.RGBA{0x66, 0x00, 0x99, 0xff}},
+	{math.Inf(1), "Hazardous", color.RGBA{0x7e, 0x00, 0x23, 0xff}},
+}
+
+// aqiMapSource renders an interpolated air quality map from aqicn.org
+// station readings.
+type aqiMapSource struct {
+	client    *fetch.Client
+	token     string
+	bbox      config.BBox
+	pollutant string
+}
+
+func newAQIMapSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.AQIAPIToken == "" {
+		return nil, fmt.Errorf("aqi_map source needs aqi_api_token")
+	}
+	bbox, err := config.ParseBBox(cfg.AQIBoundingBox)
+	if err != nil {
+		return nil, err
+	}
+	return &aqiMapSource{client: deps.Client, token: cfg.AQIAPIToken, bbox: bbox, pollutant: cfg.AQIPollutant}, nil
+}
+
+func (s *aqiMapSource) Name() string { return "aqi_map" }
+
+func (s *aqiMapSource) Host() string { return hostOf(waqiBoundsURL) }
+
+// station is one reading placed on the map.
+type station struct {
+	Lat, Lon, Value float64
+}
+
+func (s *aqiMapSource) Fetch(ctx context.Context) (*Candidate, error) {
+	stations, err := s.stations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(stations) == 0 {
+		return nil, fmt.Errorf("no %s readings in the bounding box", s.pollutant)
+	}
+	img, err := s.render(stations)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, SourceURL: "https://aqicn.org/map/", Title: "Air quality: " + s.pollutant}, nil
+}
+
+func (s *aqiMapSource) stations(ctx context.Context) ([]station, error) {
+	var body struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	b := s.bbox
+	q := url.Values{
+		"latlng": {fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East)},
+		"token":  {s.token},
+	}
+	if err := s.client.GetJSON(ctx, waqiBoundsURL+"?"+q.Encode(), waqiMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	if body.Status != "ok" {
+		return nil, fmt.Errorf("waqi: %s", body.Data) // data holds the error message
+	}
+	var readings []struct {
+		UID int     `json:"uid"`
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+		AQI string  `json:"aqi"` // "-" when the station is offline
+	}
+	if err := json.Unmarshal(body.Data, &readings); err != nil {
+		return nil, err
+	}
+	var out []station
+	for _, r := range readings {
+		if s.pollutant != "aqi" {
+			if len(out) >= aqiMaxFeeds {
+				break
+			}
+			v, ok, err := s.pollutantValue(ctx, r.UID)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, station{Lat: r.Lat, Lon: r.Lon, Value: v})
+			}
+			continue
+		}
+		if v, err := strconv.ParseFloat(r.AQI, 64); err == nil {
+			out = append(out, station{Lat: r.Lat, Lon: r.Lon, Value: v})
+		}
+	}
+	return out, nil
+}
+
+// pollutantValue returns the station's sub-index for s.pollutant; ok is
+// false when the station doesn't measure it.
+func (s *aqiMapSource) pollutantValue(ctx context.Context, uid int) (float64, bool, error) {
+	var body struct {
+		Status string `json:"status"`
+		Data   struct {
+			IAQI map[string]struct {
+				V float64 `json:"v"`
+			} `json:"iaqi"`
+		} `json:"data"`
+	}
+	u := fmt.Sprintf(waqiFeedURL, uid) + "?" + url.Values{"token": {s.token}}.Encode()
+	if err := s.client.GetJSON(ctx, u, waqiMaxBytes, &body); err != nil {
+		return 0, false, err
+	}
+	v, ok := body.Data.IAQI[s.pollutant]
+	return v.V, ok && body.Status == "ok", nil
+}
+
+// project maps a coordinate in the bbox to the image.
+func (s *aqiMapSource) project(lat, lon float64) (float64, float64) {
+	x := (lon - s.bbox.West) / (s.bbox.East - s.bbox.West) * renderWidth
+	y := (s.bbox.North - lat) / (s.bbox.North - s.bbox.South) * renderHeight
+	return x, y
+}
+
+func (s *aqiMapSource) render(stations []station) (*image.RGBA, error) {
+	labelFace, err := imaging.NewFace(22, true)
+	if err != nil {
+		return nil, err
+	}
+	legendFace, err := imaging.NewFace(20, false)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+
+	type point struct{ x, y, v float64 }
+	pts := make([]point, len(stations))
+	for i, st := range stations {
+		x, y := s.project(st.Lat, st.Lon)
+		pts[i] = point{x, y, st.Value}
+	}
+	for cy := 0; cy < renderHeight; cy += aqiCell {
+		for cx := 0; cx < renderWidth; cx += aqiCell {
+			x, y := float64(cx)+aqiCell/2, float64(cy)+aqiCell/2
+			num, den := 0.0, 0.0
+			exact := math.NaN()
+			for _, p := range pts {
+				d2 := (p.x-x)*(p.x-x) + (p.y-y)*(p.y-y)
+				if d2 < 1 {
+					exact = p.v
+					break
+				}
+				w := 1 / math.Pow(d2, aqiIDWPower/2.0)
+				num += w * p.v
+				den += w
+			}
+			v := exact
+			if math.IsNaN(v) {
+				v = num / den
+			}
+			imaging.FillRect(img, image.Rect(cx, cy, cx+aqiCell, cy+aqiCell), aqiColor(v))
+		}
+	}
+
+	dot := color.RGBA{0x20, 0x20, 0x20, 0xff}
+	for _, p := range pts {
+		imaging.FillRect(img, image.Rect(int(p.x)-3, int(p.y)-3, int(p.x)+3, int(p.y)+3), dot)
+	}
+
+	var cities struct {
+		Features []struct {
+			Properties struct {
+				Name string `json:"name"`
+			} `json:"properties"`
+			Geometry struct {
+				Coordinates [2]float64 `json:"coordinates"` // lon, lat
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(citiesGeoJSON, &cities); err != nil {
+		return nil, err
+	}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	shadow := color.RGBA{0x00, 0x00, 0x00, 0xc0}
+	for _, f := range cities.Features {
+		lon, lat := f.Geometry.Coordinates[0], f.Geometry.Coordinates[1]
+		if lat < s.bbox.South || lat > s.bbox.North || lon < s.bbox.West || lon > s.bbox.East {
+			continue
+		}
+		x, y := s.project(lat, lon)
+		imaging.FillRect(img, image.Rect(int(x)-4, int(y)-4, int(x)+4, int(y)+4), white)
+		imaging.DrawText(img, labelFace, int(x)+9, int(y)+9, f.Properties.Name, shadow)
+		imaging.DrawText(img, labelFace, int(x)+8, int(y)+8, f.Properties.Name, white)
+	}
+
+	// Legend, bottom left.
+	ly := renderHeight - 40 - len(aqiCategories)*30
+	imaging.FillRect(img, image.Rect(30, ly-40, 420, renderHeight-30), color.RGBA{0x10, 0x10, 0x14, 0xd0})
+	imaging.DrawText(img, legendFace, 45, ly-12, "Air quality ("+s.pollutant+")", white)
+	for i, c := range aqiCategories {
+		y := ly + i*30
+		imaging.FillRect(img, image.Rect(45, y, 69, y+20), c.Color)
+		imaging.DrawText(img, legendFace, 80, y+17, c.Name, white)
+	}
+	return img, nil
+}
+
+// aqiColor blends from the previous category's color across each band so
+// the map has no hard edges.
+func aqiColor(v float64) color.RGBA {
+	lo := 0.0
+	for i, c := range aqiCategories {
+		if v <= c.Max {
+			if i == 0 || math.IsInf(c.Max, 1) {
+				return c.Color
+			}
+			return imaging.LerpColor(aqiCategories[i-1].Color, c.Color, (v-lo)/(c.Max-lo))
+		}
+		lo = c.Max
+	}
+	return aqiCategories[len(aqiCategories)-1].Color
+}
@@ -0,0 +1,121 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	auroraForecastURL = "https://services.swpc.noaa.gov/images/aurora-forecast-northern-hemisphere.jpg"
+	auroraKpURL       = "https://services.swpc.noaa.gov/products/noaa-planetary-k-index.json"
+	auroraPageURL     = "https://www.swpc.noaa.gov/products/aurora-30-minute-forecast"
+	auroraKpMaxBytes  = 1 << 20
+	// auroraNotifiedFile holds the date of the last aurora notification, so
+	// the half-hourly refreshes tell once a night.
+	auroraNotifiedFile = "aurora_notified.txt"
+)
+
+// auroraSource shows NOAA SWPC's 30-minute aurora forecast for the northern
+// hemisphere and tells the user when the Kp index promises a visible one.
+type auroraSource struct {
+	client    *fetch.Client
+	now       func() time.Time
+	notify    func(title, msg string) // nil when not notifying
+	threshold float64
+	stateDir  string
+}
+
+func newAuroraSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	s := &auroraSource{client: deps.Client, now: deps.Now, threshold: cfg.AuroraKpThreshold, stateDir: deps.AppDir}
+	if cfg.AuroraNotify {
+		s.notify = deps.Notify
+	}
+	return s, nil
+}
+
+func (s *auroraSource) Name() string { return "aurora" }
+
+func (s *auroraSource) Host() string { return hostOf(auroraForecastURL) }
+
+func (s *auroraSource) Fetch(ctx context.Context) (*Candidate, error) {
+	path, err := s.client.DownloadToTemp(ctx, auroraForecastURL)
+	if err != nil {
+		return nil, err
+	}
+	title := "Aurora forecast, northern hemisphere"
+	// The Kp check is extra; the forecast is shown either way.
+	if kp, err := s.latestKp(ctx); err != nil {
+		fmt.Println("aurora: failed to get the Kp index:", err)
+	} else {
+		title += fmt.Sprintf(" (Kp %.1f)", kp)
+		s.maybeNotify(kp)
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: auroraPageURL,
+		Title:     title,
+		Author:    "NOAA Space Weather Prediction Center",
+		Category:  "space weather",
+	}, nil
+}
+
+// latestKp returns the most recent planetary Kp index. The product is a
+// table whose first row is the header, with numbers as strings; newer
+// versions of it are a list of objects instead, so both are read.
+func (s *auroraSource) latestKp(ctx context.Context) (float64, error) {
+	var rows []json.RawMessage
+	if err := s.client.GetJSON(ctx, auroraKpURL, auroraKpMaxBytes, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, errors.New("empty Kp index")
+	}
+	last := rows[len(rows)-1]
+	var table []string
+	if err := json.Unmarshal(last, &table); err == nil {
+		if len(rows) < 2 || len(table) < 2 {
+			return 0, errors.New("no Kp readings")
+		}
+		return strconv.ParseFloat(table[1], 64)
+	}
+	var obj struct {
+		Kp *float64 `json:"Kp"`
+	}
+	if err := json.Unmarshal(last, &obj); err != nil || obj.Kp == nil {
+		return 0, fmt.Errorf("unexpected Kp index format: %.60s", last)
+	}
+	return *obj.Kp, nil
+}
+
+// maybeNotify tells the user about a Kp of at least the threshold, once
+// per date.
+func (s *auroraSource) maybeNotify(kp float64) {
+	if s.notify == nil || kp < s.threshold {
+		return
+	}
+	today := s.now().Format(time.DateOnly)
+	var marker string
+	if s.stateDir != "" {
+		marker = filepath.Join(s.stateDir, auroraNotifiedFile)
+		if b, err := os.ReadFile(marker); err == nil && strings.TrimSpace(string(b)) == today {
+			return
+		}
+	}
+	s.notify("Aurora", fmt.Sprintf("Aurora visible at your latitude tonight! (Kp %.1f)", kp))
+	if marker != "" {
+		if err := store.WriteFileAtomic(marker, []byte(today)); err != nil {
+			fmt.Println("aurora: failed to save notification date:", err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"net/url"
+	"os"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	openLibrarySearchURL = "https://openlibrary.org/search.json?q=%s&limit=%d&fields=title,author_name,cover_i"
+	openLibraryCoverURL  = "https://covers.openlibrary.org/b/id/%d-L.jpg"
+	openLibraryMaxBytes  = 2 << 20
+	openLibraryResults   = 20
+	// coverAspect is width over height of a typical book cover.
+	coverAspect = 2.0 / 3.0
+)
+
+var coverFiller = rgb(24, 24, 28)
+
+// bookCoverSource tiles the covers of books matching open_library_genre into
+// a grid.
+type bookCoverSource struct {
+	client  *fetch.Client
+	genre   string
+	padding int
+}
+
+func newBookCoverSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &bookCoverSource{client: deps.Client, genre: cfg.OpenLibraryGenre, padding: cfg.BookCoverGridPadding}, nil
+}
+
+func (s *bookCoverSource) Name() string { return "book_covers" }
+
+func (s *bookCoverSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var res struct {
+		Docs []struct {
+			Title   string   `json:"title"`
+			Authors []string `json:"author_name"`
+			CoverID int      `json:"cover_i"`
+		} `json:"docs"`
+	}
+	u := fmt.Sprintf(openLibrarySearchURL, url.QueryEscape(s.genre), openLibraryResults)
+	if err := s.client.GetJSON(ctx, u, openLibraryMaxBytes, &res); err != nil {
+		return nil, err
+	}
+	var covers []image.Image
+	var tags []string
+	for _, d := range res.Docs {
+		if d.CoverID <= 0 {
+			continue
+		}
+		img, err := s.cover(ctx, d.CoverID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Printf("book_covers: skipping cover of %q: %v\n", d.Title, err)
+			continue
+		}
+		tag := d.Title
+		if len(d.Authors) > 0 {
+			tag += " by " + d.Authors[0]
+		}
+		covers = append(covers, img)
+		tags = append(tags, tag)
+	}
+	if len(covers) == 0 {
+		return nil, errors.New("no covers found for " + s.genre)
+	}
+
+	out, err := imaging.WriteTempBMP(s.montage(covers))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      out,
+		SourceURL: "https://openlibrary.org/search?q=" + url.QueryEscape(s.genre),
+		Title:     s.genre + " book covers",
+		Category:  s.genre,
+		Tags:      tags,
+	}, nil
+}
+
+func (s *bookCoverSource) cover(ctx context.Context, id int) (image.Image, error) {
+	path, err := s.client.DownloadToTemp(ctx, fmt.Sprintf(openLibraryCoverURL, id))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	img, err := imaging.DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Open Library answers missing covers with a 1x1 placeholder.
+	if b := img.Bounds(); b.Dx() < 10 || b.Dy() < 10 {
+		return nil, errors.New("no cover image")
+	}
+	return img, nil
+}
+
+// montage lays the covers out in the grid whose cells come closest to a
+// cover's proportions, filling the cells left over with a dark tile.
+func (s *bookCoverSource) montage(covers []image.Image) *image.RGBA {
+	cols, rows := coverGrid(len(covers), s.padding)
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillRect(img, img.Bounds(), coverFiller)
+	cw := (renderWidth - s.padding*(cols+1)) / cols
+	ch := (renderHeight - s.padding*(rows+1)) / rows
+	// Centre the grid in what integer division leaves over.
+	x0 := (renderWidth - cols*cw - (cols+1)*s.padding) / 2
+	y0 := (renderHeight - rows*ch - (rows+1)*s.padding) / 2
+	for i := range cols * rows {
+		x := x0 + s.padding + (i%cols)*(cw+s.padding)
+		y := y0 + s.padding + (i/cols)*(ch+s.padding)
+		cell := image.Rect(x, y, x+cw, y+ch)
+		if i >= len(covers) {
+			imaging.FillRect(img, cell, imaging.LerpColor(coverFiller, rgb(0, 0, 0), 0.5))
+			continue
+		}
+		tile := imaging.Resize(imaging.CropToAspect(covers[i], cell.Size()), image.Rect(0, 0, cw, ch))
+		draw.Draw(img, cell, tile, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// coverGrid picks the columns and rows for n covers: every cover gets a
+// cell, and the cells are shaped as much like a cover as possible.
+func coverGrid(n, padding int) (cols, rows int) {
+	best := math.Inf(1)
+	for r := 1; r <= n; r++ {
+		c := (n + r - 1) / r
+		w := float64(renderWidth-padding*(c+1)) / float64(c)
+		h := float64(renderHeight-padding*(r+1)) / float64(r)
+		if w < 1 || h < 1 {
+			continue
+		}
+		if d := math.Abs(math.Log(w / h / coverAspect)); d < best {
+			best, cols, rows = d, c, r
+		}
+	}
+	if cols == 0 { // padding too wide for any grid; show one cover
+		return 1, 1
+	}
+	return cols, rows
+}
@@ -0,0 +1,208 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	googleCalendarEventsURL = "https://www.googleapis.com/calendar/v3/calendars/primary/events"
+	googleCalendarScope     = "https://www.googleapis.com/auth/calendar.readonly"
+	googleCalendarTokenFile = "google_calendar_token.json"
+	googleCalendarMaxEvents = 10
+	googleCalendarMaxBytes  = 1 << 20
+	agendaWidth             = 720
+	agendaMargin            = 80
+	agendaPadding           = 40
+	agendaRowHeight         = 72
+)
+
+// calendarSource draws today's events from the user's primary Google
+// Calendar as an agenda over the most recent wallpaper from another source.
+type calendarSource struct {
+	client          *fetch.Client
+	now             func() time.Time
+	credentialsFile string
+	tokenPath       string
+	historyDir      string
+}
+
+func newCalendarSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.GCalCredentialsFile == "" {
+		return nil, errors.New("google_calendar source needs gcal_credentials_file")
+	}
+	if deps.AppDir == "" {
+		return nil, errors.New("google_calendar source needs the app dir to cache its token")
+	}
+	return &calendarSource{
+		client:          deps.Client,
+		now:             deps.Now,
+		credentialsFile: cfg.GCalCredentialsFile,
+		tokenPath:       filepath.Join(deps.AppDir, googleCalendarTokenFile),
+		historyDir:      filepath.Join(deps.AppDir, history.DirName),
+	}, nil
+}
+
+func (s *calendarSource) Name() string { return "google_calendar" }
+
+func (s *calendarSource) Host() string { return hostOf(googleCalendarEventsURL) }
+
+type calendarEvent struct {
+	Summary  string `json:"summary"`
+	Location string `json:"location"`
+	Start    struct {
+		DateTime time.Time `json:"dateTime"`
+		Date     string    `json:"date"` // set instead of DateTime for all-day events
+	} `json:"start"`
+	End struct {
+		DateTime time.Time `json:"dateTime"`
+	} `json:"end"`
+}
+
+func (s *calendarSource) Fetch(ctx context.Context) (*Candidate, error) {
+	client, err := googleAuthClient(ctx, s.client, s.credentialsFile, s.tokenPath, googleCalendarScope)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.todaysEvents(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	img, err := s.background()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.drawAgenda(img, events); err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, SourceURL: "https://calendar.google.com/", Title: "Agenda " + s.now().Format("Jan 2")}, nil
+}
+
+func (s *calendarSource) todaysEvents(ctx context.Context, client *http.Client) ([]calendarEvent, error) {
+	now := s.now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	q := url.Values{
+		"timeMin":      {day.Format(time.RFC3339)},
+		"timeMax":      {day.AddDate(0, 0, 1).Format(time.RFC3339)},
+		"maxResults":   {fmt.Sprint(googleCalendarMaxEvents)},
+		"singleEvents": {"true"},
+		"orderBy":      {"startTime"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleCalendarEventsURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google calendar bad status: %s", resp.Status)
+	}
+	var out struct {
+		Items []calendarEvent `json:"items"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, googleCalendarMaxBytes)).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// background is the newest wallpaper in history not drawn by this source,
+// so the agenda isn't stacked on the previous agenda, or a plain dark
+// gradient when there is none.
+func (s *calendarSource) background() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	h, err := history.Open(s.historyDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range h.Recent(h.Len()) {
+		if e.Source == s.Name() {
+			continue
+		}
+		_, m, err := h.Load(e.File)
+		if err != nil {
+			fmt.Println("google_calendar: skipping unreadable history entry:", err)
+			continue
+		}
+		draw.Draw(img, img.Bounds(), imaging.Resize(m, img.Bounds()), image.Point{}, draw.Src)
+		return img, nil
+	}
+	imaging.FillVerticalGradient(img, rgb(0x1c, 0x24, 0x30), rgb(0x0b, 0x0f, 0x14))
+	return img, nil
+}
+
+// drawAgenda lists events on a translucent dark panel at the right edge.
+func (s *calendarSource) drawAgenda(img *image.RGBA, events []calendarEvent) error {
+	titleFace, err := imaging.NewFace(44, true)
+	if err != nil {
+		return err
+	}
+	timeFace, err := imaging.NewFace(26, true)
+	if err != nil {
+		return err
+	}
+	textFace, err := imaging.NewFace(26, false)
+	if err != nil {
+		return err
+	}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	dim := color.RGBA{0xb0, 0xb8, 0xc4, 0xff}
+	rows := max(len(events), 1)
+	panel := image.Rect(renderWidth-agendaMargin-agendaWidth, agendaMargin,
+		renderWidth-agendaMargin, agendaMargin+2*agendaPadding+80+rows*agendaRowHeight)
+	draw.Draw(img, panel, image.NewUniform(color.RGBA{A: 0xa8}), image.Point{}, draw.Over)
+
+	x := panel.Min.X + agendaPadding
+	y := panel.Min.Y + agendaPadding + 44
+	imaging.DrawText(img, titleFace, x, y, s.now().Format("Monday, January 2"), white)
+	y += 80
+	if len(events) == 0 {
+		imaging.DrawText(img, textFace, x, y, "Nothing scheduled today", dim)
+		return nil
+	}
+	textWidth := agendaWidth - 2*agendaPadding
+	for _, e := range events {
+		when := "All day"
+		if e.Start.Date == "" {
+			start := e.Start.DateTime.In(s.now().Location())
+			when = start.Format("15:04")
+			if !e.End.DateTime.IsZero() {
+				when += " – " + e.End.DateTime.In(s.now().Location()).Format("15:04")
+			}
+		}
+		summary := e.Summary
+		if summary == "" {
+			summary = "(no title)"
+		}
+		imaging.DrawText(img, timeFace, x, y, when, dim)
+		imaging.DrawText(img, textFace, x, y+32, imaging.TruncateText(textFace, summary, textWidth), white)
+		if e.Location != "" {
+			loc := imaging.TruncateText(textFace, e.Location, textWidth/2)
+			imaging.DrawText(img, textFace, panel.Max.X-agendaPadding-imaging.TextWidth(textFace, loc), y, loc, dim)
+		}
+		y += agendaRowHeight
+	}
+	return nil
+}
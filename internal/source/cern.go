@@ -0,0 +1,113 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	cernRecordsURL  = "https://opendata.cern.ch/api/records/"
+	cernFileURL     = "https://opendata.cern.ch/record/%d/files/%s"
+	cernMaxBytes    = 4 << 20
+	cernPageSize    = 20
+	cernMinFileSize = 500000 // smaller images are thumbnails
+)
+
+// cernSource picks a random LHC event display of one experiment from the
+// CERN Open Data portal.
+type cernSource struct {
+	client     *fetch.Client
+	experiment string
+}
+
+func newCERNSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &cernSource{client: deps.Client, experiment: cfg.CERNExperiment}, nil
+}
+
+func (s *cernSource) Name() string { return "cern_events" }
+
+func (s *cernSource) Host() string { return hostOf(cernRecordsURL) }
+
+type cernRecords struct {
+	Hits struct {
+		Total int `json:"total"`
+		Hits  []struct {
+			Metadata struct {
+				RecID int    `json:"recid"`
+				Title string `json:"title"`
+				Files []struct {
+					Key  string `json:"key"`
+					Size int64  `json:"size"`
+				} `json:"files"`
+			} `json:"metadata"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (s *cernSource) Fetch(ctx context.Context) (*Candidate, error) {
+	res, err := s.page(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	// The first page tells how many there are; look at a random one.
+	if pages := (res.Hits.Total + cernPageSize - 1) / cernPageSize; pages > 1 {
+		if p := 1 + random.Intn(pages); p > 1 {
+			if res, err = s.page(ctx, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	type display struct {
+		recID      int
+		title, key string
+	}
+	var displays []display
+	for _, h := range res.Hits.Hits {
+		for _, f := range h.Metadata.Files {
+			switch strings.ToLower(path.Ext(f.Key)) {
+			case ".png", ".jpg", ".jpeg":
+				if f.Size >= cernMinFileSize {
+					displays = append(displays, display{h.Metadata.RecID, h.Metadata.Title, f.Key})
+				}
+			}
+		}
+	}
+	if len(displays) == 0 {
+		return nil, fmt.Errorf("no %s event displays of at least %d bytes found", s.experiment, cernMinFileSize)
+	}
+	d := displays[random.Intn(len(displays))]
+	dl, err := s.client.DownloadToTemp(ctx, fmt.Sprintf(cernFileURL, d.recID, url.PathEscape(d.key)))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      dl,
+		SourceURL: fmt.Sprintf("https://opendata.cern.ch/record/%d", d.recID),
+		Title:     d.title,
+		Author:    s.experiment + " collaboration",
+		Category:  "event display",
+		Tags:      []string{s.experiment},
+	}, nil
+}
+
+func (s *cernSource) page(ctx context.Context, page int) (*cernRecords, error) {
+	q := url.Values{
+		"type":       {"Event displays"},
+		"experiment": {s.experiment},
+		"size":       {fmt.Sprint(cernPageSize)},
+		"page":       {fmt.Sprint(page)},
+	}
+	var res cernRecords
+	if err := s.client.GetJSON(ctx, cernRecordsURL+"?"+q.Encode(), cernMaxBytes, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,560 @@
+package source
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	lichessGamesURL = "https://lichess.org/api/games/user/"
+	lichessSite     = "https://lichess.org/"
+	lichessMaxGames = 20
+	chessMaxBytes   = 2 << 20
+	// chessViewBox is the size of the piece SVGs' coordinate space.
+	chessViewBox = 45
+	// chessSquare is the size of a square in pixels: the board takes most
+	// of the picture's height, the rest goes to its frame, and the space
+	// beside it to the game's caption.
+	chessSquare    = renderHeight * 82 / 100 / 8
+	chessTitleSize = 0.045
+	chessInfoSize  = 0.028
+)
+
+//go:embed chess/pieces/*.svg
+var chessPieceFiles embed.FS
+
+// chessPieceNames maps FEN letters to the piece files.
+var chessPieceNames = map[rune]string{
+	'k': "king", 'q': "queen", 'r': "rook", 'b': "bishop", 'n': "knight", 'p': "pawn",
+}
+
+// chessStyle colors the board and the single-color piece shapes.
+type chessStyle struct {
+	background, frame, light, dark color.RGBA
+	white, black                   color.RGBA
+	whiteLine, blackLine           color.RGBA
+	// outline is the width of the pieces' outline in viewBox units.
+	outline float32
+	text    color.RGBA
+}
+
+// chessStyles are the config.ChessPieceStyles.
+var chessStyles = map[string]chessStyle{
+	"classic": {
+		background: color.RGBA{R: 0x2b, G: 0x1d, B: 0x14, A: 0xff}, frame: color.RGBA{R: 0x4a, G: 0x30, B: 0x1e, A: 0xff},
+		light: color.RGBA{R: 0xf0, G: 0xd9, B: 0xb5, A: 0xff}, dark: color.RGBA{R: 0xb5, G: 0x88, B: 0x63, A: 0xff},
+		white: color.RGBA{R: 0xfb, G: 0xf5, B: 0xe6, A: 0xff}, black: color.RGBA{R: 0x24, G: 0x1d, B: 0x18, A: 0xff},
+		whiteLine: color.RGBA{R: 0x3a, G: 0x2a, B: 0x1e, A: 0xff}, blackLine: color.RGBA{R: 0xd8, G: 0xc3, B: 0xa0, A: 0xff},
+		outline: 1.2, text: color.RGBA{R: 0xf0, G: 0xd9, B: 0xb5, A: 0xff},
+	},
+	"flat": {
+		background: color.RGBA{R: 0x26, G: 0x24, B: 0x21, A: 0xff}, frame: color.RGBA{R: 0x31, G: 0x2e, B: 0x2b, A: 0xff},
+		light: color.RGBA{R: 0xee, G: 0xee, B: 0xd2, A: 0xff}, dark: color.RGBA{R: 0x76, G: 0x96, B: 0x56, A: 0xff},
+		white: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, black: color.RGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff},
+		whiteLine: color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}, blackLine: color.RGBA{R: 0x70, G: 0x70, B: 0x70, A: 0xff},
+		text: color.RGBA{R: 0xee, G: 0xee, B: 0xd2, A: 0xff},
+	},
+	"neon": {
+		background: color.RGBA{R: 0x08, G: 0x06, B: 0x14, A: 0xff}, frame: color.RGBA{R: 0x1a, G: 0x10, B: 0x3a, A: 0xff},
+		light: color.RGBA{R: 0x1c, G: 0x1a, B: 0x38, A: 0xff}, dark: color.RGBA{R: 0x10, G: 0x0e, B: 0x24, A: 0xff},
+		white: color.RGBA{R: 0x0c, G: 0x2c, B: 0x34, A: 0xff}, black: color.RGBA{R: 0x30, G: 0x0a, B: 0x2c, A: 0xff},
+		whiteLine: color.RGBA{R: 0x3c, G: 0xf0, B: 0xff, A: 0xff}, blackLine: color.RGBA{R: 0xff, G: 0x4c, B: 0xd8, A: 0xff},
+		outline: 1.6, text: color.RGBA{R: 0x3c, G: 0xf0, B: 0xff, A: 0xff},
+	},
+}
+
+// chessSource draws the final position of a random recent classical game
+// of a Lichess player, seen from the player's side.
+type chessSource struct {
+	client   *fetch.Client
+	username string
+	style    chessStyle
+}
+
+func newChessSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.ChessUsername == "" {
+		return nil, errors.New("chess source needs chess_username")
+	}
+	return &chessSource{client: deps.Client, username: cfg.ChessUsername, style: chessStyles[cfg.ChessPieceStyle]}, nil
+}
+
+func (s *chessSource) Name() string { return "chess" }
+
+func (s *chessSource) Host() string { return hostOf(lichessGamesURL) }
+
+type lichessPlayer struct {
+	User struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	AILevel int `json:"aiLevel"`
+}
+
+func (p lichessPlayer) name() string {
+	if p.User.Name != "" {
+		return p.User.Name
+	}
+	if p.AILevel > 0 {
+		return fmt.Sprintf("Stockfish level %d", p.AILevel)
+	}
+	return "Anonymous"
+}
+
+type lichessGame struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Winner    string `json:"winner"`
+	CreatedAt int64  `json:"createdAt"` // Unix ms
+	LastFEN   string `json:"lastFen"`
+	Players   struct {
+		White lichessPlayer `json:"white"`
+		Black lichessPlayer `json:"black"`
+	} `json:"players"`
+}
+
+func (g lichessGame) result() string {
+	switch g.Winner {
+	case "white":
+		return "1-0"
+	case "black":
+		return "0-1"
+	}
+	return "½-½"
+}
+
+func (s *chessSource) Fetch(ctx context.Context) (*Candidate, error) {
+	games, err := s.games(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []lichessGame
+	for _, g := range games {
+		if g.LastFEN != "" && g.Status != "created" && g.Status != "started" {
+			done = append(done, g)
+		}
+	}
+	if len(done) == 0 {
+		return nil, fmt.Errorf("no finished classical games of %s on Lichess", s.username)
+	}
+	g := done[random.Intn(len(done))]
+	board, err := parseFEN(g.LastFEN)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", g.ID, err)
+	}
+	flipped := strings.EqualFold(g.Players.Black.User.Name, s.username)
+	img, err := s.render(board, flipped, g)
+	if err != nil {
+		return nil, err
+	}
+	p, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      p,
+		SourceURL: lichessSite + g.ID,
+		Title:     g.Players.White.name() + " vs " + g.Players.Black.name(),
+		Author:    s.username,
+		Category:  "classical",
+		Tags:      []string{g.Status},
+	}, nil
+}
+
+// games lists the player's latest classical games, which Lichess streams
+// as NDJSON.
+func (s *chessSource) games(ctx context.Context) ([]lichessGame, error) {
+	q := url.Values{
+		"max":      {strconv.Itoa(lichessMaxGames)},
+		"perfType": {"classical"},
+		"lastFen":  {"true"},
+		"moves":    {"false"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lichessGamesURL+url.PathEscape(s.username)+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("no Lichess player %q: %w", s.username, fetch.ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	var games []lichessGame
+	dec := json.NewDecoder(io.LimitReader(resp.Body, chessMaxBytes))
+	for {
+		var g lichessGame
+		if err := dec.Decode(&g); errors.Is(err, io.EOF) {
+			return games, nil
+		} else if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+}
+
+// chessBoard holds the FEN piece letters by rank from 8 down to 1, files
+// from a to h; 0 is an empty square.
+type chessBoard [8][8]rune
+
+// parseFEN reads the piece placement field of fen.
+func parseFEN(fen string) (chessBoard, error) {
+	var b chessBoard
+	placement, _, _ := strings.Cut(fen, " ")
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return b, fmt.Errorf("FEN %q doesn't have 8 ranks", fen)
+	}
+	for r, rank := range ranks {
+		f := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				f += int(c - '0')
+			case chessPieceNames[toLowerASCII(c)] != "":
+				if f < 8 {
+					b[r][f] = c
+				}
+				f++
+			default:
+				return b, fmt.Errorf("FEN %q has an unknown piece %q", fen, c)
+			}
+		}
+		if f != 8 {
+			return b, fmt.Errorf("FEN %q has a rank of %d squares", fen, f)
+		}
+	}
+	return b, nil
+}
+
+func toLowerASCII(c rune) rune {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// render draws the board centered with the game's players and result
+// beside it.
+func (s *chessSource) render(board chessBoard, flipped bool, g lichessGame) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	if err := drawChessBoard(img, s.style, board, flipped); err != nil {
+		return nil, err
+	}
+	sq := chessSquare
+	size := 8 * sq
+	x0, y0 := (renderWidth-size)/2, (renderHeight-size)/2
+	frame := sq / 6
+
+	// The caption goes in the left margin, a line per player, the one at
+	// the bottom of the board last.
+	title, err := imaging.NewFace(float64(renderHeight)*chessTitleSize, true)
+	if err != nil {
+		return nil, err
+	}
+	defer title.Close()
+	info, err := imaging.NewFace(float64(renderHeight)*chessInfoSize, false)
+	if err != nil {
+		return nil, err
+	}
+	defer info.Close()
+	top, bottom := g.Players.Black.name(), g.Players.White.name()
+	if flipped {
+		top, bottom = bottom, top
+	}
+	maxText := x0 - 2*frame - sq/2
+	tx := sq / 2
+	imaging.DrawText(img, title, tx, y0+title.Metrics().Ascent.Ceil(), imaging.TruncateText(title, top, maxText), s.style.text)
+	imaging.DrawText(img, title, tx, y0+size, imaging.TruncateText(title, bottom, maxText), s.style.text)
+	when := time.UnixMilli(g.CreatedAt).Format("Jan 2, 2006")
+	mid := y0 + size/2
+	imaging.DrawText(img, title, tx, mid, g.result(), s.style.text)
+	imaging.DrawText(img, info, tx, mid+info.Metrics().Height.Ceil()+sq/8, imaging.TruncateText(info, g.Status+", "+when, maxText), s.style.text)
+	return img, nil
+}
+
+// drawChessBoard fills img with style's background and draws board
+// centered on it, chessSquare pixels a square, in a frame; flipped puts
+// black at the bottom.
+func drawChessBoard(img *image.RGBA, style chessStyle, board chessBoard, flipped bool) error {
+	pieces, err := loadChessPieces()
+	if err != nil {
+		return err
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(style.background), image.Point{}, draw.Src)
+
+	sq := chessSquare
+	size := 8 * sq
+	x0, y0 := (renderWidth-size)/2, (renderHeight-size)/2
+	frame := sq / 6
+	draw.Draw(img, image.Rect(x0-frame, y0-frame, x0+size+frame, y0+size+frame), image.NewUniform(style.frame), image.Point{}, draw.Src)
+
+	r := vector.NewRasterizer(sq, sq)
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			rank, file := row, col
+			if flipped {
+				rank, file = 7-row, 7-col
+			}
+			c := style.light
+			if (row+col)%2 == 1 {
+				c = style.dark
+			}
+			cell := image.Rect(x0+col*sq, y0+row*sq, x0+(col+1)*sq, y0+(row+1)*sq)
+			draw.Draw(img, cell, image.NewUniform(c), image.Point{}, draw.Src)
+			if p := board[rank][file]; p != 0 {
+				drawChessPiece(img, r, style, pieces[chessPieceNames[toLowerASCII(p)]], p != toLowerASCII(p), cell)
+			}
+		}
+	}
+	return nil
+}
+
+// drawChessPiece paints p into cell: outline first, the shape in the
+// side's color over it, then its details in the outline color.
+func drawChessPiece(img *image.RGBA, r *vector.Rasterizer, style chessStyle, p chessPiece, white bool, cell image.Rectangle) {
+	fill, line := style.black, style.blackLine
+	if white {
+		fill, line = style.white, style.whiteLine
+	}
+	scale := float32(cell.Dx()) / chessViewBox
+	at := func(dx, dy float32) func(x, y float32) (float32, float32) {
+		return func(x, y float32) (float32, float32) {
+			return (x + dx) * scale, (y + dy) * scale
+		}
+	}
+	if o := style.outline; o > 0 {
+		// Spreading the shape into the outline color all around draws
+		// the stroke the single-color shapes don't have.
+		for _, d := range [][2]float32{{-o, 0}, {o, 0}, {0, -o}, {0, o}, {-o * 0.7, -o * 0.7}, {o * 0.7, -o * 0.7}, {-o * 0.7, o * 0.7}, {o * 0.7, o * 0.7}} {
+			for _, sp := range p.shapes {
+				fillSVGPath(img, r, cell, sp, at(d[0], d[1]), line)
+			}
+		}
+	}
+	for _, sp := range p.shapes {
+		fillSVGPath(img, r, cell, sp, at(0, 0), fill)
+	}
+	for _, sp := range p.details {
+		fillSVGPath(img, r, cell, sp, at(0, 0), line)
+	}
+}
+
+// chessPiece is a piece SVG: the paths of its shape and, with
+// class="detail", lines drawn over it.
+type chessPiece struct {
+	shapes, details []svgPath
+}
+
+var (
+	chessPiecesOnce sync.Once
+	chessPieces     map[string]chessPiece
+	chessPiecesErr  error
+
+	svgPathTag = regexp.MustCompile(`<path\b[^>]*>`)
+	svgAttr    = regexp.MustCompile(`\b(d|class)="([^"]*)"`)
+)
+
+// loadChessPieces parses the embedded piece SVGs once.
+func loadChessPieces() (map[string]chessPiece, error) {
+	chessPiecesOnce.Do(func() {
+		chessPieces = map[string]chessPiece{}
+		for _, name := range chessPieceNames {
+			b, err := chessPieceFiles.ReadFile(path.Join("chess", "pieces", name+".svg"))
+			if err != nil {
+				chessPiecesErr = err
+				return
+			}
+			var p chessPiece
+			for _, tag := range svgPathTag.FindAllString(string(b), -1) {
+				var d, class string
+				for _, m := range svgAttr.FindAllStringSubmatch(tag, -1) {
+					if m[1] == "d" {
+						d = m[2]
+					} else {
+						class = m[2]
+					}
+				}
+				sp, err := parseSVGPath(d)
+				if err != nil {
+					chessPiecesErr = fmt.Errorf("%s.svg: %w", name, err)
+					return
+				}
+				if class == "detail" {
+					p.details = append(p.details, sp)
+				} else {
+					p.shapes = append(p.shapes, sp)
+				}
+			}
+			chessPieces[name] = p
+		}
+	})
+	return chessPieces, chessPiecesErr
+}
+
+// svgSegment is one absolute path command: 'M', 'L', 'C' or 'Z', with its
+// points.
+type svgSegment struct {
+	op  byte
+	pts [3][2]float32
+}
+
+type svgPath []svgSegment
+
+// parseSVGPath reads the path data the piece files use: M, L, H, V, C, Q
+// and Z, absolute or relative.
+func parseSVGPath(d string) (svgPath, error) {
+	toks := svgTokens(d)
+	var out svgPath
+	var cur, start [2]float32
+	var op byte
+	for i := 0; i < len(toks); {
+		if t := toks[i]; len(t) == 1 && strings.ContainsAny(t, "MmLlHhVvCcQqZz") {
+			op = t[0]
+			i++
+		}
+		if op == 'Z' || op == 'z' {
+			out = append(out, svgSegment{op: 'Z'})
+			cur = start
+			op = 0
+			continue
+		}
+		n := map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'Q': 4}[op&^0x20]
+		if n == 0 || i+n > len(toks) {
+			return nil, fmt.Errorf("bad path data near %q", strings.Join(toks[i:], " "))
+		}
+		var v [6]float32
+		for k := 0; k < n; k++ {
+			f, err := strconv.ParseFloat(toks[i+k], 32)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = float32(f)
+		}
+		i += n
+		rel := op >= 'a'
+		pt := func(x, y float32) [2]float32 {
+			if rel {
+				return [2]float32{cur[0] + x, cur[1] + y}
+			}
+			return [2]float32{x, y}
+		}
+		switch op &^ 0x20 {
+		case 'M':
+			cur = pt(v[0], v[1])
+			start = cur
+			out = append(out, svgSegment{op: 'M', pts: [3][2]float32{cur}})
+			op = 'L' | op&0x20 // further pairs are lines
+			continue
+		case 'L':
+			cur = pt(v[0], v[1])
+			out = append(out, svgSegment{op: 'L', pts: [3][2]float32{cur}})
+			continue
+		case 'H':
+			x := v[0]
+			if rel {
+				x += cur[0]
+			}
+			cur = [2]float32{x, cur[1]}
+			out = append(out, svgSegment{op: 'L', pts: [3][2]float32{cur}})
+			continue
+		case 'V':
+			y := v[0]
+			if rel {
+				y += cur[1]
+			}
+			cur = [2]float32{cur[0], y}
+			out = append(out, svgSegment{op: 'L', pts: [3][2]float32{cur}})
+			continue
+		case 'Q':
+			c, e := pt(v[0], v[1]), pt(v[2], v[3])
+			// Raised to the cubic the rasterizer is called with.
+			c1 := [2]float32{cur[0] + 2.0/3*(c[0]-cur[0]), cur[1] + 2.0/3*(c[1]-cur[1])}
+			c2 := [2]float32{e[0] + 2.0/3*(c[0]-e[0]), e[1] + 2.0/3*(c[1]-e[1])}
+			out = append(out, svgSegment{op: 'C', pts: [3][2]float32{c1, c2, e}})
+			cur = e
+			continue
+		}
+		// C
+		c1, c2, e := pt(v[0], v[1]), pt(v[2], v[3]), pt(v[4], v[5])
+		out = append(out, svgSegment{op: 'C', pts: [3][2]float32{c1, c2, e}})
+		cur = e
+	}
+	return out, nil
+}
+
+// svgTokens splits path data into command letters and numbers.
+func svgTokens(d string) []string {
+	var toks []string
+	num := strings.Builder{}
+	flush := func() {
+		if num.Len() > 0 {
+			toks = append(toks, num.String())
+			num.Reset()
+		}
+	}
+	for _, c := range d {
+		switch {
+		case strings.ContainsRune("MmLlHhVvCcQqZz", c):
+			flush()
+			toks = append(toks, string(c))
+		case c == ' ' || c == ',' || c == '\n' || c == '\t':
+			flush()
+		case c == '-' && num.Len() > 0:
+			flush()
+			num.WriteRune(c)
+		default:
+			num.WriteRune(c)
+		}
+	}
+	flush()
+	return toks
+}
+
+// fillSVGPath fills p, mapped to the pixels of cell by at, with c.
+func fillSVGPath(img *image.RGBA, r *vector.Rasterizer, cell image.Rectangle, p svgPath, at func(x, y float32) (float32, float32), c color.RGBA) {
+	r.Reset(cell.Dx(), cell.Dy())
+	r.DrawOp = draw.Over
+	for _, sg := range p {
+		switch sg.op {
+		case 'M':
+			r.MoveTo(at(sg.pts[0][0], sg.pts[0][1]))
+		case 'L':
+			r.LineTo(at(sg.pts[0][0], sg.pts[0][1]))
+		case 'C':
+			x1, y1 := at(sg.pts[0][0], sg.pts[0][1])
+			x2, y2 := at(sg.pts[1][0], sg.pts[1][1])
+			x3, y3 := at(sg.pts[2][0], sg.pts[2][1])
+			r.CubeTo(x1, y1, x2, y2, x3, y3)
+		case 'Z':
+			r.ClosePath()
+		}
+	}
+	r.Draw(img, cell, image.NewUniform(c), image.Point{})
+}
@@ -0,0 +1,204 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	overpassURL         = "https://overpass-api.de/api/interpreter"
+	overpassMaxBytes    = 64 << 20
+	skylineGround       = 0.88 // fraction of height where buildings stand
+	defaultLevelHeightM = 3.0
+)
+
+// skyGradients holds top and horizon colors per time of day.
+var skyGradients = map[string][2]color.RGBA{
+	"dawn":  {{0x2b, 0x2f, 0x5e, 0xff}, {0xf6, 0xa8, 0x7c, 0xff}},
+	"day":   {{0x3a, 0x7b, 0xd5, 0xff}, {0xb8, 0xdc, 0xf5, 0xff}},
+	"dusk":  {{0x1d, 0x1b, 0x4a, 0xff}, {0xe9, 0x6d, 0x4c, 0xff}},
+	"night": {{0x03, 0x05, 0x12, 0xff}, {0x1b, 0x25, 0x4a, 0xff}},
+}
+
+// cityscapeSource renders a skyline silhouette from OpenStreetMap building
+// footprints queried through the Overpass API.
+type cityscapeSource struct {
+	client    *fetch.Client
+	bbox      config.BBox
+	timeOfDay string
+}
+
+func newCityscapeSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	bbox, err := config.ParseBBox(cfg.OverpassBBox)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := skyGradients[cfg.CityscapeTimeOfDay]; !ok {
+		return nil, fmt.Errorf("unknown cityscape time of day %q", cfg.CityscapeTimeOfDay)
+	}
+	return &cityscapeSource{client: deps.Client, bbox: bbox, timeOfDay: cfg.CityscapeTimeOfDay}, nil
+}
+
+func (s *cityscapeSource) Name() string { return "cityscape" }
+
+func (s *cityscapeSource) Host() string { return hostOf(overpassURL) }
+
+func (s *cityscapeSource) Fetch(ctx context.Context) (*Candidate, error) {
+	buildings, err := s.queryBuildings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(buildings) == 0 {
+		return nil, fmt.Errorf("no buildings found in bbox")
+	}
+	img := renderSkyline(buildings, s.bbox, s.timeOfDay)
+
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path}, nil
+}
+
+// building is a footprint reduced to what the skyline needs.
+type building struct {
+	minLon, maxLon float64
+	lat            float64 // centroid, used for depth
+	height         float64 // meters
+}
+
+type overpassResponse struct {
+	Elements []struct {
+		Tags     map[string]string `json:"tags"`
+		Geometry []struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"geometry"`
+	} `json:"elements"`
+}
+
+func (s *cityscapeSource) queryBuildings(ctx context.Context) ([]building, error) {
+	query := fmt.Sprintf(`[out:json][timeout:25];way["building"](%f,%f,%f,%f);out geom;`,
+		s.bbox.South, s.bbox.West, s.bbox.North, s.bbox.East)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, overpassURL,
+		strings.NewReader(url.Values{"data": {query}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("overpass bad status: %s", resp.Status)
+	}
+	var or overpassResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, overpassMaxBytes)).Decode(&or); err != nil {
+		return nil, err
+	}
+
+	var out []building
+	for _, el := range or.Elements {
+		if len(el.Geometry) < 3 {
+			continue
+		}
+		b := building{minLon: math.Inf(1), maxLon: math.Inf(-1), height: buildingHeight(el.Tags)}
+		for _, g := range el.Geometry {
+			b.minLon = math.Min(b.minLon, g.Lon)
+			b.maxLon = math.Max(b.maxLon, g.Lon)
+			b.lat += g.Lat
+		}
+		b.lat /= float64(len(el.Geometry))
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// buildingHeight estimates height in meters from OSM tags.
+func buildingHeight(tags map[string]string) float64 {
+	if h, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(tags["height"]), " m"), 64); err == nil && h > 0 {
+		return h
+	}
+	if l, err := strconv.ParseFloat(tags["building:levels"], 64); err == nil && l > 0 {
+		return l * defaultLevelHeightM
+	}
+	return 2 * defaultLevelHeightM
+}
+
+// renderSkyline draws buildings as silhouettes against the sky gradient for
+// timeOfDay. Northern (farther) buildings are drawn first in a lighter haze.
+func renderSkyline(bs []building, bbox config.BBox, timeOfDay string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	grad := skyGradients[timeOfDay]
+	imaging.FillVerticalGradient(img, grad[0], grad[1])
+
+	sort.Slice(bs, func(i, j int) bool { return bs[i].lat > bs[j].lat })
+	maxH := 0.0
+	for _, b := range bs {
+		maxH = math.Max(maxH, b.height)
+	}
+	ground := float32(renderHeight * skylineGround)
+	scaleY := float64(ground) * 0.85 / maxH
+	scaleX := renderWidth / (bbox.East - bbox.West)
+
+	night := timeOfDay == "night" || timeOfDay == "dusk"
+	rnd := rand.New(rand.NewSource(int64(len(bs))))
+	z := vector.NewRasterizer(renderWidth, renderHeight)
+	for _, b := range bs {
+		depth := (b.lat - bbox.South) / (bbox.North - bbox.South) // 0 near, 1 far
+		shade := color.RGBA{
+			R: uint8(10 + 60*depth), G: uint8(10 + 60*depth), B: uint8(20 + 70*depth), A: 0xff,
+		}
+		x0 := float32((b.minLon - bbox.West) * scaleX)
+		x1 := float32((b.maxLon - bbox.West) * scaleX)
+		if x1-x0 < 2 {
+			x1 = x0 + 2
+		}
+		top := ground - float32(b.height*scaleY)
+
+		z.Reset(renderWidth, renderHeight)
+		z.MoveTo(x0, ground)
+		z.LineTo(x0, top)
+		z.LineTo(x1, top)
+		z.LineTo(x1, ground)
+		z.ClosePath()
+		z.Draw(img, img.Bounds(), image.NewUniform(shade), image.Point{})
+
+		if night && depth < 0.5 {
+			drawLitWindows(img, rnd, int(x0), int(top), int(x1), int(ground))
+		}
+	}
+	imaging.FillRect(img, image.Rect(0, int(ground), renderWidth, renderHeight), color.RGBA{5, 5, 10, 0xff})
+	return img
+}
+
+// drawLitWindows sprinkles small warm rectangles inside a building rectangle.
+func drawLitWindows(img *image.RGBA, rnd *rand.Rand, x0, y0, x1, y1 int) {
+	lit := color.RGBA{0xff, 0xd8, 0x80, 0xff}
+	for y := y0 + 4; y+3 < y1; y += 8 {
+		for x := x0 + 3; x+3 < x1; x += 7 {
+			if rnd.Intn(5) == 0 {
+				imaging.FillRect(img, image.Rect(x, y, x+3, y+4), lit)
+			}
+		}
+	}
+}
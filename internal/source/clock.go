@@ -0,0 +1,278 @@
+package source
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	clockDigitalSize = 320
+	clockDateSize    = 56
+	clockRadius      = 400
+	clockWordSize    = 64
+	clockWordCell    = 96
+)
+
+//go:embed fonts/*.ttf
+var clockFontFiles embed.FS
+
+// clockFonts maps the built-in "clock_font" choices to their files.
+var clockFonts = map[string]string{
+	"go":           "Go-Regular.ttf",
+	"go-bold":      "Go-Bold.ttf",
+	"go-medium":    "Go-Medium.ttf",
+	"go-mono":      "Go-Mono.ttf",
+	"go-smallcaps": "Go-Smallcaps.ttf",
+}
+
+// wordClockGrid is the classic English word clock; wordClockWords maps each
+// word to its row and first and last column.
+var wordClockGrid = []string{
+	"ITLISASAMPM",
+	"ACQUARTERDC",
+	"TWENTYFIVEX",
+	"HALFSTENFTO",
+	"PASTERUNINE",
+	"ONESIXTHREE",
+	"FOURFIVETWO",
+	"EIGHTELEVEN",
+	"SEVENTWELVE",
+	"TENSEOCLOCK",
+}
+
+var wordClockWords = map[string][3]int{
+	"IT": {0, 0, 1}, "IS": {0, 3, 4},
+	"A": {1, 0, 0}, "QUARTER": {1, 2, 8},
+	"TWENTY": {2, 0, 5}, "FIVE_MIN": {2, 6, 9},
+	"HALF": {3, 0, 3}, "TEN_MIN": {3, 5, 7}, "TO": {3, 9, 10},
+	"PAST": {4, 0, 3}, "9": {4, 7, 10},
+	"1": {5, 0, 2}, "6": {5, 3, 5}, "3": {5, 6, 10},
+	"4": {6, 0, 3}, "5": {6, 4, 7}, "2": {6, 8, 10},
+	"8": {7, 0, 4}, "11": {7, 5, 10},
+	"7": {8, 0, 4}, "12": {8, 5, 10},
+	"10": {9, 0, 2}, "OCLOCK": {9, 5, 10},
+}
+
+// clockSource renders the current time offline.
+type clockSource struct {
+	now        func() time.Time
+	style      string
+	font       *opentype.Font
+	foreground color.RGBA
+	background []color.RGBA
+}
+
+func newClockSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	var data []byte
+	var err error
+	if name, ok := clockFonts[cfg.ClockFont]; ok {
+		data, err = clockFontFiles.ReadFile("fonts/" + name)
+	} else {
+		data, err = os.ReadFile(cfg.ClockFont)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("clock_font: %w", err)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("clock_font %s: %w", cfg.ClockFont, err)
+	}
+	fg, err := config.ParseColors(cfg.ClockForeground)
+	if err != nil {
+		return nil, err
+	}
+	bg, err := config.ParseColors(cfg.ClockBackground)
+	if err != nil {
+		return nil, err
+	}
+	return &clockSource{now: deps.Now, style: cfg.ClockStyle, font: f, foreground: fg[0], background: bg}, nil
+}
+
+func (s *clockSource) Name() string { return "clock" }
+
+func (s *clockSource) Fetch(ctx context.Context) (*Candidate, error) {
+	now := s.now()
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	top, bottom := s.background[0], s.background[len(s.background)-1]
+	imaging.FillVerticalGradient(img, top, bottom)
+
+	var err error
+	switch s.style {
+	case "analog":
+		s.drawAnalog(img, now)
+	case "word-clock":
+		err = s.drawWordClock(img, now)
+	default:
+		err = s.drawDigital(img, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "Clock " + now.Format("15:04")}, nil
+}
+
+func (s *clockSource) face(size float64) (font.Face, error) {
+	return opentype.NewFace(s.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func (s *clockSource) drawDigital(img *image.RGBA, now time.Time) error {
+	big, err := s.face(clockDigitalSize)
+	if err != nil {
+		return err
+	}
+	small, err := s.face(clockDateSize)
+	if err != nil {
+		return err
+	}
+	t, date := now.Format("15:04"), now.Format("Monday, January 2")
+	imaging.DrawText(img, big, (renderWidth-imaging.TextWidth(big, t))/2, renderHeight/2+clockDigitalSize/3, t, s.foreground)
+	dim := imaging.LerpColor(s.foreground, s.background[0], 0.35)
+	imaging.DrawText(img, small, (renderWidth-imaging.TextWidth(small, date))/2, renderHeight/2+clockDigitalSize/3+clockDateSize*2, date, dim)
+	return nil
+}
+
+// drawAnalog draws a face with hour ticks and hour and minute hands.
+func (s *clockSource) drawAnalog(img *image.RGBA, now time.Time) {
+	r := vector.NewRasterizer(renderWidth, renderHeight)
+	cx, cy := float64(renderWidth)/2, float64(renderHeight)/2
+	fill := func(pts [][2]float64, c color.RGBA) {
+		r.Reset(renderWidth, renderHeight)
+		r.DrawOp = draw.Over
+		for i, p := range pts {
+			if i == 0 {
+				r.MoveTo(float32(p[0]), float32(p[1]))
+			} else {
+				r.LineTo(float32(p[0]), float32(p[1]))
+			}
+		}
+		r.ClosePath()
+		r.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{})
+	}
+	// hand returns the quad of a bar from inner to outer radius at angle a
+	// (radians clockwise from 12), w pixels wide.
+	hand := func(a, inner, outer, w float64) [][2]float64 {
+		dx, dy := math.Sin(a), -math.Cos(a)
+		px, py := -dy*w/2, dx*w/2
+		return [][2]float64{
+			{cx + dx*inner + px, cy + dy*inner + py}, {cx + dx*outer + px, cy + dy*outer + py},
+			{cx + dx*outer - px, cy + dy*outer - py}, {cx + dx*inner - px, cy + dy*inner - py},
+		}
+	}
+
+	faceColor := imaging.LerpColor(s.background[0], s.foreground, 0.08)
+	var disc [][2]float64
+	for i := range 120 {
+		a := float64(i) / 120 * 2 * math.Pi
+		disc = append(disc, [2]float64{cx + math.Sin(a)*clockRadius, cy - math.Cos(a)*clockRadius})
+	}
+	fill(disc, faceColor)
+	for i := range 60 {
+		a := float64(i) / 60 * 2 * math.Pi
+		if i%5 == 0 {
+			fill(hand(a, clockRadius*0.82, clockRadius*0.95, 12), s.foreground)
+		} else {
+			fill(hand(a, clockRadius*0.9, clockRadius*0.95, 4), imaging.LerpColor(s.foreground, faceColor, 0.5))
+		}
+	}
+	minutes := float64(now.Minute())
+	hours := float64(now.Hour()%12) + minutes/60
+	fill(hand(hours/12*2*math.Pi, -clockRadius*0.1, clockRadius*0.5, 22), s.foreground)
+	fill(hand(minutes/60*2*math.Pi, -clockRadius*0.1, clockRadius*0.8, 14), s.foreground)
+	var hub [][2]float64
+	for i := range 24 {
+		a := float64(i) / 24 * 2 * math.Pi
+		hub = append(hub, [2]float64{cx + math.Sin(a)*20, cy - math.Cos(a)*20})
+	}
+	fill(hub, s.foreground)
+}
+
+// drawWordClock spells the time to the nearest five minutes on the letter
+// grid, lighting the words and dimming the rest.
+func (s *clockSource) drawWordClock(img *image.RGBA, now time.Time) error {
+	face, err := s.face(clockWordSize)
+	if err != nil {
+		return err
+	}
+	lit := map[[2]int]bool{}
+	for _, w := range wordClockPhrase(now) {
+		pos := wordClockWords[w]
+		for col := pos[1]; col <= pos[2]; col++ {
+			lit[[2]int{pos[0], col}] = true
+		}
+	}
+	dark := imaging.LerpColor(s.foreground, s.background[0], 0.85)
+	left := (renderWidth - len(wordClockGrid[0])*clockWordCell) / 2
+	top := (renderHeight - len(wordClockGrid)*clockWordCell) / 2
+	for row, letters := range wordClockGrid {
+		for col, ch := range letters {
+			c := dark
+			if lit[[2]int{row, col}] {
+				c = s.foreground
+			}
+			l := string(ch)
+			x := left + col*clockWordCell + (clockWordCell-imaging.TextWidth(face, l))/2
+			y := top + row*clockWordCell + (clockWordCell+clockWordSize*7/10)/2
+			imaging.DrawText(img, face, x, y, l, c)
+		}
+	}
+	return nil
+}
+
+// wordClockPhrase returns the wordClockWords keys spelling now, rounded down
+// to five minutes, e.g. IT IS TWENTY FIVE_MIN TO 3.
+func wordClockPhrase(now time.Time) []string {
+	words := []string{"IT", "IS"}
+	m := now.Minute() / 5 * 5
+	h := now.Hour()
+	if m > 30 {
+		h++
+	}
+	switch m {
+	case 0:
+	case 5, 55:
+		words = append(words, "FIVE_MIN")
+	case 10, 50:
+		words = append(words, "TEN_MIN")
+	case 15, 45:
+		words = append(words, "A", "QUARTER")
+	case 20, 40:
+		words = append(words, "TWENTY")
+	case 25, 35:
+		words = append(words, "TWENTY", "FIVE_MIN")
+	case 30:
+		words = append(words, "HALF")
+	}
+	switch {
+	case m == 0:
+	case m > 30:
+		words = append(words, "TO")
+	default:
+		words = append(words, "PAST")
+	}
+	if h %= 12; h == 0 {
+		h = 12
+	}
+	words = append(words, fmt.Sprint(h))
+	if m == 0 {
+		words = append(words, "OCLOCK")
+	}
+	return words
+}
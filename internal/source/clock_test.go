@@ -0,0 +1,190 @@
+package source
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+func TestWordClockPhrase(t *testing.T) {
+	tests := []struct {
+		hh, mm int
+		want   string
+	}{
+		{0, 0, "IT IS 12 OCLOCK"},
+		{9, 0, "IT IS 9 OCLOCK"},
+		{9, 4, "IT IS 9 OCLOCK"},
+		{9, 5, "IT IS FIVE_MIN PAST 9"},
+		{9, 15, "IT IS A QUARTER PAST 9"},
+		{9, 29, "IT IS TWENTY FIVE_MIN PAST 9"},
+		{9, 30, "IT IS HALF PAST 9"},
+		{9, 35, "IT IS TWENTY FIVE_MIN TO 10"},
+		{9, 45, "IT IS A QUARTER TO 10"},
+		{11, 55, "IT IS FIVE_MIN TO 12"},
+		{12, 40, "IT IS TWENTY TO 1"},
+		{23, 50, "IT IS TEN_MIN TO 12"},
+	}
+	for _, tt := range tests {
+		now := time.Date(2026, 3, 14, tt.hh, tt.mm, 0, 0, time.UTC)
+		if got := strings.Join(wordClockPhrase(now), " "); got != tt.want {
+			t.Errorf("%02d:%02d = %q, want %q", tt.hh, tt.mm, got, tt.want)
+		}
+	}
+}
+
+func TestWordClockWordsSpelled(t *testing.T) {
+	spelled := map[string]string{
+		"FIVE_MIN": "FIVE", "TEN_MIN": "TEN", "OCLOCK": "OCLOCK",
+		"1": "ONE", "2": "TWO", "3": "THREE", "4": "FOUR", "5": "FIVE", "6": "SIX",
+		"7": "SEVEN", "8": "EIGHT", "9": "NINE", "10": "TEN", "11": "ELEVEN", "12": "TWELVE",
+	}
+	for key, pos := range wordClockWords {
+		want, ok := spelled[key]
+		if !ok {
+			want = key
+		}
+		if got := wordClockGrid[pos[0]][pos[1] : pos[2]+1]; got != want {
+			t.Errorf("%s spells %q on the grid, want %q", key, got, want)
+		}
+	}
+}
+
+// renderClock renders the clock source for cfg at 09:30.
+func renderClock(t *testing.T, cfg config.Config) image.Image {
+	t.Helper()
+	cfg.Source = "clock"
+	src, err := New(cfg, Deps{Now: testNow})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	defer os.Remove(c.Path)
+	if c.Title != "Clock 09:30" {
+		t.Errorf("Title = %q", c.Title)
+	}
+	img, err := imaging.DecodeFile(c.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func near(got color.Color, want color.RGBA, tol int) bool {
+	c := color.RGBAModel.Convert(got).(color.RGBA)
+	d := func(a, b uint8) bool { return int(a)-int(b) <= tol && int(b)-int(a) <= tol }
+	return d(c.R, want.R) && d(c.G, want.G) && d(c.B, want.B)
+}
+
+// countNear counts the pixels of r within tol of c.
+func countNear(img image.Image, r image.Rectangle, c color.RGBA, tol int) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if near(img.At(x, y), c, tol) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestClockRender(t *testing.T) {
+	fg := color.RGBA{0xff, 0xd0, 0x40, 0xff}
+	top, bottom := color.RGBA{0x10, 0x20, 0x30, 0xff}, color.RGBA{0x30, 0x60, 0x90, 0xff}
+	center := image.Rect(renderWidth/2-400, renderHeight/2-300, renderWidth/2+400, renderHeight/2+300)
+	for _, style := range config.ClockStyles {
+		for _, font := range config.ClockFonts {
+			t.Run(style+"/"+font, func(t *testing.T) {
+				cfg := config.Default()
+				cfg.ClockStyle, cfg.ClockFont = style, font
+				cfg.ClockForeground, cfg.ClockBackground = "#ffd040", "#102030,#306090"
+				img := renderClock(t, cfg)
+				if b := img.Bounds(); b.Dx() != renderWidth || b.Dy() != renderHeight {
+					t.Fatalf("rendered %v", b)
+				}
+				if !near(img.At(0, 0), top, 2) || !near(img.At(0, renderHeight-1), bottom, 2) {
+					t.Errorf("corners %v, %v; want the %v to %v gradient", img.At(0, 0), img.At(0, renderHeight-1), top, bottom)
+				}
+				if n := countNear(img, center, fg, 8); n < 2000 {
+					t.Errorf("%d foreground pixels in the middle, want the clock drawn there", n)
+				}
+				outside := image.Rect(0, 0, 200, renderHeight)
+				if n := countNear(img, outside, fg, 8); n != 0 {
+					t.Errorf("%d foreground pixels at the left edge", n)
+				}
+			})
+		}
+	}
+}
+
+func TestClockAnalogHands(t *testing.T) {
+	cfg := config.Default()
+	cfg.ClockStyle = "analog"
+	cfg.ClockForeground, cfg.ClockBackground = "#ffffff", "#000000"
+	img := renderClock(t, cfg)
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	cx, cy := renderWidth/2, renderHeight/2
+	// At 09:30 the minute hand points at 6 and the hour hand between 9 and 10.
+	if !near(img.At(cx, cy+clockRadius*6/10), white, 4) {
+		t.Errorf("no minute hand below the hub: %v", img.At(cx, cy+clockRadius*6/10))
+	}
+	if near(img.At(cx, cy-clockRadius*6/10), white, 4) {
+		t.Error("a hand points at 12")
+	}
+	if !near(img.At(cx-clockRadius*35/100, cy-clockRadius*10/100), white, 4) {
+		t.Errorf("no hour hand towards 9:30: %v", img.At(cx-clockRadius*35/100, cy-clockRadius*10/100))
+	}
+}
+
+func TestClockFontFile(t *testing.T) {
+	dir := t.TempDir()
+	ttf, err := clockFontFiles.ReadFile("fonts/Go-Mono.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(dir, "Моноширинный шрифт.ttf")
+	bad := filepath.Join(dir, "bad.ttf")
+	os.WriteFile(good, ttf, 0o644)
+	os.WriteFile(bad, []byte("not a font"), 0o644)
+	tests := []struct {
+		font string
+		ok   bool
+	}{
+		{good, true},
+		{bad, false},
+		{filepath.Join(dir, "missing.ttf"), false},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Source, cfg.ClockFont = "clock", tt.font
+		if _, err := New(cfg, Deps{Now: testNow}); (err == nil) != tt.ok {
+			t.Errorf("clock_font %s: err = %v, want ok %v", filepath.Base(tt.font), err, tt.ok)
+		}
+	}
+}
+
+func TestClockFontsEmbedded(t *testing.T) {
+	names := make([]string, 0, len(clockFonts))
+	for name, file := range clockFonts {
+		names = append(names, name)
+		if _, err := clockFontFiles.ReadFile("fonts/" + file); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, config.ClockFonts) {
+		t.Errorf("clockFonts = %v, config.ClockFonts = %v", names, config.ClockFonts)
+	}
+}
@@ -0,0 +1,253 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	// Coolors has no public API; its trending page links every palette as
+	// /palette/rrggbb-rrggbb-...
+	coolorsTrendingURL = "https://coolors.co/palettes/trending"
+	coolorsPalettePath = "/palette/"
+	unsplashSearchURL  = "https://api.unsplash.com/search/photos"
+	unsplashMaxBytes   = 2 << 20
+)
+
+// coolorsSource picks a trending Coolors palette and renders it or finds a
+// photo in its dominant hue.
+type coolorsSource struct {
+	client      *fetch.Client
+	maxHTMLBody int64
+	mode        string
+	unsplashKey string
+	size        image.Point
+	query       string
+}
+
+func newCoolorsSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.CoolorsMatchMode == "download" && cfg.UnsplashAccessKey == "" {
+		return nil, fmt.Errorf("coolors source in download mode needs unsplash_access_key")
+	}
+	size := deps.Screen
+	if size.X <= 0 || size.Y <= 0 {
+		size = image.Pt(renderWidth, renderHeight)
+	}
+	query := "wallpaper"
+	if deps.PreferDark {
+		query = "dark night wallpaper"
+	}
+	return &coolorsSource{client: deps.Client, maxHTMLBody: cfg.MaxHTMLBodyBytes,
+		mode: cfg.CoolorsMatchMode, unsplashKey: cfg.UnsplashAccessKey, size: size, query: query}, nil
+}
+
+func (s *coolorsSource) Name() string { return "coolors" }
+
+func (s *coolorsSource) Host() string { return hostOf(coolorsTrendingURL) }
+
+func (s *coolorsSource) Fetch(ctx context.Context) (*Candidate, error) {
+	palette, slug, err := s.randomPalette(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.mode == "download" {
+		return s.downloadMatching(ctx, palette)
+	}
+	path, err := imaging.WriteTempBMP(renderPalette(palette))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, SourceURL: "https://coolors.co/" + slug, Title: "Palette " + slug}, nil
+}
+
+// randomPalette returns the colors of a random trending palette and its
+// hyphenated hex slug.
+func (s *coolorsSource) randomPalette(ctx context.Context) ([]color.RGBA, string, error) {
+	resp, err := s.client.Get(ctx, coolorsTrendingURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	doc, err := htmlquery.Parse(io.LimitReader(resp.Body, s.maxHTMLBody))
+	if err != nil {
+		return nil, "", err
+	}
+	var slugs []string
+	for _, a := range htmlquery.Find(doc, "//a[starts-with(@href, '"+coolorsPalettePath+"')]") {
+		slug := strings.TrimPrefix(htmlquery.SelectAttr(a, "href"), coolorsPalettePath)
+		if _, err := parsePalette(slug); err == nil {
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil, "", errors.New("no palettes found on the coolors trending page")
+	}
+	slug := slugs[random.Intn(len(slugs))]
+	palette, _ := parsePalette(slug)
+	return palette, slug, nil
+}
+
+// parsePalette decodes "264653-2a9d8f-e9c46a" into 3 to 5 colors.
+func parsePalette(slug string) ([]color.RGBA, error) {
+	parts := strings.Split(slug, "-")
+	if len(parts) < 3 || len(parts) > 10 {
+		return nil, fmt.Errorf("%q is not a palette", slug)
+	}
+	if len(parts) > 5 {
+		parts = parts[:5]
+	}
+	out := make([]color.RGBA, len(parts))
+	for i, p := range parts {
+		if len(p) != 6 {
+			return nil, fmt.Errorf("%q is not a hex color", p)
+		}
+		v, err := strconv.ParseUint(p, 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+	}
+	return out, nil
+}
+
+// renderPalette blends the palette top to bottom.
+func renderPalette(palette []color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	bands := len(palette) - 1
+	for y := 0; y < renderHeight; y++ {
+		pos := float64(y) / float64(renderHeight-1) * float64(bands)
+		i := min(int(pos), bands-1)
+		c := imaging.LerpColor(palette[i], palette[i+1], smoothstep(pos-float64(i)))
+		imaging.FillRect(img, image.Rect(0, y, renderWidth, y+1), c)
+	}
+	return img
+}
+
+func smoothstep(t float64) float64 { return t * t * (3 - 2*t) }
+
+// downloadMatching searches Unsplash for a landscape photo in the palette's
+// dominant hue.
+func (s *coolorsSource) downloadMatching(ctx context.Context, palette []color.RGBA) (*Candidate, error) {
+	var body struct {
+		Results []struct {
+			Description    string `json:"description"`
+			AltDescription string `json:"alt_description"`
+			URLs           struct {
+				Raw string `json:"raw"`
+			} `json:"urls"`
+			Links struct {
+				HTML string `json:"html"`
+			} `json:"links"`
+			User struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"results"`
+	}
+	q := url.Values{
+		"query":       {s.query},
+		"color":       {unsplashColor(dominantColor(palette))},
+		"orientation": {unsplashOrientation(s.size)},
+		"per_page":    {"30"},
+		"client_id":   {s.unsplashKey},
+	}
+	if err := s.client.GetJSON(ctx, unsplashSearchURL+"?"+q.Encode(), unsplashMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Results) == 0 {
+		return nil, fmt.Errorf("unsplash found no %s photos", q.Get("color"))
+	}
+	r := body.Results[random.Intn(len(body.Results))]
+	// raw takes imgix sizing parameters.
+	dl := fmt.Sprintf("%s&w=%d&h=%d&fit=crop", r.URLs.Raw, s.size.X, s.size.Y)
+	path, err := s.client.DownloadToTemp(ctx, dl)
+	if err != nil {
+		return nil, err
+	}
+	title := r.Description
+	if title == "" {
+		title = r.AltDescription
+	}
+	return &Candidate{Path: path, SourceURL: r.Links.HTML, Title: title, Author: r.User.Name}, nil
+}
+
+// dominantColor is the most saturated palette color, which carries the hue.
+func dominantColor(palette []color.RGBA) color.RGBA {
+	best, bestSat := palette[0], -1.0
+	for _, c := range palette {
+		if _, sat, _ := hsl(c); sat > bestSat {
+			best, bestSat = c, sat
+		}
+	}
+	return best
+}
+
+// unsplashColor maps c to one of Unsplash's color filter values.
+func unsplashColor(c color.RGBA) string {
+	h, sat, l := hsl(c)
+	switch {
+	case l < 0.15:
+		return "black"
+	case l > 0.9:
+		return "white"
+	case sat < 0.15:
+		return "black_and_white"
+	}
+	switch {
+	case h < 15 || h >= 345:
+		return "red"
+	case h < 40:
+		return "orange"
+	case h < 70:
+		return "yellow"
+	case h < 160:
+		return "green"
+	case h < 195:
+		return "teal"
+	case h < 255:
+		return "blue"
+	case h < 290:
+		return "purple"
+	default:
+		return "magenta"
+	}
+}
+
+// hsl returns hue in degrees and saturation and lightness in [0, 1].
+func hsl(c color.RGBA) (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (hi + lo) / 2
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - math.Abs(2*l-1))
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
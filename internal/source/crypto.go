@@ -0,0 +1,226 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	coinGeckoMarketChartURL = "https://api.coingecko.com/api/v3/coins/%s/market_chart"
+	cryptoChartDays         = 30
+	cryptoMaxBytes          = 2 << 20
+	chartMarginX            = 120
+	chartMarginTop          = 200
+	chartMarginBottom       = 120
+	chartLineWidth          = 4
+	chartGridLines          = 5
+)
+
+// cryptoChartSource plots a coin's 30-day price history from CoinGecko.
+type cryptoChartSource struct {
+	client    *fetch.Client
+	coin      string
+	currency  string
+	chartType string
+}
+
+func newCryptoChartSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.CryptoSymbol == "" || cfg.CryptoCurrency == "" {
+		return nil, fmt.Errorf("crypto_chart source needs crypto_symbol and crypto_currency")
+	}
+	return &cryptoChartSource{
+		client:    deps.Client,
+		coin:      strings.ToLower(cfg.CryptoSymbol),
+		currency:  strings.ToLower(cfg.CryptoCurrency),
+		chartType: cfg.ChartType,
+	}, nil
+}
+
+func (s *cryptoChartSource) Name() string { return "crypto_chart" }
+
+func (s *cryptoChartSource) Host() string { return hostOf(coinGeckoMarketChartURL) }
+
+// pricePoint is one sample of the market chart.
+type pricePoint struct {
+	Time  time.Time
+	Price float64
+}
+
+// candle is one day of samples.
+type candle struct {
+	Open, High, Low, Close float64
+}
+
+func (s *cryptoChartSource) Fetch(ctx context.Context) (*Candidate, error) {
+	prices, err := s.prices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	img, err := s.render(prices)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: "https://www.coingecko.com/en/coins/" + url.PathEscape(s.coin),
+		Title:     s.coin + " price",
+	}, nil
+}
+
+func (s *cryptoChartSource) prices(ctx context.Context) ([]pricePoint, error) {
+	var body struct {
+		Prices [][2]float64 `json:"prices"` // [ms since epoch, price]
+	}
+	q := url.Values{"vs_currency": {s.currency}, "days": {fmt.Sprint(cryptoChartDays)}}
+	u := fmt.Sprintf(coinGeckoMarketChartURL, url.PathEscape(s.coin)) + "?" + q.Encode()
+	if err := s.client.GetJSON(ctx, u, cryptoMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Prices) < 2 {
+		return nil, fmt.Errorf("coingecko returned %d prices for %s", len(body.Prices), s.coin)
+	}
+	out := make([]pricePoint, len(body.Prices))
+	for i, p := range body.Prices {
+		out[i] = pricePoint{Time: time.UnixMilli(int64(p[0])).UTC(), Price: p[1]}
+	}
+	return out, nil
+}
+
+// dailyCandles groups prices by UTC day.
+func dailyCandles(prices []pricePoint) []candle {
+	var out []candle
+	var day time.Time
+	for _, p := range prices {
+		d := p.Time.Truncate(24 * time.Hour)
+		if len(out) == 0 || !d.Equal(day) {
+			day = d
+			out = append(out, candle{Open: p.Price, High: p.Price, Low: p.Price, Close: p.Price})
+			continue
+		}
+		c := &out[len(out)-1]
+		c.High = math.Max(c.High, p.Price)
+		c.Low = math.Min(c.Low, p.Price)
+		c.Close = p.Price
+	}
+	return out
+}
+
+func (s *cryptoChartSource) render(prices []pricePoint) (*image.RGBA, error) {
+	titleFace, err := imaging.NewFace(56, true)
+	if err != nil {
+		return nil, err
+	}
+	labelFace, err := imaging.NewFace(24, false)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, color.RGBA{0x0d, 0x11, 0x17, 0xff}, color.RGBA{0x16, 0x1b, 0x22, 0xff})
+
+	lo, hi := prices[0].Price, prices[0].Price
+	for _, p := range prices {
+		lo, hi = math.Min(lo, p.Price), math.Max(hi, p.Price)
+	}
+	if hi == lo {
+		hi, lo = hi*1.01, lo*0.99
+	}
+	plot := image.Rect(chartMarginX, chartMarginTop, renderWidth-chartMarginX, renderHeight-chartMarginBottom)
+	y := func(price float64) int {
+		return plot.Max.Y - int((price-lo)/(hi-lo)*float64(plot.Dy()))
+	}
+
+	grid := color.RGBA{0x30, 0x36, 0x3d, 0xff}
+	gray := color.RGBA{0x8b, 0x94, 0x9e, 0xff}
+	for i := 0; i <= chartGridLines; i++ {
+		price := lo + (hi-lo)*float64(i)/chartGridLines
+		gy := y(price)
+		imaging.FillRect(img, image.Rect(plot.Min.X, gy, plot.Max.X, gy+1), grid)
+		imaging.DrawText(img, labelFace, plot.Max.X+12, gy+8, formatPrice(price), gray)
+	}
+
+	up := color.RGBA{0x30, 0xcc, 0x5a, 0xff}
+	down := color.RGBA{0xf6, 0x35, 0x38, 0xff}
+	first, last := prices[0].Price, prices[len(prices)-1].Price
+	if s.chartType == "candlestick" {
+		candles := dailyCandles(prices)
+		step := float64(plot.Dx()) / float64(len(candles))
+		body := max(int(step*0.6), 1)
+		for i, c := range candles {
+			col := up
+			if c.Close < c.Open {
+				col = down
+			}
+			cx := plot.Min.X + int(step*(float64(i)+0.5))
+			imaging.FillRect(img, image.Rect(cx-1, y(c.High), cx+1, y(c.Low)+1), col)
+			top, bottom := y(math.Max(c.Open, c.Close)), y(math.Min(c.Open, c.Close))
+			imaging.FillRect(img, image.Rect(cx-body/2, top, cx+body/2, max(bottom, top+2)), col)
+		}
+	} else {
+		col := up
+		if last < first {
+			col = down
+		}
+		start, span := prices[0].Time, prices[len(prices)-1].Time.Sub(prices[0].Time)
+		x := func(t time.Time) int {
+			return plot.Min.X + int(float64(t.Sub(start))/float64(span)*float64(plot.Dx()))
+		}
+		for i := 1; i < len(prices); i++ {
+			drawLine(img, x(prices[i-1].Time), y(prices[i-1].Price), x(prices[i].Time), y(prices[i].Price), col)
+		}
+	}
+
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	title := fmt.Sprintf("%s / %s", strings.ToUpper(s.coin), strings.ToUpper(s.currency))
+	imaging.DrawText(img, titleFace, chartMarginX, 110, title, white)
+	change := fmt.Sprintf("%s  %+.2f%% in %d days", formatPrice(last), (last-first)/first*100, cryptoChartDays)
+	changeCol := up
+	if last < first {
+		changeCol = down
+	}
+	imaging.DrawText(img, labelFace, chartMarginX, 160, change, changeCol)
+	return img, nil
+}
+
+// drawLine draws a chartLineWidth-thick segment by stamping squares along it.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	steps := max(abs(x1-x0), abs(y1-y0), 1)
+	half := chartLineWidth / 2
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+		imaging.FillRect(img, image.Rect(x-half, y-half, x+half, y+half), c)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// formatPrice keeps a few significant digits for both large and tiny prices.
+func formatPrice(p float64) string {
+	switch {
+	case p >= 1000:
+		return fmt.Sprintf("%.0f", p)
+	case p >= 1:
+		return fmt.Sprintf("%.2f", p)
+	default:
+		return fmt.Sprintf("%.6f", p)
+	}
+}
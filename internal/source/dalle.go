@@ -0,0 +1,170 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	openAIImagesURL = "https://api.openai.com/v1/images/generations"
+	dalleMaxBytes   = 1 << 20
+	// dalleCacheImage and dalleCacheMeta keep the day's picture in the app
+	// dir: each generation costs money, so a day gets one.
+	dalleCacheImage = "dalle_today.png"
+	dalleCacheMeta  = "dalle_today.json"
+	dalleDateLayout = "2006-01-02"
+)
+
+// dalleSource generates a picture from a prompt with the OpenAI images API,
+// once a day; changes later that day reuse it.
+type dalleSource struct {
+	client *fetch.Client
+	now    func() time.Time
+	apiKey string
+	// meta is what the cached picture must have been generated from to
+	// be reused, but for the date.
+	meta     dalleMeta
+	cacheDir string // "" when there is no app dir to keep the picture in
+}
+
+// dalleMeta describes the cached picture.
+type dalleMeta struct {
+	Date          string `json:"date"`
+	Model         string `json:"model"`
+	Prompt        string `json:"prompt"`
+	Size          string `json:"size"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+func newDALLESource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.OpenAIAPIKey == "" {
+		return nil, errors.New("dalle source needs openai_api_key")
+	}
+	if cfg.DALLEPrompt == "" {
+		return nil, errors.New("dalle source needs dalle_prompt")
+	}
+	return &dalleSource{client: deps.Client, now: deps.Now, apiKey: cfg.OpenAIAPIKey,
+		meta: dalleMeta{Model: cfg.DALLEModel, Prompt: cfg.DALLEPrompt, Size: cfg.DALLESize}, cacheDir: deps.AppDir}, nil
+}
+
+func (s *dalleSource) Name() string { return "dalle" }
+
+func (s *dalleSource) Host() string { return hostOf(openAIImagesURL) }
+
+func (s *dalleSource) Fetch(ctx context.Context) (*Candidate, error) {
+	want := s.meta
+	want.Date = s.now().Format(dalleDateLayout)
+	if got, ok := s.cached(); ok && got.Date == want.Date && got.Model == want.Model && got.Prompt == want.Prompt && got.Size == want.Size {
+		path, err := copyToTemp(filepath.Join(s.cacheDir, dalleCacheImage))
+		if err == nil {
+			return s.candidate(path, got), nil
+		}
+		fmt.Println("dalle: cached picture unusable, generating a new one:", err)
+	}
+
+	imageURL, revised, err := s.generate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.client.DownloadToTemp(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	want.RevisedPrompt = revised
+	s.cache(path, want)
+	return s.candidate(path, want), nil
+}
+
+func (s *dalleSource) generate(ctx context.Context) (imageURL, revisedPrompt string, err error) {
+	b, err := json.Marshal(map[string]any{"model": s.meta.Model, "prompt": s.meta.Prompt, "size": s.meta.Size, "n": 1})
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, openAIImagesURL, bytes.NewReader(b))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data []struct {
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, dalleMaxBytes)).Decode(&out); err != nil {
+		return "", "", fmt.Errorf("openai images bad status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("openai images bad status: %s: %s", resp.Status, out.Error.Message)
+	}
+	if len(out.Data) == 0 || out.Data[0].URL == "" {
+		return "", "", errors.New("openai images returned no image")
+	}
+	return out.Data[0].URL, out.Data[0].RevisedPrompt, nil
+}
+
+func (s *dalleSource) candidate(path string, meta dalleMeta) *Candidate {
+	title := meta.RevisedPrompt
+	if title == "" {
+		title = meta.Prompt
+	}
+	return &Candidate{Path: path, Title: title, Author: "OpenAI " + meta.Model, Category: "generated"}
+}
+
+// cached returns the metadata of the cached picture, if there is one.
+func (s *dalleSource) cached() (dalleMeta, bool) {
+	var meta dalleMeta
+	if s.cacheDir == "" {
+		return meta, false
+	}
+	b, err := os.ReadFile(filepath.Join(s.cacheDir, dalleCacheMeta))
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		fmt.Println("dalle: discarding unreadable cache info:", err)
+		return meta, false
+	}
+	return meta, true
+}
+
+// cache keeps a copy of the picture at path as the day's.
+func (s *dalleSource) cache(path string, meta dalleMeta) {
+	if s.cacheDir == "" {
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		err = store.WriteFileAtomic(filepath.Join(s.cacheDir, dalleCacheImage), b)
+	}
+	if err == nil {
+		b, err = json.Marshal(meta)
+	}
+	if err == nil {
+		err = store.WriteFileAtomic(filepath.Join(s.cacheDir, dalleCacheMeta), b)
+	}
+	if err != nil {
+		fmt.Println("dalle: failed to cache the picture:", err)
+	}
+}
@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	deviantArtTokenURL   = "https://www.deviantart.com/oauth2/token"
+	deviantArtPopularURL = "https://www.deviantart.com/api/v1/oauth2/browse/popular"
+	deviantArtPageSize   = 24
+	deviantArtMaxBytes   = 4 << 20
+)
+
+// deviantArtSource picks a random popular deviation of one category through
+// the DeviantArt API, authorized as the configured application.
+type deviantArtSource struct {
+	client   *fetch.Client
+	conf     clientcredentials.Config
+	category string
+}
+
+func newDeviantArtSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.DeviantArtClientID == "" || cfg.DeviantArtClientSecret == "" {
+		return nil, errors.New("deviantart source needs deviantart_client_id and deviantart_client_secret")
+	}
+	return &deviantArtSource{
+		client: deps.Client,
+		conf: clientcredentials.Config{
+			ClientID:     cfg.DeviantArtClientID,
+			ClientSecret: cfg.DeviantArtClientSecret,
+			TokenURL:     deviantArtTokenURL,
+			AuthStyle:    oauth2.AuthStyleInParams,
+		},
+		category: strings.Trim(cfg.DeviantArtCategory, "/ "),
+	}, nil
+}
+
+func (s *deviantArtSource) Name() string { return "deviantart" }
+
+func (s *deviantArtSource) Host() string { return hostOf(deviantArtPopularURL) }
+
+type deviantArtBrowse struct {
+	Results []struct {
+		URL      string `json:"url"`
+		Title    string `json:"title"`
+		IsMature bool   `json:"is_mature"`
+		Category string `json:"category"`
+		Author   struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		// Content is missing for literature and other non-image deviations.
+		Content *struct {
+			Src    string `json:"src"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"content"`
+	} `json:"results"`
+}
+
+func (s *deviantArtSource) Fetch(ctx context.Context) (*Candidate, error) {
+	// The token request goes through the injected client too; a token
+	// lasts an hour, so one is requested per change.
+	api := &fetch.Client{
+		HTTP:      s.conf.Client(context.WithValue(ctx, oauth2.HTTPClient, s.client.HTTP)),
+		UserAgent: s.client.UserAgent,
+	}
+	q := url.Values{
+		"category_path":  {s.category},
+		"limit":          {fmt.Sprint(deviantArtPageSize)},
+		"mature_content": {"false"},
+	}
+	var res deviantArtBrowse
+	if err := api.GetJSON(ctx, deviantArtPopularURL+"?"+q.Encode(), deviantArtMaxBytes, &res); err != nil {
+		return nil, err
+	}
+	var picks []int
+	for i, r := range res.Results {
+		// Landscape images only; portrait art is cropped beyond recognition.
+		if r.Content != nil && r.Content.Src != "" && !r.IsMature && r.Content.Width >= r.Content.Height {
+			picks = append(picks, i)
+		}
+	}
+	if len(picks) == 0 {
+		return nil, fmt.Errorf("no landscape deviations found in %s", s.category)
+	}
+	d := res.Results[picks[random.Intn(len(picks))]]
+	// src is a signed CDN URL that needs no token.
+	path, err := s.client.DownloadToTemp(ctx, d.Content.Src)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: d.URL,
+		Title:     d.Title,
+		Author:    d.Author.Username,
+		Category:  d.Category,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	eolPhotosAPIURL = "https://eol.jsc.nasa.gov/SearchPhotos/PhotosDatabaseAPI/index.pl"
+	eolPhotoPageURL = "https://eol.jsc.nasa.gov/SearchPhotos/photo.pl?mission=%s&roll=%s&frame=%s"
+	// eolHorizonCriteria asks for high-oblique frames, the ones looking at
+	// the horizon rather than down.
+	eolHorizonCriteria = "frames|tilt|eq|HO"
+	eolMaxBytes        = 8 << 20
+)
+
+// earthgazingBounds maps config.EarthgazingRegions to the nadir latitude and
+// longitude ranges, in degrees, they stand for.
+var earthgazingBounds = map[string]struct{ minLat, maxLat, minLon, maxLon float64 }{
+	"any":           {-90, 90, -180, 180},
+	"africa":        {-35, 37, -18, 52},
+	"asia":          {-10, 75, 26, 180},
+	"europe":        {35, 72, -25, 45},
+	"north_america": {7, 75, -170, -50},
+	"oceania":       {-50, 0, 110, 180},
+	"south_america": {-56, 13, -82, -34},
+}
+
+// earthgazingSource picks an astronaut photo of the horizon from the ISS,
+// taken over the configured region, from the Earth Observations Laboratory
+// photo database.
+type earthgazingSource struct {
+	client       *fetch.Client
+	region       string
+	daylightOnly bool
+}
+
+func newEarthgazingSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &earthgazingSource{client: deps.Client, region: cfg.EarthgazingRegion, daylightOnly: cfg.EarthgazingDaylightOnly}, nil
+}
+
+func (s *earthgazingSource) Name() string { return "earthgazing" }
+
+func (s *earthgazingSource) Host() string { return hostOf(eolPhotosAPIURL) }
+
+type eolPhoto struct {
+	Mission      string  `json:"mission"`
+	Roll         string  `json:"roll"`
+	Frame        string  `json:"frame"`
+	NadirLat     float64 `json:"nadir_lat"`
+	NadirLon     float64 `json:"nadir_lon"`
+	SunElevation float64 `json:"sun_elevation"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Feature      string  `json:"feature"`
+	LowresPic    string  `json:"lowres_pic"`
+}
+
+func (s *earthgazingSource) Fetch(ctx context.Context) (*Candidate, error) {
+	q := url.Values{"op": {"req"}, "rsp": {"json"}, "criteria": {eolHorizonCriteria}}
+	var photos []eolPhoto
+	if err := s.client.GetJSON(ctx, eolPhotosAPIURL+"?"+q.Encode(), eolMaxBytes, &photos); err != nil {
+		return nil, err
+	}
+	b := earthgazingBounds[s.region]
+	var usable []eolPhoto
+	for _, p := range photos {
+		switch {
+		case p.LowresPic == "" || p.Width <= p.Height:
+		case s.daylightOnly && p.SunElevation <= 0: // night side
+		case p.NadirLat < b.minLat || p.NadirLat > b.maxLat || p.NadirLon < b.minLon || p.NadirLon > b.maxLon:
+		default:
+			usable = append(usable, p)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, fmt.Errorf("none of %d horizon photos is a landscape frame over %s", len(photos), s.region)
+	}
+	p := usable[random.Intn(len(usable))]
+	path, err := s.client.DownloadToTemp(ctx, p.LowresPic)
+	if err != nil {
+		return nil, err
+	}
+	title := fmt.Sprintf("%s-%s-%s", p.Mission, p.Roll, p.Frame)
+	if p.Feature != "" {
+		title = strings.TrimSpace(p.Feature) + " (" + title + ")"
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: fmt.Sprintf(eolPhotoPageURL, url.QueryEscape(p.Mission), url.QueryEscape(p.Roll), url.QueryEscape(p.Frame)),
+		Title:     title,
+		Author:    "ISS crew, NASA Earth Observations Laboratory",
+		Category:  "horizon",
+		Tags:      []string{p.Mission, s.region},
+	}, nil
+}
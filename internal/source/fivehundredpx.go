@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	fiveHundredPxPhotosURL = "https://api.500px.com/v1/photos"
+	fiveHundredPxSite      = "https://500px.com"
+	fiveHundredPxImageSize = 2048 // long edge, the largest the API serves
+	fiveHundredPxPageSize  = 20
+	fiveHundredPxMaxBytes  = 4 << 20
+)
+
+// fiveHundredPxFeatures maps config values to the API's feature names.
+var fiveHundredPxFeatures = map[string]string{
+	"popular": "popular",
+	"fresh":   "fresh_today",
+	"editors": "editors",
+}
+
+// fiveHundredPxSource picks a random photo of one of the 500px feeds.
+type fiveHundredPxSource struct {
+	client            *fetch.Client
+	key               string
+	feature, category string
+}
+
+func newFiveHundredPxSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.FiveHundredPxAPIKey == "" {
+		return nil, errors.New("500px source needs fivehundredpx_api_key")
+	}
+	return &fiveHundredPxSource{
+		client:   deps.Client,
+		key:      cfg.FiveHundredPxAPIKey,
+		feature:  fiveHundredPxFeatures[cfg.FiveHundredPxFeature],
+		category: cfg.FiveHundredPxCategory,
+	}, nil
+}
+
+func (s *fiveHundredPxSource) Name() string { return "500px" }
+
+func (s *fiveHundredPxSource) Host() string { return hostOf(fiveHundredPxPhotosURL) }
+
+type fiveHundredPxPhotos struct {
+	Photos []struct {
+		Name   string `json:"name"`
+		URL    string `json:"url"` // path on the site
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		NSFW   bool   `json:"nsfw"`
+		User   struct {
+			Fullname string `json:"fullname"`
+		} `json:"user"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	} `json:"photos"`
+}
+
+func (s *fiveHundredPxSource) Fetch(ctx context.Context) (*Candidate, error) {
+	q := url.Values{
+		"feature":      {s.feature},
+		"image_size":   {fmt.Sprint(fiveHundredPxImageSize)},
+		"rpp":          {fmt.Sprint(fiveHundredPxPageSize)},
+		"consumer_key": {s.key},
+	}
+	if s.category != "" {
+		q.Set("only", s.category)
+	}
+	var res fiveHundredPxPhotos
+	if err := s.client.GetJSON(ctx, fiveHundredPxPhotosURL+"?"+q.Encode(), fiveHundredPxMaxBytes, &res); err != nil {
+		return nil, err
+	}
+	var picks []int
+	for i, p := range res.Photos {
+		if len(p.Images) > 0 && p.Images[0].URL != "" && !p.NSFW && p.Width >= p.Height {
+			picks = append(picks, i)
+		}
+	}
+	if len(picks) == 0 {
+		return nil, fmt.Errorf("no landscape photos in the 500px %s feed", s.feature)
+	}
+	p := res.Photos[picks[random.Intn(len(picks))]]
+	path, err := s.client.DownloadToTemp(ctx, p.Images[0].URL)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: fiveHundredPxSite + p.URL,
+		Title:     p.Name,
+		Author:    p.User.Fullname,
+		Category:  s.category,
+	}, nil
+}
@@ -0,0 +1,269 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/random"
+)
+
+// sftpPort is used instead of ftp_port when SFTP is on and the port was
+// left at the FTP default.
+const sftpPort = 22
+
+// sshDir holds the known_hosts file and the keys the SFTP client offers,
+// the same ones the OpenSSH client uses. Tests point it elsewhere.
+var sshDir = func() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
+
+// sshKeyFiles are the unencrypted private keys in sshDir tried before the
+// password, in the order OpenSSH tries them.
+var sshKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// ftpSource picks a random image from a folder on an FTP server, such as a
+// company's shared wallpaper folder.
+type ftpSource struct {
+	addr, user, password, dir string
+}
+
+// sftpSource is ftpSource over SFTP. It logs in with ftp_password or the
+// user's SSH keys and only trusts hosts already in known_hosts.
+type sftpSource struct {
+	addr, user, password, dir string
+}
+
+func newFTPSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.FTPHost == "" {
+		return nil, errors.New("ftp source needs ftp_host")
+	}
+	dir := cfg.FTPPath
+	if dir == "" {
+		dir = "/"
+	}
+	port := cfg.FTPPort
+	if cfg.FTPUseSFTP {
+		if port == config.Default().FTPPort {
+			port = sftpPort
+		}
+		name := cfg.FTPUser
+		if name == "" {
+			u, err := user.Current()
+			if err != nil {
+				return nil, fmt.Errorf("ftp source with ftp_use_sftp needs ftp_user: %w", err)
+			}
+			// DOMAIN\name on Windows
+			name = u.Username[strings.LastIndex(u.Username, `\`)+1:]
+		}
+		return &sftpSource{addr: net.JoinHostPort(cfg.FTPHost, strconv.Itoa(port)), user: name, password: cfg.FTPPassword, dir: dir}, nil
+	}
+	name, password := cfg.FTPUser, cfg.FTPPassword
+	if name == "" {
+		name, password = "anonymous", "anonymous@"
+	}
+	return &ftpSource{addr: net.JoinHostPort(cfg.FTPHost, strconv.Itoa(port)), user: name, password: password, dir: dir}, nil
+}
+
+func (s *ftpSource) Name() string { return "ftp" }
+
+func (s *sftpSource) Name() string { return "ftp" }
+
+// pickImage returns a random one of names, which may be full paths, that
+// has an image extension, as a path inside dir.
+func pickImage(dir string, names []string) (string, error) {
+	var images []string
+	for _, n := range names {
+		switch strings.ToLower(path.Ext(n)) {
+		case ".jpg", ".jpeg", ".png", ".bmp":
+			if !strings.Contains(n, "/") {
+				n = path.Join(dir, n)
+			}
+			images = append(images, n)
+		}
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no images in %s", dir)
+	}
+	return images[random.Intn(len(images))], nil
+}
+
+// saveTemp copies r to a temp file with the extension of name.
+func saveTemp(r io.Reader, name string) (string, error) {
+	tmp, err := os.CreateTemp("", "wall_*"+path.Ext(name))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// dialer dials with ctx and closes every connection it made once ctx is
+// done, so a stalled control or data connection can't outlive the fetch.
+type dialer struct {
+	ctx   context.Context
+	mu    sync.Mutex
+	stops []func() bool
+}
+
+func (d *dialer) dial(network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(d.ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.stops = append(d.stops, context.AfterFunc(d.ctx, func() { conn.Close() }))
+	d.mu.Unlock()
+	return conn, nil
+}
+
+func (d *dialer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, stop := range d.stops {
+		stop()
+	}
+}
+
+func (s *ftpSource) Fetch(ctx context.Context) (*Candidate, error) {
+	d := &dialer{ctx: ctx}
+	defer d.stop()
+	// The dial func is used for the data connections too; the library
+	// tries EPSV first and falls back to PASV for servers without it.
+	c, err := ftp.Dial(s.addr, ftp.DialWithDialFunc(d.dial))
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+	defer c.Quit()
+	if err := c.Login(s.user, s.password); err != nil {
+		return nil, fmt.Errorf("ftp: login as %s: %w", s.user, err)
+	}
+	names, err := c.NameList(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: listing %s: %w", s.dir, err)
+	}
+	file, err := pickImage(s.dir, names)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.Retr(file)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+	tmp, err := saveTemp(r, file)
+	if cerr := r.Close(); err == nil && cerr != nil {
+		os.Remove(tmp)
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
+	}
+	return &Candidate{Path: tmp, SourceURL: "ftp://" + s.addr + file, Title: path.Base(file)}, nil
+}
+
+func (s *sftpSource) Fetch(ctx context.Context) (*Candidate, error) {
+	cfg, err := s.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	d := &dialer{ctx: ctx}
+	defer d.stop()
+	conn, err := d.dial("tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(conn, s.addr, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	client, err := sftp.NewClient(ssh.NewClient(sc, chans, reqs))
+	if err != nil {
+		sc.Close()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	defer client.Close()
+	defer sc.Close()
+
+	entries, err := client.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: listing %s: %w", s.dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Mode().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	file, err := pickImage(s.dir, names)
+	if err != nil {
+		return nil, err
+	}
+	f, err := client.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	defer f.Close()
+	tmp, err := saveTemp(f, file)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	return &Candidate{Path: tmp, SourceURL: "sftp://" + s.addr + file, Title: path.Base(file)}, nil
+}
+
+// clientConfig authenticates with the keys in sshDir and then the
+// password. Unknown host keys are refused rather than trusted on first
+// use: connect once with ssh to add the server to known_hosts.
+func (s *sftpSource) clientConfig() (*ssh.ClientConfig, error) {
+	dir := sshDir()
+	hostKey, err := knownhosts.New(filepath.Join(dir, "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("sftp: reading known hosts: %w", err)
+	}
+	var signers []ssh.Signer
+	for _, name := range sshKeyFiles {
+		pem, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		// passphrase-protected keys are skipped; ftp_password still works
+		if signer, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if s.password != "" {
+		auth = append(auth, ssh.Password(s.password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("sftp: no ftp_password and no SSH key in %s", dir)
+	}
+	return &ssh.ClientConfig{User: s.user, Auth: auth, HostKeyCallback: hostKey}, nil
+}
@@ -0,0 +1,417 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"wallpaper-changer/internal/config"
+)
+
+func TestPickImage(t *testing.T) {
+	tests := []struct {
+		name  string
+		dir   string
+		names []string
+		want  string // "" for an error
+	}{
+		{"space in name", "/walls", []string{"summer sky.jpg", "notes.txt"}, "/walls/summer sky.jpg"},
+		{"full path", "/walls", []string{"/walls/a b/c d.PNG"}, "/walls/a b/c d.PNG"},
+		{"only images", "/", []string{"readme", "old.bmp.txt", "x.Jpeg"}, "/x.Jpeg"},
+		{"no images", "/walls", []string{"notes.txt", "sub dir"}, ""},
+		{"empty", "/walls", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickImage(tt.dir, tt.names)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("pickImage = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("pickImage = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFTPSourcePort(t *testing.T) {
+	tests := []struct {
+		name string
+		port int
+		sftp bool
+		want string
+	}{
+		{"ftp default", 21, false, "files.example:21"},
+		{"ftp custom", 2121, false, "files.example:2121"},
+		{"sftp default", 21, true, "files.example:22"},
+		{"sftp custom", 2222, true, "files.example:2222"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.FTPHost, cfg.FTPPort, cfg.FTPUseSFTP, cfg.FTPUser = "files.example", tt.port, tt.sftp, "me"
+			src, err := newFTPSource(cfg, Deps{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var addr string
+			switch s := src.(type) {
+			case *ftpSource:
+				addr = s.addr
+			case *sftpSource:
+				addr = s.addr
+			}
+			if addr != tt.want {
+				t.Errorf("addr = %q, want %q", addr, tt.want)
+			}
+		})
+	}
+}
+
+// ftpServer is a minimal passive-mode FTP server serving files, for one
+// user with one password. Without epsv it refuses EPSV like older servers.
+type ftpServer struct {
+	t              *testing.T
+	files          map[string]string
+	user, password string
+	epsv           bool
+}
+
+// start serves until the test ends and returns the address.
+func (s *ftpServer) start() string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	// send writes body over the next data connection.
+	send := func(body string) {
+		if data == nil {
+			reply("425 use PASV first")
+			return
+		}
+		dc, err := data.Accept()
+		data.Close()
+		data = nil
+		if err != nil {
+			reply("425 %v", err)
+			return
+		}
+		reply("150 sending")
+		dc.Write([]byte(body))
+		dc.Close()
+		reply("226 done")
+	}
+	reply("220 ready")
+	loggedIn := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch cmd {
+		case "USER":
+			reply("331 password please")
+			loggedIn = arg == s.user
+		case "PASS":
+			if !loggedIn || arg != s.password {
+				loggedIn = false
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+		case "TYPE":
+			reply("200 ok")
+		case "EPSV", "PASV":
+			if cmd == "EPSV" && !s.epsv {
+				reply("500 unknown command")
+				continue
+			}
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			if cmd == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256)
+			}
+		case "NLST":
+			var names []string
+			for p := range s.files {
+				if path.Dir(p) == arg {
+					names = append(names, path.Base(p))
+				}
+			}
+			send(strings.Join(names, "\r\n") + "\r\n")
+		case "RETR":
+			body, ok := s.files[arg]
+			if !ok {
+				reply("550 no such file")
+				continue
+			}
+			send(body)
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func ftpConfig(addr, user, password string) config.Config {
+	host, port, _ := net.SplitHostPort(addr)
+	cfg := config.Default()
+	cfg.FTPHost, cfg.FTPUser, cfg.FTPPassword, cfg.FTPPath = host, user, password, "/walls"
+	fmt.Sscan(port, &cfg.FTPPort)
+	return cfg
+}
+
+// TestFTPFetch downloads the one image in a folder whose listing has names
+// with spaces, over EPSV and over a server that only speaks PASV.
+func TestFTPFetch(t *testing.T) {
+	files := map[string]string{
+		"/walls/summer sky.jpg": "jpeg bytes",
+		"/walls/read me.txt":    "not an image",
+	}
+	for _, epsv := range []bool{true, false} {
+		t.Run(fmt.Sprintf("epsv=%v", epsv), func(t *testing.T) {
+			srv := &ftpServer{t: t, files: files, user: "staff", password: "secret", epsv: epsv}
+			src, err := newFTPSource(ftpConfig(srv.start(), "staff", "secret"), Deps{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := src.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer os.Remove(c.Path)
+			if c.Title != "summer sky.jpg" {
+				t.Errorf("Title = %q, want the image with a space in its name", c.Title)
+			}
+			if got, err := os.ReadFile(c.Path); err != nil || string(got) != files["/walls/summer sky.jpg"] {
+				t.Errorf("downloaded %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestFTPFetchWrongPassword(t *testing.T) {
+	srv := &ftpServer{t: t, files: map[string]string{"/walls/a.jpg": "x"}, user: "staff", password: "secret", epsv: true}
+	src, err := newFTPSource(ftpConfig(srv.start(), "staff", "guess"), Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := src.Fetch(context.Background()); err == nil {
+		os.Remove(c.Path)
+		t.Fatal("Fetch succeeded with the wrong password")
+	}
+}
+
+func TestFTPFetchCanceled(t *testing.T) {
+	// A listener that accepts and never greets, so only ctx can end Fetch.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	src, err := newFTPSource(ftpConfig(ln.Addr().String(), "staff", "secret"), Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := src.Fetch(ctx); err == nil {
+		t.Error("Fetch succeeded after its context was canceled")
+	}
+}
+
+// sftpServer serves the local filesystem over SFTP on an SSH server that
+// accepts one password, and writes its host key to a known_hosts file in a
+// temp sshDir unless unknown is set.
+func sftpServer(t *testing.T, password string, unknown bool) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, fmt.Errorf("wrong password for %s", c.User())
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, cfg)
+		}
+	}()
+
+	dir := t.TempDir()
+	saved := sshDir
+	sshDir = func() string { return dir }
+	t.Cleanup(func() { sshDir = saved })
+	var hosts string
+	if !unknown {
+		hosts = knownhosts.Line([]string{knownhosts.Normalize(ln.Addr().String())}, signer.PublicKey()) + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "known_hosts"), []byte(hosts), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ln.Addr().String()
+}
+
+func serveSFTP(conn net.Conn, cfg *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}()
+		srv, err := sftp.NewServer(ch)
+		if err != nil {
+			return
+		}
+		srv.Serve()
+		srv.Close()
+	}
+}
+
+func sftpConfig(t *testing.T, addr, password string) (config.Config, string) {
+	cfg := ftpConfig(addr, "staff", password)
+	cfg.FTPUseSFTP = true
+	cfg.FTPPath = filepath.ToSlash(t.TempDir())
+	want := "jpeg bytes"
+	for name, body := range map[string]string{"summer sky.jpg": want, "read me.txt": "not an image"} {
+		if err := os.WriteFile(filepath.Join(cfg.FTPPath, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(cfg.FTPPath, "old.jpg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, want
+}
+
+func TestSFTPFetch(t *testing.T) {
+	cfg, want := sftpConfig(t, sftpServer(t, "secret", false), "secret")
+	src, err := newFTPSource(cfg, Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	defer os.Remove(c.Path)
+	if c.Title != "summer sky.jpg" {
+		t.Errorf("Title = %q, want the image and not the folder", c.Title)
+	}
+	if got, err := os.ReadFile(c.Path); err != nil || string(got) != want {
+		t.Errorf("downloaded %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestSFTPFetchRefused(t *testing.T) {
+	tests := []struct {
+		name, password string
+		unknown        bool
+	}{
+		{"wrong password", "guess", false},
+		{"unknown host", "secret", true},
+		{"no credentials", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := sftpConfig(t, sftpServer(t, "secret", tt.unknown), tt.password)
+			src, err := newFTPSource(cfg, Deps{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c, err := src.Fetch(context.Background()); err == nil {
+				os.Remove(c.Path)
+				t.Fatal("Fetch succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	githubSearchURL   = "https://api.github.com/search/repositories"
+	githubMaxBytes    = 2 << 20
+	githubRepoCount   = 20
+	githubChartMargin = 80
+)
+
+// githubLanguageColors follows GitHub's linguist colors for common languages.
+var githubLanguageColors = map[string]color.RGBA{
+	"Go":         {0x00, 0xad, 0xd8, 0xff},
+	"Python":     {0x35, 0x72, 0xa5, 0xff},
+	"JavaScript": {0xf1, 0xe0, 0x5a, 0xff},
+	"TypeScript": {0x31, 0x78, 0xc6, 0xff},
+	"Rust":       {0xde, 0xa5, 0x84, 0xff},
+	"C++":        {0xf3, 0x4b, 0x7d, 0xff},
+	"Java":       {0xb0, 0x72, 0x19, 0xff},
+	"C":          {0x55, 0x55, 0x55, 0xff},
+}
+
+// githubTrendingSource charts the most-starred repositories created since yesterday.
+type githubTrendingSource struct {
+	client   *fetch.Client
+	language string
+	now      func() time.Time
+}
+
+func newGitHubTrendingSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &githubTrendingSource{client: deps.Client, language: cfg.GitHubTrendingLanguage, now: deps.Now}, nil
+}
+
+func (s *githubTrendingSource) Name() string { return "github_trending" }
+
+func (s *githubTrendingSource) Host() string { return hostOf(githubSearchURL) }
+
+type githubRepo struct {
+	FullName string `json:"full_name"`
+	Stars    int    `json:"stargazers_count"`
+	Language string `json:"language"`
+}
+
+func (s *githubTrendingSource) Fetch(ctx context.Context) (*Candidate, error) {
+	since := s.now().AddDate(0, 0, -1).Format("2006-01-02")
+	q := "created:>" + since
+	if s.language != "" {
+		q += " language:" + s.language
+	}
+	u := githubSearchURL + "?" + url.Values{
+		"q": {q}, "sort": {"stars"}, "order": {"desc"}, "per_page": {strconv.Itoa(githubRepoCount)},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github bad status: %s", resp.Status)
+	}
+	var body struct {
+		Items []githubRepo `json:"items"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, githubMaxBytes)).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Items) == 0 {
+		return nil, fmt.Errorf("github returned no repositories for %q", q)
+	}
+
+	img, err := renderRepoChart(body.Items, "GitHub trending since "+since)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	c := &Candidate{Path: path, SourceURL: "https://github.com/" + body.Items[0].FullName, Title: "GitHub trending"}
+	for _, r := range body.Items {
+		c.Tags = append(c.Tags, r.FullName)
+	}
+	return c, nil
+}
+
+// renderRepoChart draws a horizontal bar chart of repos by star count.
+func renderRepoChart(repos []githubRepo, title string) (*image.RGBA, error) {
+	titleFace, err := imaging.NewFace(48, true)
+	if err != nil {
+		return nil, err
+	}
+	rowFace, err := imaging.NewFace(24, false)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, color.RGBA{0x0d, 0x11, 0x17, 0xff}, color.RGBA{0x16, 0x1b, 0x22, 0xff})
+
+	fg := color.RGBA{0xe6, 0xed, 0xf3, 0xff}
+	dim := color.RGBA{0x8b, 0x94, 0x9e, 0xff}
+	imaging.DrawText(img, titleFace, githubChartMargin, githubChartMargin+40, title, fg)
+
+	nameCol := githubChartMargin
+	barCol := githubChartMargin + 560
+	barMax := renderWidth - barCol - githubChartMargin - 120
+	top := githubChartMargin + 110
+	rowH := (renderHeight - top - githubChartMargin) / len(repos)
+	maxStars := max(1, repos[0].Stars)
+
+	for i, r := range repos {
+		y := top + i*rowH
+		name := imaging.TruncateText(rowFace, r.FullName, barCol-nameCol-20)
+		imaging.DrawText(img, rowFace, nameCol, y+rowH/2+8, name, fg)
+
+		w := max(4, barMax*r.Stars/maxStars)
+		bar := image.Rect(barCol, y+rowH/4, barCol+w, y+rowH*3/4)
+		imaging.FillRect(img, bar, languageColor(r.Language))
+
+		label := strconv.Itoa(r.Stars) + " stars"
+		if r.Language != "" {
+			label += "  " + r.Language
+		}
+		imaging.DrawText(img, rowFace, barCol+w+12, y+rowH/2+8, label, dim)
+	}
+	return img, nil
+}
+
+// languageColor returns the linguist color, or a stable hashed color.
+func languageColor(lang string) color.RGBA {
+	if c, ok := githubLanguageColors[lang]; ok {
+		return c
+	}
+	h := fnv.New32a()
+	h.Write([]byte(lang))
+	v := h.Sum32()
+	return color.RGBA{R: 0x60 + uint8(v)%0x80, G: 0x60 + uint8(v>>8)%0x80, B: 0x60 + uint8(v>>16)%0x80, A: 0xff}
+}
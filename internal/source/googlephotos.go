@@ -0,0 +1,267 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	googlePhotosSearchURL  = "https://photoslibrary.googleapis.com/v1/mediaItems:search"
+	googlePhotosScope      = "https://www.googleapis.com/auth/photoslibrary.readonly"
+	googlePhotosTokenFile  = "google_photos_token.json"
+	googlePhotosPageSize   = 100
+	googlePhotosMaxPages   = 3
+	googlePhotosMaxBytes   = 4 << 20
+	googleAuthTimeout      = 5 * time.Minute
+	googleAuthCallbackPath = "/oauth2callback"
+)
+
+// googlePhotosSource picks a random landscape photo from the user's library.
+type googlePhotosSource struct {
+	client          *fetch.Client
+	credentialsFile string
+	tokenPath       string
+}
+
+func newGooglePhotosSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.GooglePhotosCredentialsFile == "" {
+		return nil, errors.New("google_photos source needs google_photos_credentials_file")
+	}
+	if deps.AppDir == "" {
+		return nil, errors.New("google_photos source needs the app dir to cache its token")
+	}
+	return &googlePhotosSource{
+		client:          deps.Client,
+		credentialsFile: cfg.GooglePhotosCredentialsFile,
+		tokenPath:       filepath.Join(deps.AppDir, googlePhotosTokenFile),
+	}, nil
+}
+
+func (s *googlePhotosSource) Name() string { return "google_photos" }
+
+func (s *googlePhotosSource) Host() string { return hostOf(googlePhotosSearchURL) }
+
+type googleMediaItem struct {
+	ID          string `json:"id"`
+	BaseURL     string `json:"baseUrl"`
+	ProductURL  string `json:"productUrl"`
+	Description string `json:"description"`
+}
+
+func (s *googlePhotosSource) Fetch(ctx context.Context) (*Candidate, error) {
+	client, err := s.authClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []googleMediaItem
+	pageToken := ""
+	for page := 0; page < googlePhotosMaxPages; page++ {
+		batch, next, err := searchLandscapes(ctx, client, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no landscape photos found in Google Photos")
+	}
+
+	item := items[random.Intn(len(items))]
+	// baseUrl is a short-lived, unauthenticated URL; "=d" asks for the full original.
+	path, err := s.client.DownloadToTemp(ctx, item.BaseURL+"=d")
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, SourceURL: item.ProductURL, Title: item.Description}, nil
+}
+
+func searchLandscapes(ctx context.Context, client *http.Client, pageToken string) ([]googleMediaItem, string, error) {
+	body := map[string]any{
+		"pageSize": googlePhotosPageSize,
+		"filters": map[string]any{
+			"mediaTypeFilter": map[string]any{"mediaTypes": []string{"PHOTO"}},
+			"contentFilter":   map[string]any{"includedContentCategories": []string{"LANDSCAPES"}},
+		},
+	}
+	if pageToken != "" {
+		body["pageToken"] = pageToken
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googlePhotosSearchURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("google photos bad status: %s", resp.Status)
+	}
+	var out struct {
+		MediaItems    []googleMediaItem `json:"mediaItems"`
+		NextPageToken string            `json:"nextPageToken"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, googlePhotosMaxBytes)).Decode(&out); err != nil {
+		return nil, "", err
+	}
+	return out.MediaItems, out.NextPageToken, nil
+}
+
+func (s *googlePhotosSource) authClient(ctx context.Context) (*http.Client, error) {
+	return googleAuthClient(ctx, s.client, s.credentialsFile, s.tokenPath, googlePhotosScope)
+}
+
+// googleAuthClient returns an HTTP client authorized for scope, running the
+// browser consent flow the first time and caching the token at tokenPath
+// afterwards.
+func googleAuthClient(ctx context.Context, client *fetch.Client, credentialsFile, tokenPath, scope string) (*http.Client, error) {
+	b, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read google credentials: %w", err)
+	}
+	conf, err := google.ConfigFromJSON(b, scope)
+	if err != nil {
+		return nil, err
+	}
+	// Token exchange and refresh go through the injected client too.
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client.HTTP)
+
+	tok, err := loadToken(tokenPath)
+	if err != nil {
+		if tok, err = authorizeInBrowser(ctx, conf); err != nil {
+			return nil, err
+		}
+	}
+	ts := &savingTokenSource{base: conf.TokenSource(ctx, tok), path: tokenPath, last: tok}
+	if _, err := ts.Token(); err != nil { // refresh now so failures surface here
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+func loadToken(path string) (*oauth2.Token, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tok oauth2.Token
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+func saveToken(path string, tok *oauth2.Token) error {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, b)
+}
+
+// savingTokenSource persists the token whenever it is refreshed.
+type savingTokenSource struct {
+	base oauth2.TokenSource
+	path string
+	last *oauth2.Token
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+	if s.last == nil || tok.AccessToken != s.last.AccessToken {
+		if err := saveToken(s.path, tok); err != nil {
+			fmt.Println("failed to cache google token:", err)
+		}
+		s.last = tok
+	}
+	return tok, nil
+}
+
+// authorizeInBrowser opens the consent page and receives the code on a
+// loopback redirect, as recommended for installed apps.
+func authorizeInBrowser(ctx context.Context, conf *oauth2.Config) (*oauth2.Token, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	conf.RedirectURL = "http://" + ln.Addr().String() + googleAuthCallbackPath
+
+	state := fmt.Sprintf("%x", rand.Int63())
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(googleAuthCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		if e := r.URL.Query().Get("error"); e != "" {
+			errCh <- fmt.Errorf("google authorization denied: %s", e)
+			fmt.Fprintln(w, "Authorization failed, you can close this window.")
+			return
+		}
+		codeCh <- r.URL.Query().Get("code")
+		fmt.Fprintln(w, "GoWallpaperTray is authorized, you can close this window.")
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	authURL := conf.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	if err := openBrowser(authURL); err != nil {
+		return nil, err
+	}
+
+	select {
+	case code := <-codeCh:
+		return conf.Exchange(ctx, code)
+	case err := <-errCh:
+		return nil, err
+	case <-time.After(googleAuthTimeout):
+		return nil, errors.New("timed out waiting for google authorization")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// openBrowser shows url in the default browser.
+func openBrowser(url string) error {
+	if err := exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start(); err != nil {
+		return fmt.Errorf("open browser: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"sync"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	hnTopStoriesURL = "https://hacker-news.firebaseio.com/v0/topstories.json"
+	hnItemURL       = "https://hacker-news.firebaseio.com/v0/item/%d.json"
+	hnItemPageURL   = "https://news.ycombinator.com/item?id=%d"
+	hnMaxBytes      = 1 << 20
+	// With hn_min_score some stories drop out, so hnLookahead times as
+	// many are fetched, hnParallel at a time.
+	hnLookahead = 3
+	hnParallel  = 8
+	hnMargin    = 90
+)
+
+var (
+	hnOrange = color.RGBA{0xff, 0x66, 0x00, 0xff}
+	hnText   = color.RGBA{0xe8, 0xe6, 0xe3, 0xff}
+	hnDim    = color.RGBA{0x8a, 0x87, 0x82, 0xff}
+)
+
+// hackerNewsSource lists the Hacker News front page as a dark ticker.
+type hackerNewsSource struct {
+	client   *fetch.Client
+	count    int
+	minScore int
+}
+
+func newHackerNewsSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &hackerNewsSource{client: deps.Client, count: cfg.HNNumStories, minScore: cfg.HNMinScore}, nil
+}
+
+func (s *hackerNewsSource) Name() string { return "hacker_news" }
+
+func (s *hackerNewsSource) Host() string { return hostOf(hnTopStoriesURL) }
+
+type hnItem struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	By          string `json:"by"`
+	Score       int    `json:"score"`
+	Descendants int    `json:"descendants"`
+	Dead        bool   `json:"dead"`
+	Deleted     bool   `json:"deleted"`
+}
+
+func (s *hackerNewsSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var ids []int
+	if err := s.client.GetJSON(ctx, hnTopStoriesURL, hnMaxBytes, &ids); err != nil {
+		return nil, err
+	}
+	ids = ids[:min(len(ids), s.count*hnLookahead)]
+	items := s.items(ctx, ids)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var stories []hnItem
+	for _, it := range items {
+		if it != nil && it.Type == "story" && !it.Dead && !it.Deleted && it.Score >= s.minScore {
+			stories = append(stories, *it)
+			if len(stories) == s.count {
+				break
+			}
+		}
+	}
+	if len(stories) == 0 {
+		return nil, fmt.Errorf("no front page stories with at least %d points", s.minScore)
+	}
+	img, err := renderHNTicker(stories)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	c := &Candidate{Path: path, SourceURL: fmt.Sprintf(hnItemPageURL, stories[0].ID), Title: "Hacker News front page", Category: "news"}
+	for _, st := range stories {
+		c.Tags = append(c.Tags, st.Title)
+	}
+	return c, nil
+}
+
+// items fetches ids, hnParallel at a time, in their order; an item that
+// failed is nil.
+func (s *hackerNewsSource) items(ctx context.Context, ids []int) []*hnItem {
+	items := make([]*hnItem, len(ids))
+	sem := make(chan struct{}, hnParallel)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			var it hnItem
+			if err := s.client.GetJSON(ctx, fmt.Sprintf(hnItemURL, id), hnMaxBytes, &it); err != nil {
+				fmt.Printf("hacker_news: item %d: %v\n", id, err)
+				return
+			}
+			items[i] = &it
+		}()
+	}
+	wg.Wait()
+	return items
+}
+
+// renderHNTicker draws stories as ranked rows: rank, title, then points,
+// comments and submitter.
+func renderHNTicker(stories []hnItem) (*image.RGBA, error) {
+	headFace, err := imaging.NewFace(44, true)
+	if err != nil {
+		return nil, err
+	}
+	defer headFace.Close()
+	top := hnMargin + 100
+	rowH := min(120, (renderHeight-top-hnMargin)/len(stories))
+	titleFace, err := imaging.NewFace(float64(rowH)*0.36, true)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	metaFace, err := imaging.NewFace(float64(rowH)*0.22, false)
+	if err != nil {
+		return nil, err
+	}
+	defer metaFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, color.RGBA{0x12, 0x12, 0x14, 0xff}, color.RGBA{0x1c, 0x1b, 0x1a, 0xff})
+	imaging.FillRect(img, image.Rect(0, 0, renderWidth, 8), hnOrange)
+	imaging.DrawText(img, headFace, hnMargin, hnMargin+40, "Hacker News", hnOrange)
+
+	textCol := hnMargin + imaging.TextWidth(titleFace, "30.") + 24
+	maxW := renderWidth - textCol - hnMargin
+	for i, st := range stories {
+		y := top + i*rowH + titleFace.Metrics().Ascent.Ceil()
+		rank := strconv.Itoa(i+1) + "."
+		imaging.DrawText(img, titleFace, textCol-24-imaging.TextWidth(titleFace, rank), y, rank, hnDim)
+		imaging.DrawText(img, titleFace, textCol, y, imaging.TruncateText(titleFace, st.Title, maxW), hnText)
+		meta := fmt.Sprintf("%d points · %d comments · by %s", st.Score, st.Descendants, st.By)
+		imaging.DrawText(img, metaFace, textCol, y+metaFace.Metrics().Height.Ceil()+4, meta, hnDim)
+	}
+	return img, nil
+}
@@ -0,0 +1,242 @@
+package source
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	// isoCityWidthFraction is the share of the image width the grid spans.
+	isoCityWidthFraction = 0.8
+	isoCityBottomMargin  = 60
+	// isoCityInset is the gap between a building and its tile edge, in tiles.
+	isoCityInset = 0.12
+	// Building heights are multiples of the tile height; one in
+	// isoCityTowerChance is a tower up to isoCityMaxTower.
+	isoCityMaxFloors   = 2.0
+	isoCityMaxTower    = 4.5
+	isoCityTowerChance = 8
+	// isoCityWindowRows is window rows per tile height of wall.
+	isoCityWindowRows = 4
+	isoCityWindowCols = 3
+)
+
+// isoScheme is the lighting of one "iso_city_color_scheme". Faces are
+// gradients from their top color to their bottom one.
+type isoScheme struct {
+	skyTop, skyBottom     color.RGBA
+	ground                color.RGBA
+	roofTop, roofBottom   color.RGBA
+	leftTop, leftBottom   color.RGBA
+	rightTop, rightBottom color.RGBA
+	windowLit, windowDark color.RGBA
+	// litChance is the probability that a window is lit.
+	litChance float64
+}
+
+var isoSchemes = map[string]isoScheme{
+	"day": {
+		skyTop: rgb(110, 170, 230), skyBottom: rgb(200, 225, 245),
+		ground:  rgb(120, 160, 100),
+		roofTop: rgb(205, 205, 200), roofBottom: rgb(180, 180, 175),
+		leftTop: rgb(170, 175, 185), leftBottom: rgb(130, 135, 145),
+		rightTop: rgb(215, 215, 220), rightBottom: rgb(175, 175, 185),
+		windowLit: rgb(160, 200, 235), windowDark: rgb(90, 115, 145),
+		litChance: 0.6,
+	},
+	"sunset": {
+		skyTop: rgb(60, 50, 110), skyBottom: rgb(250, 150, 90),
+		ground:  rgb(90, 70, 80),
+		roofTop: rgb(230, 150, 110), roofBottom: rgb(200, 120, 95),
+		leftTop: rgb(110, 80, 110), leftBottom: rgb(70, 50, 80),
+		rightTop: rgb(220, 130, 100), rightBottom: rgb(160, 90, 90),
+		windowLit: rgb(255, 210, 120), windowDark: rgb(60, 45, 70),
+		litChance: 0.35,
+	},
+	"night": {
+		skyTop: rgb(5, 8, 25), skyBottom: rgb(25, 30, 70),
+		ground:  rgb(20, 22, 35),
+		roofTop: rgb(55, 60, 85), roofBottom: rgb(40, 45, 70),
+		leftTop: rgb(30, 32, 50), leftBottom: rgb(18, 20, 35),
+		rightTop: rgb(45, 48, 70), rightBottom: rgb(28, 30, 50),
+		windowLit: rgb(255, 220, 130), windowDark: rgb(15, 17, 28),
+		litChance: 0.4,
+	},
+}
+
+func rgb(r, g, b uint8) color.RGBA { return color.RGBA{R: r, G: g, B: b, A: 0xff} }
+
+// isoCitySource draws an isometric city of random box buildings offline.
+type isoCitySource struct {
+	now     func() time.Time
+	daily   bool
+	grid    int
+	density float64
+	scheme  isoScheme
+}
+
+func newIsoCitySource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &isoCitySource{
+		now:     deps.Now,
+		daily:   cfg.GeneratorMode == "daily",
+		grid:    cfg.IsoCityGridSize,
+		density: cfg.IsoCityDensity,
+		scheme:  isoSchemes[cfg.IsoCityColorScheme],
+	}, nil
+}
+
+func (s *isoCitySource) Name() string { return "iso_city" }
+
+// Deterministic reports whether the same day always draws the same picture.
+func (s *isoCitySource) Deterministic() bool { return s.daily }
+
+func (s *isoCitySource) Fetch(ctx context.Context) (*Candidate, error) {
+	path, err := imaging.WriteTempBMP(s.render(generatorRand(s.daily, s.now())))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "Isometric city"}, nil
+}
+
+// isoView projects grid coordinates (x, y along the grid axes, in tiles)
+// and height z (in tile heights) onto the image.
+type isoView struct {
+	originX, originY float64
+	tileW, tileH     float64
+}
+
+func (v isoView) at(x, y, z float64) (float32, float32) {
+	return float32(v.originX + (x-y)*v.tileW/2), float32(v.originY + (x+y-2*z)*v.tileH/2)
+}
+
+func (s *isoCitySource) render(rng *rand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, s.scheme.skyTop, s.scheme.skyBottom)
+
+	n := float64(s.grid)
+	tileW := renderWidth * isoCityWidthFraction / n
+	v := isoView{originX: renderWidth / 2, tileW: tileW, tileH: tileW / 2}
+	v.originY = renderHeight - isoCityBottomMargin - n*v.tileH
+
+	p := &painter{dst: img, r: vector.NewRasterizer(renderWidth, renderHeight)}
+	p.quad(v, [4][3]float64{{0, 0, 0}, {n, 0, 0}, {n, n, 0}, {0, n, 0}}, image.NewUniform(s.scheme.ground))
+
+	// Back to front: tiles nearer the viewer have a larger x+y.
+	for d := 0; d <= 2*(s.grid-1); d++ {
+		for x := max(0, d-s.grid+1); x <= min(d, s.grid-1); x++ {
+			if rng.Float64() >= s.density {
+				continue
+			}
+			h := 0.5 + rng.Float64()*(isoCityMaxFloors-0.5)
+			if rng.Intn(isoCityTowerChance) == 0 {
+				h = isoCityMaxFloors + rng.Float64()*(isoCityMaxTower-isoCityMaxFloors)
+			}
+			s.building(p, v, rng, float64(x), float64(d-x), h)
+		}
+	}
+	return img
+}
+
+// building draws a box on tile (gx, gy) h tile heights tall: the two walls
+// facing the viewer with their windows, then the roof.
+func (s *isoCitySource) building(p *painter, v isoView, rng *rand.Rand, gx, gy, h float64) {
+	x0, y0 := gx+isoCityInset, gy+isoCityInset
+	x1, y1 := gx+1-isoCityInset, gy+1-isoCityInset
+	// Image rows spanned by the walls and the roof, for their gradients.
+	_, wallTop := v.at(x1, y1, h)
+	_, wallBottom := v.at(x1, y1, 0)
+	_, roofBack := v.at(x0, y0, h)
+	sch := s.scheme
+
+	left := [4][3]float64{{x0, y1, 0}, {x1, y1, 0}, {x1, y1, h}, {x0, y1, h}}
+	p.quad(v, left, verticalGradient(sch.leftTop, sch.leftBottom, wallTop, wallBottom))
+	right := [4][3]float64{{x1, y1, 0}, {x1, y0, 0}, {x1, y0, h}, {x1, y1, h}}
+	p.quad(v, right, verticalGradient(sch.rightTop, sch.rightBottom, wallTop, wallBottom))
+
+	rows := max(1, int(h*isoCityWindowRows))
+	for row := range rows {
+		z0 := h * (float64(row) + 0.3) / float64(rows)
+		z1 := h * (float64(row) + 0.75) / float64(rows)
+		for col := range isoCityWindowCols {
+			a := (float64(col) + 0.25) / isoCityWindowCols
+			b := (float64(col) + 0.75) / isoCityWindowCols
+			p.quad(v, [4][3]float64{
+				{x0 + a*(x1-x0), y1, z0}, {x0 + b*(x1-x0), y1, z0},
+				{x0 + b*(x1-x0), y1, z1}, {x0 + a*(x1-x0), y1, z1},
+			}, s.window(rng))
+			p.quad(v, [4][3]float64{
+				{x1, y1 - a*(y1-y0), z0}, {x1, y1 - b*(y1-y0), z0},
+				{x1, y1 - b*(y1-y0), z1}, {x1, y1 - a*(y1-y0), z1},
+			}, s.window(rng))
+		}
+	}
+
+	roof := [4][3]float64{{x0, y0, h}, {x1, y0, h}, {x1, y1, h}, {x0, y1, h}}
+	p.quad(v, roof, verticalGradient(sch.roofTop, sch.roofBottom, roofBack, wallTop))
+}
+
+// window is a lit or dark pane; lit ones vary a little in warmth.
+func (s *isoCitySource) window(rng *rand.Rand) image.Image {
+	if rng.Float64() >= s.scheme.litChance {
+		return image.NewUniform(s.scheme.windowDark)
+	}
+	c := imaging.LerpColor(s.scheme.windowLit, s.scheme.windowDark, rng.Float64()*0.25)
+	return image.NewUniform(c)
+}
+
+// painter fills projected quads with antialiased edges.
+type painter struct {
+	dst *image.RGBA
+	r   *vector.Rasterizer
+}
+
+// quad fills the parallelogram with corners pts (grid x, y, z) with src.
+func (p *painter) quad(v isoView, pts [4][3]float64, src image.Image) {
+	b := p.dst.Bounds()
+	p.r.Reset(b.Dx(), b.Dy())
+	p.r.DrawOp = draw.Over
+	for i, pt := range pts {
+		x, y := v.at(pt[0], pt[1], pt[2])
+		if i == 0 {
+			p.r.MoveTo(x, y)
+		} else {
+			p.r.LineTo(x, y)
+		}
+	}
+	p.r.ClosePath()
+	p.r.Draw(p.dst, b, src, image.Point{})
+}
+
+// gradientImage is an unbounded image blending from top at row y0 to
+// bottom at row y1, used as the paint for faces.
+type gradientImage struct {
+	top, bottom color.RGBA
+	y0, y1      float64
+}
+
+func verticalGradient(top, bottom color.RGBA, y0, y1 float32) image.Image {
+	return &gradientImage{top: top, bottom: bottom, y0: float64(y0), y1: float64(y1)}
+}
+
+func (g *gradientImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (g *gradientImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (g *gradientImage) At(x, y int) color.Color {
+	if g.y1 <= g.y0 {
+		return g.top
+	}
+	t := (float64(y) - g.y0) / (g.y1 - g.y0)
+	return imaging.LerpColor(g.top, g.bottom, min(max(t, 0), 1))
+}
@@ -0,0 +1,86 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	// issHDEVSnapshotURL is the still the ESRS HDEV page refreshes from.
+	issHDEVSnapshotURL = "https://eol.jsc.nasa.gov/ESRS/HDEV/images/HDEV_current.jpg"
+	issPageURL         = "https://eol.jsc.nasa.gov/ESRS/HDEV/"
+	issAttempts        = 3
+	issRetryDelay      = 30 * time.Second
+	// issDarkLuma is the 0-255 luma below which a pixel counts as dark.
+	issDarkLuma = 40
+)
+
+// issLiveSource uses the current frame of the ISS HDEV Earth-viewing camera,
+// skipping frames taken over the night side.
+type issLiveSource struct {
+	client       *fetch.Client
+	maxDarkShare float64
+}
+
+func newISSLiveSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &issLiveSource{client: deps.Client, maxDarkShare: cfg.ISSCameraMaxDarkPixelFraction}, nil
+}
+
+func (s *issLiveSource) Name() string { return "iss_live" }
+
+func (s *issLiveSource) Host() string { return hostOf(issHDEVSnapshotURL) }
+
+func (s *issLiveSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var dark float64
+	for attempt := 0; attempt < issAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(issRetryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		path, err := s.client.DownloadToTemp(ctx, issHDEVSnapshotURL)
+		if err != nil {
+			return nil, err
+		}
+		img, err := imaging.DecodeFile(path)
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		if dark = darkPixelFraction(img); dark <= s.maxDarkShare {
+			return &Candidate{Path: path, SourceURL: issPageURL}, nil
+		}
+		os.Remove(path)
+		fmt.Printf("iss_live: snapshot is %.0f%% dark (night side or camera off), retrying\n", dark*100)
+	}
+	return nil, fmt.Errorf("ISS camera still dark after %d tries (%.0f%% dark pixels)", issAttempts, dark*100)
+}
+
+// darkPixelFraction returns the share of pixels with luma below issDarkLuma,
+// sampling every 4th pixel in each direction.
+func darkPixelFraction(img image.Image) float64 {
+	const step = 4
+	b := img.Bounds()
+	var dark, n int
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			if imaging.Luma(img.At(x, y)) < issDarkLuma {
+				dark++
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return float64(dark) / float64(n)
+}
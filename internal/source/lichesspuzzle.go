@@ -0,0 +1,423 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+	"unicode"
+
+	"github.com/skip2/go-qrcode"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	lichessPuzzleURL   = "https://lichess.org/api/puzzle/daily"
+	lichessTrainingURL = "https://lichess.org/training/"
+	chessStartFEN      = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR"
+	// chessMaxThemes is how many of the puzzle's themes the caption lists.
+	chessMaxThemes = 3
+)
+
+var (
+	rookDirs   = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	bishopDirs = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+// lichessPuzzleSource draws Lichess' daily puzzle from the solver's side,
+// with whose move it is and optionally a QR code of the puzzle page.
+type lichessPuzzleSource struct {
+	client *fetch.Client
+	style  chessStyle
+	qrCode bool
+}
+
+func newLichessPuzzleSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &lichessPuzzleSource{client: deps.Client, style: chessStyles[cfg.ChessPieceStyle], qrCode: cfg.LichessPuzzleQRCode}, nil
+}
+
+func (s *lichessPuzzleSource) Name() string { return "lichess_puzzle" }
+
+func (s *lichessPuzzleSource) Host() string { return hostOf(lichessPuzzleURL) }
+
+type lichessPuzzle struct {
+	Game struct {
+		ID string `json:"id"`
+		// PGN is the game's moves in SAN up to the puzzle, without move
+		// numbers.
+		PGN string `json:"pgn"`
+	} `json:"game"`
+	Puzzle struct {
+		ID       string   `json:"id"`
+		Rating   int      `json:"rating"`
+		Solution []string `json:"solution"` // UCI moves, the solver's first
+		Themes   []string `json:"themes"`
+	} `json:"puzzle"`
+}
+
+func (s *lichessPuzzleSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var p lichessPuzzle
+	if err := s.client.GetJSON(ctx, lichessPuzzleURL, chessMaxBytes, &p); err != nil {
+		return nil, err
+	}
+	if p.Puzzle.ID == "" || len(p.Puzzle.Solution) == 0 {
+		return nil, errors.New("Lichess returned no daily puzzle")
+	}
+	pos, err := replayPGN(p.Game.PGN)
+	if err != nil {
+		return nil, fmt.Errorf("puzzle %s: %w", p.Puzzle.ID, err)
+	}
+	// The solution starts with the solver's move, which tells whether the
+	// moves were replayed right.
+	if from, ok := parseSquare(p.Puzzle.Solution[0]); !ok || !pos.own(pos.board[from[0]][from[1]]) {
+		return nil, fmt.Errorf("puzzle %s: solution %s doesn't fit the position", p.Puzzle.ID, p.Puzzle.Solution[0])
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	if err := drawChessBoard(img, s.style, pos.board, !pos.white); err != nil {
+		return nil, err
+	}
+	if err := s.caption(img, p, pos.white); err != nil {
+		return nil, err
+	}
+	pageURL := lichessTrainingURL + p.Puzzle.ID
+	if s.qrCode {
+		sq := chessSquare
+		right := (renderWidth+8*sq)/2 + sq/6
+		side := min(renderWidth-right-sq, 3*sq)
+		x, y := renderWidth-sq/2-side, (renderHeight+8*sq)/2-side
+		if err := drawQRCode(img, image.Rect(x, y, x+side, y+side), pageURL); err != nil {
+			return nil, err
+		}
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      path,
+		SourceURL: pageURL,
+		Title:     "Lichess puzzle of the day",
+		Category:  "puzzle",
+		Tags:      p.Puzzle.Themes,
+	}, nil
+}
+
+// drawQRCode paints content as a QR code, with its quiet zone, into r of
+// dst as square modules as large as r allows, centered.
+func drawQRCode(dst *image.RGBA, r image.Rectangle, content string) error {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	scale := min(r.Dx(), r.Dy()) / len(code.Bitmap())
+	if scale < 1 {
+		return fmt.Errorf("no room for a QR code of %s", content)
+	}
+	// A negative size is pixels per module, which keeps the modules even.
+	qr := code.Image(-scale)
+	side := qr.Bounds().Dx()
+	x, y := r.Min.X+(r.Dx()-side)/2, r.Min.Y+(r.Dy()-side)/2
+	draw.Draw(dst, image.Rect(x, y, x+side, y+side), qr, image.Point{}, draw.Src)
+	return nil
+}
+
+// caption writes whose move it is, how long the solution is and what the
+// puzzle is about in the left margin.
+func (s *lichessPuzzleSource) caption(img *image.RGBA, p lichessPuzzle, white bool) error {
+	title, err := imaging.NewFace(float64(renderHeight)*chessTitleSize, true)
+	if err != nil {
+		return err
+	}
+	defer title.Close()
+	info, err := imaging.NewFace(float64(renderHeight)*chessInfoSize, false)
+	if err != nil {
+		return err
+	}
+	defer info.Close()
+
+	sq := chessSquare
+	maxText := (renderWidth-8*sq)/2 - sq/3 - sq/2
+	tx, y := sq/2, (renderHeight-8*sq)/2+title.Metrics().Ascent.Ceil()
+	side := "Black"
+	if white {
+		side = "White"
+	}
+	imaging.DrawText(img, title, tx, y, side+" to move", s.style.text)
+	y += title.Metrics().Height.Ceil() + sq/8
+
+	moves := (len(p.Puzzle.Solution) + 1) / 2
+	lines := []string{"Find the best move"}
+	if moves > 1 {
+		lines[0] = fmt.Sprintf("Find the best %d moves", moves)
+	}
+	if p.Puzzle.Rating > 0 {
+		lines = append(lines, fmt.Sprintf("Rated %d", p.Puzzle.Rating))
+	}
+	for _, t := range p.Puzzle.Themes[:min(len(p.Puzzle.Themes), chessMaxThemes)] {
+		lines = append(lines, themeWords(t))
+	}
+	for _, l := range lines {
+		imaging.DrawText(img, info, tx, y, imaging.TruncateText(info, l, maxText), s.style.text)
+		y += info.Metrics().Height.Ceil()
+	}
+	return nil
+}
+
+// themeWords spells a camelCase Lichess theme, such as "mateIn2", as
+// words.
+func themeWords(theme string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range theme {
+		if prev != 0 && (unicode.IsUpper(r) || unicode.IsDigit(r) && !unicode.IsDigit(prev)) {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(unicode.ToLower(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// chessPosition is a board with the side to move and the square a pawn
+// can be taken en passant on, if any.
+type chessPosition struct {
+	board     chessBoard
+	white     bool
+	enPassant *[2]int
+}
+
+// replayPGN plays SAN moves from the starting position.
+func replayPGN(pgn string) (chessPosition, error) {
+	board, err := parseFEN(chessStartFEN)
+	if err != nil {
+		return chessPosition{}, err
+	}
+	pos := chessPosition{board: board, white: true}
+	for _, san := range strings.Fields(pgn) {
+		if strings.HasSuffix(san, ".") || san == "*" || strings.Contains(san, "-") && strings.ContainsAny(san, "12/") {
+			continue // move numbers and results
+		}
+		if err := pos.play(san); err != nil {
+			return pos, err
+		}
+	}
+	return pos, nil
+}
+
+// own reports whether piece belongs to the side to move.
+func (p *chessPosition) own(piece rune) bool {
+	return piece != 0 && (piece != toLowerASCII(piece)) == p.white
+}
+
+// piece returns the side to move's FEN letter for kind, a lowercase
+// letter.
+func (p *chessPosition) piece(kind rune) rune {
+	if p.white {
+		return kind - 'a' + 'A'
+	}
+	return kind
+}
+
+// play makes the move san for the side to move.
+func (p *chessPosition) play(san string) error {
+	move := strings.TrimRight(san, "+#!?")
+	move = strings.ReplaceAll(move, "0", "O")
+	if move == "O-O" || move == "O-O-O" {
+		row := 7
+		if !p.white {
+			row = 0
+		}
+		king, rook, kingTo, rookTo := 4, 7, 6, 5
+		if move == "O-O-O" {
+			rook, kingTo, rookTo = 0, 2, 3
+		}
+		b := &p.board
+		b[row][kingTo], b[row][rookTo] = b[row][king], b[row][rook]
+		b[row][king], b[row][rook] = 0, 0
+		p.enPassant, p.white = nil, !p.white
+		return nil
+	}
+
+	var promotion rune
+	if i := strings.IndexByte(move, '='); i >= 0 && i+1 < len(move) {
+		promotion, move = toLowerASCII(rune(move[i+1])), move[:i]
+	} else if n := len(move); n > 2 && strings.ContainsRune("QRBN", rune(move[n-1])) && move[0] >= 'a' && move[0] <= 'h' {
+		promotion, move = toLowerASCII(rune(move[n-1])), move[:n-1]
+	}
+	if len(move) < 2 {
+		return fmt.Errorf("can't read move %q", san)
+	}
+	to, ok := parseSquare(move[len(move)-2:])
+	if !ok {
+		return fmt.Errorf("can't read move %q", san)
+	}
+	kind, rest := 'p', move[:len(move)-2]
+	if rest != "" && strings.ContainsRune("KQRBN", rune(rest[0])) {
+		kind, rest = toLowerASCII(rune(rest[0])), rest[1:]
+	}
+	rest = strings.ReplaceAll(rest, "x", "")
+
+	var found [][2]int
+	for row := range 8 {
+		for file := range 8 {
+			if p.board[row][file] != p.piece(kind) || !disambiguates(rest, row, file) {
+				continue
+			}
+			from := [2]int{row, file}
+			if p.reaches(kind, from, to) && !p.after(from, to, promotion).inCheck(p.white) {
+				found = append(found, from)
+			}
+		}
+	}
+	if len(found) != 1 {
+		return fmt.Errorf("move %q fits %d pieces", san, len(found))
+	}
+	*p = p.after(found[0], to, promotion)
+	return nil
+}
+
+// disambiguates reports whether the square matches the file and rank, if
+// given, of a SAN move's disambiguation.
+func disambiguates(hint string, row, file int) bool {
+	for _, c := range hint {
+		switch {
+		case c >= 'a' && c <= 'h' && int(c-'a') != file:
+			return false
+		case c >= '1' && c <= '8' && 8-int(c-'0') != row:
+			return false
+		}
+	}
+	return true
+}
+
+// reaches reports whether the side to move's piece of kind on from can
+// move to to.
+func (p *chessPosition) reaches(kind rune, from, to [2]int) bool {
+	if kind != 'p' {
+		target := p.board[to[0]][to[1]]
+		return !p.own(target) && attacks(&p.board, kind, from, to)
+	}
+	dir := 1 // rows count down from rank 8
+	start := 1
+	if p.white {
+		dir, start = -1, 6
+	}
+	target := p.board[to[0]][to[1]]
+	if from[1] == to[1] {
+		if target != 0 {
+			return false
+		}
+		if to[0] == from[0]+dir {
+			return true
+		}
+		return from[0] == start && to[0] == from[0]+2*dir && p.board[from[0]+dir][from[1]] == 0
+	}
+	if to[0] != from[0]+dir || abs(to[1]-from[1]) != 1 {
+		return false
+	}
+	return target != 0 && !p.own(target) || p.enPassant != nil && *p.enPassant == to
+}
+
+// after returns the position once the piece on from moved to to.
+func (p chessPosition) after(from, to [2]int, promotion rune) chessPosition {
+	b := &p.board
+	piece := b[from[0]][from[1]]
+	pawn := toLowerASCII(piece) == 'p'
+	if pawn && from[1] != to[1] && b[to[0]][to[1]] == 0 {
+		b[from[0]][to[1]] = 0 // en passant
+	}
+	b[to[0]][to[1]], b[from[0]][from[1]] = piece, 0
+	if pawn && promotion != 0 {
+		b[to[0]][to[1]] = p.piece(promotion)
+	}
+	p.enPassant = nil
+	if pawn && abs(to[0]-from[0]) == 2 {
+		p.enPassant = &[2]int{(from[0] + to[0]) / 2, from[1]}
+	}
+	p.white = !p.white
+	return p
+}
+
+// inCheck reports whether white's king, or black's, is attacked.
+func (p chessPosition) inCheck(white bool) bool {
+	king := 'k'
+	if white {
+		king = 'K'
+	}
+	var at [2]int
+	for row := range 8 {
+		for file := range 8 {
+			if p.board[row][file] == king {
+				at = [2]int{row, file}
+			}
+		}
+	}
+	for row := range 8 {
+		for file := range 8 {
+			piece := p.board[row][file]
+			if piece == 0 || (piece != toLowerASCII(piece)) == white {
+				continue
+			}
+			from := [2]int{row, file}
+			if toLowerASCII(piece) == 'p' {
+				dir := 1
+				if piece == 'P' {
+					dir = -1
+				}
+				if at[0] == row+dir && abs(at[1]-file) == 1 {
+					return true
+				}
+			} else if attacks(&p.board, toLowerASCII(piece), from, at) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attacks reports whether a piece of kind, other than a pawn, on from
+// reaches to on b.
+func attacks(b *chessBoard, kind rune, from, to [2]int) bool {
+	dr, df := to[0]-from[0], to[1]-from[1]
+	switch kind {
+	case 'n':
+		return abs(dr)*abs(df) == 2
+	case 'k':
+		return max(abs(dr), abs(df)) == 1
+	}
+	var dirs [][2]int
+	switch kind {
+	case 'r':
+		dirs = rookDirs
+	case 'b':
+		dirs = bishopDirs
+	case 'q':
+		dirs = append(rookDirs[:len(rookDirs):len(rookDirs)], bishopDirs...)
+	}
+	for _, d := range dirs {
+		for r, f := from[0]+d[0], from[1]+d[1]; r >= 0 && r < 8 && f >= 0 && f < 8; r, f = r+d[0], f+d[1] {
+			if r == to[0] && f == to[1] {
+				return true
+			}
+			if b[r][f] != 0 {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// parseSquare reads the square, such as "e4", that s starts with as a
+// chessBoard row and file.
+func parseSquare(s string) ([2]int, bool) {
+	if len(s) < 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return [2]int{}, false
+	}
+	return [2]int{8 - int(s[1]-'0'), int(s[0] - 'a')}, true
+}
@@ -0,0 +1,137 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+
+	"wallpaper-changer/internal/fetch"
+)
+
+// serverTransport sends every request to srv, keeping the path.
+type serverTransport struct{ srv *httptest.Server }
+
+func (s serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(s.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	return s.srv.Client().Transport.RoundTrip(req)
+}
+
+func TestLichessPuzzleFetch(t *testing.T) {
+	daily, err := os.ReadFile(filepath.Join("testdata", "lichess_daily.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		status  int
+		offline bool
+		wantErr error
+	}{
+		{"200", http.StatusOK, false, nil},
+		{"404", http.StatusNotFound, false, fetch.ErrNotFound},
+		{"offline", 0, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/puzzle/daily" {
+					t.Errorf("asked for %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					w.Write(daily)
+				}
+			}))
+			defer srv.Close()
+			if tt.offline {
+				srv.Close() // connections are refused from here on
+			}
+			s := &lichessPuzzleSource{
+				client: fetch.New(&http.Client{Transport: serverTransport{srv}}, "test"),
+				style:  chessStyles["classic"],
+				qrCode: true,
+			}
+			c, err := s.Fetch(context.Background())
+			switch {
+			case tt.offline:
+				if err == nil {
+					t.Fatal("Fetch succeeded offline")
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer os.Remove(c.Path)
+			if c.SourceURL != lichessTrainingURL+"K69di" {
+				t.Errorf("SourceURL = %q", c.SourceURL)
+			}
+			if len(c.Tags) != 3 || c.Tags[0] != "fork" {
+				t.Errorf("Tags = %v, want the puzzle's themes", c.Tags)
+			}
+			f, err := os.Open(c.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if cfg, _, err := image.DecodeConfig(f); err != nil || cfg.Width != renderWidth || cfg.Height != renderHeight {
+				t.Errorf("rendered %dx%d (%v), want %dx%d", cfg.Width, cfg.Height, err, renderWidth, renderHeight)
+			}
+		})
+	}
+}
+
+// TestDrawQRCode checks that every module of the puzzle link lands where
+// the library put it, as an even square of pixels.
+func TestDrawQRCode(t *testing.T) {
+	link := lichessTrainingURL + "K69di"
+	code, err := qrcode.New(link, qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := code.Bitmap()
+	n := len(modules)
+	for _, side := range []int{n, 3*n + 2, 200} {
+		img := image.NewRGBA(image.Rect(0, 0, side+10, side+10))
+		r := image.Rect(5, 5, 5+side, 5+side)
+		if err := drawQRCode(img, r, link); err != nil {
+			t.Fatalf("side %d: %v", side, err)
+		}
+		scale := side / n
+		x0, y0 := r.Min.X+(side-n*scale)/2, r.Min.Y+(side-n*scale)/2
+		for y := range n {
+			for x := range n {
+				want := color.RGBA{0xff, 0xff, 0xff, 0xff}
+				if modules[y][x] {
+					want = color.RGBA{0, 0, 0, 0xff}
+				}
+				for _, p := range []image.Point{{x * scale, y * scale}, {(x+1)*scale - 1, (y+1)*scale - 1}} {
+					if got := img.RGBAAt(x0+p.X, y0+p.Y); got != want {
+						t.Fatalf("side %d: module (%d, %d) has %v, want %v", side, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+	if err := drawQRCode(image.NewRGBA(image.Rect(0, 0, 10, 10)), image.Rect(0, 0, 10, 10), link); err == nil {
+		t.Error("drew a QR code into 10 pixels")
+	}
+}
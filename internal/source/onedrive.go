@@ -0,0 +1,161 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/public"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	graphBaseURL        = "https://graph.microsoft.com/v1.0/me/drive"
+	graphImageQuery     = "?$filter=file/mimeType eq 'image/jpeg'&$top=50"
+	graphMaxBytes       = 4 << 20
+	oneDriveTokenFile   = "onedrive_token.json"
+	oneDriveAuthority   = "https://login.microsoftonline.com/"
+	oneDriveFilesScope  = "Files.Read"
+	oneDriveAuthTimeout = 5 * time.Minute
+)
+
+// oneDriveSource picks a random JPEG from the user's OneDrive or SharePoint
+// drive through Microsoft Graph.
+type oneDriveSource struct {
+	client  *fetch.Client
+	app     public.Client
+	listURL string
+}
+
+func newOneDriveSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.OneDriveClientID == "" {
+		return nil, errors.New("onedrive source needs onedrive_client_id")
+	}
+	if deps.AppDir == "" {
+		return nil, errors.New("onedrive source needs the app dir to cache its token")
+	}
+	app, err := public.New(cfg.OneDriveClientID,
+		public.WithAuthority(oneDriveAuthority+cfg.OneDriveTenantID),
+		public.WithCache(fileTokenCache(filepath.Join(deps.AppDir, oneDriveTokenFile))),
+		public.WithHTTPClient(deps.Client.HTTP))
+	if err != nil {
+		return nil, err
+	}
+	listURL := graphBaseURL + "/special/photos/children" + graphImageQuery
+	if p := strings.Trim(cfg.OneDriveFolderPath, "/"); p != "" {
+		listURL = graphBaseURL + "/root:/" + (&url.URL{Path: p}).EscapedPath() + ":/children" + graphImageQuery
+	}
+	return &oneDriveSource{client: deps.Client, app: app, listURL: listURL}, nil
+}
+
+func (s *oneDriveSource) Name() string { return "onedrive" }
+
+func (s *oneDriveSource) Host() string { return hostOf(graphBaseURL) }
+
+type driveItem struct {
+	Name        string `json:"name"`
+	WebURL      string `json:"webUrl"`
+	DownloadURL string `json:"@microsoft.graph.downloadUrl"`
+	File        *struct {
+		MimeType string `json:"mimeType"`
+	} `json:"file"`
+}
+
+func (s *oneDriveSource) Fetch(ctx context.Context) (*Candidate, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("graph bad status: %s", resp.Status)
+	}
+	var body struct {
+		Value []driveItem `json:"value"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, graphMaxBytes)).Decode(&body); err != nil {
+		return nil, err
+	}
+	var images []driveItem
+	for _, it := range body.Value {
+		if it.File != nil && it.DownloadURL != "" && strings.HasPrefix(it.File.MimeType, "image/") {
+			images = append(images, it)
+		}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no images found in OneDrive folder")
+	}
+
+	it := images[random.Intn(len(images))]
+	// downloadUrl is pre-authenticated and short-lived; no bearer token.
+	path, err := s.client.DownloadToTemp(ctx, it.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, SourceURL: it.WebURL, Title: strings.TrimSuffix(it.Name, filepath.Ext(it.Name))}, nil
+}
+
+// accessToken returns a Graph token from the cache, signing in through the
+// browser the first time.
+func (s *oneDriveSource) accessToken(ctx context.Context) (string, error) {
+	scopes := []string{oneDriveFilesScope}
+	if accounts, err := s.app.Accounts(ctx); err == nil && len(accounts) > 0 {
+		res, err := s.app.AcquireTokenSilent(ctx, scopes, public.WithSilentAccount(accounts[0]))
+		if err == nil {
+			return res.AccessToken, nil
+		}
+		fmt.Println("onedrive: cached sign-in expired, opening browser:", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, oneDriveAuthTimeout)
+	defer cancel()
+	res, err := s.app.AcquireTokenInteractive(ctx, scopes, public.WithOpenURL(openBrowser))
+	if err != nil {
+		return "", fmt.Errorf("onedrive sign-in: %w", err)
+	}
+	return res.AccessToken, nil
+}
+
+// fileTokenCache persists the MSAL cache, which holds the refresh token, in
+// the app dir.
+type fileTokenCache string
+
+func (p fileTokenCache) Replace(ctx context.Context, c cache.Unmarshaler, _ cache.ReplaceHints) error {
+	b, err := os.ReadFile(string(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(b)
+}
+
+func (p fileTokenCache) Export(ctx context.Context, c cache.Marshaler, _ cache.ExportHints) error {
+	b, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(string(p), b)
+}
@@ -0,0 +1,193 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	poetryRandomURL = "https://poetrydb.org/random/1"
+	poetryAuthorURL = "https://poetrydb.org/author,random/%s;1"
+	poetryMaxBytes  = 1 << 20
+	// The text shrinks from poetryMaxSize towards poetryMinSize until the
+	// poem fits; past that only an excerpt is shown.
+	poetryMaxSize   = 40
+	poetryMinSize   = 20
+	poetryTitleMult = 1.6
+	poetryMargin    = 120
+	poetryBorder    = 40
+)
+
+// poetryPalettes are the background gradients, top to bottom, with the
+// text color drawn on them.
+var poetryPalettes = [][3]color.RGBA{
+	{{0x1d, 0x26, 0x3b, 0xff}, {0x41, 0x5a, 0x77, 0xff}, {0xf1, 0xea, 0xda, 0xff}},
+	{{0x2b, 0x1d, 0x2f, 0xff}, {0x6b, 0x3e, 0x5c, 0xff}, {0xf6, 0xe7, 0xe0, 0xff}},
+	{{0x1b, 0x2e, 0x25, 0xff}, {0x4a, 0x6b, 0x52, 0xff}, {0xee, 0xf0, 0xe2, 0xff}},
+	{{0xf4, 0xee, 0xe1, 0xff}, {0xd9, 0xcd, 0xb4, 0xff}, {0x2e, 0x26, 0x1f, 0xff}},
+}
+
+// poetrySource sets a random poem from PoetryDB, typeset on a gradient.
+type poetrySource struct {
+	client *fetch.Client
+	author string
+}
+
+func newPoetrySource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &poetrySource{client: deps.Client, author: strings.TrimSpace(cfg.PoetryAuthorFilter)}, nil
+}
+
+func (s *poetrySource) Name() string { return "poetry" }
+
+func (s *poetrySource) Host() string { return hostOf(poetryRandomURL) }
+
+type poem struct {
+	Title  string   `json:"title"`
+	Author string   `json:"author"`
+	Lines  []string `json:"lines"`
+}
+
+func (s *poetrySource) Fetch(ctx context.Context) (*Candidate, error) {
+	u := poetryRandomURL
+	if s.author != "" {
+		u = fmt.Sprintf(poetryAuthorURL, url.PathEscape(s.author))
+	}
+	// A search without results answers with an object instead of a list.
+	var body json.RawMessage
+	if err := s.client.GetJSON(ctx, u, poetryMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	var poems []poem
+	if err := json.Unmarshal(body, &poems); err != nil || len(poems) == 0 {
+		if s.author != "" {
+			return nil, fmt.Errorf("no poems by %q on PoetryDB", s.author)
+		}
+		return nil, errors.New("PoetryDB returned no poem")
+	}
+	p := poems[0]
+	// Trailing blank lines would only push the poem off center.
+	for len(p.Lines) > 0 && strings.TrimSpace(p.Lines[len(p.Lines)-1]) == "" {
+		p.Lines = p.Lines[:len(p.Lines)-1]
+	}
+	if len(p.Lines) == 0 {
+		return nil, fmt.Errorf("PoetryDB poem %q has no text", p.Title)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	palette := poetryPalettes[random.Intn(len(poetryPalettes))]
+	imaging.FillVerticalGradient(img, palette[0], palette[1])
+	drawPoetryBorder(img, imaging.LerpColor(palette[1], palette[2], 0.3))
+	if err := drawPoem(img, p, palette[2], palette[0]); err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: p.Title, Author: p.Author, Category: "poem", Tags: []string{p.Author}}, nil
+}
+
+// drawPoem centers the title, author and lines of p on img, at the largest
+// size they fit in, or as an excerpt at poetryMinSize, in fg with the
+// author dimmed towards bg.
+func drawPoem(img *image.RGBA, p poem, fg, bg color.RGBA) error {
+	maxW := renderWidth - 2*poetryMargin
+	maxH := renderHeight - 2*poetryMargin
+	lines := p.Lines
+	size := poetryMaxSize
+	for ; size > poetryMinSize; size -= 2 {
+		if fits, err := poemFits(p.Title, lines, size, maxW, maxH); err != nil {
+			return err
+		} else if fits {
+			break
+		}
+	}
+
+	titleFace, err := imaging.NewFace(float64(size)*poetryTitleMult, true)
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	face, err := imaging.NewFace(float64(size), false)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+	lineH := face.Metrics().Height.Ceil()
+	titleH := titleFace.Metrics().Height.Ceil()
+	header := titleH + lineH*2 // title, author, a blank line
+
+	if room := (maxH - header) / lineH; len(lines) > room {
+		lines = append(append([]string(nil), lines[:room-1]...), "…")
+	}
+
+	// The poem is one left-aligned block, centered as a whole, so its
+	// indentation survives.
+	blockW := 0
+	for _, l := range lines {
+		blockW = max(blockW, min(imaging.TextWidth(face, l), maxW))
+	}
+	x := (renderWidth - blockW) / 2
+	y := (renderHeight-header-len(lines)*lineH)/2 + titleFace.Metrics().Ascent.Ceil()
+
+	title := imaging.TruncateText(titleFace, p.Title, maxW)
+	imaging.DrawText(img, titleFace, (renderWidth-imaging.TextWidth(titleFace, title))/2, y, title, fg)
+	y += titleH
+	author := imaging.TruncateText(face, p.Author, maxW)
+	imaging.DrawText(img, face, (renderWidth-imaging.TextWidth(face, author))/2, y, author, imaging.LerpColor(fg, bg, 0.35))
+	y += lineH * 2
+	for _, l := range lines {
+		imaging.DrawText(img, face, x, y, imaging.TruncateText(face, l, maxW), fg)
+		y += lineH
+	}
+	return nil
+}
+
+// poemFits reports whether title and lines fit in w by h pixels at size.
+func poemFits(title string, lines []string, size, w, h int) (bool, error) {
+	titleFace, err := imaging.NewFace(float64(size)*poetryTitleMult, true)
+	if err != nil {
+		return false, err
+	}
+	defer titleFace.Close()
+	face, err := imaging.NewFace(float64(size), false)
+	if err != nil {
+		return false, err
+	}
+	defer face.Close()
+	lineH := face.Metrics().Height.Ceil()
+	if titleFace.Metrics().Height.Ceil()+lineH*(len(lines)+2) > h {
+		return false, nil
+	}
+	for _, l := range lines {
+		if imaging.TextWidth(face, l) > w {
+			return false, nil
+		}
+	}
+	return imaging.TextWidth(titleFace, title) <= w, nil
+}
+
+// drawPoetryBorder draws a thin double frame poetryBorder pixels inside
+// the edges of img.
+func drawPoetryBorder(img *image.RGBA, c color.RGBA) {
+	frame := func(inset, width int) {
+		r := img.Bounds().Inset(inset)
+		imaging.FillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), c)
+		imaging.FillRect(img, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), c)
+		imaging.FillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), c)
+		imaging.FillRect(img, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), c)
+	}
+	frame(poetryBorder, 3)
+	frame(poetryBorder+10, 1)
+}
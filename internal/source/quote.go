@@ -0,0 +1,165 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	quoteRandomURL = "https://api.quotable.io/random"
+	quoteMaxBytes  = 64 << 10
+	// quoteMaxChars is where a long quote is cut, at a word boundary, so
+	// it stays large enough to read across the room.
+	quoteMaxChars   = 200
+	quoteTextSize   = 64
+	quoteAuthorMult = 0.5
+	quoteMargin     = 220
+)
+
+// quoteGradients are the backgrounds, top to bottom, with the text color.
+var quoteGradients = [][3]color.RGBA{
+	{{0x0f, 0x20, 0x27, 0xff}, {0x2c, 0x53, 0x64, 0xff}, {0xf5, 0xf5, 0xf0, 0xff}},
+	{{0x42, 0x27, 0x5a, 0xff}, {0x73, 0x4b, 0x6d, 0xff}, {0xfb, 0xf1, 0xe9, 0xff}},
+	{{0x13, 0x2a, 0x13, 0xff}, {0x31, 0x57, 0x3c, 0xff}, {0xf0, 0xf4, 0xe6, 0xff}},
+	{{0xff, 0xd8, 0x9b, 0xff}, {0xe9, 0x8a, 0x6b, 0xff}, {0x2b, 0x1b, 0x17, 0xff}},
+}
+
+// quoteSource sets a random quote from Quotable as typographic art.
+type quoteSource struct {
+	client   *fetch.Client
+	tags     []string
+	min, max int
+}
+
+func newQuoteSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &quoteSource{client: deps.Client, tags: cfg.QuoteTags, min: cfg.QuoteMinLength, max: cfg.QuoteMaxLength}, nil
+}
+
+func (s *quoteSource) Name() string { return "quote" }
+
+func (s *quoteSource) Host() string { return hostOf(quoteRandomURL) }
+
+type quotableQuote struct {
+	ID      string   `json:"_id"`
+	Content string   `json:"content"`
+	Author  string   `json:"author"`
+	Tags    []string `json:"tags"`
+}
+
+func (s *quoteSource) Fetch(ctx context.Context) (*Candidate, error) {
+	q := url.Values{}
+	if len(s.tags) > 0 {
+		q.Set("tags", strings.Join(s.tags, "|")) // any of them
+	}
+	if s.min > 0 {
+		q.Set("minLength", strconv.Itoa(s.min))
+	}
+	if s.max > 0 {
+		q.Set("maxLength", strconv.Itoa(s.max))
+	}
+	u := quoteRandomURL
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var qt quotableQuote
+	if err := s.client.GetJSON(ctx, u, quoteMaxBytes, &qt); err != nil {
+		return nil, err
+	}
+	text := strings.Join(strings.Fields(qt.Content), " ")
+	if text == "" {
+		return nil, errors.New("quotable returned no quote")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	g := quoteGradients[random.Intn(len(quoteGradients))]
+	imaging.FillVerticalGradient(img, g[0], g[1])
+	if err := drawQuote(img, shortenQuote(text, quoteMaxChars), qt.Author, g[2], g[1]); err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: text, Author: qt.Author, Category: "quote", Tags: qt.Tags}, nil
+}
+
+// shortenQuote cuts s after at most n characters at the last word boundary,
+// ending it with "…".
+func shortenQuote(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	cut := string([]rune(s)[:n])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-–—") + "…"
+}
+
+// drawQuote centers text, wrapped, on img in fg, with the author in smaller
+// text below it, dimmed towards bg.
+func drawQuote(img *image.RGBA, text, author string, fg, bg color.RGBA) error {
+	face, err := imaging.NewFace(quoteTextSize, true)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+	authorFace, err := imaging.NewFace(quoteTextSize*quoteAuthorMult, false)
+	if err != nil {
+		return err
+	}
+	defer authorFace.Close()
+
+	maxW := renderWidth - 2*quoteMargin
+	lines := wrapText(face, "“"+text+"”", maxW)
+	lineH := face.Metrics().Height.Ceil() * 5 / 4
+	authorH := authorFace.Metrics().Height.Ceil()
+	if author == "" {
+		author = "Unknown"
+	}
+	author = imaging.TruncateText(authorFace, "— "+author, maxW)
+
+	blockH := len(lines)*lineH + authorH*2
+	y := (renderHeight-blockH)/2 + face.Metrics().Ascent.Ceil()
+	for _, l := range lines {
+		imaging.DrawText(img, face, (renderWidth-imaging.TextWidth(face, l))/2, y, l, fg)
+		y += lineH
+	}
+	y += authorH
+	imaging.DrawText(img, authorFace, (renderWidth-imaging.TextWidth(authorFace, author))/2, y, author, imaging.LerpColor(fg, bg, 0.3))
+	return nil
+}
+
+// wrapText breaks s into lines no wider than maxW at spaces; a word wider
+// than maxW on its own is truncated.
+func wrapText(face font.Face, s string, maxW int) []string {
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(s) {
+		if line != "" && imaging.TextWidth(face, line+" "+w) <= maxW {
+			line += " " + w
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = imaging.TruncateText(face, w, maxW)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,187 @@
+package source
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	// s3AWSEndpoint is used without s3_endpoint; MinIO, Backblaze B2 and
+	// other S3-compatible stores are reached through theirs.
+	s3AWSEndpoint = "https://s3.%s.amazonaws.com"
+	s3MaxBytes    = 4 << 20
+	// s3MaxPages bounds the listing of a huge bucket; the pick comes from
+	// the first s3MaxPages*1000 images.
+	s3MaxPages = 10
+	// s3URLExpiry is how long the presigned URLs stay valid.
+	s3URLExpiry   = 15 * time.Minute
+	s3AmzDate     = "20060102T150405Z"
+	s3ShortDate   = "20060102"
+	s3UnsignedSHA = "UNSIGNED-PAYLOAD"
+)
+
+// s3BucketSource picks a random image under a prefix of an S3-compatible
+// bucket. Requests are presigned with AWS Signature Version 4 and use
+// path-style URLs, which every S3-compatible store accepts.
+type s3BucketSource struct {
+	client            *fetch.Client
+	now               func() time.Time
+	endpoint          *url.URL
+	bucket, prefix    string
+	region            string
+	accessKey, secret string
+}
+
+func newS3BucketSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+		return nil, errors.New("s3_bucket source needs s3_bucket, s3_access_key and s3_secret_key")
+	}
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(s3AWSEndpoint, cfg.S3Region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3_endpoint: %w", err)
+	}
+	return &s3BucketSource{client: deps.Client, now: deps.Now, endpoint: u, bucket: cfg.S3Bucket, prefix: cfg.S3Prefix,
+		region: cfg.S3Region, accessKey: cfg.S3AccessKey, secret: cfg.S3SecretKey}, nil
+}
+
+func (s *s3BucketSource) Name() string { return "s3_bucket" }
+
+func (s *s3BucketSource) Host() string { return s.endpoint.Hostname() }
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3BucketSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var keys []string
+	token := ""
+	for page := 0; page < s3MaxPages; page++ {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		res, err := s.list(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res.Contents {
+			switch strings.ToLower(path.Ext(c.Key)) {
+			case ".jpg", ".jpeg", ".png", ".bmp":
+				keys = append(keys, c.Key)
+			}
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			break
+		}
+		token = res.NextContinuationToken
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no images under %s/%s", s.bucket, s.prefix)
+	}
+	key := keys[random.Intn(len(keys))]
+	dl, err := s.client.DownloadToTemp(ctx, s.presign("GET", "/"+s.bucket+"/"+key, url.Values{}))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: dl, Title: path.Base(key), Category: path.Dir(key)}, nil
+}
+
+func (s *s3BucketSource) list(ctx context.Context, q url.Values) (*s3ListResult, error) {
+	resp, err := s.client.Get(ctx, s.presign("GET", "/"+s.bucket, q))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", s.bucket, err)
+	}
+	defer resp.Body.Close()
+	var res s3ListResult
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, s3MaxBytes)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("listing %s: %w", s.bucket, err)
+	}
+	return &res, nil
+}
+
+// presign returns the URL of a method request for path with query q, signed
+// in its query string with Signature Version 4.
+func (s *s3BucketSource) presign(method, path string, q url.Values) string {
+	now := s.now().UTC()
+	scope := now.Format(s3ShortDate) + "/" + s.region + "/s3/aws4_request"
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.Format(s3AmzDate))
+	q.Set("X-Amz-Expires", fmt.Sprint(int(s3URLExpiry.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	uri := s3Escape(strings.TrimSuffix(s.endpoint.Path, "/")+path, true)
+	query := s3CanonicalQuery(q)
+	canonical := strings.Join([]string{method, uri, query, "host:" + s.endpoint.Host, "", "host", s3UnsignedSHA}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", now.Format(s3AmzDate), scope, hex.EncodeToString(sum[:])}, "\n")
+
+	key := []byte("AWS4" + s.secret)
+	for _, part := range []string{now.Format(s3ShortDate), s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return s.endpoint.Scheme + "://" + s.endpoint.Host + uri + "?" + query +
+		"&X-Amz-Signature=" + hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3CanonicalQuery encodes q sorted by key, as Signature Version 4 wants.
+func s3CanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything but RFC 3986 unreserved characters
+// and, when keepSlash is set, slashes.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
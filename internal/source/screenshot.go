@@ -0,0 +1,206 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/random"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// screenshotShownFile maps the screenshots used to when, so they sit out
+	// screenshot_avoid_repeat_days.
+	screenshotShownFile = "screenshot_shown.json"
+	screenshotMinWidth  = 1280
+	screenshotMinHeight = 720
+)
+
+// screenshotSource picks a random landscape screenshot of at least 720p from
+// the user's Pictures\Screenshots folder and screenshot_dir.
+type screenshotSource struct {
+	dirs      []string
+	avoidFor  time.Duration
+	shownPath string // "" when there is no app dir to keep it in
+	now       func() time.Time
+}
+
+func newScreenshotSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Pictures", "Screenshots"))
+	}
+	if cfg.ScreenshotDir != "" {
+		dirs = append(dirs, cfg.ScreenshotDir)
+	}
+	if len(dirs) == 0 {
+		return nil, errors.New("screenshot source found no user profile; set screenshot_dir")
+	}
+	s := &screenshotSource{dirs: dirs, avoidFor: time.Duration(cfg.ScreenshotAvoidRepeatDays) * 24 * time.Hour, now: deps.Now}
+	if deps.AppDir != "" {
+		s.shownPath = filepath.Join(deps.AppDir, screenshotShownFile)
+	}
+	return s, nil
+}
+
+func (s *screenshotSource) Name() string { return "screenshot" }
+
+func (s *screenshotSource) Fetch(ctx context.Context) (*Candidate, error) {
+	now := s.now()
+	shown := s.loadShown(now)
+	var fresh, stale []string
+	for _, dir := range s.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir {
+					return err
+				}
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if d.IsDir() || !isScreenshotFile(path) {
+				return nil
+			}
+			if _, seen := shown[path]; seen {
+				stale = append(stale, path)
+			} else {
+				fresh = append(fresh, path)
+			}
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Probe in random order until one is big enough; once every usable file
+	// was shown recently, the least recently shown one goes again.
+	random.Shuffle(len(fresh), func(i, j int) { fresh[i], fresh[j] = fresh[j], fresh[i] })
+	pick := firstLandscape(fresh)
+	if pick == "" {
+		var usable []string
+		for _, p := range stale {
+			if isLandscapeScreenshot(p) {
+				usable = append(usable, p)
+			}
+		}
+		for _, p := range usable {
+			if pick == "" || shown[p].Before(shown[pick]) {
+				pick = p
+			}
+		}
+	}
+	if pick == "" {
+		return nil, fmt.Errorf("no landscape screenshots of at least %dx%d in %s",
+			screenshotMinWidth, screenshotMinHeight, strings.Join(s.dirs, ", "))
+	}
+
+	// The manager deletes the candidate file once it is set.
+	tmp, err := copyToTemp(pick)
+	if err != nil {
+		return nil, err
+	}
+	shown[pick] = now
+	s.saveShown(shown)
+	return &Candidate{Path: tmp, Title: strings.TrimSuffix(filepath.Base(pick), filepath.Ext(pick))}, nil
+}
+
+func isScreenshotFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".bmp":
+		return true
+	}
+	return false
+}
+
+func firstLandscape(paths []string) string {
+	for _, p := range paths {
+		if isLandscapeScreenshot(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// isLandscapeScreenshot reads just the header of path to check its size.
+func isLandscapeScreenshot(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	return err == nil && c.Width > c.Height && c.Width >= screenshotMinWidth && c.Height >= screenshotMinHeight
+}
+
+func copyToTemp(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp("", "wall_*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// loadShown returns the screenshots used within the avoid window.
+func (s *screenshotSource) loadShown(now time.Time) map[string]time.Time {
+	shown := map[string]time.Time{}
+	if s.shownPath == "" || s.avoidFor == 0 {
+		return shown
+	}
+	b, err := os.ReadFile(s.shownPath)
+	if err != nil {
+		return shown
+	}
+	if err := json.Unmarshal(b, &shown); err != nil {
+		fmt.Println("screenshot: discarding unreadable shown list:", err)
+		return map[string]time.Time{}
+	}
+	for path, at := range shown {
+		if now.Sub(at) >= s.avoidFor {
+			delete(shown, path)
+		}
+	}
+	return shown
+}
+
+func (s *screenshotSource) saveShown(shown map[string]time.Time) {
+	if s.shownPath == "" || s.avoidFor == 0 {
+		return
+	}
+	b, err := json.Marshal(shown)
+	if err == nil {
+		err = store.WriteFileAtomic(s.shownPath, b)
+	}
+	if err != nil {
+		fmt.Println("screenshot: failed to save shown list:", err)
+	}
+}
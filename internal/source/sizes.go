@@ -0,0 +1,112 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// aspectTolerance is how far, relatively, a size's aspect ratio may be
+	// from the screen's and still count as the same shape: 16:10 against
+	// 16:9 is past it, 3440x1440 against 2560x1080 within.
+	aspectTolerance = 0.05
+	// maxSizeAttempts caps the sizes tried before a source's default, each
+	// a request that may 404.
+	maxSizeAttempts = 4
+)
+
+// Screen shapes, for the log and for sources that ask for an orientation.
+const (
+	shapeLandscape      = "landscape"
+	shapeUltrawide      = "ultrawide"
+	shapeSuperUltrawide = "super ultrawide"
+	shapePortrait       = "portrait"
+)
+
+func aspect(p image.Point) float64 { return float64(p.X) / float64(p.Y) }
+
+// sameAspect reports whether a and b are within aspectTolerance of the
+// same shape.
+func sameAspect(a, b image.Point) bool {
+	r := aspect(a) / aspect(b)
+	return r > 1-aspectTolerance && r < 1+aspectTolerance
+}
+
+// screenShape classes screen by aspect ratio: 21:9 is about 2.37, 32:9
+// 3.56.
+func screenShape(screen image.Point) string {
+	switch r := aspect(screen); {
+	case r < 1:
+		return shapePortrait
+	case r >= 3:
+		return shapeSuperUltrawide
+	case r >= 2.1:
+		return shapeUltrawide
+	default:
+		return shapeLandscape
+	}
+}
+
+// SizeStrategy describes how downloads are sized for screen, for the log.
+func SizeStrategy(screen image.Point) string {
+	if screen.X <= 0 || screen.Y <= 0 {
+		return "screen size unknown, using each source's default"
+	}
+	shape := screenShape(screen)
+	if shape == shapePortrait {
+		return fmt.Sprintf("%s %.2f:1, asking for portrait images where sources can, else cropping from a larger size", shape, aspect(screen))
+	}
+	return fmt.Sprintf("%s %.2f:1, preferring sizes within %.0f%% of its ratio, else cropping from a larger size", shape, aspect(screen), aspectTolerance*100)
+}
+
+// sizeLadder orders the sizes a source publishes for screen, which always
+// comes first: then larger sizes of the same shape, smallest first; then
+// sizes of another shape that still cover the screen, to crop from, least
+// waste first; then smaller sizes of the same shape, largest first. Smaller
+// sizes of another shape would be cropped and stretched both and are left
+// to the source's default. Capped at maxSizeAttempts, the ladder still
+// keeps the largest smaller size, which may be all a source has.
+func sizeLadder(screen image.Point, published []image.Point) []image.Point {
+	area := func(p image.Point) int { return p.X * p.Y }
+	var same, cover, smaller []image.Point
+	for _, sz := range published {
+		switch {
+		case sz == screen:
+		case sameAspect(sz, screen) && sz.X >= screen.X:
+			same = append(same, sz)
+		case sz.X >= screen.X && sz.Y >= screen.Y:
+			cover = append(cover, sz)
+		case sameAspect(sz, screen):
+			smaller = append(smaller, sz)
+		}
+	}
+	sort.SliceStable(same, func(i, j int) bool { return area(same[i]) < area(same[j]) })
+	sort.SliceStable(cover, func(i, j int) bool { return area(cover[i]) < area(cover[j]) })
+	sort.SliceStable(smaller, func(i, j int) bool { return area(smaller[i]) > area(smaller[j]) })
+	ladder := append([]image.Point{screen}, same...)
+	ladder = append(ladder, cover...)
+	keep := min(len(smaller), max(1, maxSizeAttempts-len(ladder)))
+	ladder = ladder[:min(len(ladder), maxSizeAttempts-keep)]
+	return append(ladder, smaller[:keep]...)
+}
+
+// coverWidth is the width an image of aspect ratio r has to be rendered at
+// to cover screen, so it is cropped down rather than stretched up; for a
+// rotated monitor that is far more than the screen's own width.
+func coverWidth(screen image.Point, r float64) int {
+	return max(screen.X, int(math.Ceil(float64(screen.Y)*r)))
+}
+
+// unsplashOrientation is Unsplash's orientation search filter for screen.
+func unsplashOrientation(screen image.Point) string {
+	switch {
+	case screen.Y > screen.X:
+		return "portrait"
+	case sameAspect(screen, image.Pt(1, 1)):
+		return "squarish"
+	default:
+		return "landscape"
+	}
+}
@@ -0,0 +1,69 @@
+package source
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+// sixteenNine is a ladder of the kind most sources publish, 16:9 only.
+var sixteenNine = []image.Point{{3840, 2160}, {2560, 1440}, {1920, 1080}, {1366, 768}}
+
+func TestSizeLadder(t *testing.T) {
+	pt := image.Pt
+	tests := []struct {
+		name      string
+		screen    image.Point
+		published []image.Point
+		shape     string
+		want      []image.Point
+	}{
+		{"16:9 keeps a smaller fallback", pt(1920, 1080), publishedImageSizes, shapeLandscape,
+			[]image.Point{pt(1920, 1080), pt(2560, 1440), pt(3840, 2160), pt(1600, 900)}},
+		{"16:9 with room for every smaller size", pt(2560, 1440), sixteenNine, shapeLandscape,
+			[]image.Point{pt(2560, 1440), pt(3840, 2160), pt(1920, 1080), pt(1366, 768)}},
+		{"21:9 crops from larger sizes", pt(3440, 1440), publishedImageSizes, shapeUltrawide,
+			[]image.Point{pt(3440, 1440), pt(5120, 1440), pt(3840, 2160), pt(2560, 1080)}},
+		{"21:9 prefers its shape", pt(2560, 1080), publishedImageSizes, shapeUltrawide,
+			[]image.Point{pt(2560, 1080), pt(3440, 1440), pt(2560, 1440), pt(2560, 1600)}},
+		{"21:9 against 16:9 only", pt(3440, 1440), sixteenNine, shapeUltrawide,
+			[]image.Point{pt(3440, 1440), pt(3840, 2160)}},
+		{"32:9 largest published", pt(5120, 1440), publishedImageSizes, shapeSuperUltrawide,
+			[]image.Point{pt(5120, 1440), pt(3840, 1080)}},
+		{"32:9", pt(3840, 1080), publishedImageSizes, shapeSuperUltrawide,
+			[]image.Point{pt(3840, 1080), pt(5120, 1440), pt(3840, 2160)}},
+		{"9:16", pt(1080, 1920), publishedImageSizes, shapePortrait,
+			[]image.Point{pt(1080, 1920), pt(1440, 2560), pt(2160, 3840), pt(3840, 2160)}},
+		{"9:16 keeps a smaller fallback", pt(1440, 2560), publishedImageSizes, shapePortrait,
+			[]image.Point{pt(1440, 2560), pt(2160, 3840), pt(1080, 1920)}},
+		{"9:16 against 16:9 only", pt(1080, 1920), sixteenNine, shapePortrait,
+			[]image.Point{pt(1080, 1920), pt(3840, 2160)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := screenShape(tt.screen); got != tt.shape {
+				t.Errorf("screenShape = %q, want %q", got, tt.shape)
+			}
+			got := sizeLadder(tt.screen, tt.published)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sizeLadder =\n%v\nwant\n%v", got, tt.want)
+			}
+			if len(got) > maxSizeAttempts {
+				t.Errorf("%d sizes, more than maxSizeAttempts", len(got))
+			}
+		})
+	}
+}
+
+func TestUnsplashOrientation(t *testing.T) {
+	for screen, want := range map[image.Point]string{
+		{1920, 1080}: "landscape",
+		{3440, 1440}: "landscape",
+		{1080, 1920}: "portrait",
+		{1920, 1920}: "squarish",
+	} {
+		if got := unsplashOrientation(screen); got != want {
+			t.Errorf("unsplashOrientation(%v) = %q, want %q", screen, got, want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package source defines WallpaperSource and the built-in sources selected by
+// the config "source" field.
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"net/url"
+	"sort"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+// Size of images the generated sources render.
+const (
+	renderWidth  = imaging.RenderWidth
+	renderHeight = imaging.RenderHeight
+)
+
+// Candidate is an image a source produced, saved to a temp file the caller removes.
+type Candidate struct {
+	Path      string
+	SourceURL string // page the image came from, if any
+
+	// Metadata filters such as exclude_keywords match against; any of it
+	// may be empty.
+	Title    string
+	Author   string
+	Category string
+	Tags     []string
+	// Size is the resolution actually downloaded when the source had to
+	// substitute one for the screen size.
+	Size string
+}
+
+// WallpaperSource produces wallpaper candidates.
+type WallpaperSource interface {
+	Name() string
+	Fetch(ctx context.Context) (*Candidate, error)
+}
+
+// Hosted is implemented by sources that download from one known host, so
+// the host can be resolved ahead of the request.
+type Hosted interface {
+	Host() string
+}
+
+// Category is one entry of a source's category list.
+type Category struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// Categorized is implemented by sources that can list their categories, so
+// they can be browsed instead of typing slugs blind.
+type Categorized interface {
+	Categories(ctx context.Context) ([]Category, error)
+}
+
+// Refetchable is implemented by sources that can download a wallpaper they
+// produced before again from its SourceURL, at the size they would pick now.
+// It fails with fetch.ErrNotFound when the page is gone.
+type Refetchable interface {
+	Refetch(ctx context.Context, sourceURL string) (*Candidate, error)
+}
+
+// Deterministic is implemented by sources that may fetch the same image
+// again and again, such as the offline generators in generator_mode
+// "daily". Fetching again after turning one down is no use while
+// Deterministic reports true.
+type Deterministic interface {
+	Deterministic() bool
+}
+
+// hostOf returns rawURL's host name, or "" if it doesn't parse.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Deps are the dependencies shared by every source.
+type Deps struct {
+	Client *fetch.Client
+	// AppDir holds per-source state such as cached OAuth tokens.
+	AppDir string
+	Now    func() time.Time
+	// Screen is the target monitor's size in pixels; zero when unknown.
+	Screen image.Point
+	// PreferDark asks sources that search by keyword for dark, night-time
+	// images.
+	PreferDark bool
+	// Notify, if set, shows the user something a source noticed, such as
+	// an aurora forecast.
+	Notify func(title, msg string)
+}
+
+// generatorRand seeds an offline generator: by the date when daily, so the
+// same day always draws the same picture, else from the app's random
+// source.
+func generatorRand(daily bool, now time.Time) *rand.Rand {
+	seed := random.Int63()
+	if daily {
+		y, m, d := now.Date()
+		seed = int64(y*10000 + int(m)*100 + d)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+type factory func(cfg config.Config, deps Deps) (WallpaperSource, error)
+
+// factories maps config "source" names to constructors; keep the keys in sync
+// with config.SourceNames.
+var factories = map[string]factory{
+	"wallscloud": newWallscloudSource,
+	"cityscape":  newCityscapeSource,
+
+	"github_trending": newGitHubTrendingSource,
+	"stock_heatmap":   newStockHeatmapSource,
+	"google_photos":   newGooglePhotosSource,
+	"aerial":          newAerialSource,
+	"iss_live":        newISSLiveSource,
+	"onedrive":        newOneDriveSource,
+	"crypto_chart":    newCryptoChartSource,
+	"coolors":         newCoolorsSource,
+	"aqi_map":         newAQIMapSource,
+	"street_view":     newStreetViewSource,
+	"starfield":       newStarfieldSource,
+	"iso_city":        newIsoCitySource,
+	"webcam":          newWebcamSource,
+	"static_map":      newStaticMapSource,
+	"clock":           newClockSource,
+	"sysmon":          newSysMonSource,
+	"google_calendar": newCalendarSource,
+	"voronoi":         newVoronoiSource,
+	"screenshot":      newScreenshotSource,
+	"book_covers":     newBookCoverSource,
+	"watercolor_map":  newWatercolorMapSource,
+	"cern_events":     newCERNSource,
+	"earthgazing":     newEarthgazingSource,
+	"dalle":           newDALLESource,
+	"s3_bucket":       newS3BucketSource,
+	"ftp":             newFTPSource,
+	"deviantart":      newDeviantArtSource,
+	"aurora":          newAuroraSource,
+	"500px":           newFiveHundredPxSource,
+	"chess":           newChessSource,
+	"poetry":          newPoetrySource,
+	"hacker_news":     newHackerNewsSource,
+	"lichess_puzzle":  newLichessPuzzleSource,
+	"quote":           newQuoteSource,
+	"world_clock":     newWorldClockSource,
+
+	"wikipedia_featured": newWikipediaFeaturedSource,
+	"wikipedia_random":   newWikipediaRandomSource,
+	"stable_diffusion":   newStableDiffusionSource,
+	"wikimedia_potd":     newWikimediaPotdSource,
+	"windows_builtin":    newWindowsBuiltInSource,
+}
+
+// refreshIntervals lists sources whose picture goes stale within the day and
+// is redrawn this often while the source is selected.
+var refreshIntervals = map[string]time.Duration{
+	"clock":  time.Minute,
+	"sysmon": 5 * time.Minute,
+	"aurora": 30 * time.Minute,
+
+	"google_calendar": 15 * time.Minute,
+	"world_clock":     time.Minute,
+}
+
+// RefreshInterval returns how often the named source's wallpaper should be
+// redrawn, or 0 if once a day is enough.
+func RefreshInterval(name string) time.Duration {
+	return refreshIntervals[name]
+}
+
+// New returns the source selected by cfg.Source, or with
+// weighted_random_selection a source drawn from cfg.SourceWeights.
+func New(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if deps.Client == nil {
+		deps.Client = fetch.New(nil, "")
+	}
+	if deps.Now == nil {
+		deps.Now = time.Now
+	}
+	if cfg.WeightedRandomSelection {
+		return newWeighted(cfg, deps)
+	}
+	return newNamed(cfg.Source, cfg, deps)
+}
+
+func newNamed(name string, cfg config.Config, deps Deps) (WallpaperSource, error) {
+	f, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", name)
+	}
+	return f(cfg, deps)
+}
+
+// newWeighted builds every weighted source and draws one. Sources that can't
+// be built (e.g. missing credentials) are left out of the draw.
+func newWeighted(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	sources := map[string]WallpaperSource{}
+	for name, w := range cfg.SourceWeights {
+		if w <= 0 {
+			continue
+		}
+		src, err := newNamed(name, cfg, deps)
+		if err != nil {
+			fmt.Printf("source_weights: skipping %s: %v\n", name, err)
+			continue
+		}
+		sources[name] = src
+	}
+	src := selectWeightedSource(cfg.SourceWeights, sources)
+	if src == nil {
+		return nil, errors.New("no weighted source could be set up")
+	}
+	return src, nil
+}
+
+// selectWeightedSource draws one of sources with probability proportional to
+// its weight. Names missing from either map, or with weight <= 0, never win.
+// It returns nil when nothing can be drawn.
+func selectWeightedSource(weights map[string]float64, sources map[string]WallpaperSource) WallpaperSource {
+	names := make([]string, 0, len(sources))
+	total := 0.0
+	for name := range sources {
+		if w := weights[name]; w > 0 {
+			names = append(names, name)
+			total += w
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	// Fixed order so a given draw always maps to the same source.
+	sort.Strings(names)
+	r := random.Float64() * total
+	cum := 0.0
+	for _, name := range names {
+		cum += weights[name]
+		if r < cum {
+			return sources[name]
+		}
+	}
+	return sources[names[len(names)-1]] // r rounded up to total
+}
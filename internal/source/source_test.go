@@ -0,0 +1,139 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+func TestFactoriesMatchSourceNames(t *testing.T) {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !slices.Equal(names, config.SourceNames) {
+		t.Errorf("factories = %v\nSourceNames = %v", names, config.SourceNames)
+	}
+}
+
+func TestNewUnknownSource(t *testing.T) {
+	cfg := config.Default()
+	cfg.Source = "nowhere"
+	if _, err := New(cfg, Deps{}); err == nil {
+		t.Error("New succeeded for an unknown source")
+	}
+}
+
+// testNow is a fixed time for the offline generators.
+func testNow() time.Time { return time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC) }
+
+// TestGenerators renders the generators that need no network. iso_city is
+// left out: a full render takes minutes on a slow machine.
+func TestGenerators(t *testing.T) {
+	for _, name := range []string{"clock", "starfield", "voronoi", "world_clock"} {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Source = name
+			src, err := New(cfg, Deps{Now: testNow, AppDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if src.Name() != name {
+				t.Errorf("Name = %q, want %q", src.Name(), name)
+			}
+			c, err := src.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer os.Remove(c.Path)
+			img, err := imaging.DecodeFile(c.Path)
+			if err != nil {
+				t.Fatalf("decoding the generated image: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != renderWidth || b.Dy() != renderHeight {
+				t.Errorf("generated %dx%d, want %dx%d", b.Dx(), b.Dy(), renderWidth, renderHeight)
+			}
+		})
+	}
+}
+
+// namedSource is a fake source that only has a name.
+type namedSource string
+
+func (n namedSource) Name() string { return string(n) }
+
+func (n namedSource) Fetch(context.Context) (*Candidate, error) { return nil, nil }
+
+// reseed seeds the app's random source for one test.
+func reseed(t *testing.T, seed int64) {
+	saved := random.CurrentSeed()
+	random.Seed(seed)
+	t.Cleanup(func() { random.Seed(saved) })
+}
+
+// TestWeightedSelectionReplays checks that two runs seeded alike draw the
+// same sequence of sources.
+func TestWeightedSelectionReplays(t *testing.T) {
+	weights := map[string]float64{"wallscloud": 3, "clock": 1, "starfield": 2, "voronoi": 0.5}
+	sources := map[string]WallpaperSource{}
+	for name := range weights {
+		sources[name] = namedSource(name)
+	}
+	run := func(seed int64) []string {
+		reseed(t, seed)
+		var picks []string
+		for range 50 {
+			picks = append(picks, selectWeightedSource(weights, sources).Name())
+		}
+		return picks
+	}
+	first := run(7)
+	if again := run(7); !slices.Equal(first, again) {
+		t.Errorf("seed 7 drew\n%v\nthen\n%v", first, again)
+	}
+	if other := run(8); slices.Equal(first, other) {
+		t.Error("seeds 7 and 8 drew the same 50 sources")
+	}
+}
+
+// TestGeneratorRand checks that a daily generator draws the same picture all
+// day whatever the app's seed, and a random one follows the seed.
+func TestGeneratorRand(t *testing.T) {
+	cfg := config.Default()
+	src, err := newStarfieldSource(cfg, Deps{Now: testNow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := src.(*starfieldSource)
+	render := func(daily bool, now time.Time, seed int64) []byte {
+		reseed(t, seed)
+		return s.render(generatorRand(daily, now)).Pix
+	}
+	morning := testNow()
+	evening := morning.Add(12 * time.Hour)
+	tomorrow := morning.Add(24 * time.Hour)
+
+	daily := render(true, morning, 1)
+	if !bytes.Equal(daily, render(true, evening, 2)) {
+		t.Error("daily pictures differ within the same day")
+	}
+	if bytes.Equal(daily, render(true, tomorrow, 1)) {
+		t.Error("daily picture is the same the next day")
+	}
+	seeded := render(false, morning, 5)
+	if !bytes.Equal(seeded, render(false, tomorrow, 5)) {
+		t.Error("random pictures differ for the same seed")
+	}
+	if bytes.Equal(seeded, render(false, morning, 6)) {
+		t.Error("random pictures are the same for different seeds")
+	}
+}
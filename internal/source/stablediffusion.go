@@ -0,0 +1,115 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	sdTxt2ImgURL = "http://127.0.0.1:7860/sdapi/v1/txt2img"
+	// sdMaxBytes fits a few base64 PNGs of the largest allowed size.
+	sdMaxBytes = 64 << 20
+)
+
+// stableDiffusionSource generates a picture from a prompt with a locally
+// running Automatic1111 web UI. When its API isn't reachable — the web UI
+// isn't started — the change falls back to sd_fallback_source.
+type stableDiffusionSource struct {
+	client  *fetch.Client
+	request sdTxt2ImgRequest
+	// cfg and deps build the fallback source.
+	cfg  config.Config
+	deps Deps
+}
+
+type sdTxt2ImgRequest struct {
+	Prompt         string  `json:"prompt"`
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	Steps          int     `json:"steps"`
+	CFGScale       float64 `json:"cfg_scale"`
+}
+
+func newStableDiffusionSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.SDPrompt == "" {
+		return nil, errors.New("stable_diffusion source needs sd_prompt")
+	}
+	return &stableDiffusionSource{
+		client: deps.Client,
+		request: sdTxt2ImgRequest{Prompt: cfg.SDPrompt, NegativePrompt: cfg.SDNegativePrompt,
+			Width: cfg.SDWidth, Height: cfg.SDHeight, Steps: cfg.SDSteps, CFGScale: cfg.SDCFGScale},
+		cfg:  cfg,
+		deps: deps,
+	}, nil
+}
+
+func (s *stableDiffusionSource) Name() string { return "stable_diffusion" }
+
+func (s *stableDiffusionSource) Fetch(ctx context.Context) (*Candidate, error) {
+	c, err := s.generate(ctx)
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return c, err
+	}
+	fb, ferr := newNamed(s.cfg.SDFallbackSource, s.cfg, s.deps)
+	if ferr != nil {
+		return nil, fmt.Errorf("%w (fallback: %v)", err, ferr)
+	}
+	fmt.Printf("stable_diffusion: API unreachable (%v), using %s\n", err, fb.Name())
+	return fb.Fetch(ctx)
+}
+
+func (s *stableDiffusionSource) generate(ctx context.Context) (*Candidate, error) {
+	b, err := json.Marshal(s.request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sdTxt2ImgURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stable diffusion bad status: %s", resp.Status)
+	}
+	var out struct {
+		Images []string `json:"images"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, sdMaxBytes)).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Images) == 0 {
+		return nil, errors.New("stable diffusion returned no image")
+	}
+	raw, err := base64.StdEncoding.DecodeString(out.Images[0])
+	if err != nil {
+		return nil, fmt.Errorf("stable diffusion image: %w", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: stable diffusion image: %v", imaging.ErrCorrupt, err)
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: s.request.Prompt, Author: "Stable Diffusion", Category: "generated"}, nil
+}
@@ -0,0 +1,223 @@
+package source
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	// starMaxRadius is the radius in pixels of the brightest stars.
+	starMaxRadius = 2.5
+	// nebulaScale is the size in pixels of the largest nebula features.
+	nebulaScale   = 600.0
+	nebulaOctaves = 5
+	nebulaOpacity = 0.55
+)
+
+var (
+	spaceTop    = color.RGBA{R: 2, G: 3, B: 12, A: 0xff}
+	spaceBottom = color.RGBA{R: 12, G: 10, B: 34, A: 0xff}
+	// nebulaColors are blended by two independent noise fields.
+	nebulaColors = [2]color.RGBA{{R: 140, G: 40, B: 150, A: 0xff}, {R: 30, G: 110, B: 170, A: 0xff}}
+)
+
+// spectralClass is a stellar spectral type's apparent color and its share
+// of naked-eye stars.
+type spectralClass struct {
+	color  color.RGBA
+	weight float64
+}
+
+var spectralClasses = []spectralClass{
+	{color.RGBA{R: 155, G: 176, B: 255, A: 0xff}, 0.04}, // O
+	{color.RGBA{R: 170, G: 191, B: 255, A: 0xff}, 0.18}, // B
+	{color.RGBA{R: 202, G: 215, B: 255, A: 0xff}, 0.20}, // A
+	{color.RGBA{R: 248, G: 247, B: 255, A: 0xff}, 0.15}, // F
+	{color.RGBA{R: 255, G: 244, B: 234, A: 0xff}, 0.12}, // G
+	{color.RGBA{R: 255, G: 210, B: 161, A: 0xff}, 0.22}, // K
+	{color.RGBA{R: 255, G: 204, B: 111, A: 0xff}, 0.09}, // M
+}
+
+// starfieldSource draws stars and optional nebula clouds offline. In "daily"
+// mode the picture is seeded by the date, so a day keeps its sky.
+type starfieldSource struct {
+	now       func() time.Time
+	daily     bool
+	density   float64
+	colorMode string
+	nebula    bool
+}
+
+func newStarfieldSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &starfieldSource{
+		now:       deps.Now,
+		daily:     cfg.GeneratorMode == "daily",
+		density:   cfg.StarDensity,
+		colorMode: cfg.StarColorMode,
+		nebula:    cfg.NebulaEnabled,
+	}, nil
+}
+
+func (s *starfieldSource) Name() string { return "starfield" }
+
+// Deterministic reports whether the same day always draws the same picture.
+func (s *starfieldSource) Deterministic() bool { return s.daily }
+
+func (s *starfieldSource) Fetch(ctx context.Context) (*Candidate, error) {
+	path, err := imaging.WriteTempBMP(s.render(generatorRand(s.daily, s.now())))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "Starfield"}, nil
+}
+
+func (s *starfieldSource) render(rng *rand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, spaceTop, spaceBottom)
+	if s.nebula {
+		drawNebula(img, newPerlin(rng), newPerlin(rng))
+	}
+	// StarDensity is stars per thousand pixels.
+	n := int(s.density * renderWidth * renderHeight / 1000)
+	for range n {
+		// Most stars are faint: brightness falls off as a power law.
+		brightness := math.Pow(rng.Float64(), 3)
+		c := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		if s.colorMode == "realistic" {
+			c = spectralColor(rng)
+		}
+		drawStar(img, rng.Float64()*renderWidth, rng.Float64()*renderHeight,
+			0.5+brightness*(starMaxRadius-0.5), 0.3+brightness*0.7, c)
+	}
+	return img
+}
+
+// spectralColor picks a star color weighted by spectral class frequency.
+func spectralColor(rng *rand.Rand) color.RGBA {
+	v := rng.Float64()
+	for _, sc := range spectralClasses {
+		if v -= sc.weight; v < 0 {
+			return sc.color
+		}
+	}
+	return spectralClasses[len(spectralClasses)-1].color
+}
+
+// drawStar adds a soft disc of radius r centered at (cx, cy), fading out
+// towards the edge, at peak opacity alpha.
+func drawStar(img *image.RGBA, cx, cy, r, alpha float64, c color.RGBA) {
+	b := img.Bounds()
+	for y := int(cy - r - 1); y <= int(cy+r+1); y++ {
+		for x := int(cx - r - 1); x <= int(cx+r+1); x++ {
+			if !(image.Point{X: x, Y: y}).In(b) {
+				continue
+			}
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			if d > r {
+				continue
+			}
+			blendPixel(img, x, y, c, alpha*(1-d/r))
+		}
+	}
+}
+
+// drawNebula washes img with two colors following fractal noise, each
+// thresholded so clouds leave dark gaps between them.
+func drawNebula(img *image.RGBA, a, b *perlin) {
+	for y := 0; y < renderHeight; y++ {
+		for x := 0; x < renderWidth; x++ {
+			fx, fy := float64(x)/nebulaScale, float64(y)/nebulaScale
+			for i, p := range []*perlin{a, b} {
+				v := p.fractal(fx, fy, nebulaOctaves)
+				// fractal is roughly in [-0.7, 0.7]; keep the upper part.
+				t := math.Max(0, v-0.05) / 0.5
+				if t > 0 {
+					blendPixel(img, x, y, nebulaColors[i], math.Min(t, 1)*nebulaOpacity)
+				}
+			}
+		}
+	}
+}
+
+// blendPixel mixes c into the pixel at (x, y) by t in [0, 1], additively so
+// overlapping light brightens.
+func blendPixel(img *image.RGBA, x, y int, c color.RGBA, t float64) {
+	p := img.RGBAAt(x, y)
+	add := func(a, b uint8) uint8 { return uint8(math.Min(255, float64(a)+float64(b)*t)) }
+	img.SetRGBA(x, y, color.RGBA{R: add(p.R, c.R), G: add(p.G, c.G), B: add(p.B, c.B), A: 0xff})
+}
+
+// perlin is 2D gradient noise over a shuffled permutation table.
+type perlin struct {
+	perm [512]uint8
+}
+
+func newPerlin(rng *rand.Rand) *perlin {
+	p := &perlin{}
+	for i, v := range rng.Perm(256) {
+		p.perm[i] = uint8(v)
+		p.perm[i+256] = uint8(v)
+	}
+	return p
+}
+
+// fractal sums octaves of noise at doubling frequency and halving amplitude.
+func (p *perlin) fractal(x, y float64, octaves int) float64 {
+	sum, amp, freq := 0.0, 1.0, 1.0
+	for range octaves {
+		sum += amp * p.noise(x*freq, y*freq)
+		amp /= 2
+		freq *= 2
+	}
+	return sum / 2
+}
+
+// noise is Perlin noise at (x, y), in roughly [-1, 1].
+func (p *perlin) noise(x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	xi, yi := int(x0)&255, int(y0)&255
+	xf, yf := x-x0, y-y0
+	u, v := perlinFade(xf), perlinFade(yf)
+
+	aa := p.perm[int(p.perm[xi])+yi]
+	ab := p.perm[int(p.perm[xi])+yi+1]
+	ba := p.perm[int(p.perm[xi+1])+yi]
+	bb := p.perm[int(p.perm[xi+1])+yi+1]
+
+	top := perlinLerp(perlinGrad(aa, xf, yf), perlinGrad(ba, xf-1, yf), u)
+	bottom := perlinLerp(perlinGrad(ab, xf, yf-1), perlinGrad(bb, xf-1, yf-1), u)
+	return perlinLerp(top, bottom, v)
+}
+
+func perlinFade(t float64) float64 { return t * t * t * (t*(t*6-15) + 10) }
+
+func perlinLerp(a, b, t float64) float64 { return a + (b-a)*t }
+
+// perlinGrad dots (x, y) with one of eight gradient directions picked by h.
+func perlinGrad(h uint8, x, y float64) float64 {
+	switch h & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	default:
+		return -y
+	}
+}
@@ -0,0 +1,150 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	mapTilerStaticURL   = "https://api.maptiler.com/maps/%s/static/%s,%s,%d/%dx%d.png"
+	nominatimReverseURL = "https://nominatim.openstreetmap.org/reverse"
+	nominatimMaxBytes   = 64 << 10
+	// MapTiler renders at most this many pixels per side.
+	mapTilerMaxSide     = 2048
+	watermarkMargin     = 40
+	watermarkPadding    = 16
+	watermarkLineHeight = 40
+)
+
+// staticMapSource renders a MapTiler static map around a fixed point, with
+// the time and the place name from Nominatim in a corner.
+type staticMapSource struct {
+	client   *fetch.Client
+	now      func() time.Time
+	apiKey   string
+	style    string
+	lat, lon float64
+	zoom     int
+	size     image.Point
+}
+
+func newStaticMapSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.StaticMapAPIKey == "" {
+		return nil, fmt.Errorf("static_map source needs static_map_api_key")
+	}
+	size := deps.Screen
+	if size.X <= 0 || size.Y <= 0 {
+		size = image.Pt(renderWidth, renderHeight)
+	}
+	// Keep the aspect ratio when scaling down to what the API renders.
+	if side := max(size.X, size.Y); side > mapTilerMaxSide {
+		size = image.Pt(size.X*mapTilerMaxSide/side, size.Y*mapTilerMaxSide/side)
+	}
+	return &staticMapSource{client: deps.Client, now: deps.Now, apiKey: cfg.StaticMapAPIKey, style: cfg.StaticMapStyle,
+		lat: cfg.StaticMapLat, lon: cfg.StaticMapLon, zoom: cfg.StaticMapZoom, size: size}, nil
+}
+
+func (s *staticMapSource) Name() string { return "static_map" }
+
+func (s *staticMapSource) Host() string { return hostOf(mapTilerStaticURL) }
+
+func (s *staticMapSource) Fetch(ctx context.Context) (*Candidate, error) {
+	u := fmt.Sprintf(mapTilerStaticURL, url.PathEscape(s.style), formatCoord(s.lon), formatCoord(s.lat), s.zoom, s.size.X, s.size.Y) +
+		"?" + url.Values{"key": {s.apiKey}}.Encode()
+	path, err := s.client.DownloadToTemp(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	m, err := imaging.DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	place, err := s.placeName(ctx)
+	if err != nil {
+		// The map is still worth having without its caption.
+		fmt.Println("static_map: reverse geocoding failed:", err)
+		place = formatCoord(s.lat) + ", " + formatCoord(s.lon)
+	}
+	img := image.NewRGBA(m.Bounds())
+	draw.Draw(img, img.Bounds(), m, m.Bounds().Min, draw.Src)
+	if err := drawWatermark(img, []string{s.now().Format("15:04, Monday Jan 2"), place}); err != nil {
+		return nil, err
+	}
+	out, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      out,
+		SourceURL: fmt.Sprintf("https://www.openstreetmap.org/#map=%d/%s/%s", s.zoom, formatCoord(s.lat), formatCoord(s.lon)),
+		Title:     "Map of " + place,
+	}, nil
+}
+
+// placeName reverse-geocodes the map center to a city and country.
+func (s *staticMapSource) placeName(ctx context.Context) (string, error) {
+	var body struct {
+		DisplayName string `json:"display_name"`
+		Address     struct {
+			City    string `json:"city"`
+			Town    string `json:"town"`
+			Village string `json:"village"`
+			Country string `json:"country"`
+		} `json:"address"`
+	}
+	q := url.Values{"format": {"jsonv2"}, "lat": {formatCoord(s.lat)}, "lon": {formatCoord(s.lon)}, "zoom": {"10"}}
+	if err := s.client.GetJSON(ctx, nominatimReverseURL+"?"+q.Encode(), nominatimMaxBytes, &body); err != nil {
+		return "", err
+	}
+	a := body.Address
+	for _, name := range []string{a.City, a.Town, a.Village} {
+		if name == "" {
+			continue
+		}
+		if a.Country != "" {
+			name += ", " + a.Country
+		}
+		return name, nil
+	}
+	if body.DisplayName == "" {
+		return "", fmt.Errorf("no place at %s, %s", formatCoord(s.lat), formatCoord(s.lon))
+	}
+	return body.DisplayName, nil
+}
+
+// drawWatermark writes lines in the bottom-right corner on a translucent
+// dark panel.
+func drawWatermark(img *image.RGBA, lines []string) error {
+	face, err := imaging.NewFace(28, false)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	width := 0
+	for i, l := range lines {
+		lines[i] = imaging.TruncateText(face, l, b.Dx()/2)
+		width = max(width, imaging.TextWidth(face, lines[i]))
+	}
+	panel := image.Rect(b.Max.X-watermarkMargin-width-2*watermarkPadding, b.Max.Y-watermarkMargin-len(lines)*watermarkLineHeight-2*watermarkPadding,
+		b.Max.X-watermarkMargin, b.Max.Y-watermarkMargin)
+	draw.Draw(img, panel, image.NewUniform(color.RGBA{A: 0x99}), image.Point{}, draw.Over)
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	for i, l := range lines {
+		imaging.DrawText(img, face, panel.Min.X+watermarkPadding, panel.Min.Y+watermarkPadding+(i+1)*watermarkLineHeight-10, l, white)
+	}
+	return nil
+}
+
+func formatCoord(v float64) string { return strconv.FormatFloat(v, 'f', 5, 64) }
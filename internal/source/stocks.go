@@ -0,0 +1,235 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	finnhubQuoteURL      = "https://finnhub.io/api/v1/quote"
+	alphaVantageURL      = "https://www.alphavantage.co/query"
+	financeMaxBytes      = 1 << 20
+	heatmapFullScalePct  = 3.0 // change that maps to full green/red
+	heatmapTilePadding   = 3
+	heatmapMinLabelWidth = 140
+)
+
+// sectorETF is an S&P 500 sector with its SPDR ETF and approximate index weight.
+type sectorETF struct {
+	Symbol string
+	Name   string
+	Weight float64 // percent of S&P 500 market cap
+}
+
+// sp500Sectors approximates sector weights; they only size the tiles.
+var sp500Sectors = []sectorETF{
+	{"XLK", "Technology", 31},
+	{"XLF", "Financials", 13},
+	{"XLV", "Health Care", 11},
+	{"XLY", "Consumer Discretionary", 10},
+	{"XLC", "Communication Services", 9},
+	{"XLI", "Industrials", 8},
+	{"XLP", "Consumer Staples", 6},
+	{"XLE", "Energy", 3.5},
+	{"XLU", "Utilities", 2.5},
+	{"XLRE", "Real Estate", 2.3},
+	{"XLB", "Materials", 2.2},
+}
+
+// stockHeatmapSource renders S&P 500 sector daily performance as a treemap.
+type stockHeatmapSource struct {
+	client   *fetch.Client
+	apiKey   string
+	provider string
+}
+
+func newStockHeatmapSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.FinanceAPIKey == "" {
+		return nil, fmt.Errorf("stock_heatmap source needs finance_api_key")
+	}
+	switch cfg.FinanceAPIProvider {
+	case "finnhub", "alphavantage":
+	default:
+		return nil, fmt.Errorf("unknown finance API provider %q", cfg.FinanceAPIProvider)
+	}
+	return &stockHeatmapSource{client: deps.Client, apiKey: cfg.FinanceAPIKey, provider: cfg.FinanceAPIProvider}, nil
+}
+
+func (s *stockHeatmapSource) Name() string { return "stock_heatmap" }
+
+// sectorChange is one tile of the heatmap.
+type sectorChange struct {
+	sectorETF
+	ChangePct float64
+}
+
+func (s *stockHeatmapSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var tiles []sectorChange
+	for _, sec := range sp500Sectors {
+		pct, err := s.changePercent(ctx, sec.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sec.Symbol, err)
+		}
+		tiles = append(tiles, sectorChange{sectorETF: sec, ChangePct: pct})
+	}
+	img, err := renderHeatmap(tiles)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path}, nil
+}
+
+// changePercent returns today's percent change for symbol.
+func (s *stockHeatmapSource) changePercent(ctx context.Context, symbol string) (float64, error) {
+	if s.provider == "alphavantage" {
+		var body struct {
+			Quote map[string]string `json:"Global Quote"`
+		}
+		q := url.Values{"function": {"GLOBAL_QUOTE"}, "symbol": {symbol}, "apikey": {s.apiKey}}
+		if err := s.client.GetJSON(ctx, alphaVantageURL+"?"+q.Encode(), financeMaxBytes, &body); err != nil {
+			return 0, err
+		}
+		raw, ok := body.Quote["10. change percent"]
+		if !ok {
+			return 0, fmt.Errorf("alphavantage returned no quote (rate limited?)")
+		}
+		return strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	}
+	var body struct {
+		DP *float64 `json:"dp"`
+	}
+	q := url.Values{"symbol": {symbol}, "token": {s.apiKey}}
+	if err := s.client.GetJSON(ctx, finnhubQuoteURL+"?"+q.Encode(), financeMaxBytes, &body); err != nil {
+		return 0, err
+	}
+	if body.DP == nil {
+		return 0, fmt.Errorf("finnhub returned no percent change")
+	}
+	return *body.DP, nil
+}
+
+func renderHeatmap(tiles []sectorChange) (*image.RGBA, error) {
+	nameFace, err := imaging.NewFace(30, true)
+	if err != nil {
+		return nil, err
+	}
+	pctFace, err := imaging.NewFace(26, false)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillRect(img, img.Bounds(), color.RGBA{0x10, 0x10, 0x14, 0xff})
+
+	sort.Slice(tiles, func(i, j int) bool { return tiles[i].Weight > tiles[j].Weight })
+	weights := make([]float64, len(tiles))
+	for i, t := range tiles {
+		weights[i] = t.Weight
+	}
+	rects := squarify(weights, image.Rect(0, 0, renderWidth, renderHeight))
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	for i, r := range rects {
+		r = r.Inset(heatmapTilePadding)
+		if r.Empty() {
+			continue
+		}
+		t := tiles[i]
+		imaging.FillRect(img, r, changeColor(t.ChangePct))
+		if r.Dx() < heatmapMinLabelWidth {
+			continue
+		}
+		name := imaging.TruncateText(nameFace, t.Name, r.Dx()-20)
+		pct := fmt.Sprintf("%s %+.2f%%", t.Symbol, t.ChangePct)
+		cy := r.Min.Y + r.Dy()/2
+		imaging.DrawText(img, nameFace, r.Min.X+(r.Dx()-imaging.TextWidth(nameFace, name))/2, cy, name, white)
+		imaging.DrawText(img, pctFace, r.Min.X+(r.Dx()-imaging.TextWidth(pctFace, pct))/2, cy+36, pct, white)
+	}
+	return img, nil
+}
+
+// changeColor maps a percent change to red (down), gray (flat) or green (up).
+func changeColor(pct float64) color.RGBA {
+	t := math.Min(math.Abs(pct)/heatmapFullScalePct, 1)
+	flat := color.RGBA{0x41, 0x45, 0x54, 0xff}
+	target := color.RGBA{0x30, 0xcc, 0x5a, 0xff}
+	if pct < 0 {
+		target = color.RGBA{0xf6, 0x35, 0x38, 0xff}
+	}
+	return imaging.LerpColor(flat, target, t)
+}
+
+// squarify lays out weights (sorted descending) in bounds using the squarified
+// treemap algorithm, returning one rectangle per weight in the same order.
+func squarify(weights []float64, bounds image.Rectangle) []image.Rectangle {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	out := make([]image.Rectangle, len(weights))
+	if total <= 0 {
+		return out
+	}
+	area := float64(bounds.Dx() * bounds.Dy())
+	scaled := make([]float64, len(weights))
+	for i, w := range weights {
+		scaled[i] = w / total * area
+	}
+
+	x, y := float64(bounds.Min.X), float64(bounds.Min.Y)
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	for start := 0; start < len(scaled); {
+		side := math.Min(w, h)
+		end := start + 1
+		for end < len(scaled) && worstRatio(scaled[start:end+1], side) <= worstRatio(scaled[start:end], side) {
+			end++
+		}
+		rowArea := 0.0
+		for _, a := range scaled[start:end] {
+			rowArea += a
+		}
+		thick := rowArea / side
+		pos := 0.0
+		for i := start; i < end; i++ {
+			length := scaled[i] / thick
+			if w >= h { // row is a column on the left
+				out[i] = image.Rect(int(x), int(y+pos), int(x+thick), int(y+pos+length))
+			} else { // row is a strip on top
+				out[i] = image.Rect(int(x+pos), int(y), int(x+pos+length), int(y+thick))
+			}
+			pos += length
+		}
+		if w >= h {
+			x, w = x+thick, w-thick
+		} else {
+			y, h = y+thick, h-thick
+		}
+		start = end
+	}
+	return out
+}
+
+// worstRatio is the largest aspect ratio in a row of areas laid along side.
+func worstRatio(row []float64, side float64) float64 {
+	sum, hi, lo := 0.0, 0.0, math.Inf(1)
+	for _, a := range row {
+		sum += a
+		hi = math.Max(hi, a)
+		lo = math.Min(lo, a)
+	}
+	s2, sum2 := side*side, sum*sum
+	return math.Max(s2*hi/sum2, sum2/(s2*lo))
+}
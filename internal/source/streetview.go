@@ -0,0 +1,118 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"net/url"
+	"os"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	streetViewURL = "https://maps.googleapis.com/maps/api/streetview"
+	// The Static API serves at most 640x640; scale=2 doubles that, which
+	// is as close to 1920x1080 as it gets.
+	streetViewSize  = "640x360"
+	streetViewScale = "2"
+	streetViewFOV   = "90"
+	// streetViewAttempts is how many random points are tried; most land
+	// where there is no imagery.
+	streetViewAttempts = 10
+	// streetViewFlatLuma is the luma standard deviation below which an image
+	// is the flat gray "no imagery" placeholder.
+	streetViewFlatLuma = 4
+)
+
+// streetViewSource looks at a random point of a bounding box through the
+// Google Street View Static API.
+type streetViewSource struct {
+	client  *fetch.Client
+	key     string
+	bbox    config.BBox
+	heading string
+}
+
+func newStreetViewSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.StreetViewAPIKey == "" {
+		return nil, fmt.Errorf("street_view source needs street_view_api_key")
+	}
+	bbox, err := config.ParseBBox(cfg.StreetViewBoundingBox)
+	if err != nil {
+		return nil, err
+	}
+	return &streetViewSource{client: deps.Client, key: cfg.StreetViewAPIKey, bbox: bbox, heading: cfg.StreetViewHeadingMode}, nil
+}
+
+func (s *streetViewSource) Name() string { return "street_view" }
+
+func (s *streetViewSource) Host() string { return hostOf(streetViewURL) }
+
+func (s *streetViewSource) Fetch(ctx context.Context) (*Candidate, error) {
+	for attempt := 0; attempt < streetViewAttempts; attempt++ {
+		lat := s.bbox.South + random.Float64()*(s.bbox.North-s.bbox.South)
+		lon := s.bbox.West + random.Float64()*(s.bbox.East-s.bbox.West)
+		heading := 0
+		switch s.heading {
+		case "south":
+			heading = 180
+		case "random":
+			heading = random.Intn(360)
+		}
+		location := fmt.Sprintf("%.6f,%.6f", lat, lon)
+		q := url.Values{
+			"size":     {streetViewSize},
+			"scale":    {streetViewScale},
+			"location": {location},
+			"fov":      {streetViewFOV},
+			"heading":  {fmt.Sprint(heading)},
+			"key":      {s.key},
+		}
+		path, err := s.client.DownloadToTemp(ctx, streetViewURL+"?"+q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		img, err := imaging.DecodeFile(path)
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		if lumaStdDev(img) < streetViewFlatLuma {
+			os.Remove(path)
+			continue
+		}
+		return &Candidate{
+			Path:      path,
+			SourceURL: fmt.Sprintf("https://www.google.com/maps/@?api=1&map_action=pano&viewpoint=%s&heading=%d", location, heading),
+			Title:     "Street View at " + location,
+		}, nil
+	}
+	return nil, fmt.Errorf("no Street View imagery at %d random points", streetViewAttempts)
+}
+
+// lumaStdDev is the standard deviation of luma, sampling every 4th pixel in
+// each direction.
+func lumaStdDev(img image.Image) float64 {
+	const step = 4
+	b := img.Bounds()
+	var sum, sumSq float64
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			l := imaging.Luma(img.At(x, y))
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	mean := sum / float64(n)
+	return math.Sqrt(math.Max(sumSq/float64(n)-mean*mean, 0))
+}
@@ -0,0 +1,329 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	gnet "github.com/shirou/gopsutil/v3/net"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// sysmonHistoryFile keeps recent samples for the sparklines, and the
+	// last network counters for the rate.
+	sysmonHistoryFile = "sysmon_history.json"
+	// sysmonKeep is six hours of five-minute samples.
+	sysmonKeep      = 72
+	sysmonCPUWindow = time.Second
+	sysmonMargin    = 100
+	sysmonGap       = 60
+	sysmonGaugeR    = 150
+	sysmonGaugeW    = 26
+)
+
+// sysmonSchemes are the "sysmon_color_scheme" palettes: background top
+// and bottom, panel, text, dim text and the accent per metric.
+var sysmonSchemes = map[string]struct {
+	top, bottom, panel, text, dim color.RGBA
+	accent                        map[string]color.RGBA
+}{
+	"dark": {
+		top: rgb(0x0d, 0x11, 0x17), bottom: rgb(0x16, 0x1b, 0x22), panel: rgb(0x1f, 0x26, 0x2e),
+		text: rgb(0xe6, 0xed, 0xf3), dim: rgb(0x8b, 0x94, 0x9e),
+		accent: map[string]color.RGBA{"cpu": rgb(0x58, 0xa6, 0xff), "mem": rgb(0xbc, 0x8c, 0xff), "disk": rgb(0xf0, 0x88, 0x3e), "net": rgb(0x3f, 0xb9, 0x50)},
+	},
+	"matrix": {
+		top: rgb(0x00, 0x08, 0x00), bottom: rgb(0x00, 0x14, 0x04), panel: rgb(0x04, 0x20, 0x0a),
+		text: rgb(0x9c, 0xff, 0xa8), dim: rgb(0x3c, 0x8a, 0x48),
+		accent: map[string]color.RGBA{"cpu": rgb(0x00, 0xff, 0x41), "mem": rgb(0x00, 0xe0, 0x60), "disk": rgb(0x7c, 0xff, 0x00), "net": rgb(0x00, 0xc8, 0x90)},
+	},
+	"solarized": {
+		top: rgb(0x00, 0x2b, 0x36), bottom: rgb(0x07, 0x36, 0x42), panel: rgb(0x0a, 0x40, 0x4e),
+		text: rgb(0xee, 0xe8, 0xd5), dim: rgb(0x93, 0xa1, 0xa1),
+		accent: map[string]color.RGBA{"cpu": rgb(0x26, 0x8b, 0xd2), "mem": rgb(0xd3, 0x36, 0x82), "disk": rgb(0xcb, 0x4b, 0x16), "net": rgb(0x85, 0x99, 0x00)},
+	},
+}
+
+var sysmonLabels = map[string]string{"cpu": "CPU", "mem": "Memory", "disk": "Disk", "net": "Network"}
+
+// sysmonSample is one reading. Percentages are 0-100; NetRate is bytes per
+// second since the previous sample.
+type sysmonSample struct {
+	Time                time.Time `json:"time"`
+	CPU, Mem, Disk      float64
+	NetRate             float64
+	BytesSent, BytesRcv uint64
+}
+
+// sysMonSource renders a dashboard of this machine's CPU, memory, disk and
+// network use. It is redrawn every few minutes while selected; when the
+// readings fail the change fails and history supplies the wallpaper.
+type sysMonSource struct {
+	now         func() time.Time
+	metrics     []string
+	scheme      string
+	historyPath string // "" keeps no history: sparklines show one point
+	diskPath    string
+}
+
+func newSysMonSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	s := &sysMonSource{now: deps.Now, metrics: cfg.SysMonMetrics, scheme: cfg.SysMonColorScheme, diskPath: systemDrive()}
+	if deps.AppDir != "" {
+		s.historyPath = filepath.Join(deps.AppDir, sysmonHistoryFile)
+	}
+	return s, nil
+}
+
+func (s *sysMonSource) Name() string { return "sysmon" }
+
+func (s *sysMonSource) Fetch(ctx context.Context) (*Candidate, error) {
+	history := s.loadHistory()
+	var prev *sysmonSample
+	if len(history) > 0 {
+		prev = &history[len(history)-1]
+	}
+	cur, err := s.sample(ctx, prev)
+	if err != nil {
+		return nil, err
+	}
+	history = append(history, cur)
+	if len(history) > sysmonKeep {
+		history = history[len(history)-sysmonKeep:]
+	}
+	s.saveHistory(history)
+
+	img, err := s.render(history)
+	if err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "System monitor"}, nil
+}
+
+// sample reads the selected metrics. Any one may fail; all failing is an
+// error.
+func (s *sysMonSource) sample(ctx context.Context, prev *sysmonSample) (sysmonSample, error) {
+	cur := sysmonSample{Time: s.now()}
+	var errs []error
+	for _, m := range s.metrics {
+		var err error
+		switch m {
+		case "cpu":
+			var p []float64
+			if p, err = cpu.PercentWithContext(ctx, sysmonCPUWindow, false); err == nil && len(p) > 0 {
+				cur.CPU = p[0]
+			}
+		case "mem":
+			var v *mem.VirtualMemoryStat
+			if v, err = mem.VirtualMemoryWithContext(ctx); err == nil {
+				cur.Mem = v.UsedPercent
+			}
+		case "disk":
+			var u *disk.UsageStat
+			if u, err = disk.UsageWithContext(ctx, s.diskPath); err == nil {
+				cur.Disk = u.UsedPercent
+			}
+		case "net":
+			var io []gnet.IOCountersStat
+			if io, err = gnet.IOCountersWithContext(ctx, false); err == nil && len(io) > 0 {
+				cur.BytesSent, cur.BytesRcv = io[0].BytesSent, io[0].BytesRecv
+				if prev != nil && cur.BytesSent+cur.BytesRcv >= prev.BytesSent+prev.BytesRcv {
+					if secs := cur.Time.Sub(prev.Time).Seconds(); secs > 0 {
+						cur.NetRate = float64(cur.BytesSent+cur.BytesRcv-prev.BytesSent-prev.BytesRcv) / secs
+					}
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m, err))
+		}
+	}
+	if len(errs) == len(s.metrics) {
+		return cur, fmt.Errorf("reading system metrics: %w", errors.Join(errs...))
+	}
+	for _, err := range errs {
+		fmt.Println("sysmon:", err)
+	}
+	return cur, nil
+}
+
+func (s *sysMonSource) render(history []sysmonSample) (*image.RGBA, error) {
+	titleFace, err := imaging.NewFace(40, true)
+	if err != nil {
+		return nil, err
+	}
+	valueFace, err := imaging.NewFace(64, true)
+	if err != nil {
+		return nil, err
+	}
+	labelFace, err := imaging.NewFace(24, false)
+	if err != nil {
+		return nil, err
+	}
+	sch := sysmonSchemes[s.scheme]
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillVerticalGradient(img, sch.top, sch.bottom)
+	cur := history[len(history)-1]
+	imaging.DrawText(img, titleFace, sysmonMargin, sysmonMargin, "System monitor", sch.text)
+	imaging.DrawText(img, labelFace, sysmonMargin, sysmonMargin+40, "Updated "+cur.Time.Format("15:04, Jan 2"), sch.dim)
+
+	n := len(s.metrics)
+	panelW := (renderWidth - 2*sysmonMargin - (n-1)*sysmonGap) / n
+	top := sysmonMargin + 120
+	for i, m := range s.metrics {
+		panel := image.Rect(sysmonMargin+i*(panelW+sysmonGap), top, sysmonMargin+i*(panelW+sysmonGap)+panelW, renderHeight-sysmonMargin)
+		imaging.FillRect(img, panel, sch.panel)
+		accent := sch.accent[m]
+		imaging.DrawText(img, titleFace, panel.Min.X+30, panel.Min.Y+60, sysmonLabels[m], sch.text)
+
+		cx, cy := float64(panel.Min.X+panel.Dx()/2), float64(panel.Min.Y+120+sysmonGaugeR)
+		series := make([]float64, len(history))
+		var value string
+		if m == "net" {
+			for j, h := range history {
+				series[j] = h.NetRate
+			}
+			value = formatRate(cur.NetRate)
+		} else {
+			for j, h := range history {
+				series[j] = metricValue(h, m)
+			}
+			p := metricValue(cur, m)
+			drawGauge(img, cx, cy, 1, sch.top)
+			drawGauge(img, cx, cy, p/100, accent)
+			value = fmt.Sprintf("%.0f%%", p)
+		}
+		imaging.DrawText(img, valueFace, int(cx)-imaging.TextWidth(valueFace, value)/2, int(cy)+22, value, sch.text)
+
+		spark := image.Rect(panel.Min.X+30, int(cy)+sysmonGaugeR+80, panel.Max.X-30, panel.Max.Y-60)
+		drawSparkline(img, spark, series, m != "net", accent)
+		imaging.DrawText(img, labelFace, spark.Min.X, panel.Max.Y-24, fmt.Sprintf("last %d samples", len(history)), sch.dim)
+	}
+	return img, nil
+}
+
+func metricValue(h sysmonSample, metric string) float64 {
+	switch metric {
+	case "cpu":
+		return h.CPU
+	case "mem":
+		return h.Mem
+	case "disk":
+		return h.Disk
+	}
+	return 0
+}
+
+// drawGauge draws a ring arc from 12 o'clock clockwise over frac of the
+// circle.
+func drawGauge(img *image.RGBA, cx, cy, frac float64, c color.RGBA) {
+	frac = min(max(frac, 0), 1)
+	if frac == 0 {
+		return
+	}
+	const steps = 120
+	r := vector.NewRasterizer(renderWidth, renderHeight)
+	r.DrawOp = draw.Over
+	outer, inner := float64(sysmonGaugeR), float64(sysmonGaugeR-sysmonGaugeW)
+	pt := func(rad, t float64) (float32, float32) {
+		a := t * frac * 2 * math.Pi
+		return float32(cx + math.Sin(a)*rad), float32(cy - math.Cos(a)*rad)
+	}
+	r.MoveTo(pt(outer, 0))
+	for i := 1; i <= steps; i++ {
+		r.LineTo(pt(outer, float64(i)/steps))
+	}
+	for i := steps; i >= 0; i-- {
+		r.LineTo(pt(inner, float64(i)/steps))
+	}
+	r.ClosePath()
+	r.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+// drawSparkline plots series left to right in rect, scaled to 0-100 for
+// percentages or to its own maximum otherwise.
+func drawSparkline(img *image.RGBA, rect image.Rectangle, series []float64, percent bool, c color.RGBA) {
+	hi := 100.0
+	if !percent {
+		hi = slices.Max(series)
+	}
+	if hi <= 0 {
+		hi = 1
+	}
+	y := func(v float64) int { return rect.Max.Y - int(v/hi*float64(rect.Dy())) }
+	if len(series) == 1 {
+		drawLine(img, rect.Min.X, y(series[0]), rect.Max.X, y(series[0]), c)
+		return
+	}
+	x := func(i int) int { return rect.Min.X + i*rect.Dx()/(len(series)-1) }
+	for i := 1; i < len(series); i++ {
+		drawLine(img, x(i-1), y(series[i-1]), x(i), y(series[i]), c)
+	}
+}
+
+func formatRate(bps float64) string {
+	switch {
+	case bps >= 1<<20:
+		return fmt.Sprintf("%.1f MB/s", bps/(1<<20))
+	case bps >= 1<<10:
+		return fmt.Sprintf("%.0f KB/s", bps/(1<<10))
+	}
+	return fmt.Sprintf("%.0f B/s", bps)
+}
+
+func (s *sysMonSource) loadHistory() []sysmonSample {
+	if s.historyPath == "" {
+		return nil
+	}
+	b, err := os.ReadFile(s.historyPath)
+	if err != nil {
+		return nil
+	}
+	var h []sysmonSample
+	if err := json.Unmarshal(b, &h); err != nil {
+		fmt.Println("sysmon: discarding unreadable history:", err)
+		return nil
+	}
+	return h
+}
+
+func (s *sysMonSource) saveHistory(h []sysmonSample) {
+	if s.historyPath == "" {
+		return
+	}
+	b, err := json.Marshal(h)
+	if err == nil {
+		err = store.WriteFileAtomic(s.historyPath, b)
+	}
+	if err != nil {
+		fmt.Println("sysmon: failed to save history:", err)
+	}
+}
+
+// systemDrive is the root of the Windows drive, whose usage "disk" shows.
+func systemDrive() string {
+	if d := os.Getenv("SystemDrive"); d != "" {
+		return d + `\`
+	}
+	return `C:\`
+}
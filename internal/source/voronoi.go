@@ -0,0 +1,415 @@
+package source
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	voronoiEdgeWidth = 3.0
+	// voronoiTint is how far each cell's color is lightened at most, so
+	// neighbours sharing a palette color still read as two cells.
+	voronoiTint = 0.18
+)
+
+// voronoiPalettes are the named "voronoi_color_palette" choices.
+var voronoiPalettes = map[string][]color.RGBA{
+	"pastel": {rgb(0xff, 0xd1, 0xdc), rgb(0xff, 0xe5, 0xb4), rgb(0xfd, 0xfd, 0x96), rgb(0xc1, 0xe1, 0xc1),
+		rgb(0xae, 0xc6, 0xcf), rgb(0xcb, 0xaa, 0xcb), rgb(0xb5, 0xea, 0xd7), rgb(0xe2, 0xf0, 0xcb)},
+	"sunset": {rgb(0x35, 0x5c, 0x7d), rgb(0x6c, 0x5b, 0x7b), rgb(0xc0, 0x6c, 0x84), rgb(0xf6, 0x72, 0x80), rgb(0xf8, 0xb1, 0x95)},
+	"ocean":  {rgb(0x03, 0x04, 0x5e), rgb(0x00, 0x77, 0xb6), rgb(0x00, 0xb4, 0xd8), rgb(0x90, 0xe0, 0xef), rgb(0xca, 0xf0, 0xf8)},
+	"forest": {rgb(0x1b, 0x43, 0x32), rgb(0x2d, 0x6a, 0x4f), rgb(0x40, 0x91, 0x6c), rgb(0x52, 0xb7, 0x88), rgb(0x74, 0xc6, 0x9d), rgb(0x95, 0xd5, 0xb2)},
+}
+
+// voronoiSource draws a Voronoi diagram of random seed points offline. In
+// "daily" mode the points are seeded by the date.
+type voronoiSource struct {
+	now     func() time.Time
+	daily   bool
+	cells   int
+	palette []color.RGBA
+	edges   bool
+}
+
+func newVoronoiSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	palette, ok := voronoiPalettes[cfg.VoronoiColorPalette]
+	if !ok {
+		var err error
+		if palette, err = config.ParseColors(cfg.VoronoiColorPalette); err != nil {
+			return nil, err
+		}
+	}
+	return &voronoiSource{now: deps.Now, daily: cfg.GeneratorMode == "daily", cells: cfg.VoronoiNumCells,
+		palette: palette, edges: cfg.VoronoiShowEdges}, nil
+}
+
+func (s *voronoiSource) Name() string { return "voronoi" }
+
+// Deterministic reports whether the same day always draws the same picture.
+func (s *voronoiSource) Deterministic() bool { return s.daily }
+
+func (s *voronoiSource) Fetch(ctx context.Context) (*Candidate, error) {
+	path, err := imaging.WriteTempBMP(s.render(generatorRand(s.daily, s.now())))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "Voronoi"}, nil
+}
+
+func (s *voronoiSource) render(rng *rand.Rand) *image.RGBA {
+	w, h := float64(renderWidth), float64(renderHeight)
+	sites := make([]vpoint, s.cells)
+	for i := range sites {
+		sites[i] = vpoint{rng.Float64() * w, rng.Float64() * h}
+	}
+	edges := fortune(sites, w, h)
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	// Anti-aliased cell borders let the background through a little; a
+	// blend of the palette keeps that from showing as seams.
+	var lum float64
+	mid := s.palette[0]
+	for i, c := range s.palette {
+		lum += imaging.Luma(c) / float64(len(s.palette))
+		mid = imaging.LerpColor(mid, c, 1/float64(i+1))
+	}
+	imaging.FillRect(img, img.Bounds(), mid)
+
+	r := vector.NewRasterizer(renderWidth, renderHeight)
+	fill := func(pts []vpoint, c color.RGBA) {
+		r.Reset(renderWidth, renderHeight)
+		r.DrawOp = draw.Over
+		r.MoveTo(float32(pts[0].x), float32(pts[0].y))
+		for _, p := range pts[1:] {
+			r.LineTo(float32(p.x), float32(p.y))
+		}
+		r.ClosePath()
+		r.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{})
+	}
+	for _, cell := range cellPolygons(sites, edges, w, h) {
+		if len(cell) < 3 {
+			continue
+		}
+		c := s.palette[rng.Intn(len(s.palette))]
+		fill(cell, imaging.LerpColor(c, rgb(0xff, 0xff, 0xff), rng.Float64()*voronoiTint))
+	}
+	if s.edges {
+		edge := rgb(0xf4, 0xf4, 0xf6)
+		if lum > 128 {
+			edge = rgb(0x33, 0x33, 0x3d)
+		}
+		for _, e := range edges {
+			a, b, ok := clipSegment(e.start, e.end, w, h)
+			if !ok {
+				continue
+			}
+			dx, dy := b.x-a.x, b.y-a.y
+			n := math.Hypot(dx, dy)
+			if n == 0 {
+				continue
+			}
+			px, py := -dy/n*voronoiEdgeWidth/2, dx/n*voronoiEdgeWidth/2
+			fill([]vpoint{{a.x + px, a.y + py}, {b.x + px, b.y + py}, {b.x - px, b.y - py}, {a.x - px, a.y - py}}, edge)
+		}
+	}
+	return img
+}
+
+// cellPolygons returns each site's cell clipped to the w x h box, as the
+// vertices of a convex polygon in angular order. A cell's corners are the
+// ends of its edges inside the box, where they cross the box, and the box
+// corners nearest its site.
+func cellPolygons(sites []vpoint, edges []*vedge, w, h float64) [][]vpoint {
+	pts := make([][]vpoint, len(sites))
+	for _, e := range edges {
+		a, b, ok := clipSegment(e.start, e.end, w, h)
+		if !ok {
+			continue
+		}
+		pts[e.left] = append(pts[e.left], a, b)
+		pts[e.right] = append(pts[e.right], a, b)
+	}
+	for _, corner := range []vpoint{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+		nearest := 0
+		for i, s := range sites {
+			if dist2(s, corner) < dist2(sites[nearest], corner) {
+				nearest = i
+			}
+		}
+		pts[nearest] = append(pts[nearest], corner)
+	}
+	for i, cell := range pts {
+		site := sites[i]
+		slices.SortFunc(cell, func(a, b vpoint) int {
+			return cmp.Compare(math.Atan2(a.y-site.y, a.x-site.x), math.Atan2(b.y-site.y, b.x-site.x))
+		})
+		pts[i] = slices.CompactFunc(cell, func(a, b vpoint) bool { return dist2(a, b) < 1e-6 })
+	}
+	return pts
+}
+
+// clipSegment clips a-b to the w x h box (Liang-Barsky).
+func clipSegment(a, b vpoint, w, h float64) (vpoint, vpoint, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.x-a.x, b.y-a.y
+	for _, c := range [4][2]float64{{-dx, a.x}, {dx, w - a.x}, {-dy, a.y}, {dy, h - a.y}} {
+		p, q := c[0], c[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			t0 = max(t0, t)
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			t1 = min(t1, t)
+		}
+	}
+	return vpoint{a.x + t0*dx, a.y + t0*dy}, vpoint{a.x + t1*dx, a.y + t1*dy}, true
+}
+
+func dist2(a, b vpoint) float64 { return (a.x-b.x)*(a.x-b.x) + (a.y-b.y)*(a.y-b.y) }
+
+// Fortune's sweep-line algorithm, sweeping left to right with the beach
+// line kept as a linked list of parabolic arcs. A list rather than a
+// balanced tree is quadratic in the worst case, which is nothing at the
+// few thousand cells allowed.
+
+type vpoint struct{ x, y float64 }
+
+// vedge is a Voronoi edge between sites left and right.
+type vedge struct {
+	start, end  vpoint
+	done        bool
+	left, right int
+}
+
+func (e *vedge) finish(p vpoint) {
+	if !e.done {
+		e.end, e.done = p, true
+	}
+}
+
+type arc struct {
+	p          vpoint
+	site       int
+	prev, next *arc
+	event      *circleEvent
+	s0, s1     *vedge // edges at the arc's left and right ends
+}
+
+// circleEvent is where arc a vanishes: the sweep line reaching x, with p
+// the Voronoi vertex left behind.
+type circleEvent struct {
+	x     float64
+	p     vpoint
+	a     *arc
+	valid bool
+}
+
+type eventQueue []*circleEvent
+
+func (q eventQueue) Len() int           { return len(q) }
+func (q eventQueue) Less(i, j int) bool { return q[i].x < q[j].x }
+func (q eventQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)        { *q = append(*q, x.(*circleEvent)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type sweep struct {
+	sites  []vpoint
+	root   *arc
+	events eventQueue
+	edges  []*vedge
+	bound  float64 // far beyond the box, where open edges are ended
+}
+
+// fortune returns the Voronoi edges of sites. Edges without a vertex at
+// one end run well off the w x h box.
+func fortune(sites []vpoint, w, h float64) []*vedge {
+	s := &sweep{sites: sites, bound: 2 * (2*w + h)}
+	order := make([]int, len(sites))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return cmp.Compare(sites[a].x, sites[b].x) })
+	for len(order) > 0 || len(s.events) > 0 {
+		if len(order) > 0 && (len(s.events) == 0 || sites[order[0]].x <= s.events[0].x) {
+			s.insert(order[0])
+			order = order[1:]
+		} else {
+			s.circle(heap.Pop(&s.events).(*circleEvent))
+		}
+	}
+	for a := s.root; a != nil && a.next != nil; a = a.next {
+		if a.s1 != nil {
+			a.s1.finish(breakpoint(a.p, a.next.p, s.bound))
+		}
+	}
+	return s.edges
+}
+
+func (s *sweep) newEdge(start vpoint, left, right int) *vedge {
+	e := &vedge{start: start, left: left, right: right}
+	s.edges = append(s.edges, e)
+	return e
+}
+
+// insert adds site i's arc to the beach line, splitting the arc above it.
+func (s *sweep) insert(i int) {
+	p := s.sites[i]
+	if s.root == nil {
+		s.root = &arc{p: p, site: i}
+		return
+	}
+	for a := s.root; a != nil; a = a.next {
+		z, ok := s.under(p, a)
+		if !ok {
+			continue
+		}
+		// Split a in two around the new arc.
+		dup := &arc{p: a.p, site: a.site, prev: a, next: a.next}
+		if a.next != nil {
+			a.next.prev = dup
+		}
+		a.next = dup
+		dup.s1 = a.s1
+		n := &arc{p: p, site: i, prev: a, next: a.next}
+		a.next.prev = n
+		a.next = n
+		n.prev.s1 = s.newEdge(z, a.site, i)
+		n.s0 = n.prev.s1
+		n.next.s0 = s.newEdge(z, i, a.site)
+		n.s1 = n.next.s0
+		s.check(n, p.x)
+		s.check(n.prev, p.x)
+		s.check(n.next, p.x)
+		return
+	}
+	// p lies level with no arc: sites sharing the first x. Append it.
+	last := s.root
+	for last.next != nil {
+		last = last.next
+	}
+	last.next = &arc{p: p, site: i, prev: last}
+	start := vpoint{-s.bound, (p.y + last.p.y) / 2}
+	last.s1 = s.newEdge(start, last.site, i)
+	last.next.s0 = last.s1
+}
+
+// circle removes the arc an event is for and starts the edge between its
+// neighbours at the vertex.
+func (s *sweep) circle(e *circleEvent) {
+	if !e.valid {
+		return
+	}
+	a := e.a
+	edge := s.newEdge(e.p, a.prev.site, a.next.site)
+	a.prev.next = a.next
+	a.prev.s1 = edge
+	a.next.prev = a.prev
+	a.next.s0 = edge
+	if a.s0 != nil {
+		a.s0.finish(e.p)
+	}
+	if a.s1 != nil {
+		a.s1.finish(e.p)
+	}
+	s.check(a.prev, e.x)
+	s.check(a.next, e.x)
+}
+
+// check queues the event for arc a vanishing, if its neighbours converge
+// past the sweep line x0, replacing any older event.
+func (s *sweep) check(a *arc, x0 float64) {
+	if a.event != nil && a.event.x != x0 {
+		a.event.valid = false
+	}
+	a.event = nil
+	if a.prev == nil || a.next == nil {
+		return
+	}
+	if x, o, ok := circumcircle(a.prev.p, a.p, a.next.p); ok && x > x0 {
+		a.event = &circleEvent{x: x, p: o, a: a, valid: true}
+		heap.Push(&s.events, a.event)
+	}
+}
+
+// circumcircle returns the center o of the circle through a, b and c and
+// its rightmost x, if b-c turns right from a-b.
+func circumcircle(a, b, c vpoint) (x float64, o vpoint, ok bool) {
+	if (b.x-a.x)*(c.y-a.y)-(c.x-a.x)*(b.y-a.y) > 0 {
+		return 0, o, false
+	}
+	A, B := b.x-a.x, b.y-a.y
+	C, D := c.x-a.x, c.y-a.y
+	E, F := A*(a.x+b.x)+B*(a.y+b.y), C*(a.x+c.x)+D*(a.y+c.y)
+	G := 2 * (A*(c.y-b.y) - B*(c.x-b.x))
+	if G == 0 { // collinear
+		return 0, o, false
+	}
+	o = vpoint{(D*E - B*F) / G, (A*F - C*E) / G}
+	return o.x + math.Sqrt(dist2(a, o)), o, true
+}
+
+// under reports whether the new site p lies under arc a, and where on a.
+func (s *sweep) under(p vpoint, a *arc) (vpoint, bool) {
+	if a.p.x == p.x {
+		return vpoint{}, false
+	}
+	if a.prev != nil && breakpoint(a.prev.p, a.p, p.x).y > p.y {
+		return vpoint{}, false
+	}
+	if a.next != nil && breakpoint(a.p, a.next.p, p.x).y < p.y {
+		return vpoint{}, false
+	}
+	return vpoint{(a.p.x*a.p.x + (a.p.y-p.y)*(a.p.y-p.y) - p.x*p.x) / (2*a.p.x - 2*p.x), p.y}, true
+}
+
+// breakpoint is where the arcs of p0 and p1 meet with the sweep line at l.
+func breakpoint(p0, p1 vpoint, l float64) vpoint {
+	var res vpoint
+	p := p0
+	switch {
+	case p0.x == p1.x:
+		res.y = (p0.y + p1.y) / 2
+	case p1.x == l:
+		res.y = p1.y
+	case p0.x == l:
+		res.y = p0.y
+		p = p1
+	default:
+		z0, z1 := 2*(p0.x-l), 2*(p1.x-l)
+		a := 1/z0 - 1/z1
+		b := -2 * (p0.y/z0 - p1.y/z1)
+		c := (p0.y*p0.y+p0.x*p0.x-l*l)/z0 - (p1.y*p1.y+p1.x*p1.x-l*l)/z1
+		res.y = (-b - math.Sqrt(b*b-4*a*c)) / (2 * a)
+	}
+	res.x = (p.x*p.x + (p.y-res.y)*(p.y-res.y) - l*l) / (2*p.x - 2*l)
+	return res
+}
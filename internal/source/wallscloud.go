@@ -0,0 +1,199 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+)
+
+const (
+	siteURL       = "https://wallscloud.net/ru/wallpapers/random"
+	xpathSelector = "//*[@id=\"main\"]/div[4]/div[2]/figure[1]/div/a"
+	// imageSizeFormat is appended to the wallpaper page URL to download it at a size.
+	imageSizeFormat = "/%dx%d/download"
+	// defaultDownloadPath downloads the wallpaper at whatever size the site
+	// picks, the last resort when no listed size exists.
+	defaultDownloadPath = "/download"
+	// categoriesURL is a page whose navigation menu links every category
+	// as .../category/<slug>.
+	categoriesURL   = "https://wallscloud.net/ru/wallpapers"
+	categoriesXPath = "//nav//a[contains(@href, '/category/')]"
+)
+
+// defaultImageSize is downloaded when the screen size is unknown.
+var defaultImageSize = image.Point{X: 1600, Y: 900}
+
+// publishedImageSizes are the sizes wallscloud commonly publishes a
+// wallpaper at, landscape, ultrawide and portrait; not every wallpaper has
+// every size. wallscloud doesn't list a page's sizes, so this is a static
+// table that sizeLadder picks from.
+var publishedImageSizes = []image.Point{
+	{X: 3840, Y: 2160}, {X: 2560, Y: 1440}, {X: 1920, Y: 1080}, {X: 1600, Y: 900}, {X: 1366, Y: 768},
+	{X: 2560, Y: 1600}, {X: 1920, Y: 1200}, {X: 1680, Y: 1050},
+	{X: 3440, Y: 1440}, {X: 2560, Y: 1080}, {X: 5120, Y: 1440}, {X: 3840, Y: 1080},
+	{X: 2160, Y: 3840}, {X: 1440, Y: 2560}, {X: 1080, Y: 1920},
+}
+
+// wallscloudSource scrapes a random wallpaper from wallscloud.net.
+type wallscloudSource struct {
+	client      *fetch.Client
+	maxHTMLBody int64
+	size        image.Point
+}
+
+func newWallscloudSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	size := deps.Screen
+	if size.X <= 0 || size.Y <= 0 {
+		size = defaultImageSize
+	}
+	return &wallscloudSource{client: deps.Client, maxHTMLBody: cfg.MaxHTMLBodyBytes, size: size}, nil
+}
+
+func (s *wallscloudSource) Name() string { return "wallscloud" }
+
+func (s *wallscloudSource) Host() string { return hostOf(siteURL) }
+
+func (s *wallscloudSource) Fetch(ctx context.Context) (*Candidate, error) {
+	href, title, err := s.fetchRandomWallpaperHref(ctx, siteURL, xpathSelector)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(href, "http") {
+		href = strings.TrimRight(siteURL, "/") + "/" + strings.TrimLeft(href, "/")
+	}
+	tmpFile, size, err := s.download(ctx, strings.TrimRight(href, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: tmpFile, SourceURL: href, Title: title, Tags: slugWords(href), Size: size}, nil
+}
+
+// Refetch downloads the wallpaper on page again at the current screen size.
+func (s *wallscloudSource) Refetch(ctx context.Context, page string) (*Candidate, error) {
+	if err := s.client.Check(ctx, page); err != nil {
+		return nil, err
+	}
+	tmpFile, size, err := s.download(ctx, strings.TrimRight(page, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: tmpFile, SourceURL: page, Size: size}, nil
+}
+
+// download fetches the wallpaper at the screen size, walking down
+// sizeLadder of the published sizes on 404 and finally taking the default
+// download. size names the
+// substitute that was used, or is "" when the screen size was available.
+func (s *wallscloudSource) download(ctx context.Context, page string) (path, size string, err error) {
+	for _, sz := range sizeLadder(s.size, publishedImageSizes) {
+		path, err = s.client.DownloadToTemp(ctx, page+fmt.Sprintf(imageSizeFormat, sz.X, sz.Y))
+		if err == nil {
+			if sz != s.size {
+				size = fmt.Sprintf("%dx%d", sz.X, sz.Y)
+				fmt.Printf("wallscloud: %dx%d not available, downloaded %s instead\n", s.size.X, s.size.Y, size)
+			}
+			return path, size, nil
+		}
+		if !errors.Is(err, fetch.ErrNotFound) {
+			return "", "", err
+		}
+	}
+	path, err = s.client.DownloadToTemp(ctx, page+defaultDownloadPath)
+	if err != nil {
+		return "", "", err
+	}
+	fmt.Printf("wallscloud: no listed size available, downloaded the default size instead of %dx%d\n", s.size.X, s.size.Y)
+	return path, "default", nil
+}
+
+// slugWords splits the last path segment of a wallpaper URL, which
+// wallscloud builds from the image's title, into words.
+func slugWords(href string) []string {
+	slug := strings.TrimRight(href, "/")
+	slug = slug[strings.LastIndex(slug, "/")+1:]
+	return strings.FieldsFunc(slug, func(r rune) bool { return r == '-' || r == '_' })
+}
+
+// fetchRandomWallpaperHref returns the wallpaper link and its title, if the
+// page gives one.
+func (s *wallscloudSource) fetchRandomWallpaperHref(ctx context.Context, url, xpath string) (href, title string, err error) {
+	resp, err := s.client.Get(ctx, url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	// Never read more than MaxHTMLBodyBytes; a truncated page still parses.
+	doc, err := htmlquery.Parse(io.LimitReader(resp.Body, s.maxHTMLBody))
+	if err != nil {
+		return "", "", err
+	}
+	if htmlquery.FindOne(doc, "//figure") == nil {
+		return "", "", errors.New("page has no figure elements (truncated or layout changed)")
+	}
+	n := htmlquery.FindOne(doc, xpath)
+	if n == nil {
+		return "", "", errors.New("xpath didn't return node")
+	}
+	href = htmlquery.SelectAttr(n, "href")
+	if href == "" {
+		href = htmlquery.SelectAttr(n, "data-href")
+	}
+	title = htmlquery.SelectAttr(n, "title")
+	if img := htmlquery.FindOne(n, ".//img"); title == "" && img != nil {
+		title = htmlquery.SelectAttr(img, "alt")
+	}
+	return href, title, nil
+}
+
+// Categories lists the categories linked from the site's navigation menu.
+func (s *wallscloudSource) Categories(ctx context.Context) ([]Category, error) {
+	resp, err := s.client.Get(ctx, categoriesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	cats, err := parseCategories(io.LimitReader(resp.Body, s.maxHTMLBody))
+	if err != nil {
+		return nil, err
+	}
+	if len(cats) == 0 {
+		return nil, errors.New("no category links in the navigation menu (layout changed?)")
+	}
+	return cats, nil
+}
+
+// parseCategories reads the category links of a wallscloud page, once each
+// and in menu order.
+func parseCategories(page io.Reader) ([]Category, error) {
+	doc, err := htmlquery.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	var cats []Category
+	seen := map[string]bool{}
+	for _, a := range htmlquery.Find(doc, categoriesXPath) {
+		href := strings.TrimRight(htmlquery.SelectAttr(a, "href"), "/")
+		_, slug, _ := strings.Cut(href, "/category/")
+		if i := strings.IndexAny(slug, "/?#"); i >= 0 {
+			slug = slug[:i] // a page of the category, or a sort order
+		}
+		name := strings.TrimSpace(htmlquery.InnerText(a))
+		if slug == "" || seen[slug] {
+			continue
+		}
+		if name == "" {
+			name = slug
+		}
+		seen[slug] = true
+		cats = append(cats, Category{Slug: slug, Name: name})
+	}
+	return cats, nil
+}
@@ -0,0 +1,192 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"wallpaper-changer/internal/fetch"
+)
+
+// sizedServer serves a wallpaper page's downloads: 200 for the paths in
+// found, status for any other. It records the paths asked for.
+func sizedServer(t *testing.T, status int, found ...string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var asked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		asked = append(asked, r.URL.Path)
+		mu.Unlock()
+		if !slices.Contains(found, r.URL.Path) {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("image bytes"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), asked...)
+	}
+}
+
+func sizePath(p image.Point) string { return "/w/sunset" + fmt.Sprintf(imageSizeFormat, p.X, p.Y) }
+
+func TestWallscloudDownloadLadder(t *testing.T) {
+	screen := image.Pt(1920, 1080)
+	ladder := sizeLadder(screen, publishedImageSizes)
+	var all []string
+	for _, sz := range ladder {
+		all = append(all, sizePath(sz))
+	}
+	tests := []struct {
+		name     string
+		status   int
+		found    []string
+		wantSize string
+		wantErr  error
+		asked    []string
+	}{
+		{"native size", http.StatusNotFound, all[:1], "", nil, all[:1]},
+		{"first two 404", http.StatusNotFound, all[2:3], fmt.Sprintf("%dx%d", ladder[2].X, ladder[2].Y), nil, all[:3]},
+		{"native 404, a smaller size 200", http.StatusNotFound, []string{sizePath(image.Pt(1600, 900))}, "1600x900", nil, all},
+		{"only the default", http.StatusNotFound, []string{"/w/sunset" + defaultDownloadPath}, "default", nil,
+			append(slices.Clone(all), "/w/sunset"+defaultDownloadPath)},
+		{"nothing", http.StatusNotFound, nil, "", fetch.ErrNotFound,
+			append(slices.Clone(all), "/w/sunset"+defaultDownloadPath)},
+		{"rate limited stops", http.StatusTooManyRequests, all[2:3], "", fetch.ErrRateLimited, all[:1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, asked := sizedServer(t, tt.status, tt.found...)
+			s := &wallscloudSource{client: fetch.New(srv.Client(), "test"), size: screen}
+			path, size, err := s.download(context.Background(), srv.URL+"/w/sunset")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("download = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("download: %v", err)
+			} else {
+				os.Remove(path)
+			}
+			if size != tt.wantSize {
+				t.Errorf("size = %q, want %q", size, tt.wantSize)
+			}
+			if got := asked(); !slices.Equal(got, tt.asked) {
+				t.Errorf("asked for\n%v\nwant\n%v", got, tt.asked)
+			}
+		})
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		file string
+		want []Category
+	}{
+		{"wallscloud_nav.html", []Category{
+			{"nature", "Природа"},
+			{"space", "Космос"},
+			{"cities", "Города"},
+			{"cars", "Машины"},
+			{"abstract", "abstract"},
+			{"games", "Игры & приставки"},
+			{"minimalism", "Минимализм"},
+		}},
+		{"wallscloud_nav_changed.html", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := parseCategories(f)
+			if err != nil {
+				t.Fatalf("parseCategories: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseCategories =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fixtureTransport answers every request with the testdata file page and
+// records the URLs asked for.
+type fixtureTransport struct {
+	page  string
+	asked []string
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.asked = append(f.asked, req.URL.String())
+	b, err := os.ReadFile(filepath.Join("testdata", f.page))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(string(b))),
+		Request:    req,
+	}, nil
+}
+
+func TestWallscloudCategories(t *testing.T) {
+	tests := []struct {
+		page    string
+		want    int
+		wantErr string
+	}{
+		{"wallscloud_nav.html", 7, ""},
+		{"wallscloud_nav_changed.html", 0, "layout changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			tr := &fixtureTransport{page: tt.page}
+			s := &wallscloudSource{client: fetch.New(&http.Client{Transport: tr}, "test"), maxHTMLBody: 1 << 20}
+			got, err := s.Categories(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Categories = %v, %v; want an error mentioning %q", got, err, tt.wantErr)
+				}
+			} else if err != nil || len(got) != tt.want {
+				t.Fatalf("Categories = %d categories, %v; want %d", len(got), err, tt.want)
+			}
+			if len(tr.asked) != 1 || tr.asked[0] != categoriesURL {
+				t.Errorf("asked for %v, want only %s", tr.asked, categoriesURL)
+			}
+		})
+	}
+}
+
+// TestParseCategoriesTruncated checks that a page cut off by maxHTMLBody
+// still yields the links before the cut.
+func TestParseCategoriesTruncated(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "wallscloud_nav.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := strings.Index(string(b), "category/cities")
+	got, err := parseCategories(strings.NewReader(string(b[:cut])))
+	if err != nil {
+		t.Fatalf("parseCategories: %v", err)
+	}
+	if want := []Category{{"nature", "Природа"}, {"space", "Космос"}}; !slices.Equal(got, want) {
+		t.Errorf("parseCategories = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	stamenWatercolorURL = "https://stamen-tiles-%s.a.ssl.fastly.net/watercolor/%d/%d/%d.jpg"
+	mapTileSize         = 256
+	// watercolorTileDir caches the tiles inside the app dir; the map rarely
+	// changes, so they are kept for watercolorTileMaxAge.
+	watercolorTileDir    = "cache/tiles"
+	watercolorTileMaxAge = 30 * 24 * time.Hour
+)
+
+// watercolorMapSource composes Stamen watercolor tiles into a painted map
+// around a fixed point.
+type watercolorMapSource struct {
+	client   *fetch.Client
+	now      func() time.Time
+	lat, lon float64
+	zoom     int
+	cacheDir string // "" when there is no app dir to keep tiles in
+}
+
+func newWatercolorMapSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	s := &watercolorMapSource{client: deps.Client, now: deps.Now,
+		lat: cfg.WatercolorCenterLat, lon: cfg.WatercolorCenterLon, zoom: cfg.WatercolorZoom}
+	if deps.AppDir != "" {
+		s.cacheDir = filepath.Join(deps.AppDir, filepath.FromSlash(watercolorTileDir))
+	}
+	return s, nil
+}
+
+func (s *watercolorMapSource) Name() string { return "watercolor_map" }
+
+func (s *watercolorMapSource) Fetch(ctx context.Context) (*Candidate, error) {
+	s.pruneCache()
+	n := 1 << s.zoom
+	world := n * mapTileSize
+	// Web Mercator pixel position of the centre.
+	latRad := s.lat * math.Pi / 180
+	cx := int((s.lon + 180) / 360 * float64(world))
+	cy := int((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * float64(world))
+	x0 := cx - renderWidth/2
+	// Keep the picture inside the map vertically; horizontally it wraps.
+	y0 := min(max(cy-renderHeight/2, 0), world-renderHeight)
+
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	for ty := y0 / mapTileSize; ty*mapTileSize < y0+renderHeight; ty++ {
+		for tx := floorDiv(x0, mapTileSize); tx*mapTileSize < x0+renderWidth; tx++ {
+			tile, err := s.tile(ctx, ((tx%n)+n)%n, ty)
+			if err != nil {
+				return nil, err
+			}
+			at := image.Pt(tx*mapTileSize-x0, ty*mapTileSize-y0)
+			draw.Draw(img, image.Rectangle{Min: at, Max: at.Add(image.Pt(mapTileSize, mapTileSize))}, tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+	out, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:      out,
+		SourceURL: fmt.Sprintf("https://maps.stamen.com/watercolor/#%d/%s/%s", s.zoom, formatCoord(s.lat), formatCoord(s.lon)),
+		Title:     fmt.Sprintf("Watercolor map at %s, %s", formatCoord(s.lat), formatCoord(s.lon)),
+		Author:    "Stamen Design, OpenStreetMap contributors",
+	}, nil
+}
+
+// tile returns tile x, y at the source's zoom, from the cache when it is
+// fresh enough.
+func (s *watercolorMapSource) tile(ctx context.Context, x, y int) (image.Image, error) {
+	var cached string
+	if s.cacheDir != "" {
+		cached = filepath.Join(s.cacheDir, fmt.Sprintf("watercolor_%d_%d_%d.jpg", s.zoom, x, y))
+		if fi, err := os.Stat(cached); err == nil && s.now().Sub(fi.ModTime()) < watercolorTileMaxAge {
+			if img, err := imaging.DecodeFile(cached); err == nil {
+				return img, nil
+			}
+		}
+	}
+	// Spread the requests over the a, b and c tile servers.
+	path, err := s.client.DownloadToTemp(ctx, fmt.Sprintf(stamenWatercolorURL, string(rune('a'+(x+y)%3)), s.zoom, x, y))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	img, err := imaging.DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached != "" {
+		b, err := os.ReadFile(path)
+		if err == nil {
+			err = os.MkdirAll(s.cacheDir, 0o755)
+		}
+		if err == nil {
+			err = store.WriteFileAtomic(cached, b)
+		}
+		if err != nil {
+			fmt.Println("watercolor_map: failed to cache tile:", err)
+		}
+	}
+	return img, nil
+}
+
+// pruneCache deletes tiles past watercolorTileMaxAge, such as those of a
+// place or zoom no longer configured.
+func (s *watercolorMapSource) pruneCache() {
+	if s.cacheDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if fi, err := e.Info(); err == nil && !e.IsDir() && s.now().Sub(fi.ModTime()) >= watercolorTileMaxAge {
+			os.Remove(filepath.Join(s.cacheDir, e.Name()))
+		}
+	}
+}
+
+// floorDiv divides rounding towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/store"
+)
+
+const (
+	// webcamHashFile remembers the last snapshot's SHA-256 across changes.
+	webcamHashFile   = "webcam_snapshot.sha256"
+	webcamRetryDelay = 30 * time.Second
+	// webcamStaleAfter is how long an unchanged snapshot is retried before
+	// the camera counts as frozen.
+	webcamStaleAfter = 5 * time.Minute
+)
+
+// webcamSource takes the current snapshot of a webcam that publishes one at
+// a static URL, skipping snapshots identical to the last one set.
+type webcamSource struct {
+	client             *fetch.Client
+	url                string
+	username, password string
+	hashPath           string // "" when there is no app dir to keep it in
+}
+
+func newWebcamSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	if cfg.WebcamURL == "" {
+		return nil, fmt.Errorf("webcam source needs webcam_url")
+	}
+	s := &webcamSource{client: deps.Client, url: cfg.WebcamURL, username: cfg.WebcamUsername, password: cfg.WebcamPassword}
+	if deps.AppDir != "" {
+		s.hashPath = filepath.Join(deps.AppDir, webcamHashFile)
+	}
+	return s, nil
+}
+
+func (s *webcamSource) Name() string { return "webcam" }
+
+func (s *webcamSource) Host() string { return hostOf(s.url) }
+
+func (s *webcamSource) Fetch(ctx context.Context) (*Candidate, error) {
+	last, _ := os.ReadFile(s.hashPath)
+	deadline := time.Now().Add(webcamStaleAfter)
+	for {
+		path, sum, err := s.snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(sum, last) {
+			if s.hashPath != "" {
+				if err := store.WriteFileAtomic(s.hashPath, sum); err != nil {
+					fmt.Println("webcam: failed to save snapshot hash:", err)
+				}
+			}
+			return &Candidate{Path: path, SourceURL: s.url, Title: "Webcam snapshot"}, nil
+		}
+		os.Remove(path)
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("webcam snapshot unchanged for %s, the camera looks frozen", webcamStaleAfter)
+		}
+		fmt.Println("webcam: snapshot hasn't changed since the last one, retrying")
+		select {
+		case <-time.After(webcamRetryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// snapshot downloads the current image and returns its path and SHA-256.
+func (s *webcamSource) snapshot(ctx context.Context) (path string, sum []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("webcam: bad status: %s", resp.Status)
+	}
+	h := sha256.New()
+	resp.Body = io.NopCloser(io.TeeReader(resp.Body, h))
+	if path, err = fetch.SaveToTemp(resp); err != nil {
+		return "", nil, err
+	}
+	return path, h.Sum(nil), nil
+}
@@ -0,0 +1,212 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const (
+	wikipediaFeaturedFeedURL = "https://%s.wikipedia.org/api/rest_v1/feed/featured/%s"
+	commonsAPIURL            = "https://commons.wikimedia.org/w/api.php"
+	// The caption's margin, padding and font sizes are fractions of the
+	// picture height, so it looks the same at any resolution.
+	potdOverlayMargin  = 0.03
+	potdOverlayPadding = 0.012
+	potdTitleSize      = 0.024
+	potdCreditSize     = 0.017
+	// potdTypicalAspect is the 3:2 of most pictures of the day, landscape
+	// photographs, which the rendering is sized to cover the screen with.
+	potdTypicalAspect = 1.5
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// wikimediaPotdSource sets Wikimedia Commons' picture of the day, found
+// through Wikipedia's featured feed, optionally with its title and credit
+// printed in a corner.
+type wikimediaPotdSource struct {
+	client   *fetch.Client
+	now      func() time.Time
+	language string
+	size     image.Point
+	overlay  string // corner, "" for none
+}
+
+func newWikimediaPotdSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	size := deps.Screen
+	if size.X <= 0 || size.Y <= 0 {
+		size = image.Pt(renderWidth, renderHeight)
+	}
+	s := &wikimediaPotdSource{client: deps.Client, now: deps.Now, language: cfg.WikipediaLanguage, size: size}
+	if cfg.WikimediaPotdOverlay {
+		s.overlay = cfg.WikimediaPotdOverlayPosition
+	}
+	return s, nil
+}
+
+func (s *wikimediaPotdSource) Name() string { return "wikimedia_potd" }
+
+func (s *wikimediaPotdSource) Host() string { return s.language + ".wikipedia.org" }
+
+// commonsImageInfo is the imageinfo of a Commons file; the extmetadata
+// values are HTML.
+type commonsImageInfo struct {
+	ThumbURL       string `json:"thumburl"`
+	URL            string `json:"url"`
+	DescriptionURL string `json:"descriptionurl"`
+	ExtMetadata    map[string]struct {
+		Value string `json:"value"`
+	} `json:"extmetadata"`
+}
+
+func (s *wikimediaPotdSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var feed struct {
+		Image *struct {
+			Title string `json:"title"`
+		} `json:"image"`
+	}
+	date := s.now().UTC().Format("2006/01/02")
+	if err := s.client.GetJSON(ctx, fmt.Sprintf(wikipediaFeaturedFeedURL, s.language, date), wikipediaMaxBytes, &feed); err != nil {
+		return nil, err
+	}
+	if feed.Image == nil || feed.Image.Title == "" {
+		return nil, fmt.Errorf("no picture of the day for %s yet", date)
+	}
+	info, err := s.imageInfo(ctx, feed.Image.Title)
+	if err != nil {
+		return nil, err
+	}
+	title := metadataText(info, "ObjectName")
+	if title == "" {
+		title = strings.TrimSuffix(strings.TrimPrefix(feed.Image.Title, "File:"), path.Ext(feed.Image.Title))
+	}
+	artist := metadataText(info, "Artist")
+
+	imgURL := info.ThumbURL
+	if imgURL == "" {
+		imgURL = info.URL
+	}
+	dl, err := s.client.DownloadToTemp(ctx, imgURL)
+	if err != nil {
+		return nil, err
+	}
+	c := &Candidate{Path: dl, SourceURL: info.DescriptionURL, Title: title, Author: artist, Category: "picture of the day"}
+	if s.overlay == "" {
+		return c, nil
+	}
+	img, err := imaging.DecodeFile(dl)
+	os.Remove(dl)
+	if err != nil {
+		return nil, err
+	}
+	credit := artist
+	if license := metadataText(info, "LicenseShortName"); license != "" {
+		if credit != "" {
+			credit += " · "
+		}
+		credit += license
+	}
+	out := imaging.CropToAspect(img, s.size)
+	if err := drawCaption(out, s.overlay, title, credit); err != nil {
+		return nil, err
+	}
+	if c.Path, err = imaging.WriteTempBMP(out); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// imageInfo asks Commons for file's metadata and a rendering wide enough
+// to cover the screen.
+func (s *wikimediaPotdSource) imageInfo(ctx context.Context, file string) (*commonsImageInfo, error) {
+	q := url.Values{
+		"action":        {"query"},
+		"prop":          {"imageinfo"},
+		"iiprop":        {"url|extmetadata"},
+		"iiurlwidth":    {fmt.Sprint(coverWidth(s.size, potdTypicalAspect))},
+		"titles":        {file},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	var body struct {
+		Query struct {
+			Pages []struct {
+				ImageInfo []commonsImageInfo `json:"imageinfo"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	if err := s.client.GetJSON(ctx, commonsAPIURL+"?"+q.Encode(), wikipediaMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Query.Pages) == 0 || len(body.Query.Pages[0].ImageInfo) == 0 {
+		return nil, errors.New("no image info for " + file + " on Commons")
+	}
+	return &body.Query.Pages[0].ImageInfo[0], nil
+}
+
+// metadataText returns the extmetadata field name as plain text.
+func metadataText(info *commonsImageInfo, name string) string {
+	s := html.UnescapeString(htmlTag.ReplaceAllString(info.ExtMetadata[name].Value, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// drawCaption prints title over credit on a translucent dark block in
+// corner of img, one of config.OverlayPositions.
+func drawCaption(img *image.RGBA, corner, title, credit string) error {
+	h := float64(img.Bounds().Dy())
+	titleFace, err := imaging.NewFace(h*potdTitleSize, true)
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	creditFace, err := imaging.NewFace(h*potdCreditSize, false)
+	if err != nil {
+		return err
+	}
+	defer creditFace.Close()
+
+	margin, pad := int(h*potdOverlayMargin), int(h*potdOverlayPadding)
+	maxText := img.Bounds().Dx()/2 - 2*pad
+	title = imaging.TruncateText(titleFace, title, maxText)
+	credit = imaging.TruncateText(creditFace, credit, maxText)
+	titleH := titleFace.Metrics().Height.Ceil()
+	creditH := 0
+	if credit != "" {
+		creditH = creditFace.Metrics().Height.Ceil()
+	}
+	w := max(imaging.TextWidth(titleFace, title), imaging.TextWidth(creditFace, credit)) + 2*pad
+	bh := titleH + creditH + 2*pad
+
+	b := img.Bounds()
+	x, y := b.Min.X+margin, b.Max.Y-margin-bh
+	if strings.HasSuffix(corner, "right") {
+		x = b.Max.X - margin - w
+	}
+	if strings.HasPrefix(corner, "top") {
+		y = b.Min.Y + margin
+	}
+	box := image.Rect(x, y, x+w, y+bh)
+	draw.Draw(img, box, image.NewUniform(color.RGBA{A: 0xa0}), image.Point{}, draw.Over)
+	asc := titleFace.Metrics().Ascent.Ceil()
+	imaging.DrawText(img, titleFace, x+pad, y+pad+asc, title, color.White)
+	if credit != "" {
+		imaging.DrawText(img, creditFace, x+pad, y+pad+titleH+creditFace.Metrics().Ascent.Ceil(), credit, color.RGBA{0xdd, 0xdd, 0xdd, 0xff})
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+)
+
+const (
+	wikipediaRandomSummaryURL = "https://%s.wikipedia.org/api/rest_v1/page/summary/Special:Random"
+	wikipediaActionURL        = "https://%s.wikipedia.org/w/api.php"
+	wikipediaMaxBytes         = 1 << 20
+	wikipediaMinWidth         = 800
+	wikipediaMinHeight        = 600
+	// wikipediaAttempts is how many random articles are tried before giving
+	// up; many have no lead image at all.
+	wikipediaAttempts = 10
+)
+
+// wikipediaFeaturedSource uses the lead image of a random Wikipedia article.
+type wikipediaFeaturedSource struct {
+	client   *fetch.Client
+	language string
+}
+
+func newWikipediaFeaturedSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return &wikipediaFeaturedSource{client: deps.Client, language: cfg.WikipediaLanguage}, nil
+}
+
+func (s *wikipediaFeaturedSource) Name() string { return "wikipedia_featured" }
+
+func (s *wikipediaFeaturedSource) Host() string { return s.language + ".wikipedia.org" }
+
+// wikiImage is an image reference in the REST and action API responses.
+type wikiImage struct {
+	Source string `json:"source"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (i *wikiImage) bigEnough() bool {
+	return i != nil && i.Width >= wikipediaMinWidth && i.Height >= wikipediaMinHeight
+}
+
+func (s *wikipediaFeaturedSource) Fetch(ctx context.Context) (*Candidate, error) {
+	for attempt := 0; attempt < wikipediaAttempts; attempt++ {
+		var page struct {
+			Title       string     `json:"title"`
+			Description string     `json:"description"`
+			Thumbnail   *wikiImage `json:"thumbnail"`
+			ContentURLs struct {
+				Desktop struct {
+					Page string `json:"page"`
+				} `json:"desktop"`
+			} `json:"content_urls"`
+		}
+		if err := s.client.GetJSON(ctx, fmt.Sprintf(wikipediaRandomSummaryURL, s.language), wikipediaMaxBytes, &page); err != nil {
+			return nil, err
+		}
+		if page.Thumbnail == nil {
+			continue
+		}
+		img := page.Thumbnail
+		if !img.bigEnough() {
+			orig, err := s.originalImage(ctx, page.Title)
+			if err != nil {
+				return nil, err
+			}
+			if !orig.bigEnough() {
+				continue
+			}
+			img = orig
+		}
+		path, err := s.client.DownloadToTemp(ctx, img.Source)
+		if err != nil {
+			return nil, err
+		}
+		return &Candidate{
+			Path:      path,
+			SourceURL: page.ContentURLs.Desktop.Page,
+			Title:     page.Title,
+			Category:  page.Description,
+		}, nil
+	}
+	return nil, fmt.Errorf("no article with an image of at least %dx%d in %d tries",
+		wikipediaMinWidth, wikipediaMinHeight, wikipediaAttempts)
+}
+
+// originalImage asks the action API for the full-size lead image of title;
+// nil means the article has none.
+func (s *wikipediaFeaturedSource) originalImage(ctx context.Context, title string) (*wikiImage, error) {
+	var body struct {
+		Query struct {
+			Pages []struct {
+				Original *wikiImage `json:"original"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	q := url.Values{
+		"action":        {"query"},
+		"prop":          {"pageimages"},
+		"piprop":        {"original"},
+		"titles":        {title},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	if err := s.client.GetJSON(ctx, fmt.Sprintf(wikipediaActionURL, s.language)+"?"+q.Encode(), wikipediaMaxBytes, &body); err != nil {
+		return nil, err
+	}
+	if len(body.Query.Pages) == 0 {
+		return nil, nil
+	}
+	return body.Query.Pages[0].Original, nil
+}
@@ -0,0 +1,95 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/imaging"
+)
+
+const wikipediaRandomPageURL = "https://%s.wikipedia.org/api/rest_v1/page/random/summary"
+
+// wikipediaRandomSource uses the full-size Commons image behind a random
+// article's lead thumbnail, cropped to the screen's aspect ratio.
+type wikipediaRandomSource struct {
+	client   *fetch.Client
+	language string
+	minWidth int
+	size     image.Point
+}
+
+func newWikipediaRandomSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	size := deps.Screen
+	if size.X <= 0 || size.Y <= 0 {
+		size = image.Pt(renderWidth, renderHeight)
+	}
+	return &wikipediaRandomSource{client: deps.Client, language: cfg.WikipediaLanguage, minWidth: cfg.WikipediaMinThumbWidth, size: size}, nil
+}
+
+func (s *wikipediaRandomSource) Name() string { return "wikipedia_random" }
+
+func (s *wikipediaRandomSource) Host() string { return s.language + ".wikipedia.org" }
+
+func (s *wikipediaRandomSource) Fetch(ctx context.Context) (*Candidate, error) {
+	for attempt := 0; attempt < wikipediaAttempts; attempt++ {
+		var page struct {
+			Title       string     `json:"title"`
+			Description string     `json:"description"`
+			Thumbnail   *wikiImage `json:"thumbnail"`
+			ContentURLs struct {
+				Desktop struct {
+					Page string `json:"page"`
+				} `json:"desktop"`
+			} `json:"content_urls"`
+		}
+		if err := s.client.GetJSON(ctx, fmt.Sprintf(wikipediaRandomPageURL, s.language), wikipediaMaxBytes, &page); err != nil {
+			return nil, err
+		}
+		if page.Thumbnail == nil || page.Thumbnail.Width < s.minWidth {
+			continue
+		}
+		dl, err := s.client.DownloadToTemp(ctx, fullSizeImageURL(page.Thumbnail.Source))
+		if err != nil {
+			return nil, err
+		}
+		img, err := imaging.DecodeFile(dl)
+		os.Remove(dl)
+		if err != nil {
+			return nil, err
+		}
+		out, err := imaging.WriteTempBMP(imaging.CropToAspect(img, s.size))
+		if err != nil {
+			return nil, err
+		}
+		return &Candidate{
+			Path:      out,
+			SourceURL: page.ContentURLs.Desktop.Page,
+			Title:     page.Title,
+			Category:  page.Description,
+		}, nil
+	}
+	return nil, fmt.Errorf("no article with a lead image at least %dpx wide in %d tries", s.minWidth, wikipediaAttempts)
+}
+
+// fullSizeImageURL turns a Wikimedia thumbnail URL such as
+// .../commons/thumb/a/ab/Foo.jpg/320px-Foo.jpg into the original's,
+// .../commons/a/ab/Foo.jpg. Thumbnails of formats that can't be decoded
+// (SVG, TIFF, PDF pages) are kept as they are.
+func fullSizeImageURL(thumb string) string {
+	before, after, ok := strings.Cut(thumb, "/thumb/")
+	if !ok {
+		return thumb
+	}
+	original := after[:max(strings.LastIndex(after, "/"), 0)]
+	switch strings.ToLower(path.Ext(original)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return before + "/" + original
+	}
+	return thumb
+}
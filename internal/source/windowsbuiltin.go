@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/random"
+)
+
+const (
+	windowsBuiltInMinWidth  = 800
+	windowsBuiltInMinHeight = 600
+)
+
+// windowsBuiltInDirs are the folders below windows_builtin_path holding the
+// desktop wallpapers and the lock screen images Windows ships with.
+var windowsBuiltInDirs = []string{"Wallpaper", "Screen"}
+
+// windowsBuiltInSource picks a random image Windows ships with. It needs no
+// network, so it is also the last fallback when a change fails offline.
+type windowsBuiltInSource struct {
+	dirs []string
+}
+
+func newWindowsBuiltInSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	return OfflineFallback(cfg), nil
+}
+
+// OfflineFallback returns the windows_builtin source, which always works
+// without a network.
+func OfflineFallback(cfg config.Config) WallpaperSource {
+	s := &windowsBuiltInSource{}
+	for _, d := range windowsBuiltInDirs {
+		s.dirs = append(s.dirs, filepath.Join(cfg.WindowsBuiltInPath, d))
+	}
+	return s
+}
+
+func (s *windowsBuiltInSource) Name() string { return "windows_builtin" }
+
+func (s *windowsBuiltInSource) Fetch(ctx context.Context) (*Candidate, error) {
+	var paths []string
+	for _, dir := range s.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir {
+					return err
+				}
+				return nil // part of it is off limits; take the rest
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !d.IsDir() && isScreenshotFile(path) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Probe in random order; most are big but the folders also hold
+	// thumbnails.
+	random.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	var pick string
+	for _, p := range paths {
+		if bigEnoughBuiltIn(p) {
+			pick = p
+			break
+		}
+	}
+	if pick == "" {
+		return nil, fmt.Errorf("no images of at least %dx%d in %s",
+			windowsBuiltInMinWidth, windowsBuiltInMinHeight, strings.Join(s.dirs, ", "))
+	}
+	// The manager deletes the candidate file once it is set.
+	tmp, err := copyToTemp(pick)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{
+		Path:     tmp,
+		Title:    strings.TrimSuffix(filepath.Base(pick), filepath.Ext(pick)),
+		Author:   "Microsoft",
+		Category: filepath.Base(filepath.Dir(pick)),
+	}, nil
+}
+
+// bigEnoughBuiltIn reads just the header of path to check its size.
+func bigEnoughBuiltIn(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	return err == nil && c.Width >= windowsBuiltInMinWidth && c.Height >= windowsBuiltInMinHeight
+}
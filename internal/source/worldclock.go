@@ -0,0 +1,271 @@
+package source
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Windows has no zoneinfo for time.LoadLocation
+
+	"golang.org/x/image/vector"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/imaging"
+)
+
+// worldMapSVG is a simplified equirectangular world map, 360 by 180 units,
+// land paths plus lakes with class="water".
+//
+//go:embed worldmap.svg
+var worldMapSVG string
+
+const (
+	// The map takes the full width at 2:1, leaving a strip below for the
+	// time zone labels.
+	worldClockMapHeight = renderWidth / 2
+	worldClockLabelSize = 30
+	worldClockTimeSize  = 44
+	// Night falls over civil and nautical twilight, from the sun on the
+	// horizon to 12° below it.
+	worldClockDusk  = -0.833
+	worldClockNight = -12.0
+	// worldClockNightShade is how much of the map's color the night takes
+	// away.
+	worldClockNightShade = 0.62
+)
+
+var (
+	worldClockOcean  = color.RGBA{0x12, 0x2c, 0x44, 0xff}
+	worldClockLand   = color.RGBA{0x5b, 0x7d, 0x5a, 0xff}
+	worldClockGrid   = color.RGBA{0x1f, 0x3d, 0x58, 0xff}
+	worldClockNightC = color.RGBA{0x03, 0x06, 0x14, 0xff}
+	worldClockSun    = color.RGBA{0xff, 0xd5, 0x4a, 0xff}
+	worldClockMarker = color.RGBA{0xff, 0x9f, 0x43, 0xff}
+	worldClockText   = color.RGBA{0xee, 0xf1, 0xf4, 0xff}
+	worldClockDim    = color.RGBA{0x8d, 0x9a, 0xa8, 0xff}
+	worldClockStrip  = color.RGBA{0x0a, 0x10, 0x18, 0xff}
+)
+
+// worldClockSource draws the day and night sides of the world as they are
+// now, with the time in each of its time zones.
+type worldClockSource struct {
+	now   func() time.Time
+	zones []*time.Location
+}
+
+func newWorldClockSource(cfg config.Config, deps Deps) (WallpaperSource, error) {
+	s := &worldClockSource{now: deps.Now}
+	for _, name := range cfg.WorldClockTimezones {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("world_clock_timezones: %w", err)
+		}
+		s.zones = append(s.zones, loc)
+	}
+	return s, nil
+}
+
+func (s *worldClockSource) Name() string { return "world_clock" }
+
+func (s *worldClockSource) Fetch(ctx context.Context) (*Candidate, error) {
+	land, water, err := loadWorldMap()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	img := image.NewRGBA(image.Rect(0, 0, renderWidth, renderHeight))
+	imaging.FillRect(img, img.Bounds(), worldClockStrip)
+	mapRect := image.Rect(0, 0, renderWidth, worldClockMapHeight)
+	imaging.FillRect(img, mapRect, worldClockOcean)
+	drawGraticule(img, mapRect)
+
+	r := vector.NewRasterizer(mapRect.Dx(), mapRect.Dy())
+	scale := float32(mapRect.Dx()) / 360
+	at := func(x, y float32) (float32, float32) { return x * scale, y * scale }
+	for _, p := range land {
+		fillSVGPath(img, r, mapRect, p, at, worldClockLand)
+	}
+	for _, p := range water {
+		fillSVGPath(img, r, mapRect, p, at, worldClockOcean)
+	}
+
+	sunLat, sunLon := subsolarPoint(now)
+	shadeNight(img, mapRect, sunLat, sunLon)
+	sx := int((sunLon + 180) / 360 * float64(mapRect.Dx()))
+	sy := int((90 - sunLat) / 180 * float64(mapRect.Dy()))
+	fillDisc(img, sx, sy, 14, worldClockSun)
+
+	if err := s.drawZones(img, mapRect, now); err != nil {
+		return nil, err
+	}
+	path, err := imaging.WriteTempBMP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Candidate{Path: path, Title: "World clock " + now.UTC().Format("15:04 UTC"), Category: "world clock"}, nil
+}
+
+var (
+	worldMapOnce         sync.Once
+	worldLand, worldLake []svgPath
+	worldMapErr          error
+)
+
+// loadWorldMap parses the embedded map once.
+func loadWorldMap() (land, water []svgPath, err error) {
+	worldMapOnce.Do(func() {
+		for _, tag := range svgPathTag.FindAllString(worldMapSVG, -1) {
+			var d, class string
+			for _, m := range svgAttr.FindAllStringSubmatch(tag, -1) {
+				if m[1] == "d" {
+					d = m[2]
+				} else {
+					class = m[2]
+				}
+			}
+			sp, err := parseSVGPath(d)
+			if err != nil {
+				worldMapErr = fmt.Errorf("worldmap.svg: %w", err)
+				return
+			}
+			if class == "water" {
+				worldLake = append(worldLake, sp)
+			} else {
+				worldLand = append(worldLand, sp)
+			}
+		}
+	})
+	return worldLand, worldLake, worldMapErr
+}
+
+// subsolarPoint returns where the sun is overhead at t, in degrees, by the
+// low-precision solar coordinates of the Astronomical Almanac, good to
+// about a hundredth of a degree this century.
+func subsolarPoint(t time.Time) (lat, lon float64) {
+	rad := math.Pi / 180
+	d := float64(t.UTC().UnixNano())/float64(24*time.Hour) - 10957.5 // days since J2000.0
+	g := (357.529 + 0.98560028*d) * rad                              // mean anomaly
+	q := 280.459 + 0.98564736*d                                      // mean longitude
+	l := (q + 1.915*math.Sin(g) + 0.020*math.Sin(2*g)) * rad         // ecliptic longitude
+	e := (23.439 - 0.00000036*d) * rad                               // obliquity
+	ra := math.Atan2(math.Cos(e)*math.Sin(l), math.Cos(l)) / rad
+	dec := math.Asin(math.Sin(e) * math.Sin(l))
+	gmst := math.Mod(18.697374558+24.06570982441908*d, 24) * 15
+	lon = math.Mod(ra-gmst+540, 360) - 180
+	return dec / rad, lon
+}
+
+// shadeNight darkens the pixels of r, an equirectangular map, where the
+// sun is below the horizon, gradually through twilight.
+func shadeNight(img *image.RGBA, r image.Rectangle, sunLat, sunLon float64) {
+	rad := math.Pi / 180
+	sinDec, cosDec := math.Sin(sunLat*rad), math.Cos(sunLat*rad)
+	dusk, night := math.Sin(worldClockDusk*rad), math.Sin(worldClockNight*rad)
+	cosH := make([]float64, r.Dx())
+	for x := range cosH {
+		lon := (float64(x)+0.5)/float64(r.Dx())*360 - 180
+		cosH[x] = math.Cos((lon - sunLon) * rad)
+	}
+	for y := range r.Dy() {
+		lat := 90 - (float64(y)+0.5)/float64(r.Dy())*180
+		sinLat, cosLat := math.Sin(lat*rad), math.Cos(lat*rad)
+		for x := range r.Dx() {
+			sinAlt := sinLat*sinDec + cosLat*cosDec*cosH[x]
+			if sinAlt >= dusk {
+				continue
+			}
+			t := min(1, (dusk-sinAlt)/(dusk-night)) * worldClockNightShade
+			i := img.PixOffset(r.Min.X+x, r.Min.Y+y)
+			c := color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 0xff}
+			c = imaging.LerpColor(c, worldClockNightC, t)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = c.R, c.G, c.B
+		}
+	}
+}
+
+// drawGraticule draws the meridians and parallels every 30° over r.
+func drawGraticule(img *image.RGBA, r image.Rectangle) {
+	for deg := 30; deg < 360; deg += 30 {
+		x := r.Min.X + deg*r.Dx()/360
+		imaging.FillRect(img, image.Rect(x, r.Min.Y, x+1, r.Max.Y), worldClockGrid)
+	}
+	for deg := 30; deg < 180; deg += 30 {
+		y := r.Min.Y + deg*r.Dy()/180
+		imaging.FillRect(img, image.Rect(r.Min.X, y, r.Max.X, y+1), worldClockGrid)
+	}
+}
+
+// fillDisc fills a circle of radius rad around cx, cy with c.
+func fillDisc(img *image.RGBA, cx, cy, rad int, c color.RGBA) {
+	for dy := -rad; dy <= rad; dy++ {
+		w := int(math.Sqrt(float64(rad*rad - dy*dy)))
+		imaging.FillRect(img, image.Rect(cx-w, cy+dy, cx+w+1, cy+dy+1), c)
+	}
+}
+
+// drawZones marks each time zone's meridian, by its current UTC offset, on
+// the map and labels it in the strip below with its local time, east to
+// west in evenly spaced columns.
+func (s *worldClockSource) drawZones(img *image.RGBA, mapRect image.Rectangle, now time.Time) error {
+	if len(s.zones) == 0 {
+		return nil
+	}
+	nameFace, err := imaging.NewFace(worldClockLabelSize, false)
+	if err != nil {
+		return err
+	}
+	defer nameFace.Close()
+	timeFace, err := imaging.NewFace(worldClockTimeSize, true)
+	if err != nil {
+		return err
+	}
+	defer timeFace.Close()
+
+	zones := append([]*time.Location(nil), s.zones...)
+	offset := func(loc *time.Location) int { _, off := now.In(loc).Zone(); return off }
+	sort.SliceStable(zones, func(i, j int) bool { return offset(zones[i]) < offset(zones[j]) })
+
+	colW := renderWidth / len(zones)
+	stripTop := mapRect.Max.Y
+	for i, loc := range zones {
+		lon := math.Mod(float64(offset(loc))/3600*15+540, 360) - 180
+		mx := mapRect.Min.X + int((lon+180)/360*float64(mapRect.Dx()))
+		for y := mapRect.Min.Y; y < mapRect.Max.Y; y += 12 {
+			imaging.FillRect(img, image.Rect(mx, y, mx+2, min(y+6, mapRect.Max.Y)), worldClockMarker)
+		}
+
+		cx := i*colW + colW/2
+		// An elbow from the meridian down to the label's column.
+		imaging.FillRect(img, image.Rect(mx, stripTop, mx+2, stripTop+12), worldClockMarker)
+		imaging.FillRect(img, image.Rect(min(mx, cx), stripTop+12, max(mx, cx)+2, stripTop+14), worldClockMarker)
+		imaging.FillRect(img, image.Rect(cx, stripTop+12, cx+2, stripTop+22), worldClockMarker)
+
+		local := now.In(loc)
+		clock := local.Format("15:04")
+		if local.YearDay() != now.YearDay() {
+			clock = local.Format("Mon 15:04")
+		}
+		name := imaging.TruncateText(nameFace, zoneCity(loc), colW-16)
+		y := stripTop + 30 + timeFace.Metrics().Ascent.Ceil()
+		imaging.DrawText(img, timeFace, cx-imaging.TextWidth(timeFace, clock)/2, y, clock, worldClockText)
+		y += nameFace.Metrics().Height.Ceil() + 4
+		imaging.DrawText(img, nameFace, cx-imaging.TextWidth(nameFace, name)/2, y, name, worldClockDim)
+	}
+	return nil
+}
+
+// zoneCity names loc by the city of its IANA name, e.g. "New York" for
+// America/New_York.
+func zoneCity(loc *time.Location) string {
+	name := loc.String()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ReplaceAll(name, "_", " ")
+}
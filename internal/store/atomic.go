@@ -0,0 +1,176 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+const (
+	// BackupSuffix names the previous version of a state file, kept by
+	// every write so a torn or corrupt file can be recovered.
+	BackupSuffix = ".bak"
+	// CorruptSuffix names state files set aside when neither they nor their
+	// backup could be read, kept for diagnostics.
+	CorruptSuffix = ".corrupt"
+)
+
+// WriteFileAtomic replaces path with data so that a crash leaves either the
+// old or the new content: the data goes to a temp file in the same dir,
+// is synced, and is renamed over path. For state kept outside a Store.
+func WriteFileAtomic(path string, data []byte) error {
+	return replaceFile(path, data, false)
+}
+
+// writeFileAtomic is WriteFileAtomic that keeps the old content as the
+// backup, for ReadJSON to fall back on.
+func writeFileAtomic(path string, data []byte) error {
+	return replaceFile(path, data, true)
+}
+
+func replaceFile(path string, data []byte, backup bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if backup {
+		if err := os.Rename(path, path+BackupSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// envelope wraps the JSON state files with their schema version.
+type envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// WriteJSON stores v under name as schema version version.
+func (s *Store) WriteJSON(name string, version int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(envelope{SchemaVersion: version, Data: data})
+	if err != nil {
+		return err
+	}
+	return s.Write(name, b)
+}
+
+// ReadJSON decodes name, as written by WriteJSON with the same version,
+// into v. When the file is torn, not UTF-8, not JSON or of another version,
+// the backup from the write before is used instead. When that fails too,
+// both are renamed with CorruptSuffix and os.ErrNotExist is returned, so
+// the caller starts fresh as if there had never been a file. Bare JSON from
+// before versioning is read as it is.
+func (s *Store) ReadJSON(name string, version int, v any) error {
+	primary, err := s.Read(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var primaryErr error
+	if err == nil {
+		if primaryErr = decodeVersioned(primary, version, v); primaryErr == nil {
+			return nil
+		}
+	}
+	backup, berr := s.readFile(name + BackupSuffix)
+	if berr != nil && !errors.Is(berr, os.ErrNotExist) {
+		return berr
+	}
+	if berr == nil {
+		backupErr := decodeVersioned(backup, version, v)
+		if backupErr == nil {
+			if primaryErr != nil {
+				fmt.Printf("state file %s is corrupt (%v), restored the backup\n", name, primaryErr)
+				s.setAside(name)
+				if dir := s.Dir(); dir != "" {
+					if err := writeFileAtomic(filepath.Join(dir, name), backup); err != nil {
+						fmt.Println("failed to restore", name+":", err)
+					}
+				}
+				if s.hooks.Corrupt != nil {
+					s.hooks.Corrupt(name, true)
+				}
+			}
+			// Without primaryErr the primary is missing: a crash between
+			// the two renames leaves the backup as the latest version.
+			return nil
+		}
+		if primaryErr == nil {
+			primaryErr = backupErr
+		}
+	}
+	if primaryErr == nil {
+		return os.ErrNotExist // never written
+	}
+	fmt.Printf("state file %s and its backup are unreadable (%v), starting fresh; kept them with suffix %s\n",
+		name, primaryErr, CorruptSuffix)
+	s.setAside(name)
+	s.setAside(name + BackupSuffix)
+	if s.hooks.Corrupt != nil {
+		s.hooks.Corrupt(name, false)
+	}
+	return os.ErrNotExist
+}
+
+func decodeVersioned(b []byte, version int, v any) error {
+	if !utf8.Valid(b) {
+		return errors.New("not valid UTF-8")
+	}
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil || (env.SchemaVersion == 0 && env.Data == nil) {
+		// Bare JSON from before state files were versioned, or no JSON.
+		return json.Unmarshal(b, v)
+	}
+	if env.SchemaVersion != version {
+		return fmt.Errorf("schema_version %d, want %d", env.SchemaVersion, version)
+	}
+	return json.Unmarshal(env.Data, v)
+}
+
+// readFile reads name from disk only, for files never queued in memory.
+func (s *Store) readFile(name string) ([]byte, error) {
+	dir := s.Dir()
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(filepath.Join(dir, name))
+}
+
+// setAside renames name to name+CorruptSuffix, replacing an older one.
+func (s *Store) setAside(name string) {
+	dir := s.Dir()
+	if dir == "" {
+		return
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Rename(path, path+CorruptSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("failed to set aside", name+":", err)
+	}
+}
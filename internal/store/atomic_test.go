@@ -0,0 +1,149 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type state struct {
+	Count int    `json:"count"`
+	Name  string `json:"name"`
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("WriteFileAtomic(%q): %v", content, err)
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != content {
+			t.Fatalf("file holds %q, %v; want %q", b, err, content)
+		}
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(names) != 1 {
+		t.Errorf("dir holds %v, want only the file: no temp file and no backup", names)
+	}
+}
+
+func TestWriteKeepsBackup(t *testing.T) {
+	s := New(t.TempDir(), Hooks{})
+	s.Write("state.json", []byte("one"))
+	s.Write("state.json", []byte("two"))
+	if b, err := os.ReadFile(filepath.Join(s.Dir(), "state.json"+BackupSuffix)); err != nil || string(b) != "one" {
+		t.Errorf("backup holds %q, %v; want the previous write", b, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(s.Dir(), "*.tmp")); len(matches) != 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
+
+// TestReadJSONRecovery damages state.json, after two good writes, the ways a
+// crash, a bad sync or an older build can, and checks what ReadJSON makes of
+// it.
+func TestReadJSONRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage breaks the files in dir after the writes of {1 first}
+		// then {2 second}.
+		damage   func(t *testing.T, dir string)
+		want     state
+		notExist bool
+		restored bool // the Corrupt hook is told the backup was used
+		corrupt  bool // the Corrupt hook is called at all
+	}{
+		{"intact", func(t *testing.T, dir string) {}, state{2, "second"}, false, false, false},
+		{"truncated", func(t *testing.T, dir string) {
+			truncate(t, filepath.Join(dir, "state.json"))
+		}, state{1, "first"}, false, true, true},
+		{"empty", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, "state.json"), nil, 0o644)
+		}, state{1, "first"}, false, true, true},
+		{"invalid UTF-8", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, "state.json"), []byte(`{"schema_version":1,"data":{"name":"`+"\xff\xfe"+`"}}`), 0o644)
+		}, state{1, "first"}, false, true, true},
+		{"wrong schema_version", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, "state.json"), []byte(`{"schema_version":9,"data":{"count":9}}`), 0o644)
+		}, state{1, "first"}, false, true, true},
+		{"missing, backup left by a crash between renames", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, "state.json"))
+		}, state{1, "first"}, false, false, false},
+		{"both truncated", func(t *testing.T, dir string) {
+			truncate(t, filepath.Join(dir, "state.json"))
+			truncate(t, filepath.Join(dir, "state.json"+BackupSuffix))
+		}, state{}, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var corrupt, restored bool
+			s := New(t.TempDir(), Hooks{Corrupt: func(name string, r bool) { corrupt, restored = true, r }})
+			s.WriteJSON("state.json", 1, state{1, "first"})
+			s.WriteJSON("state.json", 1, state{2, "second"})
+			tt.damage(t, s.Dir())
+
+			var got state
+			err := s.ReadJSON("state.json", 1, &got)
+			if tt.notExist {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("ReadJSON = %v, want ErrNotExist to start fresh", err)
+				}
+			} else if err != nil || got != tt.want {
+				t.Fatalf("ReadJSON = %+v, %v; want %+v", got, err, tt.want)
+			}
+			if corrupt != tt.corrupt || restored != tt.restored {
+				t.Errorf("Corrupt hook called %v restored %v, want %v %v", corrupt, restored, tt.corrupt, tt.restored)
+			}
+			if !tt.corrupt {
+				return
+			}
+			if _, err := os.Stat(filepath.Join(s.Dir(), "state.json"+CorruptSuffix)); err != nil {
+				t.Errorf("the corrupt file wasn't kept: %v", err)
+			}
+			if tt.restored {
+				// The backup was written back, so the next read is clean.
+				corrupt = false
+				var again state
+				if err := s.ReadJSON("state.json", 1, &again); err != nil || again != tt.want || corrupt {
+					t.Errorf("second ReadJSON = %+v, %v, corrupt %v; want the restored %+v", again, err, corrupt, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadJSONBare(t *testing.T) {
+	s := New(t.TempDir(), Hooks{})
+	os.WriteFile(filepath.Join(s.Dir(), "state.json"), []byte(`{"count":3,"name":"старый"}`), 0o644)
+	var got state
+	if err := s.ReadJSON("state.json", 1, &got); err != nil || got != (state{3, "старый"}) {
+		t.Errorf("ReadJSON of unversioned JSON = %+v, %v", got, err)
+	}
+}
+
+func TestReadJSONNeverWritten(t *testing.T) {
+	called := false
+	s := New(t.TempDir(), Hooks{Corrupt: func(string, bool) { called = true }})
+	var got state
+	if err := s.ReadJSON("state.json", 1, &got); !errors.Is(err, os.ErrNotExist) || called {
+		t.Errorf("ReadJSON = %v, Corrupt called %v; want ErrNotExist quietly", err, called)
+	}
+}
+
+// truncate cuts path to half its length, as a write torn by a crash leaves it.
+func truncate(t *testing.T, path string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "{") {
+		t.Fatalf("%s is not JSON: %q", path, b)
+	}
+	if err := os.WriteFile(path, b[:len(b)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"context"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/app"
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/fetch"
+	"wallpaper-changer/internal/store"
+)
+
+// visibleSetter records when the wallpaper was set and how many state
+// writes had reached the disk by then.
+type visibleSetter struct {
+	writes func() int
+
+	mu     sync.Mutex
+	at     time.Time
+	before int
+}
+
+func (v *visibleSetter) SetWallpaper(string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.at, v.before = time.Now(), v.writes()
+	return nil
+}
+
+func (v *visibleSetter) ApplyFitMode(string) error             { return nil }
+func (v *visibleSetter) MatchBackgroundColor(color.RGBA) error { return nil }
+
+// TestBatchSlowDisk runs a Manager change on a disk taking diskDelay per
+// state write and measures how long the wallpaper takes to show and the
+// change to finish. None of the state writes may come before the
+// wallpaper shows; after, they reach the disk together, once per file.
+func TestBatchSlowDisk(t *testing.T) {
+	const diskDelay = 50 * time.Millisecond
+	writes := store.SlowDisk(t, diskDelay)
+	cfg := config.Default()
+	cfg.Source = "starfield"
+	cfg.RespectExternalChanges = false
+	set := &visibleSetter{writes: writes}
+	monitor := func(string) (display.Monitor, error) {
+		return display.Monitor{Name: "test", Width: 640, Height: 360}, nil
+	}
+	st := store.New(t.TempDir(), store.Hooks{})
+	m := app.NewManager(config.NewLive(cfg), st, set, fetch.New(nil, "test"), time.Now, monitor, app.Hooks{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	start := time.Now()
+	if err := m.ChangeNow(app.InitiatorManual); err != nil {
+		t.Fatalf("ChangeNow: %v", err)
+	}
+	done := time.Since(start)
+	set.mu.Lock()
+	visible, before := set.at.Sub(start), set.before
+	set.mu.Unlock()
+	t.Logf("with %s per state write: visible after %s, change done after %s with %d writes",
+		diskDelay, visible.Round(time.Millisecond), done.Round(time.Millisecond), writes())
+
+	if set.at.IsZero() {
+		t.Fatal("no wallpaper was set")
+	}
+	if before != 0 {
+		t.Errorf("%d state writes before the wallpaper showed, want none", before)
+	}
+	// the daily marker, the protected files and the history's index
+	if got := writes(); got != 3 {
+		t.Errorf("%d state writes, want one per file", got)
+	}
+	if done < visible+3*diskDelay {
+		t.Errorf("change done %s after it showed, before its writes could be", done-visible)
+	}
+	if !st.WasUpdatedToday(time.Now()) {
+		t.Error("the daily marker isn't on disk once the change is done")
+	}
+}
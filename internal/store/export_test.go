@@ -0,0 +1,21 @@
+package store
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// SlowDisk makes every state write take delay until t ends. It returns the
+// number of writes made so far.
+func SlowDisk(t *testing.T, delay time.Duration) (writes func() int) {
+	var n atomic.Int32
+	orig := writeState
+	writeState = func(path string, data []byte) error {
+		time.Sleep(delay)
+		n.Add(1)
+		return orig(path, data)
+	}
+	t.Cleanup(func() { writeState = orig })
+	return func() int { return int(n.Load()) }
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ProtectedFileName lists the files in use, such as the current
+	// wallpaper, that deletions in the app dir must leave alone.
+	ProtectedFileName = "protected_paths.json"
+
+	protectedSchemaVersion = 1
+)
+
+// ErrProtected marks a deletion refused because the file is in use.
+var ErrProtected = errors.New("file is in use")
+
+// Protect records paths as owner's files in use, replacing what owner
+// protected before; with no paths owner's protection is released. It waits
+// for a RemoveFile or MoveFile in progress, so once it returns none of paths
+// can be deleted.
+func (s *Store) Protect(owner string, paths ...string) {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	s.loadProtected()
+	if len(paths) == 0 {
+		delete(s.protected, owner)
+	} else {
+		clean := make([]string, len(paths))
+		for i, p := range paths {
+			clean[i] = filepath.Clean(p)
+		}
+		s.protected[owner] = clean
+	}
+	if err := s.WriteJSON(ProtectedFileName, protectedSchemaVersion, s.protected); err != nil {
+		fmt.Println("failed to save protected paths:", err)
+	}
+}
+
+// Protected reports whether any owner protects path.
+func (s *Store) Protected(path string) bool {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	return s.isProtected(path)
+}
+
+// RemoveFile deletes path unless it is protected, in which case it returns
+// ErrProtected. Deletions and moves are serialized with each other and
+// with Protect.
+func (s *Store) RemoveFile(path string) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.isProtected(path) {
+		return fmt.Errorf("%w: %s", ErrProtected, path)
+	}
+	return os.Remove(path)
+}
+
+// MoveFile renames from to to unless from is protected, like RemoveFile.
+func (s *Store) MoveFile(from, to string) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.isProtected(from) {
+		return fmt.Errorf("%w: %s", ErrProtected, from)
+	}
+	return os.Rename(from, to)
+}
+
+// isProtected is Protected with fileMu held. Paths compare
+// case-insensitively, as Windows does.
+func (s *Store) isProtected(path string) bool {
+	s.loadProtected()
+	path = filepath.Clean(path)
+	for _, paths := range s.protected {
+		for _, p := range paths {
+			if strings.EqualFold(p, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadProtected reads the protected paths saved by an earlier run once the
+// app dir is known, so files still in use stay protected until their owners
+// protect again. Owners that protected since keep their newer paths.
+func (s *Store) loadProtected() {
+	if s.protected == nil {
+		s.protected = map[string][]string{}
+	}
+	if s.protectedLoaded || s.Dir() == "" {
+		return
+	}
+	s.protectedLoaded = true
+	var saved map[string][]string
+	if err := s.ReadJSON(ProtectedFileName, protectedSchemaVersion, &saved); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("failed to read protected paths:", err)
+		}
+		return
+	}
+	for owner, paths := range saved {
+		if _, ok := s.protected[owner]; !ok {
+			s.protected[owner] = paths
+		}
+	}
+}
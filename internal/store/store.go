@@ -0,0 +1,307 @@
+// Package store owns the app data dir and the small state files kept in it.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wallpaper-changer/internal/logging"
+)
+
+const (
+	// FolderName is the app dir name under %APPDATA%.
+	FolderName = "GoWallpaperTray"
+	// LastDateFileName records the date of the last daily change.
+	LastDateFileName = "last_update.txt"
+
+	flushInterval     = 30 * time.Second
+	appDirWaitEnv     = "GO_WALLPAPER_APPDIR_WAIT"
+	defaultAppDirWait = 2 * time.Minute
+	maxAppDirBackoff  = 30 * time.Second
+	dateLayout        = "2006-01-02"
+)
+
+// Hooks let the caller surface outages, e.g. in the tray. Any may be nil.
+type Hooks struct {
+	Outage    func(err error)
+	Recovered func()
+	// Corrupt is told about a state file ReadJSON couldn't parse, and
+	// whether its backup could be used instead.
+	Corrupt func(name string, restored bool)
+}
+
+// pendingWrite is a state write that couldn't reach the disk yet.
+type pendingWrite struct {
+	data   []byte
+	remove bool
+}
+
+// Store writes small state files in the app dir. When the dir is
+// unwritable (e.g. a roaming profile mid-sync) writes are kept in memory,
+// served to readers, and flushed once the dir comes back.
+type Store struct {
+	hooks Hooks
+
+	mu          sync.Mutex
+	dir         string
+	pending     map[string]pendingWrite
+	queued      []string // the names in pending, first queued first
+	outageSince time.Time
+	batches     int // open Batch calls
+
+	// fileMu serializes deletions in the app dir with the protected paths
+	// they must spare; see Protect.
+	fileMu          sync.Mutex
+	protected       map[string][]string
+	protectedLoaded bool
+}
+
+// New returns a Store rooted at dir. dir may be empty until SetDir is called;
+// writes made before that are queued.
+func New(dir string, hooks Hooks) *Store {
+	return &Store{dir: dir, hooks: hooks, pending: map[string]pendingWrite{}}
+}
+
+// Dir returns the app dir, or "" while it is unresolved.
+func (s *Store) Dir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+func (s *Store) path(name string) string { return filepath.Join(s.dir, name) }
+
+// SetDir points the store at the app dir once it could be resolved, flushing
+// anything written before that.
+func (s *Store) SetDir(dir string) {
+	s.mu.Lock()
+	s.dir = dir
+	s.mu.Unlock()
+	s.Flush()
+}
+
+// Write stores data under name. It only fails for errors other than the data
+// dir being unavailable; in that case the write is queued.
+func (s *Store) Write(name string, data []byte) error {
+	return s.apply(name, pendingWrite{data: data})
+}
+
+// Remove deletes name, queueing the deletion during an outage.
+func (s *Store) Remove(name string) error {
+	return s.apply(name, pendingWrite{remove: true})
+}
+
+// Read returns the queued value for name if there is one, else the file.
+func (s *Store) Read(name string) ([]byte, error) {
+	s.mu.Lock()
+	p, ok := s.pending[name]
+	dir := s.dir
+	s.mu.Unlock()
+	if ok {
+		if p.remove {
+			return nil, os.ErrNotExist
+		}
+		return p.data, nil
+	}
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(filepath.Join(dir, name))
+}
+
+func (s *Store) apply(name string, w pendingWrite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) > 0 || s.batches > 0 {
+		// Keep ordering: once anything is queued, queue everything.
+		s.queue(name, w)
+		return nil
+	}
+	if err := s.commit(name, w); err != nil {
+		s.beginOutage(err)
+		s.queue(name, w)
+	}
+	return nil
+}
+
+// queue keeps w as name's pending write. A file queued again keeps its
+// place, so Flush writes files in the order they were first written.
+// s.mu must be held.
+func (s *Store) queue(name string, w pendingWrite) {
+	if _, ok := s.pending[name]; !ok {
+		s.queued = append(s.queued, name)
+	}
+	s.pending[name] = w
+}
+
+func (s *Store) commit(name string, w pendingWrite) error {
+	if s.dir == "" {
+		return errors.New("app dir not resolved yet")
+	}
+	if w.remove {
+		os.Remove(s.path(name) + BackupSuffix)
+		err := os.Remove(s.path(name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	return writeState(s.path(name), w.data)
+}
+
+// writeState writes a state file to disk. Tests slow it down.
+var writeState = writeFileAtomic
+
+func (s *Store) beginOutage(err error) {
+	if !s.outageSince.IsZero() {
+		return
+	}
+	s.outageSince = time.Now()
+	fmt.Println("data dir unwritable, queueing state writes:", err)
+	if s.hooks.Outage != nil {
+		s.hooks.Outage(err)
+	}
+}
+
+// Batch keeps writes in memory, where readers see them, until the returned
+// func is called, and then writes them together, the last write of each
+// file only. A change batches the bookkeeping after its wallpaper shows, a
+// handful of writes, several to the same file. Writes from elsewhere wait
+// too, so keep batches short.
+func (s *Store) Batch() (done func()) {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.batches--
+			last := s.batches == 0
+			s.mu.Unlock()
+			if last {
+				s.Flush()
+			}
+		})
+	}
+}
+
+// Flush tries to write everything queued, in order; it stops at the first
+// failure, leaving that write and the later ones queued.
+func (s *Store) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queued) > 0 {
+		name := s.queued[0]
+		if err := s.commit(name, s.pending[name]); err != nil {
+			return
+		}
+		delete(s.pending, name)
+		s.queued = s.queued[1:]
+	}
+	if !s.outageSince.IsZero() {
+		fmt.Printf("data dir writable again after %s, flushed queued state\n",
+			time.Since(s.outageSince).Round(time.Second))
+		s.outageSince = time.Time{}
+		if s.hooks.Recovered != nil {
+			s.hooks.Recovered()
+		}
+	}
+}
+
+// FlushLoop retries queued writes periodically until ctx is done.
+func (s *Store) FlushLoop(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(flushInterval):
+			s.Flush()
+		case <-ctx.Done():
+			s.Flush()
+			return
+		}
+	}
+}
+
+// WasUpdatedToday reports whether MarkUpdated was called on now's date.
+func (s *Store) WasUpdatedToday(now time.Time) bool {
+	b, err := s.Read(LastDateFileName)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(b)) == now.Format(dateLayout)
+}
+
+// MarkUpdated records now's date as the last daily change.
+func (s *Store) MarkUpdated(now time.Time) error {
+	return s.Write(LastDateFileName, []byte(now.Format(dateLayout)))
+}
+
+// ResolveAppDir returns %APPDATA%\GoWallpaperTray without creating it.
+func ResolveAppDir() (string, error) {
+	appdata := os.Getenv("APPDATA")
+	if appdata == "" {
+		return "", errors.New("APPDATA not set")
+	}
+	return filepath.Join(appdata, FolderName), nil
+}
+
+func ensureAppDir() (string, error) {
+	appDir, err := ResolveAppDir()
+	if err != nil {
+		return "", err
+	}
+	return appDir, os.MkdirAll(appDir, 0o755)
+}
+
+// WaitForAppDir resolves and creates the app dir, retrying with backoff for
+// up to GO_WALLPAPER_APPDIR_WAIT (default 2m) since %APPDATA% can be briefly
+// missing at logon on roaming profiles.
+func WaitForAppDir() (string, error) {
+	wait := defaultAppDirWait
+	if v := os.Getenv(appDirWaitEnv); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			wait = d
+		} else {
+			fmt.Printf("ignoring %s=%q: %v\n", appDirWaitEnv, v, err)
+		}
+	}
+	deadline := time.Now().Add(wait)
+	backoff := time.Second
+	for {
+		appDir, err := ensureAppDir()
+		if err == nil {
+			return appDir, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return "", err
+		}
+		logging.Warnf("store", "app dir unavailable (%v), retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxAppDirBackoff)
+	}
+}
+
+// RecoverAppDir keeps retrying after WaitForAppDir gave up at startup and
+// returns the app dir once it exists, or ctx's error.
+func RecoverAppDir(ctx context.Context) (string, error) {
+	for {
+		select {
+		case <-time.After(maxAppDirBackoff):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if appDir, err := ensureAppDir(); err == nil {
+			fmt.Println("app dir available again:", appDir)
+			return appDir, nil
+		}
+	}
+}
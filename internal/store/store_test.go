@@ -0,0 +1,159 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWriteReadRemove(t *testing.T) {
+	s := New(t.TempDir(), Hooks{})
+	if err := s.Write("state.txt", []byte("one")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if b, err := s.Read("state.txt"); err != nil || string(b) != "one" {
+		t.Fatalf("Read = %q, %v; want %q", b, err, "one")
+	}
+	if b, err := os.ReadFile(filepath.Join(s.Dir(), "state.txt")); err != nil || string(b) != "one" {
+		t.Fatalf("file holds %q, %v; want %q", b, err, "one")
+	}
+	if err := s.Remove("state.txt"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := s.Read("state.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read after Remove = %v, want ErrNotExist", err)
+	}
+}
+
+func TestWritesBeforeSetDirAreQueued(t *testing.T) {
+	s := New("", Hooks{})
+	if err := s.Write("state.txt", []byte("queued")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if b, err := s.Read("state.txt"); err != nil || string(b) != "queued" {
+		t.Fatalf("Read of queued write = %q, %v", b, err)
+	}
+	dir := t.TempDir()
+	s.SetDir(dir)
+	if b, err := os.ReadFile(filepath.Join(dir, "state.txt")); err != nil || string(b) != "queued" {
+		t.Errorf("after SetDir the file holds %q, %v; want %q", b, err, "queued")
+	}
+}
+
+func TestMarkUpdated(t *testing.T) {
+	s := New(t.TempDir(), Hooks{})
+	day := time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)
+	if s.WasUpdatedToday(day) {
+		t.Fatal("WasUpdatedToday before MarkUpdated")
+	}
+	if err := s.MarkUpdated(day); err != nil {
+		t.Fatalf("MarkUpdated: %v", err)
+	}
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{day, true},
+		{day.Add(14 * time.Hour), true},
+		{day.Add(24 * time.Hour), false},
+		{day.Add(-24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := s.WasUpdatedToday(tt.now); got != tt.want {
+			t.Errorf("WasUpdatedToday(%s) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+// unicodeDirs are app dirs like those of users with non-ASCII profiles.
+var unicodeDirs = []string{"Пользователь", "with space", "фото 🌄 wallpapers", "ÅÄÖ\u00a0nbsp"}
+
+func TestUnicodeAppDir(t *testing.T) {
+	for _, name := range unicodeDirs {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), name, FolderName)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			s := New(dir, Hooks{})
+			if err := s.Write("state.txt", []byte("ok")); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if b, err := os.ReadFile(filepath.Join(dir, "state.txt")); err != nil || string(b) != "ok" {
+				t.Fatalf("file holds %q, %v", b, err)
+			}
+
+			wall := filepath.Join(dir, "обои 🖼.bmp")
+			if err := os.WriteFile(wall, []byte("bmp"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s.Protect("test", wall)
+			// A new Store reloads the protected paths from disk.
+			s = New(dir, Hooks{})
+			if !s.Protected(wall) {
+				t.Fatalf("%s not protected after reload", wall)
+			}
+			if err := s.RemoveFile(wall); !errors.Is(err, ErrProtected) {
+				t.Errorf("RemoveFile of a protected file = %v", err)
+			}
+			s.Protect("test")
+			moved := filepath.Join(dir, "ещё 🎞.bmp")
+			if err := s.MoveFile(wall, moved); err != nil {
+				t.Fatalf("MoveFile: %v", err)
+			}
+			if err := s.RemoveFile(moved); err != nil {
+				t.Fatalf("RemoveFile: %v", err)
+			}
+		})
+	}
+}
+
+// TestFlushOrder checks that queued writes reach the disk in the order
+// they were first made and that a failure leaves it and the later ones
+// queued.
+func TestFlushOrder(t *testing.T) {
+	s := New("", Hooks{})
+	for _, w := range []struct{ name, data string }{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}, {"a", "5"}} {
+		if err := s.Write(w.name, []byte(w.data)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	var order []string
+	failing := errors.New("disk full")
+	orig := writeState
+	writeState = func(path string, data []byte) error {
+		order = append(order, filepath.Base(path))
+		if filepath.Base(path) == "c" {
+			return failing
+		}
+		return orig(path, data)
+	}
+	t.Cleanup(func() { writeState = orig })
+
+	dir := t.TempDir()
+	s.SetDir(dir)
+	if want := []string{"a", "b", "c"}; !slices.Equal(order, want) {
+		t.Fatalf("wrote %v, want %v and a stop", order, want)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "a")); err != nil || string(b) != "5" {
+		t.Errorf("a holds %q, %v; want its last write", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "d")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("d written past the failed c: %v", err)
+	}
+	if b, err := s.Read("d"); err != nil || string(b) != "4" {
+		t.Errorf("Read of queued d = %q, %v", b, err)
+	}
+
+	order = nil
+	writeState = orig
+	s.Flush()
+	for _, name := range []string{"c", "d"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not written by the next Flush: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !tray_native
+
+package tray
+
+import "github.com/getlantern/systray"
+
+const (
+	// systrayClass and systrayIconID are what getlantern/systray registers
+	// its hidden window and notification icon with.
+	systrayClass  = "SystrayClass"
+	systrayIconID = 100
+)
+
+func newDefault() Tray { return systrayTray{} }
+
+type systrayTray struct{}
+
+func (systrayTray) Run(onReady, onExit func()) { systray.Run(onReady, onExit) }
+func (systrayTray) Quit()                      { systray.Quit() }
+func (systrayTray) SetIcon(icon []byte)        { systray.SetIcon(icon) }
+func (systrayTray) SetTitle(title string)      { systray.SetTitle(title) }
+func (systrayTray) SetTooltip(tooltip string)  { systray.SetTooltip(tooltip) }
+
+func (systrayTray) AddItem(title, tooltip string) Item {
+	return systrayItem{systray.AddMenuItem(title, tooltip)}
+}
+
+func (systrayTray) AddItemCheckbox(title, tooltip string, checked bool) Item {
+	return systrayItem{systray.AddMenuItemCheckbox(title, tooltip, checked)}
+}
+
+func (systrayTray) IconWindow() (string, uint32) { return systrayClass, systrayIconID }
+
+// systrayItem adapts *systray.MenuItem, whose methods it mostly shares.
+type systrayItem struct {
+	*systray.MenuItem
+}
+
+func (i systrayItem) Clicked() <-chan struct{} { return i.ClickedCh }
+
+func (i systrayItem) AddSubItem(title, tooltip string) Item {
+	return systrayItem{i.AddSubMenuItem(title, tooltip)}
+}
+
+func (i systrayItem) AddSubItemCheckbox(title, tooltip string, checked bool) Item {
+	return systrayItem{i.AddSubMenuItemCheckbox(title, tooltip, checked)}
+}
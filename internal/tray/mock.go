@@ -0,0 +1,173 @@
+package tray
+
+import "sync"
+
+// Mock is a Tray that shows nothing and records what it was told, for
+// tests of the menu logic. Its methods are safe for concurrent use.
+type Mock struct {
+	mu             sync.Mutex
+	items          []*MockItem
+	title, tooltip string
+	icon           []byte
+	quit           chan struct{}
+}
+
+// NewMock returns an empty Mock.
+func NewMock() *Mock { return &Mock{quit: make(chan struct{})} }
+
+// Run calls onReady, then blocks until Quit and calls onExit.
+func (m *Mock) Run(onReady, onExit func()) {
+	onReady()
+	<-m.quit
+	onExit()
+}
+
+// Quit ends Run; later calls do nothing.
+func (m *Mock) Quit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.quit:
+	default:
+		close(m.quit)
+	}
+}
+
+func (m *Mock) SetIcon(icon []byte)       { m.set(func() { m.icon = icon }) }
+func (m *Mock) SetTitle(title string)     { m.set(func() { m.title = title }) }
+func (m *Mock) SetTooltip(tooltip string) { m.set(func() { m.tooltip = tooltip }) }
+
+func (m *Mock) AddItem(title, tooltip string) Item { return m.add(newMockItem(title, tooltip, false)) }
+
+func (m *Mock) AddItemCheckbox(title, tooltip string, checked bool) Item {
+	return m.add(newMockItem(title, tooltip, checked))
+}
+
+func (m *Mock) IconWindow() (string, uint32) { return "", 0 }
+
+func (m *Mock) add(i *MockItem) Item {
+	m.set(func() { m.items = append(m.items, i) })
+	return i
+}
+
+func (m *Mock) set(f func()) {
+	m.mu.Lock()
+	f()
+	m.mu.Unlock()
+}
+
+// Tooltip returns the last tooltip set.
+func (m *Mock) Tooltip() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tooltip
+}
+
+// Title returns the last title set.
+func (m *Mock) Title() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.title
+}
+
+// Icon returns the last icon set.
+func (m *Mock) Icon() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.icon
+}
+
+// Items returns the top-level items in the order they were added.
+func (m *Mock) Items() []*MockItem {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*MockItem(nil), m.items...)
+}
+
+// MockItem is a Mock's menu item.
+type MockItem struct {
+	mu                sync.Mutex
+	title, tooltip    string
+	checked, disabled bool
+	hidden            bool
+	icon              []byte
+	sub               []*MockItem
+	clicked           chan struct{}
+}
+
+func newMockItem(title, tooltip string, checked bool) *MockItem {
+	return &MockItem{title: title, tooltip: tooltip, checked: checked, clicked: make(chan struct{}, 1)}
+}
+
+func (i *MockItem) set(f func()) {
+	i.mu.Lock()
+	f()
+	i.mu.Unlock()
+}
+
+func (i *MockItem) SetTitle(title string)     { i.set(func() { i.title = title }) }
+func (i *MockItem) SetTooltip(tooltip string) { i.set(func() { i.tooltip = tooltip }) }
+func (i *MockItem) Check()                    { i.set(func() { i.checked = true }) }
+func (i *MockItem) Uncheck()                  { i.set(func() { i.checked = false }) }
+func (i *MockItem) Enable()                   { i.set(func() { i.disabled = false }) }
+func (i *MockItem) Disable()                  { i.set(func() { i.disabled = true }) }
+func (i *MockItem) Show()                     { i.set(func() { i.hidden = false }) }
+func (i *MockItem) Hide()                     { i.set(func() { i.hidden = true }) }
+func (i *MockItem) SetIcon(icon []byte)       { i.set(func() { i.icon = icon }) }
+
+func (i *MockItem) Checked() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.checked
+}
+
+func (i *MockItem) Disabled() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.disabled
+}
+
+// Hidden reports whether the item is hidden.
+func (i *MockItem) Hidden() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.hidden
+}
+
+// Title returns the item's label.
+func (i *MockItem) Title() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.title
+}
+
+func (i *MockItem) AddSubItem(title, tooltip string) Item {
+	return i.addSub(newMockItem(title, tooltip, false))
+}
+
+func (i *MockItem) AddSubItemCheckbox(title, tooltip string, checked bool) Item {
+	return i.addSub(newMockItem(title, tooltip, checked))
+}
+
+func (i *MockItem) addSub(s *MockItem) Item {
+	i.set(func() { i.sub = append(i.sub, s) })
+	return s
+}
+
+// Sub returns the submenu's items in the order they were added.
+func (i *MockItem) Sub() []*MockItem {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return append([]*MockItem(nil), i.sub...)
+}
+
+func (i *MockItem) Clicked() <-chan struct{} { return i.clicked }
+
+// Click clicks the item, unless it is disabled or hidden, as the user
+// can't click those. It blocks until the last click was taken.
+func (i *MockItem) Click() {
+	if i.Disabled() || i.Hidden() {
+		return
+	}
+	i.clicked <- struct{}{}
+}
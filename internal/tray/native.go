@@ -0,0 +1,388 @@
+//go:build tray_native
+
+package tray
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"wallpaper-changer/internal/winmsg"
+)
+
+const (
+	// nativeClass and nativeIconID are what the native tray registers its
+	// window and notification icon with.
+	nativeClass  = "GoWallpaperTrayIcon"
+	nativeIconID = 1
+
+	wmCommand     = 0x0111
+	wmNull        = 0x0000
+	wmLButtonUp   = 0x0202
+	wmRButtonUp   = 0x0205
+	wmContextMenu = 0x007B
+	// wmTrayIcon is the callback message the icon's mouse events come in.
+	wmTrayIcon = 0x8000 + 1 // WM_APP + 1
+
+	nimAdd     = 0
+	nimModify  = 1
+	nimDelete  = 2
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+
+	mfString    = 0x0000
+	mfGrayed    = 0x0001
+	mfChecked   = 0x0008
+	mfPopup     = 0x0010
+	tpmRightBtn = 0x0002
+
+	lrDefaultColor = 0
+	iconVersion    = 0x00030000
+)
+
+var (
+	shell32               = syscall.NewLazyDLL("shell32.dll")
+	user32                = syscall.NewLazyDLL("user32.dll")
+	procShellNotifyIcon   = shell32.NewProc("Shell_NotifyIconW")
+	procCreatePopupMenu   = user32.NewProc("CreatePopupMenu")
+	procAppendMenu        = user32.NewProc("AppendMenuW")
+	procDestroyMenu       = user32.NewProc("DestroyMenu")
+	procTrackPopupMenu    = user32.NewProc("TrackPopupMenu")
+	procGetCursorPos      = user32.NewProc("GetCursorPos")
+	procSetForeground     = user32.NewProc("SetForegroundWindow")
+	procPostMessage       = user32.NewProc("PostMessageW")
+	procCreateIcon        = user32.NewProc("CreateIconFromResourceEx")
+	procDestroyIcon       = user32.NewProc("DestroyIcon")
+	procRegisterWindowMsg = user32.NewProc("RegisterWindowMessageW")
+)
+
+// notifyIconData is NOTIFYICONDATAW.
+type notifyIconData struct {
+	size            uint32
+	hwnd            uintptr
+	id              uint32
+	flags           uint32
+	callbackMessage uint32
+	icon            uintptr
+	tip             [128]uint16
+	state           uint32
+	stateMask       uint32
+	info            [256]uint16
+	version         uint32
+	infoTitle       [64]uint16
+	infoFlags       uint32
+	guid            [16]byte
+	balloonIcon     uintptr
+}
+
+func newDefault() Tray { return &nativeTray{quit: make(chan struct{})} }
+
+// nativeTray is a Tray on Shell_NotifyIcon and a popup menu built afresh
+// from the items each time it opens, so hidden and relabeled items need no
+// bookkeeping in the shell.
+type nativeTray struct {
+	mu      sync.Mutex
+	win     *winmsg.Window
+	icon    uintptr
+	tooltip string
+	items   []*nativeItem
+	byID    map[uint32]*nativeItem
+	nextID  uint32
+	quit    chan struct{}
+	quitted bool
+}
+
+func (t *nativeTray) Run(onReady, onExit func()) {
+	win, err := winmsg.Start(nativeClass)
+	if err != nil {
+		fmt.Println("tray:", err)
+		return
+	}
+	t.mu.Lock()
+	t.win = win
+	t.mu.Unlock()
+	taskbarCreated := registerWindowMessage("TaskbarCreated")
+	win.Handle(func(msg uint32, wParam, lParam uintptr) {
+		switch {
+		case msg == wmTrayIcon && (lParam&0xffff == wmRButtonUp || lParam&0xffff == wmLButtonUp || lParam&0xffff == wmContextMenu):
+			t.showMenu()
+		case msg == wmCommand:
+			t.click(uint32(wParam & 0xffff))
+		case msg == taskbarCreated:
+			// Explorer restarted and forgot the icon.
+			t.notify(nimAdd)
+		}
+	})
+	t.notify(nimAdd)
+	go onReady()
+	<-t.quit
+	t.notify(nimDelete)
+	win.Close()
+	t.mu.Lock()
+	if t.icon != 0 {
+		procDestroyIcon.Call(t.icon)
+		t.icon = 0
+	}
+	t.mu.Unlock()
+	onExit()
+}
+
+func (t *nativeTray) Quit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.quitted {
+		t.quitted = true
+		close(t.quit)
+	}
+}
+
+// SetIcon shows the ICO image closest to the small icon size.
+func (t *nativeTray) SetIcon(ico []byte) {
+	h, err := iconFromICO(ico)
+	if err != nil {
+		fmt.Println("tray: icon:", err)
+		return
+	}
+	t.mu.Lock()
+	old := t.icon
+	t.icon = h
+	t.mu.Unlock()
+	t.notify(nimModify)
+	if old != 0 {
+		procDestroyIcon.Call(old)
+	}
+}
+
+// SetTitle does nothing: the notification area shows no titles.
+func (t *nativeTray) SetTitle(string) {}
+
+func (t *nativeTray) SetTooltip(tooltip string) {
+	t.mu.Lock()
+	t.tooltip = tooltip
+	t.mu.Unlock()
+	t.notify(nimModify)
+}
+
+func (t *nativeTray) AddItem(title, tooltip string) Item {
+	i := t.newItem(title, false)
+	t.mu.Lock()
+	t.items = append(t.items, i)
+	t.mu.Unlock()
+	return i
+}
+
+func (t *nativeTray) AddItemCheckbox(title, tooltip string, checked bool) Item {
+	i := t.newItem(title, checked)
+	t.mu.Lock()
+	t.items = append(t.items, i)
+	t.mu.Unlock()
+	return i
+}
+
+func (t *nativeTray) IconWindow() (string, uint32) { return nativeClass, nativeIconID }
+
+func (t *nativeTray) newItem(title string, checked bool) *nativeItem {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	i := &nativeItem{tray: t, id: t.nextID, title: title, checked: checked, clicked: make(chan struct{}, 1)}
+	if t.byID == nil {
+		t.byID = map[uint32]*nativeItem{}
+	}
+	t.byID[i.id] = i
+	return i
+}
+
+// notify adds, updates or removes the icon; before Run there is no window
+// to attach it to yet.
+func (t *nativeTray) notify(op uintptr) {
+	t.mu.Lock()
+	if t.win == nil {
+		t.mu.Unlock()
+		return
+	}
+	nid := notifyIconData{hwnd: t.win.HWND(), id: nativeIconID, flags: nifMessage | nifTip, callbackMessage: wmTrayIcon}
+	nid.size = uint32(unsafe.Sizeof(nid))
+	if t.icon != 0 {
+		nid.flags |= nifIcon
+		nid.icon = t.icon
+	}
+	tip, _ := syscall.UTF16FromString(t.tooltip)
+	copy(nid.tip[:len(nid.tip)-1], tip)
+	t.mu.Unlock()
+	procShellNotifyIcon.Call(op, uintptr(unsafe.Pointer(&nid)))
+}
+
+// showMenu opens the menu at the cursor. It runs on the window's thread,
+// which TrackPopupMenu needs, and returns once the menu is closed.
+func (t *nativeTray) showMenu() {
+	t.mu.Lock()
+	items := append([]*nativeItem(nil), t.items...)
+	hwnd := t.win.HWND()
+	t.mu.Unlock()
+	menu := buildMenu(items)
+	if menu == 0 {
+		return
+	}
+	defer procDestroyMenu.Call(menu)
+	var pt struct{ x, y int32 }
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+	// Without the foreground the menu doesn't close on a click elsewhere.
+	procSetForeground.Call(hwnd)
+	procTrackPopupMenu.Call(menu, tpmRightBtn, uintptr(pt.x), uintptr(pt.y), 0, hwnd, 0)
+	procPostMessage.Call(hwnd, wmNull, 0, 0)
+}
+
+func (t *nativeTray) click(id uint32) {
+	t.mu.Lock()
+	i := t.byID[id]
+	t.mu.Unlock()
+	if i == nil {
+		return
+	}
+	select {
+	case i.clicked <- struct{}{}:
+	default: // a click is still pending
+	}
+}
+
+// buildMenu creates a popup menu of the visible items, and their submenus,
+// as they are now. DestroyMenu on it destroys the submenus too.
+func buildMenu(items []*nativeItem) uintptr {
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return 0
+	}
+	for _, i := range items {
+		i.mu.Lock()
+		title, checked, disabled, hidden := i.title, i.checked, i.disabled, i.hidden
+		sub := append([]*nativeItem(nil), i.sub...)
+		i.mu.Unlock()
+		if hidden {
+			continue
+		}
+		flags, id := uintptr(mfString), uintptr(i.id)
+		if checked {
+			flags |= mfChecked
+		}
+		if disabled {
+			flags |= mfGrayed
+		}
+		if len(sub) > 0 {
+			if id = buildMenu(sub); id == 0 {
+				continue
+			}
+			flags |= mfPopup
+		}
+		text, _ := syscall.UTF16PtrFromString(title)
+		procAppendMenu.Call(menu, flags, id, uintptr(unsafe.Pointer(text)))
+	}
+	return menu
+}
+
+// iconFromICO creates an icon from the entry of an ICO file nearest 16x16.
+func iconFromICO(ico []byte) (uintptr, error) {
+	if len(ico) < 6 || binary.LittleEndian.Uint16(ico[2:]) != 1 {
+		return 0, fmt.Errorf("not an ICO file")
+	}
+	best, bestSize := -1, 0
+	for n, k := int(binary.LittleEndian.Uint16(ico[4:])), 0; k < n; k++ {
+		e := 6 + 16*k
+		if e+16 > len(ico) {
+			break
+		}
+		w := int(ico[e])
+		if w == 0 {
+			w = 256
+		}
+		if best < 0 || abs(w-16) < abs(bestSize-16) {
+			best, bestSize = e, w
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("ICO file has no images")
+	}
+	size := int(binary.LittleEndian.Uint32(ico[best+8:]))
+	off := int(binary.LittleEndian.Uint32(ico[best+12:]))
+	if off < 0 || size <= 0 || off+size > len(ico) {
+		return 0, fmt.Errorf("ICO image out of range")
+	}
+	h, _, err := procCreateIcon.Call(uintptr(unsafe.Pointer(&ico[off])), uintptr(size), 1, iconVersion, 0, 0, lrDefaultColor)
+	if h == 0 {
+		return 0, fmt.Errorf("CreateIconFromResourceEx: %w", err)
+	}
+	return h, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func registerWindowMessage(name string) uint32 {
+	p, _ := syscall.UTF16PtrFromString(name)
+	r, _, _ := procRegisterWindowMsg.Call(uintptr(unsafe.Pointer(p)))
+	return uint32(r)
+}
+
+// nativeItem is a menu item; the menu reads its state when it opens.
+type nativeItem struct {
+	tray *nativeTray
+	id   uint32
+
+	mu                        sync.Mutex
+	title                     string
+	checked, disabled, hidden bool
+	sub                       []*nativeItem
+	clicked                   chan struct{}
+}
+
+func (i *nativeItem) set(f func()) {
+	i.mu.Lock()
+	f()
+	i.mu.Unlock()
+}
+
+func (i *nativeItem) SetTitle(title string) { i.set(func() { i.title = title }) }
+func (i *nativeItem) Check()                { i.set(func() { i.checked = true }) }
+func (i *nativeItem) Uncheck()              { i.set(func() { i.checked = false }) }
+func (i *nativeItem) Enable()               { i.set(func() { i.disabled = false }) }
+func (i *nativeItem) Disable()              { i.set(func() { i.disabled = true }) }
+func (i *nativeItem) Show()                 { i.set(func() { i.hidden = false }) }
+func (i *nativeItem) Hide()                 { i.set(func() { i.hidden = true }) }
+
+// SetTooltip and SetIcon do nothing: popup menu items have neither.
+func (i *nativeItem) SetTooltip(string) {}
+func (i *nativeItem) SetIcon([]byte)    {}
+
+func (i *nativeItem) Checked() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.checked
+}
+
+func (i *nativeItem) Disabled() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.disabled
+}
+
+func (i *nativeItem) AddSubItem(title, tooltip string) Item {
+	return i.addSub(i.tray.newItem(title, false))
+}
+
+func (i *nativeItem) AddSubItemCheckbox(title, tooltip string, checked bool) Item {
+	return i.addSub(i.tray.newItem(title, checked))
+}
+
+func (i *nativeItem) addSub(s *nativeItem) Item {
+	i.set(func() { i.sub = append(i.sub, s) })
+	return s
+}
+
+func (i *nativeItem) Clicked() <-chan struct{} { return i.clicked }
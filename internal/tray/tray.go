@@ -0,0 +1,76 @@
+// Package tray is the notification area icon and its menu, reduced to what
+// the app needs so the library behind it can be swapped without touching
+// the features. The default implementation, getlantern.go, is built on
+// github.com/getlantern/systray; building with the tray_native tag swaps it
+// for native.go, straight on Shell_NotifyIcon and popup menus. Mock stands
+// in for either in tests of the menu logic.
+package tray
+
+// Item is a menu item. Items can't be removed, only hidden.
+type Item interface {
+	SetTitle(title string)
+	SetTooltip(tooltip string)
+	Check()
+	Uncheck()
+	Checked() bool
+	Enable()
+	Disable()
+	Disabled() bool
+	Show()
+	Hide()
+	SetIcon(icon []byte)
+	// AddSubItem and AddSubItemCheckbox turn the item into a submenu.
+	AddSubItem(title, tooltip string) Item
+	AddSubItemCheckbox(title, tooltip string, checked bool) Item
+	// Clicked fires on every click.
+	Clicked() <-chan struct{}
+}
+
+// Tray is the icon and the top level of its menu.
+type Tray interface {
+	// Run shows the icon and blocks in its message loop, calling onReady
+	// once the icon is up and onExit once Quit was called.
+	Run(onReady, onExit func())
+	Quit()
+	SetIcon(icon []byte)
+	SetTitle(title string)
+	SetTooltip(tooltip string)
+	AddItem(title, tooltip string) Item
+	AddItemCheckbox(title, tooltip string, checked bool) Item
+	// IconWindow names the window class and icon ID the notification icon
+	// is registered with, for asking the shell whether it still shows it;
+	// "" when unknown.
+	IconWindow() (class string, id uint32)
+}
+
+var current = newDefault()
+
+// Use replaces the implementation, such as with a Mock; call it before
+// anything is added.
+func Use(t Tray) { current = t }
+
+// Run shows the icon; see Tray.
+func Run(onReady, onExit func()) { current.Run(onReady, onExit) }
+
+// Quit ends Run.
+func Quit() { current.Quit() }
+
+// SetIcon sets the icon from ICO data.
+func SetIcon(icon []byte) { current.SetIcon(icon) }
+
+// SetTitle sets the title shown next to the icon where the shell has one.
+func SetTitle(title string) { current.SetTitle(title) }
+
+// SetTooltip sets the icon's tooltip.
+func SetTooltip(tooltip string) { current.SetTooltip(tooltip) }
+
+// AddItem appends an item to the menu.
+func AddItem(title, tooltip string) Item { return current.AddItem(title, tooltip) }
+
+// AddItemCheckbox appends a checkable item to the menu.
+func AddItemCheckbox(title, tooltip string, checked bool) Item {
+	return current.AddItemCheckbox(title, tooltip, checked)
+}
+
+// IconWindow identifies the notification icon; see Tray.
+func IconWindow() (class string, id uint32) { return current.IconWindow() }
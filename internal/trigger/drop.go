@@ -0,0 +1,106 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/winmsg"
+)
+
+// dropQueueSize bounds the dropped files waiting to be set; the rest of a
+// bigger drop is ignored.
+const dropQueueSize = 32
+
+var (
+	shell32           = syscall.NewLazyDLL("shell32.dll")
+	procDragQueryFile = shell32.NewProc("DragQueryFileW")
+	procDragFinish    = shell32.NewProc("DragFinish")
+)
+
+// WatchDrops accepts files dropped on win while allow_drag_drop is on and
+// calls apply with each image among them in turn, drop_interval_seconds
+// apart.
+func WatchDrops(ctx context.Context, win *winmsg.Window, live *config.Live, apply func(ref string) error) {
+	dropped := make(chan string, dropQueueSize)
+	win.Handle(func(msg uint32, wParam, _ uintptr) {
+		if msg != winmsg.WMDropFiles {
+			return
+		}
+		// wParam is an HDROP owned by us until DragFinish.
+		defer procDragFinish.Call(wParam)
+		for _, path := range droppedFiles(wParam) {
+			select {
+			case dropped <- path:
+			default:
+				fmt.Println("drag and drop: too many files queued, ignoring", path)
+			}
+		}
+	})
+	changed := live.Changed()
+	accepting := live.Current().AllowDragDrop
+	win.AcceptFiles(accepting)
+
+	var last time.Time
+	for {
+		select {
+		case <-changed:
+			changed = live.Changed()
+			if on := live.Current().AllowDragDrop; on != accepting {
+				accepting = on
+				win.AcceptFiles(on)
+			}
+		case path := <-dropped:
+			cfg := live.Current()
+			if !cfg.AllowDragDrop {
+				continue
+			}
+			if !droppableImage(path) {
+				fmt.Println("drag and drop: not a supported image:", path)
+				continue
+			}
+			if wait := time.Duration(cfg.DropIntervalSeconds)*time.Second - time.Since(last); !last.IsZero() && wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+			fmt.Println("drag and drop: setting", path)
+			if err := apply(path); err != nil {
+				fmt.Printf("drag and drop: %s failed: %v\n", path, err)
+			}
+			last = time.Now()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// droppedFiles lists the paths in an HDROP.
+func droppedFiles(hdrop uintptr) []string {
+	n, _, _ := procDragQueryFile.Call(hdrop, 0xFFFFFFFF, 0, 0)
+	paths := make([]string, 0, n)
+	for i := uintptr(0); i < n; i++ {
+		size, _, _ := procDragQueryFile.Call(hdrop, i, 0, 0)
+		buf := make([]uint16, size+1)
+		procDragQueryFile.Call(hdrop, i, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+		paths = append(paths, syscall.UTF16ToString(buf))
+	}
+	return paths
+}
+
+// droppableImage reports whether path has the extension of a format the
+// imaging package decodes; it is converted to BMP like any download.
+func droppableImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp":
+		return true
+	}
+	return false
+}
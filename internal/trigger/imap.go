@@ -0,0 +1,144 @@
+// Package trigger starts wallpaper changes on outside events.
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/logging"
+)
+
+const (
+	imapDialTimeout = 30 * time.Second
+	// imapIdleRestart re-issues IDLE before servers drop it (RFC 2177 says
+	// they may after 30 minutes).
+	imapIdleRestart = 25 * time.Minute
+	imapMaxBackoff  = 10 * time.Minute
+)
+
+// imapSettings is the part of the config an IMAP session depends on.
+type imapSettings struct {
+	host, username, password, mailbox string
+	port                              int
+}
+
+func imapSettingsOf(c config.Config) imapSettings {
+	return imapSettings{host: c.IMAPHost, port: c.IMAPPort, username: c.IMAPUsername,
+		password: c.IMAPPassword, mailbox: c.IMAPMailbox}
+}
+
+// WatchMail calls change whenever a message arrives in the configured
+// mailbox while change_on_new_email is on. It reconnects with backoff and
+// restarts when the IMAP settings change.
+func WatchMail(ctx context.Context, live *config.Live, change func() error) {
+	backoff := time.Second
+	for {
+		cfg := live.Current()
+		changed := live.Changed()
+		if !cfg.ChangeOnNewEmail || cfg.IMAPHost == "" {
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		settings := imapSettingsOf(cfg)
+		sessCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			// Stop the session when the settings it was started with change.
+			for {
+				select {
+				case <-changed:
+					now := live.Current()
+					if !now.ChangeOnNewEmail || imapSettingsOf(now) != settings {
+						cancel()
+						return
+					}
+					changed = live.Changed()
+				case <-sessCtx.Done():
+					return
+				}
+			}
+		}()
+		err := watchMailbox(sessCtx, settings, change, func() { backoff = time.Second })
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if sessCtx.Err() != nil {
+			continue // settings changed, reconnect now
+		}
+		logging.Warnf("imap", "%v, reconnecting in %s", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, imapMaxBackoff)
+	}
+}
+
+// watchMailbox runs one IMAP session: LOGIN, SELECT, then IDLE until ctx is
+// done or the connection fails. connected runs once the mailbox is selected.
+func watchMailbox(ctx context.Context, s imapSettings, change func() error, connected func()) error {
+	dialer := &net.Dialer{Timeout: imapDialTimeout}
+	c, err := client.DialWithDialerTLS(dialer, net.JoinHostPort(s.host, strconv.Itoa(s.port)), nil)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+	if err := c.Login(s.username, s.password); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	mbox, err := c.Select(s.mailbox, true)
+	if err != nil {
+		return fmt.Errorf("select %s: %w", s.mailbox, err)
+	}
+	exists := mbox.Messages
+	connected()
+	fmt.Printf("imap: watching %s on %s (%d messages)\n", s.mailbox, s.host, exists)
+
+	updates := make(chan client.Update, 8)
+	c.Updates = updates
+	stop := make(chan struct{})
+	idleErr := make(chan error, 1)
+	// Idle restarts IDLE itself before the server's logout timeout and
+	// falls back to polling when the server lacks IDLE.
+	go func() { idleErr <- c.Idle(stop, &client.IdleOptions{LogoutTimeout: imapIdleRestart}) }()
+	for {
+		select {
+		case u := <-updates:
+			mu, ok := u.(*client.MailboxUpdate)
+			if !ok {
+				continue
+			}
+			if n := mu.Mailbox.Messages; n > exists {
+				fmt.Printf("imap: new mail in %s, changing wallpaper\n", s.mailbox)
+				go func() {
+					if err := change(); err != nil {
+						fmt.Println("change on new email failed:", err)
+					}
+				}()
+			}
+			exists = mu.Mailbox.Messages
+		case err := <-idleErr:
+			if err == nil {
+				err = errors.New("idle ended")
+			}
+			return err
+		case <-ctx.Done():
+			close(stop)
+			<-idleErr
+			return ctx.Err()
+		}
+	}
+}
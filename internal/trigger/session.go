@@ -0,0 +1,49 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/winmsg"
+)
+
+// sessionEvents maps WMSessionChange codes to the apply_on values.
+var sessionEvents = map[uintptr]string{
+	winmsg.WTSSessionLock:   "lock",
+	winmsg.WTSSessionUnlock: "unlock",
+}
+
+// WatchSession listens for this session's locks and unlocks on win and
+// calls apply on the one apply_on names, to set a wallpaper a scheduled
+// change left pending.
+func WatchSession(ctx context.Context, win *winmsg.Window, live *config.Live, apply func() error) {
+	if err := win.NotifySession(); err != nil {
+		fmt.Println("session lock events unavailable, pending wallpapers wait for pending_fallback_time:", err)
+		return
+	}
+	events := make(chan string, 1)
+	win.Handle(func(msg uint32, wParam, _ uintptr) {
+		ev, ok := sessionEvents[wParam]
+		if msg != winmsg.WMSessionChange || !ok {
+			return
+		}
+		select {
+		case events <- ev:
+		default: // a lock and unlock in a row; the first will do
+		}
+	})
+	for {
+		select {
+		case ev := <-events:
+			if live.Current().ApplyOn != ev {
+				continue
+			}
+			if err := apply(); err != nil {
+				fmt.Printf("session %s: setting the pending wallpaper failed: %v\n", ev, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package trigger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDroppableImage(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{`C:\Users\me\Pictures\lake.JPG`, true},
+		{`C:\Users\me\Pictures\lake.jpeg`, true},
+		{`C:\Users\me\Pictures\lake.png`, true},
+		{`C:\Users\me\Pictures\lake.bmp`, true},
+		{`C:\Users\me\Pictures\lake.webp`, false},
+		{`C:\Users\me\Pictures\notes.txt`, false},
+		{`C:\Users\me\Pictures\lake`, false},
+	}
+	for _, tt := range tests {
+		if got := droppableImage(tt.path); got != tt.want {
+			t.Errorf("droppableImage(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReadOverride(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string // "" for an error
+		rel  bool   // want is relative to the drive root
+	}{
+		{"url", `{"url": "https://example.com/a.jpg"}`, "https://example.com/a.jpg", false},
+		{"url without http", `{"url": "ftp://example.com/a.jpg"}`, "", false},
+		{"relative path", `{"path": "walls/a.jpg"}`, filepath.Join("walls", "a.jpg"), true},
+		{"neither", `{}`, "", false},
+		{"bad json", `{`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.WriteFile(filepath.Join(root, OverrideFileName), []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readOverride(root)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("readOverride = %q, want an error", got)
+				}
+				return
+			}
+			want := tt.want
+			if tt.rel {
+				want = filepath.Join(root, want)
+			}
+			if err != nil || got != want {
+				t.Errorf("readOverride = %q, %v; want %q", got, err, want)
+			}
+		})
+	}
+}
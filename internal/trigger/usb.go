@@ -0,0 +1,135 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/winmsg"
+)
+
+// OverrideFileName is looked up in the root of every inserted USB drive.
+const OverrideFileName = "wallpaper_override.json"
+
+const (
+	dbtDeviceArrival = 0x8000
+	dbtDevtypVolume  = 0x00000002
+	driveRemovable   = 2
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procGetDriveType = kernel32.NewProc("GetDriveTypeW")
+)
+
+// devBroadcastVolume is DEV_BROADCAST_VOLUME.
+type devBroadcastVolume struct {
+	size       uint32
+	deviceType uint32
+	reserved   uint32
+	unitMask   uint32
+	flags      uint16
+}
+
+// usbOverride is the content of wallpaper_override.json: an image URL or a
+// path, relative paths being relative to the drive root.
+type usbOverride struct {
+	URL  string `json:"url"`
+	Path string `json:"path"`
+}
+
+// WatchUSB listens for drive arrivals on win and, while allow_usb_override
+// is on, calls apply with the image named by the drive's
+// wallpaper_override.json.
+func WatchUSB(ctx context.Context, win *winmsg.Window, live *config.Live, apply func(ref string) error) {
+	arrived := make(chan string, 4)
+	win.Handle(func(msg uint32, wParam, lParam uintptr) {
+		if msg != winmsg.WMDeviceChange || wParam != dbtDeviceArrival || lParam == 0 {
+			return
+		}
+		// lParam points at a DEV_BROADCAST_HDR owned by the system for the
+		// duration of the call.
+		hdr := (*devBroadcastVolume)(*(*unsafe.Pointer)(unsafe.Pointer(&lParam)))
+		if hdr.deviceType != dbtDevtypVolume {
+			return
+		}
+		for _, root := range removableRoots(hdr.unitMask) {
+			select {
+			case arrived <- root:
+			default: // a burst of arrivals; drop extras
+			}
+		}
+	})
+	for {
+		select {
+		case root := <-arrived:
+			if !live.Current().AllowUSBOverride {
+				continue
+			}
+			ref, err := readOverride(root)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("usb override on %s: %v\n", root, err)
+				continue
+			}
+			fmt.Printf("usb override on %s: setting %s\n", root, ref)
+			if err := apply(ref); err != nil {
+				fmt.Printf("usb override on %s failed: %v\n", root, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// removableRoots turns a DEV_BROADCAST_VOLUME unit mask into roots such as
+// `E:\` of removable drives.
+func removableRoots(mask uint32) []string {
+	var roots []string
+	for i := 0; i < 26; i++ {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		root := string(rune('A'+i)) + `:\`
+		p, _ := syscall.UTF16PtrFromString(root)
+		if t, _, _ := procGetDriveType.Call(uintptr(unsafe.Pointer(p))); t == driveRemovable {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// readOverride returns the URL or absolute path the drive's override file
+// names.
+func readOverride(root string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(root, OverrideFileName))
+	if err != nil {
+		return "", err
+	}
+	var o usbOverride
+	if err := json.Unmarshal(b, &o); err != nil {
+		return "", fmt.Errorf("%s: %w", OverrideFileName, err)
+	}
+	switch {
+	case o.URL != "":
+		if !strings.HasPrefix(o.URL, "https://") && !strings.HasPrefix(o.URL, "http://") {
+			return "", fmt.Errorf("%s: url must be http(s)", OverrideFileName)
+		}
+		return o.URL, nil
+	case o.Path != "":
+		if filepath.IsAbs(o.Path) {
+			return o.Path, nil
+		}
+		return filepath.Join(root, o.Path), nil
+	}
+	return "", fmt.Errorf("%s has neither url nor path", OverrideFileName)
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// menuSafeLen is the longest menu label shown in full; longer ones are cut
+// with an ellipsis so the menu doesn't grow wider than the screen.
+const menuSafeLen = 64
+
+// ItemState is a menu item state that is spelled out in the item's label,
+// because screen readers don't reliably announce tray menu check marks.
+type ItemState int
+
+const (
+	StateNone ItemState = iota
+	// StateSelected marks the chosen item of a group, such as a fit mode.
+	StateSelected
+	// StateRunning marks an action in progress.
+	StateRunning
+	// StateQueued marks an action that runs again after the current one.
+	StateQueued
+)
+
+// Locale is a language of the message catalog.
+type Locale string
+
+const (
+	English Locale = "en"
+	Russian Locale = "ru"
+)
+
+// Message names a menu label in the catalog.
+type Message string
+
+const (
+	MsgForceChange      Message = "force_change"
+	MsgRefetchFavorites Message = "refetch_favorites"
+	MsgFavorite         Message = "favorite"
+	// MsgPreviewReady takes the candidate's title.
+	MsgPreviewReady Message = "preview_ready"
+	// MsgLetAppsChange takes the names of the other wallpaper apps.
+	MsgLetAppsChange Message = "let_apps_change"
+)
+
+// catalog holds the menu labels of each locale. Every locale has every
+// message; English is the fallback for a display language without one.
+var catalog = map[Locale]map[Message]string{
+	English: {
+		MsgForceChange:      "Force change now",
+		MsgRefetchFavorites: "Re-fetch favorites at current resolution",
+		MsgFavorite:         "Favorite",
+		MsgPreviewReady:     "New wallpaper ready: %s",
+		MsgLetAppsChange:    "Let %s change the wallpaper",
+	},
+	Russian: {
+		MsgForceChange:      "Сменить обои сейчас",
+		MsgRefetchFavorites: "Перекачать избранное под текущий экран",
+		MsgFavorite:         "В избранном",
+		MsgPreviewReady:     "Новые обои готовы: %s",
+		MsgLetAppsChange:    "Разрешить %s менять обои",
+	},
+}
+
+// stateCatalog holds the suffix each state adds to a label, by locale.
+var stateCatalog = map[Locale]map[ItemState]string{
+	English: {
+		StateSelected: "✓",
+		StateRunning:  "(running…)",
+		StateQueued:   "(running, +1 queued)",
+	},
+	Russian: {
+		StateSelected: "✓",
+		StateRunning:  "(выполняется…)",
+		StateQueued:   "(ещё одна в очереди)",
+	},
+}
+
+// locale is the catalog labels come from, picked from the Windows display
+// language.
+var locale = userLocale()
+
+func userLocale() Locale {
+	langs, err := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME)
+	if err != nil || len(langs) == 0 {
+		return English
+	}
+	return localeFor(langs[0])
+}
+
+// localeFor maps a language tag such as "ru-RU" to its catalog.
+func localeFor(tag string) Locale {
+	lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	if _, ok := catalog[Locale(lang)]; ok {
+		return Locale(lang)
+	}
+	return English
+}
+
+// Text returns msg in the user's language.
+func Text(msg Message) string {
+	if s, ok := catalog[locale][msg]; ok {
+		return s
+	}
+	return catalog[English][msg]
+}
+
+// Label renders a menu item's text: msg followed by state's suffix, in the
+// user's language.
+func Label(msg Message, state ItemState) string {
+	return stateLabel(Text(msg), state)
+}
+
+// Labelf is Label for a message taking arguments, such as a title.
+func Labelf(msg Message, state ItemState, args ...any) string {
+	return stateLabel(fmt.Sprintf(Text(msg), args...), state)
+}
+
+// stateLabel is label followed by state's suffix, shortened so the whole
+// fits in menuSafeLen runes. The suffix is never cut.
+func stateLabel(label string, state ItemState) string {
+	suffix, ok := stateCatalog[locale][state]
+	if !ok {
+		suffix = stateCatalog[English][state]
+	}
+	if suffix != "" {
+		suffix = " " + suffix
+	}
+	r, room := []rune(label), menuSafeLen-len([]rune(suffix))
+	if len(r) > room {
+		label = string(r[:room-1]) + "…"
+	}
+	return label + suffix
+}
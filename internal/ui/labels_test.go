@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// withLocale renders the test's labels in l.
+func withLocale(t *testing.T, l Locale) {
+	saved := locale
+	locale = l
+	t.Cleanup(func() { locale = saved })
+}
+
+var states = []ItemState{StateNone, StateSelected, StateRunning, StateQueued}
+
+// examples are arguments for the messages that take some, long enough to
+// need shortening.
+var examples = map[Message][]any{
+	MsgPreviewReady:  {"Закат над Байкалом, снятый с вершины горы Черского в январе"},
+	MsgLetAppsChange: {"Wallpaper Engine, Lively Wallpaper, DisplayFusion"},
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for l, msgs := range catalog {
+		for msg := range catalog[English] {
+			if msgs[msg] == "" {
+				t.Errorf("%s has no %s", l, msg)
+			}
+			if a, b := strings.Count(msgs[msg], "%"), strings.Count(catalog[English][msg], "%"); a != b {
+				t.Errorf("%s %s takes %d arguments, English %d", l, msg, a, b)
+			}
+		}
+		for _, st := range states[1:] {
+			if stateCatalog[l][st] == "" {
+				t.Errorf("%s has no suffix for state %d", l, st)
+			}
+		}
+	}
+}
+
+// TestLabelStates renders every message in every state and locale and
+// checks the state is spelled out in full and the label fits the menu.
+func TestLabelStates(t *testing.T) {
+	for l := range catalog {
+		t.Run(string(l), func(t *testing.T) {
+			withLocale(t, l)
+			for msg := range catalog[l] {
+				for _, st := range states {
+					got := Labelf(msg, st, examples[msg]...)
+					if n := utf8.RuneCountInString(got); n > menuSafeLen {
+						t.Errorf("%s in state %d is %d runes: %q", msg, st, n, got)
+					}
+					if suffix := stateCatalog[l][st]; !strings.HasSuffix(got, suffix) {
+						t.Errorf("%s in state %d = %q, want it to end in %q", msg, st, got, suffix)
+					}
+					if examples[msg] == nil && !strings.HasPrefix(got, Text(msg)) {
+						t.Errorf("%s in state %d was cut: %q", msg, st, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLabelShortens(t *testing.T) {
+	withLocale(t, Russian)
+	long := strings.Repeat("обои ", 20)
+	got := stateLabel(long, StateQueued)
+	if n := utf8.RuneCountInString(got); n != menuSafeLen {
+		t.Errorf("%q is %d runes, want exactly %d", got, n, menuSafeLen)
+	}
+	if !strings.HasSuffix(got, "… "+stateCatalog[Russian][StateQueued]) {
+		t.Errorf("%q does not end in the ellipsis and the whole suffix", got)
+	}
+}
+
+func TestLabelLocales(t *testing.T) {
+	tests := []struct {
+		locale Locale
+		want   string
+	}{
+		{English, "Force change now (running…)"},
+		{Russian, "Сменить обои сейчас (выполняется…)"},
+	}
+	for _, tt := range tests {
+		withLocale(t, tt.locale)
+		if got := Label(MsgForceChange, StateRunning); got != tt.want {
+			t.Errorf("%s: Label = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleFor(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Locale
+	}{
+		{"ru-RU", Russian},
+		{"ru", Russian},
+		{"RU-ru", Russian},
+		{"en-US", English},
+		{"uk-UA", English},
+		{"", English},
+	}
+	for _, tt := range tests {
+		if got := localeFor(tt.tag); got != tt.want {
+			t.Errorf("localeFor(%q) = %s, want %s", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/tray"
+)
+
+// FitModeMenu is the "Fit mode" submenu, one checkbox per mode. The
+// checked one also says so in its label.
+type FitModeMenu struct {
+	items map[string]MenuItem
+	// Clicked receives the mode the user picked.
+	Clicked chan string
+}
+
+// AddFitModeMenu adds one checkbox per fit mode under parent, checking current.
+func AddFitModeMenu(parent MenuItem, current string) *FitModeMenu {
+	m := &FitModeMenu{items: map[string]MenuItem{}, Clicked: make(chan string)}
+	for _, mode := range config.FitModes {
+		item := parent.AddSubItemCheckbox(fitModeLabel(mode, current), "Set fit mode to "+mode, mode == current)
+		m.items[mode] = item
+		go func(mode string) {
+			for range item.Clicked() {
+				m.Clicked <- mode
+			}
+		}(mode)
+	}
+	return m
+}
+
+// Check moves the check mark to mode.
+func (m *FitModeMenu) Check(mode string) {
+	for name, item := range m.items {
+		item.SetTitle(fitModeLabel(name, mode))
+		if name == mode {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+}
+
+func fitModeLabel(mode, current string) string {
+	if mode == current {
+		return stateLabel(mode, StateSelected)
+	}
+	return stateLabel(mode, StateNone)
+}
+
+// maxMonitorItems is how many monitors the "Target monitor" submenu can list.
+// Menu items can't be deleted, so a fixed set is relabeled and hidden instead.
+const maxMonitorItems = 8
+
+// MonitorMenu is the "Target monitor" submenu.
+type MonitorMenu struct {
+	mu    sync.Mutex
+	items []MenuItem
+	ids   []string // monitor ID shown by each item, "" when hidden
+	// Clicked receives the ID of the monitor the user picked.
+	Clicked chan string
+}
+
+// AddMonitorMenu adds the item pool under parent; call Update to fill it.
+func AddMonitorMenu(parent MenuItem) *MonitorMenu {
+	m := &MonitorMenu{Clicked: make(chan string), ids: make([]string, maxMonitorItems)}
+	for i := 0; i < maxMonitorItems; i++ {
+		item := parent.AddSubItemCheckbox("", "Size downloads for this monitor", false)
+		item.Hide()
+		m.items = append(m.items, item)
+		go func(i int) {
+			for range item.Clicked() {
+				m.mu.Lock()
+				id := m.ids[i]
+				m.mu.Unlock()
+				if id != "" {
+					m.Clicked <- id
+				}
+			}
+		}(i)
+	}
+	return m
+}
+
+// Update lists monitors and checks the one that is the current target.
+func (m *MonitorMenu) Update(monitors []display.Monitor, target string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, item := range m.items {
+		if i >= len(monitors) {
+			m.ids[i] = ""
+			item.Hide()
+			continue
+		}
+		m.ids[i] = monitors[i].ID
+		if monitors[i].ID == target {
+			item.SetTitle(stateLabel(monitors[i].Label(), StateSelected))
+			item.Check()
+		} else {
+			item.SetTitle(stateLabel(monitors[i].Label(), StateNone))
+			item.Uncheck()
+		}
+		item.Show()
+	}
+}
+
+// HistoryMenu is the "History" submenu. Its entries are only created when
+// the user asks for them, so a long history doesn't slow down startup.
+type HistoryMenu struct {
+	parent MenuItem
+
+	mu    sync.Mutex
+	items []MenuItem
+	files []string // history file shown by each item, "" when hidden
+	// Load fires when the user asks to see recent wallpapers.
+	Load <-chan struct{}
+	// Clicked receives the history file the user picked.
+	Clicked chan string
+}
+
+// AddHistoryMenu adds the submenu with just its "Show recent" item.
+func AddHistoryMenu(parent MenuItem) *HistoryMenu {
+	show := parent.AddSubItem("Show recent", "List the most recent wallpapers")
+	return &HistoryMenu{parent: parent, Load: show.Clicked(), Clicked: make(chan string)}
+}
+
+// Populate lists entries below "Show recent", creating items on first use
+// and hiding the ones not needed.
+func (m *HistoryMenu) Populate(entries []history.Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.items) < len(entries) {
+		i := len(m.items)
+		item := m.parent.AddSubItem("", "Set this wallpaper again")
+		m.items = append(m.items, item)
+		m.files = append(m.files, "")
+		go func() {
+			for range item.Clicked() {
+				m.mu.Lock()
+				file := m.files[i]
+				m.mu.Unlock()
+				if file != "" {
+					m.Clicked <- file
+				}
+			}
+		}()
+	}
+	for i, item := range m.items {
+		if i >= len(entries) {
+			m.files[i] = ""
+			item.Hide()
+			continue
+		}
+		m.files[i] = entries[i].File
+		item.SetTitle(stateLabel(entries[i].Label(), StateNone))
+		item.Show()
+	}
+}
+
+// PreviewMenu offers a downloaded wallpaper with Apply and Skip items,
+// hidden while nothing is pending.
+type PreviewMenu struct {
+	mu     sync.Mutex // one prompt at a time
+	header MenuItem
+	apply  MenuItem
+	skip   MenuItem
+}
+
+// AddPreviewMenu adds the hidden preview items at the top level.
+func AddPreviewMenu() *PreviewMenu {
+	m := &PreviewMenu{
+		header: tray.AddItem("", "Open the new wallpaper"),
+		apply:  tray.AddItem("    Apply", "Set this wallpaper now"),
+		skip:   tray.AddItem("    Skip", "Download another one"),
+	}
+	m.hide()
+	return m
+}
+
+func (m *PreviewMenu) hide() {
+	m.header.Hide()
+	m.apply.Hide()
+	m.skip.Hide()
+}
+
+// Ask shows the image at path and waits for Apply or Skip. It reports true
+// for Apply and when nobody answers within timeout.
+func (m *PreviewMenu) Ask(path, title string, timeout time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if title == "" {
+		title = "untitled"
+	}
+	m.header.SetTitle(Labelf(MsgPreviewReady, StateNone, title))
+	m.header.Show()
+	m.apply.Show()
+	m.skip.Show()
+	defer m.hide()
+	ShowMessage("New wallpaper ready", fmt.Sprintf("%s - Apply or Skip from the tray menu, applied automatically in %s", title, timeout))
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-m.header.Clicked():
+			if err := exec.Command("rundll32", "url.dll,FileProtocolHandler", path).Start(); err != nil {
+				fmt.Println("failed to open preview:", err)
+			}
+		case <-m.apply.Clicked():
+			return true
+		case <-m.skip.Clicked():
+			return false
+		case <-deadline:
+			return true
+		}
+	}
+}
+
+// ConflictMenu is the item offering respect_external_changes while another
+// wallpaper app is running, hidden otherwise.
+type ConflictMenu struct {
+	item MenuItem
+	// Clicked fires when the user lets the other app win.
+	Clicked <-chan struct{}
+}
+
+// AddConflictMenu adds the hidden conflict item at the top level.
+func AddConflictMenu() *ConflictMenu {
+	item := tray.AddItem("", "Skip scheduled changes while another app's wallpaper is showing")
+	item.Hide()
+	return &ConflictMenu{item: item, Clicked: item.Clicked()}
+}
+
+// Offer shows the item naming apps; no apps hides it.
+func (m *ConflictMenu) Offer(apps []string) {
+	if len(apps) == 0 {
+		m.item.Hide()
+		return
+	}
+	m.item.SetTitle(Labelf(MsgLetAppsChange, StateNone, strings.Join(apps, ", ")))
+	m.item.Show()
+}
+
+// FavoriteMenu is the checkbox marking the current wallpaper as a
+// favorite.
+type FavoriteMenu struct {
+	item MenuItem
+	// Clicked fires when the user toggles it.
+	Clicked <-chan struct{}
+}
+
+// AddFavoriteMenu adds the unchecked favorite item at the top level.
+func AddFavoriteMenu() *FavoriteMenu {
+	item := tray.AddItemCheckbox(favoriteLabel(false), "Keep the current wallpaper in the favorites", false)
+	return &FavoriteMenu{item: item, Clicked: item.Clicked()}
+}
+
+// Check shows whether the current wallpaper is a favorite.
+func (m *FavoriteMenu) Check(favorite bool) {
+	m.item.SetTitle(favoriteLabel(favorite))
+	if favorite {
+		m.item.Check()
+	} else {
+		m.item.Uncheck()
+	}
+}
+
+func favoriteLabel(favorite bool) string {
+	if favorite {
+		return Label(MsgFavorite, StateSelected)
+	}
+	return Label(MsgFavorite, StateNone)
+}
@@ -0,0 +1,246 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/display"
+	"wallpaper-changer/internal/history"
+	"wallpaper-changer/internal/tray"
+)
+
+// useMock has the menus built on a fresh tray.Mock for one test.
+func useMock(t *testing.T) *tray.Mock {
+	t.Helper()
+	m := tray.NewMock()
+	tray.Use(m)
+	return m
+}
+
+// receive returns the next value from ch, failing the test after a second.
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		var zero T
+		t.Fatal("nothing received")
+		return zero
+	}
+}
+
+// visible returns the titles of the items not hidden.
+func visible(items []*tray.MockItem) []string {
+	var titles []string
+	for _, i := range items {
+		if !i.Hidden() {
+			titles = append(titles, i.Title())
+		}
+	}
+	return titles
+}
+
+func TestFitModeMenu(t *testing.T) {
+	mock := useMock(t)
+	parent := mock.AddItem("Fit mode", "").(*tray.MockItem)
+	m := AddFitModeMenu(parent, "fill")
+	items := parent.Sub()
+	if len(items) != len(config.FitModes) {
+		t.Fatalf("%d items, want one per fit mode", len(items))
+	}
+	checked := func() []string {
+		var modes []string
+		for i, item := range items {
+			if item.Checked() {
+				modes = append(modes, config.FitModes[i])
+				if item.Title() != stateLabel(config.FitModes[i], StateSelected) {
+					t.Errorf("checked item labelled %q", item.Title())
+				}
+			}
+		}
+		return modes
+	}
+	if got := checked(); len(got) != 1 || got[0] != "fill" {
+		t.Fatalf("checked %v, want fill", got)
+	}
+
+	pick := config.FitModes[len(config.FitModes)-1]
+	items[len(items)-1].Click()
+	if got := receive(t, m.Clicked); got != pick {
+		t.Errorf("Clicked = %q, want %q", got, pick)
+	}
+	m.Check(pick)
+	if got := checked(); len(got) != 1 || got[0] != pick {
+		t.Errorf("after Check(%s) checked %v", pick, got)
+	}
+}
+
+func TestMonitorMenu(t *testing.T) {
+	mock := useMock(t)
+	parent := mock.AddItem("Target monitor", "").(*tray.MockItem)
+	m := AddMonitorMenu(parent)
+	items := parent.Sub()
+	if got := visible(items); len(got) != 0 {
+		t.Fatalf("visible before Update: %v", got)
+	}
+
+	monitors := []display.Monitor{
+		{ID: `\\?\DISPLAY#DEL40F3#1`, Name: "Left", Device: `\\.\DISPLAY1`, Width: 1920, Height: 1080},
+		{ID: `\\?\DISPLAY#GSM5B09#2`, Name: "Right", Device: `\\.\DISPLAY2`, Width: 2560, Height: 1440},
+	}
+	m.Update(monitors, monitors[1].ID)
+	if got := visible(items); len(got) != 2 {
+		t.Fatalf("visible = %v, want both monitors", got)
+	}
+	if items[0].Checked() || !items[1].Checked() {
+		t.Error("the target monitor is not the one checked")
+	}
+	items[0].Click()
+	if got := receive(t, m.Clicked); got != monitors[0].ID {
+		t.Errorf("Clicked = %q, want %q", got, monitors[0].ID)
+	}
+
+	m.Update(monitors[:1], monitors[0].ID)
+	if got := visible(items); len(got) != 1 || !items[1].Hidden() {
+		t.Errorf("visible after unplugging = %v, want only the first", got)
+	}
+	items[1].Click() // hidden: the user can't click it
+	select {
+	case id := <-m.Clicked:
+		t.Errorf("a hidden item sent %q", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestHistoryMenu(t *testing.T) {
+	mock := useMock(t)
+	parent := mock.AddItem("History", "").(*tray.MockItem)
+	m := AddHistoryMenu(parent)
+	if got := visible(parent.Sub()); len(got) != 1 || got[0] != "Show recent" {
+		t.Fatalf("before loading: %v, want just Show recent", got)
+	}
+	parent.Sub()[0].Click()
+	receive(t, m.Load)
+
+	day := time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)
+	entries := []history.Entry{
+		{File: "a.jpg", Title: "Dunes", Source: "wallscloud", Added: day},
+		{File: "b.jpg", Title: "Harbour", Source: "wallscloud", Added: day.Add(time.Hour)},
+		{File: "c.jpg", Title: "Forest", Source: "wallscloud", Added: day.Add(2 * time.Hour)},
+	}
+	m.Populate(entries)
+	if got := visible(parent.Sub()); len(got) != 4 || !strings.Contains(got[2], "Harbour") {
+		t.Fatalf("after Populate: %v", got)
+	}
+	parent.Sub()[2].Click()
+	if got := receive(t, m.Clicked); got != "b.jpg" {
+		t.Errorf("Clicked = %q, want b.jpg", got)
+	}
+
+	m.Populate(entries[:1])
+	if got := visible(parent.Sub()); len(got) != 2 {
+		t.Errorf("after a shorter Populate: %v, want Show recent and one entry", got)
+	}
+	if n := len(parent.Sub()); n != 4 {
+		t.Errorf("%d items, want Show recent and the three entries reused", n)
+	}
+}
+
+func TestPreviewMenu(t *testing.T) {
+	suppress(t, true) // keep the prompt's message out of the test output
+	tests := []struct {
+		name  string
+		click int // index of the item to click: 1 apply, 2 skip; -1 none
+		want  bool
+	}{
+		{"apply", 1, true},
+		{"skip", 2, false},
+		{"no answer", -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := useMock(t)
+			m := AddPreviewMenu()
+			items := mock.Items()
+			if got := visible(items); len(got) != 0 {
+				t.Fatalf("visible while nothing is pending: %v", got)
+			}
+			answer := make(chan bool, 1)
+			go func() { answer <- m.Ask("preview.jpg", "Dunes", 200*time.Millisecond) }()
+			for deadline := time.Now().Add(time.Second); items[0].Hidden(); time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("the preview never showed")
+				}
+			}
+			if !strings.Contains(items[0].Title(), "Dunes") {
+				t.Errorf("header = %q, want the title", items[0].Title())
+			}
+			if tt.click >= 0 {
+				items[tt.click].Click()
+			}
+			if got := receive(t, answer); got != tt.want {
+				t.Errorf("Ask = %v, want %v", got, tt.want)
+			}
+			if got := visible(items); len(got) != 0 {
+				t.Errorf("visible after answering: %v", got)
+			}
+		})
+	}
+}
+
+func TestConflictMenu(t *testing.T) {
+	mock := useMock(t)
+	m := AddConflictMenu()
+	item := mock.Items()[0]
+	if !item.Hidden() {
+		t.Fatal("shown with no conflicting app")
+	}
+	m.Offer([]string{"Wallpaper Engine", "DisplayFusion"})
+	if item.Hidden() || !strings.Contains(item.Title(), "Wallpaper Engine, DisplayFusion") {
+		t.Errorf("offer shows %q, hidden %v", item.Title(), item.Hidden())
+	}
+	item.Click()
+	receive(t, m.Clicked)
+	m.Offer(nil)
+	if !item.Hidden() {
+		t.Error("still shown once the other app is gone")
+	}
+}
+
+func TestFavoriteMenu(t *testing.T) {
+	mock := useMock(t)
+	m := AddFavoriteMenu()
+	item := mock.Items()[0]
+	for _, fav := range []bool{true, false} {
+		m.Check(fav)
+		if item.Checked() != fav || item.Title() != favoriteLabel(fav) {
+			t.Errorf("Check(%v): checked %v, title %q", fav, item.Checked(), item.Title())
+		}
+	}
+}
+
+func TestTrayStatus(t *testing.T) {
+	mock := useMock(t)
+	MarkReady()
+	t.Cleanup(func() {
+		trayMu.Lock()
+		trayReady = false
+		trayMu.Unlock()
+		ClearError("test")
+	})
+	SetError("test", "download failed")
+	if !strings.HasSuffix(mock.Title(), "(!)") || !strings.Contains(mock.Tooltip(), "download failed") {
+		t.Errorf("with an error: title %q, tooltip %q", mock.Title(), mock.Tooltip())
+	}
+	ClearError("test")
+	if mock.Title() != trayTitle {
+		t.Errorf("title once cleared = %q", mock.Title())
+	}
+	SetError("test", strings.Repeat("long ", 100))
+	if n := len([]rune(mock.Tooltip())); n > maxTooltipLen {
+		t.Errorf("tooltip of %d runes, want at most %d", n, maxTooltipLen)
+	}
+}
@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wallpaper-changer/internal/config"
+	"wallpaper-changer/internal/setter"
+)
+
+// notifyPollInterval is how often WatchNotifications checks whether
+// suppression has lifted.
+const notifyPollInterval = 30 * time.Second
+
+// errSuppressed keys the tray error badge standing in for errors raised
+// while notifications were suppressed.
+const errSuppressed = "suppressed"
+
+// quietTally counts what happened while notifications were suppressed, for
+// the one summary shown afterwards instead of a burst of stale messages.
+type quietTally struct {
+	mu      sync.Mutex
+	changes int
+	errors  int
+}
+
+var quiet quietTally
+
+// suppressed is setter.NotificationsSuppressed; tests replace it.
+var suppressed = setter.NotificationsSuppressed
+
+// Notification events, the keys of config "notifications".
+const (
+	EventSuccess         = "success"
+	EventFailure         = "failure"
+	EventOfflineFallback = "offline_fallback"
+	EventRateLimited     = "rate_limited"
+	EventUpdateAvailable = "update_available"
+	EventSourceBlocked   = "source_blocked"
+)
+
+// eventNouns name the events in the daily summary, singular and plural.
+var eventNouns = map[string][2]string{
+	EventSuccess:         {"wallpaper change", "wallpaper changes"},
+	EventFailure:         {"failed change", "failed changes"},
+	EventOfflineFallback: {"fallback to history", "fallbacks to history"},
+	EventRateLimited:     {"rate-limited source", "rate-limited sources"},
+	EventUpdateAvailable: {"update notice", "update notices"},
+	EventSourceBlocked:   {"blocked source warning", "blocked source warnings"},
+}
+
+// dispatcher routes notifications by the per-event preferences in config
+// "notifications" and holds the "daily_summary" ones until the summary.
+type dispatcher struct {
+	mu      sync.Mutex
+	live    *config.Live // nil until WatchNotifications starts: show all
+	held    map[string]int
+	shownOn string // date of the last summary
+}
+
+var notifications dispatcher
+
+// route reports whether a notification of event is shown now; otherwise
+// it is dropped or held for the summary.
+func (d *dispatcher) route(event string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.live == nil {
+		return true
+	}
+	switch d.live.Current().NotificationMode(event) {
+	case "never":
+		return false
+	case "daily_summary":
+		if d.held == nil {
+			d.held = map[string]int{}
+		}
+		d.held[event]++
+		return false
+	}
+	return true
+}
+
+// summary returns the held events and resets them once now is past the
+// summary time and no summary was shown today; "" otherwise or when
+// nothing was held.
+func (d *dispatcher) summary(now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.live == nil {
+		return ""
+	}
+	hour, min := d.live.Current().SummaryClock()
+	today := now.Format(time.DateOnly)
+	if d.shownOn == today || now.Before(time.Date(now.Year(), now.Month(), now.Day(), hour, min, 0, 0, now.Location())) {
+		return ""
+	}
+	d.shownOn = today
+	var parts []string
+	for _, event := range config.NotificationEvents {
+		switch n := d.held[event]; n {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+eventNouns[event][0])
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", n, eventNouns[event][1]))
+		}
+	}
+	d.held = nil
+	return strings.Join(parts, ", ")
+}
+
+// Notify shows a message about event unless the notification preferences
+// drop it or hold it for the daily summary.
+func Notify(event, title, msg string) {
+	if !notifications.route(event) {
+		fmt.Println(title+" (not shown per notification preferences):", msg)
+		return
+	}
+	ShowMessage(title, msg)
+}
+
+// NotifyError is Notify for failures, shown with ShowError.
+func NotifyError(event, msg string) {
+	if !notifications.route(event) {
+		fmt.Println("Error (not shown per notification preferences):", msg)
+		return
+	}
+	ShowError(msg)
+}
+
+func (q *quietTally) change() {
+	q.mu.Lock()
+	q.changes++
+	q.mu.Unlock()
+}
+
+func (q *quietTally) error() {
+	q.mu.Lock()
+	q.errors++
+	q.mu.Unlock()
+}
+
+// take returns the summary of the tally and resets it; "" when nothing
+// happened.
+func (q *quietTally) take() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var parts []string
+	switch q.changes {
+	case 0:
+	case 1:
+		parts = append(parts, "1 wallpaper change")
+	default:
+		parts = append(parts, fmt.Sprintf("%d wallpaper changes", q.changes))
+	}
+	switch q.errors {
+	case 0:
+	case 1:
+		parts = append(parts, "1 error")
+	default:
+		parts = append(parts, fmt.Sprintf("%d errors", q.errors))
+	}
+	q.changes, q.errors = 0, 0
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " and ") + " happened while you were busy"
+}
+
+// NoteChange records a wallpaper change; while notifications are
+// suppressed it is counted for the summary.
+func NoteChange() {
+	if suppressed() {
+		quiet.change()
+	}
+}
+
+// WatchNotifications shows the summary of suppressed notifications once
+// Focus Assist or a full-screen app lets them through again, and the daily
+// summary of held ones at notification_summary_time. Notifications are
+// routed by live's preferences from then on.
+func WatchNotifications(ctx context.Context, live *config.Live) {
+	notifications.mu.Lock()
+	notifications.live = live
+	notifications.mu.Unlock()
+	t := time.NewTicker(notifyPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+		pollNotifications(time.Now())
+	}
+}
+
+// pollNotifications is one check of WatchNotifications.
+func pollNotifications(now time.Time) {
+	if suppressed() {
+		return
+	}
+	if summary := quiet.take(); summary != "" {
+		ClearError(errSuppressed)
+		ShowMessage("While you were busy", summary)
+	}
+	if summary := notifications.summary(now); summary != "" {
+		ShowMessage("Daily summary", summary)
+	}
+}
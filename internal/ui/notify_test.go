@@ -0,0 +1,194 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"wallpaper-changer/internal/config"
+)
+
+// suppress replaces the Focus Assist check for one test; the returned func
+// changes its answer.
+func suppress(t *testing.T, on bool) func(bool) {
+	t.Helper()
+	old := suppressed
+	suppressed = func() bool { return on }
+	t.Cleanup(func() {
+		suppressed = old
+		quiet.take()
+		ClearError(errSuppressed)
+	})
+	return func(v bool) { on = v }
+}
+
+func TestQuietTallySummary(t *testing.T) {
+	tests := []struct {
+		changes, errors int
+		want            string
+	}{
+		{0, 0, ""},
+		{1, 0, "1 wallpaper change happened while you were busy"},
+		{2, 0, "2 wallpaper changes happened while you were busy"},
+		{0, 1, "1 error happened while you were busy"},
+		{3, 2, "3 wallpaper changes and 2 errors happened while you were busy"},
+	}
+	for _, tt := range tests {
+		var q quietTally
+		for range tt.changes {
+			q.change()
+		}
+		for range tt.errors {
+			q.error()
+		}
+		if got := q.take(); got != tt.want {
+			t.Errorf("%d changes, %d errors: take = %q, want %q", tt.changes, tt.errors, got, tt.want)
+		}
+		if again := q.take(); again != "" {
+			t.Errorf("take after take = %q, want the tally reset", again)
+		}
+	}
+}
+
+func TestSuppressedNotifications(t *testing.T) {
+	set := suppress(t, true)
+	NoteChange()
+	NoteChange()
+	ShowMessage("Wallpaper updated", "not shown")
+	ShowError("download failed")
+	if _, ok := trayErrors[errSuppressed]; !ok {
+		t.Fatal("an error while suppressed left no tray badge")
+	}
+
+	pollNotifications(time.Now()) // still suppressed: nothing is summed up
+	if _, ok := trayErrors[errSuppressed]; !ok {
+		t.Fatal("badge cleared while still suppressed")
+	}
+
+	set(false)
+	NoteChange() // not counted once notifications are back
+	pollNotifications(time.Now())
+	if _, ok := trayErrors[errSuppressed]; ok {
+		t.Error("badge kept after the summary")
+	}
+	if got := quiet.take(); got != "" {
+		t.Errorf("tally after the summary = %q, want it reset", got)
+	}
+
+	set(true)
+	NoteChange()
+	if got := quiet.take(); got != "1 wallpaper change happened while you were busy" {
+		t.Errorf("next suppression tally = %q", got)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	cfg := config.Default()
+	cfg.Notifications = map[string]string{
+		EventSuccess:     "daily_summary",
+		EventRateLimited: "never",
+		EventFailure:     "daily_summary",
+	}
+	cfg.NotificationSummaryTime = "20:00"
+	d := dispatcher{live: config.NewLive(cfg)}
+
+	routes := []struct {
+		event string
+		shown bool
+	}{
+		{EventSuccess, false},
+		{EventSuccess, false},
+		{EventFailure, false},
+		{EventRateLimited, false},
+		{EventOfflineFallback, true},
+		{EventSourceBlocked, true},
+	}
+	for _, r := range routes {
+		if got := d.route(r.event); got != r.shown {
+			t.Errorf("route(%s) = %v, want %v", r.event, got, r.shown)
+		}
+	}
+
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.Local)
+	if got := d.summary(day.Add(19 * time.Hour)); got != "" {
+		t.Errorf("summary before 20:00 = %q", got)
+	}
+	if got, want := d.summary(day.Add(20*time.Hour)), "2 wallpaper changes, 1 failed change"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+	d.route(EventSuccess)
+	if got := d.summary(day.Add(22 * time.Hour)); got != "" {
+		t.Errorf("second summary the same day = %q", got)
+	}
+	if got, want := d.summary(day.Add(44*time.Hour)), "1 wallpaper change"; got != want {
+		t.Errorf("next day's summary = %q, want %q", got, want)
+	}
+	if got := d.summary(day.Add(68 * time.Hour)); got != "" {
+		t.Errorf("summary with nothing held = %q", got)
+	}
+}
+
+func TestDispatcherBeforeWatch(t *testing.T) {
+	var d dispatcher
+	for _, event := range config.NotificationEvents {
+		if !d.route(event) {
+			t.Errorf("route(%s) dropped before preferences were loaded", event)
+		}
+	}
+	if got := d.summary(time.Now()); got != "" {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func TestEventNouns(t *testing.T) {
+	for _, event := range config.NotificationEvents {
+		if n := eventNouns[event]; n[0] == "" || n[1] == "" {
+			t.Errorf("no summary nouns for %s", event)
+		}
+	}
+}
+
+// TestDispatcherSequences routes sequences of events under different
+// preferences and checks what is shown now and what the summary adds up.
+func TestDispatcherSequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefs   map[string]string
+		events  []string
+		shown   int
+		summary string
+	}{
+		{"all shown by default", nil,
+			[]string{EventSuccess, EventFailure, EventUpdateAvailable}, 3, ""},
+		{"never drops without summing up", map[string]string{EventSuccess: "never"},
+			[]string{EventSuccess, EventSuccess, EventFailure}, 1, ""},
+		{"update notices held", map[string]string{EventUpdateAvailable: "daily_summary"},
+			[]string{EventUpdateAvailable, EventSuccess, EventUpdateAvailable}, 1, "2 update notices"},
+		{"summary in event order", map[string]string{
+			EventSourceBlocked: "daily_summary", EventUpdateAvailable: "daily_summary", EventRateLimited: "daily_summary"},
+			[]string{EventSourceBlocked, EventUpdateAvailable, EventRateLimited, EventRateLimited},
+			0, "2 rate-limited sources, 1 update notice, 1 blocked source warning"},
+		{"mixed modes", map[string]string{EventSuccess: "never", EventFailure: "always", EventOfflineFallback: "daily_summary"},
+			[]string{EventSuccess, EventFailure, EventOfflineFallback, EventFailure, EventSuccess}, 2, "1 fallback to history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Notifications = tt.prefs
+			cfg.NotificationSummaryTime = "20:00"
+			d := dispatcher{live: config.NewLive(cfg)}
+			shown := 0
+			for _, event := range tt.events {
+				if d.route(event) {
+					shown++
+				}
+			}
+			if shown != tt.shown {
+				t.Errorf("shown %d now, want %d", shown, tt.shown)
+			}
+			evening := time.Date(2026, 3, 14, 21, 0, 0, 0, time.Local)
+			if got := d.summary(evening); got != tt.summary {
+				t.Errorf("summary = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
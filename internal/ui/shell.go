@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"wallpaper-changer/internal/tray"
+)
+
+const (
+	// MB_OK | MB_ICONINFORMATION | MB_SETFOREGROUND
+	mbInfo = 0x00000040 | 0x00010000
+)
+
+var (
+	user32                     = syscall.NewLazyDLL("user32.dll")
+	shell32                    = syscall.NewLazyDLL("shell32.dll")
+	procFindWindow             = user32.NewProc("FindWindowW")
+	procRegisterWindowMessage  = user32.NewProc("RegisterWindowMessageW")
+	procPostMessage            = user32.NewProc("PostMessageW")
+	procMessageBox             = user32.NewProc("MessageBoxW")
+	procShellNotifyIconGetRect = shell32.NewProc("Shell_NotifyIconGetRect")
+
+	taskbarCreated = sync.OnceValue(func() uint32 {
+		name, _ := syscall.UTF16PtrFromString("TaskbarCreated")
+		msg, _, _ := procRegisterWindowMessage.Call(uintptr(unsafe.Pointer(name)))
+		return uint32(msg)
+	})
+)
+
+// notifyIconIdentifier is NOTIFYICONIDENTIFIER.
+type notifyIconIdentifier struct {
+	size     uint32
+	hwnd     uintptr
+	id       uint32
+	guidItem [16]byte
+}
+
+// findWindow returns the first top-level window of class, or 0.
+func findWindow(class string) uintptr {
+	name, _ := syscall.UTF16PtrFromString(class)
+	hwnd, _, _ := procFindWindow.Call(uintptr(unsafe.Pointer(name)), 0)
+	return hwnd
+}
+
+// TaskbarCreated is the message Explorer broadcasts when it (re)creates the
+// notification area, e.g. after a crash.
+func TaskbarCreated() uint32 { return taskbarCreated() }
+
+// TaskbarPresent reports whether Explorer's taskbar, which hosts the
+// notification area, exists. Without it the tray icon can't be added.
+func TaskbarPresent() bool {
+	return findWindow("Shell_TrayWnd") != 0
+}
+
+// RestoreIcon re-adds the tray icon if the notification area lost it, which
+// happens when Explorer restarts while the shell is too busy to take the icon
+// back on TaskbarCreated. It reports whether the icon had to be re-added.
+func RestoreIcon() bool {
+	if !Ready() || !TaskbarPresent() {
+		return false
+	}
+	class, id := tray.IconWindow()
+	if class == "" {
+		return false
+	}
+	hwnd := findWindow(class)
+	if hwnd == 0 {
+		return false
+	}
+	nii := notifyIconIdentifier{hwnd: hwnd, id: id}
+	nii.size = uint32(unsafe.Sizeof(nii))
+	var rect [4]int32
+	if hr, _, _ := procShellNotifyIconGetRect.Call(uintptr(unsafe.Pointer(&nii)), uintptr(unsafe.Pointer(&rect))); hr == 0 {
+		return false
+	}
+	// The tray answers TaskbarCreated by adding its icon again, with the
+	// current image and tooltip.
+	procPostMessage.Call(hwnd, uintptr(taskbarCreated()), 0, 0)
+	return true
+}
+
+// ShowText shows text in a message box without blocking the caller, for
+// output too long for a notification. It is also logged.
+func ShowText(title, text string) {
+	fmt.Println(title + ":\n" + text)
+	t, _ := syscall.UTF16PtrFromString(title)
+	m, _ := syscall.UTF16PtrFromString(text)
+	go procMessageBox.Call(0, uintptr(unsafe.Pointer(m)), uintptr(unsafe.Pointer(t)), mbInfo)
+}
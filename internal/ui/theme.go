@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"sync"
+
+	"wallpaper-changer/internal/setter"
+	"wallpaper-changer/internal/tray"
+)
+
+// Icons are the tray icon variants: Light is drawn for dark taskbars, Dark
+// for light ones.
+type Icons struct {
+	Light, Dark, Color []byte
+}
+
+var (
+	iconMu      sync.Mutex
+	currentIcon string
+)
+
+// ApplyIconTheme sets the icon for config "icon_theme"; "auto" follows the
+// system theme. The icon is only replaced when the variant changes, and not
+// at all before the tray is ready.
+func ApplyIconTheme(icons Icons, theme string) {
+	variant := theme
+	if theme == "auto" {
+		variant = "light"
+		if setter.SystemUsesLightTheme() {
+			variant = "dark"
+		}
+	}
+	data := icons.Color
+	switch variant {
+	case "light":
+		data = icons.Light
+	case "dark":
+		data = icons.Dark
+	}
+	if !Ready() {
+		return // onReady applies the theme
+	}
+	iconMu.Lock()
+	defer iconMu.Unlock()
+	if variant == currentIcon || len(data) == 0 {
+		return
+	}
+	currentIcon = variant
+	tray.SetIcon(data)
+}
@@ -0,0 +1,193 @@
+// Package ui is the system tray: status, messages and menus.
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wallpaper-changer/internal/tray"
+)
+
+const (
+	trayTitle   = "GoWallpaper"
+	trayTooltip = "Daily wallpaper changer from wallscloud.net"
+	// maxTooltipLen is what the notification area shows (szTip is 128
+	// UTF-16 units including the terminator).
+	maxTooltipLen = 127
+)
+
+// MenuItem is an item of the tray menu.
+type MenuItem = tray.Item
+
+// RunTray shows the tray icon and blocks until QuitTray, calling onReady
+// once the icon is up.
+func RunTray(onReady, onExit func()) { tray.Run(onReady, onExit) }
+
+// QuitTray removes the icon and ends RunTray.
+func QuitTray() { tray.Quit() }
+
+// AddMenuItem appends an item to the tray menu.
+func AddMenuItem(title, tooltip string) MenuItem { return tray.AddItem(title, tooltip) }
+
+// ErrDataDir keys the tray error shown while the app dir is unavailable.
+const ErrDataDir = "datadir"
+
+var (
+	trayMu     sync.Mutex
+	trayReady  bool
+	trayErrors = map[string]string{}
+	trayInfo   *WallpaperInfo
+	trayNote   string
+)
+
+// WallpaperInfo describes the current wallpaper for the tooltip.
+type WallpaperInfo struct {
+	Title, Author, Source string
+	// Initiator is who asked for the change, such as "scheduled"; "" when
+	// unknown.
+	Initiator           string
+	Applied, NextChange time.Time
+}
+
+func (i WallpaperInfo) lines() []string {
+	var out []string
+	if i.Title != "" {
+		out = append(out, i.Title)
+	}
+	src := i.Source
+	if i.Author != "" {
+		src = i.Author + " via " + src
+	}
+	set := src + ", set " + i.Applied.Format("Jan 2 15:04")
+	if i.Initiator != "" {
+		set += " (" + i.Initiator + ")"
+	}
+	out = append(out, set)
+	if !i.NextChange.IsZero() {
+		out = append(out, "Next: "+i.NextChange.Format("Jan 2 15:04"))
+	}
+	return out
+}
+
+// SetNote adds a status line, such as a deferred change, below the
+// wallpaper info; "" removes it.
+func SetNote(msg string) {
+	trayMu.Lock()
+	trayNote = msg
+	trayMu.Unlock()
+	refreshStatus()
+}
+
+// SetWallpaperInfo shows info in the tooltip while there are no errors; nil
+// restores the plain tooltip.
+func SetWallpaperInfo(info *WallpaperInfo) {
+	trayMu.Lock()
+	trayInfo = info
+	trayMu.Unlock()
+	refreshStatus()
+}
+
+// SetError records a persistent problem shown in the tray until cleared.
+func SetError(key, msg string) {
+	trayMu.Lock()
+	trayErrors[key] = msg
+	trayMu.Unlock()
+	refreshStatus()
+}
+
+// ClearError removes the problem recorded under key.
+func ClearError(key string) {
+	trayMu.Lock()
+	delete(trayErrors, key)
+	trayMu.Unlock()
+	refreshStatus()
+}
+
+// MarkReady is called once the tray icon is up; errors set earlier show from then on.
+func MarkReady() {
+	trayMu.Lock()
+	trayReady = true
+	trayMu.Unlock()
+	refreshStatus()
+}
+
+func refreshStatus() {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if !trayReady {
+		return
+	}
+	title, tooltip := statusLocked()
+	tray.SetTitle(title)
+	tray.SetTooltip(truncateTooltip(tooltip))
+}
+
+// statusLocked returns the tray title and the untruncated tooltip. trayMu
+// must be held.
+func statusLocked() (title, tooltip string) {
+	if len(trayErrors) == 0 {
+		lines := []string{trayTooltip}
+		if trayInfo != nil {
+			lines = append([]string{trayTitle}, trayInfo.lines()...)
+		}
+		if trayNote != "" {
+			lines = append(lines, trayNote)
+		}
+		return trayTitle, strings.Join(lines, "\n")
+	}
+	msgs := make([]string, 0, len(trayErrors))
+	for _, m := range trayErrors {
+		msgs = append(msgs, m)
+	}
+	sort.Strings(msgs)
+	return trayTitle + " (!)", "⚠ " + strings.Join(msgs, "\n⚠ ")
+}
+
+// Status is what the tooltip shows, in full, for "go-wallpaper-tray status"
+// and for running without a tray icon.
+func Status() string {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	_, tooltip := statusLocked()
+	return tooltip
+}
+
+// Ready reports whether the tray icon and menu are up.
+func Ready() bool {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	return trayReady
+}
+
+func truncateTooltip(s string) string {
+	r := []rune(s)
+	if len(r) <= maxTooltipLen {
+		return s
+	}
+	return string(r[:maxTooltipLen-1]) + "…"
+}
+
+// ShowMessage reports the outcome of a user action. While notifications are
+// suppressed it is only logged.
+func ShowMessage(title, msg string) {
+	if suppressed() {
+		fmt.Println(title+" (notifications suppressed):", msg)
+		return
+	}
+	fmt.Println(title+":", msg)
+}
+
+// ShowError reports a failure. While notifications are suppressed it turns
+// into the tray error badge and is summed up once they resume.
+func ShowError(msg string) {
+	if suppressed() {
+		fmt.Println("Error (notifications suppressed):", msg)
+		quiet.error()
+		SetError(errSuppressed, "Error while notifications were suppressed: "+msg)
+		return
+	}
+	fmt.Println("Error:", msg)
+}
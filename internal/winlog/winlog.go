@@ -0,0 +1,131 @@
+// Package winlog writes the events an administrator needs to the Windows
+// Application event log, for when nobody watches the tray or the log file.
+package winlog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// SourceName is the event source the app's events are logged under.
+const SourceName = "GoWallpaper"
+
+// Event IDs. EventCreate.exe, registered as the source's message file,
+// formats IDs 1-1000 as just the event's text, so Event Viewer never says
+// "the description for Event ID ... cannot be found".
+const (
+	EventChangeFailed  = 1
+	EventSourceBlocked = 2
+	EventDiskFull      = 3
+	EventDailySummary  = 100
+)
+
+const sourceKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + SourceName
+
+var (
+	mu  sync.Mutex
+	log *eventlog.Log
+	// failures counts the failed changes since the last daily summary.
+	failures int
+)
+
+// Install registers the event source; it needs administrator rights.
+func Install() error {
+	return eventlog.InstallAsEventCreate(SourceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+}
+
+// Remove unregisters the event source.
+func Remove() error {
+	return eventlog.Remove(SourceName)
+}
+
+// Enable starts logging events, unless the event source isn't installed:
+// events of an unregistered source show up without their text. Calling it
+// again does nothing.
+func Enable(reason string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if log != nil {
+		return
+	}
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, sourceKey, registry.QUERY_VALUE)
+	if err != nil {
+		fmt.Printf("event log (%s): event source %s is not installed; run \"go-wallpaper-tray eventlog install\" as administrator\n",
+			reason, SourceName)
+		return
+	}
+	k.Close()
+	l, err := eventlog.Open(SourceName)
+	if err != nil {
+		fmt.Printf("event log (%s) unavailable: %v\n", reason, err)
+		return
+	}
+	log = l
+	fmt.Printf("event log (%s): logging to the Application log as %s\n", reason, SourceName)
+}
+
+// ChangeFailed logs a change that failed for good, as a disk-full event
+// when that is why.
+func ChangeFailed(err error) {
+	mu.Lock()
+	failures++
+	mu.Unlock()
+	if DiskFull(err) {
+		report(eventlog.Error, EventDiskFull, "Wallpaper change failed, the disk is full: "+err.Error())
+		return
+	}
+	report(eventlog.Error, EventChangeFailed, "Wallpaper change failed after all retries: "+err.Error())
+}
+
+// SourceBlocked logs a source that keeps being blocked.
+func SourceBlocked(msg string) {
+	report(eventlog.Error, EventSourceBlocked, "Wallpaper source blocked: "+msg)
+}
+
+// StorageFailed logs a failed state write if the disk is full; other
+// causes, such as a roaming profile mid-sync, sort themselves out.
+func StorageFailed(err error) {
+	if DiskFull(err) {
+		report(eventlog.Error, EventDiskFull, "Saving state failed, the disk is full: "+err.Error())
+	}
+}
+
+// DailySummary logs the day's successful change of a wallpaper called desc
+// with the failures since the previous summary.
+func DailySummary(desc string) {
+	mu.Lock()
+	n := failures
+	failures = 0
+	mu.Unlock()
+	report(eventlog.Info, EventDailySummary,
+		fmt.Sprintf("Daily wallpaper change succeeded: %s. Failed changes since the last summary: %d.", desc, n))
+}
+
+// DiskFull reports whether err comes from a full disk.
+func DiskFull(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
+
+func report(etype uint32, id uint32, msg string) {
+	mu.Lock()
+	l := log
+	mu.Unlock()
+	if l == nil {
+		return
+	}
+	var err error
+	switch etype {
+	case eventlog.Info:
+		err = l.Info(id, msg)
+	default:
+		err = l.Error(id, msg)
+	}
+	if err != nil {
+		fmt.Println("event log:", err)
+	}
+}
@@ -0,0 +1,29 @@
+package winlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestDiskFull(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{windows.ERROR_DISK_FULL, true},
+		{&os.PathError{Op: "write", Path: `C:\x`, Err: windows.ERROR_HANDLE_DISK_FULL}, true},
+		{fmt.Errorf("saving state: %w", windows.ERROR_DISK_FULL), true},
+		{windows.ERROR_ACCESS_DENIED, false},
+		{errors.New("disk full"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := DiskFull(tt.err); got != tt.want {
+			t.Errorf("DiskFull(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package winmsg runs the hidden window that receives broadcast window
+// messages (device arrivals, setting changes, session locks) for the rest
+// of the app.
+package winmsg
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Messages handlers commonly look for.
+const (
+	WMSettingChange = 0x001A
+	WMDeviceChange  = 0x0219
+	WMDropFiles     = 0x0233
+	// WMSessionChange comes with one of the WTSSession codes as wParam
+	// once NotifySession is on.
+	WMSessionChange = 0x02B1
+
+	WTSSessionLock   = 0x7
+	WTSSessionUnlock = 0x8
+
+	wmClose          = 0x0010
+	wmDestroy        = 0x0002
+	wmCopyData       = 0x004A
+	wmCopyGlobalData = 0x0049
+	msgfltAllow      = 1
+	notifyForSession = 0 // NOTIFY_FOR_THIS_SESSION
+)
+
+var (
+	user32               = syscall.NewLazyDLL("user32.dll")
+	kernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procRegisterClassEx  = user32.NewProc("RegisterClassExW")
+	procUnregisterClass  = user32.NewProc("UnregisterClassW")
+	procCreateWindowEx   = user32.NewProc("CreateWindowExW")
+	procDefWindowProc    = user32.NewProc("DefWindowProcW")
+	procGetMessage       = user32.NewProc("GetMessageW")
+	procDispatchMessage  = user32.NewProc("DispatchMessageW")
+	procPostMessage      = user32.NewProc("PostMessageW")
+	procDestroyWindow    = user32.NewProc("DestroyWindow")
+	procPostQuitMessage  = user32.NewProc("PostQuitMessage")
+	procGetModuleHandleW = kernel32.NewProc("GetModuleHandleW")
+
+	procChangeWindowMessageFilterEx = user32.NewProc("ChangeWindowMessageFilterEx")
+	procDragAcceptFiles             = syscall.NewLazyDLL("shell32.dll").NewProc("DragAcceptFiles")
+
+	wtsapi32                 = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSRegisterSession   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSession = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+)
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   syscall.Handle
+	icon       syscall.Handle
+	cursor     syscall.Handle
+	background syscall.Handle
+	menuName   *uint16
+	className  *uint16
+	iconSm     syscall.Handle
+}
+
+type winMsg struct {
+	hwnd    syscall.Handle
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+}
+
+// Handler sees every message the window gets, on the window's thread; it
+// must not block. lParam pointers are only valid during the call.
+type Handler func(msg uint32, wParam, lParam uintptr)
+
+// Window is an invisible top-level window. It is not a message-only window
+// because those get no broadcasts.
+type Window struct {
+	hwnd uintptr
+	done chan struct{}
+
+	mu       sync.Mutex
+	handlers []Handler
+	session  bool
+}
+
+// Start creates the window with its own message loop.
+func Start(className string) (*Window, error) {
+	w := &Window{done: make(chan struct{})}
+	started := make(chan error, 1)
+	go w.loop(className, started)
+	if err := <-started; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// HWND returns the window's handle, for APIs such as Shell_NotifyIcon that
+// send it messages.
+func (w *Window) HWND() uintptr { return w.hwnd }
+
+// Handle adds h to the handlers called for each message.
+func (w *Window) Handle(h Handler) {
+	w.mu.Lock()
+	w.handlers = append(w.handlers, h)
+	w.mu.Unlock()
+}
+
+// AcceptFiles turns WMDropFiles for files dropped on the window on or off.
+// The drop messages are let through UIPI, so drops from a non-elevated
+// Explorer reach an elevated app too.
+func (w *Window) AcceptFiles(accept bool) {
+	var on uintptr
+	if accept {
+		on = 1
+		for _, msg := range []uintptr{WMDropFiles, wmCopyData, wmCopyGlobalData} {
+			procChangeWindowMessageFilterEx.Call(w.hwnd, msg, msgfltAllow, 0)
+		}
+	}
+	procDragAcceptFiles.Call(w.hwnd, on)
+}
+
+// NotifySession has the window told about this session's locks and
+// unlocks with WMSessionChange.
+func (w *Window) NotifySession() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.session {
+		return nil
+	}
+	if r, _, err := procWTSRegisterSession.Call(w.hwnd, notifyForSession); r == 0 {
+		return fmt.Errorf("WTSRegisterSessionNotification: %w", err)
+	}
+	w.session = true
+	return nil
+}
+
+// Close destroys the window and waits for its message loop to end.
+func (w *Window) Close() {
+	procPostMessage.Call(w.hwnd, wmClose, 0, 0)
+	<-w.done
+}
+
+func (w *Window) dispatch(msg uint32, wParam, lParam uintptr) {
+	w.mu.Lock()
+	handlers := w.handlers
+	w.mu.Unlock()
+	for _, h := range handlers {
+		h(msg, wParam, lParam)
+	}
+}
+
+func (w *Window) loop(className string, started chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(w.done)
+
+	wndProc := syscall.NewCallback(func(hwnd, msg, wParam, lParam uintptr) uintptr {
+		w.dispatch(uint32(msg), wParam, lParam)
+		switch msg {
+		case WMDeviceChange:
+			return 1 // TRUE: grant any device change request
+		case wmDestroy:
+			procPostQuitMessage.Call(0)
+			return 0
+		}
+		r, _, _ := procDefWindowProc.Call(hwnd, msg, wParam, lParam)
+		return r
+	})
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	name, _ := syscall.UTF16PtrFromString(className)
+	wc := wndClassEx{wndProc: wndProc, instance: syscall.Handle(instance), className: name}
+	wc.size = uint32(unsafe.Sizeof(wc))
+	if r, _, err := procRegisterClassEx.Call(uintptr(unsafe.Pointer(&wc))); r == 0 {
+		started <- fmt.Errorf("RegisterClassEx: %w", err)
+		return
+	}
+	defer procUnregisterClass.Call(uintptr(unsafe.Pointer(name)), instance)
+	hwnd, _, err := procCreateWindowEx.Call(0, uintptr(unsafe.Pointer(name)), 0, 0,
+		0, 0, 0, 0, 0, 0, instance, 0)
+	if hwnd == 0 {
+		started <- fmt.Errorf("CreateWindowEx: %w", err)
+		return
+	}
+	w.hwnd = hwnd
+	started <- nil
+
+	var m winMsg
+	for {
+		r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(r) <= 0 { // WM_QUIT or error
+			break
+		}
+		procDispatchMessage.Call(uintptr(unsafe.Pointer(&m)))
+	}
+	w.mu.Lock()
+	if w.session {
+		procWTSUnRegisterSession.Call(hwnd)
+	}
+	w.mu.Unlock()
+	procDestroyWindow.Call(hwnd)
+}
@@ -0,0 +1,27 @@
+package winmsg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartDispatchClose(t *testing.T) {
+	w, err := Start("GoWallpaperTrayTest")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got := make(chan uint32, 1)
+	const msg = 0x8000 + 42 // WM_APP + 42
+	w.Handle(func(m uint32, wParam, lParam uintptr) {
+		if m == msg {
+			got <- m
+		}
+	})
+	procPostMessage.Call(w.hwnd, msg, 0, 0)
+	select {
+	case <-got:
+	case <-time.After(5 * time.Second):
+		t.Error("posted message never reached the handler")
+	}
+	w.Close()
+}